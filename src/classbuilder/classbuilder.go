@@ -0,0 +1,598 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package classbuilder generates class files for tests, so that tests of the interpreter
+// and the classloader can be written in readable Go rather than as hex dumps. A class is
+// described with a fluent API, for example:
+//
+//	bytes, err := NewClassBuilder("TestClass").SuperClass("java/lang/Object").
+//		AddMethod("test", "()I").AddOpcode(jvm.ICONST_1).AddOpcode(jvm.IRETURN).Build()
+//
+// The constant pool is built automatically: opcodes that refer to the CP (ldc, invokestatic,
+// getstatic, new, etc.) are passed their constants or symbolic references as operands, and
+// the builder creates the CP entries and emits their indexes. Likewise, the bootstrap
+// methods of invokedynamic instructions are gathered into the BootstrapMethods attribute.
+package classbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// the opcodes whose operands are CP references. These duplicate the definitions in
+// package jvm, so that this package doesn't depend on jvm and jvm's tests can use it.
+const (
+	ldc             = 0x12
+	ldcW            = 0x13
+	ldc2W           = 0x14
+	getstatic       = 0xB2
+	putstatic       = 0xB3
+	getfield        = 0xB4
+	putfield        = 0xB5
+	invokevirtual   = 0xB6
+	invokespecial   = 0xB7
+	invokestatic    = 0xB8
+	invokedynamic   = 0xBA
+	newObject       = 0xBB
+	anewarray       = 0xBD
+	checkcast       = 0xC0
+	instanceof      = 0xC1
+	multianewarray  = 0xC5
+	defaultVersion  = 55 // Java 11
+	defaultMaxStack = 16
+	defaultMaxLocal = 16
+)
+
+// the CP entry tags. See https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.4
+const (
+	tagUTF8               = 1
+	tagInteger            = 3
+	tagFloat              = 4
+	tagLong               = 5
+	tagDouble             = 6
+	tagClass              = 7
+	tagString             = 8
+	tagFieldref           = 9
+	tagMethodref          = 10
+	tagInterfaceMethodref = 11
+	tagNameAndType        = 12
+	tagMethodHandle       = 15
+	tagMethodType         = 16
+	tagInvokeDynamic      = 18
+)
+
+// MethodType is a method type descriptor, such as ()V, to be loaded as a MethodType
+// constant, e.g., as a static argument of a bootstrap method
+type MethodType string
+
+// MethodHandle is a method handle constant: a reference kind (1-9, e.g., 6 for
+// REF_invokeStatic) and the field or method it refers to
+type MethodHandle struct {
+	Kind  byte
+	Class string
+	Name  string
+	Desc  string
+}
+
+// Bootstrap is the bootstrap method of an invokedynamic instruction: the method handle
+// of the bootstrap method and its static arguments, each of which is a constant that
+// ldc accepts, a MethodType, or a MethodHandle
+type Bootstrap struct {
+	Method MethodHandle
+	Args   []interface{}
+}
+
+// ClassBuilder accumulates the parts of a class file. Errors in the calls to its methods
+// are recorded and returned by Build(), so that calls can be chained.
+type ClassBuilder struct {
+	name        string
+	superClass  string
+	interfaces  []string
+	permitted   []string // the permitted subclasses of a sealed class
+	accessFlags uint16
+	version     uint16
+	cp          []byte         // the serialized CP entries
+	cpCount     uint16         // the number of CP slots used, including the unused slot 0
+	cpIndexes   map[string]int // the CP index of each entry, so entries aren't duplicated
+	methods     []*methodDef
+	bootstraps  [][]int        // for each bootstrap method, the CP indexes of its handle and args
+	bsmIndexes  map[string]int // the index of each bootstrap method, so they aren't duplicated
+	err         error
+}
+
+type methodDef struct {
+	name        string
+	desc        string
+	accessFlags uint16
+	maxStack    uint16
+	maxLocals   uint16
+	code        []byte
+}
+
+// NewClassBuilder starts the definition of a public class with the given name, which is
+// in internal form (e.g., java/lang/Object). The superclass defaults to java/lang/Object.
+func NewClassBuilder(name string) *ClassBuilder {
+	return &ClassBuilder{
+		name:        name,
+		superClass:  "java/lang/Object",
+		accessFlags: 0x0021, // ACC_PUBLIC, ACC_SUPER
+		version:     defaultVersion,
+		cpCount:     1,
+		cpIndexes:   make(map[string]int),
+		bsmIndexes:  make(map[string]int),
+	}
+}
+
+// SuperClass sets the superclass of the class
+func (cb *ClassBuilder) SuperClass(name string) *ClassBuilder {
+	cb.superClass = name
+	return cb
+}
+
+// Interfaces sets the interfaces that the class implements
+func (cb *ClassBuilder) Interfaces(names ...string) *ClassBuilder {
+	cb.interfaces = names
+	return cb
+}
+
+// PermittedSubclasses makes the class sealed, permitting only the named classes to
+// extend or implement it. Sealed classes require a class-file version of 61 or later.
+func (cb *ClassBuilder) PermittedSubclasses(names ...string) *ClassBuilder {
+	cb.permitted = names
+	return cb
+}
+
+// AccessFlags sets the access flags of the class
+func (cb *ClassBuilder) AccessFlags(flags uint16) *ClassBuilder {
+	cb.accessFlags = flags
+	return cb
+}
+
+// Version sets the major version of the class file (e.g., 55 for Java 11)
+func (cb *ClassBuilder) Version(major uint16) *ClassBuilder {
+	cb.version = major
+	return cb
+}
+
+// AddMethod adds a public static method with the given name and type descriptor. The
+// opcodes that follow are added to this method, until the next call to AddMethod().
+func (cb *ClassBuilder) AddMethod(name string, desc string) *ClassBuilder {
+	cb.methods = append(cb.methods, &methodDef{
+		name:        name,
+		desc:        desc,
+		accessFlags: 0x0009, // ACC_PUBLIC, ACC_STATIC
+		maxStack:    defaultMaxStack,
+		maxLocals:   defaultMaxLocal,
+	})
+	return cb
+}
+
+// MethodAccessFlags sets the access flags of the current method
+func (cb *ClassBuilder) MethodAccessFlags(flags uint16) *ClassBuilder {
+	if m := cb.currentMethod("MethodAccessFlags"); m != nil {
+		m.accessFlags = flags
+	}
+	return cb
+}
+
+// MaxStack sets the maximum operand stack depth of the current method
+func (cb *ClassBuilder) MaxStack(size uint16) *ClassBuilder {
+	if m := cb.currentMethod("MaxStack"); m != nil {
+		m.maxStack = size
+	}
+	return cb
+}
+
+// MaxLocals sets the number of local variables of the current method
+func (cb *ClassBuilder) MaxLocals(size uint16) *ClassBuilder {
+	if m := cb.currentMethod("MaxLocals"); m != nil {
+		m.maxLocals = size
+	}
+	return cb
+}
+
+// AddOpcode appends an instruction to the current method. For instructions that refer
+// to the CP, the operands are the referenced items, and the CP entries are created here:
+//
+//	ldc, ldc_w:      a string, an int32 (or int), a float32, a MethodType, or a MethodHandle
+//	ldc2_w:          an int64 or a float64
+//	get/putstatic, get/putfield, invokevirtual, invokespecial, invokestatic:
+//	                 the class, the name, and the type descriptor, as three strings
+//	new, anewarray, checkcast, instanceof: the class name
+//	multianewarray:  the class name and the number of dimensions
+//	invokedynamic:   a Bootstrap, then the name and the type descriptor of the call site
+//
+// ldc is widened to ldc_w if the CP index doesn't fit in a byte. For all other
+// instructions, the operands are bytes (or ints that fit in a byte) copied as is.
+func (cb *ClassBuilder) AddOpcode(opcode byte, operands ...interface{}) *ClassBuilder {
+	m := cb.currentMethod("AddOpcode")
+	if m == nil {
+		return cb
+	}
+
+	switch opcode {
+	case ldc, ldcW:
+		idx, err := cb.loadableConstant(operands)
+		if err != nil {
+			cb.setError(err)
+			return cb
+		}
+		if opcode == ldc && idx <= 0xFF {
+			m.code = append(m.code, ldc, byte(idx))
+		} else {
+			m.code = append(m.code, ldcW, byte(idx>>8), byte(idx))
+		}
+	case ldc2W:
+		idx, err := cb.loadableConstant(operands)
+		if err != nil {
+			cb.setError(err)
+			return cb
+		}
+		m.code = append(m.code, ldc2W, byte(idx>>8), byte(idx))
+	case getstatic, putstatic, getfield, putfield,
+		invokevirtual, invokespecial, invokestatic:
+		class, name, desc, err := memberOperands(opcode, operands)
+		if err != nil {
+			cb.setError(err)
+			return cb
+		}
+		tag := byte(tagMethodref)
+		if opcode <= putfield {
+			tag = tagFieldref
+		}
+		idx := cb.memberRef(tag, class, name, desc)
+		m.code = append(m.code, opcode, byte(idx>>8), byte(idx))
+	case invokedynamic:
+		idx, err := cb.invokeDynamic(operands)
+		if err != nil {
+			cb.setError(err)
+			return cb
+		}
+		m.code = append(m.code, invokedynamic, byte(idx>>8), byte(idx), 0, 0)
+	case newObject, anewarray, checkcast, instanceof, multianewarray:
+		class, ok := firstString(operands)
+		if !ok {
+			cb.setError(fmt.Errorf("opcode 0x%02X requires a class name", opcode))
+			return cb
+		}
+		idx := cb.classRef(class)
+		m.code = append(m.code, opcode, byte(idx>>8), byte(idx))
+		if opcode == multianewarray {
+			if len(operands) != 2 {
+				cb.setError(errors.New("multianewarray requires a class name and a dimension count"))
+				return cb
+			}
+			dims, err := operandByte(operands[1])
+			if err != nil {
+				cb.setError(err)
+				return cb
+			}
+			m.code = append(m.code, dims)
+		}
+	default:
+		m.code = append(m.code, opcode)
+		for _, operand := range operands {
+			b, err := operandByte(operand)
+			if err != nil {
+				cb.setError(err)
+				return cb
+			}
+			m.code = append(m.code, b)
+		}
+	}
+	return cb
+}
+
+// Build returns the bytes of the class file, or the first error found while building it
+func (cb *ClassBuilder) Build() ([]byte, error) {
+	if cb.err != nil {
+		return nil, cb.err
+	}
+	if cb.name == "" {
+		return nil, errors.New("class name is empty")
+	}
+
+	// all CP entries must be created before the CP is written out
+	thisClass := cb.classRef(cb.name)
+	superClass := 0
+	if cb.superClass != "" {
+		superClass = cb.classRef(cb.superClass)
+	}
+	interfaces := make([]int, len(cb.interfaces))
+	for i, name := range cb.interfaces {
+		interfaces[i] = cb.classRef(name)
+	}
+	type methodIndexes struct{ name, desc, code int }
+	indexes := make([]methodIndexes, len(cb.methods))
+	for i, m := range cb.methods {
+		indexes[i] = methodIndexes{cb.utf8(m.name), cb.utf8(m.desc), 0}
+		if len(m.code) > 0 {
+			indexes[i].code = cb.utf8("Code")
+		}
+	}
+	bootstrapsName := 0
+	if len(cb.bootstraps) > 0 {
+		bootstrapsName = cb.utf8("BootstrapMethods")
+	}
+	permittedName := 0
+	permitted := make([]int, len(cb.permitted))
+	if len(cb.permitted) > 0 {
+		permittedName = cb.utf8("PermittedSubclasses")
+		for i, name := range cb.permitted {
+			permitted[i] = cb.classRef(name)
+		}
+	}
+
+	out := []byte{0xCA, 0xFE, 0xBA, 0xBE}
+	out = appendU2(out, 0) // minor version
+	out = appendU2(out, int(cb.version))
+	out = appendU2(out, int(cb.cpCount))
+	out = append(out, cb.cp...)
+	out = appendU2(out, int(cb.accessFlags))
+	out = appendU2(out, thisClass)
+	out = appendU2(out, superClass)
+	out = appendU2(out, len(interfaces))
+	for _, index := range interfaces {
+		out = appendU2(out, index)
+	}
+	out = appendU2(out, 0) // fields
+
+	out = appendU2(out, len(cb.methods))
+	for i, m := range cb.methods {
+		out = appendU2(out, int(m.accessFlags))
+		out = appendU2(out, indexes[i].name)
+		out = appendU2(out, indexes[i].desc)
+		if len(m.code) == 0 { // abstract and native methods have no Code attribute
+			out = appendU2(out, 0)
+			continue
+		}
+		out = appendU2(out, 1) // the Code attribute
+		out = appendU2(out, indexes[i].code)
+		out = appendU4(out, 12+len(m.code)) // the attribute length
+		out = appendU2(out, int(m.maxStack))
+		out = appendU2(out, int(m.maxLocals))
+		out = appendU4(out, len(m.code))
+		out = append(out, m.code...)
+		out = appendU2(out, 0) // exception table
+		out = appendU2(out, 0) // attributes of the Code attribute
+	}
+
+	attrCount := 0
+	if len(cb.bootstraps) > 0 {
+		attrCount++
+	}
+	if len(permitted) > 0 {
+		attrCount++
+	}
+	out = appendU2(out, attrCount) // class attributes
+
+	// the BootstrapMethods attribute
+	if len(cb.bootstraps) > 0 {
+		attr := appendU2(nil, len(cb.bootstraps))
+		for _, bsm := range cb.bootstraps {
+			attr = appendU2(attr, bsm[0])
+			attr = appendU2(attr, len(bsm)-1)
+			for _, arg := range bsm[1:] {
+				attr = appendU2(attr, arg)
+			}
+		}
+		out = appendU2(out, bootstrapsName)
+		out = appendU4(out, len(attr))
+		out = append(out, attr...)
+	}
+
+	// the PermittedSubclasses attribute
+	if len(permitted) > 0 {
+		attr := appendU2(nil, len(permitted))
+		for _, index := range permitted {
+			attr = appendU2(attr, index)
+		}
+		out = appendU2(out, permittedName)
+		out = appendU4(out, len(attr))
+		out = append(out, attr...)
+	}
+	return out, nil
+}
+
+// returns the method that opcodes and settings currently apply to, recording an error
+// if no method has been added yet
+func (cb *ClassBuilder) currentMethod(caller string) *methodDef {
+	if len(cb.methods) == 0 {
+		cb.setError(errors.New(caller + "() called before AddMethod()"))
+		return nil
+	}
+	return cb.methods[len(cb.methods)-1]
+}
+
+// keeps only the first error, as later errors are often consequences of it
+func (cb *ClassBuilder) setError(err error) {
+	if cb.err == nil {
+		cb.err = err
+	}
+}
+
+// ---- CP construction ----
+
+// adds a CP entry, unless an identical entry already exists, and returns its index.
+// longs and doubles take two CP slots.
+func (cb *ClassBuilder) addEntry(key string, entry []byte) int {
+	if idx, ok := cb.cpIndexes[key]; ok {
+		return idx
+	}
+	idx := int(cb.cpCount)
+	cb.cp = append(cb.cp, entry...)
+	cb.cpCount++
+	if entry[0] == tagLong || entry[0] == tagDouble {
+		cb.cpCount++
+	}
+	cb.cpIndexes[key] = idx
+	return idx
+}
+
+func (cb *ClassBuilder) utf8(s string) int {
+	// class files use modified UTF-8, which matches Go's UTF-8 for the strings tests use
+	entry := appendU2([]byte{tagUTF8}, len(s))
+	return cb.addEntry("utf8:"+s, append(entry, s...))
+}
+
+func (cb *ClassBuilder) classRef(name string) int {
+	nameIdx := cb.utf8(name)
+	return cb.addEntry("class:"+name, appendU2([]byte{tagClass}, nameIdx))
+}
+
+func (cb *ClassBuilder) nameAndType(name string, desc string) int {
+	entry := appendU2([]byte{tagNameAndType}, cb.utf8(name))
+	entry = appendU2(entry, cb.utf8(desc))
+	return cb.addEntry("nat:"+name+":"+desc, entry)
+}
+
+func (cb *ClassBuilder) memberRef(tag byte, class string, name string, desc string) int {
+	entry := appendU2([]byte{tag}, cb.classRef(class))
+	entry = appendU2(entry, cb.nameAndType(name, desc))
+	return cb.addEntry(fmt.Sprintf("%d:%s.%s%s", tag, class, name, desc), entry)
+}
+
+// creates the CP entry for the operand of an ldc, ldc_w, or ldc2_w
+func (cb *ClassBuilder) loadableConstant(operands []interface{}) (int, error) {
+	if len(operands) != 1 {
+		return 0, errors.New("ldc requires exactly one constant")
+	}
+	switch c := operands[0].(type) {
+	case string:
+		entry := appendU2([]byte{tagString}, cb.utf8(c))
+		return cb.addEntry("string:"+c, entry), nil
+	case int:
+		if c < math.MinInt32 || c > math.MaxInt32 {
+			return 0, fmt.Errorf("ldc constant %d does not fit in an int", c)
+		}
+		return cb.loadableConstant([]interface{}{int32(c)})
+	case int32:
+		return cb.addEntry(fmt.Sprintf("int:%d", c), appendU4([]byte{tagInteger}, int(uint32(c)))), nil
+	case float32:
+		entry := appendU4([]byte{tagFloat}, int(math.Float32bits(c)))
+		return cb.addEntry(fmt.Sprintf("float:%x", math.Float32bits(c)), entry), nil
+	case int64:
+		entry := binary.BigEndian.AppendUint64([]byte{tagLong}, uint64(c))
+		return cb.addEntry(fmt.Sprintf("long:%d", c), entry), nil
+	case float64:
+		entry := binary.BigEndian.AppendUint64([]byte{tagDouble}, math.Float64bits(c))
+		return cb.addEntry(fmt.Sprintf("double:%x", math.Float64bits(c)), entry), nil
+	case MethodType:
+		entry := appendU2([]byte{tagMethodType}, cb.utf8(string(c)))
+		return cb.addEntry("methodtype:"+string(c), entry), nil
+	case MethodHandle:
+		return cb.methodHandle(c)
+	default:
+		return 0, fmt.Errorf("unsupported ldc constant type: %T", c)
+	}
+}
+
+func (cb *ClassBuilder) methodHandle(mh MethodHandle) (int, error) {
+	var tag byte
+	switch {
+	case mh.Kind >= 1 && mh.Kind <= 4: // REF_getField, REF_getStatic, REF_putField, REF_putStatic
+		tag = tagFieldref
+	case mh.Kind >= 5 && mh.Kind <= 8: // REF_invokeVirtual, REF_invokeStatic, REF_invokeSpecial, REF_newInvokeSpecial
+		tag = tagMethodref
+	case mh.Kind == 9: // REF_invokeInterface
+		tag = tagInterfaceMethodref
+	default:
+		return 0, fmt.Errorf("invalid method handle reference kind: %d", mh.Kind)
+	}
+	entry := appendU2([]byte{tagMethodHandle, mh.Kind}, cb.memberRef(tag, mh.Class, mh.Name, mh.Desc))
+	return cb.addEntry(fmt.Sprintf("handle:%d:%s.%s%s", mh.Kind, mh.Class, mh.Name, mh.Desc), entry), nil
+}
+
+// creates the CP entry for the operands of an invokedynamic, and the bootstrap method
+// it refers to
+func (cb *ClassBuilder) invokeDynamic(operands []interface{}) (int, error) {
+	var bootstrap Bootstrap
+	var name, desc string
+	ok := len(operands) == 3
+	if ok {
+		var ok1, ok2, ok3 bool
+		bootstrap, ok1 = operands[0].(Bootstrap)
+		name, ok2 = operands[1].(string)
+		desc, ok3 = operands[2].(string)
+		ok = ok1 && ok2 && ok3
+	}
+	if !ok {
+		return 0, errors.New("invokedynamic requires a Bootstrap, a name, and a type descriptor")
+	}
+
+	handle, err := cb.methodHandle(bootstrap.Method)
+	if err != nil {
+		return 0, err
+	}
+	bsm := []int{handle}
+	for _, arg := range bootstrap.Args {
+		idx, err := cb.loadableConstant([]interface{}{arg})
+		if err != nil {
+			return 0, err
+		}
+		bsm = append(bsm, idx)
+	}
+	key := fmt.Sprint(bsm)
+	bsmIndex, ok := cb.bsmIndexes[key]
+	if !ok {
+		bsmIndex = len(cb.bootstraps)
+		cb.bootstraps = append(cb.bootstraps, bsm)
+		cb.bsmIndexes[key] = bsmIndex
+	}
+
+	entry := appendU2([]byte{tagInvokeDynamic}, bsmIndex)
+	entry = appendU2(entry, cb.nameAndType(name, desc))
+	return cb.addEntry(fmt.Sprintf("indy:%d:%s:%s", bsmIndex, name, desc), entry), nil
+}
+
+// ---- operand handling ----
+
+func memberOperands(opcode byte, operands []interface{}) (string, string, string, error) {
+	if len(operands) == 3 {
+		class, ok1 := operands[0].(string)
+		name, ok2 := operands[1].(string)
+		desc, ok3 := operands[2].(string)
+		if ok1 && ok2 && ok3 {
+			return class, name, desc, nil
+		}
+	}
+	return "", "", "", fmt.Errorf(
+		"opcode 0x%02X requires a class, a name, and a type descriptor", opcode)
+}
+
+func firstString(operands []interface{}) (string, bool) {
+	if len(operands) == 0 {
+		return "", false
+	}
+	s, ok := operands[0].(string)
+	return s, ok
+}
+
+// converts an operand of an instruction that doesn't refer to the CP to a byte. Ints
+// are accepted so that negative offsets and constants can be written naturally.
+func operandByte(operand interface{}) (byte, error) {
+	switch v := operand.(type) {
+	case byte:
+		return v, nil
+	case int:
+		if v < math.MinInt8 || v > math.MaxUint8 {
+			return 0, fmt.Errorf("operand %d does not fit in a byte", v)
+		}
+		return byte(v), nil
+	default:
+		return 0, fmt.Errorf("unsupported operand type: %T", operand)
+	}
+}
+
+func appendU2(b []byte, v int) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendU4(b []byte, v int) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
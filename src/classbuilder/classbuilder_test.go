@@ -0,0 +1,198 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classbuilder
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/jvm"
+	"jacobin/log"
+	"testing"
+)
+
+// builds a class, then parses and posts it, returning the posted class's data
+func buildAndPost(t *testing.T, cb *ClassBuilder) *classloader.ClData {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	bytes, err := cb.Build()
+	if err != nil {
+		t.Fatalf("Build() returned an unexpected error: %s", err.Error())
+	}
+
+	name, err := classloader.ParseAndPostClass(classloader.AppCL, "TestClass.class", bytes)
+	if err != nil {
+		t.Fatalf("ParseAndPostClass() returned an unexpected error: %s", err.Error())
+	}
+	k, _ := classloader.LookupClass(name)
+	return k.Data
+}
+
+// returns the bytecodes of a method of the posted TestClass
+func methodCode(t *testing.T, name string, desc string) []byte {
+	classloader.MTable = make(map[string]classloader.MTentry)
+	me, err := classloader.FetchMethodAndCP("TestClass", name, desc)
+	if err != nil {
+		t.Fatalf("Did not find TestClass.%s%s: %s", name, desc, err.Error())
+	}
+	return me.Meth.(classloader.JmEntry).Code
+}
+
+func TestBuildSimpleClass(t *testing.T) {
+	cb := NewClassBuilder("TestClass").SuperClass("java/lang/Object").
+		AddMethod("test", "()I").AddOpcode(jvm.ICONST_1).AddOpcode(jvm.IRETURN)
+	class := buildAndPost(t, cb)
+
+	if class.Name != "TestClass" {
+		t.Errorf("Expected class name TestClass, got: %s", class.Name)
+	}
+	if class.Superclass != "java/lang/Object" {
+		t.Errorf("Expected superclass java/lang/Object, got: %s", class.Superclass)
+	}
+
+	code := methodCode(t, "test", "()I")
+	if len(code) != 2 || code[0] != jvm.ICONST_1 || code[1] != jvm.IRETURN {
+		t.Errorf("Expected code of iconst_1, ireturn, got: %v", code)
+	}
+}
+
+func TestBuildCreatesCPEntries(t *testing.T) {
+	cb := NewClassBuilder("TestClass").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(jvm.LDC, "hello").
+		AddOpcode(jvm.LDC, "hello"). // should reuse the first entry
+		AddOpcode(jvm.LDC2_W, int64(42)).
+		AddOpcode(jvm.INVOKESTATIC, "java/lang/Math", "abs", "(I)I").
+		AddOpcode(jvm.RETURN)
+	class := buildAndPost(t, cb)
+	cp := &class.CP
+
+	// string constants are posted as references to their UTF-8 entries
+	hellos, methodRefs, longs := 0, 0, 0
+	for _, utf8 := range cp.Utf8Refs {
+		if utf8 == "hello" {
+			hellos++
+		}
+	}
+	for _, entry := range cp.CpIndex {
+		switch entry.Type {
+		case classloader.MethodRef:
+			methodRefs++
+		case classloader.LongConst:
+			longs++
+			if cp.LongConsts[entry.Slot] != 42 {
+				t.Errorf("Expected long constant 42, got: %d", cp.LongConsts[entry.Slot])
+			}
+		}
+	}
+	if hellos != 1 || methodRefs != 1 || longs != 1 {
+		t.Errorf("Expected 1 'hello', 1 methodref, and 1 long in the CP, got: %d, %d, %d",
+			hellos, methodRefs, longs)
+	}
+
+	// the ldc instructions refer to the same string entry, and the invokestatic to
+	// the methodref, which names java/lang/Math.abs(I)I
+	code := methodCode(t, "main", "([Ljava/lang/String;)V")
+	if code[0] != jvm.LDC || code[2] != jvm.LDC || code[1] != code[3] {
+		t.Errorf("Expected two ldc instructions referring to one CP entry, got: %v", code)
+	}
+	if classloader.FetchUTF8stringFromCPEntryNumber(cp, uint16(code[1])) != "hello" {
+		t.Errorf("Expected ldc to refer to the string constant 'hello'")
+	}
+
+	mrIndex := int(code[8])<<8 | int(code[9])
+	if code[7] != jvm.INVOKESTATIC || cp.CpIndex[mrIndex].Type != classloader.MethodRef {
+		t.Fatalf("Expected invokestatic to refer to a methodref, got: %v", code)
+	}
+	mr := cp.MethodRefs[cp.CpIndex[mrIndex].Slot]
+	className := cp.Utf8Refs[cp.CpIndex[cp.ClassRefs[cp.CpIndex[mr.ClassIndex].Slot]].Slot]
+	nat := cp.NameAndTypes[cp.CpIndex[mr.NameAndType].Slot]
+	methName := cp.Utf8Refs[cp.CpIndex[nat.NameIndex].Slot]
+	methType := cp.Utf8Refs[cp.CpIndex[nat.DescIndex].Slot]
+	if className+"."+methName+methType != "java/lang/Math.abs(I)I" {
+		t.Errorf("Expected methodref to java/lang/Math.abs(I)I, got: %s.%s%s",
+			className, methName, methType)
+	}
+}
+
+func TestLdcWidenedToLdcW(t *testing.T) {
+	cb := NewClassBuilder("TestClass").AddMethod("test", "()V")
+	for i := 0; i < 300; i++ { // each int takes one CP slot, so later ones need ldc_w
+		cb.AddOpcode(jvm.LDC, i+100000).AddOpcode(jvm.POP)
+	}
+	cb.AddOpcode(jvm.RETURN)
+	_ = buildAndPost(t, cb)
+
+	code := methodCode(t, "test", "()V")
+	if code[0] != jvm.LDC {
+		t.Errorf("Expected the first constant to be loaded with ldc, got: %s",
+			jvm.BytecodeNames[code[0]])
+	}
+	if code[len(code)-5] != jvm.LDC_W {
+		t.Errorf("Expected the last constant to be loaded with ldc_w, got: %s",
+			jvm.BytecodeNames[code[len(code)-5]])
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	_, err := NewClassBuilder("TestClass").AddOpcode(jvm.RETURN).Build()
+	if err == nil {
+		t.Errorf("Expected an error for an opcode added before a method, got none")
+	}
+
+	_, err = NewClassBuilder("TestClass").AddMethod("test", "()V").
+		AddOpcode(jvm.INVOKESTATIC, "java/lang/Math").Build()
+	if err == nil {
+		t.Errorf("Expected an error for invokestatic without a name and type, got none")
+	}
+
+	_, err = NewClassBuilder("TestClass").AddMethod("test", "()V").
+		AddOpcode(jvm.LDC, []int{1}).Build()
+	if err == nil {
+		t.Errorf("Expected an error for an unsupported ldc constant, got none")
+	}
+}
+
+func TestBuildInvokeDynamic(t *testing.T) {
+	bootstrap := Bootstrap{
+		Method: MethodHandle{Kind: 6, Class: "java/lang/invoke/StringConcatFactory", Name: "makeConcatWithConstants",
+			Desc: "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;" +
+				"Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"},
+		Args: []interface{}{"x=\u0001"},
+	}
+	cb := NewClassBuilder("TestClass").
+		AddMethod("test", "(I)Ljava/lang/String;").
+		AddOpcode(jvm.ILOAD_0).
+		AddOpcode(jvm.INVOKEDYNAMIC, bootstrap, "makeConcatWithConstants", "(I)Ljava/lang/String;").
+		AddOpcode(jvm.ILOAD_0).
+		AddOpcode(jvm.INVOKEDYNAMIC, bootstrap, "makeConcatWithConstants", "(I)Ljava/lang/String;").
+		AddOpcode(jvm.ARETURN)
+	class := buildAndPost(t, cb)
+
+	// both call sites use the one bootstrap method, with its one static argument
+	if len(class.Bootstraps) != 1 || len(class.Bootstraps[0].Args) != 1 {
+		t.Fatalf("Expected 1 bootstrap method with 1 argument, got: %v", class.Bootstraps)
+	}
+	if class.CP.CpIndex[class.Bootstraps[0].MethodRef].Type != classloader.MethodHandle {
+		t.Errorf("Expected the bootstrap method to be a MethodHandle")
+	}
+
+	code := methodCode(t, "test", "(I)Ljava/lang/String;")
+	if code[1] != jvm.INVOKEDYNAMIC || code[4] != 0 || code[5] != 0 || code[2] != code[8] || code[3] != code[9] {
+		t.Errorf("Expected two invokedynamic instructions referring to one CP entry, got: %v", code)
+	}
+	if class.CP.CpIndex[int(code[2])<<8|int(code[3])].Type != classloader.InvokeDynamic {
+		t.Errorf("Expected invokedynamic to refer to an InvokeDynamic CP entry, got: %v", code)
+	}
+
+	_, err := NewClassBuilder("TestClass").AddMethod("test", "()V").
+		AddOpcode(jvm.INVOKEDYNAMIC, "run", "()Ljava/lang/Runnable;").Build()
+	if err == nil {
+		t.Errorf("Expected an error for invokedynamic without a Bootstrap, got none")
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// the access flags of fields and methods that determine who can access them
+// (see https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.6)
+const (
+	ACC_PUBLIC    = 0x0001
+	ACC_PRIVATE   = 0x0002
+	ACC_PROTECTED = 0x0004
+)
+
+// ACC_STATIC is the access flag of static fields and methods
+const ACC_STATIC = 0x0008
+
+// moduleFromFilename returns the name of the module a class was loaded from, based on
+// the filename passed to the classloader. Classes walked from a JMOD have filenames
+// of the form path/java.base.jmod+classes/java/lang/Object.class, so the module is
+// java.base. Classes walked from a jimage, and classes loaded from the module path,
+// have filenames of the form path/lib/modules+/java.base/java/lang/Object.class.
+// Classes loaded from the classpath are in the unnamed module, whose name is an empty
+// string.
+func moduleFromFilename(filename string) string {
+	if jmodEnd := strings.Index(filename, ".jmod+"); jmodEnd >= 0 {
+		return filepath.Base(filename[:jmodEnd])
+	}
+	if jimageEnd := strings.Index(filename, "+/"); jimageEnd >= 0 {
+		module := filename[jimageEnd+len("+/"):]
+		if slash := strings.Index(module, "/"); slash > 0 {
+			return module[:slash]
+		}
+	}
+	return ""
+}
+
+// packageOf returns the package of a class whose name is in java/lang/Object format.
+// Classes in the default package return an empty string.
+func packageOf(className string) string {
+	lastSlash := strings.LastIndex(className, "/")
+	if lastSlash < 0 {
+		return ""
+	}
+	return className[:lastSlash]
+}
+
+// samePackage reports whether two classes are in the same run-time package. Packages
+// are scoped to modules, so classes with the same package name in different modules
+// are not in the same package.
+func samePackage(class1, class2 *ClData) bool {
+	if class1.Module != class2.Module {
+		return false
+	}
+	return packageOf(class1.Name) == packageOf(class2.Name)
+}
+
+// CanAccessMember reports whether code in the class accessor can access a field or
+// method of the class owner that has the given access flags. Public members are
+// accessible from any module. (Jacobin does not yet read the exports of modules, so
+// all packages are treated as exported.) Package-private members are accessible only
+// from the same package in the same module; protected members additionally from
+// subclasses of owner. Private members are accessible only from the same nest (see
+// SameNest()).
+func CanAccessMember(accessor, owner *ClData, accessFlags int) bool {
+	switch {
+	case accessFlags&ACC_PUBLIC != 0:
+		return true
+	case accessFlags&ACC_PRIVATE != 0:
+		return SameNest(accessor, owner)
+	case accessFlags&ACC_PROTECTED != 0:
+		return samePackage(accessor, owner) || isSubclassOf(accessor, owner.Name)
+	default: // package-private
+		return samePackage(accessor, owner)
+	}
+}
+
+// SameNest reports whether two classes are nestmates, which share access to each other's
+// private members, as the inner classes of a class do with it and with each other since
+// Java 11. Nestmates have the same nest host: the class named by a class's NestHost
+// attribute, if the host's NestMembers attribute lists the class and the two are in the
+// same package, or else the class itself. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-5.html#jvms-5.4.4
+func SameNest(class1, class2 *ClData) bool {
+	return class1.Name == class2.Name || nestHostOf(class1) == nestHostOf(class2)
+}
+
+// nestHostOf returns the name of the nest host of klass. Its membership of the host's nest
+// can only be checked if the host has been loaded; if it hasn't, klass is its own host.
+func nestHostOf(klass *ClData) string {
+	if klass.NestHost == "" || klass.NestHost == klass.Name {
+		return klass.Name
+	}
+	host, ok := LookupClass(klass.NestHost)
+	if !ok || host.Data == nil || !samePackage(klass, host.Data) {
+		return klass.Name
+	}
+	for _, member := range host.Data.NestMembers {
+		if member == klass.Name {
+			return host.Data.Name
+		}
+	}
+	return klass.Name
+}
+
+// isSubclassOf reports whether the class klass extends the named class, directly or
+// indirectly. Only superclasses that have been loaded can be checked. A superclass chain
+// that loops back on itself, which fails to link, is walked only until it repeats.
+func isSubclassOf(klass *ClData, superclassName string) bool {
+	seen := map[string]bool{klass.Name: true}
+	for super := klass.Superclass; super != "" && !seen[super]; {
+		if super == superclassName {
+			return true
+		}
+		seen[super] = true
+		superKlass, ok := LookupClass(super)
+		if !ok || superKlass.Data == nil {
+			return false
+		}
+		super = superKlass.Data.Superclass
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+	"time"
+)
+
+func TestModuleFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"/jdk/jmods/java.base.jmod+classes/java/lang/Object.class": "java.base",
+		"java.sql.jmod+classes/java/sql/Date.class":                "java.sql",
+		"/jre/lib/modules+/java.base/java/lang/Object.class":       "java.base",
+		"/home/app/classes/Hello.class":                            "",
+		"Hello2.class":                                             "",
+	}
+
+	for filename, expected := range tests {
+		if module := moduleFromFilename(filename); module != expected {
+			t.Errorf("moduleFromFilename(%s): expected '%s', got: '%s'", filename, expected, module)
+		}
+	}
+}
+
+func TestPackagePrivateAccessAcrossModules(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	// Hello2 is in the default package. Load it as though it came from a JMOD.
+	name, err := ParseAndPostClass(AppCL, "/jmods/mod.a.jmod+classes/Hello2.class", getHello2Bytes(t))
+	if err != nil {
+		t.Fatalf("Unexpected error loading Hello2: %s", err.Error())
+	}
+	k, _ := LookupClass(name)
+	hello2 := k.Data
+	if hello2.Module != "mod.a" {
+		t.Fatalf("Expected Hello2 to be in module mod.a, got: '%s'", hello2.Module)
+	}
+
+	sameModule := &ClData{Name: "Other", Superclass: "java/lang/Object", Module: "mod.a"}
+	otherModule := &ClData{Name: "Other", Superclass: "java/lang/Object", Module: "mod.b"}
+
+	if !CanAccessMember(sameModule, hello2, 0) {
+		t.Error("Expected package-private access in the same package and module to be allowed")
+	}
+	if CanAccessMember(otherModule, hello2, 0) {
+		t.Error("Expected package-private access from a different module to be denied")
+	}
+	if CanAccessMember(otherModule, hello2, ACC_PROTECTED) {
+		t.Error("Expected protected access from a non-subclass in a different module to be denied")
+	}
+	if !CanAccessMember(otherModule, hello2, ACC_PUBLIC) {
+		t.Error("Expected public access from a different module to be allowed")
+	}
+	if CanAccessMember(sameModule, hello2, ACC_PRIVATE) {
+		t.Error("Expected private access from another class to be denied")
+	}
+}
+
+func TestProtectedAccessFromSubclassInOtherModule(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	base := &ClData{Name: "org/lib/Base", Superclass: "java/lang/Object", Module: "lib"}
+	_ = insert(base.Name, Klass{Status: 'F', Data: base})
+
+	sub := &ClData{Name: "com/app/Sub", Superclass: "org/lib/Base", Module: ""}
+	if !CanAccessMember(sub, base, ACC_PROTECTED) {
+		t.Error("Expected protected access from a subclass in another module to be allowed")
+	}
+	if CanAccessMember(sub, base, 0) {
+		t.Error("Expected package-private access from a subclass in another package to be denied")
+	}
+}
+
+// a circular superclass chain (A extends B, B extends A) is walked only until it repeats
+func TestIsSubclassOfCircularChain(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	a := &ClData{Name: "loop/A", Superclass: "loop/B"}
+	b := &ClData{Name: "loop/B", Superclass: "loop/A"}
+	_ = insert(a.Name, Klass{Status: 'F', Data: a})
+	_ = insert(b.Name, Klass{Status: 'F', Data: b})
+
+	done := make(chan bool)
+	go func() {
+		done <- isSubclassOf(a, "org/lib/Base")
+	}()
+	select {
+	case isSub := <-done:
+		if isSub {
+			t.Error("Expected loop/A not to be a subclass of org/lib/Base")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("isSubclassOf() did not return for a circular superclass chain")
+	}
+	if !isSubclassOf(a, "loop/B") {
+		t.Error("Expected loop/A to be a subclass of loop/B")
+	}
+}
+
+// since Java 11, classes in the same nest can access each other's private members
+func TestPrivateAccessBetweenNestmates(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	outer := &ClData{Name: "app/Outer", Superclass: "java/lang/Object",
+		NestMembers: []string{"app/Outer$Inner", "app/Outer$Other"}}
+	_ = insert(outer.Name, Klass{Status: 'F', Data: outer})
+
+	inner := &ClData{Name: "app/Outer$Inner", Superclass: "java/lang/Object", NestHost: "app/Outer"}
+	other := &ClData{Name: "app/Outer$Other", Superclass: "java/lang/Object", NestHost: "app/Outer"}
+	impostor := &ClData{Name: "app/Impostor", Superclass: "java/lang/Object", NestHost: "app/Outer"}
+	unloadedHost := &ClData{Name: "app/Lone$Inner", Superclass: "java/lang/Object", NestHost: "app/Lone"}
+
+	if !CanAccessMember(inner, outer, ACC_PRIVATE) || !CanAccessMember(outer, inner, ACC_PRIVATE) {
+		t.Error("Expected private access between a nest host and its member to be allowed")
+	}
+	if !CanAccessMember(inner, other, ACC_PRIVATE) {
+		t.Error("Expected private access between two members of a nest to be allowed")
+	}
+	if CanAccessMember(impostor, outer, ACC_PRIVATE) {
+		t.Error("Expected private access from a class the host doesn't list as a member to be denied")
+	}
+	if CanAccessMember(unloadedHost, outer, ACC_PRIVATE) || !SameNest(unloadedHost, unloadedHost) {
+		t.Error("Expected a class whose nest host isn't loaded to be in a nest of its own")
+	}
+}
@@ -0,0 +1,421 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+)
+
+// Annotation is an annotation, as parsed from a class's, method's, or field's
+// RuntimeVisibleAnnotations or RuntimeInvisibleAnnotations attribute, or from a
+// method's RuntimeVisibleParameterAnnotations attribute. Invisible annotations are
+// those whose retention policy is CLASS, which reflection doesn't return. Type is the
+// annotation interface, in java/lang/Deprecated format. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.16
+type Annotation struct {
+	Type     string
+	Elements []AnnotationElement
+}
+
+// AnnotationElement is one element-value pair of an annotation. Tag is the element's
+// type, using the tags of the element_value structure (I = int, s = String, etc.).
+// Value holds an int64 for B, C, I, J, S, and Z; a float64 for D and F; a string
+// for s; the class name (in java/lang/Object format) for c; an EnumConstant for e;
+// an Annotation for @; and a []AnnotationElement (whose names are empty) for [.
+type AnnotationElement struct {
+	Name  string
+	Tag   byte
+	Value interface{}
+}
+
+// EnumConstant is the value of an annotation element whose type is an enum
+type EnumConstant struct {
+	Type string
+	Name string
+}
+
+// parseAnnotationAttributes parses the RuntimeVisibleAnnotations and
+// RuntimeInvisibleAnnotations attributes of a class and of its fields, methods, and
+// record components, and the RuntimeVisibleParameterAnnotations and AnnotationDefault
+// attributes of its methods, into their VisibleAnnotations, InvisibleAnnotations,
+// VisibleParameterAnnotations, and AnnotationDefault. It's part of the format check of a class being loaded:
+// a malformed attribute is a class format error.
+func parseAnnotationAttributes(k *ClData) error {
+	a, err := annotationAttributes(k.Attributes, &k.CP, "class "+k.Name)
+	if err != nil {
+		return err
+	}
+	k.VisibleAnnotations, k.InvisibleAnnotations = a.visible, a.invisible
+
+	for i := range k.Fields {
+		f := &k.Fields[i]
+		where := "field " + utf8At(&k.CP, f.Name) + " of class " + k.Name
+		if a, err = annotationAttributes(f.Attributes, &k.CP, where); err != nil {
+			return err
+		}
+		f.VisibleAnnotations, f.InvisibleAnnotations = a.visible, a.invisible
+	}
+	for i := range k.Methods {
+		m := &k.Methods[i]
+		where := "method " + utf8At(&k.CP, m.Name) + utf8At(&k.CP, m.Desc) + " of class " + k.Name
+		if a, err = annotationAttributes(m.Attributes, &k.CP, where); err != nil {
+			return err
+		}
+		m.VisibleAnnotations, m.InvisibleAnnotations = a.visible, a.invisible
+		m.VisibleParameterAnnotations = a.visibleParams
+		m.AnnotationDefault = a.annotationDefault
+	}
+	for i := range k.RecordComponents {
+		rc := &k.RecordComponents[i]
+		where := "record component " + utf8At(&k.CP, rc.Name) + " of class " + k.Name
+		if a, err = annotationAttributes(rc.Attributes, &k.CP, where); err != nil {
+			return err
+		}
+		rc.VisibleAnnotations, rc.InvisibleAnnotations = a.visible, a.invisible
+	}
+	return nil
+}
+
+// the annotations in the attributes of a class, field, or method
+type parsedAnnotations struct {
+	visible           []Annotation
+	invisible         []Annotation
+	visibleParams     [][]Annotation
+	annotationDefault *AnnotationElement
+}
+
+// returns the annotations in the annotation attributes among attrs. where describes the
+// class, field, or method whose attributes they are, for the error message.
+func annotationAttributes(attrs []Attr, cp *CPool, where string) (parsedAnnotations, error) {
+	var a parsedAnnotations
+	for _, attr := range attrs {
+		var err error
+		name := utf8At(cp, attr.AttrName)
+		switch name {
+		case "RuntimeVisibleAnnotations":
+			a.visible, err = parseAnnotations(attr.AttrContent, cp)
+		case "RuntimeInvisibleAnnotations":
+			a.invisible, err = parseAnnotations(attr.AttrContent, cp)
+		case "RuntimeVisibleParameterAnnotations":
+			a.visibleParams, err = parseParameterAnnotations(attr.AttrContent, cp)
+		case "AnnotationDefault":
+			a.annotationDefault, err = parseAnnotationDefault(attr.AttrContent, cp)
+		}
+		if err != nil {
+			return parsedAnnotations{}, cfe("Invalid " + name + " attribute of " + where + ": " + err.Error())
+		}
+	}
+	return a, nil
+}
+
+// HasAnnotation returns whether the class is annotated, visibly or invisibly, with the
+// annotation whose type is given by descriptor, e.g., Ljava/lang/FunctionalInterface;
+func HasAnnotation(klass *ClData, descriptor string) bool {
+	annotationType := classNameFromDescriptor(descriptor)
+	for _, annotations := range [][]Annotation{klass.VisibleAnnotations, klass.InvisibleAnnotations} {
+		for _, a := range annotations {
+			if a.Type == annotationType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns the string at the given index in the Utf8Refs, or "" if there's none
+func utf8At(cp *CPool, index uint16) string {
+	if int(index) >= len(cp.Utf8Refs) {
+		return ""
+	}
+	return cp.Utf8Refs[index]
+}
+
+// annotationReader walks the contents of an annotations attribute
+type annotationReader struct {
+	bytes []byte
+	pos   int
+	cp    *CPool
+}
+
+var errBadAnnotation = errors.New("the annotation data is truncated or invalid")
+
+func (r *annotationReader) u1() (byte, error) {
+	if r.pos >= len(r.bytes) {
+		return 0, errBadAnnotation
+	}
+	b := r.bytes[r.pos]
+	r.pos += 1
+	return b, nil
+}
+
+func (r *annotationReader) u2() (uint16, error) {
+	if r.pos+2 > len(r.bytes) {
+		return 0, errBadAnnotation
+	}
+	v := uint16(r.bytes[r.pos])<<8 | uint16(r.bytes[r.pos+1])
+	r.pos += 2
+	return v, nil
+}
+
+// reads a u2 index of a UTF8 entry in the CP and returns the string
+func (r *annotationReader) utf8() (string, error) {
+	index, err := r.u2()
+	if err != nil {
+		return "", err
+	}
+	if index < 1 || int(index) >= len(r.cp.CpIndex) || r.cp.CpIndex[index].Type != UTF8 {
+		return "", errBadAnnotation
+	}
+	return r.cp.Utf8Refs[r.cp.CpIndex[index].Slot], nil
+}
+
+// parses the content of a RuntimeVisibleAnnotations or RuntimeInvisibleAnnotations
+// attribute: a u2 count of annotations, followed by the annotations
+func parseAnnotations(content []byte, cp *CPool) ([]Annotation, error) {
+	r := annotationReader{bytes: content, cp: cp}
+	annotations, err := r.annotations()
+	if err == nil && r.pos != len(content) {
+		err = errBadAnnotation
+	}
+	return annotations, err
+}
+
+// parses the content of a RuntimeVisibleParameterAnnotations attribute: a u1 count of
+// parameters, followed by the annotations of each parameter, as in
+// RuntimeVisibleAnnotations. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.18
+func parseParameterAnnotations(content []byte, cp *CPool) ([][]Annotation, error) {
+	r := annotationReader{bytes: content, cp: cp}
+	count, err := r.u1()
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([][]Annotation, count)
+	for i := range params {
+		if params[i], err = r.annotations(); err != nil {
+			return nil, err
+		}
+	}
+	if r.pos != len(content) {
+		return nil, errBadAnnotation
+	}
+	return params, nil
+}
+
+// parses the content of the AnnotationDefault attribute of a method of an annotation
+// interface: the element_value that's the default value of the element. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.22
+func parseAnnotationDefault(content []byte, cp *CPool) (*AnnotationElement, error) {
+	r := annotationReader{bytes: content, cp: cp}
+	elem, err := r.elementValue()
+	if err == nil && r.pos != len(content) {
+		err = errBadAnnotation
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &elem, nil
+}
+
+func (r *annotationReader) annotations() ([]Annotation, error) {
+	count, err := r.u2()
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []Annotation
+	for i := 0; i < int(count); i++ {
+		a, err := r.annotation()
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, nil
+}
+
+func (r *annotationReader) annotation() (Annotation, error) {
+	desc, err := r.utf8()
+	if err != nil {
+		return Annotation{}, err
+	}
+	a := Annotation{Type: classNameFromDescriptor(desc)}
+
+	pairCount, err := r.u2()
+	if err != nil {
+		return Annotation{}, err
+	}
+	for i := 0; i < int(pairCount); i++ {
+		name, err := r.utf8()
+		if err != nil {
+			return Annotation{}, err
+		}
+		elem, err := r.elementValue()
+		if err != nil {
+			return Annotation{}, err
+		}
+		elem.Name = name
+		a.Elements = append(a.Elements, elem)
+	}
+	return a, nil
+}
+
+func (r *annotationReader) elementValue() (AnnotationElement, error) {
+	tag, err := r.u1()
+	if err != nil {
+		return AnnotationElement{}, err
+	}
+	elem := AnnotationElement{Tag: tag}
+
+	switch tag {
+	case 'B', 'C', 'I', 'J', 'S', 'Z', 'D', 'F':
+		index, err := r.u2()
+		if err != nil {
+			return elem, err
+		}
+		elem.Value, err = constantValue(r.cp, index)
+		if err != nil {
+			return elem, err
+		}
+	case 's':
+		elem.Value, err = r.utf8()
+	case 'c':
+		var desc string
+		desc, err = r.utf8()
+		elem.Value = classNameFromDescriptor(desc)
+	case 'e':
+		var enumType, enumName string
+		if enumType, err = r.utf8(); err == nil {
+			enumName, err = r.utf8()
+		}
+		elem.Value = EnumConstant{Type: classNameFromDescriptor(enumType), Name: enumName}
+	case '@':
+		elem.Value, err = r.annotation()
+	case '[':
+		var count uint16
+		count, err = r.u2()
+		var values []AnnotationElement
+		for i := 0; err == nil && i < int(count); i++ {
+			var v AnnotationElement
+			v, err = r.elementValue()
+			values = append(values, v)
+		}
+		elem.Value = values
+	default:
+		err = errBadAnnotation
+	}
+	return elem, err
+}
+
+// returns the value of the numeric constant at the given CP index: an int64 for
+// integers and longs, and a float64 for floats and doubles
+func constantValue(cp *CPool, index uint16) (interface{}, error) {
+	if index < 1 || int(index) >= len(cp.CpIndex) {
+		return nil, errBadAnnotation
+	}
+	entry := cp.CpIndex[index]
+	switch entry.Type {
+	case IntConst:
+		return int64(cp.IntConsts[entry.Slot]), nil
+	case LongConst:
+		return cp.LongConsts[entry.Slot], nil
+	case FloatConst:
+		return float64(cp.Floats[entry.Slot]), nil
+	case DoubleConst:
+		return cp.Doubles[entry.Slot], nil
+	}
+	return nil, errBadAnnotation
+}
+
+// converts a field descriptor, such as Ljava/lang/Deprecated; to a class name, such as
+// java/lang/Deprecated. Descriptors of primitives and arrays are returned unchanged.
+func classNameFromDescriptor(desc string) string {
+	if len(desc) > 2 && desc[0] == 'L' && desc[len(desc)-1] == ';' {
+		return desc[1 : len(desc)-1]
+	}
+	return desc
+}
+
+// returns the descriptor of the method that returns the value of an annotation
+// element, or "" if the element's type can't be determined (as for an empty array)
+func elementMethodType(elem AnnotationElement) string {
+	switch elem.Tag {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return "()" + string(elem.Tag)
+	case 's':
+		return "()Ljava/lang/String;"
+	case 'c':
+		return "()Ljava/lang/Class;"
+	case 'e':
+		return "()L" + elem.Value.(EnumConstant).Type + ";"
+	case '@':
+		return "()L" + elem.Value.(Annotation).Type + ";"
+	case '[':
+		values := elem.Value.([]AnnotationElement)
+		if len(values) == 0 {
+			return ""
+		}
+		if componentType := elementMethodType(values[0]); componentType != "" {
+			return "()[" + componentType[2:]
+		}
+	}
+	return ""
+}
+
+// converts the value of an annotation element to its representation on the operand
+// stack. Enum constants, nested annotations, and arrays of anything other than
+// integral primitives and Strings aren't yet supported, so they're returned as null.
+func elementStackValue(elem AnnotationElement) interface{} {
+	switch elem.Tag {
+	case 'B', 'C', 'I', 'J', 'S', 'Z':
+		return elem.Value.(int64)
+	case 'D', 'F':
+		return elem.Value.(float64)
+	case 's':
+		return NewStringObject(elem.Value.(string))
+	case 'c':
+		return NewClassObject(elem.Value.(string))
+	case '[':
+		return elementArray(elem.Value.([]AnnotationElement))
+	}
+	return int64(0) // null
+}
+
+// creates the array that's the value of an array-valued annotation element
+func elementArray(values []AnnotationElement) int64 {
+	if len(values) == 0 {
+		return int64(0)
+	}
+
+	var arrayType byte
+	switch values[0].Tag {
+	case 'B':
+		arrayType = T_BYTE
+	case 'C':
+		arrayType = T_CHAR
+	case 'I':
+		arrayType = T_INT
+	case 'J':
+		arrayType = T_LONG
+	case 'S':
+		arrayType = T_SHORT
+	case 'Z':
+		arrayType = T_BOOLEAN
+	case 's':
+		arrayType = T_REF
+	default:
+		return int64(0)
+	}
+
+	addr := NewArrayObject(arrayType, len(values))
+	arr := objectAt(addr).(*ArrayObject)
+	for i, v := range values {
+		arr.Elements[i] = elementStackValue(v).(int64)
+	}
+	return addr
+}
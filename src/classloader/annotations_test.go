@@ -0,0 +1,195 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the constant pool of the annotated classes in these tests
+func annotationsTestCP() CPool {
+	utf8s := []string{"RuntimeVisibleAnnotations", "RuntimeVisibleParameterAnnotations",
+		"Lcom/example/Info;", "count", "name", "", "level", "Lcom/example/Level;", "HIGH",
+		"type", "Ljava/lang/String;", "nested", "Ljava/lang/Deprecated;", "values",
+		"f", "I", "m", "(II)V", "RuntimeInvisibleAnnotations"}
+	cp := CPool{CpIndex: []CpEntry{{Type: Dummy}}, Utf8Refs: utf8s,
+		IntConsts: []int32{7}, Doubles: []float64{2.5}}
+	for i := range utf8s {
+		cp.CpIndex = append(cp.CpIndex, CpEntry{Type: UTF8, Slot: uint16(i)})
+	}
+	cp.CpIndex = append(cp.CpIndex, CpEntry{Type: IntConst, Slot: 0}, CpEntry{Type: DoubleConst, Slot: 0})
+	return cp
+}
+
+// the CP indexes of the entries in annotationsTestCP(). The attribute and member names,
+// which are indexes into the Utf8Refs, are their CP indexes less one.
+const (
+	annInfo       = 3
+	annCount      = 4
+	annName       = 5
+	annEmpty      = 6
+	annLevel      = 7
+	annLevelType  = 8
+	annHigh       = 9
+	annType       = 10
+	annString     = 11
+	annNested     = 12
+	annDeprecated = 13
+	annValues     = 14
+	annSeven      = 20
+	annTwoPtFive  = 21
+)
+
+// @Info(count = 7, name = "", level = Level.HIGH, type = String.class,
+// nested = @Deprecated, values = {2.5, 2.5})
+var infoAnnotation = []byte{0, 1, 0, annInfo, 0, 6,
+	0, annCount, 'I', 0, annSeven,
+	0, annName, 's', 0, annEmpty,
+	0, annLevel, 'e', 0, annLevelType, 0, annHigh,
+	0, annType, 'c', 0, annString,
+	0, annNested, '@', 0, annDeprecated, 0, 0,
+	0, annValues, '[', 0, 2, 'D', 0, annTwoPtFive, 'D', 0, annTwoPtFive,
+}
+
+// @Deprecated on the field, on the second of the method's two parameters, and, as an
+// invisible annotation, on the class
+var deprecatedAnnotation = []byte{0, 1, 0, annDeprecated, 0, 0}
+var deprecatedSecondParam = []byte{2, 0, 0, 0, 1, 0, annDeprecated, 0, 0}
+
+func annotatedClass(classAnnotations, paramAnnotations []byte) ClData {
+	return annotatedClassWithInvisible(classAnnotations, paramAnnotations, deprecatedAnnotation)
+}
+
+func annotatedClassWithInvisible(classAnnotations, paramAnnotations, invisible []byte) ClData {
+	return ClData{
+		Name: "com/example/Annotated",
+		Attributes: []Attr{
+			{AttrName: 0, AttrSize: len(classAnnotations), AttrContent: classAnnotations},
+			{AttrName: 18, AttrSize: len(invisible), AttrContent: invisible}},
+		Fields: []Field{{Name: 14, Desc: 15, Attributes: []Attr{
+			{AttrName: 0, AttrSize: len(deprecatedAnnotation), AttrContent: deprecatedAnnotation}}}},
+		Methods: []Method{{Name: 16, Desc: 17, Attributes: []Attr{
+			{AttrName: 1, AttrSize: len(paramAnnotations), AttrContent: paramAnnotations}}}},
+		CP: annotationsTestCP(),
+	}
+}
+
+func TestParseAnnotationAttributes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	k := annotatedClass(infoAnnotation, deprecatedSecondParam)
+	if err := parseAnnotationAttributes(&k); err != nil {
+		t.Fatalf("Unexpected error parsing the annotations: %v", err)
+	}
+
+	deprecated := Annotation{Type: "java/lang/Deprecated"}
+	expected := []Annotation{{Type: "com/example/Info", Elements: []AnnotationElement{
+		{Name: "count", Tag: 'I', Value: int64(7)},
+		{Name: "name", Tag: 's', Value: ""},
+		{Name: "level", Tag: 'e', Value: EnumConstant{Type: "com/example/Level", Name: "HIGH"}},
+		{Name: "type", Tag: 'c', Value: "java/lang/String"},
+		{Name: "nested", Tag: '@', Value: deprecated},
+		{Name: "values", Tag: '[', Value: []AnnotationElement{{Tag: 'D', Value: 2.5}, {Tag: 'D', Value: 2.5}}},
+	}}}
+	if !reflect.DeepEqual(k.VisibleAnnotations, expected) {
+		t.Errorf("Expected the class's annotations to be %v, got: %v", expected, k.VisibleAnnotations)
+	}
+
+	if !reflect.DeepEqual(k.InvisibleAnnotations, []Annotation{deprecated}) {
+		t.Errorf("Expected the class to be invisibly @Deprecated, got: %v", k.InvisibleAnnotations)
+	}
+
+	if !reflect.DeepEqual(k.Fields[0].VisibleAnnotations, []Annotation{deprecated}) {
+		t.Errorf("Expected the field to be @Deprecated, got: %v", k.Fields[0].VisibleAnnotations)
+	}
+
+	m := k.Methods[0]
+	if m.VisibleAnnotations != nil {
+		t.Errorf("Expected the method to have no annotations, got: %v", m.VisibleAnnotations)
+	}
+	if !reflect.DeepEqual(m.VisibleParameterAnnotations, [][]Annotation{nil, {deprecated}}) {
+		t.Errorf("Expected only the second parameter to be @Deprecated, got: %v", m.VisibleParameterAnnotations)
+	}
+}
+
+func TestParseAnnotationAttributesMalformed(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	withByte := func(b []byte, pos int, value byte) []byte {
+		c := append([]byte{}, b...)
+		c[pos] = value
+		return c
+	}
+	tests := []struct {
+		classAnnotations []byte
+		paramAnnotations []byte
+		invisible        []byte
+		expected         string
+	}{
+		{infoAnnotation[:len(infoAnnotation)-1], deprecatedSecondParam, deprecatedAnnotation,
+			"RuntimeVisibleAnnotations attribute of class com/example/Annotated"},
+		{append(append([]byte{}, infoAnnotation...), 0), deprecatedSecondParam, deprecatedAnnotation,
+			"RuntimeVisibleAnnotations attribute of class com/example/Annotated"},
+		{withByte(infoAnnotation, 8, 'X'), deprecatedSecondParam, deprecatedAnnotation, // an invalid tag
+			"RuntimeVisibleAnnotations attribute of class com/example/Annotated"},
+		{withByte(infoAnnotation, 3, annSeven), deprecatedSecondParam, deprecatedAnnotation, // a type that's not a UTF8
+			"RuntimeVisibleAnnotations attribute of class com/example/Annotated"},
+		{infoAnnotation, withByte(deprecatedSecondParam, 0, 3), deprecatedAnnotation, // three parameters, not two
+			"RuntimeVisibleParameterAnnotations attribute of method m(II)V of class com/example/Annotated"},
+		{infoAnnotation, deprecatedSecondParam, deprecatedAnnotation[:5],
+			"RuntimeInvisibleAnnotations attribute of class com/example/Annotated"},
+	}
+
+	for _, test := range tests {
+		normalStderr := os.Stderr
+		r, w, _ := os.Pipe()
+		os.Stderr = w
+
+		k := annotatedClassWithInvisible(test.classAnnotations, test.paramAnnotations, test.invisible)
+		err := parseAnnotationAttributes(&k)
+
+		_ = w.Close()
+		out, _ := io.ReadAll(r)
+		os.Stderr = normalStderr
+
+		if err == nil {
+			t.Errorf("Expected an error for malformed annotations: %s", test.expected)
+		} else if !strings.Contains(string(out), "Invalid "+test.expected) {
+			t.Errorf("Expected an error about the %s, got: %s", test.expected, string(out))
+		}
+	}
+}
+
+func TestHasAnnotation(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	k := annotatedClass(infoAnnotation, deprecatedSecondParam)
+	if err := parseAnnotationAttributes(&k); err != nil {
+		t.Fatalf("Unexpected error parsing the annotations: %v", err)
+	}
+
+	for descriptor, expected := range map[string]bool{
+		"Lcom/example/Info;":              true, // visible
+		"Ljava/lang/Deprecated;":          true, // invisible
+		"Ljava/lang/Override;":            false,
+		"Lcom/example/Level;":             false, // only the type of an element
+		"Ljava/lang/FunctionalInterface;": false,
+	} {
+		if HasAnnotation(&k, descriptor) != expected {
+			t.Errorf("Expected HasAnnotation(%s) to be %t", descriptor, expected)
+		}
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"jacobin/globals"
 	"jacobin/log"
+	"strconv"
 	"strings"
 )
 
@@ -67,18 +69,92 @@ func (archive *Archive) scanArchive() error {
 		return err
 	}
 
+	var classEntries []string
 	for _, file := range reader.File {
 		entry := archive.recordFile(file)
 		if entry.Type == Manifest {
-			if archive.parseManifest(file); err != nil {
+			if err = archive.parseManifest(file); err != nil {
 				return err
 			}
+		} else if entry.Type == ClassFile {
+			classEntries = append(classEntries, file.Name)
 		}
 	}
 
+	if isMultiRelease(archive.manifest) {
+		archive.recordReleaseEntries(classEntries)
+	}
+
 	return nil
 }
 
+// recordReleaseEntries replaces the entries of a multi-release JAR's classes with the
+// entries to be loaded for Jacobin's Java version (see releaseEntries()). The versioned
+// entries aren't themselves classes.
+func (archive *Archive) recordReleaseEntries(classEntries []string) {
+	for _, name := range classEntries {
+		if strings.HasPrefix(name, versionedEntriesPrefix) {
+			delete(archive.entryCache, strings.ReplaceAll(strings.TrimSuffix(name, ".class"), "/", "."))
+		}
+	}
+	for className, location := range releaseEntries(classEntries, globals.GetGlobalRef().MaxJavaVersion) {
+		resourceName := strings.ReplaceAll(className, "/", ".")
+		archive.entryCache[resourceName] = ResourceEntry{Location: location, Name: resourceName, Type: ClassFile}
+	}
+}
+
+// A multi-release JAR (JEP 238) holds, besides its base entries, versions of classes for
+// later releases of Java, in META-INF/versions/N/, where N is the release (9 or later).
+// The version of a class that's loaded is the one for the latest release that's no
+// later than Jacobin's Java version or, if there's none, the base entry.
+const versionedEntriesPrefix = "META-INF/versions/"
+
+// reports whether the manifest marks its JAR as a multi-release JAR
+func isMultiRelease(manifest map[string]string) bool {
+	return strings.EqualFold(manifest["Multi-Release"], "true")
+}
+
+// returns the class name (in com/example/Main format) and the release of a versioned
+// entry, such as META-INF/versions/11/com/example/Main.class. ok is false if the entry
+// isn't a versioned class.
+func versionedEntry(entryName string) (className string, release int, ok bool) {
+	if !strings.HasPrefix(entryName, versionedEntriesPrefix) || !strings.HasSuffix(entryName, ".class") {
+		return "", 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(entryName, versionedEntriesPrefix), "/", 2)
+	if len(parts) < 2 {
+		return "", 0, false
+	}
+	release, err := strconv.Atoi(parts[0])
+	if err != nil || release < 9 {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(parts[1], ".class"), release, true
+}
+
+// returns, for each class in a multi-release JAR whose class entries are given, the
+// entry to load on the given release of Java, keyed by class name (in com/example/Main
+// format)
+func releaseEntries(classEntries []string, maxRelease int) map[string]string {
+	entries := make(map[string]string)
+	releases := make(map[string]int) // class name -> release of the entry chosen (0 for the base entry)
+	for _, name := range classEntries {
+		className, release, versioned := versionedEntry(name)
+		if !versioned {
+			if strings.HasPrefix(name, versionedEntriesPrefix) {
+				continue // not an entry of any release
+			}
+			className = strings.TrimSuffix(name, ".class")
+		}
+		if chosen, ok := releases[className]; release > maxRelease || (ok && chosen >= release) {
+			continue
+		}
+		entries[className] = name
+		releases[className] = release
+	}
+	return entries
+}
+
 func (archive *Archive) recordFile(file *zip.File) ResourceEntry {
 	fileType := Resource
 	resourceName := file.Name
@@ -102,29 +178,52 @@ func (archive *Archive) recordFile(file *zip.File) ResourceEntry {
 	return entry
 }
 
+// parseManifest reads the manifest's main attributes. Lines can end with CR LF or LF,
+// and a line that starts with a space continues the line before it.
 func (archive *Archive) parseManifest(file *zip.File) error {
-	rc, err := file.Open()
-
-	defer rc.Close()
+	attributes, err := readManifest(file)
 
 	if err != nil {
 		return err
 	}
 
+	for name, value := range attributes {
+		archive.manifest[name] = value
+	}
+
+	return nil
+}
+
+// readManifest returns the main attributes of the manifest in the given JAR entry
+func readManifest(file *zip.File) (map[string]string, error) {
+	rc, err := file.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
 	data, err := io.ReadAll(rc)
 
-	contents := string(data)
+	if err != nil {
+		return nil, err
+	}
 
-	lines := strings.Split(contents, "\r\n")
+	contents := strings.ReplaceAll(string(data), "\r\n", "\n")
+	contents = strings.ReplaceAll(contents, "\n ", "") // join continuation lines
+
+	lines := strings.Split(contents, "\n")
+	attributes := make(map[string]string)
 
 	for _, line := range lines {
-		parts := strings.Split(line, ":")
+		parts := strings.SplitN(line, ":", 2)
 		if len(parts) > 1 {
-			archive.manifest[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			attributes[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
 		}
 	}
 
-	return nil
+	return attributes, nil
 }
 
 func (archive *Archive) hasResource(name string, resourceType ResourceType) bool {
@@ -174,6 +273,40 @@ func (archive *Archive) loadClass(className string) (*LoadResult, error) {
 	return &LoadResult{Data: &bytes, Success: true, ResourceEntry: item}, nil
 }
 
+// loadResource returns the contents of the named resource (that is, a file that is
+// not a class), where the name is the resource's path within the archive.
+func (archive *Archive) loadResource(name string) (*LoadResult, error) {
+	item, ok := archive.entryCache[name]
+
+	if !ok || item.Type != Resource {
+		return &LoadResult{Success: false}, nil
+	}
+
+	reader, err := zip.OpenReader(archive.Filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	file, err := reader.Open(item.Location)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	bytes, err := io.ReadAll(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoadResult{Data: &bytes, Success: true, ResourceEntry: item}, nil
+}
+
 func (archive *Archive) getMainClass() string {
 	mainClass, exists := archive.manifest["Main-Class"]
 
@@ -183,3 +316,9 @@ func (archive *Archive) getMainClass() string {
 		return ""
 	}
 }
+
+// getClassPath returns the entries of the manifest's Class-Path attribute, which are
+// URLs relative to the directory of the JAR
+func (archive *Archive) getClassPath() []string {
+	return strings.Fields(archive.manifest["Class-Path"])
+}
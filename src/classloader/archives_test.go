@@ -7,8 +7,13 @@
 package classloader
 
 import (
+	"bytes"
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -102,3 +107,141 @@ func TestLoadClassDoesNotExist(t *testing.T) {
 		t.Error("Expected error loading class, but didn't get one.")
 	}
 }
+
+func TestManifestClassPathWithContinuationLines(t *testing.T) {
+	jarName := filepath.Join(t.TempDir(), "app.jar")
+	writeClasspathJar(t, jarName, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\r\n" +
+			"Main-Class: com.example.Main\r\n" +
+			"Class-Path: lib/first.jar lib/sec\r\n" +
+			" ond.jar http://example.com/third.jar\r\n\r\n"),
+	})
+
+	jar, err := NewJarFile(jarName)
+	if err != nil {
+		t.Fatalf("Unexpected error scanning JAR: %s", err.Error())
+	}
+
+	if jar.getMainClass() != "com.example.Main" {
+		t.Errorf("Expected Main-Class to be 'com.example.Main', but was %q", jar.getMainClass())
+	}
+	expected := []string{"lib/first.jar", "lib/second.jar", "http://example.com/third.jar"}
+	if classPath := jar.getClassPath(); !reflect.DeepEqual(classPath, expected) {
+		t.Errorf("Expected Class-Path to be %v, but was %v", expected, classPath)
+	}
+}
+
+var MULTI_RELEASE_JAR_NAME = "multirelease.jar"
+
+// multirelease.jar has a base mr/Main that targets Java 21 (class-file version 65), and
+// versions of it for Java 11, 17, and 21, so Jacobin must load the version for Java 17
+func TestLoadClassFromMultiReleaseJar(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	jarName, err := getJarFileName(MULTI_RELEASE_JAR_NAME)
+	if err != nil {
+		t.Fatal("Unable to get jar file", err)
+	}
+	jar, err := NewJarFile(jarName)
+	if err != nil {
+		t.Fatalf("Unexpected error scanning JAR: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"mr.Main":   "META-INF/versions/17/mr/Main.class",
+		"mr.Helper": "mr/Helper.class", // there's no versioned entry, so the base entry
+	}
+	for className, location := range expected {
+		if entry := jar.entryCache[className]; entry.Location != location || entry.Type != ClassFile {
+			t.Errorf("Expected %s to be loaded from %s, got: %+v", className, location, entry)
+		}
+	}
+	for _, className := range []string{"mr.Newer", "META-INF.versions.17.mr.Main"} {
+		if jar.hasResource(className, ClassFile) {
+			t.Errorf("Expected no class %s in the JAR", className)
+		}
+	}
+
+	if _, err = LoadClassFromJar(AppCL, "mr.Main", jarName); err != nil {
+		t.Fatalf("Unexpected error loading mr.Main: %s", err.Error())
+	}
+	if k, ok := AppCL.Classes["mr/Main"]; !ok || k.Data.JavaVersion != 61 {
+		t.Errorf("Expected mr/Main for Java 17 (class-file version 61) to be loaded, got: %+v", k.Data)
+	}
+}
+
+// a JAR that isn't marked Multi-Release ignores its versioned entries
+func TestVersionedEntriesIgnoredWithoutMultiRelease(t *testing.T) {
+	jarName := filepath.Join(t.TempDir(), "app.jar")
+	writeClasspathJar(t, jarName, map[string][]byte{
+		"META-INF/MANIFEST.MF":               []byte("Manifest-Version: 1.0\n"),
+		"mr/Main.class":                      {0xCA, 0xFE},
+		"META-INF/versions/11/mr/Main.class": {0xCA, 0xFE},
+	})
+
+	jar, err := NewJarFile(jarName)
+	if err != nil {
+		t.Fatalf("Unexpected error scanning JAR: %s", err.Error())
+	}
+	if entry := jar.entryCache["mr.Main"]; entry.Location != "mr/Main.class" {
+		t.Errorf("Expected mr.Main to be loaded from its base entry, got: %s", entry.Location)
+	}
+}
+
+func TestReleaseEntries(t *testing.T) {
+	entries := releaseEntries([]string{
+		"META-INF/versions/9/a/B.class",
+		"a/B.class",
+		"META-INF/versions/11/a/B.class",
+		"META-INF/versions/11/a/C.class",
+		"META-INF/versions/21/a/B.class",
+		"META-INF/versions/21/a/D.class",
+		"META-INF/versions/x/a/E.class",
+		"a/F.class",
+	}, 17)
+
+	expected := map[string]string{
+		"a/B": "META-INF/versions/11/a/B.class",
+		"a/C": "META-INF/versions/11/a/C.class",
+		"a/F": "a/F.class",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected entries %v, got: %v", expected, entries)
+	}
+}
+
+// a JAR with more entries than fit in the end of central directory record must be read
+// through its ZIP64 records, both as the starting JAR and on the classpath
+func TestJarFileManyEntries(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	entries := make(map[string][]byte, zip64EntryCount)
+	for i := 0; i < zip64EntryCount; i++ {
+		entries[fmt.Sprintf("org/many/C%05d.class", i)] = []byte{byte(i)}
+	}
+	entries["META-INF/MANIFEST.MF"] = []byte("Manifest-Version: 1.0\nMain-Class: org.many.C00000\n")
+	jarName := filepath.Join(t.TempDir(), "many.jar")
+	writeClasspathJar(t, jarName, entries)
+
+	jar, err := NewJarFile(jarName)
+	if err != nil {
+		t.Fatalf("Unexpected error scanning JAR: %s", err.Error())
+	}
+	if jar.getMainClass() != "org.many.C00000" {
+		t.Errorf("Expected Main-Class to be 'org.many.C00000', but was %q", jar.getMainClass())
+	}
+	last := zip64EntryCount - 1
+	result, err := jar.loadClass(fmt.Sprintf("org.many.C%05d", last))
+	if err != nil || !result.Success || !bytes.Equal(*result.Data, []byte{byte(last)}) {
+		t.Errorf("Expected to load the last class from the JAR, got: %v, error: %v", result, err)
+	}
+
+	resolver := NewClasspathResolver([]string{jarName})
+	defer resolver.Close()
+	if b, _, err := resolver.ClassBytes("org/many/C00001"); err != nil || !bytes.Equal(b, []byte{1}) {
+		t.Errorf("Expected to find org/many/C00001 on the classpath, got: % X, error: %v", b, err)
+	}
+}
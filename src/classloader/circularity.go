@@ -0,0 +1,111 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strings"
+)
+
+// ClassCircularityError is returned when a class is, directly or indirectly, its own
+// superclass, as when A extends B and B extends A. Class is the name of the class at
+// which the superclass chain was found to loop back on itself.
+type ClassCircularityError struct {
+	Class string
+}
+
+func (e *ClassCircularityError) Error() string {
+	return "java.lang.ClassCircularityError: " + strings.ReplaceAll(e.Class, "/", ".")
+}
+
+// LinkClass loads the superclasses of the named class, which must already be loaded,
+// and the interfaces that each of them implements. If a class turns out to be its own
+// superclass, a ClassCircularityError is returned rather than recursing forever. If a
+// class extends a sealed class, or implements a sealed interface, that doesn't permit
+// it to, an IncompatibleClassChangeError is returned. Classes loaded by name are linked
+// as part of their loading, so this is done once per class; a class whose linking fails
+// is marked as having failed to load, so that every later attempt to use it fails too.
+// Failures to load a supertype have already been logged by the classloader, and are
+// not returned. A core supertype, such as java/lang/Object, that the bootstrap
+// classloader has nowhere to load from is skipped, without an error or a log message.
+func LinkClass(name string) error {
+	err := linkSuperclasses(name)
+	if err != nil {
+		if k, ok := LookupClass(name); ok {
+			k.Status = 'E'
+			Classes.Store(name, k)
+		}
+	}
+	return err
+}
+
+// walks the superclass chain of the named class for LinkClass(), loading each class
+// in the chain. The classes seen so far are tracked, so a chain that loops back on
+// itself is reported rather than walked forever.
+func linkSuperclasses(name string) error {
+	seen := map[string]bool{name: true}
+	for current := name; ; {
+		k, present := LookupClass(current)
+		if !present || k.Data == nil || k.Data.Superclass == "" {
+			return nil
+		}
+
+		super := k.Data.Superclass
+		if seen[super] {
+			return &ClassCircularityError{Class: super}
+		}
+		seen[super] = true
+
+		superErr := loadSupertype(super)
+		if superErr != nil && isLinkError(superErr) {
+			return superErr
+		}
+		if err := loadSuperinterfaces(k.Data); err != nil {
+			return err
+		}
+		if err := checkSealedSupertypes(k.Data); err != nil {
+			return err
+		}
+		if superErr != nil { // the rest of the chain can't be walked
+			return nil
+		}
+		current = super
+	}
+}
+
+// loads the interfaces that klass directly implements, so that checkSealedSupertypes()
+// can check them. Only the errors of linking an interface are returned.
+func loadSuperinterfaces(klass *ClData) error {
+	for _, index := range klass.Interfaces {
+		if err := loadSupertype(klass.CP.Utf8Refs[index]); err != nil && isLinkError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// errCoreClassUnavailable is returned by loadSupertype() for a core class that can't be
+// loaded because the bootstrap classloader has nowhere to load it from
+var errCoreClassUnavailable = errors.New("core class unavailable")
+
+// loads a supertype of a class being linked. Without JACOBIN_HOME, or without the core
+// class in it, loading a core class such as java/lang/Object would log a SEVERE error.
+// A class can still run without its core supertypes loaded, as it could before it was
+// linked, so these are skipped rather than loaded.
+func loadSupertype(name string) error {
+	if isCorePackage(name) && !isLoaded(name) && !bootstrapCanFind(name) {
+		return errCoreClassUnavailable
+	}
+	return LoadClassFromNameOnly(name)
+}
+
+// reports whether err is an error in linking a class, rather than in finding it
+func isLinkError(err error) bool {
+	var circularity *ClassCircularityError
+	var incompatible *IncompatibleClassChangeError
+	return errors.As(err, &circularity) || errors.As(err, &incompatible)
+}
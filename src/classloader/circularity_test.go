@@ -0,0 +1,100 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"testing"
+)
+
+// posts a parsed class with the given superclass to the method area
+func postTestClass(name, super string) {
+	pc := ParsedClass{className: name, superClass: super}
+	classToPost := convertToPostableClass(&pc)
+	_ = insert(name, Klass{Status: 'F', Loader: "app", Data: &classToPost})
+}
+
+// A extends B and B extends A: linking either must fail with a ClassCircularityError
+// rather than looping forever
+func TestClassCircularityErrorOnCyclicSuperclasses(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	for _, name := range []string{"cycle/A", "cycle/B"} {
+		postTestClass("cycle/A", "cycle/B")
+		postTestClass("cycle/B", "cycle/A")
+
+		err := LinkClass(name)
+		var cce *ClassCircularityError
+		if !errors.As(err, &cce) {
+			t.Errorf("Expected ClassCircularityError linking %s, got: %v", name, err)
+			continue
+		}
+		if cce.Class != name {
+			t.Errorf("Expected circularity to be reported for %s, got: %s", name, cce.Class)
+		}
+
+		// a class that fails to link is marked as having failed to load
+		if k, _ := LookupClass(name); k.Status != 'E' {
+			t.Errorf("Expected %s to be marked as failed, got status: %c", name, k.Status)
+		}
+	}
+}
+
+func TestLinkClassWithoutCycle(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	postTestClass("chain/Base", "")
+	postTestClass("chain/Middle", "chain/Base")
+	postTestClass("chain/Leaf", "chain/Middle")
+
+	if err := LinkClass("chain/Leaf"); err != nil {
+		t.Errorf("Expected no error linking chain/Leaf, got: %v", err)
+	}
+	if k, _ := LookupClass("chain/Leaf"); k.Status != 'F' {
+		t.Errorf("Expected chain/Leaf to keep its status, got: %c", k.Status)
+	}
+}
+
+// without JACOBIN_HOME, there are no core classes to load, so linking a class that
+// extends java/lang/Object skips it, rather than logging that it can't be found
+func TestLinkClassSkipsUnavailableCoreSuperclass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	globals.GetGlobalRef().JacobinHome = ""
+	Classes.Delete("java/lang/Object")
+
+	postTestClass("chain/Plain", "java/lang/Object")
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	err := LinkClass("chain/Plain")
+
+	_ = w.Close()
+	msg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Errorf("Expected no error linking chain/Plain, got: %v", err)
+	}
+	if len(msg) != 0 {
+		t.Errorf("Expected nothing written to stderr, got: %s", string(msg))
+	}
+	if _, present := LookupClass("java/lang/Object"); present {
+		t.Error("Expected java/lang/Object not to be loaded")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// the bytes of each class as it was originally loaded, along with the name of the
+// file it came from. Retransforming a class always starts from these bytes, so that
+// transformations aren't applied on top of earlier transformations. They're kept only
+// for the classes that can be redefined (see isRedefinable()).
+type cachedClass struct {
+	filename string
+	bytes    []byte
+}
+
+var classBytesCache = make(map[string]cachedClass)
+var classBytesMutex sync.RWMutex
+
+// reports whether the classes defined by the given classloader can be redefined or
+// retransformed. The classes of the bootstrap classloader, which are the JDK's base
+// classes, can't be: there are thousands of them, and keeping their bytes would cost
+// memory for the life of the JVM. (When they're loaded from the class cache, their
+// bytes aren't read at all.)
+func isRedefinable(cl Classloader) bool {
+	return cl.Name != BootstrapCL.Name
+}
+
+func cacheClassBytes(name string, filename string, rawBytes []byte) {
+	classBytesMutex.Lock()
+	classBytesCache[name] = cachedClass{filename: filename, bytes: rawBytes}
+	classBytesMutex.Unlock()
+}
+
+// OriginalClassBytes returns a copy of the bytes the named class was loaded from,
+// or false if the class has not been loaded or can't be redefined
+func OriginalClassBytes(name string) ([]byte, bool) {
+	classBytesMutex.RLock()
+	cached, ok := classBytesCache[name]
+	classBytesMutex.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), cached.bytes...), true
+}
+
+// SetOriginalClassBytes replaces the bytes kept for a loaded class. It's used when a
+// class is redefined, as later retransformations start from the new definition.
+func SetOriginalClassBytes(name string, rawBytes []byte) {
+	classBytesMutex.Lock()
+	if cached, ok := classBytesCache[name]; ok {
+		classBytesCache[name] = cachedClass{filename: cached.filename, bytes: rawBytes}
+	}
+	classBytesMutex.Unlock()
+}
+
+// ReplaceClass parses rawBytes, which are a new version of an already loaded class, and
+// replaces the class in the method area with it, in the same classloader. The original
+// bytes of the class are kept for later retransformations. The class's Java methods
+// are removed from the MTable, so subsequent invocations use the new version. Methods
+// that are executing continue to run the old bytecode, which their frames hold.
+func ReplaceClass(name string, rawBytes []byte) error {
+	classBytesMutex.RLock()
+	cached, ok := classBytesCache[name]
+	classBytesMutex.RUnlock()
+	k, present := LookupClass(name)
+	if !ok || !present {
+		return errors.New("cannot replace class " + name + ", which has not been loaded or can't be redefined")
+	}
+
+	// check the name before posting, so a misnamed class doesn't get into the method area
+	parsed, err := parse(rawBytes)
+	if err != nil {
+		return err
+	}
+	if parsed.className != name {
+		return errors.New("replacement for class " + name + " is named " + parsed.className)
+	}
+
+	if _, err = parseAndPost(classloaderNamed(k.Loader), cached.filename, rawBytes); err != nil {
+		return err
+	}
+	AdvanceClassStatus(name, k.Status) // the new version is in use wherever the old one was
+
+	removeJavaMethods(name)
+	return nil
+}
+
+// removes the Java methods of the named class from the MTable. Go methods are not part
+// of the class file, so they stay.
+func removeJavaMethods(name string) {
+	prefix := name + "."
+	MTmutex.Lock()
+	defer MTmutex.Unlock()
+	for methFQN, entry := range MTable {
+		if strings.HasPrefix(methFQN, prefix) && entry.MType == 'J' {
+			delete(MTable, methFQN)
+		}
+	}
+}
+
+// returns the classloader with the given name. Classes loaded by an unknown
+// loader are treated as loaded by the application classloader.
+func classloaderNamed(name string) Classloader {
+	switch name {
+	case BootstrapCL.Name:
+		return BootstrapCL
+	case ExtensionCL.Name:
+		return ExtensionCL
+	default:
+		return AppCL
+	}
+}
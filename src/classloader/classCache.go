@@ -0,0 +1,161 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/gob"
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The class cache holds the base classes from java.base.jmod in the form in which
+// they're posted to the method area, so that later runs of Jacobin don't have to read
+// and parse the JMOD again. The cache is a gob-encoded classCache, stored in
+// $JACOBIN_HOME/classcache/java.base.cache. It's valid only for the JMOD it was built
+// from: if the JMOD's path, size, or modification time differ from those in the cache,
+// or the cache was written by another version of Jacobin, it's rebuilt. The cache is
+// disabled with -XX:-UseClassCache.
+
+// the version of the cache's format, which changes whenever ClData (or anything in it) does
+const classCacheFormat = 8
+
+type classCacheKey struct {
+	Format   int
+	Version  string // the Jacobin version that wrote the cache
+	JmodPath string
+	JmodSize int64
+	JmodTime int64 // modification time, in nanoseconds since the Unix epoch
+}
+
+type classCache struct {
+	Key     classCacheKey
+	Classes []ClData
+}
+
+var registerCacheTypesOnce sync.Once
+
+// registers with gob the types that are held in interfaces in ClData: the values of
+// annotation elements (see AnnotationElement) other than numbers and strings, which gob
+// knows already
+func registerCacheTypes() {
+	registerCacheTypesOnce.Do(func() {
+		gob.Register(EnumConstant{})
+		gob.Register(Annotation{})
+		gob.Register([]AnnotationElement{})
+	})
+}
+
+// classCachePath returns the path of the class cache, or "" if JACOBIN_HOME isn't set
+func classCachePath() string {
+	if globals.JacobinHome() == "" {
+		return ""
+	}
+	return filepath.Join(globals.JacobinHome(), "classcache", "java.base.cache")
+}
+
+// returns the key that a valid cache of the classes in the JMOD must have
+func classCacheKeyFor(jmodPath string) (classCacheKey, error) {
+	info, err := os.Stat(jmodPath)
+	if err != nil {
+		return classCacheKey{}, err
+	}
+	return classCacheKey{
+		Format:   classCacheFormat,
+		Version:  globals.GetGlobalRef().Version,
+		JmodPath: jmodPath,
+		JmodSize: info.Size(),
+		JmodTime: info.ModTime().UnixNano(),
+	}, nil
+}
+
+// loadBaseClassesFromCache posts the classes in the cache to the method area, if the
+// cache is valid for the JMOD. It returns false if the classes must instead be loaded
+// from the JMOD: that is, if there's no cache, if it's for another JMOD (or another
+// version of it), or if it can't be read. Only the last of these is reported as a
+// warning.
+func loadBaseClassesFromCache(cachePath string, jmodPath string) bool {
+	key, err := classCacheKeyFor(jmodPath)
+	if err != nil {
+		return false
+	}
+
+	cacheFile, err := os.Open(cachePath)
+	if err != nil {
+		_ = log.Log("No class cache found at "+cachePath, log.FINE)
+		return false
+	}
+	defer cacheFile.Close()
+
+	registerCacheTypes()
+	var cache classCache
+	if err = gob.NewDecoder(cacheFile).Decode(&cache); err != nil {
+		_ = log.Log("Warning: the class cache "+cachePath+" is invalid and will be rebuilt: "+
+			err.Error(), log.WARNING)
+		return false
+	}
+	if cache.Key != key {
+		_ = log.Log("The class cache "+cachePath+" is out of date and will be rebuilt", log.FINE)
+		return false
+	}
+	for _, data := range cache.Classes {
+		if data.Name == "" {
+			_ = log.Log("Warning: the class cache "+cachePath+" is invalid and will be rebuilt: "+
+				"it holds a class with no name", log.WARNING)
+			return false
+		}
+	}
+
+	for i := range cache.Classes {
+		postCachedClass(BootstrapCL, &cache.Classes[i])
+	}
+	_ = log.Log("Loaded base classes from the class cache "+cachePath, log.FINE)
+	return true
+}
+
+// posts a class from the cache to the method area, as parseAndPost() does for a parsed class
+func postCachedClass(cl Classloader, data *ClData) {
+	eKF := Klass{
+		Status: 'F', // F = format-checked
+		Loader: cl.Name,
+		Data:   data,
+	}
+	_ = insert(data.Name, eKF)
+	cl.define(data.Name, eKF)
+}
+
+// writeClassCache writes the given classes, which were loaded from the JMOD, to the
+// cache. The cache is written to a temporary file that's then renamed, so that another
+// instance of Jacobin never reads a partly written cache.
+func writeClassCache(cachePath string, jmodPath string, classes []ClData) error {
+	key, err := classCacheKeyFor(jmodPath)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name()) // fails harmlessly once the file has been renamed
+
+	registerCacheTypes()
+	err = gob.NewEncoder(tempFile).Encode(classCache{Key: key, Classes: classes})
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.New("cannot write the class cache " + cachePath + ": " + err.Error())
+	}
+	return os.Rename(tempFile.Name(), cachePath)
+}
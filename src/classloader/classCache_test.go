@@ -0,0 +1,150 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writes a java.base.jmod holding two classes and returns its path
+func writeCacheTestJmod(t *testing.T, dir string) string {
+	jmodPath := filepath.Join(dir, "java.base.jmod")
+	writeTestJmod(t, jmodPath, map[string][]byte{
+		"classes/java/lang/CacheOne.class": classpathTestClass(t, "java/lang/CacheOne"),
+		"classes/java/lang/CacheTwo.class": classpathTestClass(t, "java/lang/CacheTwo"),
+	})
+	return jmodPath
+}
+
+func TestClassCacheIsWrittenAndThenUsed(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	dir := t.TempDir()
+	jmodPath := writeCacheTestJmod(t, dir)
+	cachePath := filepath.Join(dir, "classcache", "java.base.cache")
+
+	Classes = sync.Map{}
+	loadBaseClassesFromJmod(jmodPath, cachePath)
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("Expected the class cache to be written, got: %s", err.Error())
+	}
+
+	Classes = sync.Map{}
+	if !loadBaseClassesFromCache(cachePath, jmodPath) {
+		t.Fatal("Expected the base classes to be loaded from the class cache")
+	}
+	for _, name := range []string{"java/lang/CacheOne", "java/lang/CacheTwo"} {
+		k, present := LookupClass(name)
+		if !present || k.Status != 'F' || k.Loader != "bootstrap" || k.Data.Name != name {
+			t.Errorf("Expected %s in the method area from the cache, got: %+v", name, k)
+			continue
+		}
+		if _, found := findMethodInClass(k.Data, "run", "()V"); !found {
+			t.Errorf("Expected %s from the cache to have its method run()", name)
+		}
+	}
+
+	// a change to the JMOD makes the cache out of date
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(jmodPath, later, later); err != nil {
+		t.Fatalf("Unable to change the time of the JMOD: %s", err.Error())
+	}
+	if loadBaseClassesFromCache(cachePath, jmodPath) {
+		t.Error("Expected a cache for an older version of the JMOD not to be used")
+	}
+}
+
+// annotations, whose element values are held in interfaces, survive the round trip
+// through the cache, whatever the type of their values
+func TestClassCacheRoundTripsAnnotations(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	dir := t.TempDir()
+	jmodPath := writeCacheTestJmod(t, dir)
+	cachePath := filepath.Join(dir, "java.base.cache")
+
+	annotations := []Annotation{{
+		Type: "java/lang/annotation/Retention",
+		Elements: []AnnotationElement{
+			{Name: "value", Tag: 'e', Value: EnumConstant{Type: "Ljava/lang/annotation/RetentionPolicy;", Name: "RUNTIME"}},
+			{Name: "count", Tag: 'I', Value: int64(3)},
+			{Name: "ratio", Tag: 'D', Value: 0.5},
+			{Name: "label", Tag: 's', Value: "x"},
+			{Name: "nested", Tag: '@', Value: Annotation{Type: "java/lang/Deprecated",
+				Elements: []AnnotationElement{{Name: "since", Tag: 's', Value: "9"}}}},
+			{Name: "policies", Tag: '[', Value: []AnnotationElement{
+				{Tag: 'e', Value: EnumConstant{Type: "Ljava/lang/annotation/RetentionPolicy;", Name: "CLASS"}}}},
+		},
+	}}
+	classes := []ClData{{Name: "java/lang/Annotated", VisibleAnnotations: annotations}}
+	if err := writeClassCache(cachePath, jmodPath, classes); err != nil {
+		t.Fatalf("Unexpected error writing the class cache: %s", err.Error())
+	}
+
+	Classes = sync.Map{}
+	if !loadBaseClassesFromCache(cachePath, jmodPath) {
+		t.Fatal("Expected the classes to be loaded from the class cache")
+	}
+	k, present := LookupClass("java/lang/Annotated")
+	if !present {
+		t.Fatal("Expected java/lang/Annotated in the method area from the cache")
+	}
+	if !reflect.DeepEqual(k.Data.VisibleAnnotations, annotations) {
+		t.Errorf("Expected the annotations %+v from the cache, got: %+v", annotations, k.Data.VisibleAnnotations)
+	}
+}
+
+func TestCorruptClassCacheFallsBackToJmod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	_ = Init()
+
+	dir := t.TempDir()
+	jmodPath := writeCacheTestJmod(t, dir)
+	cachePath := filepath.Join(dir, "java.base.cache")
+	if err := os.WriteFile(cachePath, []byte("not a class cache"), 0644); err != nil {
+		t.Fatalf("Unable to write the class cache: %s", err.Error())
+	}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	Classes = sync.Map{}
+	loadBaseClassesFromJmod(jmodPath, cachePath)
+
+	_ = w.Close()
+	msg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if !strings.Contains(string(msg), "class cache "+cachePath+" is invalid") {
+		t.Errorf("Expected a warning that the class cache is invalid, got: %s", string(msg))
+	}
+	if _, present := LookupClass("java/lang/CacheOne"); !present {
+		t.Error("Expected java/lang/CacheOne to be loaded from the JMOD")
+	}
+
+	// the cache has been rebuilt
+	Classes = sync.Map{}
+	if !loadBaseClassesFromCache(cachePath, jmodPath) {
+		t.Error("Expected the rebuilt class cache to be used")
+	}
+}
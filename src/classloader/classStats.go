@@ -0,0 +1,150 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/management"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClassStats holds statistics about the loading of a single class. They help users
+// find the classes (frequently in dependencies) that are slow to load.
+type ClassStats struct {
+	Name       string
+	Loader     string // the name of the classloader that defined the class
+	LoadTimeNs int64  // wall-clock time from the start of parsing to insertion in the method area
+	CPEntries  int
+	Methods    int
+	Fields     int
+	SourceFile string
+	Signature  string // the generic signature of the class, if it has one
+}
+
+// the stats for every class loaded, keyed by the classloader's name and the class name
+// (see classStatsKey()), as each classloader has its own class of any given name
+var classStats = make(map[string]ClassStats)
+var classStatsMutex sync.RWMutex
+
+// returns the key of the stats of the named class defined by the named classloader,
+// such as app:com/example/Main
+func classStatsKey(loader, className string) string {
+	return loader + ":" + className
+}
+
+func init() {
+	management.Register(ClassStatsProvider{})
+}
+
+// records the stats for a class that the named classloader has just posted to the
+// method area
+func recordClassStats(loader string, klass *ParsedClass, loadStart time.Time) {
+	stats := ClassStats{
+		Name:       klass.className,
+		Loader:     loader,
+		LoadTimeNs: time.Since(loadStart).Nanoseconds(),
+		CPEntries:  klass.cpCount,
+		Methods:    len(klass.methods),
+		Fields:     len(klass.fields),
+		SourceFile: klass.sourceFile,
+		Signature:  klass.signature,
+	}
+
+	classStatsMutex.Lock()
+	classStats[classStatsKey(loader, klass.className)] = stats
+	classStatsMutex.Unlock()
+}
+
+// LookupClassStats returns the stats for the class with the given key. The key is the
+// classloader's name and the class name (in java/lang/Object format), as in
+// app:com/example/Main, or just the class name, which gets the stats of the class the
+// application classloader sees: the one defined by the first classloader in its
+// delegation chain that defined a class of that name.
+func LookupClassStats(key string) (ClassStats, error) {
+	classStatsMutex.RLock()
+	defer classStatsMutex.RUnlock()
+
+	if stats, ok := classStats[key]; ok {
+		return stats, nil
+	}
+	if !strings.Contains(key, ":") {
+		for _, loader := range delegationChain(&AppCL) {
+			if stats, ok := classStats[classStatsKey(loader.Name, key)]; ok {
+				return stats, nil
+			}
+		}
+	}
+	return ClassStats{}, errors.New("no statistics for class: " + key)
+}
+
+// ClassStatsProvider is the "classstats" InstrumentationProvider. It reports how long
+// each class took to load and how big it is, keyed by the classloader and class name
+// (see LookupClassStats()).
+type ClassStatsProvider struct{}
+
+func (ClassStatsProvider) Name() string { return "classstats" }
+
+// List returns an entry for each class loaded, sorted by key
+func (p ClassStatsProvider) List() []management.Entry {
+	entries, _ := p.ListSorted("name")
+	return entries
+}
+
+// ListSorted returns an entry for each class loaded. If sortBy is "loadtime", the
+// slowest-loading classes come first; if it's "name", the classes are sorted by key.
+func (ClassStatsProvider) ListSorted(sortBy string) ([]management.Entry, error) {
+	classStatsMutex.RLock()
+	list := make([]ClassStats, 0, len(classStats))
+	for _, stats := range classStats {
+		list = append(list, stats)
+	}
+	classStatsMutex.RUnlock()
+
+	switch sortBy {
+	case "loadtime":
+		sort.Slice(list, func(i, j int) bool { return list[i].LoadTimeNs > list[j].LoadTimeNs })
+	case "name":
+		sort.Slice(list, func(i, j int) bool {
+			return classStatsKey(list[i].Loader, list[i].Name) < classStatsKey(list[j].Loader, list[j].Name)
+		})
+	default:
+		return nil, errors.New("class statistics can't be sorted by " + sortBy)
+	}
+
+	entries := make([]management.Entry, 0, len(list))
+	for _, stats := range list {
+		entries = append(entries, management.Entry{
+			Key: classStatsKey(stats.Loader, stats.Name),
+			Description: fmt.Sprintf("loaded in %d ns: %d CP entries, %d methods, %d fields",
+				stats.LoadTimeNs, stats.CPEntries, stats.Methods, stats.Fields),
+		})
+	}
+	return entries, nil
+}
+
+// Detail returns the stats for the class with the given key (see LookupClassStats())
+func (ClassStatsProvider) Detail(key string) (map[string]string, error) {
+	stats, err := LookupClassStats(key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"class":      stats.Name,
+		"loader":     stats.Loader,
+		"loadTimeNs": strconv.FormatInt(stats.LoadTimeNs, 10),
+		"cpEntries":  strconv.Itoa(stats.CPEntries),
+		"methods":    strconv.Itoa(stats.Methods),
+		"fields":     strconv.Itoa(stats.Fields),
+		"sourceFile": stats.SourceFile,
+		"signature":  stats.Signature,
+	}, nil
+}
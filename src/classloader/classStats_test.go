@@ -0,0 +1,142 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/management"
+	"testing"
+)
+
+func TestClassStatsForHello2(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	_, err := ParseAndPostClass(AppCL, "Hello2.class", getHello2Bytes(t))
+	if err != nil {
+		t.Fatalf("Unexpected error loading Hello2: %s", err.Error())
+	}
+
+	stats, err := LookupClassStats("Hello2")
+	if err != nil {
+		t.Fatalf("Expected stats for Hello2, got error: %s", err.Error())
+	}
+
+	if stats.LoadTimeNs <= 0 {
+		t.Errorf("Expected a positive load time, got: %d", stats.LoadTimeNs)
+	}
+
+	if stats.CPEntries != 43 {
+		t.Errorf("Expected 43 CP entries, got: %d", stats.CPEntries)
+	}
+
+	if stats.Methods != 3 {
+		t.Errorf("Expected 3 methods, got: %d", stats.Methods)
+	}
+
+	if stats.Fields != 0 {
+		t.Errorf("Expected 0 fields, got: %d", stats.Fields)
+	}
+
+	if stats.SourceFile != "Hello2.java" {
+		t.Errorf("Expected source file Hello2.java, got: %s", stats.SourceFile)
+	}
+
+	if stats.Loader != "app" {
+		t.Errorf("Expected Hello2 to be loaded by the app classloader, got: %s", stats.Loader)
+	}
+}
+
+// the stats are served by the registered "classstats" provider
+func TestClassStatsProviderDetail(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	if _, err := ParseAndPostClass(AppCL, "Hello2.class", getHello2Bytes(t)); err != nil {
+		t.Fatalf("Unexpected error loading Hello2: %s", err.Error())
+	}
+
+	p, ok := management.Provider("classstats")
+	if !ok {
+		t.Fatal("Expected the classstats provider to be registered")
+	}
+	for _, key := range []string{"Hello2", "app:Hello2"} {
+		detail, err := p.Detail(key)
+		if err != nil {
+			t.Fatalf("Expected the detail of %s, got error: %s", key, err.Error())
+		}
+		if detail["cpEntries"] != "43" || detail["methods"] != "3" || detail["loader"] != "app" {
+			t.Errorf("Expected 43 CP entries and 3 methods loaded by app for %s, got: %v", key, detail)
+		}
+		if detail["loadTimeNs"] == "" || detail["loadTimeNs"][0] == '-' || detail["loadTimeNs"] == "0" {
+			t.Errorf("Expected a positive load time for %s, got: %s", key, detail["loadTimeNs"])
+		}
+	}
+}
+
+func TestClassStatsDetailForUnknownClass(t *testing.T) {
+	if _, err := (ClassStatsProvider{}).Detail("no/such/Class"); err == nil {
+		t.Error("Expected an error getting stats for a class that was never loaded, but got none")
+	}
+	if _, err := (ClassStatsProvider{}).Detail("extension:Hello2"); err == nil {
+		t.Error("Expected an error getting stats for a class the named classloader didn't load, but got none")
+	}
+}
+
+// classes of the same name defined by different classloaders have stats of their own
+func TestClassStatsKeptPerClassloader(t *testing.T) {
+	classStatsMutex.Lock()
+	classStats[classStatsKey("bootstrap", "test/Twice")] = ClassStats{Name: "test/Twice", Loader: "bootstrap", Methods: 1}
+	classStats[classStatsKey("app", "test/Twice")] = ClassStats{Name: "test/Twice", Loader: "app", Methods: 2}
+	classStatsMutex.Unlock()
+	defer func() {
+		classStatsMutex.Lock()
+		delete(classStats, classStatsKey("bootstrap", "test/Twice"))
+		delete(classStats, classStatsKey("app", "test/Twice"))
+		classStatsMutex.Unlock()
+	}()
+
+	if stats, err := LookupClassStats("app:test/Twice"); err != nil || stats.Methods != 2 {
+		t.Errorf("Expected the app classloader's test/Twice, got: %v, %v", stats, err)
+	}
+	// as with delegation, the bootstrap classloader's class is the one the name finds
+	if stats, err := LookupClassStats("test/Twice"); err != nil || stats.Methods != 1 {
+		t.Errorf("Expected the bootstrap classloader's test/Twice, got: %v, %v", stats, err)
+	}
+}
+
+func TestClassStatsListSortedByLoadTime(t *testing.T) {
+	classStatsMutex.Lock()
+	classStats[classStatsKey("app", "test/Fast")] = ClassStats{Name: "test/Fast", Loader: "app", LoadTimeNs: 10}
+	classStats[classStatsKey("app", "test/Slow")] = ClassStats{Name: "test/Slow", Loader: "app", LoadTimeNs: 1_000_000_000}
+	classStatsMutex.Unlock()
+	defer func() {
+		classStatsMutex.Lock()
+		delete(classStats, classStatsKey("app", "test/Fast"))
+		delete(classStats, classStatsKey("app", "test/Slow"))
+		classStatsMutex.Unlock()
+	}()
+
+	list, err := ClassStatsProvider{}.ListSorted("loadtime")
+	if err != nil || len(list) < 2 || list[0].Key != "app:test/Slow" {
+		t.Errorf("Expected the slowest class first, got: %v, %v", list, err)
+	}
+
+	list = ClassStatsProvider{}.List()
+	for i := 1; i < len(list); i++ {
+		if list[i].Key < list[i-1].Key {
+			t.Errorf("List is not sorted by key: %v", list)
+		}
+	}
+
+	if _, err = (ClassStatsProvider{}).ListSorted("size"); err == nil {
+		t.Error("Expected an error sorting by an unknown field, but got none")
+	}
+}
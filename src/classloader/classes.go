@@ -10,11 +10,37 @@ import (
 	"errors"
 	"jacobin/log"
 	"sync"
-	"time"
 )
 
-// Classes contains all the loaded classes. Key is the class name in java/lang/Object format.
-var Classes = make(map[string]Klass) // TODO: make these maps sync.Map
+// Classes is the method area: it contains all the loaded classes, as Klass values keyed
+// by the class name in java/lang/Object format. Classes are loaded by several goroutines
+// at once, so it's a sync.Map; LookupClass() is the usual way to read it. Where several
+// classloaders have defined classes of the same name, the method area holds the one the
+// application classloader, which initiates the loads of the classes that bytecode
+// refers to, resolves the name to (see LookupClassIn()); the others are in the Classes
+// of the classloaders that defined them.
+var Classes sync.Map
+
+// LookupClass returns the named class (in java/lang/Object format) from the method area
+// and whether it's there. A class that's being loaded is present with a Status of 'I'.
+// The class is the one the application classloader sees.
+func LookupClass(name string) (Klass, bool) {
+	if k, present := Classes.Load(name); present {
+		return k.(Klass), true
+	}
+	return Klass{}, false
+}
+
+// ClassCount returns the number of entries in the method area, including those for
+// classes that are being loaded or whose load failed
+func ClassCount() int {
+	count := 0
+	Classes.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
 
 // Statics is a fast-lookup map of static variables and functions. The int64 value
 // contains the index into the statics array where the entry is stored.
@@ -23,7 +49,7 @@ var Statics = make(map[string]int64)
 var StaticsArray []Static
 
 type Klass struct {
-	Status byte // I=Initializing,F=formatChecked,V=verified,L=linked,N=instantiated
+	Status byte // I=Initializing,F=formatChecked,V=verified,L=linked,N=instantiated,E=load failed
 	Loader string
 	Data   *ClData
 }
@@ -46,28 +72,45 @@ type Static struct {
 	*/
 	Type      string  // Type data used for reference variables (i.e., objects, etc.)
 	ValueRef  string  // pointer--might need to change this
-	ValueInt  int64   // holds longs, ints, shorts, chars, booleans, byte
+	ValueInt  int64   // holds longs, ints, shorts, chars, booleans, byte, and addresses
 	ValueFP   float64 // holds doubles and floats
 	ValueStr  string  // string
 	ValueFunc func()  // function pointer
 	CP        *CPool  // the constant pool for the class
 }
 
-var MethAreaMutex sync.RWMutex // All additions or updates to Classes map come through this mutex
-
 type ClData struct {
-	Name       string
-	Superclass string
-	Module     string
-	Pkg        string   // package name, if any. ('package' is a golang keyword)
-	Interfaces []uint16 // indices into UTF8Refs
-	Fields     []Field
-	Methods    []Method
-	Attributes []Attr
-	SourceFile string
-	Bootstraps []BootstrapMethod
-	CP         CPool
-	Access     AccessFlags
+	Name         string
+	JavaVersion  int // the major version of the class file format
+	Superclass   string
+	Module       string
+	Pkg          string   // package name, if any. ('package' is a golang keyword)
+	Interfaces   []uint16 // indices into UTF8Refs
+	Fields       []Field
+	Methods      []Method
+	Attributes   []Attr
+	SourceFile   string
+	Signature    string // the generic signature, if any (see ParseClassSignature())
+	Bootstraps   []BootstrapMethod
+	InnerClasses []InnerClassEntry
+	ModuleData   *ModuleData // the Module attribute of a module-info class; nil for other classes
+	CP           CPool
+	Access       AccessFlags
+
+	EnclosingMethod *EnclosingMethodEntry // for local and anonymous classes; nil for other classes
+
+	// the nest of classes that share private access (see SameNest()): the host of the
+	// class's nest, if it's a nested class, or the members of its nest, if it's a host
+	NestHost    string
+	NestMembers []string
+
+	// for a sealed class or interface, the classes permitted to extend or implement it
+	PermittedSubclasses []string
+
+	RecordComponents []RecordComponent // for a record class (see IsRecord()); nil for other classes
+
+	VisibleAnnotations   []Annotation // from the RuntimeVisibleAnnotations attribute
+	InvisibleAnnotations []Annotation // from the RuntimeInvisibleAnnotations attribute
 }
 
 type CPool struct {
@@ -111,6 +154,14 @@ type Field struct {
 	Name        uint16 // index of the UTF-8 entry in the CP
 	Desc        uint16 // index of the UTF-8 entry in the CP
 	Attributes  []Attr
+	Signature   string // the generic signature, if any (see ParseFieldSignature())
+
+	// the index of the CP entry with the initial value of a static final field, from its
+	// ConstantValue attribute, or 0 if there is none. A String constant's entry is UTF8.
+	ConstantValue uint16
+
+	VisibleAnnotations   []Annotation // from the RuntimeVisibleAnnotations attribute
+	InvisibleAnnotations []Annotation // from the RuntimeInvisibleAnnotations attribute
 }
 
 // the methods of the class, including the constructors
@@ -122,15 +173,26 @@ type Method struct {
 	Attributes  []Attr
 	Exceptions  []uint16 // indexes into Utf8Refs in the CP
 	Parameters  []ParamAttrib
-	Deprecated  bool // is the method deprecated?
+	Deprecated  bool   // is the method deprecated?
+	Signature   string // the generic signature, if any (see ParseMethodSignature())
+
+	VisibleAnnotations          []Annotation       // from the RuntimeVisibleAnnotations attribute
+	InvisibleAnnotations        []Annotation       // from the RuntimeInvisibleAnnotations attribute
+	VisibleParameterAnnotations [][]Annotation     // from RuntimeVisibleParameterAnnotations, by parameter
+	AnnotationDefault           *AnnotationElement // the default value of an annotation element, if any
 }
 
 type CodeAttrib struct {
-	MaxStack   int
-	MaxLocals  int
-	Code       []byte
-	Exceptions []CodeException // exception entries for this method
-	Attributes []Attr          // the code attributes has its own sub-attributes(!)
+	MaxStack       int
+	MaxLocals      int
+	Code           []byte
+	Exceptions     []CodeException // exception entries for this method
+	Attributes     []Attr          // the code attributes has its own sub-attributes(!)
+	StackMapFrames []StackMapFrame // from the StackMapTable sub-attribute, if there is one
+	LineNumbers    LineNumberTable // from the LineNumberTable sub-attributes, if there are any
+
+	LocalVariables     LocalVariableTable // from the LocalVariableTable sub-attributes, if there are any
+	LocalVariableTypes LocalVariableTable // from the LocalVariableTypeTable sub-attributes, if there are any
 }
 
 // ParamAttrib is the MethodParameters method attribute
@@ -160,6 +222,72 @@ type BootstrapMethod struct {
 	Args      []uint16 // arguments: indexes to loadable arguments from the CP
 }
 
+// InnerClassEntry is an entry in the InnerClasses class attribute. A class's
+// InnerClasses has an entry for each of its inner classes and, if it's an inner class
+// itself, one for it (and one for each class that encloses it).
+type InnerClassEntry struct {
+	InnerClass  uint16 // index pointing to the inner class's ClassRef
+	OuterClass  uint16 // index pointing to the outer class's ClassRef; 0 for local and anonymous classes
+	InnerName   uint16 // index pointing to the inner class's simple name; 0 for anonymous classes
+	AccessFlags int    // the inner class's access flags as declared in the source
+}
+
+// RecordComponent is a component of a record class, such as x in record Point(int x, int y).
+// Its annotations are those of the component itself; the record's fields and accessor
+// methods have their own.
+type RecordComponent struct {
+	Name       uint16 // index of the UTF-8 entry in the CP
+	Desc       uint16 // index of the UTF-8 entry in the CP
+	Attributes []Attr
+	Signature  string // the generic signature, if any (see ParseFieldSignature())
+
+	VisibleAnnotations   []Annotation // from the RuntimeVisibleAnnotations attribute
+	InvisibleAnnotations []Annotation // from the RuntimeInvisibleAnnotations attribute
+}
+
+// EnclosingMethodEntry is the EnclosingMethod attribute of a local or anonymous class,
+// which gives the class, and if there is one, the method that lexically encloses it.
+// There is no method for a class declared in an initializer.
+type EnclosingMethodEntry struct {
+	Class  uint16 // index pointing to the enclosing class's ClassRef
+	Method uint16 // index pointing to the enclosing method's NameAndType, or 0
+}
+
+// ModuleData is the Module attribute of a module-info class, which describes a module.
+// Module names use dots (java.base) and package names use slashes (java/lang).
+type ModuleData struct {
+	Name     string
+	Flags    int    // ACC_OPEN (0x0020), ACC_SYNTHETIC (0x1000), and ACC_MANDATED (0x8000)
+	Version  string // "" if the module has no version
+	Requires []ModuleRequires
+	Exports  []ModulePackage
+	Opens    []ModulePackage
+	Uses     []string // the services the module uses
+	Provides []ModuleProvides
+}
+
+// ModuleRequires is a module that a module requires (that is, reads)
+type ModuleRequires struct {
+	Module  string
+	Flags   int    // ACC_TRANSITIVE (0x0020), ACC_STATIC_PHASE (0x0040), etc.
+	Version string // the version of the module at compile time, or ""
+}
+
+// ModulePackage is a package that a module exports or opens, either to all modules or,
+// if To is not empty, only to the modules in To
+type ModulePackage struct {
+	Package string
+	Flags   int // ACC_SYNTHETIC (0x1000) and ACC_MANDATED (0x8000)
+	To      []string
+}
+
+// ModuleProvides is a service that a module provides, and the classes in the module
+// that implement it
+type ModuleProvides struct {
+	Service string
+	With    []string
+}
+
 // ==== Constant Pool structs (in order by their numeric code) ====//
 type CpEntry struct {
 	Type uint16
@@ -231,44 +359,26 @@ type InvokeDynamicEntry struct { // type 18 (invokedynamic data)
 // entry as the Method it's returning.
 func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 	methFQN := class + "." + meth + methType // FQN = fully qualified name
-	methEntry := MTable[methFQN]
+	methEntry, _ := FetchMTableEntry(methFQN)
 	if methEntry.Meth == nil { // method is not in the MTable, so find it and put it there
-		k := Classes[class]
-		if k.Status == 'I' { // class is being initialized by a loader, so wait
-			time.Sleep(15 * time.Millisecond) // TODO: must be a better way to do this
-			k = Classes[class]
+		// if the class isn't loaded yet, load it now, or wait for the load in progress
+		if !isLoaded(class) && !isBeingLoaded(class) {
+			_ = LoadClassFromNameOnly(class)
 		}
-
-		if k.Loader == "" { // if class is not found, the zero value struct is returned
+		k, err := WaitForClassStatus(class)
+		if err != nil {
 			// TODO: check superclasses if method not found
 			_ = log.Log("Could not find class: "+class, log.SEVERE)
-			return MTentry{}, errors.New("class not found")
+			return MTentry{}, err
 		}
 
 		// the class has been found (k) so now go down the list of methods until
 		// we find one that matches the name we're looking for. Then return that
 		// method along with a pointer to the CP
-		for i := 0; i < len(k.Data.Methods); i++ {
-			if k.Data.CP.Utf8Refs[k.Data.Methods[i].Name] == meth &&
-				k.Data.CP.Utf8Refs[k.Data.Methods[i].Desc] == methType {
-				m := k.Data.Methods[i]
-				jme := JmEntry{
-					accessFlags: m.AccessFlags,
-					MaxStack:    m.CodeAttr.MaxStack,
-					MaxLocals:   m.CodeAttr.MaxLocals,
-					Code:        m.CodeAttr.Code,
-					exceptions:  m.CodeAttr.Exceptions,
-					attribs:     m.CodeAttr.Attributes,
-					params:      m.Parameters,
-					deprecated:  m.Deprecated,
-					Cp:          &k.Data.CP,
-				}
-				MTable[methFQN] = MTentry{
-					Meth:  jme,
-					MType: 'J',
-				}
-				return MTentry{Meth: jme, MType: 'J'}, nil
-			}
+		if jme, found := findMethodInClass(k.Data, meth, methType); found {
+			addEntry(&MTable, methFQN, MTentry{Meth: jme, MType: 'J'})
+			AdvanceClassStatus(class, 'L')
+			return MTentry{Meth: jme, MType: 'J'}, nil
 		}
 	} else { // we found the entry in the MTable
 		if methEntry.MType == 'J' {
@@ -304,3 +414,165 @@ func FetchUTF8stringFromCPEntryNumber(cp *CPool, entry uint16) string {
 
 	return cp.Utf8Refs[u.Slot]
 }
+
+// returns the name of the class whose ClassRef is at the given CP entry number in the
+// designated ClData.CP. Returns "" on error.
+func fetchClassNameFromCPEntryNumber(cp *CPool, entry uint16) string {
+	if entry < 1 || entry >= uint16(len(cp.CpIndex)) {
+		return ""
+	}
+
+	c := cp.CpIndex[entry]
+	if c.Type != ClassRef || int(c.Slot) >= len(cp.ClassRefs) {
+		return ""
+	}
+
+	return FetchUTF8stringFromCPEntryNumber(cp, cp.ClassRefs[c.Slot])
+}
+
+// returns the entry for the class itself in its InnerClasses attribute, if it has one
+func innerClassEntryOf(klass *ClData) (InnerClassEntry, bool) {
+	for _, ic := range klass.InnerClasses {
+		if fetchClassNameFromCPEntryNumber(&klass.CP, ic.InnerClass) == klass.Name {
+			return ic, true
+		}
+	}
+	return InnerClassEntry{}, false
+}
+
+// IsInnerClass reports whether the class is an inner (that is, nested) class: a member,
+// local, or anonymous class. Its InnerClasses attribute then has an entry for it.
+func IsInnerClass(klass *ClData) bool {
+	_, inner := innerClassEntryOf(klass)
+	return inner
+}
+
+// OuterClassName returns the name of the class of which the class is a member, such
+// as com/example/Outer for com/example/Outer$Inner. It returns "" if the class is not
+// an inner class, or if it's a local or anonymous class, which have no outer class.
+func OuterClassName(klass *ClData) string {
+	ic, inner := innerClassEntryOf(klass)
+	if !inner || ic.OuterClass == 0 {
+		return ""
+	}
+	return fetchClassNameFromCPEntryNumber(&klass.CP, ic.OuterClass)
+}
+
+// EnclosingMethodName returns the name of the method that encloses a local or anonymous
+// class, as in com/example/Outer.run()V, or just the name of the enclosing class if the
+// class is declared in an initializer. It returns "" for other classes.
+func EnclosingMethodName(klass *ClData) string {
+	if klass.EnclosingMethod == nil {
+		return ""
+	}
+	name := fetchClassNameFromCPEntryNumber(&klass.CP, klass.EnclosingMethod.Class)
+	index := klass.EnclosingMethod.Method
+	if index == 0 || int(index) >= len(klass.CP.CpIndex) || klass.CP.CpIndex[index].Type != NameAndType {
+		return name
+	}
+	nAndT := klass.CP.NameAndTypes[klass.CP.CpIndex[index].Slot]
+	return name + "." + FetchUTF8stringFromCPEntryNumber(&klass.CP, nAndT.NameIndex) +
+		FetchUTF8stringFromCPEntryNumber(&klass.CP, nAndT.DescIndex)
+}
+
+// SourceFileName returns the name of the source file the class was compiled from, as in
+// Hello2.java, or "Unknown Source" if its class file has no SourceFile attribute, as
+// Java's stack traces show it
+func SourceFileName(klass *ClData) string {
+	if klass.SourceFile == "" {
+		return "Unknown Source"
+	}
+	return klass.SourceFile
+}
+
+// IsRecord reports whether the class is a record class: that is, it extends
+// java/lang/Record and has a Record attribute, as Class.isRecord() requires
+func IsRecord(klass *ClData) bool {
+	return klass.Superclass == "java/lang/Record" && klass.RecordComponents != nil
+}
+
+// findMethodInClass looks for a method with the given name and type among the methods
+// declared in the class. Inherited methods are not checked.
+func findMethodInClass(class *ClData, meth string, methType string) (JmEntry, bool) {
+	if class == nil {
+		return JmEntry{}, false
+	}
+
+	for i := 0; i < len(class.Methods); i++ {
+		if class.CP.Utf8Refs[class.Methods[i].Name] == meth &&
+			class.CP.Utf8Refs[class.Methods[i].Desc] == methType {
+			m := class.Methods[i]
+			jme := JmEntry{
+				accessFlags: m.AccessFlags,
+				MaxStack:    m.CodeAttr.MaxStack,
+				MaxLocals:   m.CodeAttr.MaxLocals,
+				Code:        m.CodeAttr.Code,
+				exceptions:  m.CodeAttr.Exceptions,
+				attribs:     m.CodeAttr.Attributes,
+				params:      m.Parameters,
+				deprecated:  m.Deprecated,
+				Cp:          &class.CP,
+				LineNumbers: m.CodeAttr.LineNumbers,
+				LocalVars:   m.CodeAttr.LocalVariables,
+			}
+			return jme, true
+		}
+	}
+	return JmEntry{}, false
+}
+
+// FetchMethodFromHierarchy searches for a method starting in the named class and then
+// in its superclasses. It returns the method and the name of the class that declares
+// it. Go methods in the MTable are found as well as Java methods. Only classes that
+// have already been loaded are searched.
+func FetchMethodFromHierarchy(class, meth string, methType string) (MTentry, string, error) {
+	for class != "" {
+		if entry, ok := FetchMTableEntry(class + "." + meth + methType); ok && entry.Meth != nil {
+			return entry, class, nil
+		}
+
+		k, present := LookupClass(class)
+		if !present || k.Data == nil {
+			break
+		}
+
+		if jme, found := findMethodInClass(k.Data, meth, methType); found {
+			entry := MTentry{Meth: jme, MType: 'J'}
+			addEntry(&MTable, class+"."+meth+methType, entry)
+			AdvanceClassStatus(class, 'L')
+			return entry, class, nil
+		}
+		class = k.Data.Superclass
+	}
+	return MTentry{}, "", errors.New("method not found: " + meth + methType)
+}
+
+// InvokespecialStartClass returns the class in which invokespecial begins its search for
+// the method refClass.meth, when executed in a method of currentClass. Per the JVM spec,
+// that's the direct superclass of currentClass if all of these are true:
+//   - the method is not an instance initialization method (<init>)
+//   - refClass is a class (not an interface) and is a superclass of currentClass
+//   - currentClass has the ACC_SUPER flag set
+//
+// Otherwise, it's refClass. Without ACC_SUPER (that is, for classes compiled before
+// Java 1.1), the search always starts in refClass, even if a class between refClass
+// and currentClass overrides the method.
+func InvokespecialStartClass(currentClass, refClass, meth string) string {
+	if meth == "<init>" {
+		return refClass
+	}
+
+	current, currentPresent := LookupClass(currentClass)
+	ref, refPresent := LookupClass(refClass)
+
+	if !currentPresent || current.Data == nil || !current.Data.Access.ClassIsSuper {
+		return refClass
+	}
+	if refPresent && ref.Data != nil && ref.Data.Access.ClassIsInterface {
+		return refClass
+	}
+	if !isSubclassOf(current.Data, refClass) {
+		return refClass
+	}
+	return current.Data.Superclass
+}
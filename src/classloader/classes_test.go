@@ -10,7 +10,9 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -30,8 +32,8 @@ func TestInsertValid(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	Classes = make(map[string]Klass)
-	currLen := len(Classes)
+	Classes = sync.Map{}
+	currLen := ClassCount()
 	k := Klass{
 		Status: 0,
 		Loader: "",
@@ -45,7 +47,7 @@ func TestInsertValid(t *testing.T) {
 		t.Errorf("Got unexpected error on valid insertion into Classes[]: %s", err.Error())
 	}
 
-	newLen := len(Classes)
+	newLen := ClassCount()
 	if newLen != currLen+1 {
 		t.Errorf("Expected post-insertion Classes[] to have length of %d, got: %d",
 			currLen+1, newLen)
@@ -81,8 +83,8 @@ func TestInvalidLookupOfMethod_Test0(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	Classes = make(map[string]Klass)
-	currLen := len(Classes)
+	Classes = sync.Map{}
+	currLen := ClassCount()
 	k := Klass{
 		Status: 0,
 		Loader: "",
@@ -96,7 +98,7 @@ func TestInvalidLookupOfMethod_Test0(t *testing.T) {
 		t.Errorf("Got unexpected error on valid insertion into Classes[]: %s", err.Error())
 	}
 
-	newLen := len(Classes)
+	newLen := ClassCount()
 	if newLen != currLen+1 {
 		t.Errorf("Expected post-insertion Classes[] to have length of %d, got: %d",
 			currLen+1, newLen)
@@ -137,8 +139,8 @@ func TestInvalidLookupOfMethod_Test1(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	Classes = make(map[string]Klass)
-	currLen := len(Classes)
+	Classes = sync.Map{}
+	currLen := ClassCount()
 	k := Klass{
 		Status: 0,
 		Loader: "",
@@ -153,7 +155,7 @@ func TestInvalidLookupOfMethod_Test1(t *testing.T) {
 		t.Errorf("Got unexpected error on valid insertion into Classes[]: %s", err.Error())
 	}
 
-	newLen := len(Classes)
+	newLen := ClassCount()
 	if newLen != currLen+1 {
 		t.Errorf("Expected post-insertion Classes[] to have length of %d, got: %d",
 			currLen+1, newLen)
@@ -194,8 +196,8 @@ func TestInvalidLookupOfMethod_Test2(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	Classes = make(map[string]Klass)
-	currLen := len(Classes)
+	Classes = sync.Map{}
+	currLen := ClassCount()
 	k := Klass{
 		Status: 0,
 		Loader: "",
@@ -210,7 +212,7 @@ func TestInvalidLookupOfMethod_Test2(t *testing.T) {
 		t.Errorf("Got unexpected error on valid insertion into Classes[]: %s", err.Error())
 	}
 
-	newLen := len(Classes)
+	newLen := ClassCount()
 	if newLen != currLen+1 {
 		t.Errorf("Expected post-insertion Classes[] to have length of %d, got: %d",
 			currLen+1, newLen)
@@ -264,3 +266,225 @@ func TestFetchUTF8stringFromCPEntryNumber(t *testing.T) {
 		t.Error("Unexpected result in call toFetchUTF8stringFromCPEntryNumber()")
 	}
 }
+
+// creates a class with the given superclass and method m()V and posts it to the method area
+func postClassWithMethodM(name, superclass string, accSuper bool) {
+	klass := ClData{
+		Name:       name,
+		Superclass: superclass,
+		Methods:    []Method{{Name: 0, Desc: 1}},
+		Access:     AccessFlags{ClassIsSuper: accSuper},
+	}
+	klass.CP.Utf8Refs = []string{"m", "()V"}
+	_ = insert(name, Klass{Status: 'F', Loader: "app", Data: &klass})
+}
+
+// class A declares m(), class B extends A and overrides m(), and class C extends B.
+// C calls A.m() via invokespecial, as it would if compiled when B did not override m().
+func TestInvokespecialWithAndWithoutAccSuper(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	MTable = make(map[string]MTentry)
+
+	postClassWithMethodM("A", "java/lang/Object", true)
+	postClassWithMethodM("B", "A", true)
+
+	// with ACC_SUPER, the search begins in C's direct superclass, so B.m() is invoked
+	postClassWithMethodM("C", "B", true)
+	start := InvokespecialStartClass("C", "A", "m")
+	if start != "B" {
+		t.Errorf("With ACC_SUPER, expected search to start in B, got: %s", start)
+	}
+	_, declaringClass, err := FetchMethodFromHierarchy(start, "m", "()V")
+	if err != nil || declaringClass != "B" {
+		t.Errorf("With ACC_SUPER, expected B.m() to be invoked, got: %s.m(), err: %v", declaringClass, err)
+	}
+
+	// without ACC_SUPER, the old semantics apply: the search begins in A, so A.m() is invoked
+	postClassWithMethodM("C", "B", false)
+	start = InvokespecialStartClass("C", "A", "m")
+	if start != "A" {
+		t.Errorf("Without ACC_SUPER, expected search to start in A, got: %s", start)
+	}
+	_, declaringClass, err = FetchMethodFromHierarchy(start, "m", "()V")
+	if err != nil || declaringClass != "A" {
+		t.Errorf("Without ACC_SUPER, expected A.m() to be invoked, got: %s.m(), err: %v", declaringClass, err)
+	}
+
+	// constructors are always looked up in the referenced class
+	postClassWithMethodM("C", "B", true)
+	if start = InvokespecialStartClass("C", "A", "<init>"); start != "A" {
+		t.Errorf("Expected search for <init> to start in A, got: %s", start)
+	}
+}
+
+func TestFetchMethodFromHierarchyInheritedMethod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	MTable = make(map[string]MTentry)
+
+	postClassWithMethodM("Base", "java/lang/Object", true)
+	_ = insert("Derived", Klass{Status: 'F', Loader: "app",
+		Data: &ClData{Name: "Derived", Superclass: "Base"}})
+
+	_, declaringClass, err := FetchMethodFromHierarchy("Derived", "m", "()V")
+	if err != nil || declaringClass != "Base" {
+		t.Errorf("Expected inherited method Base.m(), got: %s.m(), err: %v", declaringClass, err)
+	}
+
+	if _, _, err = FetchMethodFromHierarchy("Derived", "missing", "()V"); err == nil {
+		t.Error("Expected an error for a method not in the hierarchy, but got none")
+	}
+}
+
+// inserts and lookups from many goroutines at once. This test is most useful when run
+// with -race, which reports any unsynchronized access to the method area or to the
+// classloaders' tables.
+func TestConcurrentInsertsAndLookups(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	_ = Init()
+	Classes = sync.Map{}
+	hello2 := getHello2Bytes(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		name := "Concurrent" + strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			_ = insert(name, Klass{Status: 'F', Loader: "app", Data: &ClData{Name: name}})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := ParseAndPostClass(AppCL, "Hello2.class", hello2); err != nil {
+				t.Errorf("Unexpected error posting Hello2: %s", err.Error())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = LookupClass(name)
+			_, _ = LookupClass("Hello2")
+			_ = AppCL.GetCountOfLoadedClasses()
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		name := "Concurrent" + strconv.Itoa(i)
+		if k, present := LookupClass(name); !present || k.Data.Name != name {
+			t.Errorf("Expected %s in the method area, got: %+v", name, k)
+		}
+	}
+	if ClassCount() != 11 {
+		t.Errorf("Expected 11 classes in the method area, got: %d", ClassCount())
+	}
+	if AppCL.GetCountOfLoadedClasses() != 1 {
+		t.Errorf("Expected the app classloader to have 1 class, got: %d", AppCL.GetCountOfLoadedClasses())
+	}
+}
+
+func TestIsInnerClassAndOuterClassName(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	tests := []struct {
+		name  string
+		bytes []byte
+		inner bool
+		outer string
+	}{
+		{"Hello2", Hello2Bytes, false, ""},
+		{"Outer$Inner", innerClassesClass(2), true, "Outer"},
+		{"Outer", innerClassesClass(6), false, ""},
+		{"Outer$1", innerClassesClass(10), true, ""},
+	}
+	for _, test := range tests {
+		klass, err := parse(test.bytes)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %s", test.name, err.Error())
+		}
+		kd := convertToPostableClass(&klass)
+		if kd.Name != test.name {
+			t.Fatalf("Expected to parse %s, got: %s", test.name, kd.Name)
+		}
+		if IsInnerClass(&kd) != test.inner {
+			t.Errorf("Expected IsInnerClass(%s) to be %t", test.name, test.inner)
+		}
+		if outer := OuterClassName(&kd); outer != test.outer {
+			t.Errorf("Expected the outer class of %s to be %q, got: %q", test.name, test.outer, outer)
+		}
+	}
+}
+
+// the class-loading trace shows the outer class of a member class
+func TestLoadTraceShowsOuterClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	_ = log.SetLogLevel(log.CLASS)
+	defer func() { _ = log.SetLogLevel(log.WARNING) }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err1 := ParseAndPostClass(AppCL, "Outer$Inner.class", innerClassesClass(2))
+	_, err2 := ParseAndPostClass(AppCL, "Outer$1.class", innerClassesClass(10))
+	_, err3 := ParseAndPostClass(AppCL, "Outer$1Local.class", localClass(0))
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatalf("Unexpected error loading the inner classes: %v, %v, %v", err1, err2, err3)
+	}
+	if !strings.Contains(msg, "Class: Outer$Inner, loader: app, inner class of Outer") {
+		t.Errorf("Expected the trace to show Outer$Inner as an inner class of Outer, got: %s", msg)
+	}
+	if !strings.Contains(msg, "Class: Outer$1, loader: app, source: Unknown Source\n") {
+		t.Errorf("Expected the trace of the anonymous class Outer$1 to name no outer class, got: %s", msg)
+	}
+	if !strings.Contains(msg, "Class: Outer$1Local, loader: app, declared in Outer.run()V") {
+		t.Errorf("Expected the trace to show the method that declares Outer$1Local, got: %s", msg)
+	}
+}
+
+// the -verbose:class trace of a class shows its source file, if it has one
+func TestLoadTraceShowsSourceFile(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	_ = log.SetLogLevel(log.CLASS)
+	defer func() { _ = log.SetLogLevel(log.WARNING) }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := ParseAndPostClass(AppCL, "Hello2.class", getHello2Bytes(t))
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Fatalf("Unexpected error loading Hello2: %s", err.Error())
+	}
+	if !strings.Contains(string(out), "Class: Hello2, loader: app, source: Hello2.java\n") {
+		t.Errorf("Expected the trace to show Hello2's source file, got: %s", string(out))
+	}
+
+	k, _ := LookupClass("Hello2")
+	if name := SourceFileName(k.Data); name != "Hello2.java" {
+		t.Errorf("Expected source file Hello2.java, got: %s", name)
+	}
+	if name := SourceFileName(&ClData{}); name != "Unknown Source" {
+		t.Errorf("Expected Unknown Source for a class without a SourceFile attribute, got: %s", name)
+	}
+}
@@ -14,12 +14,14 @@ import (
 	"io/fs"
 	"jacobin/globals"
 	"jacobin/log"
-	"jacobin/util"
+	"jacobin/management"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Classloader holds the parsed bytecode in classes, where they can be retrieved
@@ -33,6 +35,12 @@ type Classloader struct {
 	Archives map[string]*Archive // TODO: I think this should be moved to classpath when we make it a thing
 }
 
+// classloadersMutex guards the Classes and Archives maps of the classloaders, which
+// are updated by whichever goroutines load classes. A classloader's Classes holds every
+// class it has defined, even one whose name is shared by a class defined by another
+// classloader. (The method area, Classes, is a sync.Map, so it needs no lock.)
+var classloadersMutex sync.RWMutex
+
 // AppCL is the application classloader, which loads most of the app's classes
 var AppCL Classloader
 
@@ -44,22 +52,30 @@ var ExtensionCL Classloader
 
 // ParsedClass contains all the parsed fields
 type ParsedClass struct {
-	javaVersion    int
-	className      string // name of class without path and without .class
-	superClass     string // name of superclass for this class
-	moduleName     string
-	packageName    string
-	interfaceCount int   // number of interfaces this class implements
-	interfaces     []int // the interfaces this class implements, as indices into utf8Refs
-	fieldCount     int   // number of fields in this class
-	fields         []field
-	methodCount    int
-	methods        []method
-	attribCount    int
-	attributes     []attr
-	sourceFile     string
-	bootstrapCount int // the number of bootstrap methods
-	bootstraps     []bootstrapMethod
+	javaVersion      int
+	className        string // name of class without path and without .class
+	superClass       string // name of superclass for this class
+	moduleName       string
+	packageName      string
+	interfaceCount   int   // number of interfaces this class implements
+	interfaces       []int // the interfaces this class implements, as indices into utf8Refs
+	fieldCount       int   // number of fields in this class
+	fields           []field
+	methodCount      int
+	methods          []method
+	attribCount      int
+	attributes       []attr
+	sourceFile       string
+	signature        string            // the generic signature of the class, from the Signature attribute
+	bootstrapCount   int               // the number of bootstrap methods
+	BootstrapMethods []BootstrapMethod // from the BootstrapMethods attribute
+	innerClasses     []innerClassEntry // from the InnerClasses attribute
+	enclosing        *enclosingMethod  // from the EnclosingMethod attribute of a local or anonymous class
+	nestHost         string            // from the NestHost attribute of a nested class
+	nestMembers      []string          // from the NestMembers attribute of a nest host
+	permitted        []string          // from the PermittedSubclasses attribute of a sealed class
+	components       []recordComponent // from the Record attribute of a record class
+	moduleData       *ModuleData       // from the Module attribute of a module-info class
 
 	deprecated bool
 
@@ -100,10 +116,11 @@ type ParsedClass struct {
 // the fields defined in the class
 type field struct {
 	accessFlags int
-	name        int         // index of the UTF-8 entry in the CP
-	description int         // index of the UTF-8 entry in the CP
-	constValue  interface{} // the constant value if any was defined
+	name        int // index of the UTF-8 entry in the CP
+	description int // index of the UTF-8 entry in the CP
+	constValue  int // index of the CP entry with the field's ConstantValue, or 0 if it has none
 	attributes  []attr
+	signature   string // the generic signature, from the Signature attribute
 }
 
 // the methods of the class, including the constructors
@@ -115,15 +132,20 @@ type method struct {
 	attributes  []attr
 	exceptions  []int // indexes into Utf8Refs in the CP
 	parameters  []paramAttrib
-	deprecated  bool // is the method deprecated?
+	deprecated  bool   // is the method deprecated?
+	signature   string // the generic signature, from the Signature attribute
 }
 
 type codeAttrib struct {
-	maxStack   int
-	maxLocals  int
-	code       []byte
-	exceptions []exception // exception entries for this method
-	attributes []attr      // the code attributes has its own sub-attributes(!)
+	maxStack      int
+	maxLocals     int
+	code          []byte
+	exceptions    []exception // exception entries for this method
+	attributes    []attr      // the code attributes has its own sub-attributes(!)
+	stackMap      []StackMapFrame
+	lineNumbers   LineNumberTable
+	localVars     LocalVariableTable
+	localVarTypes LocalVariableTable
 }
 
 // the MethodParameters method attribute
@@ -148,9 +170,27 @@ type exception struct {
 }
 
 // the boostrap methods, specified in the bootstrap class attribute
-type bootstrapMethod struct {
-	methodRef int   // index pointing to a MethodHandle
-	args      []int // arguments: indexes to loadable arguments from the CP
+// an entry in the InnerClasses class attribute, for a class that's an inner class of,
+// or has as an inner class, this class
+type innerClassEntry struct {
+	innerClass  int // index of the inner class's ClassRef in the CP
+	outerClass  int // index of the outer class's ClassRef in the CP, or 0 if it has none
+	innerName   int // index of the inner class's simple name in the CP, or 0 if it's anonymous
+	accessFlags int // the inner class's access flags as declared in the source
+}
+
+// a component of a record class, from the class's Record attribute
+type recordComponent struct {
+	name        int // index of the UTF-8 entry in the CP
+	description int // index of the UTF-8 entry in the CP
+	attributes  []attr
+	signature   string // the generic signature, from the Signature attribute
+}
+
+// the EnclosingMethod class attribute of a local or anonymous class
+type enclosingMethod struct {
+	class  int // index of the enclosing class's ClassRef in the CP
+	method int // index of the enclosing method's NameAndType in the CP, or 0 if there's none
 }
 
 // var lock = sync.RWMutex{}
@@ -180,25 +220,26 @@ func cfe(msg string) error {
 // LoadBaseClasses loads a basic set of classes that are found in
 // JAVA_HOME/jmods/java.base.jmod directory. As of Jacobin 0.1.0,
 // that directory consists of roughly 1400 classes from the JDK.
+// Runtimes that have no jmods directory, such as JREs and those built
+// by jlink, have the classes in the JAVA_HOME/lib/modules jimage
+// instead, from which they're loaded if there's no java.base.jmod.
 func LoadBaseClasses(global *globals.Globals) {
 	if len(global.JavaHome) > 0 {
-		fname := global.JavaHome + string(os.PathSeparator) + "jmods" + string(os.PathSeparator) + "java.base.jmod"
+		jmodPath := filepath.Join(global.JavaHome, "jmods", "java.base.jmod")
+		jimagePath := filepath.Join(global.JavaHome, "lib", "modules")
 
-		jmodFile, err := os.Open(fname)
-		if err != nil {
-			_ = log.Log("Couldn't load JMOD file from "+fname, log.WARNING)
-		} else {
-			defer jmodFile.Close()
-			jmod := Jmod{File: *jmodFile}
-			err = jmod.Walk(func(bytes []byte, filename string) error {
-				_, err := loadClassFromBytes(BootstrapCL, filename, bytes)
-				return err
-			})
-
-			if err != nil {
-				_ = log.Log("Error loading jmod file "+fname, log.SEVERE)
-				_ = log.Log(err.Error(), log.SEVERE)
-			}
+		cachePath := ""
+		if global.UseClassCache {
+			cachePath = classCachePath()
+		}
+		switch {
+		case fileExists(jmodPath):
+			loadBaseClassesFromJmod(jmodPath, cachePath)
+		case fileExists(jimagePath):
+			loadBaseClassesFromJImage(jimagePath, cachePath)
+		default:
+			_ = log.Log("Couldn't load JMOD file from "+jmodPath+" or jimage file from "+jimagePath,
+				log.WARNING)
 		}
 	}
 
@@ -213,6 +254,100 @@ func LoadBaseClasses(global *globals.Globals) {
 	// }
 }
 
+// reports whether there's a file (rather than a directory) at path
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// loads the classes in java.base.jmod, from the class cache at cachePath if it's valid
+// for the JMOD. Otherwise, the classes are loaded from the JMOD, and then written to
+// the cache. If cachePath is "", the cache is not used.
+func loadBaseClassesFromJmod(fname string, cachePath string) {
+	postBaseClasses(fname, cachePath, func(walk ClassWalkFunc) error {
+		jmodFile, err := os.Open(fname)
+		if err != nil {
+			_ = log.Log("Couldn't load JMOD file from "+fname, log.WARNING)
+			return nil
+		}
+		defer jmodFile.Close()
+
+		jmod := Jmod{File: *jmodFile}
+		if err = jmod.Walk(walk); err != nil {
+			return errors.New("Error loading jmod file " + fname + "\n" + err.Error())
+		}
+		return nil
+	})
+}
+
+// loads the classes of the java.base module in the jimage at fname, as
+// loadBaseClassesFromJmod() does for java.base.jmod. The classes are filtered by
+// the classlist in the runtime's lib directory, if it has one.
+func loadBaseClassesFromJImage(fname string, cachePath string) {
+	classlist := make(map[string]struct{})
+	if content, err := os.ReadFile(filepath.Join(filepath.Dir(fname), "classlist")); err == nil {
+		classlist = parseClasslist(string(content))
+	} else {
+		_ = log.Log("No lib/classlist found beside "+fname+". Loading all classes in java.base.", log.CLASS)
+	}
+
+	postBaseClasses(fname, cachePath, func(walk ClassWalkFunc) error {
+		image, err := OpenJImage(fname)
+		if err != nil {
+			_ = log.Log("Couldn't load jimage file from "+fname+": "+err.Error(), log.WARNING)
+			return nil
+		}
+		defer image.Close()
+
+		err = image.Walk("java.base", func(entry ClassEntry) error {
+			if _, listed := classlist[entry.ClassName+".class"]; len(classlist) > 0 && !listed {
+				return nil
+			}
+			_ = walk(entry) // as in Jmod.Walk(), a class that fails to load doesn't stop the rest
+			return nil
+		})
+		if err != nil {
+			return errors.New("Error loading jimage file " + fname + "\n" + err.Error())
+		}
+		return nil
+	})
+}
+
+// posts the base classes to the method area, and to the bootstrap classloader, from the
+// class cache at cachePath if it's valid for the file at sourcePath. Otherwise, walkSource
+// is called to walk the classes in that file, each of which is posted and then, once
+// they've all been walked, written to the cache. If cachePath is "", the cache is not used.
+func postBaseClasses(sourcePath string, cachePath string, walkSource func(ClassWalkFunc) error) {
+	if cachePath != "" && loadBaseClassesFromCache(cachePath, sourcePath) {
+		return
+	}
+
+	var loaded []ClData
+	err := walkSource(func(entry ClassEntry) error {
+		name, err := loadClassFromBytes(BootstrapCL, entry.Filename(), entry.Bytes)
+		if err == nil && cachePath != "" {
+			classloadersMutex.RLock()
+			k, ok := BootstrapCL.Classes[name]
+			classloadersMutex.RUnlock()
+			if ok {
+				loaded = append(loaded, *k.Data)
+			}
+		}
+		return err
+	})
+
+	if err != nil {
+		_ = log.Log(err.Error(), log.SEVERE)
+		return
+	}
+
+	if cachePath != "" && len(loaded) > 0 {
+		if err = writeClassCache(cachePath, sourcePath, loaded); err != nil {
+			_ = log.Log("Warning: the class cache could not be written: "+err.Error(), log.WARNING)
+		}
+	}
+}
+
 // walk the directory and load every file (which is known to be a class)
 func walk(s string, d fs.DirEntry, err error) error {
 	if err != nil {
@@ -227,9 +362,12 @@ func walk(s string, d fs.DirEntry, err error) error {
 
 // LoadReferencedClasses loads the classes referenced in the loading of the class named clName.
 // It does this by reading the class entries (7) in the CP and sending the class names it finds
-// there to a go channel that will load the class.
+// there to a go channel that will load the class. This is done only when eager class loading
+// is enabled (-XX:+EagerClassLoading); otherwise, each class is loaded the first time the
+// interpreter needs it.
 func LoadReferencedClasses(clName string) {
-	cpClassCP := &Classes[clName].Data.CP
+	k, _ := LookupClass(clName)
+	cpClassCP := &k.Data.CP
 	classRefs := cpClassCP.ClassRefs
 
 	loaderChannel := make(chan string, len(classRefs))
@@ -246,52 +384,187 @@ func LoadReferencedClasses(clName string) {
 	close(loaderChannel)
 }
 
-// LoadFromLoaderChannel receives a name of a class to load in /java/lang/String format,
-// determines the classloader, checks if the class is already loaded, and loads it if not.
+// LoadFromLoaderChannel receives a name of a class to load in /java/lang/String format
+// and loads the class, unless it's already loaded or being loaded by another goroutine.
 func LoadFromLoaderChannel(LoaderChannel <-chan string) {
 	for name := range LoaderChannel {
-		_, present := Classes[name]
-		if present { // if the class is already loaded, skip rest of this loop
-			continue
+		_ = LoadClassFromNameOnly(name)
+	}
+	globals.LoaderWg.Done()
+}
+
+// a load of a class that's in progress. done is closed when the load finishes, and
+// err is then the result of the load.
+type classLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// the loads in progress, by class name. The goroutine that stores a class's load here
+// is the only one that loads the class; others wait for it to finish.
+var classLoads sync.Map
+
+// isLoaded reports whether the named class is in the method area, and its load has
+// neither failed nor is still in progress
+func isLoaded(name string) bool {
+	k, present := LookupClass(name)
+	return present && isLoadedStatus(k.Status)
+}
+
+// reports whether a class with the given status has been loaded, rather than being
+// loaded or having failed to load
+func isLoadedStatus(status byte) bool {
+	return status != 'I' && status != 'E'
+}
+
+// ranks the statuses of a loaded class in the order the class passes through them
+func statusRank(status byte) int {
+	switch status {
+	case 'F':
+		return 1
+	case 'V':
+		return 2
+	case 'L':
+		return 3
+	case 'N':
+		return 4
+	}
+	return 0
+}
+
+// AdvanceClassStatus records that the loaded class of the given name has reached the
+// given status: 'L' (linked) once its methods or its statics are used, and 'N' once it
+// has been instantiated. A class's status never moves backward, and the status of a
+// class that's being loaded, or whose load failed, isn't changed. The status is
+// updated both in the method area and in the classloader that defined the class.
+func AdvanceClassStatus(name string, status byte) {
+	k, present := LookupClass(name)
+	if !present || !isLoadedStatus(k.Status) || statusRank(k.Status) >= statusRank(status) {
+		return
+	}
+
+	classloadersMutex.Lock() // in the same order as Unload() takes these locks
+	defer classloadersMutex.Unlock()
+	methodAreaMutex.Lock()
+	defer methodAreaMutex.Unlock()
+
+	k, present = LookupClass(name)
+	if !present || !isLoadedStatus(k.Status) || statusRank(k.Status) >= statusRank(status) {
+		return
+	}
+	k.Status = status
+	Classes.Store(name, k)
+
+	cl := classloaderNamed(k.Loader)
+	if defined, ok := cl.Classes[name]; ok && defined.Data == k.Data {
+		defined.Status = status
+		cl.Classes[name] = defined
+	}
+}
+
+// ClassLoadTimeout is the longest that WaitForClassStatus() waits for a class to load
+var ClassLoadTimeout = 30 * time.Second
+
+// ClassNotLoadedError reports that a class can't be used, because it's not in the
+// method area, its load failed, or its load didn't finish within ClassLoadTimeout
+type ClassNotLoadedError struct {
+	Class  string
+	Reason string
+}
+
+func (e *ClassNotLoadedError) Error() string {
+	return "class " + e.Class + " is not available: " + e.Reason
+}
+
+// WaitForClassStatus returns the named class once its load has completed, so that its
+// methods and CP can be used. If the class is being loaded, it waits for the load to
+// finish, for up to ClassLoadTimeout. It doesn't start a load itself: if the class is
+// not in the method area and is not being loaded, or its load failed or timed out, it
+// returns a *ClassNotLoadedError.
+func WaitForClassStatus(name string) (Klass, error) {
+	timeout := time.NewTimer(ClassLoadTimeout)
+	defer timeout.Stop()
+
+	for {
+		if k, present := LookupClass(name); present && k.Status != 'I' {
+			if k.Status == 'E' {
+				return Klass{}, &ClassNotLoadedError{Class: name, Reason: "its load failed"}
+			}
+			return k, nil
+		}
+
+		load, loading := classLoads.Load(name)
+		if !loading {
+			// the load might have finished since the lookup above
+			if k, present := LookupClass(name); present && k.Status != 'I' {
+				continue
+			}
+			return Klass{}, &ClassNotLoadedError{Class: name, Reason: "it has not been loaded"}
 		}
 
-		// add entry to the method area, indicating initialization of the load of this class
-		eKI := Klass{
-			Status: 'I', // I = initializing the load
-			Loader: "",
-			Data:   nil,
+		select {
+		case <-load.(*classLoad).done:
+		case <-timeout.C:
+			return Klass{}, &ClassNotLoadedError{Class: name,
+				Reason: "its load did not finish within " + ClassLoadTimeout.String()}
 		}
-		_ = insert(name, eKI)
-		_ = LoadClassFromNameOnly(util.ConvertToPlatformPathSeparators(name))
 	}
-	globals.LoaderWg.Done()
 }
 
+// reports whether the named class is being loaded
+func isBeingLoaded(name string) bool {
+	_, loading := classLoads.Load(name)
+	return loading
+}
+
+// LoadClassFromNameOnly loads the named class, unless it's already loaded. If several
+// goroutines load the same class at once, only one of them loads it; the others wait
+// for that load to finish and return its result.
 func LoadClassFromNameOnly(name string) error {
-	_, present := Classes[name]
-	if present { // if the class is already loaded, skip rest of this
+	if isLoaded(name) { // if the class is already loaded, skip rest of this
 		return nil
 	}
 
+	load := &classLoad{done: make(chan struct{})}
+	if inProgress, loading := classLoads.LoadOrStore(name, load); loading {
+		<-inProgress.(*classLoad).done
+		return inProgress.(*classLoad).err
+	}
+	defer func() {
+		close(load.done)
+		classLoads.Delete(name)
+	}()
+
+	// the class might have been loaded between the check above and the LoadOrStore()
+	if isLoaded(name) {
+		return nil
+	}
+	load.err = loadClassByName(name)
+	return load.err
+}
+
+// loads the named class for LoadClassFromNameOnly(), which ensures that only one
+// goroutine at a time loads any given class. The class is loaded through the
+// application classloader, which delegates to its parents first, and is then linked.
+func loadClassByName(name string) error {
 	// add entry to the method area, indicating initialization of the load of this class
 	eKI := Klass{
 		Status: 'I', // I = initializing the load
 		Loader: "",
 		Data:   nil,
 	}
-	err := insert(name, eKI)
-
-	validName := util.ConvertToPlatformPathSeparators(name)
-	if strings.HasPrefix(name, "java/") || strings.HasPrefix(name, "jdk/") ||
-		strings.HasPrefix(name, "javax/") || strings.HasPrefix(name, "sun/") {
-		name = util.ConvertInternalClassNameToFilename(name)
-		name = globals.JacobinHome() + "classes" + string(os.PathSeparator) + name
-		validName = util.ConvertToPlatformPathSeparators(name)
-		_, err = LoadClassFromFile(BootstrapCL, validName)
-	} else if len(globals.GetGlobalRef().StartingJar) > 0 {
-		_, err = LoadClassFromJar(AppCL, validName, globals.GetGlobalRef().StartingJar)
-	} else {
-		_, err = LoadClassFromFile(AppCL, validName)
+	_ = insert(name, eKI)
+
+	err := LoadClass(&AppCL, name)
+	if err == nil {
+		return LinkClass(name)
+	}
+
+	// mark the placeholder entry as failed, so that nothing waits forever for this
+	// load to complete and so that a later attempt to load the class tries again
+	if k, ok := LookupClass(name); ok && k.Status == 'I' {
+		k.Status = 'E'
+		Classes.Store(name, k)
 	}
 	return err
 }
@@ -313,7 +586,9 @@ func LoadClassFromFile(cl Classloader, filename string) (string, error) {
 }
 
 func getJarFile(cl Classloader, jarFileName string) (*Archive, error) {
+	classloadersMutex.RLock()
 	archive, exists := cl.Archives[jarFileName]
+	classloadersMutex.RUnlock()
 
 	if exists {
 		return archive, nil
@@ -325,19 +600,28 @@ func getJarFile(cl Classloader, jarFileName string) (*Archive, error) {
 		return nil, err
 	}
 
+	// if another goroutine opened the JAR meanwhile, use its archive
+	classloadersMutex.Lock()
+	defer classloadersMutex.Unlock()
+	if archive, exists = cl.Archives[jarFileName]; exists {
+		return archive, nil
+	}
 	cl.Archives[jarFileName] = jar
 
 	return jar, nil
 }
 
-func GetMainClassFromJar(cl Classloader, jarFileName string) (string, error) {
+// GetMainClassFromJar returns the Main-Class named in the JAR's manifest, and the
+// entries of its Class-Path attribute, resolved against the directory of the JAR.
+// Entries that don't exist are logged and left out.
+func GetMainClassFromJar(cl Classloader, jarFileName string) (string, []string, error) {
 	jar, err := getJarFile(cl, jarFileName)
 
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return jar.getMainClass(), nil
+	return jar.getMainClass(), resolveJarClassPath(jarFileName, jar.getClassPath()), nil
 }
 
 func LoadClassFromJar(cl Classloader, filename string, jarFileName string) (string, error) {
@@ -367,6 +651,16 @@ func loadClassFromBytes(cl Classloader, filename string, rawBytes []byte) (strin
 // ParseAndPostClass parses a class, presented as a slice of bytes, and
 // if no errors occurred, posts/loads it to the method area.
 func ParseAndPostClass(cl Classloader, filename string, rawBytes []byte) (string, error) {
+	name, err := parseAndPost(cl, filename, rawBytes)
+	if err == nil && isRedefinable(cl) {
+		cacheClassBytes(name, filename, rawBytes) // kept for retransformation
+	}
+	return name, err
+}
+
+// parseAndPost does the work of ParseAndPostClass(), without caching the class's bytes
+func parseAndPost(cl Classloader, filename string, rawBytes []byte) (string, error) {
+	loadStart := time.Now()
 	fullyParsedClass, err := parse(rawBytes)
 	if err != nil {
 		_ = log.Log("error parsing "+filename+". Exiting.", log.SEVERE)
@@ -379,32 +673,59 @@ func ParseAndPostClass(cl Classloader, filename string, rawBytes []byte) (string
 		return "", fmt.Errorf("format-checking error")
 	}
 	_ = log.Log("Class "+fullyParsedClass.className+" has been format-checked.", log.FINEST)
+	checkSignatures(&fullyParsedClass)
 
 	classToPost := convertToPostableClass(&fullyParsedClass)
+	if parseAnnotationAttributes(&classToPost) != nil {
+		_ = log.Log("error format-checking "+filename+". Exiting.", log.SEVERE)
+		return "", fmt.Errorf("format-checking error")
+	}
+	if classToPost.Module == "" { // only module-info classes name their module in the CP
+		classToPost.Module = moduleFromFilename(filename)
+	}
 	eKF := Klass{
 		Status: 'F', // F = format-checked
 		Loader: cl.Name,
 		Data:   &classToPost,
 	}
 	_ = insert(fullyParsedClass.className, eKF)
+	recordClassStats(cl.Name, &fullyParsedClass, loadStart)
 
-	// record the class in the classloader
-	cl.Classes[fullyParsedClass.className] = eKF
+	cl.define(fullyParsedClass.className, eKF)
 	return fullyParsedClass.className, nil
 }
 
-// insert the fully parsed class into the method area (exec.Classes)
+// insert the fully parsed class into the method area (exec.Classes). The method area
+// holds the classes as the application classloader sees them, so if a class of the
+// same name has already been loaded by a classloader that's higher in the parent chain,
+// that class is kept; the new class is found only in its own classloader's Classes.
 func insert(name string, klass Klass) error {
-	MethAreaMutex.Lock()
-	Classes[name] = klass
-	MethAreaMutex.Unlock()
-
 	if klass.Status == 'F' || klass.Status == 'V' || klass.Status == 'L' {
-		_ = log.Log("Class: "+klass.Data.Name+", loader: "+klass.Loader, log.CLASS)
+		msg := "Class: " + klass.Data.Name + ", loader: " + klass.Loader
+		if outer := OuterClassName(klass.Data); outer != "" {
+			msg += ", inner class of " + outer
+		} else if enclosing := EnclosingMethodName(klass.Data); enclosing != "" {
+			msg += ", declared in " + enclosing
+		}
+		msg += ", source: " + SourceFileName(klass.Data)
+		_ = log.Log(msg, log.CLASS)
+	}
+
+	methodAreaMutex.Lock()
+	defer methodAreaMutex.Unlock()
+	current, present := LookupClass(name)
+	if present && isLoadedStatus(current.Status) && isLoadedStatus(klass.Status) &&
+		loaderDepth(current.Loader) < loaderDepth(klass.Loader) {
+		return nil
 	}
+	Classes.Store(name, klass)
 	return nil
 }
 
+// methodAreaMutex makes insert()'s check of the class already in the method area and
+// its replacement of that class a single step
+var methodAreaMutex sync.Mutex
+
 // load the parsed class into a form suitable for posting to the method area (which is
 // exec.Classes. This mostly involves copying the data, converting most indexes to uint16
 // and removing some fields we needed in parsing, but which are no longer required.
@@ -412,6 +733,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 
 	kd := ClData{}
 	kd.Name = fullyParsedClass.className
+	kd.JavaVersion = fullyParsedClass.javaVersion
 	kd.Superclass = fullyParsedClass.superClass
 	kd.Module = fullyParsedClass.moduleName
 	kd.Pkg = fullyParsedClass.packageName
@@ -421,6 +743,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 	if len(fullyParsedClass.fields) > 0 {
 		for i := 0; i < len(fullyParsedClass.fields); i++ {
 			kdf := Field{}
+			kdf.AccessFlags = fullyParsedClass.fields[i].accessFlags
 			kdf.Name = uint16(fullyParsedClass.fields[i].name)
 			kdf.Desc = uint16(fullyParsedClass.fields[i].description)
 			if len(fullyParsedClass.fields[i].attributes) > 0 {
@@ -432,6 +755,8 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 					kdf.Attributes = append(kdf.Attributes, kdfa)
 				}
 			}
+			kdf.Signature = fullyParsedClass.fields[i].signature
+			kdf.ConstantValue = uint16(fullyParsedClass.fields[i].constValue)
 			kd.Fields = append(kd.Fields, kdf)
 		}
 	}
@@ -464,6 +789,10 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 					kdm.CodeAttr.Attributes = append(kdm.CodeAttr.Attributes, kdmca)
 				}
 			}
+			kdm.CodeAttr.StackMapFrames = fullyParsedClass.methods[i].codeAttr.stackMap
+			kdm.CodeAttr.LineNumbers = fullyParsedClass.methods[i].codeAttr.lineNumbers
+			kdm.CodeAttr.LocalVariables = fullyParsedClass.methods[i].codeAttr.localVars
+			kdm.CodeAttr.LocalVariableTypes = fullyParsedClass.methods[i].codeAttr.localVarTypes
 			if len(fullyParsedClass.methods[i].attributes) > 0 {
 				for n := 0; n < len(fullyParsedClass.methods[i].attributes); n++ {
 					kdma := Attr{
@@ -489,6 +818,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 				}
 			}
 			kdm.Deprecated = fullyParsedClass.methods[i].deprecated
+			kdm.Signature = fullyParsedClass.methods[i].signature
 			kd.Methods = append(kd.Methods, kdm)
 		}
 	}
@@ -503,20 +833,43 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 		}
 	}
 	kd.SourceFile = fullyParsedClass.sourceFile
-	if len(fullyParsedClass.bootstraps) > 0 {
-		for j := 0; j < len(fullyParsedClass.bootstraps); j++ {
-			kdbs := BootstrapMethod{
-				MethodRef: uint16(fullyParsedClass.bootstraps[j].methodRef),
-				Args:      nil,
+	kd.Signature = fullyParsedClass.signature
+	if len(fullyParsedClass.BootstrapMethods) > 0 {
+		kd.Bootstraps = fullyParsedClass.BootstrapMethods
+	}
+	for _, ic := range fullyParsedClass.innerClasses {
+		kd.InnerClasses = append(kd.InnerClasses, InnerClassEntry{
+			InnerClass:  uint16(ic.innerClass),
+			OuterClass:  uint16(ic.outerClass),
+			InnerName:   uint16(ic.innerName),
+			AccessFlags: ic.accessFlags,
+		})
+	}
+	if fullyParsedClass.enclosing != nil {
+		kd.EnclosingMethod = &EnclosingMethodEntry{
+			Class:  uint16(fullyParsedClass.enclosing.class),
+			Method: uint16(fullyParsedClass.enclosing.method),
+		}
+	}
+	kd.NestHost = fullyParsedClass.nestHost
+	kd.NestMembers = fullyParsedClass.nestMembers
+	kd.PermittedSubclasses = fullyParsedClass.permitted
+	if fullyParsedClass.components != nil {
+		kd.RecordComponents = make([]RecordComponent, 0, len(fullyParsedClass.components))
+		for _, rc := range fullyParsedClass.components {
+			kdrc := RecordComponent{
+				Name:      uint16(rc.name),
+				Desc:      uint16(rc.description),
+				Signature: rc.signature,
 			}
-			if len(fullyParsedClass.bootstraps[j].args) > 0 {
-				for l := 0; l < len(fullyParsedClass.bootstraps[j].args); l++ {
-					kdbs.Args = append(kdbs.Args, uint16(fullyParsedClass.bootstraps[j].args[l]))
-				}
+			for _, a := range rc.attributes {
+				kdrc.Attributes = append(kdrc.Attributes,
+					Attr{AttrName: uint16(a.attrName), AttrSize: a.attrSize, AttrContent: a.attrContent})
 			}
-			kd.Bootstraps = append(kd.Bootstraps, kdbs)
+			kd.RecordComponents = append(kd.RecordComponents, kdrc)
 		}
 	}
+	kd.ModuleData = fullyParsedClass.moduleData
 	kd.Access.ClassIsPublic = fullyParsedClass.classIsPublic
 	kd.Access.ClassIsFinal = fullyParsedClass.classIsFinal
 	kd.Access.ClassIsSuper = fullyParsedClass.classIsSuper
@@ -670,9 +1023,24 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 	return kd
 }
 
+// define records a class in the classloader that has loaded it. A class that the
+// classloader hadn't already defined is counted in the management metrics.
+func (cl *Classloader) define(name string, klass Klass) {
+	classloadersMutex.Lock()
+	_, redefined := cl.Classes[name]
+	cl.Classes[name] = klass
+	classloadersMutex.Unlock()
+
+	if !redefined {
+		management.RecordClassLoad()
+	}
+}
+
 // GetCountOfLoadedClasses returns the number of classes loaded
 // by the classloader
 func (cl *Classloader) GetCountOfLoadedClasses() int {
+	classloadersMutex.RLock()
+	defer classloadersMutex.RUnlock()
 	return len(cl.Classes)
 }
 
@@ -710,5 +1078,10 @@ func Init() error {
 	AppCL.Classes = make(map[string]Klass)
 	AppCL.Archives = make(map[string]*Archive)
 
+	// the classloaders start out empty, so no class's bytes are kept
+	classBytesMutex.Lock()
+	classBytesCache = make(map[string]cachedClass)
+	classBytesMutex.Unlock()
+
 	return nil
 }
@@ -7,13 +7,21 @@
 package classloader
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // Most of the functionality in classloader package is tested in other files, such as
@@ -116,6 +124,12 @@ func TestLoadBaseClassesInvalid(t *testing.T) {
 		t.Errorf("Expecting err msg 'Couldn't load JMOD file', but got %s",
 			errMsg)
 	}
+	for _, path := range []string{filepath.Join("gherkin", "jmods", "java.base.jmod"),
+		filepath.Join("gherkin", "lib", "modules")} {
+		if !strings.Contains(errMsg, path) {
+			t.Errorf("Expecting err msg to name %s, but got %s", path, errMsg)
+		}
+	}
 }
 
 func TestLoadClassFromFileInvalidName(t *testing.T) {
@@ -195,6 +209,62 @@ func TestConvertToPostableClassStringRefs(t *testing.T) {
 	}
 }
 
+// the MethodHandle and MethodType entries that a lambda compiles to are carried through
+// to the postable class, where they still resolve to the methods and descriptors they
+// name in the class file
+func TestConvertToPostableClassMethodHandlesAndTypes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(lambdaClass(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the lambda class: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking the lambda class: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	cp := &kd.CP
+
+	var handles []string
+	for i, entry := range cp.CpIndex {
+		if entry.Type != MethodHandle {
+			continue
+		}
+		mh := cp.MethodHandles[entry.Slot]
+		if cp.CpIndex[mh.RefIndex].Type != MethodRef {
+			t.Fatalf("Expected the MethodHandle at CP entry %d to point to a MethodRef", i)
+		}
+		mr := cp.MethodRefs[cp.CpIndex[mh.RefIndex].Slot]
+		nAndT := cp.NameAndTypes[cp.CpIndex[mr.NameAndType].Slot]
+		handles = append(handles, fmt.Sprintf("%d:%s.%s%s", mh.RefKind,
+			fetchClassNameFromCPEntryNumber(cp, mr.ClassIndex),
+			FetchUTF8stringFromCPEntryNumber(cp, nAndT.NameIndex),
+			FetchUTF8stringFromCPEntryNumber(cp, nAndT.DescIndex)))
+	}
+	sort.Strings(handles)
+	expected := []string{
+		"6:Lambda.lambda$main$0()V",
+		"6:java/lang/invoke/LambdaMetafactory.metafactory(Ljava/lang/invoke/MethodHandles$Lookup;" +
+			"Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodType;" +
+			"Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;",
+	}
+	if !reflect.DeepEqual(handles, expected) {
+		t.Errorf("Expected MethodHandles %v, got: %v", expected, handles)
+	}
+
+	if len(cp.MethodTypes) != len(klass.methodTypes) || len(cp.MethodTypes) == 0 {
+		t.Fatalf("Expected %d MethodTypes, got: %d", len(klass.methodTypes), len(cp.MethodTypes))
+	}
+	for _, entry := range cp.CpIndex {
+		if entry.Type == MethodType {
+			if desc := FetchUTF8stringFromCPEntryNumber(cp, cp.MethodTypes[entry.Slot]); desc != "()V" {
+				t.Errorf("Expected the MethodType to be ()V, got: %q", desc)
+			}
+		}
+	}
+}
+
 func TestGetInvalidJar(t *testing.T) {
 	globals.InitGlobals("test")
 	log.Init()
@@ -277,7 +347,7 @@ func TestMainClassFromInvalidJar(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	_, err := GetMainClassFromJar(BootstrapCL, "gherkin")
+	_, _, err := GetMainClassFromJar(BootstrapCL, "gherkin")
 	if err == nil {
 		t.Errorf("expected err msg for loading main class from invalid JAR, but got none")
 	}
@@ -311,7 +381,7 @@ func TestInsertionIntoMethodArea(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	Classes = make(map[string]Klass)
+	Classes = sync.Map{}
 
 	k := Klass{}
 	k.Status = 'F'
@@ -335,8 +405,8 @@ func TestInsertionIntoMethodArea(t *testing.T) {
 		t.Error("Got unexpected logging message for insertion of Klass into method area: " + msg)
 	}
 
-	if len(Classes) != 1 {
-		t.Errorf("Expecting method area to have a size of 1, got: %d", len(Classes))
+	if ClassCount() != 1 {
+		t.Errorf("Expecting method area to have a size of 1, got: %d", ClassCount())
 	}
 }
 
@@ -437,3 +507,128 @@ func TestLoadFullyParsedClass(t *testing.T) {
 		t.Errorf("Invalid number of methods in Hello2.class: %d", len(classToPost.Methods))
 	}
 }
+
+// a module loader that counts the times it's asked for a class, and takes long enough
+// to load it that concurrent requests for the class overlap
+type countingModuleLoader struct {
+	classBytes []byte
+	loads      int32
+}
+
+func (l *countingModuleLoader) LoadClassByName(ctx context.Context, name string) ([]byte, error) {
+	atomic.AddInt32(&l.loads, 1)
+	time.Sleep(20 * time.Millisecond)
+	return l.classBytes, nil
+}
+
+func TestConcurrentLoadsOfClassLoadItOnce(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	Classes = sync.Map{}
+
+	loader := &countingModuleLoader{classBytes: getHello2Bytes(t)}
+	ModuleLoaders = []ModuleClassLoader{loader}
+	defer func() { ModuleLoaders = nil }()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- LoadClassFromNameOnly("Hello2")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Unexpected error loading Hello2 concurrently: %s", err.Error())
+		}
+	}
+	if loads := atomic.LoadInt32(&loader.loads); loads != 1 {
+		t.Errorf("Expected Hello2 to be loaded once, but it was loaded %d times", loads)
+	}
+	if k, present := LookupClass("Hello2"); !present || k.Status != 'F' || k.Loader != "app" {
+		t.Errorf("Expected Hello2 to be in the method area, format-checked, got: %+v", k)
+	}
+	// linking Hello2 skips java/lang/Object, which can't be loaded without JACOBIN_HOME
+	if ClassCount() != 1 {
+		t.Errorf("Expected the method area to have 1 entry, got: %d", ClassCount())
+	}
+}
+
+// waits until the named class is being loaded
+func awaitLoadStart(t *testing.T, name string) {
+	for i := 0; !isBeingLoaded(name); i++ {
+		if i > 1000 {
+			t.Fatalf("The load of %s never started", name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForClassStatusWaitsForLoad(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	Classes = sync.Map{}
+
+	ModuleLoaders = []ModuleClassLoader{&countingModuleLoader{classBytes: getHello2Bytes(t)}}
+	defer func() { ModuleLoaders = nil }()
+
+	go func() { _ = LoadClassFromNameOnly("Hello2") }()
+	awaitLoadStart(t, "Hello2")
+
+	k, err := WaitForClassStatus("Hello2")
+	if err != nil {
+		t.Fatalf("Unexpected error waiting for Hello2 to load: %s", err.Error())
+	}
+	if k.Status != 'F' || k.Data == nil || k.Data.Name != "Hello2" {
+		t.Errorf("Expected Hello2 to be format-checked once its load finished, got: %+v", k)
+	}
+
+	me, err := FetchMethodAndCP("Hello2", "main", "([Ljava/lang/String;)V")
+	if err != nil || me.MType != 'J' {
+		t.Errorf("Expected to fetch Hello2.main(), got: %+v, error: %v", me, err)
+	}
+}
+
+func TestWaitForClassStatusErrors(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	_ = Init()
+	Classes = sync.Map{}
+
+	var notLoaded *ClassNotLoadedError
+	if _, err := WaitForClassStatus("NeverLoaded"); !errors.As(err, &notLoaded) ||
+		!strings.Contains(err.Error(), "has not been loaded") {
+		t.Errorf("Expected an error for a class that's not loaded, got: %v", err)
+	}
+
+	_ = insert("FailedLoad", Klass{Status: 'E'})
+	if _, err := WaitForClassStatus("FailedLoad"); !errors.As(err, &notLoaded) ||
+		!strings.Contains(err.Error(), "load failed") {
+		t.Errorf("Expected an error for a class whose load failed, got: %v", err)
+	}
+
+	// the loader takes 20ms, which is longer than the timeout
+	normalTimeout := ClassLoadTimeout
+	ClassLoadTimeout = time.Millisecond
+	defer func() { ClassLoadTimeout = normalTimeout }()
+	ModuleLoaders = []ModuleClassLoader{&countingModuleLoader{classBytes: getHello2Bytes(t)}}
+	defer func() { ModuleLoaders = nil }()
+
+	done := make(chan struct{})
+	go func() { _ = LoadClassFromNameOnly("Hello2"); close(done) }()
+	awaitLoadStart(t, "Hello2")
+
+	if _, err := WaitForClassStatus("Hello2"); !errors.As(err, &notLoaded) ||
+		!strings.Contains(err.Error(), "did not finish within 1ms") {
+		t.Errorf("Expected a timeout waiting for Hello2 to load, got: %v", err)
+	}
+	<-done
+}
@@ -0,0 +1,305 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"jacobin/globals"
+	"jacobin/log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// appClassPath returns the entries of the application classpath, in search order. Each
+// entry is a directory or a JAR file. If Jacobin was started with -jar, the classpath
+// is the starting JAR followed by the entries of its manifest's Class-Path, which
+// jvmStart puts in ClassPath in place of any -cp entries; otherwise, it's the -cp (or -classpath) entries, if there are any.
+// Failing that, it's the directory of the starting class file or, if the starting
+// class was given by name, the current directory.
+func appClassPath() []string {
+	global := globals.GetGlobalRef()
+	switch {
+	case len(global.StartingJar) > 0:
+		return append([]string{global.StartingJar}, global.ClassPath...)
+	case len(global.ClassPath) > 0:
+		return global.ClassPath
+	case strings.HasSuffix(global.StartingClass, ".class"):
+		return []string{filepath.Dir(global.StartingClass)}
+	default:
+		return []string{"."}
+	}
+}
+
+// resolves the entries of the Class-Path attribute in a JAR's manifest, which are
+// relative URLs (e.g., lib/dependency.jar), against the directory of the JAR. Entries
+// that don't exist are logged and skipped.
+func resolveJarClassPath(jarFileName string, entries []string) []string {
+	var classPath []string
+	jarDir := filepath.Dir(jarFileName)
+	for _, entry := range entries {
+		path, err := url.PathUnescape(entry)
+		if err != nil {
+			_ = log.Log("Invalid Class-Path entry in the manifest of "+jarFileName+": "+entry, log.CLASS)
+			continue
+		}
+		path = filepath.FromSlash(strings.TrimPrefix(path, "file:"))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(jarDir, path)
+		}
+		if _, err = os.Stat(path); err != nil {
+			_ = log.Log("Class-Path entry in the manifest of "+jarFileName+" not found: "+path, log.CLASS)
+			continue
+		}
+		_ = log.Log("Adding to the classpath from the manifest of "+jarFileName+": "+path, log.CLASS)
+		classPath = append(classPath, path)
+	}
+	return classPath
+}
+
+// isJarFile reports whether a classpath entry is a JAR file rather than a directory
+func isJarFile(entry string) bool {
+	return strings.HasSuffix(strings.ToLower(entry), ".jar")
+}
+
+// ClasspathResolver finds the bytes of classes on a classpath of directories and JARs.
+// Each JAR is opened only once, when it's first searched, and the class files it
+// contains are indexed by name then; its other entries are skipped.
+type ClasspathResolver struct {
+	entries []string
+	jars    map[string]*classpathJar
+	mutex   sync.Mutex
+}
+
+// a JAR on the classpath. reader, classes, and files are set by index(); if the JAR
+// could not be opened, they're nil and err says why.
+type classpathJar struct {
+	path    string
+	once    sync.Once
+	reader  *zip.ReadCloser
+	classes map[string]*zip.File // class name (in com/example/Main format) -> entry
+	files   map[string]*zip.File // entry name -> entry, for every entry but directories
+	err     error
+}
+
+// NewClasspathResolver returns a resolver for the given classpath entries, which are
+// searched in order
+func NewClasspathResolver(entries []string) *ClasspathResolver {
+	return &ClasspathResolver{entries: entries, jars: make(map[string]*classpathJar)}
+}
+
+// ClassBytes returns the bytes of the named class (in com/example/Main format) from
+// the first classpath entry that contains it, along with the class's location, which
+// for a class in a JAR is the JAR's path and the entry's name, separated by a +.
+// If no entry contains the class, it returns nil bytes and no error.
+func (r *ClasspathResolver) ClassBytes(name string) ([]byte, string, error) {
+	for _, entry := range r.entries {
+		if !isJarFile(entry) {
+			filename := filepath.Join(entry, filepath.FromSlash(name)+".class")
+			if rawBytes, err := os.ReadFile(filename); err == nil {
+				return rawBytes, filename, nil
+			}
+			continue
+		}
+
+		jar := r.jar(entry)
+		file, ok := jar.classes[name]
+		if !ok {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		rawBytes, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		return rawBytes, entry + "+" + file.Name, nil
+	}
+	return nil, "", nil
+}
+
+// LoadResourceByName returns the bytes of the named resource (e.g.,
+// com/example/config.properties) from the first classpath entry that contains it,
+// searching the entries in the same order as ClassBytes(). If no entry contains the
+// resource, it returns nil bytes and no error.
+func (r *ClasspathResolver) LoadResourceByName(name string) ([]byte, error) {
+	for _, entry := range r.entries {
+		if !isJarFile(entry) {
+			filename, ok := resourceFile(entry, name)
+			if !ok {
+				return nil, nil // the name leads out of the classpath entry
+			}
+			if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+				return os.ReadFile(filename)
+			}
+			continue
+		}
+
+		file, ok := r.jar(entry).files[name]
+		if !ok {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		rawBytes, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		return rawBytes, nil
+	}
+	return nil, nil
+}
+
+// resourceFile returns the file of the named resource, whose path elements are
+// separated by /, in the directory root. It returns false if the name, once cleaned,
+// leads out of root (e.g., ../../etc/passwd), so that resources can't be used to
+// read files elsewhere on the host.
+func resourceFile(root, name string) (string, bool) {
+	filename := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filename, true
+}
+
+// returns the named JAR, indexed
+func (r *ClasspathResolver) jar(path string) *classpathJar {
+	r.mutex.Lock()
+	jar, ok := r.jars[path]
+	if !ok {
+		jar = &classpathJar{path: path}
+		r.jars[path] = jar
+	}
+	r.mutex.Unlock()
+
+	jar.once.Do(jar.index)
+	return jar
+}
+
+// opens the JAR and indexes the class files in it, and all its other entries, which
+// are resources. A JAR that can't be opened is reported once, then treated as empty.
+// In a multi-release JAR, each class is indexed by the entry to load for Jacobin's
+// Java version (see releaseEntries()).
+func (jar *classpathJar) index() {
+	jar.reader, jar.err = zip.OpenReader(jar.path)
+	if jar.err != nil {
+		if errors.Is(jar.err, fs.ErrNotExist) {
+			_ = log.Log("Warning: JAR file on the classpath not found, so it's skipped: "+jar.path, log.WARNING)
+		} else {
+			_ = log.Log("Warning: JAR file on the classpath cannot be read, so it's skipped: "+
+				jar.path+" ("+jar.err.Error()+")", log.WARNING)
+		}
+		return
+	}
+
+	jar.classes = make(map[string]*zip.File)
+	jar.files = make(map[string]*zip.File)
+	var manifest map[string]string
+	for _, file := range jar.reader.File {
+		if !file.FileInfo().IsDir() {
+			jar.files[file.Name] = file
+		}
+		if strings.HasSuffix(file.Name, ".class") && !file.FileInfo().IsDir() {
+			jar.classes[strings.TrimSuffix(file.Name, ".class")] = file
+		} else if file.Name == "META-INF/MANIFEST.MF" {
+			var err error
+			if manifest, err = readManifest(file); err != nil {
+				_ = log.Log("Warning: the manifest of JAR file "+jar.path+" cannot be read: "+err.Error(), log.WARNING)
+			}
+		}
+	}
+
+	if isMultiRelease(manifest) {
+		files := make(map[string]*zip.File, len(jar.classes))
+		var classEntries []string
+		for _, file := range jar.classes {
+			files[file.Name] = file
+			classEntries = append(classEntries, file.Name)
+		}
+		jar.classes = make(map[string]*zip.File, len(files))
+		for className, entry := range releaseEntries(classEntries, globals.GetGlobalRef().MaxJavaVersion) {
+			jar.classes[className] = files[entry]
+		}
+	}
+}
+
+// Close closes the JARs the resolver has opened
+func (r *ClasspathResolver) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, jar := range r.jars {
+		jar.once.Do(func() {}) // so a JAR that's being indexed is finished first
+		if jar.reader != nil {
+			_ = jar.reader.Close()
+		}
+	}
+	r.jars = make(map[string]*classpathJar)
+}
+
+// the resolver for the application classpath, which is replaced if the classpath changes
+var appResolver *ClasspathResolver
+var appResolverMutex sync.Mutex
+
+// returns the resolver for the current application classpath
+func appClasspathResolver() *ClasspathResolver {
+	entries := appClassPath()
+
+	appResolverMutex.Lock()
+	defer appResolverMutex.Unlock()
+	if appResolver == nil || !sameEntries(appResolver.entries, entries) {
+		if appResolver != nil {
+			appResolver.Close()
+		}
+		appResolver = NewClasspathResolver(entries)
+	}
+	return appResolver
+}
+
+func sameEntries(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadClassFromClasspath searches each entry of the application classpath, in order,
+// for the named class, which can be in com.example.Main or com/example/Main format.
+// In a directory, the class com.example.Main is found at com/example/Main.class
+// relative to the directory; in a JAR, at the entry com/example/Main.class. The first
+// match is parsed and posted to the method area.
+// Returns the class's internal name and error, if any.
+func LoadClassFromClasspath(cl Classloader, name string) (string, error) {
+	internalName := strings.ReplaceAll(strings.TrimSuffix(name, ".class"), ".", "/")
+	rawBytes, location, err := appClasspathResolver().ClassBytes(internalName)
+	if err != nil {
+		_ = log.Log("Error reading class "+internalName+" from "+location+": "+err.Error(), log.SEVERE)
+		return "", err
+	}
+	if rawBytes == nil {
+		_ = log.Log("Class "+internalName+" not found on the classpath", log.FINE)
+		return "", fmt.Errorf("java.lang.ClassNotFoundException: %s", strings.ReplaceAll(internalName, "/", "."))
+	}
+	return ParseAndPostClass(cl, location, rawBytes)
+}
@@ -0,0 +1,345 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"io"
+	"jacobin/classbuilder"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// returns the bytes of a class with the given name, whose only method returns at once
+func classpathTestClass(t *testing.T, name string) []byte {
+	b, err := classbuilder.NewClassBuilder(name).AddMethod("run", "()V").AddOpcode(0xB1).Build() // return
+	if err != nil {
+		t.Fatalf("Unexpected error building %s: %s", name, err.Error())
+	}
+	return b
+}
+
+// writes the class file for the named class (in com/example/Main format) under dir
+func writeClasspathClass(t *testing.T, dir string, name string) string {
+	filename := filepath.Join(dir, filepath.FromSlash(name)+".class")
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatalf("Unable to create package directory: %s", err.Error())
+	}
+	if err := os.WriteFile(filename, classpathTestClass(t, name), 0644); err != nil {
+		t.Fatalf("Unable to write class file: %s", err.Error())
+	}
+	return filename
+}
+
+func TestLoadClassFromClasspathSearchesEntriesInOrder(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	defer func() { globals.GetGlobalRef().ClassPath = nil }()
+
+	first, second := t.TempDir(), t.TempDir()
+	writeClasspathClass(t, second, "com/example/Main")
+	writeClasspathClass(t, first, "com/example/Util")
+	utilInSecond := writeClasspathClass(t, second, "com/example/Util")
+	globals.GetGlobalRef().ClassPath = []string{filepath.Join(first, "missing"), first, second}
+
+	name, err := LoadClassFromClasspath(AppCL, "com.example.Main")
+	if err != nil || name != "com/example/Main" {
+		t.Fatalf("Expected to load com/example/Main from the second entry, got: %q, error: %v", name, err)
+	}
+	if k, present := LookupClass("com/example/Main"); !present || k.Loader != "app" {
+		t.Errorf("Expected com/example/Main in the method area, loaded by app, got: %+v", k)
+	}
+
+	// the first entry that has the class wins
+	if _, err = LoadClassFromClasspath(AppCL, "com/example/Util"); err != nil {
+		t.Fatalf("Unexpected error loading com/example/Util: %s", err.Error())
+	}
+	if cached, ok := classBytesCache["com/example/Util"]; !ok || cached.filename == utilInSecond {
+		t.Errorf("Expected com/example/Util to be loaded from the first entry, got: %+v", cached)
+	}
+
+	if _, err = LoadClassFromClasspath(AppCL, "com.example.Missing"); err == nil {
+		t.Error("Expected an error loading a class that's not on the classpath")
+	}
+}
+
+func TestLoadClassFromClasspathJar(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	defer func() { globals.GetGlobalRef().ClassPath = nil }()
+
+	jarName := filepath.Join(t.TempDir(), "app.jar")
+	jarFile, err := os.Create(jarName)
+	if err != nil {
+		t.Fatalf("Unable to create JAR: %s", err.Error())
+	}
+	w := zip.NewWriter(jarFile)
+	entry, _ := w.Create("com/example/InJar.class")
+	_, _ = entry.Write(classpathTestClass(t, "com/example/InJar"))
+	_ = w.Close()
+	_ = jarFile.Close()
+
+	globals.GetGlobalRef().ClassPath = []string{t.TempDir(), jarName}
+
+	name, err := LoadClassFromClasspath(AppCL, "com.example.InJar")
+	if err != nil || name != "com/example/InJar" {
+		t.Errorf("Expected to load com/example/InJar from the JAR, got: %q, error: %v", name, err)
+	}
+}
+
+// writes a JAR holding the given entries
+func writeClasspathJar(t *testing.T, filename string, entries map[string][]byte) {
+	jarFile, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Unable to create JAR: %s", err.Error())
+	}
+	w := zip.NewWriter(jarFile)
+	for name, contents := range entries {
+		entry, _ := w.Create(name)
+		_, _ = entry.Write(contents)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unable to write JAR: %s", err.Error())
+	}
+	_ = jarFile.Close()
+}
+
+func TestClasspathResolverAcrossJars(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	mainJar, libJar := filepath.Join(dir, "main.jar"), filepath.Join(dir, "lib.jar")
+	writeClasspathJar(t, mainJar, map[string][]byte{
+		"META-INF/MANIFEST.MF":   []byte("Manifest-Version: 1.0\n"),
+		"com/example/Main.class": classpathTestClass(t, "com/example/Main"),
+	})
+	writeClasspathJar(t, libJar, map[string][]byte{
+		"com/example/lib/":                nil,
+		"com/example/lib/Util.class":      classpathTestClass(t, "com/example/lib/Util"),
+		"com/example/lib/util.properties": []byte("key=value\n"),
+	})
+
+	resolver := NewClasspathResolver([]string{mainJar, libJar})
+	defer resolver.Close()
+
+	b, location, err := resolver.ClassBytes("com/example/lib/Util")
+	if err != nil || b == nil {
+		t.Fatalf("Expected to find com/example/lib/Util in the second JAR, error: %v", err)
+	}
+	if location != libJar+"+com/example/lib/Util.class" {
+		t.Errorf("Unexpected location of com/example/lib/Util: %s", location)
+	}
+	if _, location, _ = resolver.ClassBytes("com/example/Main"); location != mainJar+"+com/example/Main.class" {
+		t.Errorf("Unexpected location of com/example/Main: %s", location)
+	}
+
+	// only class files are indexed
+	if len(resolver.jars[libJar].classes) != 1 || len(resolver.jars[mainJar].classes) != 1 {
+		t.Errorf("Expected one class in each JAR's index, got: %d and %d",
+			len(resolver.jars[mainJar].classes), len(resolver.jars[libJar].classes))
+	}
+	if b, _, _ = resolver.ClassBytes("com/example/lib/util.properties"); b != nil {
+		t.Error("Expected a resource that's not a class to be skipped")
+	}
+}
+
+// resources are found in the same order as classes, in directories and JARs alike
+func TestClasspathResolverLoadResourceByName(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	classDir, jar := filepath.Join(dir, "classes"), filepath.Join(dir, "lib.jar")
+	if err := os.MkdirAll(filepath.Join(classDir, "com", "example"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(classDir, "com", "example", "app.properties"), []byte("from=dir"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeClasspathJar(t, jar, map[string][]byte{
+		"com/example/":               nil,
+		"com/example/app.properties": []byte("from=jar"),
+		"com/example/lib.properties": []byte("lib"),
+		"com/example/Util.class":     classpathTestClass(t, "com/example/Util"),
+	})
+
+	resolver := NewClasspathResolver([]string{classDir, jar})
+	defer resolver.Close()
+
+	if b, err := resolver.LoadResourceByName("com/example/app.properties"); err != nil || string(b) != "from=dir" {
+		t.Errorf("Expected app.properties from the directory, got: %q, error: %v", b, err)
+	}
+	if b, err := resolver.LoadResourceByName("com/example/lib.properties"); err != nil || string(b) != "lib" {
+		t.Errorf("Expected lib.properties from the JAR, got: %q, error: %v", b, err)
+	}
+	if b, err := resolver.LoadResourceByName("com/example/Util.class"); err != nil || b == nil {
+		t.Errorf("Expected a class file to be loadable as a resource, error: %v", err)
+	}
+	for _, name := range []string{"com/example/missing.properties", "com/example/", "com/example"} {
+		if b, err := resolver.LoadResourceByName(name); err != nil || b != nil {
+			t.Errorf("Expected no resource %s and no error, got: %q, error: %v", name, b, err)
+		}
+	}
+}
+
+// a resource name can't lead out of a classpath directory
+func TestClasspathResolverLoadResourceByNameTraversal(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	classDir := filepath.Join(dir, "classes")
+	if err := os.MkdirAll(filepath.Join(classDir, "com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(classDir, "app.properties"), []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := NewClasspathResolver([]string{classDir})
+	defer resolver.Close()
+
+	for _, name := range []string{"../secret.txt", "com/../../secret.txt", "/../secret.txt", ".."} {
+		if b, err := resolver.LoadResourceByName(name); err != nil || b != nil {
+			t.Errorf("Expected no resource for %s, got: %q, error: %v", name, b, err)
+		}
+	}
+	if b, err := resolver.LoadResourceByName("com/../app.properties"); err != nil || string(b) != "app" {
+		t.Errorf("Expected a name that stays in the directory to be found, got: %q, error: %v", b, err)
+	}
+}
+
+// a multi-release JAR on the classpath supplies each class's entry for Jacobin's version
+func TestClasspathResolverMultiReleaseJar(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	jarName, err := getJarFileName(MULTI_RELEASE_JAR_NAME)
+	if err != nil {
+		t.Fatal("Unable to get jar file", err)
+	}
+	resolver := NewClasspathResolver([]string{jarName})
+	defer resolver.Close()
+
+	expected := map[string]string{
+		"mr/Main":   "META-INF/versions/17/mr/Main.class",
+		"mr/Helper": "mr/Helper.class",
+	}
+	for name, entry := range expected {
+		b, location, err := resolver.ClassBytes(name)
+		if err != nil || b == nil {
+			t.Errorf("Expected to find %s, error: %v", name, err)
+		} else if location != jarName+"+"+entry {
+			t.Errorf("Expected %s to be found at %s, got: %s", name, entry, location)
+		}
+	}
+	if b, _, _ := resolver.ClassBytes("mr/Newer"); b != nil {
+		t.Error("Expected mr/Newer, which has only a Java 21 entry, not to be found")
+	}
+}
+
+func TestClasspathResolverWarnsOfMissingJar(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.jar")
+	writeClasspathClass(t, dir, "com/example/Main")
+	resolver := NewClasspathResolver([]string{missing, dir})
+	defer resolver.Close()
+
+	b, _, err := resolver.ClassBytes("com/example/Main")
+	_, _, _ = resolver.ClassBytes("com/example/Other") // the warning is given only once
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err != nil || b == nil {
+		t.Errorf("Expected to find com/example/Main after the missing JAR, error: %v", err)
+	}
+	msg := string(out)
+	if !strings.Contains(msg, "not found") || strings.Count(msg, missing) != 1 {
+		t.Errorf("Expected one warning naming the missing JAR, got: %s", msg)
+	}
+}
+
+func TestGetMainClassFromJarWithClassPath(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.CLASS)
+	_ = Init()
+	defer func() { globals.GetGlobalRef().StartingJar, globals.GetGlobalRef().ClassPath = "", nil }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatalf("Unable to create lib directory: %s", err.Error())
+	}
+	libJar := filepath.Join(dir, "lib", "dependency.jar")
+	writeClasspathJar(t, libJar, map[string][]byte{
+		"com/example/lib/Util.class": classpathTestClass(t, "com/example/lib/Util"),
+	})
+	mainJar := filepath.Join(dir, "main.jar")
+	writeClasspathJar(t, mainJar, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\n" +
+			"Main-Class: com.example.Main\n" +
+			"Class-Path: lib/missing.jar lib/dependency.jar\n"),
+		"com/example/Main.class": classpathTestClass(t, "com/example/Main"),
+	})
+
+	mainClass, classPath, err := GetMainClassFromJar(AppCL, mainJar)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Fatalf("Unexpected error reading the manifest: %s", err.Error())
+	}
+	if mainClass != "com.example.Main" {
+		t.Errorf("Expected main class com.example.Main, got: %s", mainClass)
+	}
+	if !reflect.DeepEqual(classPath, []string{libJar}) {
+		t.Errorf("Expected the manifest's Class-Path to be [%s], got: %v", libJar, classPath)
+	}
+
+	globals.GetGlobalRef().StartingJar = mainJar
+	globals.GetGlobalRef().ClassPath = classPath
+	expected := []string{mainJar, libJar}
+	if classPath := appClassPath(); !reflect.DeepEqual(classPath, expected) {
+		t.Errorf("Expected the classpath to be %v, got: %v", expected, classPath)
+	}
+	if !strings.Contains(string(out), "not found: "+filepath.Join(dir, "lib", "missing.jar")) {
+		t.Errorf("Expected the missing JAR to be logged, got: %s", string(out))
+	}
+
+	name, err := LoadClassFromClasspath(AppCL, "com.example.lib.Util")
+	if err != nil || name != "com/example/lib/Util" {
+		t.Errorf("Expected to load com/example/lib/Util from the manifest's Class-Path, got: %q, error: %v",
+			name, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"jacobin/log"
 	"math"
 	"os"
+	"strconv"
 )
 
 // this file contains the parser for the constant pool and the verifier.
@@ -47,6 +48,28 @@ type cpEntry struct {
 	slot      int
 }
 
+// the number of bytes following the tag byte in each type of CP entry. For UTF8 entries,
+// this is just the two bytes holding the length of the string that follows.
+var cpEntrySizes = map[int]int{
+	UTF8:          2,
+	IntConst:      4,
+	FloatConst:    4,
+	LongConst:     8,
+	DoubleConst:   8,
+	ClassRef:      2,
+	StringConst:   2,
+	FieldRef:      4,
+	MethodRef:     4,
+	Interface:     4,
+	NameAndType:   4,
+	MethodHandle:  3,
+	MethodType:    2,
+	Dynamic:       4,
+	InvokeDynamic: 4,
+	Module:        2,
+	Package:       2,
+}
+
 // parse the CP entries in the class file and put references to their data in klass.cpIndex,
 // where appropriate. (Some entries, such as invokeDynamic, Module, etc. require other actions
 // performed here. Returns location through last parsed byte and any error.
@@ -72,12 +95,34 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 	var i int
 	for i = 1; i <= klass.cpCount-1; { // i starts at 1 due to the dummy entry at CP[0]
 		pos += 1
+		if pos >= len(rawBytes) {
+			return pos, cfe("Class file ends in the middle of the constant pool at CP entry #" +
+				strconv.Itoa(i))
+		}
 		entryType := int(rawBytes[pos])
+
+		// make sure the whole entry lies within the class file before parsing it.
+		// Every CP is followed by at least the access flags, so an entry can never
+		// end on the last byte of the file.
+		entrySize, validType := cpEntrySizes[entryType]
+		if !validType {
+			return pos, cfe("Invalid type of constant pool entry at CP entry #" + strconv.Itoa(i) +
+				": " + strconv.Itoa(entryType))
+		}
+		if pos+entrySize >= len(rawBytes) {
+			return pos, cfe("Class file ends in the middle of the constant pool at CP entry #" +
+				strconv.Itoa(i))
+		}
+
 		switch entryType {
 		case UTF8:
 			var content string
 			length, _ := intFrom2Bytes(rawBytes, pos+1)
 			pos += 2
+			if pos+length >= len(rawBytes) {
+				return pos, cfe("UTF8 string at CP entry #" + strconv.Itoa(i) +
+					" extends beyond the end of the class file")
+			}
 			if length == 0 {
 				content = ""
 			} else {
@@ -106,6 +151,10 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			klass.cpIndex[i] = cpEntry{FloatConst, len(klass.floats) - 1}
 			i++
 		case LongConst:
+			if i+1 >= klass.cpCount { // longs need two slots
+				return pos, cfe("Long constant at CP entry #" + strconv.Itoa(i) +
+					" overflows the constant pool")
+			}
 			highBytes, _ := intFrom4Bytes(rawBytes, pos+1)
 			lowBytes, _ := intFrom4Bytes(rawBytes, pos+5)
 			pos += 8
@@ -117,6 +166,10 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			klass.cpIndex[i] = cpEntry{Dummy, 0}
 			i++
 		case DoubleConst:
+			if i+1 >= klass.cpCount { // doubles need two slots
+				return pos, cfe("Double constant at CP entry #" + strconv.Itoa(i) +
+					" overflows the constant pool")
+			}
 			bytes := make([]byte, 8)
 			for j := 0; j < 8; j++ {
 				bytes[j] = rawBytes[pos+1+j]
@@ -191,6 +244,9 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			pos += 2
 			i += 1
 		case Dynamic:
+			if klass.javaVersion < 55 {
+				return pos, cfe("Java dynamic constant entry requires Java 11 or later version")
+			}
 			bootstrap, _ := intFrom2Bytes(rawBytes, pos+1)
 			nAndT, _ := intFrom2Bytes(rawBytes, pos+3)
 			dyn := dynamic{
@@ -217,16 +273,7 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 				return pos, cfe("Java module record requires Java 9 or later version")
 			}
 			nameIndex, _ := intFrom2Bytes(rawBytes, pos+1)
-			moduleName, err := fetchUTF8string(klass, nameIndex)
-			if err != nil {
-				break // error message will already have been shown
-			}
-			if klass.moduleName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two module names: " + klass.moduleName +
-					" and " + moduleName)
-			}
-			klass.moduleName = moduleName
-			klass.cpIndex[i] = cpEntry{Module, nameIndex}
+			klass.cpIndex[i] = cpEntry{Module, nameIndex} // the name is fetched after the CP is parsed
 			pos += 2
 			i += 1
 		case Package:
@@ -234,24 +281,16 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 				return pos, cfe("Java package entry requires Java 9 or later version")
 			}
 			nameIndex, _ := intFrom2Bytes(rawBytes, pos+1)
-			packageName, err := fetchUTF8string(klass, nameIndex)
-			if err != nil {
-				break // error message will already have been shown
-			}
-			if klass.packageName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two package names: " + klass.packageName +
-					" and " + packageName)
-			}
-			klass.packageName = packageName
-			klass.cpIndex[i] = cpEntry{Package, nameIndex}
+			klass.cpIndex[i] = cpEntry{Package, nameIndex} // the name is fetched after the CP is parsed
 			pos += 2
 			i += 1
-
-		default:
-			klass.cpCount = i // just to get it over with for the moment
 		}
 	}
 
+	if err := fetchModuleAndPackageNames(klass); err != nil {
+		return pos, err
+	}
+
 	if log.Level == log.FINEST {
 		printCP(klass)
 
@@ -260,6 +299,30 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 	return pos, nil
 }
 
+// fetches the names of the Module and Package entries in the CP, which, unlike the
+// names of classes, are often in UTF8 entries that come later in the CP. Only a
+// module-info class has these entries, and it has one for each module it refers to
+// and for each of its packages. klass.moduleName is set to the first module (which
+// the Module attribute, if there is one, replaces with the module-info's own module)
+// and klass.packageName to the first package.
+func fetchModuleAndPackageNames(klass *ParsedClass) error {
+	for _, entry := range klass.cpIndex {
+		if entry.entryType != Module && entry.entryType != Package {
+			continue
+		}
+		name, err := fetchUTF8string(klass, entry.slot)
+		if err != nil {
+			return err // error message will already have been shown
+		}
+		if entry.entryType == Module && klass.moduleName == "" {
+			klass.moduleName = name
+		} else if entry.entryType == Package && klass.packageName == "" {
+			klass.packageName = name
+		}
+	}
+	return nil
+}
+
 // prints the entries in the CP. Accepts the number of entries for the nonce.
 // func printCP(entries int, klass *ParsedClass) {
 func printCP(klass *ParsedClass) {
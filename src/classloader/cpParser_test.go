@@ -32,6 +32,7 @@ import (
 // 12- NameAndTypeEntry				TestCPvalidNameAndTypeEntry
 // 15- MethodHandle  	 			TestCPvalidMethodHandle
 // 16- MethodType 		 			TestCPvalidMethodType
+// 17- Dynamic						TestCPvalidDynamic
 // 18- InvokeDynamic 	 			TestCPvalidInvokeDynamic
 // 19- ModuleName					see TestPrintOfCP2
 //
@@ -598,6 +599,65 @@ func TestCPvalidMethodType(t *testing.T) {
 	}
 }
 
+func TestCPvalidDynamic(t *testing.T) {
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	bytesToTest := []byte{
+		0xCA, 0xFE, 0xBA, 0xBE, 0x00,
+		0x00, 0xFF, 0xF0, 0x00, 0x00,
+		0x0C, // Name and Type entry
+		0x00, 0x14,
+		0x01, 0x01,
+		0x11,       // Dynamic (17)
+		0x00, 0x02, // Bootstrap index
+		0x00, 0x01, // name and type entry
+	}
+
+	pc := ParsedClass{}
+	pc.cpCount = 3
+	pc.javaVersion = 55 // Java 11
+	loc, err := parseConstantPool(bytesToTest, &pc)
+
+	if err != nil {
+		t.Error("Parsing valid CP Dynamic (17) generated an unexpected error")
+	}
+
+	if loc != 19 {
+		t.Error("Was expecting a new position of 19, but got: " + strconv.Itoa(loc))
+	}
+
+	if len(pc.dynamics) != 1 {
+		t.Fatal("Was expecting the dynamics array to have 1 entry, but it has: " + strconv.Itoa(len(pc.dynamics)))
+	}
+
+	dyn := pc.dynamics[0]
+	if dyn.bootstrapIndex != 2 || dyn.nameAndType != 1 {
+		t.Errorf("Was expecting a dynamic bootstrap index of 2 and nameAndType index of 1. Got: %d, %d",
+			dyn.bootstrapIndex, dyn.nameAndType)
+	}
+
+	if pc.cpIndex[2] != (cpEntry{Dynamic, 0}) {
+		t.Errorf("Was expecting CP entry 2 to be the first Dynamic entry, got: %v", pc.cpIndex[2])
+	}
+
+	// Dynamic entries were introduced in Java 11
+	pc = ParsedClass{}
+	pc.cpCount = 3
+	pc.javaVersion = 54
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err = parseConstantPool(bytesToTest, &pc)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	if err == nil {
+		t.Error("Expected an error for a Dynamic entry in a Java 10 class, but got none")
+	}
+}
+
 func TestCPvalidInvokeDynamic(t *testing.T) {
 
 	globals.InitGlobals("test")
@@ -0,0 +1,200 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Classes are loaded by parent delegation, as in the JDK: a classloader asked for a
+// class first asks its parent (named in its Parent field), which asks its own parent,
+// and only if none of them can find the class does the classloader search its own
+// locations. The chain is app -> extension -> bootstrap, where:
+//
+//	bootstrap searches the classes directory in JACOBIN_HOME (and has the classes
+//	          from java.base.jmod, which are loaded at start-up)
+//	extension has no locations of its own at present, so it only delegates
+//	app       searches the modules on the module path, then the application classpath
+//
+// Delegation means that a user class can't shadow a core class: a java/lang/String on
+// the classpath is never loaded, because the bootstrap classloader defines the real one
+// first. Classes in the core packages (see isCorePackage) are loaded only by the
+// bootstrap classloader, even if it can't find them.
+
+// ClassNotFoundError reports a class that no classloader in the delegation chain
+// could find. Locations lists every location searched, in the order searched.
+type ClassNotFoundError struct {
+	Class     string
+	Locations []string
+}
+
+func (e *ClassNotFoundError) Error() string {
+	return "java.lang.ClassNotFoundException: " + e.describe()
+}
+
+// returns the class's name, in com.example.Main format, and the locations searched
+func (e *ClassNotFoundError) describe() string {
+	name := strings.ReplaceAll(e.Class, "/", ".")
+	if len(e.Locations) == 0 {
+		return name + " (no locations searched)"
+	}
+	return name + " (searched: " + strings.Join(e.Locations, ", ") + ")"
+}
+
+// LoadClass loads the named class, which can be in com.example.Main or com/example/Main
+// format, through the given classloader. The class is loaded by the first classloader
+// in the parent chain, starting from the bootstrap classloader, that finds it, and the
+// name of that classloader is recorded in the class's Klass.Loader. A class that's
+// already in the method area isn't loaded again. If no classloader finds the class,
+// LoadClass returns a *ClassNotFoundError.
+func LoadClass(cl *Classloader, name string) error {
+	name = strings.ReplaceAll(strings.TrimSuffix(name, ".class"), ".", "/")
+	if k, ok := LookupClassIn(cl, name); ok {
+		if !isLoaded(name) {
+			_ = insert(name, k) // in place of the placeholder for this load, if there is one
+		}
+		return nil
+	}
+
+	var searched []string
+	for _, loader := range delegationChain(cl) {
+		found, locations, err := loader.findAndDefine(name)
+		searched = append(searched, locations...)
+		if err != nil || found {
+			return err
+		}
+	}
+	notFound := &ClassNotFoundError{Class: name, Locations: searched}
+	if isCorePackage(name) { // a missing core class means Jacobin's installation is incomplete
+		_ = log.Log("Error: could not find or load class "+notFound.describe(), log.SEVERE)
+	} else {
+		_ = log.Log("Class "+name+" not found by the "+cl.Name+" classloader", log.FINE)
+	}
+	return notFound
+}
+
+// LookupClassIn returns the named class (in java/lang/Object format) as it's seen by
+// the given classloader, which is the class defined by the first classloader in its
+// parent chain, starting from the bootstrap classloader, that defined a class of that
+// name. Unlike LookupClass(), it finds only classes that have been loaded, so never a
+// class that's being loaded or whose load failed.
+func LookupClassIn(cl *Classloader, name string) (Klass, bool) {
+	classloadersMutex.RLock()
+	defer classloadersMutex.RUnlock()
+	return lookupClassInLocked(cl, name)
+}
+
+// does the work of LookupClassIn(), for callers that hold classloadersMutex
+func lookupClassInLocked(cl *Classloader, name string) (Klass, bool) {
+	for _, loader := range delegationChain(cl) {
+		if k, ok := loader.Classes[name]; ok {
+			return k, true
+		}
+	}
+	return Klass{}, false
+}
+
+// returns the classloader and its ancestors, starting with the bootstrap classloader,
+// which is the order in which they're asked for a class
+func delegationChain(cl *Classloader) []*Classloader {
+	var chain []*Classloader
+	for loader := cl; loader != nil; loader = parentOf(loader) {
+		chain = append([]*Classloader{loader}, chain...)
+	}
+	return chain
+}
+
+// returns the parent of the classloader, or nil for the bootstrap classloader
+func parentOf(cl *Classloader) *Classloader {
+	switch cl.Parent {
+	case BootstrapCL.Name:
+		return &BootstrapCL
+	case ExtensionCL.Name:
+		return &ExtensionCL
+	case AppCL.Name:
+		return &AppCL
+	default:
+		return nil
+	}
+}
+
+// returns the number of classloaders above the named one in the parent chain: 0 for
+// the bootstrap classloader, which takes precedence over all the others. Classes loaded
+// by an unknown classloader are treated as loaded by the application classloader.
+func loaderDepth(name string) int {
+	cl := classloaderNamed(name)
+	return len(delegationChain(&cl)) - 1
+}
+
+// the classes in these packages are loaded only by the bootstrap classloader
+func isCorePackage(name string) bool {
+	return strings.HasPrefix(name, "java/") || strings.HasPrefix(name, "jdk/") ||
+		strings.HasPrefix(name, "javax/") || strings.HasPrefix(name, "sun/")
+}
+
+// returns the file from which the bootstrap classloader loads the named class, in the
+// classes directory of JACOBIN_HOME
+func bootstrapClassFile(name string) string {
+	return filepath.Join(globals.JacobinHome(), "classes", filepath.FromSlash(name)+".class")
+}
+
+// reports whether the bootstrap classloader can find the named class, without loading it
+func bootstrapCanFind(name string) bool {
+	if globals.JacobinHome() == "" {
+		return false
+	}
+	_, err := os.Stat(bootstrapClassFile(name))
+	return err == nil
+}
+
+// searches the classloader's own locations for the named class and, if it's found,
+// parses and posts it. Returns whether the class was found and the locations searched.
+func (cl *Classloader) findAndDefine(name string) (bool, []string, error) {
+	switch {
+	case cl.Name == BootstrapCL.Name:
+		if globals.JacobinHome() == "" {
+			return false, nil, nil
+		}
+		dir := filepath.Join(globals.JacobinHome(), "classes")
+		filename := bootstrapClassFile(name)
+		rawBytes, err := os.ReadFile(filename)
+		if err != nil {
+			return false, []string{dir}, nil
+		}
+		_, err = ParseAndPostClass(*cl, filename, rawBytes)
+		return true, []string{dir}, err
+
+	case isCorePackage(name), cl.Name == ExtensionCL.Name:
+		return false, nil, nil
+
+	default:
+		var locations []string
+		if len(ModuleLoaders) > 0 {
+			locations = filepath.SplitList(globals.GetGlobalRef().ModulePath)
+			if rawBytes, location := loadClassFromModules(name); rawBytes != nil {
+				_, err := ParseAndPostClass(*cl, location, rawBytes)
+				return true, locations, err
+			}
+		}
+
+		locations = append(locations, appClassPath()...)
+		rawBytes, location, err := appClasspathResolver().ClassBytes(name)
+		if err != nil {
+			_ = log.Log("Error reading class "+name+" from "+location+": "+err.Error(), log.SEVERE)
+			return false, locations, err
+		}
+		if rawBytes == nil {
+			return false, locations, nil
+		}
+		_, err = ParseAndPostClass(*cl, location, rawBytes)
+		return true, locations, err
+	}
+}
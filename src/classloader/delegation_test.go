@@ -0,0 +1,168 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/classbuilder"
+	"jacobin/globals"
+	"jacobin/log"
+	"path/filepath"
+	"testing"
+)
+
+// sets up a JACOBIN_HOME and a classpath, each in its own temporary directory, and
+// returns their classes directories
+func setUpDelegation(t *testing.T) (string, string) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	global := globals.GetGlobalRef()
+	home, classpath := t.TempDir(), t.TempDir()
+	savedHome, savedLoaders := global.JacobinHome, ModuleLoaders
+	global.JacobinHome = home
+	global.ClassPath = []string{classpath}
+	ModuleLoaders = nil
+	t.Cleanup(func() {
+		global.JacobinHome = savedHome
+		global.ClassPath = nil
+		ModuleLoaders = savedLoaders
+	})
+	return filepath.Join(home, "classes"), classpath
+}
+
+func TestLoadClassDelegatesToBootstrap(t *testing.T) {
+	bootstrapDir, classpath := setUpDelegation(t)
+	writeClasspathClass(t, bootstrapDir, "java/lang/DelegatedCore")
+	writeClasspathClass(t, classpath, "java/lang/DelegatedCore")
+	writeClasspathClass(t, classpath, "com/example/Delegated")
+
+	if err := LoadClass(&AppCL, "java.lang.DelegatedCore"); err != nil {
+		t.Fatalf("Unexpected error loading java/lang/DelegatedCore: %s", err.Error())
+	}
+	if k, ok := LookupClass("java/lang/DelegatedCore"); !ok || k.Loader != "bootstrap" {
+		t.Errorf("Expected java/lang/DelegatedCore to be defined by the bootstrap classloader, got: %q",
+			k.Loader)
+	}
+	if _, ok := BootstrapCL.Classes["java/lang/DelegatedCore"]; !ok {
+		t.Error("Expected java/lang/DelegatedCore to be recorded in the bootstrap classloader")
+	}
+
+	if err := LoadClass(&AppCL, "com/example/Delegated"); err != nil {
+		t.Fatalf("Unexpected error loading com/example/Delegated: %s", err.Error())
+	}
+	if k, ok := LookupClass("com/example/Delegated"); !ok || k.Loader != "app" {
+		t.Errorf("Expected com/example/Delegated to be defined by the app classloader, got: %q", k.Loader)
+	}
+}
+
+// a class in a core package is never loaded from the classpath, even if the bootstrap
+// classloader doesn't have it
+func TestLoadClassDoesNotShadowCorePackages(t *testing.T) {
+	bootstrapDir, classpath := setUpDelegation(t)
+	writeClasspathClass(t, classpath, "java/lang/ShadowedCore")
+
+	err := LoadClass(&AppCL, "java/lang/ShadowedCore")
+	var notFound *ClassNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected a ClassNotFoundError, got: %v", err)
+	}
+	if _, ok := AppCL.Classes["java/lang/ShadowedCore"]; ok {
+		t.Error("Expected java/lang/ShadowedCore not to be loaded from the classpath")
+	}
+	if len(notFound.Locations) != 1 || notFound.Locations[0] != bootstrapDir {
+		t.Errorf("Expected only %s to be searched, got: %v", bootstrapDir, notFound.Locations)
+	}
+}
+
+func TestLoadClassNotFoundNamesLocationsSearched(t *testing.T) {
+	bootstrapDir, classpath := setUpDelegation(t)
+
+	err := LoadClass(&AppCL, "com/example/Nowhere")
+	var notFound *ClassNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected a ClassNotFoundError, got: %v", err)
+	}
+
+	expected := "java.lang.ClassNotFoundException: com.example.Nowhere (searched: " +
+		bootstrapDir + ", " + classpath + ")"
+	if err.Error() != expected {
+		t.Errorf("Expected error: %s\n got: %s", expected, err.Error())
+	}
+}
+
+// returns the bytes of a class with the given name and class-file version, so that
+// classes of the same name can be told apart
+func versionedClass(t *testing.T, name string, version uint16) []byte {
+	b, err := classbuilder.NewClassBuilder(name).Version(version).
+		AddMethod("run", "()V").AddOpcode(0xB1).Build() // return
+	if err != nil {
+		t.Fatalf("Unexpected error building %s: %s", name, err.Error())
+	}
+	return b
+}
+
+// classes of the same name defined by different classloaders are both kept, and the
+// name resolves, through each classloader, to the class defined highest in its chain
+func TestSameNamedClassesInDifferentClassloaders(t *testing.T) {
+	setUpDelegation(t)
+	const name = "com/example/Twice"
+
+	// the app class is defined first, so the extension class must replace it as the
+	// class the application classloader sees
+	if _, err := ParseAndPostClass(AppCL, "app/Twice.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error defining the app class: %s", err.Error())
+	}
+	if _, err := ParseAndPostClass(ExtensionCL, "ext/Twice.class", versionedClass(t, name, 52)); err != nil {
+		t.Fatalf("Unexpected error defining the extension class: %s", err.Error())
+	}
+
+	if k := AppCL.Classes[name]; k.Loader != "app" || k.Data.JavaVersion != 55 {
+		t.Errorf("Expected the app classloader to keep its own class, got: %s, version %d",
+			k.Loader, k.Data.JavaVersion)
+	}
+	if k := ExtensionCL.Classes[name]; k.Loader != "extension" || k.Data.JavaVersion != 52 {
+		t.Errorf("Expected the extension classloader to keep its own class, got: %s, version %d",
+			k.Loader, k.Data.JavaVersion)
+	}
+
+	if k, ok := LookupClassIn(&AppCL, name); !ok || k.Loader != "extension" {
+		t.Errorf("Expected the app classloader to resolve %s to the extension class, got: %q", name, k.Loader)
+	}
+	if k, ok := LookupClass(name); !ok || k.Loader != "extension" {
+		t.Errorf("Expected the method area to hold the extension class, got: %q", k.Loader)
+	}
+
+	// defining another app class of the same name doesn't displace the extension class
+	if _, err := ParseAndPostClass(AppCL, "app/Twice.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error redefining the app class: %s", err.Error())
+	}
+	if k, ok := LookupClass(name); !ok || k.Loader != "extension" {
+		t.Errorf("Expected the method area to still hold the extension class, got: %q", k.Loader)
+	}
+}
+
+// a class that only a child classloader has defined isn't visible to its parent
+func TestLookupClassInDoesNotSeeChildClasses(t *testing.T) {
+	setUpDelegation(t)
+	const name = "com/example/AppOnly"
+	if _, err := ParseAndPostClass(AppCL, "AppOnly.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error defining the app class: %s", err.Error())
+	}
+
+	if _, ok := LookupClassIn(&AppCL, name); !ok {
+		t.Errorf("Expected the app classloader to see %s", name)
+	}
+	if _, ok := LookupClassIn(&ExtensionCL, name); ok {
+		t.Errorf("Expected the extension classloader not to see %s", name)
+	}
+	var notFound *ClassNotFoundError
+	if err := LoadClass(&ExtensionCL, name); !errors.As(err, &notFound) {
+		t.Errorf("Expected the extension classloader not to find %s, got: %v", name, err)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package dump writes the data of a loaded class as text, in a format modeled on the
+// output of javap -verbose. It's meant for developers tracking down parsing and
+// classloading bugs.
+package dump
+
+import (
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"strings"
+)
+
+// the access flags of classes, methods, and fields, in the order javap shows them
+// (see the tables in https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html)
+type flag struct {
+	mask int
+	name string
+}
+
+var classFlags = []flag{
+	{0x0001, "ACC_PUBLIC"}, {0x0010, "ACC_FINAL"}, {0x0020, "ACC_SUPER"},
+	{0x0200, "ACC_INTERFACE"}, {0x0400, "ACC_ABSTRACT"}, {0x1000, "ACC_SYNTHETIC"},
+	{0x2000, "ACC_ANNOTATION"}, {0x4000, "ACC_ENUM"}, {0x8000, "ACC_MODULE"},
+}
+
+var methodFlags = []flag{
+	{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"},
+	{0x0008, "ACC_STATIC"}, {0x0010, "ACC_FINAL"}, {0x0020, "ACC_SYNCHRONIZED"},
+	{0x0040, "ACC_BRIDGE"}, {0x0080, "ACC_VARARGS"}, {0x0100, "ACC_NATIVE"},
+	{0x0400, "ACC_ABSTRACT"}, {0x0800, "ACC_STRICT"}, {0x1000, "ACC_SYNTHETIC"},
+}
+
+var fieldFlags = []flag{
+	{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"},
+	{0x0008, "ACC_STATIC"}, {0x0010, "ACC_FINAL"}, {0x0040, "ACC_VOLATILE"},
+	{0x0080, "ACC_TRANSIENT"}, {0x1000, "ACC_SYNTHETIC"}, {0x4000, "ACC_ENUM"},
+}
+
+// DumpClass writes the class's name, version, access flags, superclass, interfaces,
+// constant pool, fields, and methods to w, followed by the permitted subclasses of a
+// sealed class and the components of a record
+func DumpClass(w io.Writer, class *classloader.ClData) {
+	cp := &class.CP
+	access := classAccessFlags(class.Access)
+
+	fmt.Fprintf(w, "class %s\n", class.Name)
+	fmt.Fprintf(w, "  major version: %d\n", class.JavaVersion)
+	fmt.Fprintf(w, "  flags: %s\n", formatFlags(access, classFlags))
+	fmt.Fprintf(w, "  this_class: %s\n", class.Name)
+	fmt.Fprintf(w, "  super_class: %s\n", class.Superclass)
+	if class.Module != "" {
+		fmt.Fprintf(w, "  module: %s\n", class.Module)
+	}
+	if class.SourceFile != "" {
+		fmt.Fprintf(w, "  source file: %s\n", class.SourceFile)
+	}
+	fmt.Fprintf(w, "  interfaces: %d, fields: %d, methods: %d, attributes: %d\n",
+		len(class.Interfaces), len(class.Fields), len(class.Methods), len(class.Attributes))
+	for _, iface := range class.Interfaces {
+		fmt.Fprintf(w, "    implements %s\n", utf8(cp, iface))
+	}
+
+	fmt.Fprintln(w, "Constant pool:")
+	for i := 1; i < len(cp.CpIndex); i++ {
+		kind, value := cpEntry(class, i)
+		if kind == "" { // the unusable slot after a long or double
+			continue
+		}
+		fmt.Fprintf(w, "%6s = %-18s %s\n", fmt.Sprintf("#%d", i), kind, value)
+	}
+
+	fmt.Fprintln(w, "{")
+	for _, f := range class.Fields {
+		fmt.Fprintf(w, "  %s %s;\n", utf8(cp, f.Desc), utf8(cp, f.Name))
+		fmt.Fprintf(w, "    descriptor: %s\n", utf8(cp, f.Desc))
+		fmt.Fprintf(w, "    flags: %s\n", formatFlags(f.AccessFlags, fieldFlags))
+		fmt.Fprintln(w)
+	}
+	for _, m := range class.Methods {
+		fmt.Fprintf(w, "  %s%s;\n", utf8(cp, m.Name), utf8(cp, m.Desc))
+		fmt.Fprintf(w, "    descriptor: %s\n", utf8(cp, m.Desc))
+		fmt.Fprintf(w, "    flags: %s\n", formatFlags(m.AccessFlags, methodFlags))
+		if len(m.CodeAttr.Code) > 0 {
+			fmt.Fprintf(w, "    Code: stack=%d, locals=%d, length=%d\n",
+				m.CodeAttr.MaxStack, m.CodeAttr.MaxLocals, len(m.CodeAttr.Code))
+		}
+		if len(m.Exceptions) > 0 {
+			var names []string
+			for _, e := range m.Exceptions {
+				names = append(names, utf8(cp, e))
+			}
+			fmt.Fprintf(w, "    Exceptions:\n      throws %s\n", strings.Join(names, ", "))
+		}
+		if m.Deprecated {
+			fmt.Fprintln(w, "    Deprecated: true")
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "}")
+
+	if class.PermittedSubclasses != nil {
+		fmt.Fprintln(w, "PermittedSubclasses:")
+		for _, subclass := range class.PermittedSubclasses {
+			fmt.Fprintf(w, "  %s\n", subclass)
+		}
+	}
+	if class.RecordComponents != nil {
+		fmt.Fprintln(w, "Record:")
+		for _, rc := range class.RecordComponents {
+			fmt.Fprintf(w, "  %s %s;\n", utf8(cp, rc.Desc), utf8(cp, rc.Name))
+			fmt.Fprintf(w, "    descriptor: %s\n", utf8(cp, rc.Desc))
+			if rc.Signature != "" {
+				fmt.Fprintf(w, "    signature: %s\n", rc.Signature)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// the access flags of the class, reassembled from the booleans they were parsed into
+func classAccessFlags(a classloader.AccessFlags) int {
+	flags := 0
+	set := func(isSet bool, mask int) {
+		if isSet {
+			flags |= mask
+		}
+	}
+	set(a.ClassIsPublic, 0x0001)
+	set(a.ClassIsFinal, 0x0010)
+	set(a.ClassIsSuper, 0x0020)
+	set(a.ClassIsInterface, 0x0200)
+	set(a.ClassIsAbstract, 0x0400)
+	set(a.ClassIsSynthetic, 0x1000)
+	set(a.ClassIsAnnotation, 0x2000)
+	set(a.ClassIsEnum, 0x4000)
+	set(a.ClassIsModule, 0x8000)
+	return flags
+}
+
+// formats access flags as javap does: the flags in hex, followed by the names of the
+// flags that are set, as in (0x0009) ACC_PUBLIC, ACC_STATIC
+func formatFlags(flags int, table []flag) string {
+	s := fmt.Sprintf("(0x%04x)", flags)
+	var names []string
+	for _, f := range table {
+		if flags&f.mask != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) > 0 {
+		s += " " + strings.Join(names, ", ")
+	}
+	return s
+}
+
+// returns the string at the given index into the CP's UTF8 strings
+func utf8(cp *classloader.CPool, slot uint16) string {
+	if int(slot) >= len(cp.Utf8Refs) {
+		return fmt.Sprintf("<invalid UTF8 slot %d>", slot)
+	}
+	return cp.Utf8Refs[slot]
+}
+
+// returns the string in the UTF8 entry at the given CP index
+func utf8At(cp *classloader.CPool, index uint16) string {
+	return classloader.FetchUTF8stringFromCPEntryNumber(cp, index)
+}
+
+// returns the name of the class in the ClassRef entry at the given CP index
+func classAt(cp *classloader.CPool, index uint16) string {
+	if int(index) >= len(cp.CpIndex) || cp.CpIndex[index].Type != classloader.ClassRef {
+		return fmt.Sprintf("<invalid class #%d>", index)
+	}
+	return utf8At(cp, cp.ClassRefs[cp.CpIndex[index].Slot])
+}
+
+// returns the name:descriptor in the NameAndType entry at the given CP index
+func nameAndTypeAt(cp *classloader.CPool, index uint16) string {
+	if int(index) >= len(cp.CpIndex) || cp.CpIndex[index].Type != classloader.NameAndType {
+		return fmt.Sprintf("<invalid name and type #%d>", index)
+	}
+	nt := cp.NameAndTypes[cp.CpIndex[index].Slot]
+	return utf8At(cp, nt.NameIndex) + ":" + utf8At(cp, nt.DescIndex)
+}
+
+// cpEntry returns the kind of the CP entry at index i and a description of its value.
+// (String constants are converted to UTF8 entries when classes are loaded, so they
+// appear as Utf8.)
+func cpEntry(class *classloader.ClData, i int) (string, string) {
+	cp := &class.CP
+	e := cp.CpIndex[i]
+	switch e.Type {
+	case classloader.UTF8:
+		return "Utf8", cp.Utf8Refs[e.Slot]
+	case classloader.IntConst:
+		return "Integer", fmt.Sprintf("%d", cp.IntConsts[e.Slot])
+	case classloader.FloatConst:
+		return "Float", fmt.Sprintf("%gf", cp.Floats[e.Slot])
+	case classloader.LongConst:
+		return "Long", fmt.Sprintf("%dl", cp.LongConsts[e.Slot])
+	case classloader.DoubleConst:
+		return "Double", fmt.Sprintf("%gd", cp.Doubles[e.Slot])
+	case classloader.ClassRef:
+		return "Class", fmt.Sprintf("#%-13d // %s", cp.ClassRefs[e.Slot], classAt(cp, uint16(i)))
+	case classloader.FieldRef:
+		r := cp.FieldRefs[e.Slot]
+		return "Fieldref", memberRef(cp, r.ClassIndex, r.NameAndType)
+	case classloader.MethodRef:
+		r := cp.MethodRefs[e.Slot]
+		return "Methodref", memberRef(cp, r.ClassIndex, r.NameAndType)
+	case classloader.Interface:
+		r := cp.InterfaceRefs[e.Slot]
+		return "InterfaceMethodref", memberRef(cp, r.ClassIndex, r.NameAndType)
+	case classloader.NameAndType:
+		nt := cp.NameAndTypes[e.Slot]
+		return "NameAndType", fmt.Sprintf("%-14s // %s",
+			fmt.Sprintf("#%d:#%d", nt.NameIndex, nt.DescIndex), nameAndTypeAt(cp, uint16(i)))
+	case classloader.MethodHandle:
+		mh := cp.MethodHandles[e.Slot]
+		return "MethodHandle", fmt.Sprintf("%d:#%d", mh.RefKind, mh.RefIndex)
+	case classloader.MethodType:
+		return "MethodType", fmt.Sprintf("#%-13d // %s", cp.MethodTypes[e.Slot],
+			utf8At(cp, cp.MethodTypes[e.Slot]))
+	case classloader.Dynamic:
+		d := cp.Dynamics[e.Slot]
+		return "Dynamic", fmt.Sprintf("%-14s // %s",
+			fmt.Sprintf("#%d:#%d", d.BootstrapIndex, d.NameAndType), nameAndTypeAt(cp, d.NameAndType))
+	case classloader.InvokeDynamic:
+		d := cp.InvokeDynamics[e.Slot]
+		return "InvokeDynamic", fmt.Sprintf("%-14s // %s",
+			fmt.Sprintf("#%d:#%d", d.BootstrapIndex, d.NameAndType), nameAndTypeAt(cp, d.NameAndType))
+	case classloader.Module:
+		return "Module", class.Module
+	case classloader.Package:
+		return "Package", class.Pkg
+	}
+	return "", ""
+}
+
+// describes a field, method, or interface method reference
+func memberRef(cp *classloader.CPool, classIndex, nameAndType uint16) string {
+	return fmt.Sprintf("%-14s // %s.%s", fmt.Sprintf("#%d.#%d", classIndex, nameAndType),
+		classAt(cp, classIndex), nameAndTypeAt(cp, nameAndType))
+}
@@ -0,0 +1,86 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package dump
+
+import (
+	"bytes"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDumpHello2(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	classBytes, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "Hello2.class"))
+	if err != nil {
+		t.Fatalf("Unable to read Hello2.class: %s", err.Error())
+	}
+	name, err := classloader.ParseAndPostClass(classloader.AppCL, "Hello2.class", classBytes)
+	if err != nil {
+		t.Fatalf("Unable to load Hello2: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	k, _ := classloader.LookupClass(name)
+	DumpClass(&out, k.Data)
+	dump := out.String()
+
+	for _, expected := range []string{"class Hello2", "super_class: java/lang/Object",
+		"flags: (0x0020) ACC_SUPER", "<init>()V", "main([Ljava/lang/String;)V",
+		"addTwo(II)I", "flags: (0x0009) ACC_PUBLIC, ACC_STATIC", "Methodref", "Utf8"} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Expected the dump to contain %q, got:\n%s", expected, dump)
+		}
+	}
+}
+
+// the components of the record Pair(int first, List<String> rest)
+func TestDumpRecordComponents(t *testing.T) {
+	class := &classloader.ClData{Name: "Pair", Superclass: "java/lang/Record"}
+	class.CP.CpIndex = []classloader.CpEntry{{}, {Type: classloader.UTF8, Slot: 0},
+		{Type: classloader.UTF8, Slot: 1}, {Type: classloader.UTF8, Slot: 2}, {Type: classloader.UTF8, Slot: 3}}
+	class.CP.Utf8Refs = []string{"first", "I", "rest", "Ljava/util/List;"}
+	class.RecordComponents = []classloader.RecordComponent{
+		{Name: 0, Desc: 1},
+		{Name: 2, Desc: 3, Signature: "Ljava/util/List<Ljava/lang/String;>;"},
+	}
+
+	var out bytes.Buffer
+	DumpClass(&out, class)
+	dump := out.String()
+
+	expected := "Record:\n  I first;\n    descriptor: I\n\n  Ljava/util/List; rest;\n" +
+		"    descriptor: Ljava/util/List;\n    signature: Ljava/util/List<Ljava/lang/String;>;\n"
+	if !strings.Contains(dump, expected) {
+		t.Errorf("Expected the dump to contain:\n%s\ngot:\n%s", expected, dump)
+	}
+
+	class.RecordComponents = nil
+	out.Reset()
+	DumpClass(&out, class)
+	if strings.Contains(out.String(), "Record:") {
+		t.Errorf("Expected no record components for a class that's not a record, got:\n%s", out.String())
+	}
+}
+
+func TestDumpPermittedSubclasses(t *testing.T) {
+	class := &classloader.ClData{Name: "Shape", Superclass: "java/lang/Object",
+		PermittedSubclasses: []string{"Circle", "Square"}}
+
+	var out bytes.Buffer
+	DumpClass(&out, class)
+	if expected := "PermittedSubclasses:\n  Circle\n  Square\n"; !strings.Contains(out.String(), expected) {
+		t.Errorf("Expected the dump to contain:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"context"
+	"io/fs"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExplodedModuleLoader loads classes from a directory of exploded modules, as can be
+// specified on the module path. Each subdirectory that contains a module-info.class is
+// a module; its class files are in the subdirectory's package directories.
+type ExplodedModuleLoader struct {
+	Dir     string
+	Modules map[string]string // module name -> the module's directory
+	classes map[string]string // class name (in java/lang/Object format) -> class file
+}
+
+// InitExplodedModuleLoader finds the modules in dir and indexes their classes.
+// Subdirectories that do not contain a module-info.class are skipped.
+func InitExplodedModuleLoader(dir string) (*ExplodedModuleLoader, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := &ExplodedModuleLoader{
+		Dir:     dir,
+		Modules: make(map[string]string),
+		classes: make(map[string]string),
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		moduleDir := filepath.Join(dir, entry.Name())
+		moduleInfo, err := os.ReadFile(filepath.Join(moduleDir, "module-info.class"))
+		if err != nil {
+			continue // not a module
+		}
+
+		moduleName := entry.Name()
+		if parsed, err := parse(moduleInfo); err == nil && parsed.moduleName != "" {
+			moduleName = parsed.moduleName
+		} else {
+			_ = log.Log("Unable to read module name from "+moduleDir+
+				string(os.PathSeparator)+"module-info.class. Using "+moduleName, log.WARNING)
+		}
+		loader.Modules[moduleName] = moduleDir
+
+		err = filepath.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".class") ||
+				filepath.Base(path) == "module-info.class" {
+				return nil
+			}
+			rel, _ := filepath.Rel(moduleDir, path)
+			className := strings.TrimSuffix(filepath.ToSlash(rel), ".class")
+			if _, dup := loader.classes[className]; !dup {
+				loader.classes[className] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return loader, nil
+}
+
+// LoadClassByName returns the bytes of the named class, or nil (and no error) if the
+// class is not in any of the modules. The name can be in java/lang/Object or
+// java.lang.Object format.
+func (l *ExplodedModuleLoader) LoadClassByName(ctx context.Context, name string) ([]byte, error) {
+	path, ok := l.classes[strings.ReplaceAll(name, ".", "/")]
+	if !ok {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// returns the module path directory and the module that hold the named class
+func (l *ExplodedModuleLoader) moduleOf(name string) (string, string) {
+	path, ok := l.classes[strings.ReplaceAll(name, ".", "/")]
+	if !ok {
+		return l.Dir, ""
+	}
+	for module, dir := range l.Modules {
+		if strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+			return l.Dir, module
+		}
+	}
+	return l.Dir, ""
+}
+
+// LoadResourceByName returns the bytes of the named resource (e.g.,
+// com/example/config.properties), or nil (and no error) if it's not in any of the
+// modules. As with classes, the modules are searched in the order of their directories.
+func (l *ExplodedModuleLoader) LoadResourceByName(name string) ([]byte, error) {
+	dirs := make([]string, 0, len(l.Modules))
+	for _, dir := range l.Modules {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		path, ok := resourceFile(dir, name)
+		if !ok {
+			return nil, nil // the name leads out of the module's directory
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
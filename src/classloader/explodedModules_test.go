@@ -0,0 +1,104 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"context"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// returns the bytes of a minimal module-info.class for the named module
+func makeModuleInfo(moduleName string) []byte {
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x3D, // Java 17
+		0x00, 0x05, // CP count
+		0x07, 0x00, 0x02, // #1 Class -> #2
+		0x01, 0x00, 0x0B} // #2 UTF8, length 11
+	b = append(b, "module-info"...)
+	b = append(b, 0x01, 0x00, byte(len(moduleName))) // #3 UTF8
+	b = append(b, moduleName...)
+	b = append(b, 0x13, 0x00, 0x03) // #4 Module -> #3
+	return append(b,
+		0x80, 0x00, // access flags: ACC_MODULE
+		0x00, 0x01, // this class
+		0x00, 0x00, // no superclass
+		0x00, 0x00, // interfaces
+		0x00, 0x00, // fields
+		0x00, 0x00, // methods
+		0x00, 0x00) // attributes
+}
+
+func TestExplodedModuleLoader(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	// modules/hellodir/{module-info.class (for module hello.mod), Hello.class, org/test/Util.class}
+	// modules/notAModule/Other.class
+	dir := t.TempDir()
+	moduleDir := filepath.Join(dir, "hellodir")
+	_ = os.MkdirAll(filepath.Join(moduleDir, "org", "test"), 0755)
+	_ = os.MkdirAll(filepath.Join(dir, "notAModule"), 0755)
+
+	helloBytes := getHello2Bytes(t)
+	_ = os.WriteFile(filepath.Join(moduleDir, "module-info.class"), makeModuleInfo("hello.mod"), 0644)
+	_ = os.WriteFile(filepath.Join(moduleDir, "Hello.class"), helloBytes, 0644)
+	_ = os.WriteFile(filepath.Join(moduleDir, "org", "test", "Util.class"), []byte{0xCA, 0xFE}, 0644)
+	_ = os.WriteFile(filepath.Join(dir, "notAModule", "Other.class"), []byte{0xCA, 0xFE}, 0644)
+
+	loader, err := InitExplodedModuleLoader(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing ExplodedModuleLoader: %s", err.Error())
+	}
+
+	if len(loader.Modules) != 1 || loader.Modules["hello.mod"] != moduleDir {
+		t.Errorf("Expected one module, hello.mod in %s, got: %v", moduleDir, loader.Modules)
+	}
+
+	b, err := loader.LoadClassByName(context.Background(), "Hello")
+	if err != nil || !bytes.Equal(b, helloBytes) {
+		t.Errorf("Expected LoadClassByName(Hello) to return Hello.class, got %d bytes, error: %v", len(b), err)
+	}
+
+	if b, _ = loader.LoadClassByName(context.Background(), "org.test.Util"); len(b) != 2 {
+		t.Errorf("Expected LoadClassByName(org.test.Util) to find the class, got %d bytes", len(b))
+	}
+
+	if b, err = loader.LoadClassByName(context.Background(), "Other"); b != nil || err != nil {
+		t.Errorf("Expected class outside a module not to be found, got %d bytes, error: %v", len(b), err)
+	}
+}
+
+// a resource name can't lead out of a module's directory
+func TestExplodedModuleLoaderResourceTraversal(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	moduleDir := filepath.Join(dir, "hellodir")
+	_ = os.MkdirAll(moduleDir, 0755)
+	_ = os.WriteFile(filepath.Join(moduleDir, "module-info.class"), makeModuleInfo("hello.mod"), 0644)
+	_ = os.WriteFile(filepath.Join(moduleDir, "app.properties"), []byte("app"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "notAModule.txt"), []byte("outside"), 0644)
+
+	loader, err := InitExplodedModuleLoader(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing ExplodedModuleLoader: %s", err.Error())
+	}
+
+	if b, err := loader.LoadResourceByName("app.properties"); err != nil || string(b) != "app" {
+		t.Errorf("Expected app.properties from the module, got: %q, error: %v", b, err)
+	}
+	for _, name := range []string{"../notAModule.txt", "x/../../notAModule.txt", "/../notAModule.txt"} {
+		if b, err := loader.LoadResourceByName(name); err != nil || b != nil {
+			t.Errorf("Expected no resource for %s, got: %q, error: %v", name, b, err)
+		}
+	}
+}
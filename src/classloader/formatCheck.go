@@ -36,6 +36,10 @@ func formatCheckClass(klass *ParsedClass) error {
 		return errors.New("") // whatever error occurs, the user will have been notified
 	}
 
+	if klass.classIsModule && formatCheckModule(klass) != nil {
+		return errors.New("") // whatever error occurs, the user will have been notified
+	}
+
 	return formatCheckStructure(klass)
 }
 
@@ -152,6 +156,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			}
 			fieldRef := klass.fieldRefs[whichFieldRef]
 			classIndex := fieldRef.classIndex
+			if classIndex < 1 || classIndex >= len(klass.cpIndex) {
+				return cfe("Field Ref at CP entry #" + strconv.Itoa(j) +
+					" has an invalid class index: " + strconv.Itoa(classIndex))
+			}
 			class := klass.cpIndex[classIndex]
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
@@ -160,6 +168,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 					strconv.Itoa(classIndex))
 			}
 
+			if fieldRef.nameAndTypeIndex < 1 || fieldRef.nameAndTypeIndex >= len(klass.cpIndex) {
+				return cfe("Field Ref at CP entry #" + strconv.Itoa(j) +
+					" has an invalid nameAndType index: " + strconv.Itoa(fieldRef.nameAndTypeIndex))
+			}
 			nameAndType := klass.cpIndex[fieldRef.nameAndTypeIndex]
 			if nameAndType.entryType != NameAndType ||
 				nameAndType.slot < 0 || nameAndType.slot >= len(klass.nameAndTypes) {
@@ -177,6 +189,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			methodRef := klass.methodRefs[whichMethodRef]
 
 			classIndex := methodRef.classIndex
+			if classIndex < 1 || classIndex >= len(klass.cpIndex) {
+				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
+					" holds an invalid class index: " + strconv.Itoa(classIndex))
+			}
 			class := klass.cpIndex[classIndex]
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
@@ -186,6 +202,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			}
 
 			nAndTIndex := methodRef.nameAndTypeIndex
+			if nAndTIndex < 1 || nAndTIndex >= len(klass.cpIndex) {
+				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
+					" holds an invalid NameAndType index: " + strconv.Itoa(nAndTIndex))
+			}
 			nAndT := klass.cpIndex[nAndTIndex]
 			if nAndT.entryType != NameAndType ||
 				nAndT.slot < 0 || nAndT.slot >= len(klass.nameAndTypes) {
@@ -202,6 +222,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 					") has a Name and Type entry does not have a name that is a valid UTF8 entry")
 			}
 
+			if name == "" {
+				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
+					" holds an NameAndType index to an entry with an empty method name")
+			}
 			nameBytes := []byte(name)
 			if nameBytes[0] == '<' && name != "<init>" {
 				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
@@ -216,6 +240,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			interfaceRef := klass.interfaceRefs[whichInterface]
 
 			classIndex := interfaceRef.classIndex
+			if classIndex < 1 || classIndex >= len(klass.cpIndex) {
+				return cfe("Interface Ref at CP entry #" + strconv.Itoa(j) +
+					" holds an invalid class index: " + strconv.Itoa(classIndex))
+			}
 			class := klass.cpIndex[classIndex]
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
@@ -254,6 +282,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			*/
 
 			nAndTIndex := interfaceRef.nameAndTypeIndex
+			if nAndTIndex < 1 || nAndTIndex >= len(klass.cpIndex) {
+				return cfe("Interface Ref at CP entry #" + strconv.Itoa(j) +
+					" holds an invalid NameAndType index: " + strconv.Itoa(nAndTIndex))
+			}
 			nAndT := klass.cpIndex[nAndTIndex]
 			if nAndT.entryType != NameAndType ||
 				nAndT.slot < 0 || nAndT.slot >= len(klass.nameAndTypes) {
@@ -308,6 +340,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 					" has an invalid reference kind: " + strconv.Itoa(refKind))
 			}
 			refIndex := mhe.referenceIndex
+			if refIndex < 1 || refIndex >= len(klass.cpIndex) {
+				return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
+					" has an invalid reference index: " + strconv.Itoa(refIndex))
+			}
 
 			switch refKind {
 			// if refKind is 1-4, the reference_index must point to a fieldRef
@@ -344,48 +380,53 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 				}
 			}
 
-			// get the class name pointed to by the MethodRef pointed to by the MethodHandle
+			// get the name of the method pointed to by the MethodRef or Interface entry
+			// that the MethodHandle points to
 			var methodName string
 			var err error
-			if klass.cpIndex[refIndex].entryType == MethodRef {
-				methodName, _, _, err = resolveCPmethodRef(refIndex, klass)
-				if err != nil {
-					return errors.New("") // the error messsage is already displayed
-				}
-			}
-
-			// if the reference_kind is 5-7 the name of the method pointed to
-			// by the nameAndType entry in the method handle cannot be <init> or <clinit>
-			if refKind >= 5 && refKind <= 7 && klass.cpIndex[refIndex].entryType == MethodRef {
+			switch klass.cpIndex[refIndex].entryType {
+			case MethodRef:
 				methRefIndex := klass.cpIndex[refIndex].slot
 				if methRefIndex < 0 || methRefIndex >= len(klass.methodRefs) {
 					return cfe("Reference index for MethodHandle at CP entry #" + strconv.Itoa(j) +
 						" points to an invalid MethodRef: " + strconv.Itoa(methRefIndex))
 				}
-
-				if methodName == "<init>" || methodName == "<clinit>" {
-					return cfe("Invalid class name for MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" : " + methodName)
+				_, methodName, _, err = resolveCPmethodRef(refIndex, klass)
+			case Interface:
+				interfaceIndex := klass.cpIndex[refIndex].slot
+				if interfaceIndex < 0 || interfaceIndex >= len(klass.interfaceRefs) {
+					return cfe("Reference index for MethodHandle at CP entry #" + strconv.Itoa(j) +
+						" points to an invalid Interface: " + strconv.Itoa(interfaceIndex))
 				}
+				methodName, _, err = resolveCPnameAndType(klass,
+					klass.interfaceRefs[interfaceIndex].nameAndTypeIndex)
 			}
-			// The following code is commented out b/c it was emitting errors when Jacobin moved from
-			// JDK 11 to JDK 17. The cause of these errors is unclear--whether something changed in
-			// JDK 17 w.r.t MethodHandles with refKind = 8. Issue #JACOBIN-183 is the reference for this.
-			// else if refKind == 8 {
-			//	if methodName != "<init>" {
-			//		return cfe("Class name for MethodHandle at CP entry #" + strconv.Itoa(j) +
-			//			" should be <init>, but is: " + methodName)
-			//	}
-			// }
-
-			_ = log.Log("ClassName in MethodRef of MethodHandle at CP entry #"+strconv.Itoa(j)+
-				" is:"+methodName, log.FINEST)
+			if err != nil {
+				return errors.New("") // the error messsage is already displayed
+			}
+
+			// if the reference_kind is 5-7 or 9, the method cannot be <init> or <clinit>;
+			// if it's 8 (REF_newInvokeSpecial), the method must be <init>
+			if refKind >= 5 && refKind != 8 && (methodName == "<init>" || methodName == "<clinit>") {
+				return cfe("Invalid method name for MethodHandle at CP entry #" + strconv.Itoa(j) +
+					" : " + methodName)
+			} else if refKind == 8 && methodName != "<init>" {
+				return cfe("Method name for MethodHandle at CP entry #" + strconv.Itoa(j) +
+					" should be <init>, but is: " + methodName)
+			}
+
+			_ = log.Log("Method name in MethodHandle at CP entry #"+strconv.Itoa(j)+
+				" is: "+methodName, log.FINEST)
 		case MethodType:
 			// Method types consist of an integer pointing to a CP entry that's a UTF8 description
 			// of the method type, which appears to require an initial opening parenthesis. See
 			// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.9
 			whichMethType := entry.slot
 			mte := klass.methodTypes[whichMethType]
+			if mte < 1 || mte >= len(klass.cpIndex) {
+				return cfe("MethodType at CP entry #" + strconv.Itoa(j) +
+					" has an invalid description index: " + strconv.Itoa(mte))
+			}
 			utf8 := klass.cpIndex[mte]
 			if utf8.entryType != UTF8 || utf8.slot < 0 || utf8.slot > len(klass.utf8Refs)-1 {
 				return cfe("MethodType at CP entry #" + strconv.Itoa(j) +
@@ -413,15 +454,19 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			}
 			dyn := klass.dynamics[whichDyn]
 
+			if klass.BootstrapMethods == nil {
+				return cfe("The dynamic entry at CP[" + strconv.Itoa(j) + "] requires a " +
+					"BootstrapMethods attribute, but class " + klass.className + " has none")
+			}
 			bootstrap := dyn.bootstrapIndex
-			if bootstrap >= klass.bootstrapCount {
+			if bootstrap >= klass.bootstrapCount || bootstrap >= len(klass.BootstrapMethods) {
 				return cfe("The boostrap index in dynamic at CP[" + strconv.Itoa(j) +
 					"] is invalid: " + strconv.Itoa(bootstrap))
 			}
 
 			// just trying to access it to make sure it's actually there and accessible.
-			bse := klass.bootstraps[bootstrap]
-			if !(bse.methodRef > 0) {
+			bse := klass.BootstrapMethods[bootstrap]
+			if !(bse.MethodRef > 0) {
 				return cfe("Invalid methodRef in bootstrap method[" + strconv.Itoa(bootstrap) + "]")
 			}
 
@@ -464,15 +509,19 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			}
 			invDyn := klass.invokeDynamics[whichInvDyn]
 
+			if klass.BootstrapMethods == nil {
+				return cfe("The InvokeDynamic entry at CP[" + strconv.Itoa(j) + "] requires a " +
+					"BootstrapMethods attribute, but class " + klass.className + " has none")
+			}
 			bootstrap := invDyn.bootstrapIndex
-			if bootstrap >= klass.bootstrapCount {
+			if bootstrap >= klass.bootstrapCount || bootstrap >= len(klass.BootstrapMethods) {
 				return cfe("The boostrap index in InvokeDynamic at CP[" + strconv.Itoa(j) +
 					"] is invalid: " + strconv.Itoa(bootstrap))
 			}
 
 			// just trying to access it to make sure it's actually there and accessible.
-			bse := klass.bootstraps[bootstrap]
-			if !(bse.methodRef > 0) {
+			bse := klass.BootstrapMethods[bootstrap]
+			if !(bse.MethodRef > 0) {
 				return cfe("Invalid methodRef in bootstrap method[" + strconv.Itoa(bootstrap) + "]")
 			}
 
@@ -504,8 +553,9 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// placed into klass.moduleName. So, here we verify this module name rather
 			// than the CP entry that got it. We also check access permissions, as required
 			// in: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.11
-			// Note: the test for minimum Java 9 version and the limit of at most one
-			// Module entry is enforced in the original CP parsing (see cpParser.go)
+			// Note: the test for minimum Java 9 version is enforced in the original CP
+			// parsing (see cpParser.go). A module-info class can have many Module entries,
+			// one for each module it refers to; the names of all of them were fetched then.
 			if !klass.classIsModule {
 				return cfe("Module CP entry must appear only in class with ACC_MODULE set.")
 			}
@@ -517,8 +567,9 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// placed into klass.packageName. So, here we verify this package name rather
 			// than the CP entry that got it. We also check access permissions, as required
 			// in: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.12
-			// Note: the test for minimum Java 9 version and the limit of at most one
-			// Package entry is enforced in the original CP parsing (see cpParser.go)
+			// Note: the test for minimum Java 9 version is enforced in the original CP
+			// parsing (see cpParser.go), where the names of all the Package entries were
+			// fetched.
 			if !klass.classIsModule {
 				return cfe("Package CP entry must appear only in class with ACC_MODULE set.")
 			}
@@ -553,6 +604,10 @@ func formatCheckFields(klass *ParsedClass) error {
 		}
 		fDesc := klass.utf8Refs[f.description].content
 
+		if fName == "" {
+			return cfe("Invalid empty field name in format check in field #" + strconv.Itoa(i))
+		}
+
 		fNameBytes := []byte(fName)
 		if fNameBytes[0] >= '0' && fNameBytes[0] <= '9' {
 			return cfe("Invalid field name in format check (starts with a digit): " + fName)
@@ -579,6 +634,43 @@ func formatCheckFields(klass *ParsedClass) error {
 		if validateFieldDesc(fDesc) != nil {
 			return cfe("Field " + fName + " has an invalid description string: " + fDesc)
 		}
+
+		// the ConstantValue attribute of a field that's not static is ignored, see:
+		// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.2
+		if f.constValue != 0 && f.accessFlags&ACC_STATIC != 0 {
+			if err := formatCheckConstantValue(klass, fName, fDesc, f.constValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// the type of CP entry that the ConstantValue attribute of a field of each type must
+// point to. Fields of other types can't have a ConstantValue.
+var constantValueTypes = map[string]int{
+	"B":                  IntConst,
+	"C":                  IntConst,
+	"I":                  IntConst,
+	"S":                  IntConst,
+	"Z":                  IntConst,
+	"F":                  FloatConst,
+	"J":                  LongConst,
+	"D":                  DoubleConst,
+	"Ljava/lang/String;": StringConst,
+}
+
+// checks that the CP entry at index, from the ConstantValue attribute of a static field,
+// holds a constant of the field's type
+func formatCheckConstantValue(klass *ParsedClass, fName, fDesc string, index int) error {
+	expected, ok := constantValueTypes[fDesc]
+	if !ok {
+		return cfe("Field " + fName + " of type " + fDesc + " in " + klass.className +
+			" cannot have a ConstantValue attribute")
+	}
+	if klass.cpIndex[index].entryType != expected {
+		return cfe("ConstantValue of field " + fName + " of type " + fDesc + " in " + klass.className +
+			" points to CP entry #" + strconv.Itoa(index) + ", which is not a constant of that type")
 	}
 	return nil
 }
@@ -736,17 +828,17 @@ func checkPackageName(name string) error {
 func formatCheckClassAttributes(klass *ParsedClass) error {
 
 	// enforce basic checks of bootstrap entries (which are used by invokedynamic)
-	if len(klass.bootstraps) > 0 {
-		for i := 0; i < len(klass.bootstraps); i++ {
-			bsm := klass.bootstraps[i]
-			if klass.cpIndex[bsm.methodRef].entryType != MethodHandle {
+	if len(klass.BootstrapMethods) > 0 {
+		for i := 0; i < len(klass.BootstrapMethods); i++ {
+			bsm := klass.BootstrapMethods[i]
+			if klass.cpIndex[bsm.MethodRef].entryType != MethodHandle {
 				return cfe("MethodRef in bootstrapMethod[" + strconv.Itoa(i) + "] in class " +
 					klass.className + "should but does not point to a MethodHandle")
 			}
 
-			if len(bsm.args) > 0 {
-				for j := 0; j < len(bsm.args); j++ {
-					if !validateItemIsLodable(klass, bsm.args[j]) {
+			if len(bsm.Args) > 0 {
+				for j := 0; j < len(bsm.Args); j++ {
+					if !validateItemIsLodable(klass, int(bsm.Args[j])) {
 						return cfe("Boostrap method argument[" + strconv.Itoa(j) + "] in class " +
 							klass.className + " bootstrap method #[" + strconv.Itoa(i) + "] " +
 							"should be but is not a loadable constant")
@@ -755,6 +847,38 @@ func formatCheckClassAttributes(klass *ParsedClass) error {
 			}
 		}
 	}
+
+	// a local or anonymous class is not a member of a class, so if it has an entry for
+	// itself in its InnerClasses attribute, the entry can't give it an outer class. See:
+	// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.6
+	if klass.enclosing != nil {
+		for _, ic := range klass.innerClasses {
+			name, err := fetchUTF8string(klass, klass.classRefs[klass.cpIndex[ic.innerClass].slot])
+			if err == nil && name == klass.className && ic.outerClass != 0 {
+				return cfe("Class " + klass.className + " has an EnclosingMethod attribute, " +
+					"but its InnerClasses attribute declares it a member class")
+			}
+		}
+	}
+
+	// the components of a record are named and typed as its fields are. See:
+	// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.30
+	for i, rc := range klass.components {
+		name := klass.utf8Refs[rc.name].content
+		if !validateUnqualifiedName(name, false) {
+			return cfe("Record component #" + strconv.Itoa(i) + " in class " + klass.className +
+				" has an invalid name: " + name)
+		}
+		if desc := klass.utf8Refs[rc.description].content; validateFieldDesc(desc) != nil {
+			return cfe("Record component " + name + " in class " + klass.className +
+				" has an invalid descriptor: " + desc)
+		}
+	}
+
+	// a class is either a member of another class's nest or the host of its own, not both
+	if klass.nestHost != "" && klass.nestMembers != nil {
+		return cfe("Class " + klass.className + " has both a NestHost and a NestMembers attribute")
+	}
 	return nil
 }
 
@@ -776,6 +900,29 @@ func validateItemIsLodable(klass *ParsedClass, arg int) bool {
 	return true
 }
 
+// module-info classes, which have ACC_MODULE set, describe a module rather than
+// declaring a class, so they have their own requirements. They're spelled out in:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.1
+func formatCheckModule(klass *ParsedClass) error {
+	if klass.accessFlags != 0x8000 {
+		return cfe("Module class " + klass.className + " has access flags other than ACC_MODULE: 0x" +
+			strconv.FormatInt(int64(klass.accessFlags), 16))
+	}
+	if klass.javaVersion < 53 {
+		return cfe("Module class " + klass.className + " requires Java 9 or later version")
+	}
+	if klass.className != "module-info" {
+		return cfe("Module class must be named module-info, but is named: " + klass.className)
+	}
+	if klass.superClass != "" || klass.interfaceCount != 0 || klass.fieldCount != 0 || klass.methodCount != 0 {
+		return cfe("Module class " + klass.className + " cannot have a superclass, interfaces, fields, or methods")
+	}
+	if klass.moduleData == nil {
+		return cfe("Module class " + klass.className + " has no Module attribute")
+	}
+	return nil
+}
+
 // format checks of structural elements outside of CP and fields. For example,
 // checking that a count field holds the correct number, etc.
 func formatCheckStructure(klass *ParsedClass) error {
@@ -799,9 +946,9 @@ func formatCheckStructure(klass *ParsedClass) error {
 			strconv.Itoa(len(klass.attributes)))
 	}
 
-	if klass.bootstrapCount != len(klass.bootstraps) {
+	if klass.bootstrapCount != len(klass.BootstrapMethods) {
 		return cfe("Expected " + strconv.Itoa(klass.bootstrapCount) + " bootstrap methods. Got: " +
-			strconv.Itoa(len(klass.bootstraps)))
+			strconv.Itoa(len(klass.BootstrapMethods)))
 	}
 
 	return nil
@@ -37,7 +37,8 @@ import (
 // valid MethodHandle					TestValidMethodHandleEntry
 // invalid MethodHandle (refKind=4) 	TestMethodHandle4PointsToFieldRef
 // valid MethodHandle pting to Interface TestValidMethodHandlePointingToInterface
-// valid MethodHandle, w/ inv class name TestMethodHandleIndex8ButInvalidName
+// invalid MethodHandle (refKind=8)	TestMethodHandleIndex8ButInvalidName
+// <init>/<clinit> in MethodHandles	TestMethodHandleInitNames
 // invalid MethodHandle (refKind=9)		TestInvalidMethodHandleRefKind9
 // valid MethodType 					TestValidMethodType
 // valid and invalid Dynamic entries	TestDynamics
@@ -930,77 +931,126 @@ func TestValidMethodHandlePointingToInterface(t *testing.T) {
 }
 
 // MethodHandles refKind = 8 must have a method name of "<init>"
-// Note: this test commented out due to issue JACOBIN-183. Should be reinstated
-//       in some form, when that issue is resolved.
-//
-// func TestMethodHandleIndex8ButInvalidName(t *testing.T) {
-// 	globals.InitGlobals("test")
-// 	log.Init()
-// 	_ = log.SetLogLevel(log.CLASS)
-//
-// 	// redirect stderr & stdout to capture results from stderr
-// 	normalStderr := os.Stderr
-// 	r, w, _ := os.Pipe()
-// 	os.Stderr = w
-//
-// 	normalStdout := os.Stdout
-// 	_, wout, _ := os.Pipe()
-// 	os.Stdout = wout
-//
-// 	// variables we'll need.
-// 	klass := ParsedClass{}
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{MethodHandle, 0})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{MethodRef, 0})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{NameAndType, 0})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 2})
-// 	klass.cpIndex = append(klass.cpIndex, cpEntry{ClassRef, 0})
-//
-// 	klass.methodHandles = append(klass.methodHandles, methodHandleEntry{
-// 		referenceKind: 8, // this requires that the method name be <init>,
-// 		// but it's "methName"
-// 		referenceIndex: 2, // index into CP of MethodRef entry
-// 	})
-//
-// 	klass.methodRefs = append(klass.methodRefs, methodRefEntry{
-// 		classIndex: 7, // points to classRef entry for class name,
-// 		// which poitns to UTF8 record, here: "methName"
-// 		nameAndTypeIndex: 3,
-// 	})
-//
-// 	klass.classRefs = append(klass.classRefs, 4)
-//
-// 	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"methName"})
-// 	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"nAndType-methname"})
-// 	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"D"})
-//
-// 	klass.nameAndTypes = append(klass.nameAndTypes, nameAndTypeEntry{
-// 		nameIndex:       5, // points to UTF8[1], i.e., nAndTYpe-methname
-// 		descriptorIndex: 6, // points to UTF8[2], i.e., "D"
-// 	})
-//
-// 	klass.cpCount = 8
-//
-// 	err := formatCheckConstantPool(&klass)
-// 	if err == nil {
-// 		t.Error("Expected error for invalid method name, but didn't get any")
-// 	}
-//
-// 	// restore stderr and stdout to what they were before
-// 	_ = w.Close()
-// 	out, _ := io.ReadAll(r)
-// 	os.Stderr = normalStderr
-// 	msg := string(out[:])
-//
-// 	if !strings.Contains(msg, "should be <init>") {
-// 		t.Error("Got unexpected error message: " + msg)
-// 	}
-//
-// 	_ = wout.Close()
-// 	os.Stdout = normalStdout
-// }
+func TestMethodHandleIndex8ButInvalidName(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.CLASS)
+
+	// redirect stderr & stdout to capture results from stderr
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	// variables we'll need.
+	klass := ParsedClass{}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{MethodHandle, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{MethodRef, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{NameAndType, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 2})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{ClassRef, 0})
+
+	klass.methodHandles = append(klass.methodHandles, methodHandleEntry{
+		referenceKind: 8, // this requires that the method name be <init>,
+		// but it's "nAndType-methname"
+		referenceIndex: 2, // index into CP of MethodRef entry
+	})
+
+	klass.methodRefs = append(klass.methodRefs, methodRefEntry{
+		classIndex: 7, // points to classRef entry for class name,
+		// which points to UTF8 record, here: "className"
+		nameAndTypeIndex: 3,
+	})
+
+	klass.classRefs = append(klass.classRefs, 4)
+
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"className"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"nAndType-methname"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"D"})
+
+	klass.nameAndTypes = append(klass.nameAndTypes, nameAndTypeEntry{
+		nameIndex:       5, // points to UTF8[1], i.e., nAndTYpe-methname
+		descriptorIndex: 6, // points to UTF8[2], i.e., "D"
+	})
+
+	klass.cpCount = 8
+
+	err := formatCheckConstantPool(&klass)
+	if err == nil {
+		t.Error("Expected error for invalid method name, but didn't get any")
+	}
+
+	// restore stderr and stdout to what they were before
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "should be <init>") {
+		t.Error("Got unexpected error message: " + msg)
+	}
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+}
+
+// MethodHandles with refKind 5-7 or 9 cannot refer to <init> or <clinit>, while those
+// with refKind 8 must refer to <init>. The name checked is that of the method in the
+// MethodRef's NameAndType, not that of its class.
+func TestMethodHandleInitNames(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klassFor := func(refKind int, className, methName string) *ParsedClass {
+		klass := ParsedClass{javaVersion: 55}
+		klass.cpIndex = []cpEntry{{}, {MethodHandle, 0}, {MethodRef, 0}, {NameAndType, 0},
+			{UTF8, 0}, {UTF8, 1}, {UTF8, 2}, {ClassRef, 0}}
+		klass.methodHandles = []methodHandleEntry{{referenceKind: refKind, referenceIndex: 2}}
+		klass.methodRefs = []methodRefEntry{{classIndex: 7, nameAndTypeIndex: 3}}
+		klass.classRefs = []int{4}
+		klass.utf8Refs = []utf8Entry{{className}, {methName}, {"()V"}}
+		klass.nameAndTypes = []nameAndTypeEntry{{nameIndex: 5, descriptorIndex: 6}}
+		klass.cpCount = 8
+		return &klass
+	}
+
+	tests := []struct {
+		refKind   int
+		className string
+		methName  string
+		valid     bool
+	}{
+		{5, "java/lang/Object", "toString", true},
+		{5, "<init>", "toString", true}, // only the method's name matters
+		{5, "java/lang/Object", "<init>", false},
+		{6, "java/lang/Object", "<clinit>", false},
+		{7, "java/lang/Object", "<init>", false},
+		{8, "java/lang/Object", "<init>", true},
+		{8, "java/lang/Object", "toString", false},
+	}
+	for _, test := range tests {
+		err := formatCheckConstantPool(klassFor(test.refKind, test.className, test.methName))
+		if test.valid && err != nil {
+			t.Errorf("Expected MethodHandle of kind %d to %s.%s to be valid", test.refKind, test.className, test.methName)
+		} else if !test.valid && err == nil {
+			t.Errorf("Expected an error for MethodHandle of kind %d to %s.%s", test.refKind, test.className, test.methName)
+		}
+	}
+}
 
 func TestInvalidMethodHandleRefKind9(t *testing.T) {
 	globals.InitGlobals("test")
@@ -1168,9 +1218,9 @@ func TestDynamics(t *testing.T) {
 		referenceKind:  5, //
 		referenceIndex: 6, // points to MethodRef entry
 	})
-	klass.bootstraps = append(klass.bootstraps, bootstrapMethod{
-		methodRef: 3,
-		args:      []int{1},
+	klass.BootstrapMethods = append(klass.BootstrapMethods, BootstrapMethod{
+		MethodRef: 3,
+		Args:      []uint16{1},
 	})
 	klass.bootstrapCount = 1
 	klass.methodRefs = append(klass.methodRefs, methodRefEntry{
@@ -1273,9 +1323,9 @@ func TestValidInvokeDynamic(t *testing.T) {
 		referenceKind:  5, //
 		referenceIndex: 6, // points to MethodRef entry
 	})
-	klass.bootstraps = append(klass.bootstraps, bootstrapMethod{
-		methodRef: 3,
-		args:      []int{1},
+	klass.BootstrapMethods = append(klass.BootstrapMethods, BootstrapMethod{
+		MethodRef: 3,
+		Args:      []uint16{1},
 	})
 	klass.bootstrapCount = 1
 	klass.methodRefs = append(klass.methodRefs, methodRefEntry{
@@ -1746,6 +1796,91 @@ func TestInvalidFieldDescription(t *testing.T) {
 	os.Stdout = normalStdout
 }
 
+// the ConstantValue of a static field must be a constant of the field's type
+func TestConstantValueOfStaticField(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{className: "Consts"}
+	klass.cpIndex = []cpEntry{{}, {UTF8, 0}, {UTF8, 1}, {IntConst, 0}, {StringConst, 0}, {UTF8, 2}}
+	klass.utf8Refs = []utf8Entry{{"MAX"}, {"I"}, {"Ljava/lang/String;"}}
+	klass.intConsts = []int{42}
+	klass.cpCount = 6
+	klass.fieldCount = 1
+	klass.fields = []field{{accessFlags: ACC_STATIC | 0x0010, name: 0, description: 1, constValue: 3}}
+
+	errValid := formatCheckFields(&klass)
+
+	klass.fields[0].constValue = 4 // an int pointing to a String constant
+	errWrongType := formatCheckFields(&klass)
+
+	klass.fields[0].accessFlags = 0x0010 // but for a field that's not static, it's ignored
+	errNotStatic := formatCheckFields(&klass)
+
+	klass.fields[0].accessFlags = ACC_STATIC
+	klass.fields[0].description = 2 // a String field is fine, but
+	errString := formatCheckFields(&klass)
+	klass.utf8Refs[2] = utf8Entry{"Ljava/lang/Object;"} // an Object can't have a ConstantValue
+	errObject := formatCheckFields(&klass)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if errValid != nil || errNotStatic != nil || errString != nil {
+		t.Errorf("Unexpected error for a valid ConstantValue: %v, %v, %v (%s)", errValid, errNotStatic,
+			errString, msg)
+	}
+	if errWrongType == nil || !strings.Contains(msg, "ConstantValue of field MAX of type I in Consts "+
+		"points to CP entry #4, which is not a constant of that type") {
+		t.Errorf("Expected an error for an int with a String ConstantValue, got: %s", msg)
+	}
+	if errObject == nil || !strings.Contains(msg, "Field MAX of type Ljava/lang/Object; in Consts "+
+		"cannot have a ConstantValue attribute") {
+		t.Errorf("Expected an error for an Object with a ConstantValue, got: %s", msg)
+	}
+}
+
+func TestRecordComponentNamesAndDescriptors(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{className: "Point"}
+	klass.utf8Refs = []utf8Entry{{"x"}, {"I"}, {"a.b"}, {"V"}}
+	klass.components = []recordComponent{{name: 0, description: 1}}
+	errValid := formatCheckClassAttributes(&klass)
+
+	klass.components = append(klass.components, recordComponent{name: 2, description: 1})
+	errName := formatCheckClassAttributes(&klass)
+
+	klass.components[1] = recordComponent{name: 0, description: 3} // void is not a field type
+	errDesc := formatCheckClassAttributes(&klass)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if errValid != nil {
+		t.Errorf("Unexpected error for the record component int x: %s", msg)
+	}
+	if errName == nil || !strings.Contains(msg, "Record component #1 in class Point has an invalid name: a.b") {
+		t.Errorf("Expected an error for a record component named a.b, got: %s", msg)
+	}
+	if errDesc == nil || !strings.Contains(msg, "Record component x in class Point has an invalid descriptor: V") {
+		t.Errorf("Expected an error for a record component of type void, got: %s", msg)
+	}
+}
+
 func TestMethodDescription(t *testing.T) {
 	if validateMethodDesc("") == nil {
 		t.Error("Did not get expected error for empty method descriptor")
@@ -1805,7 +1940,7 @@ func TestCheckStructure(t *testing.T) {
 	pClass.attribCount = 0
 
 	pClass.bootstrapCount = 5
-	pClass.bootstraps = nil
+	pClass.BootstrapMethods = nil
 	if formatCheckStructure(&pClass) == nil {
 		t.Error("Expecting error in mismatch of bootstrapCount and boostraps.len, but got none")
 	}
@@ -0,0 +1,359 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Go methods that create Java objects (a new String, for example) hand them to the
+// interpreter as 64-bit addresses, which is how all objects are represented on the
+// operand stack. Go's garbage collector does not recognize these int64 values as
+// references, so every such object is recorded in liveObjects, which keeps it reachable
+// and lets Go methods get back the typed object from its address. Objects that the
+// interpreter can no longer reach are removed from the table by CollectObjects(), after
+// which Go collects them.
+var liveObjects = make(map[int64]interface{})
+var liveObjectsMutex sync.RWMutex
+
+// the estimated size of each object in liveObjects, when it was added, and their total
+var liveObjectSizes = make(map[int64]int64)
+var liveObjectBytes atomic.Int64
+
+// InterpreterObject is implemented by the objects that the interpreter instantiates
+// (see AddInterpreterObject()), whose layout the classloader doesn't know
+type InterpreterObject interface {
+	// HeldAddresses returns the values of the object's fields that might be addresses
+	HeldAddresses() []int64
+	// Collected is called once a collection has removed the object from the objects
+	// table, so that its space can be returned to the interpreter's heap
+	Collected()
+}
+
+// AddInterpreterObject records an object that the interpreter instantiated (see the new
+// bytecode) and returns its address, which is the value the interpreter holds for it.
+// Like the objects that Go methods create, it stays in the objects table, and so stays
+// reachable, until CollectObjects() finds that the interpreter no longer refers to it.
+// Its size is counted by the interpreter's heap, so it isn't part of GoObjectBytes().
+func AddInterpreterObject(obj InterpreterObject) int64 {
+	addr := int64(reflect.ValueOf(obj).Pointer())
+	liveObjectsMutex.Lock()
+	liveObjects[addr] = obj
+	liveObjectsMutex.Unlock()
+	return addr
+}
+
+// ObjectAt returns the object at addr, whether it was created by a Go method or
+// instantiated by the interpreter, or nil if there's no object at addr
+func ObjectAt(addr int64) interface{} {
+	return objectAt(addr)
+}
+
+// the Strings that ldc pushes for string constants, by their value. String literals
+// are interned, so every constant with the same value is the same String, and these
+// Strings are never collected. Guarded by liveObjectsMutex.
+var stringConstants = make(map[string]int64)
+
+// addObject records an object created by a Go method and returns its address.
+// The object must be a pointer.
+func addObject(obj interface{}) int64 {
+	liveObjectsMutex.Lock()
+	addr := recordObject(obj)
+	liveObjectsMutex.Unlock()
+	return addr
+}
+
+// records obj in the objects table; the caller holds liveObjectsMutex
+func recordObject(obj interface{}) int64 {
+	addr := int64(reflect.ValueOf(obj).Pointer())
+	liveObjects[addr] = obj
+	if _, present := liveObjectSizes[addr]; !present {
+		size := goObjectSize(obj)
+		liveObjectSizes[addr] = size
+		liveObjectBytes.Add(size)
+	}
+	return addr
+}
+
+// StringConstant returns the address of the String holding the string constant s,
+// creating it the first time s is loaded.
+func StringConstant(s string) int64 {
+	liveObjectsMutex.Lock()
+	defer liveObjectsMutex.Unlock()
+	addr, present := stringConstants[s]
+	if !present {
+		addr = recordObject(&StringObject{Value: s})
+		stringConstants[s] = addr
+	}
+	return addr
+}
+
+// LiveObjectCount returns the number of objects in the objects table, which are those
+// that the last collection found reachable and those created since
+func LiveObjectCount() int {
+	liveObjectsMutex.RLock()
+	defer liveObjectsMutex.RUnlock()
+	return len(liveObjects)
+}
+
+// GoObjectBytes returns the estimated size of the objects created by Go methods that
+// are in the objects table, which the JVM adds to the size of its heap.
+func GoObjectBytes() int64 {
+	return liveObjectBytes.Load()
+}
+
+// estimates the number of bytes an object created by a Go method occupies: its struct
+// plus the contents of its slices and strings, such as the elements of an array
+func goObjectSize(obj interface{}) int64 {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	size := int64(v.Type().Size())
+	if v.Kind() != reflect.Struct {
+		return size
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Slice:
+			size += int64(field.Len()) * int64(field.Type().Elem().Size())
+		case reflect.String:
+			size += int64(field.Len())
+		}
+	}
+	return size
+}
+
+// objectAt returns the object created by a Go method at the given address,
+// or nil if no such object exists.
+func objectAt(addr int64) interface{} {
+	liveObjectsMutex.RLock()
+	obj := liveObjects[addr]
+	liveObjectsMutex.RUnlock()
+	return obj
+}
+
+// NewGoObject creates an uninitialized object of the named class, for the classes whose
+// objects are created by the new bytecode as Go objects rather than as interpreter
+// objects, because their constructors and other methods are Go methods. These are
+// String, the References and ReferenceQueue, and Throwable and all its subclasses.
+// It returns the object's address, and false if the class isn't one of these.
+func NewGoObject(className string) (int64, bool) {
+	switch className {
+	case "java/lang/String":
+		return NewStringObject(""), true
+	case "java/lang/ref/WeakReference", "java/lang/ref/SoftReference", "java/lang/ref/PhantomReference":
+		return NewReferenceObject(className), true
+	case "java/lang/ref/ReferenceQueue":
+		return NewReferenceQueueObject(), true
+	}
+	if isThrowableClass(className) {
+		return NewThrowableObject(className), true
+	}
+	return 0, false
+}
+
+// isThrowableClass reports whether the named class is java/lang/Throwable or extends
+// it. The class is loaded, if it isn't already, so that its superclasses can be checked.
+// A class that fails to load isn't taken to be a Throwable, so that instantiating it
+// reports the failure.
+func isThrowableClass(className string) bool {
+	if className == "java/lang/Throwable" {
+		return true
+	}
+	if LoadClassFromNameOnly(className) != nil {
+		return false
+	}
+	k, ok := LookupClass(className)
+	return ok && k.Data != nil && isSubclassOf(k.Data, "java/lang/Throwable")
+}
+
+// CollectObjects removes from the objects table those objects that can't be reached
+// from roots, which are the values the interpreter holds on its operand stacks, in its
+// local variables, and in statics, along with the String constants, and returns the
+// number removed. As the interpreter
+// doesn't record which of these values are references, any value that's the address of
+// an object is taken to refer to it. Objects are reached in turn through the addresses
+// they hold: the elements of arrays of objects, the fields of interpreter objects, and
+// the int64 fields of other objects (a Throwable's message, for example). The referent
+// of a Reference is held only by its address, so it isn't reached through the
+// Reference. The References to the removed objects are cleared and enqueued (see
+// referentCollected()), the removed interpreter objects return their space to the
+// interpreter's heap, and Go then collects the objects when nothing else refers to them.
+func CollectObjects(roots []int64) int {
+	removed := sweepObjects(roots)
+	for addr, obj := range removed {
+		referentCollected(uintptr(addr))
+		if iobj, ok := obj.(InterpreterObject); ok {
+			iobj.Collected()
+		}
+	}
+	return len(removed)
+}
+
+// removes the objects that can't be reached from roots from the objects table and
+// returns them, by their addresses
+func sweepObjects(roots []int64) map[int64]interface{} {
+	liveObjectsMutex.Lock()
+	defer liveObjectsMutex.Unlock()
+
+	reached := make(map[int64]bool)
+	pending := append([]int64{SystemOut, theRuntime}, roots...)
+	for _, addr := range stringConstants {
+		pending = append(pending, addr)
+	}
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		obj, ok := liveObjects[addr]
+		if !ok || reached[addr] {
+			continue
+		}
+		reached[addr] = true
+		pending = appendHeldAddresses(pending, obj)
+	}
+
+	removed := make(map[int64]interface{})
+	for addr, obj := range liveObjects {
+		if !reached[addr] {
+			delete(liveObjects, addr)
+			liveObjectBytes.Add(-liveObjectSizes[addr])
+			delete(liveObjectSizes, addr)
+			removed[addr] = obj
+		}
+	}
+	return removed
+}
+
+// appends to addrs the addresses that obj holds: the elements of an array of objects,
+// the fields of an interpreter object, or the values of the int64 and []int64 fields of
+// any other object
+func appendHeldAddresses(addrs []int64, obj interface{}) []int64 {
+	if iobj, ok := obj.(InterpreterObject); ok {
+		return append(addrs, iobj.HeldAddresses()...)
+	}
+	if arr, ok := obj.(*ArrayObject); ok {
+		if arr.Type == T_REF {
+			addrs = append(addrs, arr.Elements...)
+		}
+		return addrs
+	}
+
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return addrs
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Int64:
+			addrs = append(addrs, field.Int())
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Int64:
+			for j := 0; j < field.Len(); j++ {
+				addrs = append(addrs, field.Index(j).Int())
+			}
+		}
+	}
+	return addrs
+}
+
+// the element types of arrays, using the codes of the newarray bytecode
+// (see https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-6.html#jvms-6.5.newarray)
+// plus one for arrays of object references
+const (
+	T_BOOLEAN = 4
+	T_CHAR    = 5
+	T_FLOAT   = 6
+	T_DOUBLE  = 7
+	T_BYTE    = 8
+	T_SHORT   = 9
+	T_INT     = 10
+	T_LONG    = 11
+	T_REF     = 12 // not a newarray code: arrays of objects are created by anewarray
+)
+
+// ArrayObject is the Go representation of a Java array created by a Go method. The
+// elements are held as int64s, just as they are on the operand stack; for arrays of
+// objects, the elements are the objects' addresses.
+type ArrayObject struct {
+	Type     byte
+	Elements []int64
+}
+
+// NewArrayObject creates an array of the given element type and length,
+// with all elements set to zero, and returns its address.
+func NewArrayObject(arrayType byte, length int) int64 {
+	return addObject(&ArrayObject{Type: arrayType, Elements: make([]int64, length)})
+}
+
+// ArrayAt returns the array created by a Go method at the given address, or nil if
+// there's no such array.
+func ArrayAt(addr int64) *ArrayObject {
+	arr, _ := objectAt(addr).(*ArrayObject)
+	return arr
+}
+
+// ObjectClassName returns the name of the class, in java/lang/Object format, of an
+// object created by a Go method. It returns "" if no object was created by a Go
+// method at the given address.
+func ObjectClassName(addr int64) string {
+	switch obj := objectAt(addr).(type) {
+	case *StringObject:
+		return "java/lang/String"
+	case *ThrowableObject:
+		return obj.ClassName
+	case *ClassObject:
+		return "java/lang/Class"
+	case *PrintStreamObject:
+		return "java/io/PrintStream"
+	case *InputStreamObject:
+		return "java/io/InputStream"
+	case *RuntimeObject:
+		return "java/lang/Runtime"
+	case *ProcessObject:
+		return "java/lang/Process"
+	case *MethodObject:
+		return "java/lang/reflect/Method"
+	case *FieldObject:
+		return "java/lang/reflect/Field"
+	case *AnnotationObject:
+		return obj.Annotation.Type
+	case *ReferenceObject:
+		return obj.ClassName
+	case *ReferenceQueueObject:
+		return "java/lang/ref/ReferenceQueue"
+	case *ArrayObject:
+		return "[" + arrayTypeDescriptor(obj.Type)
+	}
+	return ""
+}
+
+// returns the descriptor of the element type of arrays of the given type
+func arrayTypeDescriptor(arrayType byte) string {
+	switch arrayType {
+	case T_BOOLEAN:
+		return "Z"
+	case T_CHAR:
+		return "C"
+	case T_FLOAT:
+		return "F"
+	case T_DOUBLE:
+		return "D"
+	case T_BYTE:
+		return "B"
+	case T_SHORT:
+		return "S"
+	case T_INT:
+		return "I"
+	case T_LONG:
+		return "J"
+	}
+	return "Ljava/lang/Object;"
+}
@@ -11,6 +11,7 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -3082,8 +3083,8 @@ func TestParseAndPostFunctionWithClass_Class(t *testing.T) {
 	log.Init()
 	_ = log.SetLogLevel(log.WARNING)
 	_ = Init()
-	Classes = make(map[string]Klass)
-	if len(Classes) != 0 {
+	Classes = sync.Map{}
+	if ClassCount() != 0 {
 		t.Errorf("Unexpected error in initializing Classes (which is the method area)")
 	}
 
@@ -3100,8 +3101,8 @@ func TestParseAndPostFunctionWithClass_Class(t *testing.T) {
 		t.Errorf("Got unexpected error in ParseAndPost() of Class.class")
 	}
 
-	if len(Classes) != 1 {
-		t.Errorf("Expected Classes to have 1 entry, but it has %d", len(Classes))
+	if ClassCount() != 1 {
+		t.Errorf("Expected Classes to have 1 entry, but it has %d", ClassCount())
 	}
 
 	_ = w.Close()
@@ -3118,8 +3119,8 @@ func TestLoadClassByNameOnly(t *testing.T) {
 	log.Init()
 	_ = log.SetLogLevel(log.WARNING)
 	_ = Init()
-	Classes = make(map[string]Klass)
-	if len(Classes) != 0 {
+	Classes = sync.Map{}
+	if ClassCount() != 0 {
 		t.Errorf("Unexpected error in initializing Classes (which is the method area)")
 	}
 
@@ -3136,8 +3137,8 @@ func TestLoadClassByNameOnly(t *testing.T) {
 		t.Errorf("Got unexpected error in ParseAndPost() of Class.class")
 	}
 
-	if len(Classes) != 1 {
-		t.Errorf("Expected Classes to have 1 entry, but it has %d", len(Classes))
+	if ClassCount() != 1 {
+		t.Errorf("Expected Classes to have 1 entry, but it has %d", ClassCount())
 	}
 
 	if BootstrapCL.GetCountOfLoadedClasses() != 1 {
@@ -3150,8 +3151,8 @@ func TestLoadClassByNameOnly(t *testing.T) {
 		t.Errorf("Got unexpected error looking up loaded class in Classes: %s", err.Error())
 	}
 
-	if len(Classes) != 1 { // count should still be 1
-		t.Errorf("Expected Classes to have 1 entry, but it has %d", len(Classes))
+	if ClassCount() != 1 { // count should still be 1
+		t.Errorf("Expected Classes to have 1 entry, but it has %d", ClassCount())
 	}
 
 	// now try loading a non-existent class by name only
@@ -3160,8 +3161,8 @@ func TestLoadClassByNameOnly(t *testing.T) {
 		t.Errorf("Expected an error for attempt to load non-existent class")
 	}
 
-	if len(Classes) != 2 { // count should still be 2, one for Class.class, and one entry for the unsuccessful SnoopDog
-		t.Errorf("Expected Classes to have 2 entry, but it has %d", len(Classes))
+	if ClassCount() != 2 { // count should still be 2, one for Class.class, and one entry for the unsuccessful SnoopDog
+		t.Errorf("Expected Classes to have 2 entry, but it has %d", ClassCount())
 	}
 
 	_ = w.Close()
@@ -0,0 +1,152 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"io"
+	"jacobin/exceptions"
+)
+
+// InputStreamObject is the Go representation of a java.io.InputStream, which
+// reads from the wrapped io.Reader.
+type InputStreamObject struct {
+	Reader io.Reader
+}
+
+func Load_Io_InputStream() map[string]GMeth {
+
+	MethodSignatures["java/io/InputStream.read()I"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  inputStreamRead,
+		}
+
+	MethodSignatures["java/io/InputStream.read([B)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the InputStream, [1] = the byte array to read into
+			GFunction:  inputStreamReadBytes,
+		}
+
+	MethodSignatures["java/io/InputStream.available()I"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  inputStreamAvailable,
+		}
+
+	MethodSignatures["java/io/InputStream.close()V"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  inputStreamClose,
+		}
+
+	return MethodSignatures
+}
+
+// NewInputStreamObject creates an InputStream that reads from r and returns its address
+func NewInputStreamObject(r io.Reader) int64 {
+	return addObject(&InputStreamObject{Reader: r})
+}
+
+func getInputStream(addr interface{}, method string) (*InputStreamObject, error) {
+	stream, ok := objectAt(addr.(int64)).(*InputStreamObject)
+	if !ok {
+		msg := "java.lang.NullPointerException: in java.io.InputStream." + method
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return nil, errors.New(msg)
+	}
+	return stream, nil
+}
+
+// java/io/InputStream.read() returns the next byte (0-255), or -1 at the end of the stream
+func inputStreamRead(params []interface{}) interface{} {
+	stream, err := getInputStream(params[0], "read()")
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 1)
+	for {
+		n, err := stream.Reader.Read(b)
+		if n == 1 {
+			return int64(b[0])
+		}
+		if err == io.EOF {
+			return int64(-1)
+		}
+		if err != nil {
+			msg := "java.io.IOException: " + err.Error()
+			exceptions.Throw(exceptions.IOException, msg)
+			return errors.New(msg)
+		}
+	}
+}
+
+// java/io/InputStream.read(byte[] b) reads up to b.length bytes into b and returns the
+// number of bytes read, or -1 at the end of the stream.
+func inputStreamReadBytes(params []interface{}) interface{} {
+	stream, err := getInputStream(params[0], "read()")
+	if err != nil {
+		return err
+	}
+
+	arr, ok := objectAt(params[1].(int64)).(*ArrayObject)
+	if !ok || arr.Type != T_BYTE {
+		msg := "java.lang.NullPointerException: in java.io.InputStream.read(byte[])"
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return errors.New(msg)
+	}
+	if len(arr.Elements) == 0 {
+		return int64(0)
+	}
+
+	b := make([]byte, len(arr.Elements))
+	n, err := stream.Reader.Read(b)
+	for n == 0 && err == nil { // a reader can return 0 bytes without error; try again
+		n, err = stream.Reader.Read(b)
+	}
+	if n == 0 && err == io.EOF {
+		return int64(-1)
+	}
+	if err != nil && err != io.EOF {
+		msg := "java.io.IOException: " + err.Error()
+		exceptions.Throw(exceptions.IOException, msg)
+		return errors.New(msg)
+	}
+
+	for i := 0; i < n; i++ {
+		arr.Elements[i] = int64(int8(b[i])) // Java bytes are signed
+	}
+	return int64(n)
+}
+
+// java/io/InputStream.available() returns the number of bytes that can be read without
+// blocking. That's known only for streams over in-memory data; for others it's 0.
+func inputStreamAvailable(params []interface{}) interface{} {
+	stream, err := getInputStream(params[0], "available()")
+	if err != nil {
+		return err
+	}
+
+	if sized, ok := stream.Reader.(interface{ Len() int }); ok {
+		return int64(sized.Len())
+	}
+	return int64(0)
+}
+
+// java/io/InputStream.close() closes the underlying reader, if it can be closed
+func inputStreamClose(params []interface{}) interface{} {
+	stream, err := getInputStream(params[0], "close()")
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := stream.Reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return nil
+}
@@ -8,7 +8,11 @@ package classloader
 
 import (
 	"fmt"
-	"unsafe"
+	"io"
+	"jacobin/globals"
+	"os"
+	"runtime"
+	"strconv"
 )
 
 /*
@@ -37,7 +41,31 @@ type GMeth struct {
 
 type function func([]interface{}) interface{}
 
+// PrintStreamObject is the Go representation of a java.io.PrintStream. A nil Writer
+// means the stream writes to the io.Writer in globals.Stdout. If AutoFlush is set,
+// each println() flushes the writer (if the writer supports flushing).
+type PrintStreamObject struct {
+	Writer    io.Writer
+	AutoFlush bool
+}
+
+// SystemOut is the address of the PrintStream in the static field java.lang.System.out
+var SystemOut = addObject(&PrintStreamObject{Writer: nil, AutoFlush: true})
+
+// the line separator of the platform Jacobin is running on
+var lineSeparator = func() string {
+	if runtime.GOOS == "windows" {
+		return "\r\n"
+	}
+	return "\n"
+}()
+
 func Load_Io_PrintStream() map[string]GMeth {
+	MethodSignatures["java/io/PrintStream.println()V"] = // println with no args
+		GMeth{
+			ParamSlots: 1, // [0] = PrintStream.out object
+			GFunction:  PrintlnV,
+		}
 	MethodSignatures["java/io/PrintStream.println(Ljava/lang/String;)V"] = // println string
 		GMeth{
 			ParamSlots: 2, // [0] = PrintStream.out object,
@@ -60,34 +88,94 @@ func Load_Io_PrintStream() map[string]GMeth {
 			ParamSlots: 3, // PrintStream.out object + 2 slots for the double
 			GFunction:  PrintlnDouble,
 		}
+
+	MethodSignatures["java/io/PrintStream.print(Ljava/lang/String;)V"] = // print string
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  Print,
+		}
+
+	MethodSignatures["java/io/PrintStream.print(I)V"] = // print int
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintI,
+		}
+
+	MethodSignatures["java/io/PrintStream.print(Z)V"] = // print boolean
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintBoolean,
+		}
+
+	MethodSignatures["java/io/PrintStream.print(C)V"] = // print char
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintChar,
+		}
 	return MethodSignatures
 }
 
+// returns the PrintStream that a print method was called on. If the address passed
+// in is not that of a PrintStreamObject, System.out is used.
+func getPrintStream(addr interface{}) *PrintStreamObject {
+	if ps, ok := objectAt(addr.(int64)).(*PrintStreamObject); ok {
+		return ps
+	}
+	return objectAt(SystemOut).(*PrintStreamObject)
+}
+
+// writes s to the PrintStream. If newline is set, the platform line separator follows s
+// and the output is flushed if the stream is in auto-flush mode.
+func printToStream(ps *PrintStreamObject, s string, newline bool) {
+	w := ps.Writer
+	if w == nil {
+		w = globals.GetGlobalRef().Stdout
+	}
+	if w == nil {
+		w = os.Stdout
+	}
+
+	if newline {
+		s += lineSeparator
+	}
+	_, _ = io.WriteString(w, s)
+
+	if newline && ps.AutoFlush {
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			_ = flusher.Flush()
+		}
+	}
+}
+
+// returns the string to print for a String argument. A null String prints as "null".
+func printableString(addr int64) string {
+	if addr == 0 {
+		return "null"
+	}
+	return GoStringFromAddr(addr)
+}
+
+// PrintlnV = java/io/PrintStream.println(), which prints only the line separator
+func PrintlnV(i []interface{}) interface{} {
+	printToStream(getPrintStream(i[0]), "", true)
+	return nil
+}
+
 // Println is the go equivalent of System.out.println(). It accepts two args,
-// which are passed in a two-entry slice of type interface{}. The first arg is an
-// index in the CP to a StringConst entry; the second arg is an index into the
-// array of static fields, Statics. The entry there includes a pointer to the CP
-// for this class. The first arg then gets the StringConst ref, which is an index
-// into the UTF8 entries of the CP. This string is then printed to stdout. There
-// is no return value.
+// which are passed in a two-entry slice of type interface{}. The first arg is
+// the PrintStream (for System.out, the address in SystemOut); the second arg is
+// the address of the String to print, which is either a String constant in the CP
+// or a StringObject. This string is then printed. There is no return value.
 func Println(i []interface{}) interface{} {
-	sIndex := i[1].(int64) // points to a String constant entry in the CP
-	// cpi := i[0].(int64)    // int64 which is an index into Statics array
-	// cp := StaticsArray[cpi].CP
-
-	usIndex := uint64(sIndex)
-	upsIndex := uintptr(usIndex)
-	strAddr := unsafe.Pointer(upsIndex)
-	s := *(*string)(strAddr)
-	// s := FetchUTF8stringFromCPEntryNumber(cp, uint16(sIndex))
-	fmt.Println(s)
+	sIndex := i[1].(int64) // points to a String constant entry in the CP or a StringObject
+	printToStream(getPrintStream(i[0]), printableString(sIndex), true)
 	return nil
 }
 
 // PrintlnI = java/io/Prinstream.println(int) TODO: equivalent (verify that this grabs the right param to print)
 func PrintlnI(i []interface{}) interface{} {
 	intToPrint := i[1].(int64) // contains an int
-	fmt.Println(intToPrint)
+	printToStream(getPrintStream(i[0]), strconv.FormatInt(intToPrint, 10), true)
 	return nil
 }
 
@@ -95,7 +183,7 @@ func PrintlnI(i []interface{}) interface{} {
 // Long in Java are 64-bit ints, so we just duplicated the logic for println(int)
 func PrintlnLong(l []interface{}) interface{} {
 	longToPrint := l[1].(int64) // contains to an int64--the equivalent of a Java long
-	fmt.Println(longToPrint)
+	printToStream(getPrintStream(l[0]), strconv.FormatInt(longToPrint, 10), true)
 	return nil
 }
 
@@ -103,6 +191,31 @@ func PrintlnLong(l []interface{}) interface{} {
 // Doubles in Java are 64-bit FP
 func PrintlnDouble(l []interface{}) interface{} {
 	doubleToPrint := l[1].(float64) // contains to a float64--the equivalent of a Java double
-	fmt.Println(doubleToPrint)
+	printToStream(getPrintStream(l[0]), fmt.Sprint(doubleToPrint), true)
+	return nil
+}
+
+// Print = java/io/PrintStream.print(String). A null String prints as "null".
+func Print(i []interface{}) interface{} {
+	printToStream(getPrintStream(i[0]), printableString(i[1].(int64)), false)
+	return nil
+}
+
+// PrintI = java/io/PrintStream.print(int)
+func PrintI(i []interface{}) interface{} {
+	printToStream(getPrintStream(i[0]), strconv.FormatInt(i[1].(int64), 10), false)
+	return nil
+}
+
+// PrintBoolean = java/io/PrintStream.print(boolean), which prints true or false
+func PrintBoolean(i []interface{}) interface{} {
+	printToStream(getPrintStream(i[0]), strconv.FormatBool(i[1].(int64) != 0), false)
+	return nil
+}
+
+// PrintChar = java/io/PrintStream.print(char). The char is a UTF-16 char, which is
+// printed as UTF-8.
+func PrintChar(i []interface{}) interface{} {
+	printToStream(getPrintStream(i[0]), string(rune(uint16(i[1].(int64)))), false)
 	return nil
 }
@@ -0,0 +1,76 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bufio"
+	"bytes"
+	"jacobin/globals"
+	"testing"
+)
+
+// redirects System.out to a buffer, whose contents are returned by the returned function
+func captureSystemOut() func() string {
+	globals.InitGlobals("test")
+	buf := new(bytes.Buffer)
+	globals.GetGlobalRef().Stdout = buf
+	return func() string {
+		globals.GetGlobalRef().Stdout = nil
+		return buf.String()
+	}
+}
+
+func TestPrintVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       function
+		arg      int64
+		expected string
+	}{
+		{"print(String)", Print, NewStringObject("hello"), "hello"},
+		{"print(null String)", Print, 0, "null"},
+		{"print(int)", PrintI, -42, "-42"},
+		{"print(true)", PrintBoolean, 1, "true"},
+		{"print(false)", PrintBoolean, 0, "false"},
+		{"print(char)", PrintChar, 'J', "J"},
+		{"print(non-ASCII char)", PrintChar, 0x00E9, "é"},
+		{"println(String)", Println, NewStringObject("hello"), "hello" + lineSeparator},
+		{"println(null String)", Println, 0, "null" + lineSeparator},
+		{"println(int)", PrintlnI, 42, "42" + lineSeparator},
+	}
+
+	for _, test := range tests {
+		output := captureSystemOut()
+		test.fn([]interface{}{SystemOut, test.arg})
+		if got := output(); got != test.expected {
+			t.Errorf("%s: expected %q, got: %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestPrintlnWithNoArgs(t *testing.T) {
+	output := captureSystemOut()
+	PrintlnV([]interface{}{SystemOut})
+	if got := output(); got != lineSeparator {
+		t.Errorf("Expected println() to print only %q, got: %q", lineSeparator, got)
+	}
+}
+
+func TestPrintlnAutoFlush(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ps := addObject(&PrintStreamObject{Writer: bufio.NewWriter(buf), AutoFlush: true})
+
+	Print([]interface{}{ps, NewStringObject("buffered")})
+	if buf.Len() != 0 {
+		t.Errorf("Expected print() not to flush, but got: %q", buf.String())
+	}
+
+	PrintlnV([]interface{}{ps})
+	if buf.String() != "buffered"+lineSeparator {
+		t.Errorf("Expected println() to flush the output, got: %q", buf.String())
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"errors"
+	"jacobin/exceptions"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Go methods for java.lang.Class
+
+// ClassObject is the Go representation of a java.lang.Class, the run-time
+// representation of a loaded class. Name is in java/lang/Object format.
+type ClassObject struct {
+	Name string
+}
+
+// NewClassObject creates the java.lang.Class for the named class and returns its address
+func NewClassObject(name string) int64 {
+	return addObject(&ClassObject{Name: name})
+}
+
+func Load_Lang_Class() map[string]GMeth {
+
+	MethodSignatures["java/lang/Class.getResourceAsStream(Ljava/lang/String;)Ljava/io/InputStream;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Class, [1] = the name of the resource
+			GFunction:  classGetResourceAsStream,
+		}
+
+	MethodSignatures["java/lang/Class.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Class, [1] = the Class of the annotation
+			GFunction:  classGetAnnotation,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Class.getAnnotation(Class annotationClass) returns the class's
+// runtime-visible annotation of the given type, or null if it has none.
+// Annotations inherited via @Inherited are not yet found.
+func classGetAnnotation(params []interface{}) interface{} {
+	class, ok := objectAt(params[0].(int64)).(*ClassObject)
+	if !ok {
+		return throwNullPointerException("java.lang.Class.getAnnotation()")
+	}
+
+	k := loadedClass(class.Name)
+	if k == nil {
+		return int64(0)
+	}
+	return getAnnotation(k.VisibleAnnotations, params[1].(int64))
+}
+
+// java/lang/Class.getResourceAsStream(String name) returns an InputStream over the
+// named resource, or null if it can't be found (see findResource()). A leading / is
+// removed from the name. (Jacobin does not yet have Class objects, so names without
+// the leading / are not made relative to the class's package; they are looked up
+// from the root of the classpath, just like names that start with /.)
+func classGetResourceAsStream(params []interface{}) interface{} {
+	if params[1].(int64) == 0 {
+		msg := "java.lang.NullPointerException: in java.lang.Class.getResourceAsStream()"
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return errors.New(msg)
+	}
+	name := strings.TrimPrefix(GoStringFromAddr(params[1].(int64)), "/")
+
+	data := findResource(name)
+	if data == nil {
+		return int64(0) // null
+	}
+	return NewInputStreamObject(bytes.NewReader(data))
+}
+
+// findResource searches for the named resource, whose path elements are separated by
+// /, in the same order as classes are searched for: Jacobin's own classes, then the
+// modules on the module path, then each entry of the application classpath (either
+// a directory or a JAR file). It returns the contents of the first match, or nil if
+// there are none or if the name leads out of a directory that's searched.
+func findResource(name string) []byte {
+	if globals.JacobinHome() != "" {
+		filename, ok := resourceFile(filepath.Join(globals.JacobinHome(), "classes"), name)
+		if !ok {
+			return nil // the name leads out of the classes directory
+		}
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			if data, err := os.ReadFile(filename); err == nil {
+				return data
+			}
+		}
+	}
+
+	if data := loadResourceFromModules(name); data != nil {
+		return data
+	}
+
+	data, err := appClasspathResolver().LoadResourceByName(name)
+	if err != nil {
+		_ = log.Log("Error reading resource "+name+" from the classpath: "+err.Error(), log.WARNING)
+		return nil
+	}
+	if data == nil {
+		_ = log.Log("Resource "+name+" not found on the classpath", log.FINE)
+	}
+	return data
+}
@@ -0,0 +1,160 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// creates a JAR file in dir holding the given files (name -> contents) and returns its path
+func makeTestJar(t *testing.T, dir string, files map[string]string) string {
+	jarName := filepath.Join(dir, "resources.jar")
+	f, err := os.Create(jarName)
+	if err != nil {
+		t.Fatalf("Unable to create test JAR: %s", err.Error())
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, contents := range files {
+		entry, _ := w.Create(name)
+		_, _ = entry.Write([]byte(contents))
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unable to write test JAR: %s", err.Error())
+	}
+	return jarName
+}
+
+func TestGetResourceAsStreamFromJar(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	spi := "com.example.SPIImpl\n"
+	jarName := makeTestJar(t, t.TempDir(), map[string]string{
+		"META-INF/services/com.example.SPI": spi,
+	})
+	globals.GetGlobalRef().StartingJar = jarName
+
+	stream := classGetResourceAsStream([]interface{}{int64(0),
+		NewStringObject("/META-INF/services/com.example.SPI")})
+	if stream == int64(0) {
+		t.Fatal("Expected getResourceAsStream() to find the resource, got null")
+	}
+
+	if avail := inputStreamAvailable([]interface{}{stream}); avail != int64(len(spi)) {
+		t.Errorf("Expected available() to return %d, got: %v", len(spi), avail)
+	}
+
+	// read the first byte singly, then the rest into an array
+	first := inputStreamRead([]interface{}{stream}).(int64)
+	arr := NewArrayObject(T_BYTE, 64)
+	n := inputStreamReadBytes([]interface{}{stream, arr}).(int64)
+
+	contents := []byte{byte(first)}
+	for _, b := range objectAt(arr).(*ArrayObject).Elements[:n] {
+		contents = append(contents, byte(b))
+	}
+	if string(contents) != spi {
+		t.Errorf("Expected resource contents %q, got: %q", spi, string(contents))
+	}
+
+	if eof := inputStreamReadBytes([]interface{}{stream, arr}); eof != int64(-1) {
+		t.Errorf("Expected read(byte[]) to return -1 at the end of the stream, got: %v", eof)
+	}
+	inputStreamClose([]interface{}{stream})
+}
+
+func TestGetResourceAsStreamFromDirectory(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "config"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "config", "app.properties"), []byte("x=1"), 0644)
+	globals.GetGlobalRef().StartingClass = filepath.Join(dir, "Main.class")
+
+	stream := classGetResourceAsStream([]interface{}{int64(0), NewStringObject("config/app.properties")})
+	if stream == int64(0) {
+		t.Fatal("Expected getResourceAsStream() to find the resource, got null")
+	}
+	if b := inputStreamRead([]interface{}{stream}); b != int64('x') {
+		t.Errorf("Expected first byte of resource to be 'x', got: %v", b)
+	}
+}
+
+func TestGetResourceAsStreamMissingResource(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	globals.GetGlobalRef().StartingJar = makeTestJar(t, t.TempDir(), map[string]string{"a.txt": "a"})
+
+	ret := classGetResourceAsStream([]interface{}{int64(0), NewStringObject("/no/such/resource.txt")})
+	if ret != int64(0) {
+		t.Errorf("Expected getResourceAsStream() to return null for a missing resource, got: %v", ret)
+	}
+}
+
+// getResourceAsStream() can't read files outside the classpath and JACOBIN_HOME
+func TestGetResourceAsStreamTraversal(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	dir := t.TempDir()
+	appDir := filepath.Join(dir, "app")
+	_ = os.MkdirAll(appDir, 0755)
+	_ = os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	globals.GetGlobalRef().StartingClass = filepath.Join(appDir, "Main.class")
+
+	for _, name := range []string{"../secret.txt", "/../secret.txt", "a/../../secret.txt"} {
+		if ret := classGetResourceAsStream([]interface{}{int64(0), NewStringObject(name)}); ret != int64(0) {
+			t.Errorf("Expected getResourceAsStream(%q) to return null, got: %v", name, ret)
+		}
+	}
+}
+
+// a resource in a module on the module path is found before one on the classpath
+func TestGetResourceAsStreamFromModulePath(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	moduleDir := t.TempDir()
+	writeTestJmod(t, filepath.Join(moduleDir, "app.jmod"), map[string][]byte{
+		"classes/config.properties": []byte("from=module"),
+	})
+	manager, err := InitJmodManager(moduleDir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+	ModuleLoaders = []ModuleClassLoader{manager}
+	defer func() { ModuleLoaders = nil }()
+
+	globals.GetGlobalRef().StartingJar = makeTestJar(t, t.TempDir(), map[string]string{
+		"config.properties": "from=jar",
+		"other.properties":  "other",
+	})
+
+	stream := classGetResourceAsStream([]interface{}{int64(0), NewStringObject("config.properties")})
+	if stream == int64(0) {
+		t.Fatal("Expected getResourceAsStream() to find the resource, got null")
+	}
+	if avail := inputStreamAvailable([]interface{}{stream}); avail != int64(len("from=module")) {
+		t.Errorf("Expected the resource from the module path, of %d bytes, got: %v bytes", len("from=module"), avail)
+	}
+
+	if stream = classGetResourceAsStream([]interface{}{int64(0), NewStringObject("other.properties")}); stream == int64(0) {
+		t.Error("Expected getResourceAsStream() to fall back to the classpath, got null")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "math"
+
+// Go methods for java.lang.Double. Doubles arrive as float64s and, like longs, occupy
+// two slots on the operand stack, so each double argument appears twice in the slice
+// of parameters.
+
+func Load_Lang_Double() map[string]GMeth {
+
+	MethodSignatures["java/lang/Double.max(DD)D"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  doubleMax,
+		}
+
+	MethodSignatures["java/lang/Double.min(DD)D"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  doubleMin,
+		}
+
+	MethodSignatures["java/lang/Double.sum(DD)D"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  doubleSum,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Double.max(double a, double b). As in Java, if either value is NaN, the
+// result is NaN, and 0.0 is considered greater than -0.0. Go's math.Max() does the same.
+func doubleMax(params []interface{}) interface{} {
+	return math.Max(params[0].(float64), params[2].(float64))
+}
+
+// java/lang/Double.min(double a, double b). If either value is NaN, the result is NaN,
+// and -0.0 is considered less than 0.0.
+func doubleMin(params []interface{}) interface{} {
+	return math.Min(params[0].(float64), params[2].(float64))
+}
+
+// java/lang/Double.sum(double a, double b)
+func doubleSum(params []interface{}) interface{} {
+	return params[0].(float64) + params[2].(float64)
+}
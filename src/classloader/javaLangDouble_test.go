@@ -0,0 +1,46 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math"
+	"testing"
+)
+
+// each double is passed in two slots
+func doubleParams(a, b float64) []interface{} {
+	return []interface{}{a, a, b, b}
+}
+
+func TestDoubleMaxMinSum(t *testing.T) {
+	if ret := doubleMax(doubleParams(3.5, 5.25)); ret != 5.25 {
+		t.Errorf("Double.max(3.5, 5.25): expected 5.25, got: %v", ret)
+	}
+	if ret := doubleMin(doubleParams(3.5, 5.25)); ret != 3.5 {
+		t.Errorf("Double.min(3.5, 5.25): expected 3.5, got: %v", ret)
+	}
+	if ret := doubleSum(doubleParams(3.5, 5.25)); ret != 8.75 {
+		t.Errorf("Double.sum(3.5, 5.25): expected 8.75, got: %v", ret)
+	}
+}
+
+// if either value is NaN, max() and min() return NaN rather than the other value
+func TestDoubleMaxMinWithNaN(t *testing.T) {
+	nan := math.NaN()
+	for _, params := range [][]interface{}{doubleParams(nan, 1.0), doubleParams(1.0, nan)} {
+		if ret := doubleMax(params).(float64); !math.IsNaN(ret) {
+			t.Errorf("Double.max() with NaN: expected NaN, got: %v", ret)
+		}
+		if ret := doubleMin(params).(float64); !math.IsNaN(ret) {
+			t.Errorf("Double.min() with NaN: expected NaN, got: %v", ret)
+		}
+	}
+
+	if ret := doubleMax(doubleParams(math.Copysign(0, -1), 0)).(float64); math.Signbit(ret) {
+		t.Errorf("Double.max(-0.0, 0.0): expected 0.0, got: -0.0")
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "strconv"
+
+// Go methods for java.lang.Integer. Java ints arrive as int64s, so they're first
+// truncated to 32 bits.
+
+func Load_Lang_Integer() map[string]GMeth {
+
+	MethodSignatures["java/lang/Integer.toBinaryString(I)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1, // [0] = the int
+			GFunction:  integerToBinaryString,
+		}
+
+	MethodSignatures["java/lang/Integer.toHexString(I)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1, // [0] = the int
+			GFunction:  integerToHexString,
+		}
+
+	MethodSignatures["java/lang/Integer.toOctalString(I)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1, // [0] = the int
+			GFunction:  integerToOctalString,
+		}
+
+	MethodSignatures["java/lang/Integer.toString(II)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the int, [1] = the radix
+			GFunction:  integerToStringRadix,
+		}
+
+	MethodSignatures["java/lang/Integer.toUnsignedString(II)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the int, [1] = the radix
+			GFunction:  integerToUnsignedStringRadix,
+		}
+
+	MethodSignatures["java/lang/Integer.max(II)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = a, [1] = b
+			GFunction:  integerMax,
+		}
+
+	MethodSignatures["java/lang/Integer.min(II)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = a, [1] = b
+			GFunction:  integerMin,
+		}
+
+	MethodSignatures["java/lang/Integer.sum(II)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = a, [1] = b
+			GFunction:  integerSum,
+		}
+
+	return MethodSignatures
+}
+
+// returns the digits of the int in the given radix, treating the int as unsigned.
+// So, -1 in base 2 is 32 1s.
+func unsignedIntString(i int64, radix int) int64 {
+	return NewStringObject(strconv.FormatUint(uint64(uint32(i)), radix))
+}
+
+// java/lang/Integer.toBinaryString(int i) returns i as an unsigned binary number
+func integerToBinaryString(params []interface{}) interface{} {
+	return unsignedIntString(params[0].(int64), 2)
+}
+
+// java/lang/Integer.toHexString(int i) returns i as an unsigned hexadecimal number,
+// using lower-case letters
+func integerToHexString(params []interface{}) interface{} {
+	return unsignedIntString(params[0].(int64), 16)
+}
+
+// java/lang/Integer.toOctalString(int i) returns i as an unsigned octal number
+func integerToOctalString(params []interface{}) interface{} {
+	return unsignedIntString(params[0].(int64), 8)
+}
+
+// java/lang/Integer.toString(int i, int radix) returns i in the given radix. Like Java,
+// the result is signed (toString(-1, 16) is "-1") and a radix outside the range 2-36
+// is replaced by 10.
+func integerToStringRadix(params []interface{}) interface{} {
+	i := int64(int32(params[0].(int64)))
+	return NewStringObject(strconv.FormatInt(i, validRadix(params[1].(int64))))
+}
+
+// java/lang/Integer.toUnsignedString(int i, int radix) returns i in the given radix,
+// treating it as unsigned, so toUnsignedString(-1, 16) is "ffffffff"
+func integerToUnsignedStringRadix(params []interface{}) interface{} {
+	return unsignedIntString(params[0].(int64), validRadix(params[1].(int64)))
+}
+
+// Java uses radix 10 in place of a radix outside the range Character.MIN_RADIX
+// to Character.MAX_RADIX
+func validRadix(radix int64) int {
+	if radix < 2 || radix > 36 {
+		return 10
+	}
+	return int(radix)
+}
+
+// java/lang/Integer.max(int a, int b)
+func integerMax(params []interface{}) interface{} {
+	a, b := params[0].(int64), params[1].(int64)
+	if a >= b {
+		return a
+	}
+	return b
+}
+
+// java/lang/Integer.min(int a, int b)
+func integerMin(params []interface{}) interface{} {
+	a, b := params[0].(int64), params[1].(int64)
+	if a <= b {
+		return a
+	}
+	return b
+}
+
+// java/lang/Integer.sum(int a, int b). The sum wraps around on overflow, as in Java.
+func integerSum(params []interface{}) interface{} {
+	return int64(int32(params[0].(int64)) + int32(params[1].(int64)))
+}
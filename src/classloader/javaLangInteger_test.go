@@ -0,0 +1,54 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestIntegerToStringInRadixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func([]interface{}) interface{}
+		params   []interface{}
+		expected string
+	}{
+		{"toBinaryString(-1)", integerToBinaryString, []interface{}{int64(-1)}, strings.Repeat("1", 32)},
+		{"toBinaryString(5)", integerToBinaryString, []interface{}{int64(5)}, "101"},
+		{"toHexString(255)", integerToHexString, []interface{}{int64(255)}, "ff"},
+		{"toHexString(-1)", integerToHexString, []interface{}{int64(-1)}, "ffffffff"},
+		{"toOctalString(8)", integerToOctalString, []interface{}{int64(8)}, "10"},
+		{"toString(10, 2)", integerToStringRadix, []interface{}{int64(10), int64(2)}, "1010"},
+		{"toString(-1, 16)", integerToStringRadix, []interface{}{int64(-1), int64(16)}, "-1"},
+		{"toString(35, 99)", integerToStringRadix, []interface{}{int64(35), int64(99)}, "35"},
+		{"toUnsignedString(-1, 16)", integerToUnsignedStringRadix, []interface{}{int64(-1), int64(16)}, "ffffffff"},
+	}
+
+	for _, test := range tests {
+		ret := test.fn(test.params)
+		if s := GoStringFromAddr(ret.(int64)); s != test.expected {
+			t.Errorf("%s: expected %s, got: %s", test.name, test.expected, s)
+		}
+	}
+}
+
+func TestIntegerMaxMinSum(t *testing.T) {
+	if ret := integerMax([]interface{}{int64(3), int64(5)}); ret != int64(5) {
+		t.Errorf("Integer.max(3, 5): expected 5, got: %v", ret)
+	}
+	if ret := integerMin([]interface{}{int64(3), int64(5)}); ret != int64(3) {
+		t.Errorf("Integer.min(3, 5): expected 3, got: %v", ret)
+	}
+	if ret := integerSum([]interface{}{int64(3), int64(5)}); ret != int64(8) {
+		t.Errorf("Integer.sum(3, 5): expected 8, got: %v", ret)
+	}
+	if ret := integerSum([]interface{}{int64(math.MaxInt32), int64(1)}); ret != int64(math.MinInt32) {
+		t.Errorf("Integer.sum(MAX_VALUE, 1): expected MIN_VALUE, got: %v", ret)
+	}
+}
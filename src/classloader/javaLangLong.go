@@ -0,0 +1,56 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// Go methods for java.lang.Long. A long occupies two slots on the operand stack, so
+// each long argument appears twice in the slice of parameters.
+
+func Load_Lang_Long() map[string]GMeth {
+
+	MethodSignatures["java/lang/Long.max(JJ)J"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  longMax,
+		}
+
+	MethodSignatures["java/lang/Long.min(JJ)J"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  longMin,
+		}
+
+	MethodSignatures["java/lang/Long.sum(JJ)J"] =
+		GMeth{
+			ParamSlots: 4, // [0], [1] = a, [2], [3] = b
+			GFunction:  longSum,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Long.max(long a, long b)
+func longMax(params []interface{}) interface{} {
+	a, b := params[0].(int64), params[2].(int64)
+	if a >= b {
+		return a
+	}
+	return b
+}
+
+// java/lang/Long.min(long a, long b)
+func longMin(params []interface{}) interface{} {
+	a, b := params[0].(int64), params[2].(int64)
+	if a <= b {
+		return a
+	}
+	return b
+}
+
+// java/lang/Long.sum(long a, long b). Go's int64 addition wraps around just as Java's does.
+func longSum(params []interface{}) interface{} {
+	return params[0].(int64) + params[2].(int64)
+}
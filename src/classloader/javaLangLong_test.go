@@ -0,0 +1,33 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math"
+	"testing"
+)
+
+// each long is passed in two slots
+func longParams(a, b int64) []interface{} {
+	return []interface{}{a, a, b, b}
+}
+
+func TestLongMaxMinSum(t *testing.T) {
+	big := int64(1) << 40 // doesn't fit in an int
+	if ret := longMax(longParams(3, big)); ret != big {
+		t.Errorf("Long.max(3, 2^40): expected 2^40, got: %v", ret)
+	}
+	if ret := longMin(longParams(-big, 3)); ret != -big {
+		t.Errorf("Long.min(-2^40, 3): expected -2^40, got: %v", ret)
+	}
+	if ret := longSum(longParams(big, big)); ret != int64(1)<<41 {
+		t.Errorf("Long.sum(2^40, 2^40): expected 2^41, got: %v", ret)
+	}
+	if ret := longSum(longParams(math.MaxInt64, 1)); ret != int64(math.MinInt64) {
+		t.Errorf("Long.sum(MAX_VALUE, 1): expected MIN_VALUE, got: %v", ret)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Go methods for java.lang.Math
+
+// the generator behind Math.random(). Every JVM gets its own sequence because the
+// generator is seeded from crypto/rand when the Go methods are loaded at start-up.
+// A rand.Rand is not safe for concurrent use, hence the mutex.
+var mathRandom *rand.Rand
+var mathRandomMutex sync.Mutex
+
+func Load_Lang_Math() map[string]GMeth {
+	seedMathRandom()
+
+	MethodSignatures["java/lang/Math.random()D"] =
+		GMeth{
+			ParamSlots: 0,
+			GFunction:  mathRandomDouble,
+		}
+
+	return MethodSignatures
+}
+
+// seeds the Math.random() generator with a cryptographically secure seed. If no secure
+// seed can be had, the current time is used instead.
+func seedMathRandom() {
+	var seed int64
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err == nil {
+		seed = int64(binary.LittleEndian.Uint64(b[:]))
+	} else {
+		seed = time.Now().UnixNano()
+	}
+
+	mathRandomMutex.Lock()
+	mathRandom = rand.New(rand.NewSource(seed))
+	mathRandomMutex.Unlock()
+}
+
+// java/lang/Math.random() returns a double in the range [0.0, 1.0)
+func mathRandomDouble(params []interface{}) interface{} {
+	mathRandomMutex.Lock()
+	defer mathRandomMutex.Unlock()
+	if mathRandom == nil {
+		mathRandom = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return mathRandom.Float64()
+}
@@ -0,0 +1,68 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// the generator is given a fixed seed, so that the sequence checked, and so the
+// result of the test, is the same on every run
+func TestMathRandomRangeAndDistribution(t *testing.T) {
+	mathRandomMutex.Lock()
+	mathRandom = rand.New(rand.NewSource(42))
+	mathRandomMutex.Unlock()
+	defer seedMathRandom()
+
+	const calls = 1000
+	var buckets [10]int
+	prev := -1.0
+	for i := 0; i < calls; i++ {
+		d := mathRandomDouble(nil).(float64)
+		if d < 0.0 || d >= 1.0 {
+			t.Fatalf("Math.random() returned %f, which is outside [0.0, 1.0)", d)
+		}
+		if d == prev {
+			t.Errorf("Math.random() returned %f twice in a row", d)
+		}
+		prev = d
+		buckets[int(d*10)]++
+	}
+
+	// each bucket should hold about 100 values. With 9 degrees of freedom, a chi-squared
+	// value above 27.9 happens by chance less than one time in 1000 for a uniform
+	// generator, so a higher value means the values are skewed.
+	chiSquared := 0.0
+	for _, count := range buckets {
+		diff := float64(count) - calls/10
+		chiSquared += diff * diff / (calls / 10)
+	}
+	if chiSquared > 27.9 {
+		t.Errorf("Math.random() doesn't look uniform: chi-squared = %f, buckets: %v", chiSquared, buckets)
+	}
+}
+
+// each JVM seeds Math.random() afresh, so two JVMs get different sequences
+func TestMathRandomDiffersBetweenJVMs(t *testing.T) {
+	seedMathRandom()
+	first := make([]float64, 5)
+	for i := range first {
+		first[i] = mathRandomDouble(nil).(float64)
+	}
+
+	seedMathRandom() // as at the start-up of a second JVM
+	same := true
+	for i := range first {
+		if mathRandomDouble(nil).(float64) != first[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Errorf("Expected two JVMs to produce different Math.random() sequences")
+	}
+}
@@ -0,0 +1,26 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// Go methods for java.lang.Object
+
+func Load_Lang_Object() map[string]GMeth {
+
+	// every constructor ends up calling Object's constructor via invokespecial
+	MethodSignatures["java/lang/Object.<init>()V"] =
+		GMeth{
+			ParamSlots: 1, // [0] = the object being initialized
+			GFunction:  objectInit,
+		}
+
+	return MethodSignatures
+}
+
+// the constructor Object(), which has nothing to initialize
+func objectInit([]interface{}) interface{} {
+	return nil
+}
@@ -0,0 +1,330 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/exceptions"
+	"sync"
+	"time"
+)
+
+// ReferenceObject is the Go representation of a java.lang.ref.Reference: a WeakReference,
+// SoftReference, or PhantomReference. The referent is held only by its address, so that
+// the reference doesn't keep it reachable. When a collection finds that the program
+// can no longer reach the referent, the reference is cleared and, if it was constructed
+// with a ReferenceQueue, enqueued.
+//
+// References are created by the new bytecode as ReferenceObjects (see NewGoObject()).
+// Referents, whether created by Go methods (a String, an array, etc.) or instantiated by
+// the interpreter, are kept in the objects table (see goObjects.go) until
+// CollectObjects() finds that the interpreter no longer refers to them, which is when
+// their references are cleared. Go's own collector has no say in this. Go has no notion
+// of memory pressure, so SoftReferences are cleared just as WeakReferences are.
+type ReferenceObject struct {
+	ClassName string  // in java/lang/Object format
+	referent  uintptr // not an int64, so that CollectObjects() doesn't reach the referent through it
+	queue     *ReferenceQueueObject
+	enqueued  bool
+	mutex     sync.Mutex
+}
+
+// ReferenceQueueObject is the Go representation of a java.lang.ref.ReferenceQueue. The
+// queued references are sent on a channel.
+type ReferenceQueueObject struct {
+	references chan *ReferenceObject
+}
+
+// the references to each referent that's being tracked, by the referent's address
+var referencesTo = make(map[uintptr][]*ReferenceObject)
+var referencesMutex sync.Mutex
+
+// NewReferenceObject creates an uninitialized Reference of the given class (e.g.,
+// java/lang/ref/WeakReference), which is then initialized by one of its constructors,
+// and returns its address.
+func NewReferenceObject(className string) int64 {
+	return addObject(&ReferenceObject{ClassName: className})
+}
+
+// NewReferenceQueueObject creates a ReferenceQueue and returns its address
+func NewReferenceQueueObject() int64 {
+	return addObject(newReferenceQueue())
+}
+
+func newReferenceQueue() *ReferenceQueueObject {
+	return &ReferenceQueueObject{references: make(chan *ReferenceObject, 64)}
+}
+
+func Load_Lang_Ref() map[string]GMeth {
+
+	MethodSignatures["java/lang/ref/ReferenceQueue.<init>()V"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  referenceQueueInit,
+		}
+
+	MethodSignatures["java/lang/ref/ReferenceQueue.poll()Ljava/lang/ref/Reference;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  referenceQueuePoll,
+		}
+
+	MethodSignatures["java/lang/ref/ReferenceQueue.remove()Ljava/lang/ref/Reference;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  referenceQueueRemove,
+		}
+
+	MethodSignatures["java/lang/ref/ReferenceQueue.remove(J)Ljava/lang/ref/Reference;"] =
+		GMeth{
+			ParamSlots: 3, // [0] = the queue, [1] = the timeout in milliseconds (a long, so 2 slots)
+			GFunction:  referenceQueueRemoveWithTimeout,
+		}
+
+	// calls to these methods name the class of the reference, so they're added for each class
+	for _, class := range []string{"java/lang/ref/Reference", "java/lang/ref/WeakReference",
+		"java/lang/ref/SoftReference", "java/lang/ref/PhantomReference"} {
+
+		if class != "java/lang/ref/Reference" {
+			MethodSignatures[class+".<init>(Ljava/lang/Object;Ljava/lang/ref/ReferenceQueue;)V"] =
+				GMeth{
+					ParamSlots: 3, // [0] = the reference, [1] = the referent, [2] = the queue
+					GFunction:  referenceInit,
+				}
+		}
+		if class == "java/lang/ref/WeakReference" || class == "java/lang/ref/SoftReference" {
+			MethodSignatures[class+".<init>(Ljava/lang/Object;)V"] =
+				GMeth{
+					ParamSlots: 2, // [0] = the reference, [1] = the referent
+					GFunction:  referenceInit,
+				}
+		}
+
+		MethodSignatures[class+".get()Ljava/lang/Object;"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  referenceGet,
+			}
+
+		MethodSignatures[class+".clear()V"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  referenceClear,
+			}
+
+		MethodSignatures[class+".enqueue()Z"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  referenceEnqueue,
+			}
+	}
+
+	return MethodSignatures
+}
+
+// returns the Reference at addr, or an error (after throwing a NullPointerException)
+// if there is none
+func getReference(addr interface{}, method string) (*ReferenceObject, error) {
+	ref, ok := objectAt(addr.(int64)).(*ReferenceObject)
+	if !ok {
+		return nil, throwNullPointerException("java.lang.ref.Reference." + method)
+	}
+	return ref, nil
+}
+
+// returns the ReferenceQueue at addr, or an error (after throwing a NullPointerException)
+// if there is none
+func getReferenceQueue(addr interface{}, method string) (*ReferenceQueueObject, error) {
+	queue, ok := objectAt(addr.(int64)).(*ReferenceQueueObject)
+	if !ok {
+		return nil, throwNullPointerException("java.lang.ref.ReferenceQueue." + method)
+	}
+	return queue, nil
+}
+
+// the constructor ReferenceQueue()
+func referenceQueueInit(params []interface{}) interface{} {
+	queue, err := getReferenceQueue(params[0], "<init>()")
+	if err != nil {
+		return err
+	}
+	if queue.references == nil {
+		queue.references = newReferenceQueue().references
+	}
+	return nil
+}
+
+// java/lang/ref/ReferenceQueue.poll() returns the next queued reference, or null if
+// none is queued. It does not wait.
+func referenceQueuePoll(params []interface{}) interface{} {
+	queue, err := getReferenceQueue(params[0], "poll()")
+	if err != nil {
+		return err
+	}
+	select {
+	case ref := <-queue.references:
+		return ref.dequeued()
+	default:
+		return int64(0)
+	}
+}
+
+// java/lang/ref/ReferenceQueue.remove() waits until a reference is queued and returns it.
+// (Jacobin does not yet support interrupting threads, so the wait can't be interrupted.)
+func referenceQueueRemove(params []interface{}) interface{} {
+	queue, err := getReferenceQueue(params[0], "remove()")
+	if err != nil {
+		return err
+	}
+	return (<-queue.references).dequeued()
+}
+
+// java/lang/ref/ReferenceQueue.remove(long timeout) waits up to timeout milliseconds for a
+// reference to be queued and returns it, or null if none was queued in that time. A
+// timeout of 0 waits indefinitely.
+func referenceQueueRemoveWithTimeout(params []interface{}) interface{} {
+	queue, err := getReferenceQueue(params[0], "remove()")
+	if err != nil {
+		return err
+	}
+
+	timeout := params[1].(int64)
+	if timeout < 0 {
+		msg := "java.lang.IllegalArgumentException: Negative timeout value"
+		exceptions.Throw(exceptions.IllegalArgumentException, msg)
+		return errors.New(msg)
+	}
+	if timeout == 0 {
+		return (<-queue.references).dequeued()
+	}
+
+	select {
+	case ref := <-queue.references:
+		return ref.dequeued()
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return int64(0)
+	}
+}
+
+// the constructors WeakReference(T referent), SoftReference(T referent), and
+// (Weak|Soft|Phantom)Reference(T referent, ReferenceQueue q). The queue can be null.
+func referenceInit(params []interface{}) interface{} {
+	ref, err := getReference(params[0], "<init>()")
+	if err != nil {
+		return err
+	}
+
+	if len(params) > 2 && params[2].(int64) != 0 {
+		if ref.queue, err = getReferenceQueue(params[2], "<init>()"); err != nil {
+			return err
+		}
+	}
+
+	referent := params[1].(int64)
+	if referent == 0 {
+		return nil
+	}
+	ref.referent = uintptr(referent)
+	trackReferent(ref)
+	return nil
+}
+
+// java/lang/ref/Reference.get() returns the referent, or null if the reference has been
+// cleared. The referent of a PhantomReference is never returned.
+func referenceGet(params []interface{}) interface{} {
+	ref, err := getReference(params[0], "get()")
+	if err != nil {
+		return err
+	}
+	if ref.ClassName == "java/lang/ref/PhantomReference" {
+		return int64(0)
+	}
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+	return int64(ref.referent)
+}
+
+// java/lang/ref/Reference.clear() clears the reference without enqueuing it
+func referenceClear(params []interface{}) interface{} {
+	ref, err := getReference(params[0], "clear()")
+	if err != nil {
+		return err
+	}
+	ref.mutex.Lock()
+	ref.referent = 0
+	ref.mutex.Unlock()
+	return nil
+}
+
+// java/lang/ref/Reference.enqueue() clears the reference and adds it to its queue. It
+// returns false if the reference has no queue or was already enqueued.
+func referenceEnqueue(params []interface{}) interface{} {
+	ref, err := getReference(params[0], "enqueue()")
+	if err != nil {
+		return err
+	}
+	ref.mutex.Lock()
+	ref.referent = 0
+	ref.mutex.Unlock()
+	if ref.enqueue() {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+// adds the reference to its queue, unless it has no queue or has already been enqueued.
+// It's called during a collection, so it must not wait for room in the queue.
+func (ref *ReferenceObject) enqueue() bool {
+	ref.mutex.Lock()
+	if ref.queue == nil || ref.enqueued {
+		ref.mutex.Unlock()
+		return false
+	}
+	ref.enqueued = true
+	ref.mutex.Unlock()
+
+	select {
+	case ref.queue.references <- ref:
+	default:
+		go func() { ref.queue.references <- ref }()
+	}
+	return true
+}
+
+// returns the address of a reference that's been taken off its queue. The reference might
+// have been removed from the objects table, if the program no longer referred to it
+// except through the queue, so it's added back.
+func (ref *ReferenceObject) dequeued() int64 {
+	return addObject(ref)
+}
+
+// arranges for ref to be cleared and enqueued when a collection removes the referent
+// from the objects table (see CollectObjects())
+func trackReferent(ref *ReferenceObject) {
+	referencesMutex.Lock()
+	referencesTo[ref.referent] = append(referencesTo[ref.referent], ref)
+	referencesMutex.Unlock()
+}
+
+// clears the references to the object at addr, which a collection has found to be
+// unreachable, and enqueues those that were constructed with a queue
+func referentCollected(addr uintptr) {
+	referencesMutex.Lock()
+	refs, tracked := referencesTo[addr]
+	delete(referencesTo, addr)
+	referencesMutex.Unlock()
+	if !tracked {
+		return
+	}
+
+	for _, r := range refs {
+		r.mutex.Lock()
+		r.referent = 0
+		r.mutex.Unlock()
+		r.enqueue()
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"testing"
+)
+
+// once the referent of a WeakReference is collected, the reference is cleared and
+// enqueued on the queue it was constructed with
+func TestWeakReferenceEnqueuedAfterGC(t *testing.T) {
+	queue := NewReferenceQueueObject()
+	referenceQueueInit([]interface{}{queue})
+	referent := NewStringObject("referent")
+	ref := NewReferenceObject("java/lang/ref/WeakReference")
+	referenceInit([]interface{}{ref, referent, queue})
+
+	if got := referenceGet([]interface{}{ref}).(int64); got != referent {
+		t.Errorf("Expected get() to return the referent before GC, got: %d", got)
+	}
+	if polled := referenceQueuePoll([]interface{}{queue}).(int64); polled != 0 {
+		t.Errorf("Expected poll() to return null before GC, got: %d", polled)
+	}
+
+	// the referent is collected once the interpreter holds only the reference and the queue
+	if CollectObjects([]int64{ref, queue}) == 0 {
+		t.Fatal("Expected CollectObjects() to remove the referent from the objects table")
+	}
+	removed := referenceQueuePoll([]interface{}{queue}).(int64)
+
+	if removed != ref {
+		t.Fatalf("Expected poll() to return the WeakReference, got: %d", removed)
+	}
+	if got := referenceGet([]interface{}{ref}).(int64); got != 0 {
+		t.Errorf("Expected get() to return null after GC, got: %d", got)
+	}
+	if ObjectClassName(removed) != "java/lang/ref/WeakReference" {
+		t.Errorf("Expected a java/lang/ref/WeakReference, got: %s", ObjectClassName(removed))
+	}
+}
+
+func TestReferenceQueueRemoveTimesOut(t *testing.T) {
+	queue := NewReferenceQueueObject()
+	if removed := referenceQueueRemoveWithTimeout([]interface{}{queue, int64(10), int64(10)}); removed != int64(0) {
+		t.Errorf("Expected remove(10) on an empty queue to return null, got: %v", removed)
+	}
+}
+
+// enqueue() clears the reference and queues it once; a PhantomReference's get() is always null
+func TestReferenceEnqueueAndPhantomGet(t *testing.T) {
+	queue := NewReferenceQueueObject()
+	referent := NewStringObject("phantom referent")
+	ref := NewReferenceObject("java/lang/ref/PhantomReference")
+	referenceInit([]interface{}{ref, referent, queue})
+
+	if got := referenceGet([]interface{}{ref}).(int64); got != 0 {
+		t.Errorf("Expected a PhantomReference's get() to return null, got: %d", got)
+	}
+	if referenceEnqueue([]interface{}{ref}).(int64) != 1 {
+		t.Errorf("Expected the first enqueue() to return true")
+	}
+	if referenceEnqueue([]interface{}{ref}).(int64) != 0 {
+		t.Errorf("Expected the second enqueue() to return false")
+	}
+	if polled := referenceQueuePoll([]interface{}{queue}).(int64); polled != ref {
+		t.Errorf("Expected poll() to return the enqueued reference, got: %d", polled)
+	}
+}
+
+// objects are kept while they can be reached from the roots, directly or through arrays
+// of objects and the fields of other objects
+func TestCollectObjectsKeepsReachableObjects(t *testing.T) {
+	message := NewStringObject("message")
+	throwable := NewThrowableWithMessage("java/lang/Exception", "unused")
+	objectAt(throwable).(*ThrowableObject).Message = message
+	element := NewStringObject("element")
+	arr := NewArrayObject(T_REF, 1)
+	ArrayAt(arr).Elements[0] = element
+	ints := NewArrayObject(T_INT, 1)
+	unreached := NewStringObject("unreached")
+	ArrayAt(ints).Elements[0] = unreached // an int, not a reference
+
+	CollectObjects([]int64{throwable, arr, ints})
+	for _, addr := range []int64{throwable, message, arr, element, ints, SystemOut, theRuntime} {
+		if objectAt(addr) == nil {
+			t.Errorf("Expected the reachable %s at %d to be kept", ObjectClassName(addr), addr)
+		}
+	}
+	if objectAt(unreached) != nil {
+		t.Error("Expected the String held only in an array of ints to be removed")
+	}
+}
+
+// the objects in the objects table count against the heap until they're removed
+func TestGoObjectBytesCountsTableObjects(t *testing.T) {
+	CollectObjects(nil)
+	before := GoObjectBytes()
+
+	arr := NewArrayObject(T_LONG, 1000)
+	if grown := GoObjectBytes() - before; grown < 8000 {
+		t.Errorf("Expected an array of 1000 longs to add at least 8000 bytes, got: %d", grown)
+	}
+
+	NewStringObject("unreached")
+	CollectObjects([]int64{arr})
+	CollectObjects(nil)
+	if after := GoObjectBytes(); after != before {
+		t.Errorf("Expected the heap to return to %d bytes once the objects were removed, got: %d",
+			before, after)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// Go methods for java.lang.reflect.Method and java.lang.reflect.Field, and for the
+// proxies that implement annotation interfaces
+
+// MethodObject is the Go representation of a java.lang.reflect.Method. Class is the
+// declaring class, in java/lang/Object format, and Desc is the method's descriptor.
+type MethodObject struct {
+	Class string
+	Name  string
+	Desc  string
+}
+
+// FieldObject is the Go representation of a java.lang.reflect.Field
+type FieldObject struct {
+	Class string
+	Name  string
+}
+
+// AnnotationObject is the proxy (what java.lang.reflect.Proxy would create) that
+// implements an annotation interface. Its methods, which return the values of the
+// annotation's elements, are Go methods added to the MTable when the first proxy for
+// the annotation's type is created.
+type AnnotationObject struct {
+	Annotation Annotation
+}
+
+// NewMethodObject creates the java.lang.reflect.Method for the named method of class
+// and returns its address
+func NewMethodObject(class, name, desc string) int64 {
+	return addObject(&MethodObject{Class: class, Name: name, Desc: desc})
+}
+
+// NewFieldObject creates the java.lang.reflect.Field for the named field of class
+// and returns its address
+func NewFieldObject(class, name string) int64 {
+	return addObject(&FieldObject{Class: class, Name: name})
+}
+
+func Load_Lang_Reflect() map[string]GMeth {
+
+	MethodSignatures["java/lang/reflect/Method.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Method, [1] = the Class of the annotation
+			GFunction:  methodGetAnnotation,
+		}
+
+	MethodSignatures["java/lang/reflect/Field.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Field, [1] = the Class of the annotation
+			GFunction:  fieldGetAnnotation,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/reflect/Method.getAnnotation(Class annotationClass) returns the method's
+// runtime-visible annotation of the given type, or null if it has none.
+func methodGetAnnotation(params []interface{}) interface{} {
+	m, ok := objectAt(params[0].(int64)).(*MethodObject)
+	if !ok {
+		return throwNullPointerException("java.lang.reflect.Method.getAnnotation()")
+	}
+
+	k := loadedClass(m.Class)
+	if k == nil {
+		return int64(0)
+	}
+	for _, meth := range k.Methods {
+		if k.CP.Utf8Refs[meth.Name] == m.Name && k.CP.Utf8Refs[meth.Desc] == m.Desc {
+			return getAnnotation(meth.VisibleAnnotations, params[1].(int64))
+		}
+	}
+	return int64(0)
+}
+
+// java/lang/reflect/Field.getAnnotation(Class annotationClass) returns the field's
+// runtime-visible annotation of the given type, or null if it has none.
+func fieldGetAnnotation(params []interface{}) interface{} {
+	f, ok := objectAt(params[0].(int64)).(*FieldObject)
+	if !ok {
+		return throwNullPointerException("java.lang.reflect.Field.getAnnotation()")
+	}
+
+	k := loadedClass(f.Class)
+	if k == nil {
+		return int64(0)
+	}
+	for _, field := range k.Fields {
+		if k.CP.Utf8Refs[field.Name] == f.Name {
+			return getAnnotation(field.VisibleAnnotations, params[1].(int64))
+		}
+	}
+	return int64(0)
+}
+
+// returns the data of the named class if it's been loaded, or nil
+func loadedClass(name string) *ClData {
+	k, present := LookupClass(name)
+	if !present {
+		return nil
+	}
+	return k.Data
+}
+
+// getAnnotation is the common logic of the getAnnotation() methods. It returns a
+// proxy for the annotation among annotations whose type is the class at typeAddr,
+// or null if there's no such annotation.
+func getAnnotation(annotations []Annotation, typeAddr int64) interface{} {
+	annotationType, ok := objectAt(typeAddr).(*ClassObject)
+	if !ok {
+		return throwNullPointerException("getAnnotation()")
+	}
+
+	for _, a := range annotations {
+		if a.Type == annotationType.Name {
+			return newAnnotationProxy(a)
+		}
+	}
+	return int64(0) // null
+}
+
+// creates the proxy for an annotation and returns its address. The first time a proxy
+// is created for an annotation type, the Go methods that implement the annotation
+// interface are added to the MTable.
+func newAnnotationProxy(a Annotation) int64 {
+	addMethodsForAnnotationType(a)
+	return addObject(&AnnotationObject{Annotation: a})
+}
+
+func addMethodsForAnnotationType(a Annotation) {
+	_, present := FetchMTableEntry(a.Type + ".annotationType()Ljava/lang/Class;")
+	if present {
+		return
+	}
+
+	methods := map[string]GMeth{
+		a.Type + ".annotationType()Ljava/lang/Class;": {
+			ParamSlots: 1, // [0] = the annotation
+			GFunction:  annotationType,
+		},
+	}
+	for _, elem := range a.Elements {
+		methType := elementMethodType(elem)
+		if methType == "" {
+			continue
+		}
+		methods[a.Type+"."+elem.Name+methType] = GMeth{
+			ParamSlots: 1, // [0] = the annotation
+			GFunction:  annotationElementGetter(elem.Name),
+		}
+	}
+
+	// if the annotation interface can be loaded, each of its elements has a method,
+	// including those that this annotation leaves to their default values
+	if k := annotationInterface(a.Type); k != nil {
+		for _, m := range k.Methods {
+			name := k.CP.Utf8Refs[m.Name]
+			methods[a.Type+"."+name+k.CP.Utf8Refs[m.Desc]] = GMeth{
+				ParamSlots: 1, // [0] = the annotation
+				GFunction:  annotationElementGetter(name),
+			}
+		}
+	}
+	loadlib(&MTable, methods)
+}
+
+// returns the data of the named annotation interface, loading it if need be, or nil
+// if it can't be loaded
+func annotationInterface(name string) *ClData {
+	if err := LoadClassFromNameOnly(name); err != nil {
+		return nil
+	}
+	return loadedClass(name)
+}
+
+// returns the default value of the named element of an annotation interface, as given
+// by the AnnotationDefault attribute of the element's method, or nil if it has none
+func annotationDefault(annotationType string, name string) *AnnotationElement {
+	k := annotationInterface(annotationType)
+	if k == nil {
+		return nil
+	}
+	for _, m := range k.Methods {
+		if k.CP.Utf8Refs[m.Name] == name {
+			return m.AnnotationDefault
+		}
+	}
+	return nil
+}
+
+// the annotationType() method of an annotation proxy returns the annotation interface
+func annotationType(params []interface{}) interface{} {
+	proxy, ok := objectAt(params[0].(int64)).(*AnnotationObject)
+	if !ok {
+		return throwNullPointerException("java.lang.annotation.Annotation.annotationType()")
+	}
+	return NewClassObject(proxy.Annotation.Type)
+}
+
+// returns the Go method that implements the method of an annotation proxy that
+// returns the value of the named element
+func annotationElementGetter(name string) func([]interface{}) interface{} {
+	return func(params []interface{}) interface{} {
+		proxy, ok := objectAt(params[0].(int64)).(*AnnotationObject)
+		if !ok {
+			return throwNullPointerException("java.lang.annotation.Annotation." + name + "()")
+		}
+		for _, elem := range proxy.Annotation.Elements {
+			if elem.Name == name {
+				return elementStackValue(elem)
+			}
+		}
+		if elem := annotationDefault(proxy.Annotation.Type, name); elem != nil {
+			return elementStackValue(*elem)
+		}
+		return int64(0)
+	}
+}
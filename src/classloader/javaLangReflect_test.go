@@ -0,0 +1,181 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// posts a class equivalent to:
+//
+//	@Version(3)
+//	class com.example.Annotated {
+//	    @Deprecated void oldMethod() {}
+//	}
+func postAnnotatedClass() {
+	cp := CPool{
+		CpIndex: []CpEntry{
+			{Type: Dummy, Slot: 0},
+			{Type: UTF8, Slot: 0},
+			{Type: UTF8, Slot: 1},
+			{Type: UTF8, Slot: 2},
+			{Type: UTF8, Slot: 3},
+			{Type: UTF8, Slot: 4},
+			{Type: UTF8, Slot: 5},
+			{Type: IntConst, Slot: 0},
+		},
+		Utf8Refs: []string{"RuntimeVisibleAnnotations", "Ljava/lang/Deprecated;",
+			"oldMethod", "()V", "Lcom/example/Version;", "value"},
+		IntConsts: []int32{3},
+	}
+
+	deprecated := []byte{0, 1, 0, 2, 0, 0}               // 1 annotation: type #2, no elements
+	version := []byte{0, 1, 0, 5, 0, 1, 0, 6, 'I', 0, 7} // 1 annotation: type #5, value = #7
+	k := ClData{
+		Name: "com/example/Annotated",
+		Methods: []Method{{
+			Name: 2,
+			Desc: 3,
+			Attributes: []Attr{
+				{AttrName: 0, AttrSize: len(deprecated), AttrContent: deprecated},
+			},
+		}},
+		Attributes: []Attr{{AttrName: 0, AttrSize: len(version), AttrContent: version}},
+		CP:         cp,
+	}
+	_ = parseAnnotationAttributes(&k)
+	_ = insert(k.Name, Klass{Status: 'F', Loader: "app", Data: &k})
+}
+
+func TestMethodGetAnnotation(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	postAnnotatedClass()
+
+	method := NewMethodObject("com/example/Annotated", "oldMethod", "()V")
+	ret := methodGetAnnotation([]interface{}{method, NewClassObject("java/lang/Deprecated")})
+	proxy, ok := objectAt(ret.(int64)).(*AnnotationObject)
+	if !ok {
+		t.Fatalf("Expected getAnnotation(Deprecated.class) to return an annotation, got: %v", ret)
+	}
+	if proxy.Annotation.Type != "java/lang/Deprecated" {
+		t.Errorf("Expected annotation of type java/lang/Deprecated, got: %s", proxy.Annotation.Type)
+	}
+
+	ret = methodGetAnnotation([]interface{}{method, NewClassObject("java/lang/SuppressWarnings")})
+	if ret != int64(0) {
+		t.Errorf("Expected getAnnotation(SuppressWarnings.class) to return null, got: %v", ret)
+	}
+}
+
+func TestClassGetAnnotationElementValue(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	MTableLoadNatives()
+	postAnnotatedClass()
+
+	class := NewClassObject("com/example/Annotated")
+	ret := classGetAnnotation([]interface{}{class, NewClassObject("com/example/Version")})
+	if _, ok := objectAt(ret.(int64)).(*AnnotationObject); !ok {
+		t.Fatalf("Expected getAnnotation(Version.class) to return an annotation, got: %v", ret)
+	}
+
+	// the proxy's value() method returns the value of the annotation's element
+	entry, ok := MTable["com/example/Version.value()I"]
+	if !ok {
+		t.Fatalf("Expected com/example/Version.value()I to be in the MTable")
+	}
+	value := entry.Meth.(GmEntry).Fu([]interface{}{ret})
+	if value != int64(3) {
+		t.Errorf("Expected value() to return 3, got: %v", value)
+	}
+
+	entry = MTable["com/example/Version.annotationType()Ljava/lang/Class;"]
+	annType := objectAt(entry.Meth.(GmEntry).Fu([]interface{}{ret}).(int64)).(*ClassObject)
+	if annType.Name != "com/example/Version" {
+		t.Errorf("Expected annotationType() to be com/example/Version, got: %s", annType.Name)
+	}
+}
+
+func TestGetAnnotationOfUnannotatedField(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	postAnnotatedClass()
+
+	field := NewFieldObject("com/example/Annotated", "missing")
+	ret := fieldGetAnnotation([]interface{}{field, NewClassObject("java/lang/Deprecated")})
+	if ret != int64(0) {
+		t.Errorf("Expected getAnnotation() on a field without annotations to return null, got: %v", ret)
+	}
+}
+
+// posts the annotation interface com/example/Version, equivalent to:
+//
+//	@interface Version {
+//	    int value() default 1;
+//	    int level() default 7;
+//	}
+func postVersionAnnotation() {
+	cp := CPool{
+		CpIndex: []CpEntry{
+			{Type: Dummy, Slot: 0},
+			{Type: UTF8, Slot: 0},
+			{Type: UTF8, Slot: 1},
+			{Type: UTF8, Slot: 2},
+			{Type: UTF8, Slot: 3},
+			{Type: IntConst, Slot: 0},
+			{Type: IntConst, Slot: 1},
+		},
+		Utf8Refs:  []string{"AnnotationDefault", "value", "()I", "level"},
+		IntConsts: []int32{1, 7},
+	}
+
+	valueDefault := []byte{'I', 0, 5}
+	levelDefault := []byte{'I', 0, 6}
+	k := ClData{
+		Name: "com/example/Version",
+		Methods: []Method{
+			{Name: 1, Desc: 2, Attributes: []Attr{
+				{AttrName: 0, AttrSize: len(valueDefault), AttrContent: valueDefault}}},
+			{Name: 3, Desc: 2, Attributes: []Attr{
+				{AttrName: 0, AttrSize: len(levelDefault), AttrContent: levelDefault}}},
+		},
+		CP: cp,
+	}
+	_ = parseAnnotationAttributes(&k)
+	_ = insert(k.Name, Klass{Status: 'F', Loader: "app", Data: &k})
+}
+
+// an element that the annotation doesn't give a value returns its default value
+func TestAnnotationElementDefaultValue(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+	MTable = make(map[string]MTentry) // so the proxy's methods are added anew
+	MTableLoadNatives()
+	postVersionAnnotation()
+	postAnnotatedClass()
+
+	class := NewClassObject("com/example/Annotated")
+	ret := classGetAnnotation([]interface{}{class, NewClassObject("com/example/Version")})
+
+	for method, expected := range map[string]int64{"value()I": 3, "level()I": 7} {
+		entry, ok := FetchMTableEntry("com/example/Version." + method)
+		if !ok {
+			t.Errorf("Expected com/example/Version.%s to be in the MTable", method)
+			continue
+		}
+		if value := entry.Meth.(GmEntry).Fu([]interface{}{ret}); value != expected {
+			t.Errorf("Expected %s to return %d, got: %v", method, expected, value)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/exceptions"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+/*
+ Go methods for java.lang.Runtime and java.lang.Process. Runtime.exec() starts the
+ subprocess using Go's os/exec package; the returned Process is backed by the
+ exec.Cmd. The subprocess's stdout is available via Process.getInputStream().
+*/
+
+// RuntimeObject is the Go representation of the singleton java.lang.Runtime
+type RuntimeObject struct {
+	processes []int64 // the Processes started by exec() that are still running
+}
+
+// ProcessObject is the Go representation of a java.lang.Process started by Runtime.exec()
+type ProcessObject struct {
+	cmd      *exec.Cmd
+	stdout   int64         // address of the InputStream that reads the process's stdout
+	done     chan struct{} // closed when the process has exited
+	exitCode int
+}
+
+// SecurityChecker is implemented by a security manager. CheckExec returns an error if
+// the given command may not be executed.
+type SecurityChecker interface {
+	CheckExec(cmd string) error
+}
+
+// SecurityManager is the installed security manager, if any. By default, none is
+// installed and so all commands can be executed.
+var SecurityManager SecurityChecker
+
+var theRuntime = addObject(&RuntimeObject{})
+
+func Load_Lang_Runtime() map[string]GMeth {
+
+	MethodSignatures["java/lang/Runtime.getRuntime()Ljava/lang/Runtime;"] =
+		GMeth{
+			ParamSlots: 0,
+			GFunction:  runtimeGetRuntime,
+		}
+
+	MethodSignatures["java/lang/Runtime.exec(Ljava/lang/String;)Ljava/lang/Process;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Runtime, [1] = the command
+			GFunction:  runtimeExec,
+		}
+
+	MethodSignatures["java/lang/Process.waitFor()I"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  processWaitFor,
+		}
+
+	MethodSignatures["java/lang/Process.exitValue()I"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  processExitValue,
+		}
+
+	MethodSignatures["java/lang/Process.getInputStream()Ljava/io/InputStream;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  processGetInputStream,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Runtime.getRuntime() returns the single Runtime instance
+func runtimeGetRuntime([]interface{}) interface{} {
+	return theRuntime
+}
+
+// java/lang/Runtime.exec(String command). The command is split into the program and its
+// arguments at whitespace, except for whitespace inside single or double quotes.
+func runtimeExec(params []interface{}) interface{} {
+	if params[1].(int64) == 0 {
+		msg := "java.lang.NullPointerException: in java.lang.Runtime.exec()"
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return errors.New(msg)
+	}
+	command := GoStringFromAddr(params[1].(int64))
+
+	args := splitCommand(command)
+	if len(args) == 0 {
+		msg := "java.lang.IllegalArgumentException: Empty command"
+		exceptions.Throw(exceptions.IllegalArgumentException, msg)
+		return errors.New(msg)
+	}
+
+	if SecurityManager != nil {
+		if err := SecurityManager.CheckExec(args[0]); err != nil {
+			msg := "java.lang.SecurityException: " + err.Error()
+			exceptions.Throw(exceptions.SecurityException, msg)
+			return errors.New(msg)
+		}
+	}
+
+	// the subprocess writes directly into a pipe that's not closed when the process
+	// exits, so its output can still be read after waitFor() returns
+	r, w, err := os.Pipe()
+	if err != nil {
+		return throwIOException(command, err)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = w
+	if err = cmd.Start(); err != nil {
+		_ = r.Close()
+		_ = w.Close()
+		return throwIOException(command, err)
+	}
+	_ = w.Close() // the subprocess has its own copy
+
+	process := &ProcessObject{
+		cmd:    cmd,
+		stdout: NewInputStreamObject(r),
+		done:   make(chan struct{}),
+	}
+	addr := addObject(process)
+
+	rt := objectAt(theRuntime).(*RuntimeObject)
+	rt.addProcess(addr)
+	go process.reap(rt, addr)
+	return addr
+}
+
+// records a running process in the Runtime, which keeps the process from being
+// collected (see CollectObjects()) until it exits. The Runtime's processes are
+// guarded by the objects table's mutex, under which CollectObjects() reads them.
+func (rt *RuntimeObject) addProcess(addr int64) {
+	liveObjectsMutex.Lock()
+	rt.processes = append(rt.processes, addr)
+	liveObjectsMutex.Unlock()
+}
+
+// removes a process that has exited from the Runtime's running processes
+func (rt *RuntimeObject) removeProcess(addr int64) {
+	liveObjectsMutex.Lock()
+	defer liveObjectsMutex.Unlock()
+	for i, process := range rt.processes {
+		if process == addr {
+			rt.processes = append(rt.processes[:i], rt.processes[i+1:]...)
+			return
+		}
+	}
+}
+
+func throwIOException(command string, err error) error {
+	msg := "java.io.IOException: Cannot run program \"" + command + "\": " + err.Error()
+	exceptions.Throw(exceptions.IOException, msg)
+	return errors.New(msg)
+}
+
+// splits a command line into its words. Quotes (single or double) group words
+// containing whitespace into a single word; the quotes themselves are removed.
+func splitCommand(command string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune // the open quote character, 0 if not inside quotes
+
+	for _, c := range command {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+func getProcess(addr interface{}, method string) (*ProcessObject, error) {
+	process, ok := objectAt(addr.(int64)).(*ProcessObject)
+	if !ok {
+		msg := "java.lang.NullPointerException: in java.lang.Process." + method
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return nil, errors.New(msg)
+	}
+	return process, nil
+}
+
+// runs in its own goroutine, started by exec(), to wait for the process to exit. It then
+// records the exit code, removes the process from the Runtime's running processes, and
+// marks it as done.
+func (p *ProcessObject) reap(rt *RuntimeObject, addr int64) {
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		p.exitCode = 0
+	case errors.As(err, &exitErr):
+		p.exitCode = exitErr.ExitCode()
+	default:
+		p.exitCode = -1
+	}
+	rt.removeProcess(addr)
+	close(p.done)
+}
+
+// java/lang/Process.waitFor() waits for the process to exit and returns its exit code
+func processWaitFor(params []interface{}) interface{} {
+	process, err := getProcess(params[0], "waitFor()")
+	if err != nil {
+		return err
+	}
+	<-process.done
+	return int64(process.exitCode)
+}
+
+// java/lang/Process.exitValue() returns the exit code of a process that has exited.
+// If the process is still running, it throws an IllegalThreadStateException.
+func processExitValue(params []interface{}) interface{} {
+	process, err := getProcess(params[0], "exitValue()")
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-process.done:
+		return int64(process.exitCode)
+	default:
+		msg := "java.lang.IllegalThreadStateException: process hasn't exited"
+		exceptions.Throw(exceptions.IllegalThreadStateException, msg)
+		return errors.New(msg)
+	}
+}
+
+// java/lang/Process.getInputStream() returns the stream that reads the process's stdout
+func processGetInputStream(params []interface{}) interface{} {
+	process, err := getProcess(params[0], "getInputStream()")
+	if err != nil {
+		return err
+	}
+	return process.stdout
+}
@@ -0,0 +1,125 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		"echo hello":                 {"echo", "hello"},
+		"  ls   -l\t/tmp ":           {"ls", "-l", "/tmp"},
+		`echo "hello world"`:         {"echo", "hello world"},
+		`grep 'a b' "c d" e`:         {"grep", "a b", "c d", "e"},
+		`echo ""`:                    {"echo", ""},
+		`echo "it's"`:                {"echo", "it's"},
+		"":                           nil,
+		"   ":                        nil,
+		`say pre"quoted part"post x`: {"say", "prequoted partpost", "x"},
+	}
+
+	for command, expected := range tests {
+		words := splitCommand(command)
+		if !reflect.DeepEqual(words, expected) {
+			t.Errorf("splitCommand(%q): expected %q, got %q", command, expected, words)
+		}
+	}
+}
+
+func TestRuntimeExecEcho(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is not an executable on Windows")
+	}
+
+	rt := runtimeGetRuntime(nil)
+	ret := runtimeExec([]interface{}{rt, NewStringObject("echo hello")})
+	process, ok := ret.(int64)
+	if !ok {
+		t.Fatalf("Expected exec() to return a Process, got: %v", ret)
+	}
+
+	stream := processGetInputStream([]interface{}{process})
+	var output []byte
+	for {
+		b := inputStreamRead([]interface{}{stream}).(int64)
+		if b == -1 {
+			break
+		}
+		output = append(output, byte(b))
+	}
+
+	if string(output) != "hello\n" {
+		t.Errorf("Expected process output to be \"hello\\n\", got: %q", string(output))
+	}
+
+	if exitCode := processWaitFor([]interface{}{process}); exitCode != int64(0) {
+		t.Errorf("Expected waitFor() to return 0, got: %v", exitCode)
+	}
+	if exitCode := processExitValue([]interface{}{process}); exitCode != int64(0) {
+		t.Errorf("Expected exitValue() to return 0, got: %v", exitCode)
+	}
+}
+
+type denyAllSecurityManager struct{}
+
+func (denyAllSecurityManager) CheckExec(cmd string) error {
+	return errors.New("exec of " + cmd + " denied")
+}
+
+func TestRuntimeExecDeniedBySecurityManager(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	SecurityManager = denyAllSecurityManager{}
+	defer func() { SecurityManager = nil }()
+
+	normalStderr := os.Stderr
+	devNull, _ := os.Open(os.DevNull)
+	os.Stderr = devNull
+	ret := runtimeExec([]interface{}{theRuntime, NewStringObject("echo hello")})
+	os.Stderr = normalStderr
+	_ = devNull.Close()
+
+	if _, isErr := ret.(error); !isErr {
+		t.Errorf("Expected a SecurityException when the security manager denies exec, got: %v", ret)
+	}
+}
+
+// exitValue() returns the exit code of a process that has exited, even if waitFor()
+// was never called, and the Runtime no longer holds the process once it has exited
+func TestRuntimeExecExitValueWithoutWaitFor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("true is not an executable on Windows")
+	}
+
+	rt := runtimeGetRuntime(nil)
+	process, ok := runtimeExec([]interface{}{rt, NewStringObject("true")}).(int64)
+	if !ok {
+		t.Fatal("Expected exec() to return a Process")
+	}
+
+	<-objectAt(process).(*ProcessObject).done
+	if exitCode := processExitValue([]interface{}{process}); exitCode != int64(0) {
+		t.Errorf("Expected exitValue() to return 0, got: %v", exitCode)
+	}
+
+	rt = objectAt(theRuntime)
+	liveObjectsMutex.RLock()
+	defer liveObjectsMutex.RUnlock()
+	for _, running := range rt.(*RuntimeObject).processes {
+		if running == process {
+			t.Error("Expected the process to be removed from the Runtime once it exited")
+		}
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/exceptions"
+	"unicode"
+	"unicode/utf16"
+)
+
+/*
+ Go methods for java.lang.String. As with the other libraries of Go methods, the
+ functions here are loaded into the MTable via MethodSignatures. Strings are passed
+ to and from these functions as 64-bit addresses. The address is either that of a
+ String constant in the CP (which is the address of a Go string) or that of a
+ StringObject created by a Go method.
+
+ For instance methods, the first entry in the passed-in slice is the address of the
+ String the method is called on; the remaining entries are the method's arguments.
+*/
+
+// StringObject is the Go representation of an instance of java.lang.String. Its only
+// field is the string itself, so the address of a StringObject is also the address of
+// a Go string. This lets String constants and StringObjects be handled the same way.
+type StringObject struct {
+	Value string
+}
+
+func Load_Lang_String() map[string]GMeth {
+
+	MethodSignatures["java/lang/String.substring(I)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the string, [1] = begin index
+			GFunction:  stringSubstringToEnd,
+		}
+
+	MethodSignatures["java/lang/String.substring(II)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 3, // [0] = the string, [1] = begin index, [2] = end index
+			GFunction:  stringSubstring,
+		}
+
+	MethodSignatures["java/lang/String.indexOf(Ljava/lang/String;)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the string, [1] = the string to search for
+			GFunction:  stringIndexOf,
+		}
+
+	MethodSignatures["java/lang/String.contains(Ljava/lang/CharSequence;)Z"] =
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  stringContains,
+		}
+
+	MethodSignatures["java/lang/String.startsWith(Ljava/lang/String;)Z"] =
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  stringStartsWith,
+		}
+
+	MethodSignatures["java/lang/String.endsWith(Ljava/lang/String;)Z"] =
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  stringEndsWith,
+		}
+
+	MethodSignatures["java/lang/String.compareTo(Ljava/lang/String;)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the string, [1] = the string to compare it to
+			GFunction:  stringCompareTo,
+		}
+
+	MethodSignatures["java/lang/String.compareTo(Ljava/lang/Object;)I"] = // Comparable.compareTo()
+		GMeth{
+			ParamSlots: 2, // [0] = the string, [1] = the string to compare it to
+			GFunction:  stringCompareTo,
+		}
+
+	MethodSignatures["java/lang/String.compareToIgnoreCase(Ljava/lang/String;)I"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the string, [1] = the string to compare it to
+			GFunction:  stringCompareToIgnoreCase,
+		}
+
+	MethodSignatures["java/lang/String.toCharArray()[C"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  stringToCharArray,
+		}
+
+	MethodSignatures["java/lang/String.<init>([C)V"] = // new String(char[])
+		GMeth{
+			ParamSlots: 2, // [0] = the new String, [1] = the char array
+			GFunction:  stringInitFromChars,
+		}
+
+	MethodSignatures["java/lang/String.valueOf([C)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  stringValueOfChars,
+		}
+
+	return MethodSignatures
+}
+
+// NewStringObject creates a java.lang.String holding s and returns its address.
+func NewStringObject(s string) int64 {
+	return addObject(&StringObject{Value: s})
+}
+
+// GoStringFromAddr returns the Go string held by the String at addr, or an empty
+// string if addr isn't the address of a String.
+func GoStringFromAddr(addr int64) string {
+	if str, ok := objectAt(addr).(*StringObject); ok {
+		return str.Value
+	}
+	return ""
+}
+
+// Java strings are sequences of 16-bit UTF-16 chars and all indexes into strings
+// count those chars, rather than bytes. So Go strings are converted to UTF-16 chars
+// before indexing into them.
+func javaChars(s string) []uint16 {
+	return utf16.Encode([]rune(s))
+}
+
+func goStringFromChars(chars []uint16) string {
+	return string(utf16.Decode(chars))
+}
+
+// Go methods signal an exception by logging it via exceptions.Throw() and
+// returning the error, which halts execution of the calling Java method.
+// method is the qualified name of the method, e.g., java.lang.String.substring()
+func throwNullPointerException(method string) error {
+	msg := "java.lang.NullPointerException: in " + method
+	exceptions.Throw(exceptions.NullPointerException, msg)
+	return errors.New(msg)
+}
+
+// java/lang/String.substring(int beginIndex, int endIndex). The returned String is a new
+// string: it does not share the storage of the original string.
+func stringSubstring(params []interface{}) interface{} {
+	if params[0].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.substring()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	begin := params[1].(int64)
+	end := params[2].(int64)
+
+	if begin < 0 || end > int64(len(chars)) || begin > end {
+		msg := fmt.Sprintf("java.lang.StringIndexOutOfBoundsException: begin %d, end %d, length %d",
+			begin, end, len(chars))
+		exceptions.Throw(exceptions.StringIndexOutOfBoundsException, msg)
+		return errors.New(msg)
+	}
+	return NewStringObject(goStringFromChars(chars[begin:end]))
+}
+
+// java/lang/String.substring(int beginIndex), which returns the rest of the string
+func stringSubstringToEnd(params []interface{}) interface{} {
+	if params[0].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.substring()")
+	}
+	length := int64(len(javaChars(GoStringFromAddr(params[0].(int64)))))
+	return stringSubstring([]interface{}{params[0], params[1], length})
+}
+
+// java/lang/String.indexOf(String str) returns the index of the first occurrence
+// of str, or -1 if str does not occur in the string. This is a simple scan; a
+// faster search (Boyer-Moore, for example) can come later.
+func stringIndexOf(params []interface{}) interface{} {
+	if params[0].(int64) == 0 || params[1].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.indexOf()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	target := javaChars(GoStringFromAddr(params[1].(int64)))
+
+	for i := 0; i+len(target) <= len(chars); i++ {
+		j := 0
+		for j < len(target) && chars[i+j] == target[j] {
+			j++
+		}
+		if j == len(target) {
+			return int64(i)
+		}
+	}
+	return int64(-1)
+}
+
+// java/lang/String.contains(CharSequence s)
+func stringContains(params []interface{}) interface{} {
+	index := stringIndexOf(params)
+	if err, isErr := index.(error); isErr {
+		return err
+	}
+	return javaBoolean(index.(int64) >= 0)
+}
+
+// java/lang/String.startsWith(String prefix)
+func stringStartsWith(params []interface{}) interface{} {
+	if params[0].(int64) == 0 || params[1].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.startsWith()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	prefix := javaChars(GoStringFromAddr(params[1].(int64)))
+
+	if len(prefix) > len(chars) {
+		return javaBoolean(false)
+	}
+	return javaBoolean(goStringFromChars(chars[:len(prefix)]) == goStringFromChars(prefix))
+}
+
+// java/lang/String.endsWith(String suffix)
+func stringEndsWith(params []interface{}) interface{} {
+	if params[0].(int64) == 0 || params[1].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.endsWith()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	suffix := javaChars(GoStringFromAddr(params[1].(int64)))
+
+	if len(suffix) > len(chars) {
+		return javaBoolean(false)
+	}
+	return javaBoolean(goStringFromChars(chars[len(chars)-len(suffix):]) == goStringFromChars(suffix))
+}
+
+// java/lang/String.compareTo(String anotherString) compares the strings' UTF-16 chars.
+// As in Java, the result is the difference between the first pair of chars that
+// differ or, if one string is a prefix of the other, the difference in their lengths.
+func stringCompareTo(params []interface{}) interface{} {
+	if params[0].(int64) == 0 || params[1].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.compareTo()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	other := javaChars(GoStringFromAddr(params[1].(int64)))
+	return compareChars(chars, other, func(c uint16) uint16 { return c })
+}
+
+// java/lang/String.compareToIgnoreCase(String str) is compareTo() after each char is
+// converted to upper case and then to lower case, which is how Java folds case
+// (Character.toLowerCase(Character.toUpperCase(c))).
+func stringCompareToIgnoreCase(params []interface{}) interface{} {
+	if params[0].(int64) == 0 || params[1].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.compareToIgnoreCase()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+	other := javaChars(GoStringFromAddr(params[1].(int64)))
+	return compareChars(chars, other, func(c uint16) uint16 {
+		if utf16.IsSurrogate(rune(c)) {
+			return c
+		}
+		return uint16(unicode.ToLower(unicode.ToUpper(rune(c))))
+	})
+}
+
+// compares two strings of chars, after applying fold to each char
+func compareChars(chars, other []uint16, fold func(uint16) uint16) int64 {
+	for i := 0; i < len(chars) && i < len(other); i++ {
+		c1, c2 := fold(chars[i]), fold(other[i])
+		if c1 != c2 {
+			return int64(c1) - int64(c2)
+		}
+	}
+	return int64(len(chars) - len(other))
+}
+
+// booleans are passed on the operand stack as int64s: 1 = true, 0 = false
+func javaBoolean(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// java/lang/String.toCharArray() returns a new char[] holding the string's UTF-16 chars.
+// Characters above U+FFFF become two chars (a surrogate pair).
+func stringToCharArray(params []interface{}) interface{} {
+	if params[0].(int64) == 0 {
+		return throwNullPointerException("java.lang.String.toCharArray()")
+	}
+	chars := javaChars(GoStringFromAddr(params[0].(int64)))
+
+	arrAddr := NewArrayObject(T_CHAR, len(chars))
+	arr := objectAt(arrAddr).(*ArrayObject)
+	for i, c := range chars {
+		arr.Elements[i] = int64(c)
+	}
+	return arrAddr
+}
+
+// converts a char[] into a Go string, joining any surrogate pairs into a single character
+func stringFromCharArray(arrAddr int64) (string, error) {
+	arr, ok := objectAt(arrAddr).(*ArrayObject)
+	if !ok || arr.Type != T_CHAR {
+		return "", throwNullPointerException("java.lang.String.String(char[])")
+	}
+
+	chars := make([]uint16, len(arr.Elements))
+	for i, c := range arr.Elements {
+		chars[i] = uint16(c)
+	}
+	return goStringFromChars(chars), nil
+}
+
+// the constructor String(char[] value). The chars are copied into the new String, so
+// later changes to the array do not affect the String. The new bytecode creates the
+// String as an empty StringObject (see NewGoObject()), whose value is set here.
+func stringInitFromChars(params []interface{}) interface{} {
+	str, ok := objectAt(params[0].(int64)).(*StringObject)
+	if !ok {
+		return throwNullPointerException("java.lang.String.<init>()")
+	}
+
+	value, err := stringFromCharArray(params[1].(int64))
+	if err != nil {
+		return err
+	}
+	str.Value = value
+	return nil
+}
+
+// java/lang/String.valueOf(char[] data), the static equivalent of new String(char[])
+func stringValueOfChars(params []interface{}) interface{} {
+	value, err := stringFromCharArray(params[0].(int64))
+	if err != nil {
+		return err
+	}
+	return NewStringObject(value)
+}
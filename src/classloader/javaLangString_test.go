@@ -0,0 +1,253 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGoStringFromAddrWithStringConstant(t *testing.T) {
+	constant := "a string in the CP"
+	addr := StringConstant(constant)
+	if GoStringFromAddr(addr) != constant {
+		t.Errorf("Expected '%s', got: '%s'", constant, GoStringFromAddr(addr))
+	}
+
+	// string constants are interned and survive collections
+	if StringConstant(constant) != addr {
+		t.Error("Expected the same String for the same string constant")
+	}
+	CollectObjects(nil)
+	if GoStringFromAddr(addr) != constant {
+		t.Errorf("Expected '%s' after a collection, got: '%s'", constant, GoStringFromAddr(addr))
+	}
+
+	// an address that isn't a String yields an empty string, rather than a crash
+	if GoStringFromAddr(12345) != "" {
+		t.Errorf("Expected an empty string for a non-String address, got: '%s'", GoStringFromAddr(12345))
+	}
+}
+
+func TestSubstring(t *testing.T) {
+	str := NewStringObject("hello, world")
+
+	tests := []struct {
+		begin, end int64
+		expected   string
+	}{
+		{0, 5, "hello"},
+		{7, 12, "world"},
+		{3, 3, ""},
+		{0, 12, "hello, world"},
+	}
+
+	for _, test := range tests {
+		ret := stringSubstring([]interface{}{str, test.begin, test.end})
+		addr, ok := ret.(int64)
+		if !ok {
+			t.Errorf("substring(%d, %d): expected a String, got: %v", test.begin, test.end, ret)
+			continue
+		}
+		if GoStringFromAddr(addr) != test.expected {
+			t.Errorf("substring(%d, %d): expected '%s', got: '%s'",
+				test.begin, test.end, test.expected, GoStringFromAddr(addr))
+		}
+	}
+
+	// substring() always returns a new String, even when it's the whole string
+	ret := stringSubstring([]interface{}{str, int64(0), int64(12)})
+	if ret.(int64) == str {
+		t.Error("Expected substring() to return a new String, but got the original")
+	}
+
+	ret = stringSubstringToEnd([]interface{}{str, int64(7)})
+	if GoStringFromAddr(ret.(int64)) != "world" {
+		t.Errorf("substring(7): expected 'world', got: '%s'", GoStringFromAddr(ret.(int64)))
+	}
+}
+
+func TestSubstringWithMultibyteChars(t *testing.T) {
+	str := NewStringObject("naïve café")
+	ret := stringSubstring([]interface{}{str, int64(6), int64(10)})
+	if GoStringFromAddr(ret.(int64)) != "café" {
+		t.Errorf("Expected 'café', got: '%s'", GoStringFromAddr(ret.(int64)))
+	}
+}
+
+func TestSubstringInvalidBounds(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	str := NewStringObject("hello")
+	invalid := [][2]int64{{-1, 3}, {0, 6}, {4, 2}}
+	for _, bounds := range invalid {
+		ret := stringSubstring([]interface{}{str, bounds[0], bounds[1]})
+		if _, isErr := ret.(error); !isErr {
+			t.Errorf("substring(%d, %d): expected an exception, got: %v", bounds[0], bounds[1], ret)
+		}
+	}
+
+	_ = w.Close()
+	msg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if !strings.Contains(string(msg), "java.lang.StringIndexOutOfBoundsException") {
+		t.Errorf("Expected StringIndexOutOfBoundsException, got: %s", string(msg))
+	}
+}
+
+func TestIndexOfAndContains(t *testing.T) {
+	str := NewStringObject("the quick brown fox")
+
+	tests := []struct {
+		target string
+		index  int64
+	}{
+		{"quick", 4},
+		{"the", 0},
+		{"fox", 16},
+		{"", 0},
+		{"slow", -1},
+		{"the quick brown fox jumps", -1},
+	}
+
+	for _, test := range tests {
+		target := NewStringObject(test.target)
+		index := stringIndexOf([]interface{}{str, target}).(int64)
+		if index != test.index {
+			t.Errorf("indexOf(\"%s\"): expected %d, got: %d", test.target, test.index, index)
+		}
+
+		contains := stringContains([]interface{}{str, target}).(int64)
+		if (contains == 1) != (test.index >= 0) {
+			t.Errorf("contains(\"%s\"): expected %t, got: %d", test.target, test.index >= 0, contains)
+		}
+	}
+}
+
+func TestStartsWithAndEndsWith(t *testing.T) {
+	str := NewStringObject("jacobin")
+
+	tests := []struct {
+		affix                string
+		startsWith, endsWith int64
+	}{
+		{"jac", 1, 0},
+		{"bin", 0, 1},
+		{"", 1, 1},
+		{"jacobin", 1, 1},
+		{"jacobins", 0, 0},
+		{"x", 0, 0},
+	}
+
+	for _, test := range tests {
+		affix := NewStringObject(test.affix)
+		if ret := stringStartsWith([]interface{}{str, affix}); ret != test.startsWith {
+			t.Errorf("startsWith(\"%s\"): expected %d, got: %v", test.affix, test.startsWith, ret)
+		}
+		if ret := stringEndsWith([]interface{}{str, affix}); ret != test.endsWith {
+			t.Errorf("endsWith(\"%s\"): expected %d, got: %v", test.affix, test.endsWith, ret)
+		}
+	}
+}
+
+func TestCompareToAndCompareToIgnoreCase(t *testing.T) {
+	tests := []struct {
+		s1, s2     string
+		compareTo  int64
+		ignoreCase int64
+	}{
+		{"abc", "abd", -1, -1},
+		{"abc", "abc", 0, 0},
+		{"b", "a", 1, 1},
+		{"ABC", "abc", 'A' - 'a', 0},
+		{"ABC", "ABD", -1, -1},
+		{"abc", "ab", 1, 1},
+		{"", "abc", -3, -3},
+	}
+
+	for _, test := range tests {
+		s1 := NewStringObject(test.s1)
+		s2 := NewStringObject(test.s2)
+		if ret := stringCompareTo([]interface{}{s1, s2}); ret != test.compareTo {
+			t.Errorf("\"%s\".compareTo(\"%s\"): expected %d, got: %v", test.s1, test.s2, test.compareTo, ret)
+		}
+		if ret := stringCompareToIgnoreCase([]interface{}{s1, s2}); ret != test.ignoreCase {
+			t.Errorf("\"%s\".compareToIgnoreCase(\"%s\"): expected %d, got: %v",
+				test.s1, test.s2, test.ignoreCase, ret)
+		}
+	}
+}
+
+func TestToCharArray(t *testing.T) {
+	str := NewStringObject("Hello")
+	arrAddr := stringToCharArray([]interface{}{str}).(int64)
+
+	arr, ok := objectAt(arrAddr).(*ArrayObject)
+	if !ok || arr.Type != T_CHAR {
+		t.Fatalf("Expected toCharArray() to return a char[], got: %v", objectAt(arrAddr))
+	}
+
+	if len(arr.Elements) != 5 {
+		t.Errorf("Expected char[] of length 5, got: %d", len(arr.Elements))
+	}
+
+	if arr.Elements[0] != 0x0048 {
+		t.Errorf("Expected first char to be 'H' (0x0048), got: %04X", arr.Elements[0])
+	}
+
+	// now rebuild the string, first via valueOf(char[]), then via new String(char[])
+	ret := stringValueOfChars([]interface{}{arrAddr})
+	if GoStringFromAddr(ret.(int64)) != "Hello" {
+		t.Errorf("Expected String.valueOf(char[]) to return 'Hello', got: '%s'",
+			GoStringFromAddr(ret.(int64)))
+	}
+
+	newStr := NewStringObject("")
+	if ret = stringInitFromChars([]interface{}{newStr, arrAddr}); ret != nil {
+		t.Errorf("Expected new String(char[]) to succeed, got: %v", ret)
+	}
+	if GoStringFromAddr(newStr) != "Hello" {
+		t.Errorf("Expected new String(char[]) to be 'Hello', got: '%s'", GoStringFromAddr(newStr))
+	}
+
+	// changing the array must not change the String
+	arr.Elements[0] = 'J'
+	if GoStringFromAddr(newStr) != "Hello" {
+		t.Errorf("Expected String to be unchanged by change to array, got: '%s'",
+			GoStringFromAddr(newStr))
+	}
+}
+
+func TestToCharArrayWithSurrogatePair(t *testing.T) {
+	str := NewStringObject("\U0001F600") // a character above U+FFFF
+	arrAddr := stringToCharArray([]interface{}{str}).(int64)
+	arr := objectAt(arrAddr).(*ArrayObject)
+
+	if len(arr.Elements) != 2 {
+		t.Fatalf("Expected a surrogate pair (2 chars), got %d chars", len(arr.Elements))
+	}
+
+	if arr.Elements[0] != 0xD83D || arr.Elements[1] != 0xDE00 {
+		t.Errorf("Expected chars D83D DE00, got: %04X %04X", arr.Elements[0], arr.Elements[1])
+	}
+
+	ret := stringValueOfChars([]interface{}{arrAddr})
+	if GoStringFromAddr(ret.(int64)) != "\U0001F600" {
+		t.Errorf("Expected surrogate pair to convert back to U+1F600, got: %q",
+			GoStringFromAddr(ret.(int64)))
+	}
+}
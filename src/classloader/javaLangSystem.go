@@ -7,6 +7,8 @@
 package classloader
 
 import (
+	"jacobin/shutdown"
+	"strconv"
 	"time"
 )
 
@@ -34,6 +36,12 @@ func Load_Lang_System() map[string]GMeth {
 			GFunction:  currentTimeMillis,
 		}
 
+	MethodSignatures["java/lang/System.exit(I)V"] = // exit the JVM with the given status
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  exit,
+		}
+
 	MethodSignatures["java/lang/System.nanoTime()J"] = // get nanoseconds time, returned as long
 		GMeth{
 			ParamSlots: 0,
@@ -53,3 +61,21 @@ func currentTimeMillis([]interface{}) interface{} {
 func nanoTime([]interface{}) interface{} {
 	return int64(time.Now().UnixNano())
 }
+
+// SystemExit is returned by System.exit() to stop the interpreter: the method that
+// called System.exit() must not continue. By the time it's returned, the shutdown
+// hooks have run and, except in test mode, the JVM has exited.
+type SystemExit struct {
+	Status int
+}
+
+func (e *SystemExit) Error() string {
+	return "System.exit(" + strconv.Itoa(e.Status) + ")"
+}
+
+// java/lang/System.exit(int status) shuts down the JVM, which exits with the given status
+func exit(params []interface{}) interface{} {
+	status := int(int32(params[0].(int64)))
+	shutdown.ExitWithStatus(status)
+	return &SystemExit{Status: status}
+}
@@ -0,0 +1,181 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/exceptions"
+	"strings"
+)
+
+/*
+ Go methods for java.lang.Throwable, which are inherited by all exceptions and errors.
+ The first entry in the passed-in slice is the address of the Throwable the method is
+ called on.
+*/
+
+// ThrowableObject is the Go representation of an instance of java.lang.Throwable or
+// any of its subclasses. Message and Cause are the addresses of the message String and
+// the cause Throwable, respectively; 0 is null. As in Java, the cause can be set only
+// once, either when the exception is constructed or via initCause().
+type ThrowableObject struct {
+	ClassName string // in java/lang/Object format
+	Message   int64
+	Cause     int64
+	causeSet  bool
+}
+
+func Load_Lang_Throwable() map[string]GMeth {
+
+	MethodSignatures["java/lang/Throwable.<init>()V"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  throwableInit,
+		}
+
+	MethodSignatures["java/lang/Throwable.<init>(Ljava/lang/String;)V"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Throwable, [1] = the message
+			GFunction:  throwableInitWithMessage,
+		}
+
+	MethodSignatures["java/lang/Throwable.getMessage()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  throwableGetMessage,
+		}
+
+	MethodSignatures["java/lang/Throwable.toString()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  throwableToString,
+		}
+
+	MethodSignatures["java/lang/Throwable.getCause()Ljava/lang/Throwable;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  throwableGetCause,
+		}
+
+	MethodSignatures["java/lang/Throwable.initCause(Ljava/lang/Throwable;)Ljava/lang/Throwable;"] =
+		GMeth{
+			ParamSlots: 2, // [0] = the Throwable, [1] = the cause
+			GFunction:  throwableInitCause,
+		}
+
+	return MethodSignatures
+}
+
+// NewThrowableObject creates an exception or error of the named class (in
+// java/lang/Object format) with a null message and returns its address.
+func NewThrowableObject(className string) int64 {
+	return addObject(&ThrowableObject{ClassName: className})
+}
+
+// NewThrowableWithMessage creates an exception or error of the named class (in
+// java/lang/Object format) with the given message and returns its address.
+func NewThrowableWithMessage(className, message string) int64 {
+	return addObject(&ThrowableObject{ClassName: className, Message: NewStringObject(message)})
+}
+
+// returns the ThrowableObject at addr, or an error (after throwing a NullPointerException)
+// if there is none.
+func getThrowable(addr interface{}, method string) (*ThrowableObject, error) {
+	throwable, ok := objectAt(addr.(int64)).(*ThrowableObject)
+	if !ok {
+		msg := "java.lang.NullPointerException: in java.lang.Throwable." + method
+		exceptions.Throw(exceptions.NullPointerException, msg)
+		return nil, errors.New(msg)
+	}
+	return throwable, nil
+}
+
+// ThrowableToString returns the string Throwable.toString() returns for the Throwable
+// at addr: the class name, followed by ": " and the message if the message is not null.
+// This is also what is shown for uncaught exceptions.
+func ThrowableToString(addr int64) string {
+	throwable, ok := objectAt(addr).(*ThrowableObject)
+	if !ok {
+		return "null"
+	}
+
+	className := strings.ReplaceAll(throwable.ClassName, "/", ".")
+	if throwable.Message == 0 {
+		return className
+	}
+	return className + ": " + GoStringFromAddr(throwable.Message)
+}
+
+// the constructor Throwable(), which leaves the message null
+func throwableInit(params []interface{}) interface{} {
+	if _, err := getThrowable(params[0], "<init>()"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// the constructor Throwable(String message)
+func throwableInitWithMessage(params []interface{}) interface{} {
+	throwable, err := getThrowable(params[0], "<init>()")
+	if err != nil {
+		return err
+	}
+	throwable.Message = params[1].(int64)
+	return nil
+}
+
+// java/lang/Throwable.getMessage() returns the message, which can be null
+func throwableGetMessage(params []interface{}) interface{} {
+	throwable, err := getThrowable(params[0], "getMessage()")
+	if err != nil {
+		return err
+	}
+	return throwable.Message
+}
+
+// java/lang/Throwable.toString(), see ThrowableToString()
+func throwableToString(params []interface{}) interface{} {
+	if _, err := getThrowable(params[0], "toString()"); err != nil {
+		return err
+	}
+	return NewStringObject(ThrowableToString(params[0].(int64)))
+}
+
+// java/lang/Throwable.getCause() returns the cause, or null if it's not been set
+func throwableGetCause(params []interface{}) interface{} {
+	throwable, err := getThrowable(params[0], "getCause()")
+	if err != nil {
+		return err
+	}
+	return throwable.Cause
+}
+
+// java/lang/Throwable.initCause(Throwable cause) sets the cause and returns the Throwable.
+// The cause can be set only once and a Throwable cannot be its own cause.
+func throwableInitCause(params []interface{}) interface{} {
+	throwable, err := getThrowable(params[0], "initCause()")
+	if err != nil {
+		return err
+	}
+
+	cause := params[1].(int64)
+	if throwable.causeSet {
+		msg := "java.lang.IllegalStateException: Can't overwrite cause of " +
+			ThrowableToString(params[0].(int64))
+		exceptions.Throw(exceptions.IllegalStateException, msg)
+		return errors.New(msg)
+	}
+	if cause == params[0].(int64) {
+		msg := "java.lang.IllegalArgumentException: Self-causation not permitted"
+		exceptions.Throw(exceptions.IllegalArgumentException, msg)
+		return errors.New(msg)
+	}
+
+	throwable.Cause = cause
+	throwable.causeSet = true
+	return params[0].(int64)
+}
@@ -0,0 +1,82 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"testing"
+)
+
+func TestThrowableMessageAndToString(t *testing.T) {
+	ex := NewThrowableObject("java/lang/RuntimeException")
+	throwableInitWithMessage([]interface{}{ex, NewStringObject("oops")})
+
+	msg := throwableGetMessage([]interface{}{ex}).(int64)
+	if GoStringFromAddr(msg) != "oops" {
+		t.Errorf("Expected getMessage() to return 'oops', got: '%s'", GoStringFromAddr(msg))
+	}
+
+	str := throwableToString([]interface{}{ex}).(int64)
+	if GoStringFromAddr(str) != "java.lang.RuntimeException: oops" {
+		t.Errorf("Expected toString() to return 'java.lang.RuntimeException: oops', got: '%s'",
+			GoStringFromAddr(str))
+	}
+}
+
+func TestThrowableToStringWithNullMessage(t *testing.T) {
+	ex := NewThrowableObject("java/lang/IllegalStateException")
+	throwableInit([]interface{}{ex})
+
+	if msg := throwableGetMessage([]interface{}{ex}).(int64); msg != 0 {
+		t.Errorf("Expected getMessage() to return null, got: %d", msg)
+	}
+
+	str := throwableToString([]interface{}{ex}).(int64)
+	if GoStringFromAddr(str) != "java.lang.IllegalStateException" {
+		t.Errorf("Expected toString() to return only the class name, got: '%s'", GoStringFromAddr(str))
+	}
+}
+
+func TestThrowableCause(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	ex := NewThrowableObject("java/lang/RuntimeException")
+	throwableInitWithMessage([]interface{}{ex, NewStringObject("oops")})
+
+	if cause := throwableGetCause([]interface{}{ex}).(int64); cause != 0 {
+		t.Errorf("Expected getCause() to return null before the cause is set, got: %d", cause)
+	}
+
+	cause := NewThrowableObject("java/io/IOException")
+	throwableInitWithMessage([]interface{}{cause, NewStringObject("disk full")})
+
+	if ret := throwableInitCause([]interface{}{ex, cause}); ret != ex {
+		t.Errorf("Expected initCause() to return the exception, got: %v", ret)
+	}
+
+	gotCause := throwableGetCause([]interface{}{ex}).(int64)
+	causeMsg := throwableGetMessage([]interface{}{gotCause}).(int64)
+	if GoStringFromAddr(causeMsg) != "disk full" {
+		t.Errorf("Expected getCause().getMessage() to return 'disk full', got: '%s'",
+			GoStringFromAddr(causeMsg))
+	}
+
+	// the cause can be set only once
+	normalStderr := os.Stderr
+	devNull, _ := os.Open(os.DevNull)
+	os.Stderr = devNull
+	ret := throwableInitCause([]interface{}{ex, NewThrowableObject("java/lang/Error")})
+	os.Stderr = normalStderr
+	_ = devNull.Close()
+
+	if _, isErr := ret.(error); !isErr {
+		t.Errorf("Expected an IllegalStateException setting the cause twice, got: %v", ret)
+	}
+}
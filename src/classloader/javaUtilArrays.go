@@ -0,0 +1,36 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "sort"
+
+// Go methods for java.util.Arrays. The sorts of arrays of objects, which need to call
+// the objects' compareTo() methods, are in the jvm package.
+
+func Load_Util_Arrays() map[string]GMeth {
+
+	for _, arrayType := range []string{"[I", "[J", "[S", "[C", "[B"} {
+		MethodSignatures["java/util/Arrays.sort("+arrayType+")V"] =
+			GMeth{
+				ParamSlots: 1, // [0] = the array
+				GFunction:  arraysSortIntegral,
+			}
+	}
+
+	return MethodSignatures
+}
+
+// java/util/Arrays.sort() for arrays of ints and the other integral types, whose
+// elements are all held as int64s, sorts the array into ascending order
+func arraysSortIntegral(params []interface{}) interface{} {
+	arr := ArrayAt(params[0].(int64))
+	if arr == nil {
+		return throwNullPointerException("java.util.Arrays.sort()")
+	}
+	sort.Slice(arr.Elements, func(i, j int) bool { return arr.Elements[i] < arr.Elements[j] })
+	return nil
+}
@@ -0,0 +1,35 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"testing"
+)
+
+func TestArraysSortInts(t *testing.T) {
+	addr := NewArrayObject(T_INT, 5)
+	arr := ArrayAt(addr)
+	copy(arr.Elements, []int64{5, 2, 8, 1, 9})
+
+	if ret := arraysSortIntegral([]interface{}{addr}); ret != nil {
+		t.Fatalf("Unexpected error sorting int[]: %v", ret)
+	}
+
+	expected := []int64{1, 2, 5, 8, 9}
+	for i := range expected {
+		if arr.Elements[i] != expected[i] {
+			t.Errorf("Expected sorted array %v, got: %v", expected, arr.Elements)
+			break
+		}
+	}
+}
+
+func TestArraysSortNullArray(t *testing.T) {
+	if _, isErr := arraysSortIntegral([]interface{}{int64(0)}).(error); !isErr {
+		t.Errorf("Expected NullPointerException sorting a null array")
+	}
+}
@@ -0,0 +1,375 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A jimage file, lib/modules in a Java runtime, holds the classes and resources of all
+// the runtime's modules. JDKs also have a jmods directory, from which Jacobin prefers to
+// load classes, but plain JREs and the runtimes built by jlink have only lib/modules.
+// The format is defined by the JDK's jdk.internal.jimage package. The file is:
+//
+//	header     seven u4s: the magic number (0xCAFEDADA), the version, flags, the number
+//	           of resources, the length of the redirect and offsets tables, and the
+//	           sizes of the locations and strings. The u4s of the header and tables are
+//	           in the byte order of the platform that built the image, which the magic
+//	           number shows.
+//	redirect   an s4 for each resource, which, with the hash of a resource's name, gives
+//	           the index of its offset in the offsets table (see locationIndex())
+//	offsets    a u4 for each resource: the offset of its location in the locations
+//	locations  the attributes of each resource (see decodeLocation())
+//	strings    NUL-terminated strings, referred to by their offsets in the locations
+//	resources  the contents of the resources, at the offsets in their locations
+
+const jimageMagic = 0xCAFEDADA
+const jimageMajorVersion = 1
+const jimageHeaderSize = 7 * 4
+const jimageHashMultiplier = 0x01000193
+
+// the kinds of attribute in a resource's location
+const (
+	jimageAttrEnd = iota
+	jimageAttrModule
+	jimageAttrParent
+	jimageAttrBase
+	jimageAttrExtension
+	jimageAttrOffset
+	jimageAttrCompressed
+	jimageAttrUncompressed
+	jimageAttrCount
+)
+
+// the header that precedes each layer of compression of a compressed resource
+const jimageCompressedMagic = 0xCAFEFAFA
+const jimageCompressedHeaderSize = 29
+
+// JImage reads the resources in a jimage file. The index, which is everything before the
+// resources, is read when the file is opened; resources are read as they're needed.
+type JImage struct {
+	File      *os.File
+	order     binary.ByteOrder
+	redirect  []int32
+	offsets   []uint32
+	locations []byte
+	strings   []byte
+	indexSize int64 // the resources follow the index
+	fileSize  int64
+}
+
+// a resource's location: its name, in parts, and where its contents are
+type jimageLocation struct {
+	module, parent, base, extension  string
+	offset, compressed, uncompressed int64
+}
+
+// the resource's name, e.g., /java.base/java/lang/Object.class
+func (loc *jimageLocation) name() string {
+	var name strings.Builder
+	if loc.module != "" {
+		name.WriteString("/" + loc.module + "/")
+	}
+	if loc.parent != "" {
+		name.WriteString(loc.parent + "/")
+	}
+	name.WriteString(loc.base)
+	if loc.extension != "" {
+		name.WriteString("." + loc.extension)
+	}
+	return name.String()
+}
+
+// OpenJImage opens the jimage file at path and reads its index
+func OpenJImage(path string) (*JImage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	image, err := readJImageIndex(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("invalid jimage file %s: %w", path, err)
+	}
+	return image, nil
+}
+
+func readJImageIndex(f *os.File) (*JImage, error) {
+	header := make([]byte, jimageHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, errors.New("file is too short to hold the jimage header")
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(header) != jimageMagic {
+		order = binary.BigEndian
+		if order.Uint32(header) != jimageMagic {
+			return nil, fmt.Errorf("the magic number is invalid: %X", binary.BigEndian.Uint32(header))
+		}
+	}
+	if major := order.Uint32(header[4:]) >> 16; major != jimageMajorVersion {
+		return nil, fmt.Errorf("version %d is not supported", major)
+	}
+
+	tableLength := int64(order.Uint32(header[16:]))
+	locationsSize := int64(order.Uint32(header[20:]))
+	stringsSize := int64(order.Uint32(header[24:]))
+	indexSize := jimageHeaderSize + tableLength*8 + locationsSize + stringsSize
+	info, err := f.Stat()
+	if err != nil || info.Size() < indexSize {
+		return nil, errors.New("file is too short to hold the jimage index")
+	}
+
+	index := make([]byte, indexSize-jimageHeaderSize)
+	if _, err := io.ReadFull(f, index); err != nil {
+		return nil, err
+	}
+	image := &JImage{
+		File:      f,
+		order:     order,
+		redirect:  make([]int32, tableLength),
+		offsets:   make([]uint32, tableLength),
+		locations: index[tableLength*8 : tableLength*8+locationsSize],
+		strings:   index[tableLength*8+locationsSize:],
+		indexSize: indexSize,
+		fileSize:  info.Size(),
+	}
+	for i := int64(0); i < tableLength; i++ {
+		image.redirect[i] = int32(order.Uint32(index[i*4:]))
+		image.offsets[i] = order.Uint32(index[(tableLength+i)*4:])
+	}
+	return image, nil
+}
+
+// Close closes the jimage file
+func (image *JImage) Close() error {
+	return image.File.Close()
+}
+
+// LoadClass returns the bytes of the named class (in java/lang/Object format) in the
+// named module, or nil (and no error) if the jimage does not contain the class
+func (image *JImage) LoadClass(module string, name string) ([]byte, error) {
+	return image.LoadResource("/" + module + "/" + name + ".class")
+}
+
+// LoadResource returns the contents of the named resource, e.g.,
+// /java.base/java/lang/Object.class, or nil (and no error) if the jimage has no such
+// resource
+func (image *JImage) LoadResource(name string) ([]byte, error) {
+	index := image.locationIndex(name)
+	if index < 0 {
+		return nil, nil
+	}
+	loc, err := image.decodeLocation(index)
+	if err != nil {
+		return nil, err
+	}
+	if loc.name() != name { // the hash matched, but not the name
+		return nil, nil
+	}
+	return image.contents(loc)
+}
+
+// Walk invokes `walk` for each class in the named module, except its module-info
+// class. The ArchivePath of each entry is the class's resource name, e.g.,
+// /java.base/java/lang/Object.class.
+func (image *JImage) Walk(module string, walk ClassWalkFunc) error {
+	for index := range image.offsets {
+		loc, err := image.decodeLocation(index)
+		if err != nil {
+			return err
+		}
+		if loc.module != module || loc.extension != "class" || loc.base == "module-info" {
+			continue
+		}
+		b, err := image.contents(loc)
+		if err != nil {
+			return err
+		}
+		className := loc.base
+		if loc.parent != "" {
+			className = loc.parent + "/" + loc.base
+		}
+		entry := ClassEntry{Bytes: b, ArchivePath: loc.name(), ClassName: className, SourceJmod: image.File.Name()}
+		if err = walk(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the hash of a name, starting from seed, as the JDK's ImageStringsReader does
+func jimageHash(name string, seed int32) int32 {
+	for _, b := range []byte(name) {
+		seed = (seed * jimageHashMultiplier) ^ int32(b)
+	}
+	return seed & 0x7FFFFFFF
+}
+
+// returns the index in the offsets table of the named resource's location, if the image
+// has the resource. The redirect entry for the name's hash is 0 if no resource has that
+// hash; negative if only one resource does, in which case it's -1 minus that resource's
+// index; otherwise, it's the seed from which to hash the name again to get the index.
+// If the image doesn't have the resource, the index might be another resource's or -1.
+func (image *JImage) locationIndex(name string) int {
+	count := int32(len(image.redirect))
+	if count == 0 {
+		return -1
+	}
+	redirect := image.redirect[jimageHash(name, jimageHashMultiplier)%count]
+	switch {
+	case redirect < 0:
+		return int(-redirect - 1)
+	case redirect > 0:
+		return int(jimageHash(name, redirect) % count)
+	default:
+		return -1
+	}
+}
+
+// decodes the location at the given index in the offsets table. A location is a series
+// of attributes, ended by a zero byte. Each attribute is a byte holding its kind (in
+// the top five bits) and the length of its value less one (in the bottom three),
+// followed by the value, which is big-endian whatever the byte order of the image.
+func (image *JImage) decodeLocation(index int) (*jimageLocation, error) {
+	if index < 0 || index >= len(image.offsets) {
+		return nil, fmt.Errorf("jimage location index %d is out of range", index)
+	}
+	var attrs [jimageAttrCount]int64
+	pos := int(image.offsets[index])
+	for {
+		if pos >= len(image.locations) {
+			return nil, fmt.Errorf("jimage location %d is truncated", index)
+		}
+		data := image.locations[pos]
+		pos++
+		kind := int(data >> 3)
+		if kind == jimageAttrEnd {
+			break
+		}
+		length := int(data&0x7) + 1
+		if kind >= jimageAttrCount || pos+length > len(image.locations) {
+			return nil, fmt.Errorf("jimage location %d is invalid", index)
+		}
+		var value int64
+		for _, b := range image.locations[pos : pos+length] {
+			value = value<<8 | int64(b)
+		}
+		attrs[kind] = value
+		pos += length
+	}
+
+	loc := &jimageLocation{
+		offset:       attrs[jimageAttrOffset],
+		compressed:   attrs[jimageAttrCompressed],
+		uncompressed: attrs[jimageAttrUncompressed],
+	}
+	var err error
+	for _, attr := range []struct {
+		kind int
+		s    *string
+	}{
+		{jimageAttrModule, &loc.module},
+		{jimageAttrParent, &loc.parent},
+		{jimageAttrBase, &loc.base},
+		{jimageAttrExtension, &loc.extension},
+	} {
+		if *attr.s, err = image.stringAt(attrs[attr.kind]); err != nil {
+			return nil, err
+		}
+	}
+	return loc, nil
+}
+
+// returns the NUL-terminated string at the given offset in the strings
+func (image *JImage) stringAt(offset int64) (string, error) {
+	if offset < 0 || offset >= int64(len(image.strings)) {
+		return "", fmt.Errorf("jimage string offset %d is out of range", offset)
+	}
+	end := bytes.IndexByte(image.strings[offset:], 0)
+	if end < 0 {
+		return "", errors.New("jimage string is not terminated")
+	}
+	return string(image.strings[offset : offset+int64(end)]), nil
+}
+
+// returns the contents of the resource at the location, decompressed if need be. The
+// offset and sizes in the location are checked before anything is read, so that a
+// corrupt jimage can't make Jacobin read outside the resources or allocate more than
+// the file holds.
+func (image *JImage) contents(loc *jimageLocation) ([]byte, error) {
+	size := loc.uncompressed
+	if loc.compressed != 0 {
+		size = loc.compressed
+	}
+	resourcesSize := image.fileSize - image.indexSize
+	if loc.offset < 0 || size < 0 || loc.uncompressed < 0 ||
+		loc.offset > resourcesSize || size > resourcesSize-loc.offset {
+		return nil, fmt.Errorf("jimage location of %s is out of range: offset %d, size %d",
+			loc.name(), loc.offset, size)
+	}
+	b := make([]byte, size)
+	if _, err := image.File.ReadAt(b, image.indexSize+loc.offset); err != nil {
+		return nil, fmt.Errorf("cannot read %s from the jimage: %w", loc.name(), err)
+	}
+	if loc.compressed == 0 {
+		return b, nil
+	}
+	return image.decompress(b, loc)
+}
+
+// decompresses a compressed resource. It might have been compressed more than once, in
+// which case each layer of compression is preceded by a header that gives its size and
+// the name of the decompressor. Only zip compression is supported. As the sizes in the
+// headers might be corrupt, no more is read from a layer than its header says it holds,
+// or than the resource's uncompressed size.
+func (image *JImage) decompress(b []byte, loc *jimageLocation) ([]byte, error) {
+	for len(b) >= jimageCompressedHeaderSize && image.order.Uint32(b) == jimageCompressedMagic {
+		compressedSize := int64(image.order.Uint64(b[4:]))
+		uncompressedSize := int64(image.order.Uint64(b[12:]))
+		decompressor, err := image.stringAt(int64(image.order.Uint32(b[20:])))
+		if err != nil {
+			return nil, err
+		}
+		if decompressor != "zip" {
+			return nil, fmt.Errorf("%s is compressed with %s, which is not supported", loc.name(), decompressor)
+		}
+		content := b[jimageCompressedHeaderSize:]
+		if compressedSize < 0 || int64(len(content)) < compressedSize {
+			return nil, fmt.Errorf("compressed resource %s is truncated", loc.name())
+		}
+		if uncompressedSize < 0 || uncompressedSize > loc.uncompressed {
+			return nil, fmt.Errorf("compressed resource %s has an invalid uncompressed size: %d",
+				loc.name(), uncompressedSize)
+		}
+
+		r, err := zlib.NewReader(bytes.NewReader(content[:compressedSize]))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", loc.name(), err)
+		}
+		b, err = io.ReadAll(io.LimitReader(r, uncompressedSize))
+		_ = r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", loc.name(), err)
+		}
+		if int64(len(b)) != uncompressedSize {
+			return nil, fmt.Errorf("cannot decompress %s: %w", loc.name(), io.ErrUnexpectedEOF)
+		}
+	}
+	if int64(len(b)) != loc.uncompressed {
+		return nil, fmt.Errorf("resource %s decompressed to %d bytes rather than %d",
+			loc.name(), len(b), loc.uncompressed)
+	}
+	return b, nil
+}
@@ -0,0 +1,359 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// a resource to put in a test jimage
+type jimageTestResource struct {
+	name       string // e.g., /java.base/java/lang/Object.class
+	content    []byte
+	compressed bool // with zip compression
+}
+
+// writes a little-endian jimage holding the resources to filename, and returns its path
+func writeTestJImage(t *testing.T, filename string, resources []jimageTestResource) string {
+	order := binary.LittleEndian
+	var strs bytes.Buffer
+	stringOffsets := map[string]int{}
+	addString := func(s string) int {
+		if offset, ok := stringOffsets[s]; ok {
+			return offset
+		}
+		offset := strs.Len()
+		strs.WriteString(s)
+		strs.WriteByte(0)
+		stringOffsets[s] = offset
+		return offset
+	}
+	addString("")
+
+	var locations, content bytes.Buffer
+	offsets := make([]uint32, len(resources))
+	names := make([]string, len(resources))
+	for i, res := range resources {
+		names[i] = res.name
+		parts := strings.SplitN(strings.TrimPrefix(res.name, "/"), "/", 2)
+		module, path := parts[0], parts[1]
+		parent, base := "", path
+		if slash := strings.LastIndex(path, "/"); slash >= 0 {
+			parent, base = path[:slash], path[slash+1:]
+		}
+		extension := ""
+		if dot := strings.LastIndex(base, "."); dot >= 0 {
+			base, extension = base[:dot], base[dot+1:]
+		}
+
+		stored := res.content
+		if res.compressed {
+			var zipped bytes.Buffer
+			w := zlib.NewWriter(&zipped)
+			_, _ = w.Write(res.content)
+			_ = w.Close()
+			header := make([]byte, jimageCompressedHeaderSize)
+			order.PutUint32(header, jimageCompressedMagic)
+			order.PutUint64(header[4:], uint64(zipped.Len()))
+			order.PutUint64(header[12:], uint64(len(res.content)))
+			order.PutUint32(header[20:], uint32(addString("zip")))
+			header[28] = 1 // is terminal
+			stored = append(header, zipped.Bytes()...)
+		}
+
+		offsets[i] = uint32(locations.Len())
+		attrs := []struct {
+			kind  int
+			value int64
+		}{
+			{jimageAttrModule, int64(addString(module))},
+			{jimageAttrParent, int64(addString(parent))},
+			{jimageAttrBase, int64(addString(base))},
+			{jimageAttrExtension, int64(addString(extension))},
+			{jimageAttrOffset, int64(content.Len())},
+			{jimageAttrUncompressed, int64(len(res.content))},
+		}
+		if res.compressed {
+			attrs = append(attrs, struct {
+				kind  int
+				value int64
+			}{jimageAttrCompressed, int64(len(stored))})
+		}
+		for _, attr := range attrs {
+			if attr.value == 0 {
+				continue
+			}
+			var value []byte
+			for v := attr.value; v > 0; v >>= 8 {
+				value = append([]byte{byte(v)}, value...)
+			}
+			locations.WriteByte(byte(attr.kind<<3 | (len(value) - 1)))
+			locations.Write(value)
+		}
+		locations.WriteByte(jimageAttrEnd)
+		content.Write(stored)
+	}
+
+	redirect, slots := jimageRedirectTable(t, names)
+	table := make([]uint32, len(names))
+	for slot, resource := range slots {
+		table[slot] = offsets[resource]
+	}
+
+	var image bytes.Buffer
+	for _, u4 := range []uint32{jimageMagic, jimageMajorVersion << 16, 0, uint32(len(resources)),
+		uint32(len(resources)), uint32(locations.Len()), uint32(strs.Len())} {
+		_ = binary.Write(&image, order, u4)
+	}
+	_ = binary.Write(&image, order, redirect)
+	_ = binary.Write(&image, order, table)
+	image.Write(locations.Bytes())
+	image.Write(strs.Bytes())
+	image.Write(content.Bytes())
+
+	path := filepath.Join(t.TempDir(), filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, image.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// builds the redirect table for the names, as the JDK's PerfectHashBuilder does, and
+// returns it with the index in names of the resource in each slot of the offsets table
+func jimageRedirectTable(t *testing.T, names []string) ([]int32, []int) {
+	count := int32(len(names))
+	buckets := make([][]int, count)
+	for i, name := range names {
+		bucket := jimageHash(name, jimageHashMultiplier) % count
+		buckets[bucket] = append(buckets[bucket], i)
+	}
+	order := make([]int32, count)
+	for i := range order {
+		order[i] = int32(i)
+	}
+	sort.SliceStable(order, func(a, b int) bool { return len(buckets[order[a]]) > len(buckets[order[b]]) })
+
+	redirect := make([]int32, count)
+	slots := make([]int, count)
+	used := make([]bool, count)
+	for _, bucket := range order {
+		members := buckets[bucket]
+		switch len(members) {
+		case 0:
+			continue
+		case 1:
+			for slot := range used {
+				if !used[slot] {
+					used[slot], slots[slot] = true, members[0]
+					redirect[bucket] = int32(-slot - 1)
+					break
+				}
+			}
+		default:
+		seeds:
+			for seed := int32(1); ; seed++ {
+				if seed == 1<<20 {
+					t.Fatal("cannot build the jimage redirect table")
+				}
+				taken := map[int32]bool{}
+				for _, m := range members {
+					slot := jimageHash(names[m], seed) % count
+					if used[slot] || taken[slot] {
+						continue seeds
+					}
+					taken[slot] = true
+				}
+				for _, m := range members {
+					slot := jimageHash(names[m], seed) % count
+					used[slot], slots[slot] = true, m
+				}
+				redirect[bucket] = seed
+				break
+			}
+		}
+	}
+	return redirect, slots
+}
+
+func TestJImageLoadResource(t *testing.T) {
+	var resources []jimageTestResource
+	for i := 0; i < 40; i++ { // enough resources that some of their hashes collide
+		resources = append(resources, jimageTestResource{
+			name:    fmt.Sprintf("/java.base/java/lang/Class%d.class", i),
+			content: []byte(fmt.Sprintf("class %d", i)),
+		})
+	}
+	resources = append(resources,
+		jimageTestResource{name: "/java.base/java/lang/Zipped.class", content: []byte("zipped class"), compressed: true},
+		jimageTestResource{name: "/java.sql/META-INF/services/java.sql.Driver", content: []byte("driver")})
+
+	image, err := OpenJImage(writeTestJImage(t, "modules", resources))
+	if err != nil {
+		t.Fatalf("Unexpected error opening the jimage: %s", err.Error())
+	}
+	defer image.Close()
+
+	for _, res := range resources {
+		b, err := image.LoadResource(res.name)
+		if err != nil {
+			t.Errorf("Unexpected error loading %s: %s", res.name, err.Error())
+		} else if !bytes.Equal(b, res.content) {
+			t.Errorf("Expected %s to hold %q, got: %q", res.name, res.content, b)
+		}
+	}
+
+	if b, err := image.LoadClass("java.base", "java/lang/Class7"); err != nil || string(b) != "class 7" {
+		t.Errorf("Expected LoadClass to return java/lang/Class7, got: %q, %v", b, err)
+	}
+	for _, missing := range []string{"/java.base/java/lang/Missing.class", "/java.sql/java/lang/Class7.class"} {
+		if b, err := image.LoadResource(missing); b != nil || err != nil {
+			t.Errorf("Expected no resource for %s, got: %q, %v", missing, b, err)
+		}
+	}
+}
+
+func TestJImageWalk(t *testing.T) {
+	path := writeTestJImage(t, "modules", []jimageTestResource{
+		{name: "/java.base/java/lang/Object.class", content: []byte("Object")},
+		{name: "/java.base/module-info.class", content: []byte("module-info")},
+		{name: "/java.base/java/lang/uniName.dat", content: []byte("data")},
+		{name: "/java.sql/java/sql/Date.class", content: []byte("Date")},
+		{name: "/java.base/java/util/List.class", content: []byte("List"), compressed: true},
+	})
+	image, err := OpenJImage(path)
+	if err != nil {
+		t.Fatalf("Unexpected error opening the jimage: %s", err.Error())
+	}
+	defer image.Close()
+
+	walked := map[string]string{}
+	err = image.Walk("java.base", func(entry ClassEntry) error {
+		if entry.SourceJmod != path {
+			t.Errorf("Expected %s to be from %s, got: %s", entry.ArchivePath, path, entry.SourceJmod)
+		}
+		walked[entry.ClassName+"="+entry.ArchivePath] = string(entry.Bytes)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking the jimage: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"java/lang/Object=/java.base/java/lang/Object.class": "Object",
+		"java/util/List=/java.base/java/util/List.class":     "List",
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(expected) {
+		t.Errorf("Expected the walk to visit %v, got: %v", expected, walked)
+	}
+}
+
+// a location or compression header whose sizes are corrupt is an error, rather than a
+// read outside the resources or an allocation of more than the file holds
+func TestJImageMalformedLocation(t *testing.T) {
+	const zipped = "/java.base/java/lang/Zipped.class"
+	image, err := OpenJImage(writeTestJImage(t, "modules", []jimageTestResource{
+		{name: "/java.base/java/lang/Object.class", content: []byte("Object")},
+		{name: zipped, content: []byte("zipped class"), compressed: true},
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error opening the jimage: %s", err.Error())
+	}
+	defer image.Close()
+
+	for _, loc := range []jimageLocation{
+		{base: "Huge", uncompressed: 1 << 60},
+		{base: "Negative", uncompressed: -1},
+		{base: "NegativeOffset", offset: -1, uncompressed: 6},
+		{base: "PastTheEnd", offset: 1 << 40, uncompressed: 6},
+		{base: "HugeCompressed", compressed: 1 << 60, uncompressed: 6},
+	} {
+		if b, err := image.contents(&loc); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("Expected an out-of-range error for %s, got: %q, %v", loc.base, b, err)
+		}
+	}
+
+	loc, err := image.decodeLocation(image.locationIndex(zipped))
+	if err != nil {
+		t.Fatalf("Unexpected error decoding the location of %s: %s", zipped, err.Error())
+	}
+	stored := make([]byte, loc.compressed)
+	if _, err = image.File.ReadAt(stored, image.indexSize+loc.offset); err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []struct {
+		field    int // the offset of the size in the compression header
+		size     uint64
+		expected string
+	}{
+		{4, 1 << 63, "truncated"}, // a negative compressed size
+		{4, 1 << 40, "truncated"},
+		{12, 1 << 63, "invalid uncompressed size"},
+		{12, 1 << 40, "invalid uncompressed size"},
+	} {
+		corrupt := append([]byte(nil), stored...)
+		binary.LittleEndian.PutUint64(corrupt[header.field:], header.size)
+		if b, err := image.decompress(corrupt, loc); err == nil || !strings.Contains(err.Error(), header.expected) {
+			t.Errorf("Expected an error containing %q for size %d at %d, got: %q, %v",
+				header.expected, header.size, header.field, b, err)
+		}
+	}
+}
+
+func TestOpenJImageInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modules")
+	if err := os.WriteFile(path, []byte("not a jimage, but long enough to hold a header"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenJImage(path); err == nil || !strings.Contains(err.Error(), "magic number") {
+		t.Errorf("Expected an error about the magic number, got: %v", err)
+	}
+}
+
+// a JAVA_HOME with no jmods directory has its base classes loaded from lib/modules,
+// filtered by lib/classlist
+func TestLoadBaseClassesFromJImage(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	path := writeTestJImage(t, filepath.Join("jre", "lib", "modules"), []jimageTestResource{
+		{name: "/java.base/java/lang/JImageListed.class", content: versionedClass(t, "java/lang/JImageListed", 55)},
+		{name: "/java.base/java/lang/JImageUnlisted.class", content: versionedClass(t, "java/lang/JImageUnlisted", 55)},
+	})
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "classlist"), []byte("java/lang/JImageListed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := globals.GetGlobalRef()
+	g.JavaHome = filepath.Dir(filepath.Dir(path))
+	g.UseClassCache = false
+	LoadBaseClasses(g)
+
+	k, ok := BootstrapCL.Classes["java/lang/JImageListed"]
+	if !ok {
+		t.Fatal("Expected java/lang/JImageListed to be loaded from the jimage")
+	}
+	if k.Data.Module != "java.base" {
+		t.Errorf("Expected java/lang/JImageListed to be in module java.base, got: %q", k.Data.Module)
+	}
+	if _, ok := BootstrapCL.Classes["java/lang/JImageUnlisted"]; ok {
+		t.Error("Expected java/lang/JImageUnlisted, which isn't in the classlist, not to be loaded")
+	}
+}
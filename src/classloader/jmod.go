@@ -8,19 +8,43 @@ package classloader
 
 import (
 	"archive/zip"
-	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"jacobin/exceptions"
 	"jacobin/globals"
 	"jacobin/log"
 	"jacobin/shutdown"
 	"os"
 	"strings"
+	"sync"
 )
 
-type WalkEntryFunc func(bytes []byte, filename string) error
+// ClassEntry is a class found in a walk of a JMOD or a jimage (or, in a walk of the
+// resources of a JMOD, a resource)
+type ClassEntry struct {
+	Bytes       []byte
+	ArchivePath string // the entry's path in the archive, e.g., classes/java/lang/Object.class
+	ClassName   string // e.g., java/lang/Object; empty for a resource that isn't a class
+	SourceJmod  string // the path of the JMOD (or jimage) file
+}
+
+// Filename returns the entry's filename as it's passed to the classloader: the path of
+// the JMOD and the entry's path in it, separated by a +
+func (e ClassEntry) Filename() string {
+	return e.SourceJmod + "+" + e.ArchivePath
+}
+
+// ClassWalkFunc is invoked for each entry found in a walk
+type ClassWalkFunc func(entry ClassEntry) error
+
+// WalkEntryFunc is the former name of ClassWalkFunc.
+//
+// Deprecated: use ClassWalkFunc. WalkEntryFunc will be removed in the next release.
+type WalkEntryFunc = ClassWalkFunc
 
 // MagicNumber JMOD Magic Number
 const MagicNumber = 0x4A4D
@@ -31,18 +55,137 @@ const MagicNumber = 0x4A4D
 // contained in the classlist file; otherwise, all classes found in classes/ in the module.
 type Jmod struct {
 	File os.File
+
+	// the index of the JMOD's archive, which is read once, on first use, by archive().
+	// The entries themselves are read from the file as they're needed.
+	archiveOnce sync.Once
+	archiveErr  error
+	header      []byte               // the JMOD header, or nil if the file couldn't be read
+	reader      *zip.Reader          // reads the archive in the file
+	entries     map[string]*zip.File // entry name (e.g., classes/java/lang/Object.class) -> entry
+	loaded      sync.Map             // entry name -> the entry's bytes, once LoadByName has read it
+
+	// the module the JMOD holds, which is read once, on first use, by descriptor()
+	moduleOnce sync.Once
+	module     ModuleData
+	moduleErr  error
 }
 
 // Walk Walks a JMOD file and invokes `walk` for all classes found in the classlist
-func (j *Jmod) Walk(walk WalkEntryFunc) error {
-	b, err := os.ReadFile(j.File.Name())
+func (j *Jmod) Walk(walk ClassWalkFunc) error {
+	files, err := j.classFiles()
 	if err != nil {
 		return err
 	}
 
-	fileMagic := binary.BigEndian.Uint16(b[:2])
+	for _, f := range files {
+		b, err := readZipEntry(f)
+		if err != nil {
+			return err
+		}
+
+		_ = walk(j.entry(f, b))
+	}
+
+	return nil
+}
+
+// returns the ClassEntry for an entry in the JMOD, whose bytes are b
+func (j *Jmod) entry(f *zip.File, b []byte) ClassEntry {
+	entry := ClassEntry{Bytes: b, ArchivePath: f.Name, SourceJmod: j.File.Name()}
+	if strings.HasSuffix(f.Name, ".class") {
+		entry.ClassName = strings.TrimSuffix(strings.TrimPrefix(f.Name, "classes/"), ".class")
+	}
+	return entry
+}
+
+// WalkParallel invokes `walk` for the same classes as Walk(), but from `workers`
+// goroutines at once, so `walk` must be safe to call concurrently. Unlike Walk(), an
+// error returned by `walk` stops the walk: no further classes are handed to the
+// workers, and once the classes they're working on are done, the errors are returned
+// together as a WalkErrors.
+func (j *Jmod) WalkParallel(walk ClassWalkFunc, workers int) error {
+	files, err := j.classFiles()
+	if err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var errs WalkErrors
+	var errsMutex sync.Mutex
+	fail := func(err error) {
+		errsMutex.Lock()
+		errs = append(errs, err)
+		errsMutex.Unlock()
+		cancel()
+	}
+
+	entries := make(chan *zip.File)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range entries {
+				b, err := readZipEntry(f)
+				if err == nil {
+					err = walk(j.entry(f, b))
+				}
+				if err != nil {
+					fail(fmt.Errorf("%s: %w", f.Name, err))
+				}
+			}
+		}()
+	}
+
+sendEntries:
+	for _, f := range files {
+		select {
+		case entries <- f:
+		case <-ctx.Done():
+			break sendEntries
+		}
+	}
+	close(entries)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// WalkErrors holds the errors from the workers of a parallel walk
+type WalkErrors []error
+
+func (e WalkErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// returns the entries of the classes to be walked: the classes in the classlist, if
+// the JMOD has one; otherwise, all the classes
+func (j *Jmod) classFiles() ([]*zip.File, error) {
+	r, err := j.archive()
+	if j.header == nil {
+		return nil, err // the file couldn't be read
+	}
+	b := j.header
+
+	var fileMagic uint16
+	if len(b) >= 2 { // shorter files are rejected by getZipReaderFromFile()
+		fileMagic = binary.BigEndian.Uint16(b[:2])
+	}
 
-	if fileMagic != MagicNumber {
+	if len(b) >= 2 && fileMagic != MagicNumber {
 
 		if !globals.GetGlobalRef().StrictJDK {
 			msg := fmt.Sprintf("An IOException occurred reading %s: the magic number is invalid. Expected: %x, Got: %x", j.File.Name(), MagicNumber, fileMagic)
@@ -53,19 +196,20 @@ func (j *Jmod) Walk(walk WalkEntryFunc) error {
 		shutdown.Exit(shutdown.JVM_EXCEPTION)
 	}
 
-	// Skip over the JMOD header so that it is recognized as a ZIP file
-	offsetReader := bytes.NewReader(b[4:])
-
-	r, err := zip.NewReader(offsetReader, int64(len(b)-4))
 	if err != nil {
 		_ = log.Log(err.Error(), log.WARNING)
-		return err
+		return nil, err
 	}
 
-	classSet := getClasslist(*r)
+	classSet, err := getClasslist(r)
+	if err != nil {
+		_ = log.Log(err.Error(), log.WARNING)
+		return nil, fmt.Errorf("invalid JMOD file %s: %w", j.File.Name(), err)
+	}
 
 	useClassSet := len(classSet) > 0
 
+	var files []*zip.File
 	for _, f := range r.File {
 		if !strings.HasPrefix(f.Name, "classes") {
 			continue
@@ -84,43 +228,145 @@ func (j *Jmod) Walk(walk WalkEntryFunc) error {
 			}
 		}
 
-		rc, err := f.Open()
-		if err != nil {
-			return err
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// archive returns the reader for the JMOD's archive. The first call reads the JMOD's
+// header and the archive's central directory, and indexes the entries by name; later
+// calls reuse the index. Entries are read from the file only when they're loaded, so
+// memory use grows with the classes loaded, not the size of the JMOD. If the file can
+// be read but isn't a valid archive, header is set, but the reader is nil.
+func (j *Jmod) archive() (*zip.Reader, error) {
+	j.archiveOnce.Do(func() {
+		header := make([]byte, jmodHeaderSize)
+		n, err := j.File.ReadAt(header, 0)
+		if err != nil && err != io.EOF {
+			j.archiveErr = err
+			return
 		}
+		j.header = header[:n]
 
-		b, err := io.ReadAll(rc)
-		if err != nil {
-			return err
+		j.reader, j.archiveErr = getZipReaderFromFile(&j.File)
+		if j.archiveErr != nil {
+			return
 		}
+		j.entries = make(map[string]*zip.File, len(j.reader.File))
+		for _, f := range j.reader.File {
+			j.entries[f.Name] = f
+		}
+	})
+	return j.reader, j.archiveErr
+}
 
-		_ = walk(b, j.File.Name()+"+"+f.Name)
+// ModuleName returns the name of the module in the JMOD (e.g., java.base), as given by
+// its module-info class, or "" if the JMOD has no valid module-info class
+func (j *Jmod) ModuleName() string {
+	desc, _ := j.descriptor()
+	return desc.Name
+}
+
+// ModuleVersion returns the version of the module in the JMOD (e.g., 17.0.2), or "" if
+// the module has no version or the JMOD has no valid module-info class
+func (j *Jmod) ModuleVersion() string {
+	desc, _ := j.descriptor()
+	return desc.Version
+}
 
-		_ = rc.Close()
+// descriptor returns the Module attribute of the module in the JMOD, which is parsed
+// from its module-info class on the first call. A JMOD that has no module-info class,
+// or can't be read, isn't a named module, so its descriptor is empty; one whose
+// module-info class is invalid returns the error from parsing it.
+func (j *Jmod) descriptor() (ModuleData, error) {
+	j.moduleOnce.Do(func() {
+		b, err := j.LoadByName(context.Background(), "module-info")
+		if err != nil || b == nil {
+			return
+		}
+		klass, err := parse(b)
+		switch {
+		case err != nil:
+			j.moduleErr = err
+		case klass.moduleData == nil:
+			j.moduleErr = errors.New("module-info class has no Module attribute")
+		default:
+			j.module = *klass.moduleData
+		}
+	})
+	return j.module, j.moduleErr
+}
+
+// returns the contents of an entry in a JMOD
+func readZipEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer rc.Close()
 
-	return nil
+	return io.ReadAll(rc)
 }
 
-// Returns lib/classlist from the JMOD file, returning an empty map if the classlist cannot be found or read
-func getClasslist(reader zip.Reader) map[string]struct{} {
-	classSet := make(map[string]struct{})
+// the size of the header (the magic number and the version) that precedes the archive
+const jmodHeaderSize = 4
+
+// getZipReaderFromFile returns a reader for the ZIP archive in an open JMOD file. The
+// archive's entries are read from the file as they're opened, so the file must stay
+// open while the reader is in use.
+func getZipReaderFromFile(f *os.File) (*zip.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r, err := getZipReaderAt(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("invalid JMOD file %s: %w", f.Name(), err)
+	}
+	return r, nil
+}
+
+// getZipReaderAt returns a reader for the ZIP archive in a JMOD of the given size, which
+// is read through r. The archive follows the 4-byte JMOD header, so the header is
+// skipped and the size of the archive is the size of the JMOD less the header. All
+// offsets in the archive, including those in the ZIP64 end of central directory records
+// used by large JMODs, are relative to the start of the archive, so this size must be
+// exact for the end of central directory records to be found.
+func getZipReaderAt(r io.ReaderAt, size int64) (*zip.Reader, error) {
+	if size < jmodHeaderSize {
+		return nil, fmt.Errorf("%d bytes is too short to hold the JMOD header", size)
+	}
 
+	offsetReader := io.NewSectionReader(r, jmodHeaderSize, size-jmodHeaderSize)
+	return zip.NewReader(offsetReader, size-jmodHeaderSize)
+}
+
+// Returns the set of classes in lib/classlist in the JMOD file. If the JMOD has no
+// classlist, it returns an empty set, so that all classes in the JMOD are loaded. If the
+// classlist is present but can't be read, it returns an error, as the JMOD is corrupt.
+func getClasslist(reader *zip.Reader) (map[string]struct{}, error) {
 	classlist, err := reader.Open("lib/classlist")
+	if errors.Is(err, fs.ErrNotExist) {
+		_ = log.Log("No lib/classlist in jmod file. Loading all classes in jmod file.", log.CLASS)
+		return make(map[string]struct{}), nil
+	}
 	if err != nil {
-		_ = log.Log(err.Error(), log.CLASS)
-		_ = log.Log("Unable to read lib/classlist from jmod file. Loading all classes in jmod file.", log.CLASS)
-		return classSet
+		return nil, fmt.Errorf("unable to open lib/classlist: %w", err)
 	}
+	defer classlist.Close()
 
 	classlistContent, err := io.ReadAll(classlist)
 	if err != nil {
-		_ = log.Log(err.Error(), log.CLASS)
-		_ = log.Log("Unable to read lib/classlist from jmod file. Loading all classes in jmod file.", log.CLASS)
-		return classSet
+		return nil, fmt.Errorf("unable to read lib/classlist: %w", err)
 	}
+	return parseClasslist(string(classlistContent)), nil
+}
 
-	classes := strings.Split(string(classlistContent), "\n")
+// Returns the set of classes in a classlist, keyed by their entry names, e.g., java/lang/Object.class
+func parseClasslist(content string) map[string]struct{} {
+	classSet := make(map[string]struct{})
+	classes := strings.Split(content, "\n")
 
 	var empty struct{}
 
@@ -0,0 +1,408 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"context"
+	"io"
+	"jacobin/exceptions"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModuleClassLoader is implemented by the sources of classes in named modules: the
+// JMOD files of a JmodManager and the exploded module directories of an
+// ExplodedModuleLoader. LoadClassByName takes a class name in java/lang/Object format
+// and returns the bytes of the class file, or nil (and no error) if the class is not
+// found in any of the loader's modules. If ctx is cancelled or its deadline passes
+// before the class is read, ctx.Err() is returned.
+type ModuleClassLoader interface {
+	LoadClassByName(ctx context.Context, name string) ([]byte, error)
+}
+
+// ModuleResourceLoader is implemented by the module loaders that can also load the
+// resources that sit alongside the classes in their modules (e.g., config.properties).
+// LoadResourceByName takes a resource name whose path elements are separated by / and
+// returns the resource's bytes, or nil (and no error) if no module has it.
+type ModuleResourceLoader interface {
+	LoadResourceByName(name string) ([]byte, error)
+}
+
+// ModuleLoaders are the loaders for the modules on the module path, in the order they
+// appear on the module path. They're set up by InitModuleLoaders().
+var ModuleLoaders []ModuleClassLoader
+
+// JmodManager gives access to the classes in all the JMOD files in a directory
+type JmodManager struct {
+	Dir   string
+	Graph *ModuleGraph // the modules in the JMODs, indexed by the packages they export
+	jmods []*Jmod
+
+	jmodByModule map[string]*Jmod // module name -> the first JMOD (in search order) that holds it
+
+	// the index of the classes in the JMODs, which is built by BuildIndex()
+	indexOnce  sync.Once
+	indexErr   error
+	index      map[string]string // class name (in java/lang/Object format) -> JMOD filename
+	jmodByFile map[string]*Jmod
+}
+
+// InitJmodManager opens all the .jmod files in the given directory. java.base, if
+// present, is searched first; the remaining JMODs are searched in alphabetic order.
+// The module-info classes of the JMODs are read to build the module graph; if two
+// of the modules export the same package, a *SplitPackageError is returned.
+func InitJmodManager(dir string) (*JmodManager, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jmod") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == "java.base.jmod" ||
+			(names[j] != "java.base.jmod" && names[i] < names[j])
+	})
+
+	manager := &JmodManager{Dir: dir}
+	for _, name := range names {
+		jmodFile, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			_ = log.Log("Couldn't open JMOD file "+name+": "+err.Error(), log.WARNING)
+			continue
+		}
+		manager.jmods = append(manager.jmods, &Jmod{File: *jmodFile})
+	}
+
+	var descriptors []ModuleData
+	manager.jmodByModule = make(map[string]*Jmod)
+	for _, jmod := range manager.jmods {
+		descriptor, err := jmod.descriptor()
+		if err != nil {
+			_ = log.Log("Invalid module-info class in "+jmod.File.Name()+": "+err.Error(), log.WARNING)
+			continue
+		}
+		if descriptor.Name == "" {
+			continue // not a named module, so it has no packages to index
+		}
+		descriptors = append(descriptors, descriptor)
+		if _, found := manager.jmodByModule[descriptor.Name]; !found {
+			manager.jmodByModule[descriptor.Name] = jmod
+		}
+	}
+
+	if manager.Graph, err = BuildModuleGraph(descriptors); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// JmodForModule returns the JMOD that holds the named module (e.g., java.base). If
+// several JMODs hold the module, the first of them in search order is returned.
+func (m *JmodManager) JmodForModule(name string) (*Jmod, bool) {
+	jmod, ok := m.jmodByModule[name]
+	return jmod, ok
+}
+
+// LoadClassByName returns the named class from the first JMOD that contains it. The
+// first call builds the index of the classes in the JMODs, so later calls read only
+// the JMOD that holds the class. If the index can't be built, the JMODs are searched
+// in order instead.
+func (m *JmodManager) LoadClassByName(ctx context.Context, name string) ([]byte, error) {
+	if m.BuildIndex() != nil {
+		return m.searchJmods(ctx, name)
+	}
+
+	jmodFile, ok := m.index[name]
+	if !ok {
+		return nil, nil
+	}
+	return m.jmodByFile[jmodFile].LoadByName(ctx, name)
+}
+
+// BuildIndex indexes the classes in the JMODs by name, recording for each class the
+// first JMOD (in search order) that contains it. The index is built only once, however
+// many goroutines call BuildIndex; later calls return the result of the first.
+func (m *JmodManager) BuildIndex() error {
+	m.indexOnce.Do(func() {
+		index := make(map[string]string)
+		jmodByFile := make(map[string]*Jmod)
+		for _, jmod := range m.jmods {
+			r, err := jmod.archive()
+			if err != nil {
+				m.indexErr = err
+				return
+			}
+
+			jmodByFile[jmod.File.Name()] = jmod
+			for _, f := range r.File {
+				if !strings.HasPrefix(f.Name, "classes/") || !strings.HasSuffix(f.Name, ".class") {
+					continue
+				}
+				name := strings.TrimSuffix(strings.TrimPrefix(f.Name, "classes/"), ".class")
+				if _, found := index[name]; !found {
+					index[name] = jmod.File.Name()
+				}
+			}
+		}
+		m.index, m.jmodByFile = index, jmodByFile
+	})
+	return m.indexErr
+}
+
+// LoadResource returns the entry at the given path (e.g., conf/security/java.security)
+// from the first JMOD that contains it, searching the JMODs in order, or nil (and no
+// error) if none of them does
+func (m *JmodManager) LoadResource(ctx context.Context, path string) ([]byte, error) {
+	return m.searchEntries(ctx, path)
+}
+
+// LoadResourceByName returns the named resource (e.g., com/example/config.properties)
+// from the first JMOD that contains it, searching the JMODs in order, or nil (and no
+// error) if none of them does. Unlike LoadResource(), the name is that by which a
+// program refers to the resource, so it's looked up among the JMODs' classes.
+func (m *JmodManager) LoadResourceByName(name string) ([]byte, error) {
+	return m.searchEntries(context.Background(), "classes/"+name)
+}
+
+// searches the JMODs in order for the named class
+func (m *JmodManager) searchJmods(ctx context.Context, name string) ([]byte, error) {
+	return m.searchEntries(ctx, "classes/"+name+".class")
+}
+
+// searches the JMODs in order for the named entry
+func (m *JmodManager) searchEntries(ctx context.Context, entryName string) ([]byte, error) {
+	for _, jmod := range m.jmods {
+		b, err := jmod.loadEntry(ctx, entryName)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+// WalkBaseClasses walks the classes in java.base.jmod, just as Jmod.Walk() does
+func (m *JmodManager) WalkBaseClasses(walk ClassWalkFunc) error {
+	for _, jmod := range m.jmods {
+		if filepath.Base(jmod.File.Name()) == "java.base.jmod" {
+			return jmod.Walk(walk)
+		}
+	}
+	return os.ErrNotExist
+}
+
+// WalkBaseClassesParallel walks the classes in java.base.jmod, just as
+// Jmod.WalkParallel() does, with the given number of worker goroutines. walk must be
+// safe to call from several goroutines at once.
+func (m *JmodManager) WalkBaseClassesParallel(walk ClassWalkFunc, workers int) error {
+	for _, jmod := range m.jmods {
+		if filepath.Base(jmod.File.Name()) == "java.base.jmod" {
+			return jmod.WalkParallel(walk, workers)
+		}
+	}
+	return os.ErrNotExist
+}
+
+// LoadByName returns the bytes of the named class (in java/lang/Object format) from
+// the JMOD, or nil (and no error) if the JMOD does not contain the class. The JMOD is
+// read and its entries indexed on the first call, which can take a while for a large
+// JMOD, so ctx is checked before each step of the read; if it's been cancelled or its
+// deadline has passed, ctx.Err() is returned. The bytes of each class are kept once
+// it's been read, so a later call for the same class needn't decompress it again.
+func (j *Jmod) LoadByName(ctx context.Context, name string) ([]byte, error) {
+	return j.loadEntry(ctx, "classes/"+name+".class")
+}
+
+// LoadResource returns the bytes of the entry at the given path in the JMOD (e.g.,
+// conf/security/java.security), or nil (and no error) if the JMOD has no such entry.
+// Any entry can be loaded, including native libraries and header files. As with
+// LoadByName(), ctx is checked during the read, and the entry's bytes are kept.
+func (j *Jmod) LoadResource(ctx context.Context, path string) ([]byte, error) {
+	return j.loadEntry(ctx, path)
+}
+
+// LoadResourceByName returns the bytes of the named resource in the JMOD, as a program
+// refers to it (e.g., com/example/config.properties), or nil (and no error) if the JMOD
+// has no such resource. Class files can be loaded this way too.
+func (j *Jmod) LoadResourceByName(name string) ([]byte, error) {
+	return j.loadEntry(context.Background(), "classes/"+name)
+}
+
+// returns the bytes of the named entry, for LoadByName() and LoadResource()
+func (j *Jmod) loadEntry(ctx context.Context, entryName string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if b, ok := j.loaded.Load(entryName); ok {
+		return append([]byte(nil), b.([]byte)...), nil // a copy, so the caller can't alter ours
+	}
+
+	if _, err := j.archive(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, ok := j.entries[entryName]
+	if !ok || f.FileInfo().IsDir() {
+		return nil, nil // not in this JMOD
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	j.loaded.Store(entryName, b)
+	return append([]byte(nil), b...), nil
+}
+
+// WalkResources invokes `walk` for each entry in the JMOD whose path starts with
+// prefix (e.g., conf/) and that isn't a class, in the order of the entries in the
+// archive. If `walk` returns an error, the walk stops and the error is returned.
+func (j *Jmod) WalkResources(prefix string, walk ClassWalkFunc) error {
+	r, err := j.archive()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, prefix) || strings.HasSuffix(f.Name, ".class") ||
+			f.FileInfo().IsDir() {
+			continue
+		}
+		b, err := readZipEntry(f)
+		if err != nil {
+			return err
+		}
+		if err = walk(j.entry(f, b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitModuleLoaders sets up ModuleLoaders for each directory on the module path. A
+// directory can hold JMOD files, exploded modules (one subdirectory per module), or both.
+func InitModuleLoaders(global *globals.Globals) {
+	ModuleLoaders = nil
+	if global.ModulePath == "" {
+		return
+	}
+
+	for _, dir := range filepath.SplitList(global.ModulePath) {
+		manager, err := InitJmodManager(dir)
+		if splitErr, ok := err.(*SplitPackageError); ok {
+			exceptions.JVMexception(exceptions.ResolutionException,
+				"Error occurred during initialization of boot layer\n"+
+					"java.lang.module.ResolutionException: "+splitErr.Error())
+			return
+		}
+		if err == nil && len(manager.jmods) > 0 {
+			ModuleLoaders = append(ModuleLoaders, manager)
+		}
+
+		exploded, err := InitExplodedModuleLoader(dir)
+		if err != nil {
+			_ = log.Log("Invalid module path entry "+dir+": "+err.Error(), log.WARNING)
+			continue
+		}
+		if len(exploded.Modules) > 0 {
+			ModuleLoaders = append(ModuleLoaders, exploded)
+		}
+	}
+}
+
+// loadResourceFromModules searches the modules on the module path, in the same order
+// as loadClassFromModules(), for the named resource and returns its bytes, or nil if
+// it's not found there.
+func loadResourceFromModules(name string) []byte {
+	for _, loader := range ModuleLoaders {
+		resourceLoader, ok := loader.(ModuleResourceLoader)
+		if !ok {
+			continue
+		}
+		b, err := resourceLoader.LoadResourceByName(name)
+		if err != nil {
+			_ = log.Log("Error loading resource "+name+" from module path: "+err.Error(), log.WARNING)
+			continue
+		}
+		if b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// loadClassFromModules searches the modules on the module path for the named class
+// and returns the bytes of the class, or nil if it's not found there, along with its
+// location. Where the loader can tell which module the class is in, the location has
+// the form path/to/mods+/org.app/org/app/Main.class, so the class is tagged with its
+// module when it's posted (see moduleFromFilename()); otherwise, it's the class's name.
+func loadClassFromModules(name string) ([]byte, string) {
+	for _, loader := range ModuleLoaders {
+		b, err := loader.LoadClassByName(context.Background(), name)
+		if err != nil {
+			_ = log.Log("Error loading "+name+" from module path: "+err.Error(), log.WARNING)
+			continue
+		}
+		if b != nil {
+			if locator, ok := loader.(moduleLocator); ok {
+				if dir, module := locator.moduleOf(name); module != "" {
+					return b, dir + "+/" + module + "/" + name + ".class"
+				}
+			}
+			return b, name
+		}
+	}
+	return nil, ""
+}
+
+// moduleLocator is implemented by the module loaders that can tell which of their
+// modules holds a class. moduleOf returns the directory on the module path and the
+// name of the module, or "" for the module if the loader doesn't have the class.
+type moduleLocator interface {
+	moduleOf(name string) (string, string)
+}
+
+// returns the module path directory and the module that hold the named class. The
+// module is named by the module-info class of its JMOD or, if the JMOD has none, by
+// the JMOD's filename.
+func (m *JmodManager) moduleOf(name string) (string, string) {
+	if m.BuildIndex() != nil {
+		return m.Dir, ""
+	}
+	jmodFile, ok := m.index[name]
+	if !ok {
+		return m.Dir, ""
+	}
+	if module := m.jmodByFile[jmodFile].ModuleName(); module != "" {
+		return m.Dir, module
+	}
+	return m.Dir, strings.TrimSuffix(filepath.Base(jmodFile), ".jmod")
+}
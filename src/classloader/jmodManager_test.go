@@ -0,0 +1,466 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// returns the bytes of a JMOD holding the given files (path in the JMOD -> contents)
+func testJmodBytes(t testing.TB, files map[string][]byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0x4A, 0x4D, 0x01, 0x00}) // JMOD header
+	w := zip.NewWriter(buf)
+	for name, contents := range files {
+		entry, _ := w.Create(name)
+		_, _ = entry.Write(contents)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to create JMOD: %s", err.Error())
+	}
+	return buf.Bytes()
+}
+
+// writes a JMOD file holding the given files (path in the JMOD -> contents)
+func writeTestJmod(t testing.TB, filename string, files map[string][]byte) {
+	if err := os.WriteFile(filename, testJmodBytes(t, files), 0644); err != nil {
+		t.Fatalf("Unable to write JMOD: %s", err.Error())
+	}
+}
+
+func TestJmodManagerLoadClassByName(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "java.base.jmod"), map[string][]byte{
+		"classes/java/lang/Object.class": {0xCA, 0xFE, 0x01},
+	})
+	writeTestJmod(t, filepath.Join(dir, "app.jmod"), map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+	})
+
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	if filepath.Base(manager.jmods[0].File.Name()) != "java.base.jmod" {
+		t.Errorf("Expected java.base.jmod to be searched first, got: %s", manager.jmods[0].File.Name())
+	}
+
+	b, err := manager.LoadClassByName(context.Background(), "org/app/Main")
+	if err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0x02}) {
+		t.Errorf("Expected to load org/app/Main from app.jmod, got: % X, error: %v", b, err)
+	}
+
+	if b, err = manager.LoadClassByName(context.Background(), "org/app/Missing"); b != nil || err != nil {
+		t.Errorf("Expected a missing class to return nil and no error, got: % X, error: %v", b, err)
+	}
+}
+
+func TestJmodManagerLoadResource(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "java.base.jmod"), map[string][]byte{
+		"classes/java/lang/Object.class": {0xCA, 0xFE, 0x01},
+		"conf/security/java.security":    []byte("base"),
+	})
+	writeTestJmod(t, filepath.Join(dir, "app.jmod"), map[string][]byte{
+		"conf/security/java.security": []byte("app"),
+		"conf/app.properties":         []byte("name=app"),
+		"native/libapp.so":            {0x7F, 'E', 'L', 'F'},
+	})
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+	ctx := context.Background()
+
+	// the first JMOD that has the resource wins
+	if b, err := manager.LoadResource(ctx, "conf/security/java.security"); err != nil || string(b) != "base" {
+		t.Errorf("Expected java.security from java.base.jmod, got: %q, error: %v", b, err)
+	}
+	if b, err := manager.LoadResource(ctx, "native/libapp.so"); err != nil || !bytes.Equal(b, []byte{0x7F, 'E', 'L', 'F'}) {
+		t.Errorf("Expected libapp.so from app.jmod, got: % X, error: %v", b, err)
+	}
+	if b, err := manager.LoadResource(ctx, "conf/missing.properties"); err != nil || b != nil {
+		t.Errorf("Expected a missing resource to return nil and no error, got: %q, error: %v", b, err)
+	}
+	if b, err := manager.jmods[0].LoadResource(ctx, "classes/java/lang/Object.class"); err != nil || len(b) != 3 {
+		t.Errorf("Expected a class to be loadable by its path, got: % X, error: %v", b, err)
+	}
+
+	var walked []string
+	err = manager.jmods[1].WalkResources("conf/", func(entry ClassEntry) error {
+		walked = append(walked, entry.ArchivePath+"="+string(entry.Bytes))
+		return nil
+	})
+	sort.Strings(walked)
+	expected := []string{"conf/app.properties=name=app", "conf/security/java.security=app"}
+	if err != nil || !reflect.DeepEqual(walked, expected) {
+		t.Errorf("Expected to walk %v, got: %v, error: %v", expected, walked, err)
+	}
+
+	// classes aren't resources
+	walked = nil
+	err = manager.jmods[0].WalkResources("", func(entry ClassEntry) error {
+		walked = append(walked, entry.ArchivePath)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(walked, []string{"conf/security/java.security"}) {
+		t.Errorf("Expected to walk only java.security in java.base.jmod, got: %v, error: %v", walked, err)
+	}
+
+	// an error from walk stops the walk
+	stop := errors.New("stop")
+	count := 0
+	err = manager.jmods[1].WalkResources("", func(ClassEntry) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("Expected the walk to stop after 1 resource with the walk's error, got: %d, error: %v", count, err)
+	}
+}
+
+// resources are loaded by the names programs use for them, which are under classes/
+func TestJmodManagerLoadResourceByName(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "a.jmod"), map[string][]byte{
+		"classes/org/app/Main.class":        {0xCA, 0xFE, 0x03},
+		"classes/org/app/config.properties": []byte("from=a"),
+		"conf/app.properties":               []byte("conf"),
+	})
+	writeTestJmod(t, filepath.Join(dir, "b.jmod"), map[string][]byte{
+		"classes/org/app/config.properties": []byte("from=b"),
+		"classes/org/lib/lib.properties":    []byte("lib"),
+	})
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	// the first JMOD that has the resource wins
+	if b, err := manager.LoadResourceByName("org/app/config.properties"); err != nil || string(b) != "from=a" {
+		t.Errorf("Expected config.properties from a.jmod, got: %q, error: %v", b, err)
+	}
+	if b, err := manager.LoadResourceByName("org/lib/lib.properties"); err != nil || string(b) != "lib" {
+		t.Errorf("Expected lib.properties from b.jmod, got: %q, error: %v", b, err)
+	}
+	if b, err := manager.jmods[0].LoadResourceByName("org/app/Main.class"); err != nil || len(b) != 3 {
+		t.Errorf("Expected a class file to be loadable as a resource, got: % X, error: %v", b, err)
+	}
+
+	// entries outside classes/ aren't resources of the program, and missing ones aren't errors
+	for _, name := range []string{"conf/app.properties", "org/app/missing.properties", "org/app"} {
+		if b, err := manager.LoadResourceByName(name); err != nil || b != nil {
+			t.Errorf("Expected no resource %s and no error, got: %q, error: %v", name, b, err)
+		}
+	}
+}
+
+func TestJmodLoadByNameWithExpiredDeadline(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "app.jmod"), map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+	})
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Time{})
+	defer cancel()
+
+	b, err := manager.jmods[0].LoadByName(ctx, "org/app/Main")
+	if !errors.Is(err, context.DeadlineExceeded) || b != nil {
+		t.Errorf("Expected context.DeadlineExceeded from LoadByName(), got: % X, error: %v", b, err)
+	}
+	if _, err = manager.LoadClassByName(ctx, "org/app/Main"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded from LoadClassByName(), got: %v", err)
+	}
+}
+
+// the JMOD is read on the first call to LoadByName, after which its entries are loaded
+// from the bytes read then, so later calls work even if the file is gone
+func TestJmodLoadByNameReadsFileOnce(t *testing.T) {
+	dir := t.TempDir()
+	jmodFileName := filepath.Join(dir, "app.jmod")
+	writeTestJmod(t, jmodFileName, map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+		"classes/org/app/Util.class": {0xCA, 0xFE, 0x03},
+	})
+	jmodFile, err := os.Open(jmodFileName)
+	if err != nil {
+		t.Fatalf("Unable to open JMOD: %s", err.Error())
+	}
+	defer jmodFile.Close()
+	jmod := Jmod{File: *jmodFile}
+
+	if b, err := jmod.LoadByName(context.Background(), "org/app/Main"); err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0x02}) {
+		t.Fatalf("Expected to load org/app/Main, got: % X, error: %v", b, err)
+	}
+	if err = os.Remove(jmodFileName); err != nil {
+		t.Fatalf("Unable to remove JMOD: %s", err.Error())
+	}
+
+	if b, err := jmod.LoadByName(context.Background(), "org/app/Util"); err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0x03}) {
+		t.Errorf("Expected to load org/app/Util from the JMOD already opened, got: % X, error: %v", b, err)
+	}
+	if b, err := jmod.LoadByName(context.Background(), "org/app/Missing"); err != nil || b != nil {
+		t.Errorf("Expected no bytes and no error for a missing class, got: % X, error: %v", b, err)
+	}
+}
+
+// once the first call has read the JMOD and the class, another call to LoadByName for
+// the same class should take well under a microsecond, as the class's bytes are kept
+func BenchmarkJmodLoadByName(b *testing.B) {
+	dir := b.TempDir()
+	jmodFileName := filepath.Join(dir, "app.jmod")
+	writeTestJmod(b, jmodFileName, map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+	})
+	jmodFile, err := os.Open(jmodFileName)
+	if err != nil {
+		b.Fatalf("Unable to open JMOD: %s", err.Error())
+	}
+	defer jmodFile.Close()
+	jmod := Jmod{File: *jmodFile}
+	ctx := context.Background()
+
+	if _, err = jmod.LoadByName(ctx, "org/app/Main"); err != nil {
+		b.Fatalf("Unexpected error loading org/app/Main: %s", err.Error())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = jmod.LoadByName(ctx, "org/app/Main")
+	}
+}
+
+func TestInitModuleLoaders(t *testing.T) {
+	global := globals.InitGlobals("test")
+	log.Init()
+
+	jmodDir := t.TempDir()
+	writeTestJmod(t, filepath.Join(jmodDir, "app.jmod"), map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+	})
+
+	explodedDir := t.TempDir()
+	moduleDir := filepath.Join(explodedDir, "hello.mod")
+	_ = os.MkdirAll(moduleDir, 0755)
+	_ = os.WriteFile(filepath.Join(moduleDir, "module-info.class"), makeModuleInfo("hello.mod"), 0644)
+	_ = os.WriteFile(filepath.Join(moduleDir, "Hello.class"), []byte{0xCA, 0xFE, 0x03}, 0644)
+
+	global.ModulePath = jmodDir + string(os.PathListSeparator) + explodedDir
+	InitModuleLoaders(&global)
+	defer func() { ModuleLoaders = nil }()
+
+	if len(ModuleLoaders) != 2 {
+		t.Fatalf("Expected 2 module loaders, got: %d", len(ModuleLoaders))
+	}
+	b, location := loadClassFromModules("org/app/Main")
+	if !bytes.Equal(b, []byte{0xCA, 0xFE, 0x02}) {
+		t.Errorf("Expected to load org/app/Main from the JMOD, got: % X", b)
+	}
+	// app.jmod has no module-info class, so its module is named after the file
+	if module := moduleFromFilename(location); module != "app" {
+		t.Errorf("Expected org/app/Main to be in module app, got: %q (location: %s)", module, location)
+	}
+	b, location = loadClassFromModules("Hello")
+	if !bytes.Equal(b, []byte{0xCA, 0xFE, 0x03}) {
+		t.Errorf("Expected to load Hello from the exploded module, got: % X", b)
+	}
+	if module := moduleFromFilename(location); module != "hello.mod" {
+		t.Errorf("Expected Hello to be in module hello.mod, got: %q (location: %s)", module, location)
+	}
+	if b, location = loadClassFromModules("org/app/Missing"); b != nil || location != "" {
+		t.Errorf("Expected no class org/app/Missing, got: % X from %q", b, location)
+	}
+}
+
+// writes a java.base.jmod holding the given number of classes to dir
+func writeManyClassJmod(t *testing.T, dir string, count int) {
+	files := make(map[string][]byte, count)
+	for i := 0; i < count; i++ {
+		files["classes/java/lang/Class"+strconv.Itoa(i)+".class"] = []byte{0xCA, 0xFE, byte(i)}
+	}
+	writeTestJmod(t, filepath.Join(dir, "java.base.jmod"), files)
+}
+
+func TestWalkBaseClassesParallel(t *testing.T) {
+	dir := t.TempDir()
+	writeManyClassJmod(t, dir, 50)
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	sequential := make(map[string]bool)
+	_ = manager.WalkBaseClasses(func(entry ClassEntry) error {
+		sequential[entry.Filename()] = true
+		return nil
+	})
+
+	var mutex sync.Mutex
+	parallel := make(map[string]int)
+	err = manager.WalkBaseClassesParallel(func(entry ClassEntry) error {
+		mutex.Lock()
+		parallel[entry.Filename()]++
+		mutex.Unlock()
+		return nil
+	}, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from parallel walk: %s", err.Error())
+	}
+
+	if len(parallel) != 50 || len(parallel) != len(sequential) {
+		t.Errorf("Expected the parallel walk to visit the %d classes of the sequential walk, got: %d",
+			len(sequential), len(parallel))
+	}
+	for filename, visits := range parallel {
+		if !sequential[filename] || visits != 1 {
+			t.Errorf("Expected %s to be walked once, as in the sequential walk, got: %d", filename, visits)
+		}
+	}
+}
+
+func TestWalkBaseClassesParallelStopsOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeManyClassJmod(t, dir, 50)
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	const workers = 4
+	var walked int32
+	err = manager.WalkBaseClassesParallel(func(entry ClassEntry) error {
+		atomic.AddInt32(&walked, 1)
+		return errors.New("cannot load")
+	}, workers)
+
+	var walkErrs WalkErrors
+	if !errors.As(err, &walkErrs) {
+		t.Fatalf("Expected WalkErrors, got: %v", err)
+	}
+	// once the first error cancels the walk, only the classes already handed to the
+	// workers are walked
+	if len(walkErrs) < 1 || len(walkErrs) > workers || int(walked) != len(walkErrs) {
+		t.Errorf("Expected 1 to %d errors, one per class walked, got: %d errors, %d walked",
+			workers, len(walkErrs), walked)
+	}
+	if !strings.Contains(err.Error(), "cannot load") {
+		t.Errorf("Expected the aggregated error to include the walk's errors, got: %s", err.Error())
+	}
+}
+
+// compares the sequential and parallel walks of the JDK's java.base.jmod, which
+// requires JAVA_HOME
+func BenchmarkWalkBaseClasses(b *testing.B) {
+	globals.InitGlobals("test")
+	log.Init()
+	if globals.JavaHome() == "" {
+		b.Skip("JAVA_HOME is not set")
+	}
+	manager, err := InitJmodManager(filepath.Join(globals.JavaHome(), "jmods"))
+	if err != nil || manager.WalkBaseClasses(func(ClassEntry) error { return nil }) != nil {
+		b.Skip("java.base.jmod cannot be read from JAVA_HOME")
+	}
+	noop := func(ClassEntry) error { return nil }
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = manager.WalkBaseClasses(noop)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = manager.WalkBaseClassesParallel(noop, runtime.NumCPU())
+		}
+	})
+}
+
+func TestJmodManagerLoadClassByNameUsesIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "java.base.jmod"), map[string][]byte{
+		"classes/java/lang/Object.class": {0xCA, 0xFE, 0x01},
+		"lib/classlist":                  []byte("java/lang/Object\n"),
+	})
+	writeTestJmod(t, filepath.Join(dir, "app.jmod"), map[string][]byte{
+		"classes/java/lang/Object.class": {0xCA, 0xFE, 0x02}, // hidden by java.base's
+		"classes/org/app/Main.class":     {0xCA, 0xFE, 0x03},
+	})
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+
+	for i := 0; i < 2; i++ { // the first call builds the index; the second uses it
+		b, err := manager.LoadClassByName(context.Background(), "org/app/Main")
+		if err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0x03}) {
+			t.Errorf("Call %d: expected to load org/app/Main from app.jmod, got: % X, error: %v", i+1, b, err)
+		}
+	}
+
+	if filepath.Base(manager.index["org/app/Main"]) != "app.jmod" {
+		t.Errorf("Expected the index to map org/app/Main to app.jmod, got: %q", manager.index["org/app/Main"])
+	}
+	if filepath.Base(manager.index["java/lang/Object"]) != "java.base.jmod" {
+		t.Errorf("Expected the index to map java/lang/Object to the first JMOD that has it, got: %q",
+			manager.index["java/lang/Object"])
+	}
+	if _, indexed := manager.index["lib/classlist"]; indexed || len(manager.index) != 2 {
+		t.Errorf("Expected only the 2 classes to be indexed, got: %v", manager.index)
+	}
+	if b, err := manager.LoadClassByName(context.Background(), "org/app/Missing"); b != nil || err != nil {
+		t.Errorf("Expected a class that's not in the index to return nil and no error, got: % X, error: %v", b, err)
+	}
+}
+
+// compares searching 70 JMODs in order for a class in the last one with looking the
+// class up in the index
+func BenchmarkJmodManagerLoadClassByName(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 70; i++ {
+		writeTestJmod(b, filepath.Join(dir, fmt.Sprintf("module%02d.jmod", i)), map[string][]byte{
+			fmt.Sprintf("classes/org/module%02d/Main.class", i): {0xCA, 0xFE, byte(i)},
+		})
+	}
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		b.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+	ctx := context.Background()
+
+	b.Run("search", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = manager.searchJmods(ctx, "org/module69/Main")
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		_ = manager.BuildIndex()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = manager.LoadClassByName(ctx, "org/module69/Main")
+		}
+	})
+}
@@ -6,7 +6,15 @@
 package classloader
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"jacobin/globals"
+	"jacobin/log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,15 +36,18 @@ func TestJmodFile(t *testing.T) {
 		return
 	}
 
-	jmod := Jmod{*jmodFile}
+	jmod := Jmod{File: *jmodFile}
 
 	filesFound := make(map[string]any, 10)
 
 	var empty struct{}
 
-	jmod.Walk(func(bytes []byte, filename string) error {
-		fname := strings.Split(filename, "+")[1]
-		filesFound[fname] = empty
+	var hello ClassEntry
+	jmod.Walk(func(entry ClassEntry) error {
+		filesFound[entry.ArchivePath] = empty
+		if entry.ArchivePath == "classes/org/jacobin/test/Hello.class" {
+			hello = entry
+		}
 		return nil
 	})
 
@@ -44,6 +55,12 @@ func TestJmodFile(t *testing.T) {
 		t.Error("Expected org.jacobin.test.Hello, but it wasn't there.")
 	}
 
+	if hello.ClassName != "org/jacobin/test/Hello" || hello.SourceJmod != jmodFileName ||
+		hello.Filename() != jmodFileName+"+classes/org/jacobin/test/Hello.class" {
+		t.Errorf("Unexpected entry for org.jacobin.test.Hello: %s, %s, %s",
+			hello.ClassName, hello.SourceJmod, hello.Filename())
+	}
+
 	if _, ok := filesFound["classes/module-info.class"]; ok {
 		t.Error("Didn't expect module-info, but it was there.")
 	}
@@ -63,15 +80,14 @@ func TestJmodFileNoClasslist(t *testing.T) {
 		return
 	}
 
-	jmod := Jmod{*jmodFile}
+	jmod := Jmod{File: *jmodFile}
 
 	filesFound := make(map[string]any, 10)
 
 	var empty struct{}
 
-	err = jmod.Walk(func(bytes []byte, filename string) error {
-		fname := strings.Split(filename, "+")[1]
-		filesFound[fname] = empty
+	err = jmod.Walk(func(entry ClassEntry) error {
+		filesFound[entry.ArchivePath] = empty
 		return nil
 	})
 
@@ -107,9 +123,9 @@ func TestNotJmodFile(t *testing.T) {
 		return
 	}
 
-	jmod := Jmod{*jmodFile}
+	jmod := Jmod{File: *jmodFile}
 
-	err = jmod.Walk(func(bytes []byte, filename string) error {
+	err = jmod.Walk(func(entry ClassEntry) error {
 		return nil
 	})
 
@@ -117,3 +133,332 @@ func TestNotJmodFile(t *testing.T) {
 		t.Error("Should have gotten error that README.md isn't a JMOD file, but didn't.")
 	}
 }
+
+// builds a JMOD holding a single stored (uncompressed) file, in which the ZIP64 forms of
+// all the records are used, just as they are in JMODs larger than 4GB: the sizes and
+// offsets in the headers are 0xFFFFFFFF with the actual values in ZIP64 extra fields,
+// the file has a ZIP64 data descriptor, and the archive ends with a ZIP64 end of central
+// directory record and locator. All offsets are relative to the start of the archive,
+// which follows the 4-byte JMOD header.
+func makeZip64Jmod(name string, data []byte) []byte {
+	le := binary.LittleEndian
+	crc := crc32.ChecksumIEEE(data)
+	zip := new(bytes.Buffer)
+	put := func(v any) { _ = binary.Write(zip, le, v) }
+
+	// local file header. Flag bit 3: the CRC and sizes are in the data descriptor
+	put(uint32(0x04034b50))
+	put(uint16(45)) // version 4.5 is needed for ZIP64
+	put(uint16(0x0008))
+	put(uint16(0)) // stored
+	put(uint32(0)) // mod time & date
+	put(uint32(0)) // CRC (in the data descriptor)
+	put(uint32(0xFFFFFFFF))
+	put(uint32(0xFFFFFFFF))
+	put(uint16(len(name)))
+	put(uint16(20)) // extra field: 4 bytes header + 16 bytes of sizes
+	zip.WriteString(name)
+	put(uint16(0x0001)) // ZIP64 extra field
+	put(uint16(16))
+	put(uint64(0)) // sizes are in the data descriptor
+	put(uint64(0))
+	zip.Write(data)
+
+	// ZIP64 data descriptor, which has 8-byte sizes
+	put(uint32(0x08074b50))
+	put(crc)
+	put(uint64(len(data)))
+	put(uint64(len(data)))
+
+	// central directory
+	cdOffset := zip.Len()
+	put(uint32(0x02014b50))
+	put(uint16(45))
+	put(uint16(45))
+	put(uint16(0x0008))
+	put(uint16(0))
+	put(uint32(0))
+	put(crc)
+	put(uint32(0xFFFFFFFF)) // compressed size
+	put(uint32(0xFFFFFFFF)) // uncompressed size
+	put(uint16(len(name)))
+	put(uint16(28)) // extra field: 4 bytes header + 24 bytes of sizes and offset
+	put(uint16(0))  // comment length
+	put(uint16(0))  // disk number
+	put(uint16(0))  // internal attributes
+	put(uint32(0))  // external attributes
+	put(uint32(0xFFFFFFFF))
+	zip.WriteString(name)
+	put(uint16(0x0001))
+	put(uint16(24))
+	put(uint64(len(data))) // uncompressed size
+	put(uint64(len(data))) // compressed size
+	put(uint64(0))         // offset of the local file header
+	cdSize := zip.Len() - cdOffset
+
+	// ZIP64 end of central directory record
+	zip64EndOffset := zip.Len()
+	put(uint32(0x06064b50))
+	put(uint64(44)) // size of the rest of this record
+	put(uint16(45))
+	put(uint16(45))
+	put(uint32(0)) // this disk
+	put(uint32(0)) // disk with the central directory
+	put(uint64(1)) // entries on this disk
+	put(uint64(1)) // total entries
+	put(uint64(cdSize))
+	put(uint64(cdOffset))
+
+	// ZIP64 end of central directory locator
+	put(uint32(0x07064b50))
+	put(uint32(0))
+	put(uint64(zip64EndOffset))
+	put(uint32(1))
+
+	// end of central directory record, with all values pointing to the ZIP64 record
+	put(uint32(0x06054b50))
+	put(uint16(0))
+	put(uint16(0))
+	put(uint16(0xFFFF))
+	put(uint16(0xFFFF))
+	put(uint32(0xFFFFFFFF))
+	put(uint32(0xFFFFFFFF))
+	put(uint16(0))
+
+	return append([]byte{0x4A, 0x4D, 0x01, 0x00}, zip.Bytes()...)
+}
+
+// returns a reader for the ZIP archive in the bytes of a JMOD that's in memory
+func getZipReader(data []byte) (*zip.Reader, error) {
+	r, err := getZipReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JMOD: %w", err)
+	}
+	return r, nil
+}
+
+func TestJmodFileZip64(t *testing.T) {
+	globals.InitGlobals("test")
+
+	classBytes := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x3D}
+	jmodBytes := makeZip64Jmod("classes/org/jacobin/test/Big.class", classBytes)
+
+	r, err := getZipReader(jmodBytes)
+	if err != nil {
+		t.Fatalf("Unexpected error getting reader for ZIP64 JMOD: %s", err.Error())
+	}
+	if len(r.File) != 1 || r.File[0].UncompressedSize64 != uint64(len(classBytes)) {
+		t.Fatalf("Expected one file of %d bytes in ZIP64 JMOD, got: %v", len(classBytes), r.File)
+	}
+
+	jmodFileName := filepath.Join(t.TempDir(), "big.jmod")
+	if err = os.WriteFile(jmodFileName, jmodBytes, 0644); err != nil {
+		t.Fatalf("Unable to write JMOD file: %s", err.Error())
+	}
+	jmodFile, err := os.Open(jmodFileName)
+	if err != nil {
+		t.Fatalf("Unable to open JMOD file: %s", err.Error())
+	}
+	defer jmodFile.Close()
+
+	// no lib/classlist in this JMOD, so the CLASS-level message about it is discarded
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	jmod := Jmod{File: *jmodFile}
+	var found []byte
+	err = jmod.Walk(func(entry ClassEntry) error {
+		if entry.ClassName == "org/jacobin/test/Big" {
+			found = entry.Bytes
+		}
+		return nil
+	})
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Errorf("Unexpected error walking ZIP64 JMOD: %s", err.Error())
+	}
+	if !bytes.Equal(found, classBytes) {
+		t.Errorf("Expected to read % X from ZIP64 JMOD, got: % X", classBytes, found)
+	}
+}
+
+// the number of entries in the archives of the tests of ZIP64 archives: more than a ZIP
+// archive without the ZIP64 extensions can hold
+const zip64EntryCount = 70000
+
+// a JMOD with more entries than fit in the end of central directory record must be read
+// through its ZIP64 records
+func TestJmodFileManyEntries(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	files := make(map[string][]byte, zip64EntryCount)
+	for i := 0; i < zip64EntryCount; i++ {
+		files[fmt.Sprintf("classes/org/many/C%05d.class", i)] = []byte{byte(i)}
+	}
+	jmodFileName := filepath.Join(t.TempDir(), "many.jmod")
+	writeTestJmod(t, jmodFileName, files)
+
+	jmodFile, err := os.Open(jmodFileName)
+	if err != nil {
+		t.Fatalf("Unable to open JMOD file: %s", err.Error())
+	}
+	defer jmodFile.Close()
+	jmod := Jmod{File: *jmodFile}
+
+	walked := 0
+	if err = jmod.Walk(func(ClassEntry) error { walked++; return nil }); err != nil {
+		t.Fatalf("Unexpected error walking JMOD: %s", err.Error())
+	}
+	if walked != zip64EntryCount {
+		t.Errorf("Expected to walk %d classes, got: %d", zip64EntryCount, walked)
+	}
+
+	last := zip64EntryCount - 1
+	b, err := jmod.LoadByName(context.Background(), fmt.Sprintf("org/many/C%05d", last))
+	if err != nil || !bytes.Equal(b, []byte{byte(last)}) {
+		t.Errorf("Expected to load the last class, got: % X, error: %v", b, err)
+	}
+}
+
+// a JMOD that's already in memory is read without touching the filesystem
+func TestGetZipReaderFromBytes(t *testing.T) {
+	data := testJmodBytes(t, map[string][]byte{
+		"classes/org/app/Main.class": {0xCA, 0xFE, 0x02},
+		"conf/app.properties":        []byte("name=app"),
+	})
+
+	r, err := getZipReader(data)
+	if err != nil {
+		t.Fatalf("Unexpected error getting reader for JMOD bytes: %s", err.Error())
+	}
+	if len(r.File) != 2 {
+		t.Errorf("Expected 2 entries in the JMOD, got: %d", len(r.File))
+	}
+	f, err := r.Open("classes/org/app/Main.class")
+	if err != nil {
+		t.Fatalf("Unexpected error opening org/app/Main: %s", err.Error())
+	}
+	defer f.Close()
+	if b, err := io.ReadAll(f); err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0x02}) {
+		t.Errorf("Expected to read org/app/Main from the JMOD bytes, got: % X, error: %v", b, err)
+	}
+}
+
+func TestGetZipReaderWrongSize(t *testing.T) {
+	// too short to hold even the JMOD header
+	if _, err := getZipReader([]byte{0x4A, 0x4D}); err == nil {
+		t.Error("Expected an error for a JMOD shorter than its header, but got none")
+	}
+
+	// a header and nothing else
+	if _, err := getZipReader([]byte{0x4A, 0x4D, 0x01, 0x00}); err == nil {
+		t.Error("Expected an error for a JMOD with no ZIP archive, but got none")
+	}
+
+	// a ZIP64 JMOD that's been truncated, so the size no longer matches the records
+	jmodBytes := makeZip64Jmod("classes/A.class", []byte("data"))
+	_, err := getZipReader(jmodBytes[:len(jmodBytes)-10])
+	if err == nil {
+		t.Error("Expected an error for a truncated ZIP64 JMOD, but got none")
+	}
+}
+
+// returns a zip reader over an archive holding a class and, if classlist isn't
+// nil, lib/classlist with that content, stored with its CRC-32 unless badCRC is set
+func classlistArchive(t *testing.T, classlist []byte, badCRC bool) *zip.Reader {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	entry, _ := w.Create("classes/java/lang/Object.class")
+	_, _ = entry.Write([]byte{0xCA, 0xFE})
+	if classlist != nil {
+		crc := crc32.ChecksumIEEE(classlist)
+		if badCRC {
+			crc++
+		}
+		entry, err := w.CreateRaw(&zip.FileHeader{Name: "lib/classlist", Method: zip.Store, CRC32: crc,
+			CompressedSize64: uint64(len(classlist)), UncompressedSize64: uint64(len(classlist))})
+		if err != nil {
+			t.Fatalf("Unable to create lib/classlist: %s", err.Error())
+		}
+		_, _ = entry.Write(classlist)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to create archive: %s", err.Error())
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Unable to read archive: %s", err.Error())
+	}
+	return r
+}
+
+func TestGetClasslist(t *testing.T) {
+	classSet, err := getClasslist(classlistArchive(t, []byte("java/lang/Object\r\njava/lang/String\n"), false))
+	if err != nil {
+		t.Fatalf("Unexpected error reading lib/classlist: %s", err.Error())
+	}
+	for _, class := range []string{"java/lang/Object.class", "java/lang/String.class"} {
+		if _, ok := classSet[class]; !ok {
+			t.Errorf("Expected %s in the classlist, got: %v", class, classSet)
+		}
+	}
+}
+
+// a JMOD without a classlist has all its classes loaded
+func TestGetClasslistAbsent(t *testing.T) {
+	classSet, err := getClasslist(classlistArchive(t, nil, false))
+	if err != nil {
+		t.Errorf("Expected no error for a missing lib/classlist, got: %s", err.Error())
+	}
+	if classSet == nil || len(classSet) != 0 {
+		t.Errorf("Expected an empty classlist, got: %v", classSet)
+	}
+}
+
+// a JMOD whose classlist can't be read is corrupt, so its classes aren't walked
+func TestGetClasslistUnreadable(t *testing.T) {
+	if _, err := getClasslist(classlistArchive(t, []byte("java/lang/Object\n"), true)); err == nil {
+		t.Error("Expected an error for an unreadable lib/classlist, but got none")
+	}
+
+	globals.InitGlobals("test")
+	log.Init()
+	jmodFileName := filepath.Join(t.TempDir(), "corrupt.jmod")
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0x4A, 0x4D, 0x01, 0x00}) // JMOD header
+	r := classlistArchive(t, []byte("java/lang/Object\n"), true)
+	w := zip.NewWriter(buf)
+	for _, f := range r.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatalf("Unable to copy %s: %s", f.Name, err.Error())
+		}
+	}
+	_ = w.Close()
+	if err := os.WriteFile(jmodFileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	jmodFile, err := os.Open(jmodFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jmodFile.Close()
+	jmod := Jmod{File: *jmodFile}
+
+	walked := 0
+	err = jmod.Walk(func(entry ClassEntry) error {
+		walked++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "lib/classlist") {
+		t.Errorf("Expected Walk to return an error about lib/classlist, got: %v", err)
+	}
+	if walked != 0 {
+		t.Errorf("Expected no classes to be walked, got: %d", walked)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"sort"
+	"strconv"
+)
+
+// The LineNumberTable attributes of a method's Code attribute map its bytecode to the
+// lines of its source file, so that stack traces can show line numbers. A Code
+// attribute can have several LineNumberTables, in any order; their entries are merged
+// into one LineNumberTable, sorted by bytecode offset. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.12
+
+// LineNumber is an entry in a LineNumberTable: the source line that starts at the
+// bytecode offset StartPC
+type LineNumber struct {
+	StartPC int
+	Line    int
+}
+
+// LineNumberTable is the line numbers of a method, in order by StartPC
+type LineNumberTable []LineNumber
+
+// Line returns the source line of the instruction at the bytecode offset pc, which is
+// the line of the last entry that starts at or before pc. It returns -1 if there's no
+// such entry, as when the method has no line numbers.
+func (table LineNumberTable) Line(pc int) int {
+	i := sort.Search(len(table), func(i int) bool { return table[i].StartPC > pc })
+	if i == 0 {
+		return -1
+	}
+	return table[i-1].Line
+}
+
+// LineForPC returns the source line of the instruction at the bytecode offset pc in
+// the method, or -1 if the method has no line numbers
+func LineForPC(method *Method, pc int) int {
+	return method.CodeAttr.LineNumbers.Line(pc)
+}
+
+// parseLineNumberTable adds the entries in the content of a LineNumberTable attribute
+// to table, which it returns sorted. codeLength is the length of the method's bytecode,
+// which every entry must start within.
+func parseLineNumberTable(content []byte, table LineNumberTable, codeLength int,
+	klass *ParsedClass, methodName string) (LineNumberTable, error) {
+	r := &classReader{b: content}
+	count := r.u2()
+	for i := 0; i < count && r.err == nil; i++ {
+		entry := LineNumber{StartPC: r.u2(), Line: r.u2()}
+		if r.err == nil && entry.StartPC >= codeLength {
+			return nil, cfe("Invalid start PC " + strconv.Itoa(entry.StartPC) + " in entry #" +
+				strconv.Itoa(i) + " of LineNumberTable in " + methodName + "() of " + klass.className)
+		}
+		table = append(table, entry)
+	}
+
+	if r.err != nil || r.pos != len(content) {
+		return nil, cfe("Invalid LineNumberTable in " + methodName + "() of " + klass.className)
+	}
+	sort.SliceStable(table, func(i, j int) bool { return table[i].StartPC < table[j].StartPC })
+	return table, nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Hello2's main() has a loop, so its lines don't appear in the order of its bytecode
+func TestLineNumbersOfHello2(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(getHello2Bytes(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Hello2: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	methods := make(map[string]*Method)
+	for i := range kd.Methods {
+		methods[kd.CP.Utf8Refs[kd.Methods[i].Name]] = &kd.Methods[i]
+	}
+
+	main := methods["main"]
+	if main == nil {
+		t.Fatal("Expected Hello2 to have a main() method")
+	}
+	for pc, line := range map[int]int{0: 6, 4: 6, 5: 7, 12: 7, 13: 8, 20: 6, 28: 6, 29: 10} {
+		if got := LineForPC(main, pc); got != line {
+			t.Errorf("Expected pc %d of main() to be on line %d, got: %d", pc, line, got)
+		}
+	}
+	if got := LineForPC(methods["<init>"], 0); got != 2 {
+		t.Errorf("Expected the constructor to be on line 2, got: %d", got)
+	}
+
+	if got := LineForPC(&Method{}, 0); got != -1 {
+		t.Errorf("Expected -1 for a method without line numbers, got: %d", got)
+	}
+}
+
+// the entries of a method's LineNumberTables are merged and sorted by start PC
+func TestParseLineNumberTablesMerged(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := ParsedClass{className: "LineTest"}
+	table, err := parseLineNumberTable([]byte{0, 2, 0, 8, 0, 12, 0, 0, 0, 10}, nil, 20, &klass, "run")
+	if err == nil {
+		table, err = parseLineNumberTable([]byte{0, 1, 0, 4, 0, 11}, table, 20, &klass, "run")
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error parsing LineNumberTables: %s", err.Error())
+	}
+
+	expected := LineNumberTable{{0, 10}, {4, 11}, {8, 12}}
+	if len(table) != len(expected) {
+		t.Fatalf("Expected %d line numbers, got: %v", len(expected), table)
+	}
+	for i := range expected {
+		if table[i] != expected[i] {
+			t.Errorf("Expected line number #%d to be %v, got: %v", i, expected[i], table[i])
+		}
+	}
+	if table.Line(7) != 11 || table.Line(19) != 12 {
+		t.Errorf("Expected pc 7 on line 11 and pc 19 on line 12, got: %d and %d", table.Line(7), table.Line(19))
+	}
+}
+
+func TestParseLineNumberTableInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{className: "LineTest"}
+	var errs []error
+	for _, content := range [][]byte{
+		{0, 2, 0, 0, 0, 10},               // fewer entries than the count
+		{0, 1, 0, 0, 0, 10, 0},            // a stray byte
+		{0, 2, 0, 0, 0, 10, 0, 20, 0, 11}, // starts past the end of the bytecode
+	} {
+		_, err := parseLineNumberTable(content, nil, 20, &klass, "run")
+		errs = append(errs, err)
+	}
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error for LineNumberTable #%d", i)
+		}
+	}
+	msg := string(out)
+	if !strings.Contains(msg, "Invalid LineNumberTable in run() of LineTest") {
+		t.Errorf("Expected an error about the malformed LineNumberTable, got: %s", msg)
+	}
+	if !strings.Contains(msg, "Invalid start PC 20 in entry #1 of LineNumberTable in run() of LineTest") {
+		t.Errorf("Expected an error about the start PC, got: %s", msg)
+	}
+}
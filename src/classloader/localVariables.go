@@ -0,0 +1,90 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "strconv"
+
+// The LocalVariableTable attributes of a method's Code attribute give the names and
+// types of its local variables, such as that local 1 is args, a String[]. The
+// LocalVariableTypeTable attributes do the same for variables of generic types, giving
+// their signatures rather than descriptors. Both are compiled in by javac -g, and are
+// used for debugging and tracing only. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.13
+
+// LocalVariable is an entry in a LocalVariableTable or LocalVariableTypeTable: the
+// local variable in Slot, which is in scope for the Length bytes of bytecode that
+// start at StartPC
+type LocalVariable struct {
+	StartPC int
+	Length  int
+	Name    uint16 // index of the UTF-8 entry in the CP
+	Desc    uint16 // index of the UTF-8 entry in the CP: a descriptor, or in a LocalVariableTypeTable, a signature
+	Slot    int
+}
+
+// LocalVariableTable is the local variables of a method, from all its LocalVariableTable
+// (or LocalVariableTypeTable) attributes
+type LocalVariableTable []LocalVariable
+
+// At returns the local variable in slot that's in scope at the bytecode offset pc, or
+// nil if there's none, as when the method has no local variable table.
+func (table LocalVariableTable) At(slot, pc int) *LocalVariable {
+	for i := range table {
+		v := &table[i]
+		if v.Slot == slot && pc >= v.StartPC && pc < v.StartPC+v.Length {
+			return v
+		}
+	}
+	return nil
+}
+
+// parseLocalVariableTable adds the entries in the content of a LocalVariableTable or
+// LocalVariableTypeTable attribute (per attrName) to table, which it returns. Every
+// entry must be within the method's bytecode, whose length is codeLength, and within
+// its maxLocals local variables, where a long or double takes up two.
+func parseLocalVariableTable(content []byte, table LocalVariableTable, attrName string, codeLength,
+	maxLocals int, klass *ParsedClass, methodName string) (LocalVariableTable, error) {
+	r := &classReader{b: content}
+	count := r.u2()
+	for i := 0; i < count && r.err == nil; i++ {
+		startPC, length, nameIndex, descIndex, slot := r.u2(), r.u2(), r.u2(), r.u2(), r.u2()
+		if r.err != nil {
+			break
+		}
+		invalid := func(what string) error {
+			return cfe("Invalid " + what + " in entry #" + strconv.Itoa(i) + " of " + attrName + " in " +
+				methodName + "() of " + klass.className)
+		}
+
+		if startPC+length > codeLength {
+			return nil, invalid("range of bytecode " + strconv.Itoa(startPC) + "-" + strconv.Itoa(startPC+length))
+		}
+		name, err := fetchUTF8slot(klass, nameIndex)
+		if err != nil {
+			return nil, invalid("name")
+		}
+		desc, err := fetchUTF8slot(klass, descIndex)
+		if err != nil {
+			return nil, invalid("descriptor")
+		}
+		slots := 1
+		if d := klass.utf8Refs[desc].content; d == "J" || d == "D" {
+			slots = 2
+		}
+		if slot+slots > maxLocals {
+			return nil, invalid("slot " + strconv.Itoa(slot) + " (max_locals is " + strconv.Itoa(maxLocals) + ")")
+		}
+
+		table = append(table, LocalVariable{StartPC: startPC, Length: length,
+			Name: uint16(name), Desc: uint16(desc), Slot: slot})
+	}
+
+	if r.err != nil || r.pos != len(content) {
+		return nil, cfe("Invalid " + attrName + " in " + methodName + "() of " + klass.className)
+	}
+	return table, nil
+}
@@ -0,0 +1,134 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Hello2 was compiled with -g, so its main() has a LocalVariableTable
+func TestLocalVariablesOfHello2(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(getHello2Bytes(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Hello2: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	var main *Method
+	for i := range kd.Methods {
+		if kd.CP.Utf8Refs[kd.Methods[i].Name] == "main" {
+			main = &kd.Methods[i]
+		}
+	}
+	if main == nil {
+		t.Fatal("Expected Hello2 to have a main() method")
+	}
+
+	vars := main.CodeAttr.LocalVariables
+	for _, expected := range []struct {
+		slot, pc   int
+		name, desc string
+	}{
+		{0, 0, "args", "[Ljava/lang/String;"},
+		{1, 13, "x", "I"},
+		{2, 5, "i", "I"},
+	} {
+		v := vars.At(expected.slot, expected.pc)
+		if v == nil {
+			t.Errorf("Expected a local variable in slot %d at pc %d, got none", expected.slot, expected.pc)
+			continue
+		}
+		if kd.CP.Utf8Refs[v.Name] != expected.name || kd.CP.Utf8Refs[v.Desc] != expected.desc {
+			t.Errorf("Expected local variable %s %s in slot %d, got: %s %s", expected.name, expected.desc,
+				expected.slot, kd.CP.Utf8Refs[v.Name], kd.CP.Utf8Refs[v.Desc])
+		}
+	}
+
+	// x is not in scope until it's first stored
+	if v := vars.At(1, 0); v != nil {
+		t.Errorf("Expected no local variable in slot 1 at pc 0, got: %s", kd.CP.Utf8Refs[v.Name])
+	}
+}
+
+// returns a class whose CP has the names and descriptors of the local variables
+// in the tests: #1 total, #2 J, #3 list, #4 Ljava/util/List<Ljava/lang/String;>;
+func localVariablesClass() *ParsedClass {
+	klass := ParsedClass{className: "LocalsTest"}
+	klass.cpIndex = []cpEntry{{}, {UTF8, 0}, {UTF8, 1}, {UTF8, 2}, {UTF8, 3}}
+	klass.utf8Refs = []utf8Entry{{"total"}, {"J"}, {"list"}, {"Ljava/util/List<Ljava/lang/String;>;"}}
+	klass.cpCount = 5
+	return &klass
+}
+
+func TestParseLocalVariableTypeTable(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := localVariablesClass()
+	vars, err := parseLocalVariableTable([]byte{0, 1, 0, 2, 0, 8, 0, 3, 0, 4, 0, 3}, nil,
+		"LocalVariableTypeTable", 10, 4, klass, "run")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing LocalVariableTypeTable: %s", err.Error())
+	}
+	expected := LocalVariable{StartPC: 2, Length: 8, Name: 2, Desc: 3, Slot: 3}
+	if len(vars) != 1 || vars[0] != expected {
+		t.Fatalf("Expected %+v, got: %+v", expected, vars)
+	}
+	if vars.At(3, 1) != nil || vars.At(3, 10) != nil || vars.At(3, 9) == nil {
+		t.Errorf("Expected the variable to be in scope at pcs 2-9 only")
+	}
+}
+
+func TestParseLocalVariableTableInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := localVariablesClass()
+	var errs []error
+	for _, content := range [][]byte{
+		{0, 1, 0, 0, 0, 11, 0, 1, 0, 2, 0, 0},    // extends past the end of the bytecode
+		{0, 1, 0, 0, 0, 10, 0, 1, 0, 2, 0, 3},    // a long in the last local variable
+		{0, 1, 0, 0, 0, 10, 0, 9, 0, 2, 0, 0},    // a name that's not in the CP
+		{0, 2, 0, 0, 0, 10, 0, 1, 0, 2, 0, 0},    // fewer entries than the count
+		{0, 1, 0, 0, 0, 10, 0, 1, 0, 2, 0, 0, 0}, // a stray byte
+	} {
+		_, err := parseLocalVariableTable(content, nil, "LocalVariableTable", 10, 4, klass, "run")
+		errs = append(errs, err)
+	}
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error for LocalVariableTable #%d", i)
+		}
+	}
+	msg := string(out)
+	for _, expected := range []string{
+		"Invalid range of bytecode 0-11 in entry #0 of LocalVariableTable in run() of LocalsTest",
+		"Invalid slot 3 (max_locals is 4) in entry #0 of LocalVariableTable in run() of LocalsTest",
+		"Invalid name in entry #0 of LocalVariableTable in run() of LocalsTest",
+		"Invalid LocalVariableTable in run() of LocalsTest",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected error: %s, got: %s", expected, msg)
+		}
+	}
+}
@@ -58,6 +58,8 @@ type JmEntry struct {
 	params      []ParamAttrib
 	deprecated  bool
 	Cp          *CPool
+	LineNumbers LineNumberTable
+	LocalVars   LocalVariableTable
 }
 
 // Function is the generic-style function used for Go entries: a function that accepts a
@@ -65,9 +67,9 @@ type JmEntry struct {
 // stack rather than actually returned to a caller).
 type Function func([]interface{}) interface{}
 
-// MTmutex is used for updates to the MTable because multiple threads could be
-// updating it simultaneously.
-var MTmutex sync.Mutex
+// MTmutex guards the MTable, which is read by the interpreter threads while it's
+// updated by other threads, such as those that redefine and unload classes.
+var MTmutex sync.RWMutex
 
 // MTableLoadNatives loads the Go methods from files that contain them. It does this
 // by calling the Load_* function in each of those files to load whatever Go functions
@@ -75,6 +77,25 @@ var MTmutex sync.Mutex
 func MTableLoadNatives() {
 	loadlib(&MTable, Load_Io_PrintStream()) // load the java.io.prinstream golang functions
 	loadlib(&MTable, Load_Lang_System())    // load the java.lang.system golang functions
+	loadlib(&MTable, Load_Lang_Object())    // load the java.lang.Object golang functions
+	loadlib(&MTable, Load_Lang_String())    // load the java.lang.String golang functions
+	loadlib(&MTable, Load_Lang_Integer())   // load the java.lang.Integer golang functions
+	loadlib(&MTable, Load_Lang_Long())      // load the java.lang.Long golang functions
+	loadlib(&MTable, Load_Lang_Double())    // load the java.lang.Double golang functions
+	loadlib(&MTable, Load_Lang_Math())      // load the java.lang.Math golang functions
+	loadlib(&MTable, Load_Lang_Throwable()) // load the java.lang.Throwable golang functions
+	loadlib(&MTable, Load_Lang_Runtime())   // load the java.lang.Runtime and Process golang functions
+	loadlib(&MTable, Load_Io_InputStream()) // load the java.io.InputStream golang functions
+	loadlib(&MTable, Load_Lang_Class())     // load the java.lang.Class golang functions
+	loadlib(&MTable, Load_Lang_Reflect())   // load the java.lang.reflect.Method and Field golang functions
+	loadlib(&MTable, Load_Util_Arrays())    // load the java.util.Arrays golang functions
+	loadlib(&MTable, Load_Lang_Ref())       // load the java.lang.ref Reference and ReferenceQueue golang functions
+}
+
+// AddGoMethods adds Go methods that are implemented outside this package to the
+// MTable. These are methods, such as Class.forName(), that need the interpreter.
+func AddGoMethods(libMeths map[string]GMeth) {
+	loadlib(&MTable, libMeths)
 }
 
 func loadlib(tbl *MT, libMeths map[string]GMeth) {
@@ -100,3 +121,12 @@ func addEntry(tbl *MT, key string, mte MTentry) {
 	mt[key] = mte
 	MTmutex.Unlock()
 }
+
+// FetchMTableEntry returns the MTable entry for the method with the given fully
+// qualified name, and whether there is one
+func FetchMTableEntry(methFQN string) (MTentry, bool) {
+	MTmutex.RLock()
+	defer MTmutex.RUnlock()
+	mte, present := MTable[methFQN]
+	return mte, present
+}
@@ -42,10 +42,14 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 				klass.className)
 		}
 		nameSlot, err2 := fetchUTF8slot(klass, nameIndex)
+		if err2 != nil {
+			return pos, cfe("Invalid fetch of method name in class: " +
+				klass.className)
+		}
 
 		descIndex, err3 := intFrom2Bytes(bytes, pos+1)
 		pos += 2
-		if err2 != nil || err3 != nil {
+		if err3 != nil {
 			return pos, cfe("Invalid fetch of method description index in method: " +
 				klass.utf8Refs[nameSlot].content)
 		}
@@ -107,6 +111,14 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 					if parseMethodParametersAttribute(attrib, &meth, klass) != nil {
 						return pos, cfe("") // error msg will already have been shown to user
 					}
+				case "Signature":
+					log.Log("    Attribute: Signature", log.FINEST)
+					meth.signature, err = parseSignatureAttribute(klass, attrib.attrContent,
+						"method "+klass.utf8Refs[nameSlot].content+klass.utf8Refs[descSlot].content+
+							" of "+klass.className)
+					if err != nil {
+						return pos, err
+					}
 				default:
 					log.Log("    Attribute: "+klass.utf8Refs[attrib.attrName].content, log.FINEST)
 				}
@@ -147,6 +159,11 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 		return cfe("Error getting code length in Code attribute in " + klass.className)
 	}
 
+	if codeLength < 0 || pos+codeLength >= len(att.attrContent) {
+		return cfe("Invalid code length in Code attribute of " + methodName +
+			"() of " + klass.className + ": " + strconv.Itoa(codeLength))
+	}
+
 	var code []byte
 	for i := 0; i < codeLength; i++ {
 		code = append(code, att.attrContent[pos+1+i])
@@ -177,15 +194,19 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 			}
 
 			if ex.catchType != 0 {
-				catchType := klass.cpIndex[ex.catchType]
-				if catchType.entryType != ClassRef {
+				if ex.catchType >= len(klass.cpIndex) ||
+					klass.cpIndex[ex.catchType].entryType != ClassRef {
 					return cfe("Invalid catchType in method " + methodName +
 						" in " + klass.className)
-				} else {
-					log.Log("        Method: "+methodName+
-						" throws exception: "+klass.utf8Refs[catchType.slot].content,
-						log.FINEST)
 				}
+				catchType := klass.cpIndex[ex.catchType]
+				exceptionName, err := fetchUTF8string(klass, klass.classRefs[catchType.slot])
+				if err != nil {
+					return cfe("Invalid name for catchType in method " + methodName +
+						" in " + klass.className)
+				}
+				log.Log("        Method: "+methodName+" throws exception: "+exceptionName,
+					log.FINEST)
 			}
 			ca.exceptions = append(ca.exceptions, ex)
 		}
@@ -211,6 +232,37 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 			pos = loc
 			log.Log("        "+klass.utf8Refs[cat.attrName].content, log.FINEST)
 			ca.attributes = append(ca.attributes, cat)
+
+			if klass.utf8Refs[cat.attrName].content == "StackMapTable" {
+				if ca.stackMap != nil {
+					return cfe("More than one StackMapTable in Code attribute of " + methodName +
+						"() of " + klass.className)
+				}
+				if ca.stackMap, err = parseStackMapTable(cat.attrContent, code, klass, methodName); err != nil {
+					return err
+				}
+			}
+			if klass.utf8Refs[cat.attrName].content == "LineNumberTable" {
+				ca.lineNumbers, err = parseLineNumberTable(cat.attrContent, ca.lineNumbers, len(code),
+					klass, methodName)
+				if err != nil {
+					return err
+				}
+			}
+			if klass.utf8Refs[cat.attrName].content == "LocalVariableTable" {
+				ca.localVars, err = parseLocalVariableTable(cat.attrContent, ca.localVars,
+					"LocalVariableTable", len(code), maxLocals, klass, methodName)
+				if err != nil {
+					return err
+				}
+			}
+			if klass.utf8Refs[cat.attrName].content == "LocalVariableTypeTable" {
+				ca.localVarTypes, err = parseLocalVariableTable(cat.attrContent, ca.localVarTypes,
+					"LocalVariableTypeTable", len(code), maxLocals, klass, methodName)
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -242,11 +294,19 @@ func parseExceptionsMethodAttribute(attrib attr, meth *method, klass *ParsedClas
 			klass.utf8Refs[meth.name].content)
 	}
 
+	// the attribute must hold exactly the count and the exceptions, no more or less
+	if len(attrib.attrContent) != 2+2*exceptionCount {
+		return cfe("Exceptions attribute in method " + klass.utf8Refs[meth.name].content +
+			" has length " + strconv.Itoa(len(attrib.attrContent)) + ", but should have length " +
+			strconv.Itoa(2+2*exceptionCount) + " for " + strconv.Itoa(exceptionCount) + " exception(s)")
+	}
+
 	for ex := 0; ex < exceptionCount; ex++ {
 		// exception is an index into CP that points to a classRef
-		cRefIndex, _ := intFrom2Bytes(attrib.attrContent, loc+1)
+		cRefIndex, err := intFrom2Bytes(attrib.attrContent, loc+1)
 		loc += 2
-		if klass.cpIndex[cRefIndex].entryType != ClassRef {
+		if err != nil || cRefIndex < 1 || cRefIndex >= len(klass.cpIndex) ||
+			klass.cpIndex[cRefIndex].entryType != ClassRef {
 			return cfe("Exception attribute #" + strconv.Itoa(ex+1) +
 				" in method " + klass.utf8Refs[meth.name].content +
 				" does not point to a ClassRef CP entry")
@@ -286,14 +346,13 @@ func parseExceptionsMethodAttribute(attrib attr, meth *method, klass *ParsedClas
 //    } parameters[parameters_count];
 // }
 func parseMethodParametersAttribute(att attr, meth *method, klass *ParsedClass) error {
-	var err error
 	pos := 0
-	parametersCount := int(att.attrContent[pos])
-	pos += 1
-	if err != nil {
+	if len(att.attrContent) < 1 {
 		return cfe("Error getting number of Parameter attributes in method: " +
 			klass.utf8Refs[meth.name].content)
 	}
+	parametersCount := int(att.attrContent[pos])
+	pos += 1
 
 	for k := 0; k < parametersCount; k++ {
 		mpAttrib := paramAttrib{}
@@ -320,6 +379,7 @@ func parseMethodParametersAttribute(att attr, meth *method, klass *ParsedClass)
 		log.Log("        "+logName, log.FINEST)
 
 		accessFlags, err := intFrom2Bytes(att.attrContent, pos)
+		pos += 2
 		if err != nil {
 			return cfe("Error getting access flags of MethodParameters attribute #" +
 				strconv.Itoa(k+1) + " in " + klass.utf8Refs[meth.name].content)
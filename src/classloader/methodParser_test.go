@@ -7,6 +7,7 @@
 package classloader
 
 import (
+	"fmt"
 	"io"
 	"jacobin/globals"
 	"jacobin/log"
@@ -315,3 +316,196 @@ func TestValidMethodParameterAttribute(t *testing.T) {
 		t.Error("MethodParameter name: " + mp.name + " is not a valid unqualified name")
 	}
 }
+
+// Hello2's main() has a loop, so its Code attribute has a StackMapTable
+func TestParseStackMapTableOfHello2(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(getHello2Bytes(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Hello2: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	var frames []StackMapFrame
+	for _, m := range kd.Methods {
+		if kd.CP.Utf8Refs[m.Name] == "main" {
+			frames = m.CodeAttr.StackMapFrames
+		}
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 stack map frames in main(), got: %d", len(frames))
+	}
+
+	// a full frame at the loop's test, with args, an unused local, and the loop counter
+	full := frames[0]
+	if full.FrameType != 255 || full.OffsetDelta != 5 || len(full.Stack) != 0 || len(full.Locals) != 3 {
+		t.Fatalf("Expected a full frame at offset 5 with 3 locals and an empty stack, got: %+v", full)
+	}
+	args := fetchClassNameFromCPEntryNumber(&kd.CP, uint16(full.Locals[0].Index))
+	if full.Locals[0].Tag != ItemObject || args != "[Ljava/lang/String;" {
+		t.Errorf("Expected the first local to be a String[], got: %+v (%s)", full.Locals[0], args)
+	}
+	if full.Locals[1].Tag != ItemTop || full.Locals[2].Tag != ItemInteger {
+		t.Errorf("Expected the other locals to be top and an int, got: %+v", full.Locals[1:])
+	}
+
+	// then a same frame after the loop
+	if frames[1].FrameType != 17 || frames[1].OffsetDelta != 17 || frames[1].Locals != nil || frames[1].Stack != nil {
+		t.Errorf("Expected a same frame with an offset delta of 17, got: %+v", frames[1])
+	}
+}
+
+func TestParseStackMapTableFrameTypes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := ParsedClass{className: "Frames"}
+	klass.cpIndex = []cpEntry{{}, {ClassRef, 0}, {UTF8, 0}}
+	klass.cpCount = 3
+
+	content := []byte{0, 6,
+		70, 1, // same_locals_1_stack_item, offset delta 6: int
+		247, 0, 200, 7, 0, 1, // same_locals_1_stack_item_extended, offset delta 200: Object #1
+		249, 0, 3, // chop 2 locals, offset delta 3
+		251, 1, 0, // same_frame_extended, offset delta 256
+		253, 0, 4, 4, 8, 0, 9, // append a long and an object created at offset 9, offset delta 4
+		255, 0, 1, 0, 1, 6, 0, 2, 5, 3, // full frame, offset delta 1: uninitialized this; null, double
+	}
+	code := make([]byte, 480) // the last frame is at offset 475
+	code[9] = opNew
+	frames, err := parseStackMapTable(content, code, &klass, "frames")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the StackMapTable: %s", err.Error())
+	}
+	expected := []StackMapFrame{
+		{FrameType: 70, OffsetDelta: 6, Stack: []VerificationType{{Tag: ItemInteger}}},
+		{FrameType: 247, OffsetDelta: 200, Stack: []VerificationType{{Tag: ItemObject, Index: 1}}},
+		{FrameType: 249, OffsetDelta: 3},
+		{FrameType: 251, OffsetDelta: 256},
+		{FrameType: 253, OffsetDelta: 4, Locals: []VerificationType{{Tag: ItemLong}, {Tag: ItemUninitialized, Index: 9}}},
+		{FrameType: 255, OffsetDelta: 1, Locals: []VerificationType{{Tag: ItemUninitializedThis}},
+			Stack: []VerificationType{{Tag: ItemNull}, {Tag: ItemDouble}}},
+	}
+	if fmt.Sprintf("%+v", frames) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("Expected frames:\n%+v\ngot:\n%+v", expected, frames)
+	}
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	invalid := [][]byte{
+		{0, 1},               // no frames
+		{0, 1, 128},          // a reserved frame type
+		{0, 1, 64, 9},        // an invalid verification type
+		{0, 1, 64, 7, 0, 2},  // an object whose class isn't a ClassRef
+		{0, 1, 252, 0, 1},    // the appended local is missing
+		{0, 1, 255, 0, 1, 0}, // the full frame is truncated
+	}
+	for _, content := range invalid {
+		if _, err := parseStackMapTable(content, code, &klass, "frames"); err == nil {
+			t.Errorf("Expected an error for invalid StackMapTable % X, but got none", content)
+		}
+	}
+}
+
+// frames must be within the method's bytecode, and uninitialized objects must have
+// been created by a new instruction
+func TestParseStackMapTableCorrupt(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{className: "Frames"}
+	klass.cpIndex = []cpEntry{{}, {ClassRef, 0}, {UTF8, 0}}
+	klass.cpCount = 3
+	code := make([]byte, 20)
+	code[4] = opNew
+
+	var errs []error
+	for _, content := range [][]byte{
+		{0, 2, 10, 9},              // the second frame is at offset 20, past the end
+		{0, 1, 251, 0, 20},         // so is this one
+		{0, 1, 64, 8, 0, 3},        // the object at offset 3 wasn't created by new
+		{0, 1, 64, 8, 0, 40},       // and there's no instruction at offset 40
+		{0, 1, 252, 0, 1, 1, 0},    // a stray byte after the last frame
+		{0, 2, 252, 0, 1, 8, 0},    // the second frame is missing
+		{0, 1, 255, 0, 0, 0, 2, 1}, // the full frame's stack is short an item
+	} {
+		_, err := parseStackMapTable(content, code, &klass, "run")
+		errs = append(errs, err)
+	}
+	valid, err := parseStackMapTable([]byte{0, 2, 10, 252, 0, 8, 8, 0, 4}, code, &klass, "run")
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error for corrupt StackMapTable #%d, but got none", i)
+		}
+	}
+	if err != nil || len(valid) != 2 {
+		t.Errorf("Expected two frames in a valid StackMapTable, got: %+v, %v", valid, err)
+	}
+	msg := string(out)
+	for _, expected := range []string{
+		"Invalid offset 20 (the code is 20 bytes long) in frame #1 of StackMapTable in run() of Frames",
+		"Invalid offset of new instruction 3 in frame #0 of StackMapTable in run() of Frames",
+		"StackMapTable has 1 extra byte(s) in run() of Frames",
+		"StackMapTable is truncated in run() of Frames",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected error: %s, got: %s", expected, msg)
+		}
+	}
+}
+
+// the Exceptions attribute must be exactly as long as its exception count requires
+func TestMethodExceptionsAttributeWrongLength(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{}
+	klass.cpIndex = []cpEntry{{}, {UTF8, 0}, {ClassRef, 0}, {UTF8, 1}}
+	klass.utf8Refs = []utf8Entry{{"testMethod"}, {"java/io/IOException"}}
+	klass.classRefs = []int{3}
+	klass.cpCount = 4
+	meth := method{name: 0}
+
+	var errs []error
+	for _, content := range [][]byte{
+		{0, 1, 0, 2, 0, 2}, // an extra exception beyond the count
+		{0, 2, 0, 2},       // one fewer exception than the count
+		{0, 1, 0, 2, 0},    // a stray byte
+	} {
+		errs = append(errs, parseExceptionsMethodAttribute(attr{attrContent: content}, &meth, &klass))
+	}
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error for Exceptions attribute #%d, which has the wrong length", i)
+		}
+	}
+	if !strings.Contains(string(out), "Exceptions attribute in method testMethod has length 6, "+
+		"but should have length 4 for 1 exception(s)") {
+		t.Errorf("Expected an error about the length of the attribute, got: %s", string(out))
+	}
+}
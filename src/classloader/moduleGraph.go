@@ -0,0 +1,130 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ModuleGraph is the set of modules on the module path, indexed by package
+type ModuleGraph struct {
+	Modules  map[string]ModuleData // module name -> the module's Module attribute
+	packages map[string]string     // package name -> the module that exports it
+}
+
+// SplitPackageError reports a package that's exported by two modules, which Java 9 and
+// later prohibit. Reader is the module that reads the package from both, if there is one.
+type SplitPackageError struct {
+	Package string
+	Module1 string
+	Module2 string
+	Reader  string
+}
+
+func (e *SplitPackageError) Error() string {
+	pkg := strings.ReplaceAll(e.Package, "/", ".")
+	if e.Reader != "" {
+		return "module " + e.Reader + " reads package " + pkg + " from both module " +
+			e.Module1 + " and module " + e.Module2
+	}
+	return "package " + pkg + " is exported by both module " + e.Module1 + " and module " + e.Module2
+}
+
+// BuildModuleGraph indexes the packages exported by the given modules. If two modules
+// export the same package, it returns a SplitPackageError, whose module names are in
+// alphabetic order.
+func BuildModuleGraph(modules []ModuleData) (*ModuleGraph, error) {
+	graph := &ModuleGraph{
+		Modules:  make(map[string]ModuleData),
+		packages: make(map[string]string),
+	}
+
+	// sort the modules, so the same split is always reported in the same way
+	sorted := append([]ModuleData(nil), modules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, module := range sorted {
+		graph.Modules[module.Name] = module
+		for _, export := range module.Exports {
+			pkg := export.Package
+			owner, exported := graph.packages[pkg]
+			if exported && owner != module.Name {
+				return nil, &SplitPackageError{
+					Package: pkg,
+					Module1: owner,
+					Module2: module.Name,
+					Reader:  graph.readerOfBoth(owner, module.Name, sorted),
+				}
+			}
+			graph.packages[pkg] = module.Name
+		}
+	}
+	return graph, nil
+}
+
+// ModuleOf returns the name of the module that exports the package (e.g., java/lang)
+func (g *ModuleGraph) ModuleOf(pkg string) (string, bool) {
+	module, ok := g.packages[pkg]
+	return module, ok
+}
+
+// returns the first module that requires both of the named modules, or "" if none does
+func (g *ModuleGraph) readerOfBoth(module1, module2 string, modules []ModuleData) string {
+	for _, module := range modules {
+		reads1, reads2 := false, false
+		for _, required := range module.Requires {
+			reads1 = reads1 || required.Module == module1
+			reads2 = reads2 || required.Module == module2
+		}
+		if reads1 && reads2 {
+			return module.Name
+		}
+	}
+	return ""
+}
+
+// classReader reads big-endian values from a class file. Once a read runs past the end
+// of the bytes, err is set, and all further reads return zero values.
+type classReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.b) {
+		r.err = errors.New("read past end of class")
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *classReader) u1() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *classReader) u2() int {
+	if b := r.bytes(2); b != nil {
+		return int(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *classReader) u4() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
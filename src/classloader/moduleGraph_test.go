@@ -0,0 +1,268 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns the bytes of a minimal module-info class for the described module, of which
+// only the name, version, and the names of the modules it requires and the packages
+// it exports are used
+func moduleInfoBytes(desc ModuleData) []byte {
+	var cp []byte
+	cpCount := 1
+	utf8 := func(s string) int {
+		cp = append(cp, UTF8)
+		cp = binary.BigEndian.AppendUint16(cp, uint16(len(s)))
+		cp = append(cp, s...)
+		cpCount++
+		return cpCount - 1
+	}
+	ref := func(tag byte, name string) int {
+		nameIndex := utf8(name)
+		cp = append(cp, tag)
+		cp = binary.BigEndian.AppendUint16(cp, uint16(nameIndex))
+		cpCount++
+		return cpCount - 1
+	}
+
+	thisClass := ref(ClassRef, "module-info")
+	attrName := utf8("Module")
+	var attr []byte
+	attr = binary.BigEndian.AppendUint16(attr, uint16(ref(Module, desc.Name)))
+	attr = append(attr, 0, 0) // flags
+	if desc.Version != "" {
+		attr = binary.BigEndian.AppendUint16(attr, uint16(utf8(desc.Version)))
+	} else {
+		attr = append(attr, 0, 0)
+	}
+	attr = binary.BigEndian.AppendUint16(attr, uint16(len(desc.Requires)))
+	for _, required := range desc.Requires {
+		attr = binary.BigEndian.AppendUint16(attr, uint16(ref(Module, required.Module)))
+		attr = append(attr, 0, 0, 0, 0)
+	}
+	attr = binary.BigEndian.AppendUint16(attr, uint16(len(desc.Exports)))
+	for _, pkg := range desc.Exports {
+		attr = binary.BigEndian.AppendUint16(attr, uint16(ref(Package, pkg.Package)))
+		attr = append(attr, 0, 0, 0, 0) // flags, and no modules it's exported to
+	}
+	attr = append(attr, 0, 0, 0, 0, 0, 0) // no opens, uses, or provides
+
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x35}
+	b = binary.BigEndian.AppendUint16(b, uint16(cpCount))
+	b = append(b, cp...)
+	b = append(b, 0x80, 0x00) // ACC_MODULE
+	b = binary.BigEndian.AppendUint16(b, uint16(thisClass))
+	b = append(b, 0, 0, 0, 0, 0, 0, 0, 0) // no superclass, interfaces, fields, or methods
+	b = append(b, 0, 1)                   // one attribute: Module
+	b = binary.BigEndian.AppendUint16(b, uint16(attrName))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(attr)))
+	return append(b, attr...)
+}
+
+// returns the Requires of a module that requires the named modules
+func requires(modules ...string) []ModuleRequires {
+	var reqs []ModuleRequires
+	for _, module := range modules {
+		reqs = append(reqs, ModuleRequires{Module: module})
+	}
+	return reqs
+}
+
+// returns the Exports of a module that exports the named packages to all modules
+func exports(packages ...string) []ModulePackage {
+	var pkgs []ModulePackage
+	for _, pkg := range packages {
+		pkgs = append(pkgs, ModulePackage{Package: pkg})
+	}
+	return pkgs
+}
+
+func TestBuildModuleGraphIndexesPackages(t *testing.T) {
+	graph, err := BuildModuleGraph([]ModuleData{
+		{Name: "java.base", Exports: exports("java/lang", "java/util")},
+		{Name: "org.app", Requires: requires("java.base"), Exports: exports("org/app")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error building module graph: %s", err.Error())
+	}
+
+	if module, ok := graph.ModuleOf("java/util"); !ok || module != "java.base" {
+		t.Errorf("Expected java/util to be in java.base, got: %q", module)
+	}
+	if module, ok := graph.ModuleOf("org/app"); !ok || module != "org.app" {
+		t.Errorf("Expected org/app to be in org.app, got: %q", module)
+	}
+	if _, ok := graph.ModuleOf("org/missing"); ok {
+		t.Error("Expected org/missing to be in no module")
+	}
+}
+
+func TestBuildModuleGraphRejectsSplitPackage(t *testing.T) {
+	_, err := BuildModuleGraph([]ModuleData{
+		{Name: "org.app", Requires: requires("org.lib.two", "org.lib.one")},
+		{Name: "org.lib.two", Exports: exports("org/lib/util")},
+		{Name: "org.lib.one", Exports: exports("org/lib", "org/lib/util")},
+	})
+
+	var splitErr *SplitPackageError
+	if !errors.As(err, &splitErr) {
+		t.Fatalf("Expected a SplitPackageError, got: %v", err)
+	}
+	if splitErr.Package != "org/lib/util" || splitErr.Module1 != "org.lib.one" ||
+		splitErr.Module2 != "org.lib.two" || splitErr.Reader != "org.app" {
+		t.Errorf("Unexpected split package error: %+v", *splitErr)
+	}
+
+	expected := "module org.app reads package org.lib.util from both module org.lib.one and module org.lib.two"
+	if err.Error() != expected {
+		t.Errorf("Expected error: %s\n got: %s", expected, err.Error())
+	}
+}
+
+func TestBuildModuleGraphSplitPackageWithNoReader(t *testing.T) {
+	_, err := BuildModuleGraph([]ModuleData{
+		{Name: "org.b", Exports: exports("org/shared")},
+		{Name: "org.a", Exports: exports("org/shared")},
+	})
+	if err == nil {
+		t.Fatal("Expected a split package error, got none")
+	}
+
+	expected := "package org.shared is exported by both module org.a and module org.b"
+	if err.Error() != expected {
+		t.Errorf("Expected error: %s\n got: %s", expected, err.Error())
+	}
+}
+
+func TestJmodDescriptorOfInvalidModuleInfo(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	filename := filepath.Join(t.TempDir(), "truncated.jmod")
+	writeTestJmod(t, filename, map[string][]byte{
+		"classes/module-info.class": moduleInfoBytes(ModuleData{Name: "org.app"})[:40],
+	})
+	jmodFile, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Unable to open jmod file: %s", err.Error())
+	}
+	defer jmodFile.Close()
+
+	jmod := Jmod{File: *jmodFile}
+	if _, err = jmod.descriptor(); err == nil {
+		t.Error("Expected an error reading a truncated module-info class, got none")
+	}
+}
+
+func TestJmodDescriptor(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get cwd")
+	}
+	jmodFile, err := os.Open(filepath.Join(pwd, "..", "..", "testdata", "jmod", "jacobinfull.jmod"))
+	if err != nil {
+		t.Fatalf("Unable to open jmod file: %s", err.Error())
+	}
+	defer jmodFile.Close()
+
+	jmod := Jmod{File: *jmodFile}
+	desc, err := jmod.descriptor()
+	if err != nil {
+		t.Fatalf("Unexpected error reading module-info: %s", err.Error())
+	}
+	if desc.Name == "" || len(desc.Requires) == 0 {
+		t.Errorf("Expected a named module that requires java.base, got: %+v", desc)
+	}
+}
+
+func TestJmodModuleName(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get cwd")
+	}
+	jmodFile, err := os.Open(filepath.Join(pwd, "..", "..", "testdata", "jmod", "jacobinfull.jmod"))
+	if err != nil {
+		t.Fatalf("Unable to open jmod file: %s", err.Error())
+	}
+	defer jmodFile.Close()
+
+	jmod := Jmod{File: *jmodFile}
+	if name := jmod.ModuleName(); name != "jacobin" {
+		t.Errorf("Expected the module in jacobinfull.jmod to be jacobin, got: %q", name)
+	}
+	if version := jmod.ModuleVersion(); version != "" {
+		t.Errorf("Expected the module in jacobinfull.jmod to have no version, got: %q", version)
+	}
+}
+
+func TestJmodManagerJmodForModule(t *testing.T) {
+	log.Init()
+
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "one.jmod"), map[string][]byte{
+		"classes/module-info.class": moduleInfoBytes(ModuleData{
+			Name: "org.one", Version: "2.1", Exports: exports("org/one")}),
+	})
+	writeTestJmod(t, filepath.Join(dir, "unnamed.jmod"), map[string][]byte{
+		"classes/org/unnamed/Main.class": {0xCA, 0xFE},
+	})
+
+	manager, err := InitJmodManager(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error initializing JmodManager: %s", err.Error())
+	}
+	jmod, ok := manager.JmodForModule("org.one")
+	if !ok || filepath.Base(jmod.File.Name()) != "one.jmod" {
+		t.Fatalf("Expected org.one to be in one.jmod, got: %v", jmod)
+	}
+	if jmod.ModuleName() != "org.one" || jmod.ModuleVersion() != "2.1" {
+		t.Errorf("Expected module org.one 2.1, got: %s %s", jmod.ModuleName(), jmod.ModuleVersion())
+	}
+	if _, ok = manager.JmodForModule("unnamed"); ok {
+		t.Error("Expected no JMOD for a module name that isn't in any JMOD")
+	}
+}
+
+func TestInitJmodManagerRejectsSplitPackage(t *testing.T) {
+	log.Init()
+
+	dir := t.TempDir()
+	writeTestJmod(t, filepath.Join(dir, "org.one.jmod"), map[string][]byte{
+		"classes/module-info.class": moduleInfoBytes(ModuleData{
+			Name: "org.one", Exports: exports("org/shared")}),
+	})
+	writeTestJmod(t, filepath.Join(dir, "org.two.jmod"), map[string][]byte{
+		"classes/module-info.class": moduleInfoBytes(ModuleData{
+			Name: "org.two", Exports: exports("org/shared")}),
+	})
+
+	_, err := InitJmodManager(dir)
+	if err == nil {
+		t.Fatal("Expected a split package error, got none")
+	}
+	if !strings.Contains(err.Error(), "org.shared") || !strings.Contains(err.Error(), "org.one") ||
+		!strings.Contains(err.Error(), "org.two") {
+		t.Errorf("Expected the error to name the package and both modules, got: %s", err.Error())
+	}
+}
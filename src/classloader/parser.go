@@ -42,9 +42,12 @@ func parse(rawBytes []byte) (ParsedClass, error) {
 	}
 
 	pos, err := parseConstantPool(rawBytes, &pClass)
-	if err != nil || pos < 10 {
+	if err != nil {
 		return pClass, err
 	}
+	if pos < 10 {
+		return pClass, cfe("Invalid constant pool")
+	}
 
 	pos, err = parseAccessFlags(rawBytes, pos, &pClass)
 	if err != nil {
@@ -280,7 +283,7 @@ func parseClassName(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 }
 
 // Get the name of the superclass. The logic is identical to that of parseClassName()
-// All classes, except java/lang/Object have superclasses.
+// All classes, except java/lang/Object and module-info classes, have superclasses.
 func parseSuperClassName(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	pos := loc
 	index, err := intFrom2Bytes(bytes, pos+1)
@@ -291,7 +294,7 @@ func parseSuperClassName(bytes []byte, loc int, klass *ParsedClass) (int, error)
 	}
 
 	if index == 0 {
-		if klass.className != "java/lang/Object" {
+		if klass.className != "java/lang/Object" && !klass.classIsModule {
 			return pos, cfe("invaild index for superclass name. Got: 0," +
 				" but class is not java/lang/Object")
 		} else {
@@ -419,7 +422,6 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	pos := loc
 	for i := 0; i < klass.fieldCount; i += 1 {
 		f := field{}
-		f.constValue = nil
 
 		accessFlags, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
@@ -464,82 +466,30 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 				return pos, errors.New("") // error message will already have been displayed
 			}
 			attrName := klass.utf8Refs[attribute.attrName].content
-			// if the attribute is a constant value (for initializing the field)
-			// then stick the value into the field struct. That value is a pointer
-			// into the CP and its value must be converted based on the type of
-			// field we're dealing with (shown in the desc data item)
+			// if the attribute is a constant value (for initializing the field), keep the
+			// index of the CP entry that holds the value. That the entry's type matches
+			// the field's type is checked in the format check.
 			if attrName == "ConstantValue" {
-				desc := klass.utf8Refs[f.description].content
-				switch desc {
-				case "L", "Z": // TODO: Find out how to process these
-					f.constValue = nil
-				case "B": // byte--same logic as for "I", only error message is different
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for byte " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.intConsts[entryInCp.slot]
-				case "C": // char--same logic as for "I", only error message is different
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for char " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.intConsts[entryInCp.slot]
-				case "D": // double
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != DoubleConst {
-						return pos, cfe("error: wrong type of constant value for double " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.doubles[entryInCp.slot]
-				case "F": // float
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != FloatConst {
-						return pos, cfe("error: wrong type of constant value for float " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.floats[entryInCp.slot]
-				case "I": // integer
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for integer " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.intConsts[entryInCp.slot]
-				case "J": // long
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != LongConst {
-						return pos, cfe("error: wrong type of constant value for long " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.longConsts[entryInCp.slot]
-				case "S": // short--same logic as int, only message is different
-					indexIntoCP := int(attribute.attrContent[0])*256 +
-						int(attribute.attrContent[1])
-					entryInCp := klass.cpIndex[indexIntoCP]
-					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for short " +
-							klass.utf8Refs[f.name].content)
-					}
-					f.constValue = klass.intConsts[entryInCp.slot]
+				if f.constValue != 0 {
+					return pos, cfe("error: more than one ConstantValue attribute for field " +
+						klass.utf8Refs[f.name].content)
+				}
+				indexIntoCP, err := intFrom2Bytes(attribute.attrContent, 0)
+				if err != nil || attribute.attrSize != 2 || indexIntoCP < 1 || indexIntoCP >= len(klass.cpIndex) {
+					return pos, cfe("error: invalid constant value index for field " +
+						klass.utf8Refs[f.name].content)
 				}
+				f.constValue = indexIntoCP
 			} else { // append the attribute only if it's not ConstantValue
 				f.attributes = append(f.attributes, attribute)
 			}
+			if attrName == "Signature" {
+				f.signature, err = parseSignatureAttribute(klass, attribute.attrContent,
+					"field "+klass.utf8Refs[f.name].content+" of "+klass.className)
+				if err != nil {
+					return pos, err
+				}
+			}
 			pos = k
 		}
 
@@ -606,48 +556,445 @@ func parseClassAttributes(bytes []byte, loc int, klass *ParsedClass) (int, error
 
 		switch klass.utf8Refs[attrib.attrName].content {
 		case "BootstrapMethods":
-			// see: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.7.23
-			loc = 0
-			boostrapCount, err1 := u16From2bytes(attrib.attrContent, loc)
-			loc += 2
-			if err1 != nil {
-				break // error msg will already have been shown
-			} else {
-				klass.bootstrapCount = int(boostrapCount)
+			if err = parseBootstrapMethods(klass, attrib.attrContent); err != nil {
+				return pos, err
+			}
+
+		case "Deprecated":
+			klass.deprecated = true
+
+		case "EnclosingMethod":
+			if err = parseEnclosingMethod(klass, attrib.attrContent); err != nil {
+				return pos, err
+			}
+
+		case "InnerClasses":
+			if err = parseInnerClasses(klass, attrib.attrContent); err != nil {
+				return pos, err
+			}
+
+		case "Module":
+			if err = parseModule(klass, attrib.attrContent); err != nil {
+				return pos, err
+			}
+
+		case "NestHost":
+			if klass.javaVersion >= 55 { // nests were introduced in Java 11
+				if err = parseNestHost(klass, attrib.attrContent); err != nil {
+					return pos, err
+				}
 			}
-			for m := 0; m < klass.bootstrapCount; m++ {
-				bsm := bootstrapMethod{}
-				methodRef, err2 := u16From2bytes(attrib.attrContent, loc)
-				loc += 2
-				if err2 != nil || klass.cpIndex[methodRef].entryType != MethodHandle {
-					return pos, cfe("Invalid method reference in Boostrap method #" + strconv.Itoa(m))
-				} else {
-					bsm.methodRef = int(methodRef)
+
+		case "NestMembers":
+			if klass.javaVersion >= 55 {
+				if err = parseNestMembers(klass, attrib.attrContent); err != nil {
+					return pos, err
 				}
+			}
 
-				bootstrapArgCount, _ := intFrom2Bytes(attrib.attrContent, loc)
-				loc += 2
-				if bootstrapArgCount > 0 {
-					for n := 0; n < bootstrapArgCount; n++ {
-						arg, _ := intFrom2Bytes(attrib.attrContent, loc)
-						loc += 2
-						bsm.args = append(bsm.args, arg)
-					}
+		case "PermittedSubclasses":
+			if klass.javaVersion >= 61 { // sealed classes were introduced in Java 17
+				if err = parsePermittedSubclasses(klass, attrib.attrContent); err != nil {
+					return pos, err
 				}
-				klass.bootstraps = append(klass.bootstraps, bsm)
 			}
-			_ = log.Log("    "+strconv.Itoa(klass.bootstrapCount)+" boostrap method(s)", log.FINEST)
 
-		case "Deprecated":
-			klass.deprecated = true
+		case "Record":
+			if klass.javaVersion >= 60 { // records were introduced in Java 16
+				if err = parseRecord(klass, attrib.attrContent); err != nil {
+					return pos, err
+				}
+			}
+
+		case "Signature":
+			if klass.signature, err = parseSignatureAttribute(klass, attrib.attrContent,
+				"class "+klass.className); err != nil {
+				return pos, err
+			}
 
 		case "SourceFile":
 			sourceNameIndex, _ := intFrom2Bytes(attrib.attrContent, 0)
-			utf8slot := klass.cpIndex[sourceNameIndex].slot
-			sourceFile := klass.utf8Refs[utf8slot].content // points to the name of the source file
+			sourceFile, err := fetchUTF8string(klass, sourceNameIndex) // the name of the source file
+			if err != nil {
+				return pos, cfe("Invalid SourceFile attribute in class: " + klass.className)
+			}
 			klass.sourceFile = sourceFile
 			_ = log.Log("Source file: "+sourceFile, log.FINEST)
 		}
 	}
 	return pos, nil
 }
+
+// parseBootstrapMethods parses the content of the BootstrapMethods class attribute into
+// klass.BootstrapMethods. Each bootstrap method is the CP index of its MethodHandle and
+// the CP indexes of its static arguments, whose validity is checked in the format check.
+// See: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.23
+// klass.BootstrapMethods is non-nil once the attribute has been parsed, even if it holds no
+// bootstrap methods, which is how the format check tells whether the class has one.
+func parseBootstrapMethods(klass *ParsedClass, content []byte) error {
+	if klass.BootstrapMethods != nil {
+		return cfe("Class " + klass.className + " has more than one BootstrapMethods attribute")
+	}
+
+	loc := 0
+	bootstrapCount, err := intFrom2Bytes(content, loc)
+	if err != nil {
+		return cfe("Invalid BootstrapMethods attribute in class: " + klass.className)
+	}
+	loc += 2
+	klass.bootstrapCount = bootstrapCount
+	klass.BootstrapMethods = make([]BootstrapMethod, 0, bootstrapCount)
+
+	for m := 0; m < bootstrapCount; m++ {
+		bsm := BootstrapMethod{}
+		methodRef, err := intFrom2Bytes(content, loc)
+		loc += 2
+		if err != nil || !cpEntryIs(klass, methodRef, MethodHandle) {
+			return cfe("Invalid method reference in Bootstrap method #" + strconv.Itoa(m))
+		}
+		bsm.MethodRef = uint16(methodRef)
+
+		argCount, err := intFrom2Bytes(content, loc)
+		loc += 2
+		if err != nil {
+			return cfe("Missing argument count in Bootstrap method #" + strconv.Itoa(m))
+		}
+		for n := 0; n < argCount; n++ {
+			arg, err := intFrom2Bytes(content, loc)
+			loc += 2
+			if err != nil {
+				return cfe("Missing argument #" + strconv.Itoa(n) + " in Bootstrap method #" + strconv.Itoa(m))
+			}
+			bsm.Args = append(bsm.Args, uint16(arg))
+		}
+		klass.BootstrapMethods = append(klass.BootstrapMethods, bsm)
+	}
+	_ = log.Log("    "+strconv.Itoa(klass.bootstrapCount)+" bootstrap method(s)", log.FINEST)
+	return nil
+}
+
+// parseNestHost parses the content of the NestHost attribute of a nested class into
+// klass.nestHost: the name of the class at the top of its nest, which it shares private
+// access with. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.28
+func parseNestHost(klass *ParsedClass, content []byte) error {
+	if klass.nestHost != "" {
+		return cfe("Class " + klass.className + " has more than one NestHost attribute")
+	}
+
+	r := &classReader{b: content}
+	host, ok := moduleCPName(klass, r.u2(), ClassRef)
+	if r.err != nil || r.pos != len(content) || !ok {
+		return cfe("Invalid NestHost attribute in class: " + klass.className)
+	}
+	klass.nestHost = host
+	_ = log.Log("    nest host: "+host, log.FINEST)
+	return nil
+}
+
+// parseNestMembers parses the content of the NestMembers attribute of a nest host into
+// klass.nestMembers: the names of the classes that claim membership of its nest.
+// klass.nestMembers is non-nil once the attribute has been parsed. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.29
+func parseNestMembers(klass *ParsedClass, content []byte) error {
+	if klass.nestMembers != nil {
+		return cfe("Class " + klass.className + " has more than one NestMembers attribute")
+	}
+
+	r := &classReader{b: content}
+	count := r.u2()
+	members := make([]string, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		name, ok := moduleCPName(klass, r.u2(), ClassRef)
+		if !ok && r.err == nil {
+			return cfe("Invalid CP index in NestMembers entry #" + strconv.Itoa(i) +
+				" in class: " + klass.className)
+		}
+		members = append(members, name)
+	}
+	if r.err != nil || r.pos != len(content) {
+		return cfe("Invalid NestMembers attribute in class: " + klass.className)
+	}
+	klass.nestMembers = members
+	_ = log.Log("    "+strconv.Itoa(count)+" nest member(s)", log.FINEST)
+	return nil
+}
+
+// parsePermittedSubclasses parses the content of the PermittedSubclasses attribute of
+// a sealed class or interface into klass.permitted: the names of the classes that are
+// permitted to extend or implement it. klass.permitted is non-nil once the attribute
+// has been parsed, which is how a class is known to be sealed. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.31
+func parsePermittedSubclasses(klass *ParsedClass, content []byte) error {
+	if klass.permitted != nil {
+		return cfe("Class " + klass.className + " has more than one PermittedSubclasses attribute")
+	}
+
+	r := &classReader{b: content}
+	count := r.u2()
+	permitted := make([]string, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		name, ok := moduleCPName(klass, r.u2(), ClassRef)
+		if !ok && r.err == nil {
+			return cfe("Invalid CP index in PermittedSubclasses entry #" + strconv.Itoa(i) +
+				" in class: " + klass.className)
+		}
+		permitted = append(permitted, name)
+	}
+	if r.err != nil || r.pos != len(content) {
+		return cfe("Invalid PermittedSubclasses attribute in class: " + klass.className)
+	}
+	klass.permitted = permitted
+	_ = log.Log("    "+strconv.Itoa(count)+" permitted subclass(es)", log.FINEST)
+	return nil
+}
+
+// parseRecord parses the content of the Record attribute of a record class into
+// klass.components. Each component has a name, a descriptor, and attributes; only its
+// Signature attribute is parsed here, and its annotations, like those of fields and
+// methods, are parsed in parseAnnotationAttributes(). klass.components is non-nil once
+// the attribute has been parsed, even for a record with no components. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.30
+func parseRecord(klass *ParsedClass, content []byte) error {
+	if klass.components != nil {
+		return cfe("Class " + klass.className + " has more than one Record attribute")
+	}
+	invalid := func(i int) error {
+		return cfe("Invalid record component #" + strconv.Itoa(i) + " in Record attribute in class: " +
+			klass.className)
+	}
+
+	r := &classReader{b: content}
+	count := r.u2()
+	components := make([]recordComponent, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		rc := recordComponent{}
+		var err error
+		nameIndex, descIndex := r.u2(), r.u2()
+		if r.err != nil {
+			break
+		}
+		if rc.name, err = fetchUTF8slot(klass, nameIndex); err != nil {
+			return invalid(i)
+		}
+		if rc.description, err = fetchUTF8slot(klass, descIndex); err != nil {
+			return invalid(i)
+		}
+
+		for attrCount := r.u2(); attrCount > 0 && r.err == nil; attrCount-- {
+			a := attr{}
+			nameIndex := r.u2()
+			a.attrSize = int(r.u4())
+			a.attrContent = r.bytes(a.attrSize)
+			if r.err != nil {
+				break
+			}
+			if a.attrName, err = fetchUTF8slot(klass, nameIndex); err != nil {
+				return invalid(i)
+			}
+			if klass.utf8Refs[a.attrName].content == "Signature" {
+				rc.signature, err = parseSignatureAttribute(klass, a.attrContent,
+					"record component "+klass.utf8Refs[rc.name].content+" of "+klass.className)
+				if err != nil {
+					return err
+				}
+			}
+			rc.attributes = append(rc.attributes, a)
+		}
+		components = append(components, rc)
+	}
+
+	if r.err != nil || r.pos != len(content) {
+		return cfe("Invalid Record attribute in class: " + klass.className)
+	}
+	klass.components = components
+	_ = log.Log("    "+strconv.Itoa(count)+" record component(s)", log.FINEST)
+	return nil
+}
+
+// parseSignatureAttribute returns the generic signature in the content of a Signature
+// attribute of a class, field, or method (which is described by owner). The attribute
+// is the CP index of the signature's UTF8 entry; the signature itself is parsed only
+// when it's needed (see signatures.go). See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.9
+func parseSignatureAttribute(klass *ParsedClass, content []byte, owner string) (string, error) {
+	index, err := intFrom2Bytes(content, 0)
+	if err != nil || len(content) != 2 || !cpEntryIs(klass, index, UTF8) {
+		return "", cfe("Invalid Signature attribute of " + owner)
+	}
+	return fetchUTF8string(klass, index)
+}
+
+// parseInnerClasses parses the content of the InnerClasses class attribute into
+// klass.innerClasses. Each entry is the CP indexes of an inner class, of its outer
+// class, and of its simple name, and the inner class's access flags. The outer class
+// and the name can be 0, for local and anonymous classes. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.6
+func parseInnerClasses(klass *ParsedClass, content []byte) error {
+	classCount, err := intFrom2Bytes(content, 0)
+	if err != nil {
+		return cfe("Invalid InnerClasses attribute in class: " + klass.className)
+	}
+
+	loc := 2
+	for i := 0; i < classCount; i++ {
+		var values [4]int
+		for v := range values {
+			values[v], err = intFrom2Bytes(content, loc)
+			loc += 2
+			if err != nil {
+				return cfe("InnerClasses entry #" + strconv.Itoa(i) + " is truncated in class: " + klass.className)
+			}
+		}
+
+		entry := innerClassEntry{
+			innerClass: values[0], outerClass: values[1], innerName: values[2], accessFlags: values[3]}
+		if !cpEntryIs(klass, entry.innerClass, ClassRef) ||
+			(entry.outerClass != 0 && !cpEntryIs(klass, entry.outerClass, ClassRef)) ||
+			(entry.innerName != 0 && !cpEntryIs(klass, entry.innerName, UTF8)) {
+			return cfe("Invalid CP index in InnerClasses entry #" + strconv.Itoa(i) + " in class: " + klass.className)
+		}
+		klass.innerClasses = append(klass.innerClasses, entry)
+	}
+	_ = log.Log("    "+strconv.Itoa(classCount)+" inner class(es)", log.FINEST)
+	return nil
+}
+
+// parseEnclosingMethod parses the content of the EnclosingMethod class attribute into
+// klass.enclosing: the CP index of the ClassRef of the class that encloses a local or
+// anonymous class, and that of the NameAndType of the enclosing method, or 0 if the
+// class isn't enclosed by a method. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.7
+func parseEnclosingMethod(klass *ParsedClass, content []byte) error {
+	if klass.enclosing != nil {
+		return cfe("Class " + klass.className + " has more than one EnclosingMethod attribute")
+	}
+
+	r := &classReader{b: content}
+	enclosing := enclosingMethod{class: r.u2(), method: r.u2()}
+	if r.err != nil || len(content) != 4 {
+		return cfe("Invalid EnclosingMethod attribute in class: " + klass.className)
+	}
+	if !cpEntryIs(klass, enclosing.class, ClassRef) ||
+		(enclosing.method != 0 && !cpEntryIs(klass, enclosing.method, NameAndType)) {
+		return cfe("Invalid CP index in EnclosingMethod attribute in class: " + klass.className)
+	}
+	klass.enclosing = &enclosing
+	return nil
+}
+
+// parseModule parses the content of the Module attribute of a module-info class into
+// klass.moduleData: the module's name, flags, and version, and the modules it requires
+// and the packages it exports and opens, the services it uses, and the implementations
+// of the services it provides. Service and implementation names are class names, in
+// java/lang/Object format. The module's name replaces the one taken from the CP, as a
+// module-info class has a Module entry in the CP for each module it refers to. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.25
+func parseModule(klass *ParsedClass, content []byte) error {
+	if klass.moduleData != nil {
+		return cfe("Class " + klass.className + " has more than one Module attribute")
+	}
+	invalid := func(what string) error {
+		return cfe("Invalid " + what + " in Module attribute in class: " + klass.className)
+	}
+
+	r := &classReader{b: content}
+	data := ModuleData{}
+	var ok bool
+	if data.Name, ok = moduleCPName(klass, r.u2(), Module); !ok {
+		return invalid("module name")
+	}
+	data.Flags = r.u2()
+	if data.Version, ok = optionalUTF8(klass, r.u2()); !ok {
+		return invalid("module version")
+	}
+
+	for count := r.u2(); count > 0 && r.err == nil; count-- {
+		req := ModuleRequires{}
+		if req.Module, ok = moduleCPName(klass, r.u2(), Module); !ok {
+			return invalid("requires entry")
+		}
+		req.Flags = r.u2()
+		if req.Version, ok = optionalUTF8(klass, r.u2()); !ok {
+			return invalid("requires entry version")
+		}
+		data.Requires = append(data.Requires, req)
+	}
+
+	for _, packages := range []*[]ModulePackage{&data.Exports, &data.Opens} {
+		for count := r.u2(); count > 0 && r.err == nil; count-- {
+			pkg := ModulePackage{}
+			if pkg.Package, ok = moduleCPName(klass, r.u2(), Package); !ok {
+				return invalid("exports or opens entry")
+			}
+			pkg.Flags = r.u2()
+			for to := r.u2(); to > 0 && r.err == nil; to-- {
+				module, ok := moduleCPName(klass, r.u2(), Module)
+				if !ok {
+					return invalid("exports or opens entry")
+				}
+				pkg.To = append(pkg.To, module)
+			}
+			*packages = append(*packages, pkg)
+		}
+	}
+
+	for count := r.u2(); count > 0 && r.err == nil; count-- {
+		service, ok := moduleCPName(klass, r.u2(), ClassRef)
+		if !ok {
+			return invalid("uses entry")
+		}
+		data.Uses = append(data.Uses, service)
+	}
+
+	for count := r.u2(); count > 0 && r.err == nil; count-- {
+		provides := ModuleProvides{}
+		if provides.Service, ok = moduleCPName(klass, r.u2(), ClassRef); !ok {
+			return invalid("provides entry")
+		}
+		with := r.u2()
+		if with == 0 && r.err == nil {
+			return invalid("provides entry")
+		}
+		for ; with > 0 && r.err == nil; with-- {
+			impl, ok := moduleCPName(klass, r.u2(), ClassRef)
+			if !ok {
+				return invalid("provides entry")
+			}
+			provides.With = append(provides.With, impl)
+		}
+		data.Provides = append(data.Provides, provides)
+	}
+
+	if r.err != nil {
+		return cfe("Module attribute is truncated in class: " + klass.className)
+	}
+	klass.moduleData = &data
+	klass.moduleName = data.Name
+	_ = log.Log("Module: "+data.Name+", requires: "+strconv.Itoa(len(data.Requires))+
+		", exports: "+strconv.Itoa(len(data.Exports))+", opens: "+strconv.Itoa(len(data.Opens))+
+		", uses: "+strconv.Itoa(len(data.Uses))+", provides: "+strconv.Itoa(len(data.Provides)), log.FINEST)
+	return nil
+}
+
+// returns the name in the Module, Package, or ClassRef CP entry (as given by entryType) at index
+func moduleCPName(klass *ParsedClass, index int, entryType int) (string, bool) {
+	if !cpEntryIs(klass, index, entryType) {
+		return "", false
+	}
+	nameIndex := klass.cpIndex[index].slot
+	if entryType == ClassRef {
+		nameIndex = klass.classRefs[nameIndex]
+	}
+	name, err := fetchUTF8string(klass, nameIndex)
+	return name, err == nil
+}
+
+// returns the UTF8 string at index, or "" if index is 0, as it is for missing versions
+func optionalUTF8(klass *ParsedClass, index int) (string, bool) {
+	if index == 0 {
+		return "", true
+	}
+	s, err := fetchUTF8string(klass, index)
+	return s, err == nil
+}
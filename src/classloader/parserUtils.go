@@ -45,6 +45,16 @@ func intFrom4Bytes(bytes []byte, pos int) (int, error) {
 	return retVal, nil
 }
 
+// returns true if index is a valid index into the CP and the CP entry
+// at that index is of the specified type. Used to check CP indexes found
+// in the class file before they are dereferenced.
+func cpEntryIs(klass *ParsedClass, index int, entryType int) bool {
+	if index < 1 || index >= len(klass.cpIndex) {
+		return false
+	}
+	return klass.cpIndex[index].entryType == entryType
+}
+
 // finds and returns a UTF8 string when handed an index into the CP that points
 // to a UTF8 entry. Does extensive checking of values.
 func fetchUTF8string(klass *ParsedClass, index int) (string, error) {
@@ -114,6 +124,11 @@ func fetchAttribute(klass *ParsedClass, bytes []byte, loc int) (attr, int, error
 	}
 	attribute.attrSize = length
 
+	if length < 0 || pos+length >= len(bytes) {
+		return attribute, pos, cfe("attribute length extends beyond the end of the class file: " +
+			strconv.Itoa(length))
+	}
+
 	b := make([]byte, length)
 	for i := 0; i < length; i++ {
 		b[i] = bytes[pos+1+i]
@@ -139,6 +154,10 @@ func resolveCPmethodRef(index int, klass *ParsedClass) (string, string, string,
 	}
 
 	methRef := klass.methodRefs[cpEnt.slot]
+	if !cpEntryIs(klass, methRef.classIndex, ClassRef) {
+		return "", "", "", cfe("MethodRef CP entry #" + strconv.Itoa(index) +
+			" does not point to a valid ClassRef")
+	}
 	pointedToClassRef := klass.cpIndex[methRef.classIndex]
 	nameIndex := klass.classRefs[pointedToClassRef.slot]
 	className, err := fetchUTF8string(klass, nameIndex)
@@ -157,7 +176,7 @@ func resolveCPmethodRef(index int, klass *ParsedClass) (string, string, string,
 }
 
 func resolveCPnameAndType(klass *ParsedClass, index int) (string, string, error) {
-	if index < 1 || index >= len(klass.cpIndex) {
+	if !cpEntryIs(klass, index, NameAndType) {
 		return "", "", cfe("Invalid nameAndType index into CP: " +
 			strconv.Itoa(index))
 	}
@@ -167,14 +186,14 @@ func resolveCPnameAndType(klass *ParsedClass, index int) (string, string, error)
 	nameIndex := nAndT.nameIndex
 	descIndex := nAndT.descriptorIndex
 
-	if klass.cpIndex[nameIndex].entryType != UTF8 {
+	if !cpEntryIs(klass, nameIndex, UTF8) {
 		return "", "", cfe("Name index in nameAndType entry (CP #" + strconv.Itoa(index) +
 			") does not point to a UTF8 entry.")
 	}
 
 	name := klass.utf8Refs[klass.cpIndex[nameIndex].slot]
 
-	if klass.cpIndex[descIndex].entryType != UTF8 {
+	if !cpEntryIs(klass, descIndex, UTF8) {
 		return "", "", cfe("Desc index in nameAndType entry (CP #" + strconv.Itoa(index) +
 			") does not point to a UTF8 entry.")
 	}
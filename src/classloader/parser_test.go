@@ -7,10 +7,13 @@
 package classloader
 
 import (
+	"errors"
 	"io"
+	"jacobin/classbuilder"
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -1290,8 +1293,8 @@ func TestValidBootstrapClassAttribute(t *testing.T) {
 		t.Error("Unexpected error in test of parseClassAttributes()")
 	}
 
-	if len(klass.bootstraps) != 1 {
-		t.Error("Class should have 1 bootstrap methods. Got: " + strconv.Itoa(len(klass.bootstraps)))
+	if len(klass.BootstrapMethods) != 1 {
+		t.Error("Class should have 1 bootstrap methods. Got: " + strconv.Itoa(len(klass.BootstrapMethods)))
 	}
 
 	// restore stderr and stdout to what they were before
@@ -1353,3 +1356,923 @@ func TestDeprecatedClassAttribute(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 }
+
+// returns the bytes of a class whose main() creates a Runnable from a lambda, as javac
+// compiles `Runnable r = () -> {};`: an invokedynamic bootstrapped by LambdaMetafactory
+func lambdaClass(t *testing.T) []byte {
+	metafactory := classbuilder.MethodHandle{Kind: 6, // REF_invokeStatic
+		Class: "java/lang/invoke/LambdaMetafactory", Name: "metafactory",
+		Desc: "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;" +
+			"Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)" +
+			"Ljava/lang/invoke/CallSite;"}
+	bootstrap := classbuilder.Bootstrap{Method: metafactory, Args: []interface{}{
+		classbuilder.MethodType("()V"),
+		classbuilder.MethodHandle{Kind: 6, Class: "Lambda", Name: "lambda$main$0", Desc: "()V"},
+		classbuilder.MethodType("()V"),
+	}}
+
+	b, err := classbuilder.NewClassBuilder("Lambda").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(0xBA, bootstrap, "run", "()Ljava/lang/Runnable;"). // invokedynamic
+		AddOpcode(0x4C).                                             // astore_1
+		AddOpcode(0xB1).                                             // return
+		AddMethod("lambda$main$0", "()V").AddOpcode(0xB1).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building the lambda class: %s", err.Error())
+	}
+	return b
+}
+
+func TestParseBootstrapMethodsOfLambda(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(lambdaClass(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the lambda class: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking the lambda class: %s", err.Error())
+	}
+
+	if klass.bootstrapCount != 1 || len(klass.BootstrapMethods) != 1 {
+		t.Fatalf("Expected 1 bootstrap method, got: %d", len(klass.BootstrapMethods))
+	}
+	bsm := klass.BootstrapMethods[0]
+	if !cpEntryIs(&klass, int(bsm.MethodRef), MethodHandle) {
+		t.Errorf("Expected the bootstrap method to be a MethodHandle, got CP entry type: %d",
+			klass.cpIndex[bsm.MethodRef].entryType)
+	}
+	expected := []int{MethodType, MethodHandle, MethodType}
+	if len(bsm.Args) != len(expected) {
+		t.Fatalf("Expected %d static arguments, got: %d", len(expected), len(bsm.Args))
+	}
+	for i, arg := range bsm.Args {
+		if !cpEntryIs(&klass, int(arg), expected[i]) {
+			t.Errorf("Expected static argument %d to be of CP entry type %d, got: %d",
+				i, expected[i], klass.cpIndex[arg].entryType)
+		}
+	}
+
+	if len(klass.invokeDynamics) != 1 || klass.invokeDynamics[0].bootstrapIndex != 0 {
+		t.Errorf("Expected one invokedynamic call site using bootstrap method 0, got: %v", klass.invokeDynamics)
+	}
+}
+
+func TestParseBootstrapMethodsTruncated(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klass := ParsedClass{}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{}, cpEntry{MethodHandle, 0})
+	klass.cpCount = 2
+
+	truncated := [][]byte{
+		{00},                         // no bootstrap count
+		{00, 01},                     // no bootstrap method
+		{00, 01, 00, 01},             // no argument count
+		{00, 01, 00, 01, 00, 02, 00}, // only part of the first of two arguments
+	}
+	for _, content := range truncated {
+		klass.BootstrapMethods = nil
+		if err := parseBootstrapMethods(&klass, content); err == nil {
+			t.Errorf("Expected an error for truncated BootstrapMethods % X, but got none", content)
+		}
+	}
+}
+
+// the bootstrap methods are carried through to the postable class, where the interpreter
+// can find the one an invokedynamic call site names
+func TestPostableClassBootstrapMethods(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(lambdaClass(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the lambda class: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+
+	if len(kd.Bootstraps) != 1 {
+		t.Fatalf("Expected 1 bootstrap method in the postable class, got: %d", len(kd.Bootstraps))
+	}
+	indy := kd.CP.InvokeDynamics[0]
+	bsm := kd.Bootstraps[indy.BootstrapIndex]
+	if kd.CP.CpIndex[bsm.MethodRef].Type != MethodHandle {
+		t.Errorf("Expected the bootstrap method to be a MethodHandle, got CP entry type: %d",
+			kd.CP.CpIndex[bsm.MethodRef].Type)
+	}
+	if !reflect.DeepEqual(bsm.Args, klass.BootstrapMethods[0].Args) {
+		t.Errorf("Expected static arguments %v, got: %v", klass.BootstrapMethods[0].Args, bsm.Args)
+	}
+}
+
+// the operand of an invokedynamic instruction in the postable class leads, through its
+// InvokeDynamic CP entry, to the call site's name and type and to its bootstrap method
+func TestInvokeDynamicInstructionResolvesToBootstrapMethod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(lambdaClass(t))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the lambda class: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking the lambda class: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	cp := &kd.CP
+
+	var code []byte
+	for _, m := range kd.Methods {
+		if cp.Utf8Refs[m.Name] == "main" {
+			code = m.CodeAttr.Code
+		}
+	}
+	if len(code) < 5 || code[0] != 0xBA || code[3] != 0 || code[4] != 0 {
+		t.Fatalf("Expected main() to start with invokedynamic, got: % X", code)
+	}
+
+	index := int(code[1])<<8 | int(code[2])
+	entry := cp.CpIndex[index]
+	if entry.Type != InvokeDynamic {
+		t.Fatalf("Expected the invokedynamic operand to be an InvokeDynamic CP entry, got type: %d", entry.Type)
+	}
+	indy := cp.InvokeDynamics[entry.Slot]
+
+	nAndT := cp.NameAndTypes[cp.CpIndex[indy.NameAndType].Slot]
+	name := FetchUTF8stringFromCPEntryNumber(cp, nAndT.NameIndex)
+	desc := FetchUTF8stringFromCPEntryNumber(cp, nAndT.DescIndex)
+	if name != "run" || desc != "()Ljava/lang/Runnable;" {
+		t.Errorf("Expected the call site to be run()Ljava/lang/Runnable;, got: %s%s", name, desc)
+	}
+
+	if int(indy.BootstrapIndex) >= len(kd.Bootstraps) {
+		t.Fatalf("Expected bootstrap method %d to be in the postable class, which has %d",
+			indy.BootstrapIndex, len(kd.Bootstraps))
+	}
+	bsm := kd.Bootstraps[indy.BootstrapIndex]
+	mh := cp.MethodHandles[cp.CpIndex[bsm.MethodRef].Slot]
+	mr := cp.MethodRefs[cp.CpIndex[mh.RefIndex].Slot]
+	if className := fetchClassNameFromCPEntryNumber(cp, mr.ClassIndex); className != "java/lang/invoke/LambdaMetafactory" {
+		t.Errorf("Expected the bootstrap method to be in LambdaMetafactory, got: %s", className)
+	}
+}
+
+// a class with invokedynamic or dynamic constants must have exactly one BootstrapMethods
+// attribute
+func TestBootstrapMethodsMissingOrDuplicated(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass, err := parse(lambdaClass(t))
+	if err != nil {
+		os.Stderr = normalStderr
+		t.Fatalf("Unexpected error parsing the lambda class: %s", err.Error())
+	}
+	errDuplicate := parseBootstrapMethods(&klass, []byte{00, 00})
+
+	klass.BootstrapMethods, klass.bootstrapCount = nil, 0
+	errMissing := formatCheckClass(&klass)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if errDuplicate == nil || !strings.Contains(msg, "more than one BootstrapMethods attribute") {
+		t.Errorf("Expected an error for a second BootstrapMethods attribute, got: %s", msg)
+	}
+	if errMissing == nil || !strings.Contains(msg, "requires a BootstrapMethods attribute") {
+		t.Errorf("Expected an error for an InvokeDynamic entry without BootstrapMethods, got: %s", msg)
+	}
+}
+
+// returns the bytes of a class whose InnerClasses attribute lists the member class
+// Outer$Inner and the anonymous class Outer$1. thisClass is the CP index of the class
+// itself: 2 for Outer$Inner, 6 for Outer, or 10 for Outer$1.
+func innerClassesClass(thisClass byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x37, 0x00, 11}
+	b = append(b, utf8("Outer$Inner")...)                           // 1
+	b = append(b, 0x07, 0x00, 1)                                    // 2: Class Outer$Inner
+	b = append(b, utf8("java/lang/Object")...)                      // 3
+	b = append(b, 0x07, 0x00, 3)                                    // 4: Class java/lang/Object
+	b = append(b, utf8("Outer")...)                                 // 5
+	b = append(b, 0x07, 0x00, 5)                                    // 6: Class Outer
+	b = append(b, utf8("Inner")...)                                 // 7
+	b = append(b, utf8("InnerClasses")...)                          // 8
+	b = append(b, utf8("Outer$1")...)                               // 9
+	b = append(b, 0x07, 0x00, 9)                                    // 10: Class Outer$1
+	b = append(b, 0x00, 0x20, 0x00, thisClass, 0x00, 4, 0x00, 0x00) // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x00, 0x00, 0x00)                           // no fields or methods
+	b = append(b, 0x00, 0x01, 0x00, 8, 0x00, 0x00, 0x00, 18, 0x00, 2)
+	b = append(b, 0x00, 2, 0x00, 6, 0x00, 7, 0x00, 0x09)  // Outer$Inner, public static member of Outer
+	b = append(b, 0x00, 10, 0x00, 0, 0x00, 0, 0x00, 0x00) // Outer$1, anonymous
+	return b
+}
+
+func TestParseInnerClasses(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(innerClassesClass(2))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Outer$Inner: %s", err.Error())
+	}
+	expected := []innerClassEntry{
+		{innerClass: 2, outerClass: 6, innerName: 7, accessFlags: 0x0009},
+		{innerClass: 10},
+	}
+	if len(klass.innerClasses) != len(expected) {
+		t.Fatalf("Expected %d inner class entries, got: %v", len(expected), klass.innerClasses)
+	}
+	for i := range expected {
+		if klass.innerClasses[i] != expected[i] {
+			t.Errorf("Expected inner class entry %d to be %+v, got: %+v", i, expected[i], klass.innerClasses[i])
+		}
+	}
+
+	kd := convertToPostableClass(&klass)
+	if len(kd.InnerClasses) != 2 || kd.InnerClasses[0] != (InnerClassEntry{2, 6, 7, 0x0009}) {
+		t.Errorf("Expected the inner class entries to be posted, got: %+v", kd.InnerClasses)
+	}
+}
+
+func TestParseInnerClassesInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klass := ParsedClass{}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{}, cpEntry{ClassRef, 0}, cpEntry{UTF8, 0})
+	klass.cpCount = 3
+
+	invalid := [][]byte{
+		{00},                                     // no count
+		{00, 01, 00, 01, 00, 00, 00},             // only part of the entry
+		{00, 01, 00, 02, 00, 00, 00, 00, 00, 00}, // the inner class isn't a ClassRef
+		{00, 01, 00, 01, 00, 02, 00, 00, 00, 00}, // nor is the outer class
+		{00, 01, 00, 01, 00, 00, 00, 01, 00, 00}, // the name isn't a UTF8
+	}
+	for _, content := range invalid {
+		klass.innerClasses = nil
+		if err := parseInnerClasses(&klass, content); err == nil {
+			t.Errorf("Expected an error for invalid InnerClasses % X, but got none", content)
+		}
+	}
+}
+
+// the local class Outer$1Local, declared in Outer.run(). Its InnerClasses entry for
+// itself has the given outer class, which should be 0, but is Outer if it's 6.
+func localClass(outerClass byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x37, 0x00, 13}
+	b = append(b, utf8("Outer$1Local")...)                  // 1
+	b = append(b, 0x07, 0x00, 1)                            // 2: Class Outer$1Local
+	b = append(b, utf8("java/lang/Object")...)              // 3
+	b = append(b, 0x07, 0x00, 3)                            // 4: Class java/lang/Object
+	b = append(b, utf8("Outer")...)                         // 5
+	b = append(b, 0x07, 0x00, 5)                            // 6: Class Outer
+	b = append(b, utf8("run")...)                           // 7
+	b = append(b, utf8("()V")...)                           // 8
+	b = append(b, 0x0C, 0x00, 7, 0x00, 8)                   // 9: NameAndType run()V
+	b = append(b, utf8("EnclosingMethod")...)               // 10
+	b = append(b, utf8("InnerClasses")...)                  // 11
+	b = append(b, utf8("Local")...)                         // 12
+	b = append(b, 0x00, 0x20, 0x00, 2, 0x00, 4, 0x00, 0x00) // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x00, 0x00, 0x00)                   // no fields or methods
+	b = append(b, 0x00, 0x02, 0x00, 10, 0x00, 0x00, 0x00, 4, 0x00, 6, 0x00, 9)
+	b = append(b, 0x00, 11, 0x00, 0x00, 0x00, 10, 0x00, 1)
+	b = append(b, 0x00, 2, 0x00, outerClass, 0x00, 12, 0x00, 0x00)
+	return b
+}
+
+func TestParseEnclosingMethod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(localClass(0))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Outer$1Local: %s", err.Error())
+	}
+	if klass.enclosing == nil || *klass.enclosing != (enclosingMethod{class: 6, method: 9}) {
+		t.Fatalf("Expected the enclosing method to be CP entries 6 and 9, got: %+v", klass.enclosing)
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking Outer$1Local: %s", err.Error())
+	}
+
+	kd := convertToPostableClass(&klass)
+	if kd.EnclosingMethod == nil || *kd.EnclosingMethod != (EnclosingMethodEntry{6, 9}) {
+		t.Errorf("Expected the enclosing method to be posted, got: %+v", kd.EnclosingMethod)
+	}
+	if name := EnclosingMethodName(&kd); name != "Outer.run()V" {
+		t.Errorf("Expected the enclosing method to be Outer.run()V, got: %s", name)
+	}
+	kd.EnclosingMethod.Method = 0 // as for a class declared in an initializer
+	if name := EnclosingMethodName(&kd); name != "Outer" {
+		t.Errorf("Expected the enclosing class to be Outer, got: %s", name)
+	}
+}
+
+func TestParseEnclosingMethodInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass, err := parse(localClass(6))
+	if err == nil {
+		err = formatCheckClass(&klass)
+	}
+	invalid := [][]byte{
+		{0, 6, 0},       // truncated
+		{0, 6, 0, 9, 0}, // too long
+		{0, 5, 0, 9},    // the class isn't a ClassRef
+		{0, 6, 0, 8},    // the method isn't a NameAndType
+	}
+	var invalidErrs []error
+	for _, content := range invalid {
+		klass.enclosing = nil
+		invalidErrs = append(invalidErrs, parseEnclosingMethod(&klass, content))
+	}
+	klass.enclosing = &enclosingMethod{class: 6}
+	duplicateErr := parseEnclosingMethod(&klass, []byte{0, 6, 0, 0})
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Error("Expected an error for a local class declared a member class, but got none")
+	} else if !strings.Contains(string(out), "InnerClasses attribute declares it a member class") {
+		t.Errorf("Expected an error about the contradictory InnerClasses entry, got: %s", string(out))
+	}
+	for i, err := range invalidErrs {
+		if err == nil {
+			t.Errorf("Expected an error for invalid EnclosingMethod % X, but got none", invalid[i])
+		}
+	}
+	if duplicateErr == nil {
+		t.Error("Expected an error for a second EnclosingMethod attribute, but got none")
+	}
+}
+
+func TestParseModuleInfo(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(moduleInfoBytes(ModuleData{
+		Name:     "org.app",
+		Version:  "1.2",
+		Requires: requires("java.base", "org.lib"),
+		Exports:  exports("org/app", "org/app/api"),
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing module-info: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking module-info: %s", err.Error())
+	}
+
+	expected := &ModuleData{
+		Name:     "org.app",
+		Version:  "1.2",
+		Requires: []ModuleRequires{{Module: "java.base"}, {Module: "org.lib"}},
+		Exports:  []ModulePackage{{Package: "org/app"}, {Package: "org/app/api"}},
+	}
+	if !reflect.DeepEqual(klass.moduleData, expected) {
+		t.Errorf("Expected module data %+v, got: %+v", expected, klass.moduleData)
+	}
+	kd := convertToPostableClass(&klass)
+	if kd.Module != "org.app" || kd.ModuleData != klass.moduleData {
+		t.Errorf("Expected the posted class to be in module org.app, with its module data, got: %q, %+v",
+			kd.Module, kd.ModuleData)
+	}
+}
+
+// a module's own name, and the names in its CP's Module and Package entries, can be
+// anywhere in the CP; the module and package named by the Module attribute are used
+func TestParseModuleAttribute(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := ParsedClass{className: "module-info", javaVersion: 55}
+	for _, s := range []string{"org.friend", "org.app", "org/app/internal", "org/app/Service", "org/app/internal/Impl"} {
+		klass.utf8Refs = append(klass.utf8Refs, utf8Entry{s})
+	}
+	klass.cpIndex = []cpEntry{{}, {Module, 4}, {Module, 5}, {Package, 6},
+		{UTF8, 0}, {UTF8, 1}, {UTF8, 2}, {UTF8, 3}, {UTF8, 4}, {ClassRef, 0}, {ClassRef, 1}}
+	klass.classRefs = []int{7, 8}
+	klass.cpCount = len(klass.cpIndex)
+	if err := fetchModuleAndPackageNames(&klass); err != nil {
+		t.Fatalf("Unexpected error fetching the module and package names: %s", err.Error())
+	}
+	if klass.moduleName != "org.friend" || klass.packageName != "org/app/internal" {
+		t.Errorf("Expected the first module and package in the CP, got: %q, %q", klass.moduleName, klass.packageName)
+	}
+
+	content := []byte{
+		0, 2, 0x00, 0x20, 0, 0, // org.app, open, no version
+		0, 0, // no requires
+		0, 0, // no exports
+		0, 1, 0, 3, 0, 0, 0, 1, 0, 1, // opens org/app/internal to org.friend
+		0, 1, 0, 9, // uses org/app/Service
+		0, 1, 0, 9, 0, 1, 0, 10, // provides org/app/Service with org/app/internal/Impl
+	}
+	if err := parseModule(&klass, content); err != nil {
+		t.Fatalf("Unexpected error parsing the Module attribute: %s", err.Error())
+	}
+	expected := &ModuleData{
+		Name:  "org.app",
+		Flags: 0x20,
+		Opens: []ModulePackage{{Package: "org/app/internal", To: []string{"org.friend"}}},
+		Uses:  []string{"org/app/Service"},
+		Provides: []ModuleProvides{
+			{Service: "org/app/Service", With: []string{"org/app/internal/Impl"}}},
+	}
+	if !reflect.DeepEqual(klass.moduleData, expected) || klass.moduleName != "org.app" {
+		t.Errorf("Expected module data %+v for module org.app, got: %+v for module %s",
+			expected, klass.moduleData, klass.moduleName)
+	}
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	if err := parseModule(&klass, content); err == nil {
+		t.Error("Expected an error for a second Module attribute, but got none")
+	}
+	invalid := [][]byte{
+		{0, 2, 0, 0},                                                       // truncated
+		{0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},                               // the module isn't a Module entry
+		{0, 2, 0, 0, 0, 0, 0, 1, 0, 2, 0, 0, 0, 0},                         // the requires entry has no version
+		{0, 2, 0, 0, 0, 0, 0, 0, 0, 1, 0, 2, 0, 0},                         // the export isn't a Package entry
+		{0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 2, 0, 0},             // the service used isn't a ClassRef
+		{0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 9, 0, 0},       // the service has no implementations
+		{0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 9, 0, 1, 0, 3}, // the implementation isn't a ClassRef
+	}
+	for _, content := range invalid {
+		klass.moduleData = nil
+		if err := parseModule(&klass, content); err == nil {
+			t.Errorf("Expected an error for invalid Module attribute % X, but got none", content)
+		}
+	}
+}
+
+func TestFormatCheckModuleInfo(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	valid := moduleInfoBytes(ModuleData{Name: "org.app"})
+	tests := map[string]func(klass *ParsedClass){
+		"other access flags":    func(klass *ParsedClass) { klass.accessFlags |= 0x0001 },
+		"not named module-info": func(klass *ParsedClass) { klass.className = "org/app/Main" },
+		"fields":                func(klass *ParsedClass) { klass.fieldCount = 1 },
+		"no Module attribute":   func(klass *ParsedClass) { klass.moduleData = nil },
+	}
+	for name, spoil := range tests {
+		klass, err := parse(valid)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing module-info: %s", err.Error())
+		}
+		spoil(&klass)
+		if formatCheckModule(&klass) == nil {
+			t.Errorf("Expected a format-check error for a module-info class with %s, but got none", name)
+		}
+	}
+}
+
+// the class Names<T extends Comparable<T>>, which has a field List<String> names and an
+// abstract method List<String> first(Map<String, ? extends T>), with their Signature attributes
+func genericSignaturesClass(fieldSignature byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	methodSignature := "(Ljava/util/Map<Ljava/lang/String;+TT;>;)Ljava/util/List<Ljava/lang/String;>;"
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x37, 0x00, 13}
+	b = append(b, utf8("Names")...)                                              // 1
+	b = append(b, 0x07, 0x00, 1)                                                 // 2: Class Names
+	b = append(b, utf8("java/lang/Object")...)                                   // 3
+	b = append(b, 0x07, 0x00, 3)                                                 // 4: Class java/lang/Object
+	b = append(b, utf8("Signature")...)                                          // 5
+	b = append(b, utf8("<T::Ljava/lang/Comparable<TT;>;>Ljava/lang/Object;")...) // 6
+	b = append(b, utf8("names")...)                                              // 7
+	b = append(b, utf8("Ljava/util/List;")...)                                   // 8
+	b = append(b, utf8("Ljava/util/List<Ljava/lang/String;>;")...)               // 9
+	b = append(b, utf8("first")...)                                              // 10
+	b = append(b, utf8("(Ljava/util/Map;)Ljava/util/List;")...)                  // 11
+	b = append(b, utf8(methodSignature)...)                                      // 12
+	b = append(b, 0x04, 0x21, 0x00, 2, 0x00, 4, 0x00, 0x00)                      // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x01, 0x00, 0x02, 0x00, 7, 0x00, 8)                      // private List names
+	b = append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 0x02, 0x00, fieldSignature)
+	b = append(b, 0x00, 0x01, 0x04, 0x01, 0x00, 10, 0x00, 11) // public abstract List first(Map)
+	b = append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 0x02, 0x00, 12)
+	b = append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 0x02, 0x00, 6)
+	return b
+}
+
+func TestParseSignatureAttributes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(genericSignaturesClass(9))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Names: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking Names: %s", err.Error())
+	}
+
+	kd := convertToPostableClass(&klass)
+	if kd.Signature != "<T::Ljava/lang/Comparable<TT;>;>Ljava/lang/Object;" {
+		t.Errorf("Expected the class's generic signature, got: %q", kd.Signature)
+	}
+	if len(kd.Fields) != 1 || kd.Fields[0].Signature != "Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("Expected the field's generic signature, got: %+v", kd.Fields)
+	}
+	if len(kd.Methods) != 1 || kd.Methods[0].Signature !=
+		"(Ljava/util/Map<Ljava/lang/String;+TT;>;)Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("Expected the method's generic signature, got: %+v", kd.Methods)
+	}
+
+	field, err := ParseFieldSignature(kd.Fields[0].Signature)
+	if err != nil || field.String() != "java/util/List<java/lang/String>" {
+		t.Errorf("Expected the field to be a List<String>, got: %v, %v", field, err)
+	}
+}
+
+func TestParseSignatureAttributeInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := parse(genericSignaturesClass(2)) // a ClassRef, not a UTF8
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Errorf("Expected an error for a Signature attribute that's not a UTF8, but got none")
+	} else if !strings.Contains(string(out), "Invalid Signature attribute of field names of Names") {
+		t.Errorf("Expected an error about the field's Signature attribute, got: %s", string(out))
+	}
+}
+
+// the sealed class Shape, which permits only Circle and Square to extend it
+func sealedShapeClass(version byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, version, 0x00, 10}
+	b = append(b, utf8("Shape")...)                         // 1
+	b = append(b, 0x07, 0x00, 1)                            // 2: Class Shape
+	b = append(b, utf8("java/lang/Object")...)              // 3
+	b = append(b, 0x07, 0x00, 3)                            // 4: Class java/lang/Object
+	b = append(b, utf8("PermittedSubclasses")...)           // 5
+	b = append(b, utf8("Circle")...)                        // 6
+	b = append(b, 0x07, 0x00, 6)                            // 7: Class Circle
+	b = append(b, utf8("Square")...)                        // 8
+	b = append(b, 0x07, 0x00, 8)                            // 9: Class Square
+	b = append(b, 0x04, 0x21, 0x00, 2, 0x00, 4, 0x00, 0x00) // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x00, 0x00, 0x00)                   // no fields or methods
+	b = append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 6, 0x00, 2, 0x00, 7, 0x00, 9)
+	return b
+}
+
+func TestParsePermittedSubclasses(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(sealedShapeClass(61))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Shape: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	if !reflect.DeepEqual(kd.PermittedSubclasses, []string{"Circle", "Square"}) {
+		t.Errorf("Expected Circle and Square to be the permitted subclasses, got: %v", kd.PermittedSubclasses)
+	}
+
+	// before Java 17, the attribute is not recognized
+	klass, err = parse(sealedShapeClass(60))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing a Java 16 Shape: %s", err.Error())
+	}
+	if klass.permitted != nil {
+		t.Errorf("Expected a Java 16 class to have no permitted subclasses, got: %v", klass.permitted)
+	}
+}
+
+func TestParsePermittedSubclassesInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klass, err := parse(sealedShapeClass(61))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Shape: %s", err.Error())
+	}
+	if err = parsePermittedSubclasses(&klass, []byte{0, 1, 0, 7}); err == nil {
+		t.Error("Expected an error for a second PermittedSubclasses attribute, but got none")
+	}
+
+	invalid := [][]byte{
+		{0, 2, 0, 7},       // truncated
+		{0, 1, 0, 7, 0},    // too long
+		{0, 1, 0, 6},       // the subclass isn't a ClassRef
+		{0, 2, 0, 7, 0, 0}, // nor is CP entry 0
+	}
+	for _, content := range invalid {
+		klass.permitted = nil
+		if err := parsePermittedSubclasses(&klass, content); err == nil {
+			t.Errorf("Expected an error for invalid PermittedSubclasses % X, but got none", content)
+		}
+	}
+}
+
+// a subclass of a sealed class that doesn't permit it parses, but fails to link
+func TestSubclassOfSealedClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	_, err := ParseAndPostClass(AppCL, "Shape.class", sealedShapeClass(61))
+	var errs []error
+	for _, name := range []string{"Circle", "Triangle"} {
+		subclass, _ := classbuilder.NewClassBuilder(name).SuperClass("Shape").Version(61).Build()
+		_, err := ParseAndPostClass(AppCL, name+".class", subclass)
+		errs = append(errs, err)
+	}
+	if err != nil || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("Unexpected error loading the classes: %v, %v", err, errs)
+	}
+
+	if err = LinkClass("Circle"); err != nil {
+		t.Errorf("Expected no error linking the permitted subclass Circle, got: %v", err)
+	}
+	var icce *IncompatibleClassChangeError
+	if err = LinkClass("Triangle"); !errors.As(err, &icce) {
+		t.Errorf("Expected IncompatibleClassChangeError linking Triangle, got: %v", err)
+	}
+}
+
+// the class Outer, the host of a nest whose only other member is Outer$Inner. If
+// nestHost is non-zero, Outer also has a NestHost attribute that points to that CP entry.
+func outerNestClass(version byte, nestHost byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, version, 0x00, 9}
+	b = append(b, utf8("Outer")...)                         // 1
+	b = append(b, 0x07, 0x00, 1)                            // 2: Class Outer
+	b = append(b, utf8("java/lang/Object")...)              // 3
+	b = append(b, 0x07, 0x00, 3)                            // 4: Class java/lang/Object
+	b = append(b, utf8("NestMembers")...)                   // 5
+	b = append(b, utf8("Outer$Inner")...)                   // 6
+	b = append(b, 0x07, 0x00, 6)                            // 7: Class Outer$Inner
+	b = append(b, utf8("NestHost")...)                      // 8
+	b = append(b, 0x00, 0x21, 0x00, 2, 0x00, 4, 0x00, 0x00) // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x00, 0x00, 0x00)                   // no fields or methods
+	if nestHost == 0 {
+		return append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 4, 0x00, 1, 0x00, 7)
+	}
+	b = append(b, 0x00, 0x02, 0x00, 5, 0x00, 0x00, 0x00, 4, 0x00, 1, 0x00, 7)
+	return append(b, 0x00, 8, 0x00, 0x00, 0x00, 2, 0x00, nestHost)
+}
+
+func TestParseNestMembers(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(outerNestClass(55, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Outer: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format checking Outer: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	if !reflect.DeepEqual(kd.NestMembers, []string{"Outer$Inner"}) || kd.NestHost != "" {
+		t.Errorf("Expected Outer to be the host of a nest with Outer$Inner, got host '%s' and members %v",
+			kd.NestHost, kd.NestMembers)
+	}
+
+	// before Java 11, the attribute is not recognized
+	klass, err = parse(outerNestClass(54, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing a Java 10 Outer: %s", err.Error())
+	}
+	if klass.nestMembers != nil {
+		t.Errorf("Expected a Java 10 class to have no nest members, got: %v", klass.nestMembers)
+	}
+}
+
+func TestParseNestAttributesInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	// a class can't be both a nest host and a member of another nest
+	klass, err := parse(outerNestClass(55, 7))
+	var errBoth error
+	if err == nil {
+		errBoth = formatCheckClass(&klass)
+	}
+
+	_, errHost := parse(outerNestClass(55, 6)) // the host is a UTF8, not a ClassRef
+	errs := []error{
+		parseNestHost(&klass, []byte{0, 2}),          // a second NestHost attribute
+		parseNestMembers(&klass, []byte{0, 1, 0, 7}), // a second NestMembers attribute
+	}
+	klass.nestHost, klass.nestMembers = "", nil
+	for _, content := range [][]byte{{0, 2, 0}, {0, 2, 0, 0}} {
+		errs = append(errs, parseNestHost(&klass, content))
+	}
+	for _, content := range [][]byte{{0, 2, 0, 7}, {0, 1, 0, 7, 0}, {0, 1, 0, 6}} {
+		errs = append(errs, parseNestMembers(&klass, content))
+	}
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Outer: %s", err.Error())
+	}
+	if errBoth == nil || !strings.Contains(msg, "Class Outer has both a NestHost and a NestMembers attribute") {
+		t.Errorf("Expected an error for a class with both nest attributes, got: %s", msg)
+	}
+	if errHost == nil || !strings.Contains(msg, "Invalid NestHost attribute in class: Outer") {
+		t.Errorf("Expected an error for a NestHost that's not a ClassRef, got: %s", msg)
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error for invalid nest attribute #%d, but got none", i)
+		}
+	}
+}
+
+// the class of record Point(int x, @Deprecated int y), with its Record attribute
+func pointRecordClass(version byte) []byte {
+	utf8 := func(s string) []byte {
+		return append([]byte{0x01, 0x00, byte(len(s))}, s...)
+	}
+	b := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, version, 0x00, 11}
+	b = append(b, utf8("Point")...)                         // 1
+	b = append(b, 0x07, 0x00, 1)                            // 2: Class Point
+	b = append(b, utf8("java/lang/Record")...)              // 3
+	b = append(b, 0x07, 0x00, 3)                            // 4: Class java/lang/Record
+	b = append(b, utf8("Record")...)                        // 5
+	b = append(b, utf8("x")...)                             // 6
+	b = append(b, utf8("I")...)                             // 7
+	b = append(b, utf8("y")...)                             // 8
+	b = append(b, utf8("RuntimeVisibleAnnotations")...)     // 9
+	b = append(b, utf8("Ljava/lang/Deprecated;")...)        // 10
+	b = append(b, 0x00, 0x31, 0x00, 2, 0x00, 4, 0x00, 0x00) // flags, this, super, no interfaces
+	b = append(b, 0x00, 0x02)                               // private final int x, y
+	b = append(b, 0x00, 0x12, 0x00, 6, 0x00, 7, 0x00, 0x00)
+	b = append(b, 0x00, 0x12, 0x00, 8, 0x00, 7, 0x00, 0x00)
+	b = append(b, 0x00, 0x00) // no methods
+	b = append(b, 0x00, 0x01, 0x00, 5, 0x00, 0x00, 0x00, 26, 0x00, 2)
+	b = append(b, 0x00, 6, 0x00, 7, 0x00, 0x00) // int x
+	b = append(b, 0x00, 8, 0x00, 7, 0x00, 0x01) // @Deprecated int y
+	b = append(b, 0x00, 9, 0x00, 0x00, 0x00, 6, 0x00, 0x01, 0x00, 10, 0x00, 0x00)
+	return b
+}
+
+func TestParseRecord(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(pointRecordClass(60))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Point: %s", err.Error())
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("Unexpected error format-checking Point: %s", err.Error())
+	}
+	kd := convertToPostableClass(&klass)
+	if err = parseAnnotationAttributes(&kd); err != nil {
+		t.Fatalf("Unexpected error parsing the annotations of Point: %s", err.Error())
+	}
+
+	if !IsRecord(&kd) {
+		t.Error("Expected Point to be a record")
+	}
+	if len(kd.RecordComponents) != 2 {
+		t.Fatalf("Expected 2 record components, got: %+v", kd.RecordComponents)
+	}
+	for i, name := range []string{"x", "y"} {
+		rc := kd.RecordComponents[i]
+		if kd.CP.Utf8Refs[rc.Name] != name || kd.CP.Utf8Refs[rc.Desc] != "I" {
+			t.Errorf("Expected record component int %s, got: %s %s", name,
+				kd.CP.Utf8Refs[rc.Desc], kd.CP.Utf8Refs[rc.Name])
+		}
+	}
+	if kd.RecordComponents[0].VisibleAnnotations != nil {
+		t.Errorf("Expected x to have no annotations, got: %v", kd.RecordComponents[0].VisibleAnnotations)
+	}
+	if !reflect.DeepEqual(kd.RecordComponents[1].VisibleAnnotations, []Annotation{{Type: "java/lang/Deprecated"}}) {
+		t.Errorf("Expected y to be @Deprecated, got: %v", kd.RecordComponents[1].VisibleAnnotations)
+	}
+
+	// before Java 16, the attribute is not recognized, so the class is not a record
+	klass, err = parse(pointRecordClass(59))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing a Java 15 Point: %s", err.Error())
+	}
+	kd = convertToPostableClass(&klass)
+	if IsRecord(&kd) || kd.RecordComponents != nil {
+		t.Errorf("Expected a Java 15 class not to be a record, got components: %+v", kd.RecordComponents)
+	}
+}
+
+func TestParseRecordInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klass, err := parse(pointRecordClass(60))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Point: %s", err.Error())
+	}
+	if err = parseRecord(&klass, []byte{0, 0}); err == nil {
+		t.Error("Expected an error for a second Record attribute, but got none")
+	}
+
+	invalid := [][]byte{
+		{0, 1, 0, 6, 0, 7},                         // truncated
+		{0, 1, 0, 6, 0, 7, 0, 0, 0},                // too long
+		{0, 1, 0, 2, 0, 7, 0, 0},                   // the name isn't a UTF8 entry
+		{0, 1, 0, 6, 0, 4, 0, 0},                   // nor is the descriptor
+		{0, 1, 0, 6, 0, 7, 0, 1, 0, 9, 0, 0, 0, 2}, // the attribute is truncated
+		{0, 1, 0, 6, 0, 7, 0, 1, 0, 2, 0, 0, 0, 0}, // its name isn't a UTF8 entry
+	}
+	for _, content := range invalid {
+		klass.components = nil
+		if err := parseRecord(&klass, content); err == nil {
+			t.Errorf("Expected an error for invalid Record attribute % X, but got none", content)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fuzz test here feeds mutated class files to ParseAndPostClass(). The parser
+// and the format checker must reject malformed input with an error; they must never
+// panic or loop forever. To run the fuzzer (rather than just the seed corpus), use:
+//
+//	go test -fuzz=FuzzParseAndPostClass ./classloader
+
+// loads Hello2.class from the testdata directory. It's the same class that's
+// in Hello2Bytes in the jvm package tests.
+func getHello2Bytes(tb testing.TB) []byte {
+	pwd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal("Unable to get cwd")
+	}
+
+	classBytes, err := os.ReadFile(filepath.Join(pwd, "..", "..", "testdata", "Hello2.class"))
+	if err != nil {
+		tb.Fatal("Unable to read Hello2.class", err)
+	}
+	return classBytes
+}
+
+// returns hand-mutated variants of a valid class that must all be rejected
+func getMalformedSeeds(valid []byte) map[string][]byte {
+	seeds := make(map[string][]byte)
+
+	seeds["empty"] = []byte{}
+	seeds["magic only"] = valid[:4]
+
+	badMagic := bytes.Clone(valid)
+	badMagic[0] = 0xCB
+	seeds["bad magic"] = badMagic
+
+	// bytes 8-9 hold the CP count. Make it larger than the actual CP.
+	cpCountTooHigh := bytes.Clone(valid)
+	cpCountTooHigh[9] += 1
+	seeds["CP count too high"] = cpCountTooHigh
+
+	cpCountTooLow := bytes.Clone(valid)
+	cpCountTooLow[9] -= 1
+	seeds["CP count too low"] = cpCountTooLow
+
+	cpCountZero := bytes.Clone(valid)
+	cpCountZero[8], cpCountZero[9] = 0x00, 0x00
+	seeds["CP count zero"] = cpCountZero
+
+	seeds["truncated in CP"] = valid[:40]
+	seeds["truncated at end"] = valid[:len(valid)-1]
+	seeds["extra byte at end"] = append(bytes.Clone(valid), 0x00)
+
+	return seeds
+}
+
+func FuzzParseAndPostClass(f *testing.F) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	// the rejected classes generate a lot of SEVERE messages, so discard them
+	normalStderr := os.Stderr
+	devNull, _ := os.Open(os.DevNull)
+	os.Stderr = devNull
+	f.Cleanup(func() {
+		os.Stderr = normalStderr
+		_ = devNull.Close()
+	})
+
+	valid := getHello2Bytes(f)
+	f.Add(valid)
+	for _, seed := range getMalformedSeeds(valid) {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, classBytes []byte) {
+		_, err := ParseAndPostClass(AppCL, "fuzz.class", classBytes)
+
+		clearlyInvalid := len(classBytes) < 10 ||
+			!bytes.HasPrefix(classBytes, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+		if clearlyInvalid && err == nil {
+			t.Errorf("Expected an error on invalid class bytes % X, but got none", classBytes)
+		}
+	})
+}
+
+func TestParseAndPostClassRejectsMalformedSeeds(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	normalStderr := os.Stderr
+	devNull, _ := os.Open(os.DevNull)
+	os.Stderr = devNull
+	defer func() {
+		os.Stderr = normalStderr
+		_ = devNull.Close()
+	}()
+
+	valid := getHello2Bytes(t)
+	if _, err := ParseAndPostClass(AppCL, "Hello2.class", valid); err != nil {
+		t.Errorf("Expected valid Hello2 class to parse, but got: %s", err.Error())
+	}
+
+	for name, seed := range getMalformedSeeds(valid) {
+		if _, err := ParseAndPostClass(AppCL, "fuzz.class", seed); err == nil {
+			t.Errorf("Expected an error parsing malformed class (%s), but got none", name)
+		}
+	}
+}
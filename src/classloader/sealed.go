@@ -0,0 +1,58 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "strings"
+
+// IncompatibleClassChangeError is returned when a class extends a sealed class, or
+// implements a sealed interface, that does not permit it to. Msg is the error's message.
+type IncompatibleClassChangeError struct {
+	Msg string
+}
+
+func (e *IncompatibleClassChangeError) Error() string {
+	return "java.lang.IncompatibleClassChangeError: " + e.Msg
+}
+
+// checkSealedSupertypes checks that each sealed supertype of klass permits klass to
+// extend or implement it. A permitted subclass must also be in the same module as the
+// sealed class, or, if they're in the unnamed module, in the same package. LinkClass()
+// loads the supertypes before they're checked. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-5.html#jvms-5.3.5
+func checkSealedSupertypes(klass *ClData) error {
+	supertypes := []string{klass.Superclass}
+	for _, index := range klass.Interfaces {
+		supertypes = append(supertypes, klass.CP.Utf8Refs[index])
+	}
+
+	name := strings.ReplaceAll(klass.Name, "/", ".")
+	for _, supertype := range supertypes {
+		sealed := loadedClass(supertype)
+		if sealed == nil || sealed.PermittedSubclasses == nil {
+			continue
+		}
+		sealedName := strings.ReplaceAll(sealed.Name, "/", ".")
+
+		permitted := false
+		for _, subclass := range sealed.PermittedSubclasses {
+			permitted = permitted || subclass == klass.Name
+		}
+		switch {
+		case !permitted:
+			return &IncompatibleClassChangeError{Msg: "class " + name + " cannot inherit from sealed class " +
+				sealedName}
+		case klass.Module != sealed.Module:
+			return &IncompatibleClassChangeError{Msg: "Failed same module check: subclass " + name +
+				" is in module '" + klass.Module + "' and sealed class " + sealedName + " is in module '" +
+				sealed.Module + "'"}
+		case klass.Module == "" && !samePackage(klass, sealed):
+			return &IncompatibleClassChangeError{Msg: "Failed same package check: subclass " + name +
+				" and sealed class " + sealedName + " are in the unnamed module, but in different packages"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/classbuilder"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// posts a class with the given superclass and module to the method area
+func postSealedTestClass(name, super, module string, permitted []string) *ClData {
+	k := &ClData{Name: name, Superclass: super, Module: module, PermittedSubclasses: permitted}
+	_ = insert(name, Klass{Status: 'F', Loader: "app", Data: k})
+	return k
+}
+
+func TestSubclassesOfSealedClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	postSealedTestClass("shapes/Shape", "", "",
+		[]string{"shapes/Circle", "other/Square", "shapes/Hexagon"})
+	postSealedTestClass("shapes/Circle", "shapes/Shape", "", nil)
+	postSealedTestClass("shapes/Triangle", "shapes/Shape", "", nil)
+	postSealedTestClass("other/Square", "shapes/Shape", "", nil)
+	postSealedTestClass("shapes/Hexagon", "shapes/Shape", "geometry", nil)
+
+	if err := LinkClass("shapes/Circle"); err != nil {
+		t.Errorf("Expected no error linking a permitted subclass, got: %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"shapes/Triangle": "java.lang.IncompatibleClassChangeError: class shapes.Triangle cannot inherit " +
+			"from sealed class shapes.Shape",
+		"other/Square": "java.lang.IncompatibleClassChangeError: Failed same package check: subclass " +
+			"other.Square and sealed class shapes.Shape are in the unnamed module, but in different packages",
+		"shapes/Hexagon": "java.lang.IncompatibleClassChangeError: Failed same module check: subclass " +
+			"shapes.Hexagon is in module 'geometry' and sealed class shapes.Shape is in module ''",
+	} {
+		err := LinkClass(name)
+		var icce *IncompatibleClassChangeError
+		if !errors.As(err, &icce) {
+			t.Errorf("Expected IncompatibleClassChangeError linking %s, got: %v", name, err)
+			continue
+		}
+		if icce.Error() != expected {
+			t.Errorf("Expected: %s, got: %s", expected, icce.Error())
+		}
+	}
+}
+
+// a class that implements a sealed interface must be one of its permitted subclasses too
+func TestImplementationOfSealedInterface(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	postSealedTestClass("shapes/Polygon", "", "", []string{"shapes/Square"})
+	square := &ClData{Name: "shapes/Square", Superclass: "java/lang/Object", Interfaces: []uint16{0}}
+	square.CP.Utf8Refs = []string{"shapes/Polygon"}
+	if err := checkSealedSupertypes(square); err != nil {
+		t.Errorf("Expected no error for a permitted implementation, got: %v", err)
+	}
+
+	square.Name = "shapes/Rhombus"
+	if err := checkSealedSupertypes(square); err == nil {
+		t.Error("Expected an error for an implementation that's not permitted, but got none")
+	}
+}
+
+// writes the class built by cb to the classpath directory dir
+func writeBuiltClass(t *testing.T, dir string, name string, cb *classbuilder.ClassBuilder) {
+	b, err := cb.Version(61).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building %s: %s", name, err.Error())
+	}
+	filename := filepath.Join(dir, filepath.FromSlash(name)+".class")
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatalf("Unable to create package directory: %s", err.Error())
+	}
+	if err = os.WriteFile(filename, b, 0644); err != nil {
+		t.Fatalf("Unable to write class file: %s", err.Error())
+	}
+}
+
+// the sealed interface that a class implements is loaded, and checked, when the class
+// is loaded, even if nothing else has loaded the interface yet
+func TestLoadingImplementationOfUnloadedSealedInterface(t *testing.T) {
+	_, classpath := setUpDelegation(t)
+	writeBuiltClass(t, classpath, "shapes/Sealed", classbuilder.NewClassBuilder("shapes/Sealed").
+		AccessFlags(0x0601). // ACC_PUBLIC, ACC_INTERFACE, ACC_ABSTRACT
+		PermittedSubclasses("shapes/Allowed"))
+	writeBuiltClass(t, classpath, "shapes/Allowed",
+		classbuilder.NewClassBuilder("shapes/Allowed").Interfaces("shapes/Sealed"))
+	writeBuiltClass(t, classpath, "shapes/Intruder",
+		classbuilder.NewClassBuilder("shapes/Intruder").Interfaces("shapes/Sealed"))
+
+	err := LoadClassFromNameOnly("shapes/Intruder")
+	var icce *IncompatibleClassChangeError
+	if !errors.As(err, &icce) {
+		t.Fatalf("Expected IncompatibleClassChangeError loading shapes/Intruder, got: %v", err)
+	}
+	expected := "java.lang.IncompatibleClassChangeError: class shapes.Intruder cannot inherit " +
+		"from sealed class shapes.Sealed"
+	if icce.Error() != expected {
+		t.Errorf("Expected: %s, got: %s", expected, icce.Error())
+	}
+	if k, _ := LookupClass("shapes/Intruder"); k.Status != 'E' {
+		t.Errorf("Expected shapes/Intruder to be marked as failed, got status: %c", k.Status)
+	}
+
+	if err = LoadClassFromNameOnly("shapes/Allowed"); err != nil {
+		t.Errorf("Expected no error loading a permitted implementation, got: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/log"
+	"strings"
+)
+
+// The Signature attributes of classes, fields, and methods hold their generic type
+// information, which is erased from their descriptors: Ljava/util/List<Ljava/lang/String;>;
+// rather than Ljava/util/List;, for example. The signatures are kept as strings in ClData,
+// Field, and Method, and parsed into trees of TypeSignatures by the functions here, which
+// implement the grammar in:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.9.1
+
+// the kinds of TypeSignature
+const (
+	SigBaseType = iota // a primitive type, or V for the result of a void method
+	SigClassType
+	SigTypeVariable
+	SigArrayType
+)
+
+// TypeSignature is a type in a generic signature. Name is the descriptor character of a
+// base type (I, Z, V, etc.); the name of a class type, as in java/util/Map$Entry; or the
+// name of a type variable, as in T. Args are the type arguments of a class type, and
+// Outer is the class type of which an inner class type is a member, when the outer type
+// has type arguments of its own. Component is the component type of an array type.
+type TypeSignature struct {
+	Kind      int
+	Name      string
+	Args      []TypeArgument
+	Outer     *TypeSignature
+	Component *TypeSignature
+}
+
+// TypeArgument is a type argument of a class type. Wildcard is 0 for an exact type,
+// '+' for ? extends Type, '-' for ? super Type, or '*' for an unbounded ?, which has no
+// Type.
+type TypeArgument struct {
+	Wildcard byte
+	Type     *TypeSignature
+}
+
+// TypeParameter is a type parameter of a generic class or method, such as
+// T extends Comparable<T>. ClassBound is nil if the first bound is an interface.
+type TypeParameter struct {
+	Name            string
+	ClassBound      *TypeSignature
+	InterfaceBounds []*TypeSignature
+}
+
+// ClassSignature is the parsed Signature attribute of a class
+type ClassSignature struct {
+	TypeParams []TypeParameter
+	Superclass *TypeSignature
+	Interfaces []*TypeSignature
+}
+
+// MethodSignature is the parsed Signature attribute of a method
+type MethodSignature struct {
+	TypeParams []TypeParameter
+	Params     []*TypeSignature
+	Result     *TypeSignature
+	Throws     []*TypeSignature
+}
+
+var errBadSignature = errors.New("invalid generic signature")
+
+// checkSignatures checks the generic signatures of the class and of its fields and
+// methods. As the JVM doesn't use the signatures itself, a malformed one doesn't stop
+// the class from loading: it's logged as a warning and dropped, as if the class had
+// no Signature attribute there.
+func checkSignatures(klass *ParsedClass) {
+	warn := func(sig, owner string) {
+		_ = log.Log("Invalid generic signature "+sig+" of "+owner+" ignored", log.WARNING)
+	}
+
+	if klass.signature != "" {
+		if _, err := ParseClassSignature(klass.signature); err != nil {
+			warn(klass.signature, "class "+klass.className)
+			klass.signature = ""
+		}
+	}
+	for i := range klass.fields {
+		f := &klass.fields[i]
+		if f.signature == "" {
+			continue
+		}
+		if _, err := ParseFieldSignature(f.signature); err != nil {
+			warn(f.signature, "field "+klass.utf8Refs[f.name].content+" of "+klass.className)
+			f.signature = ""
+		}
+	}
+	for i := range klass.components {
+		rc := &klass.components[i]
+		if rc.signature == "" {
+			continue
+		}
+		if _, err := ParseFieldSignature(rc.signature); err != nil {
+			warn(rc.signature, "record component "+klass.utf8Refs[rc.name].content+" of "+klass.className)
+			rc.signature = ""
+		}
+	}
+	for i := range klass.methods {
+		m := &klass.methods[i]
+		if m.signature == "" {
+			continue
+		}
+		if _, err := ParseMethodSignature(m.signature); err != nil {
+			warn(m.signature, "method "+klass.utf8Refs[m.name].content+
+				klass.utf8Refs[m.description].content+" of "+klass.className)
+			m.signature = ""
+		}
+	}
+}
+
+// ParseClassSignature parses the generic signature of a class, such as
+// <T:Ljava/lang/Object;>Ljava/lang/Object;Ljava/lang/Iterable<TT;>;
+func ParseClassSignature(sig string) (*ClassSignature, error) {
+	p := signatureParser{sig: sig}
+	cs := &ClassSignature{TypeParams: p.typeParams()}
+	cs.Superclass = p.classType()
+	for p.err == nil && p.pos < len(sig) {
+		cs.Interfaces = append(cs.Interfaces, p.classType())
+	}
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// ParseMethodSignature parses the generic signature of a method, such as
+// <T:Ljava/lang/Object;>(Ljava/util/List<TT;>;)TT;
+func ParseMethodSignature(sig string) (*MethodSignature, error) {
+	p := signatureParser{sig: sig}
+	ms := &MethodSignature{TypeParams: p.typeParams()}
+	p.expect('(')
+	for p.err == nil && p.peek() != ')' {
+		ms.Params = append(ms.Params, p.javaType())
+	}
+	p.expect(')')
+	if p.peek() == 'V' {
+		p.pos++
+		ms.Result = &TypeSignature{Kind: SigBaseType, Name: "V"}
+	} else {
+		ms.Result = p.javaType()
+	}
+	for p.err == nil && p.peek() == '^' {
+		p.pos++
+		thrown := p.referenceType()
+		if thrown != nil && thrown.Kind == SigArrayType {
+			p.err = errBadSignature
+		}
+		ms.Throws = append(ms.Throws, thrown)
+	}
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// ParseFieldSignature parses the generic signature of a field, such as
+// Ljava/util/List<Ljava/lang/String;>;
+func ParseFieldSignature(sig string) (*TypeSignature, error) {
+	p := signatureParser{sig: sig}
+	ts := p.referenceType()
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// String returns the type as it would be written in Java, but with class names in
+// java/lang/Object format, e.g., java/util/List<? extends T>[]
+func (ts *TypeSignature) String() string {
+	switch ts.Kind {
+	case SigBaseType:
+		return baseTypeNames[ts.Name[0]]
+	case SigTypeVariable:
+		return ts.Name
+	case SigArrayType:
+		return ts.Component.String() + "[]"
+	}
+
+	var sb strings.Builder
+	if ts.Outer != nil {
+		sb.WriteString(ts.Outer.String() + "." + ts.Name[strings.LastIndex(ts.Name, "$")+1:])
+	} else {
+		sb.WriteString(ts.Name)
+	}
+	if len(ts.Args) > 0 {
+		args := make([]string, len(ts.Args))
+		for i, arg := range ts.Args {
+			switch arg.Wildcard {
+			case '*':
+				args[i] = "?"
+			case '+':
+				args[i] = "? extends " + arg.Type.String()
+			case '-':
+				args[i] = "? super " + arg.Type.String()
+			default:
+				args[i] = arg.Type.String()
+			}
+		}
+		sb.WriteString("<" + strings.Join(args, ", ") + ">")
+	}
+	return sb.String()
+}
+
+var baseTypeNames = map[byte]string{
+	'B': "byte", 'C': "char", 'D': "double", 'F': "float", 'I': "int",
+	'J': "long", 'S': "short", 'Z': "boolean", 'V': "void",
+}
+
+// signatureParser parses a signature by recursive descent. Once err is set, the parsing
+// functions return without consuming anything, so callers check err only at the end.
+type signatureParser struct {
+	sig string
+	pos int
+	err error
+}
+
+// returns the next character, or 0 at the end of the signature
+func (p *signatureParser) peek() byte {
+	if p.err != nil || p.pos >= len(p.sig) {
+		return 0
+	}
+	return p.sig[p.pos]
+}
+
+func (p *signatureParser) expect(c byte) {
+	if p.peek() != c {
+		p.err = errBadSignature
+		return
+	}
+	p.pos++
+}
+
+// returns the error, if any, or an error if the signature hasn't been wholly parsed
+func (p *signatureParser) finish() error {
+	if p.err == nil && p.pos != len(p.sig) {
+		p.err = errBadSignature
+	}
+	return p.err
+}
+
+// parses an identifier, which can hold any characters other than . ; [ / < > :
+func (p *signatureParser) identifier() string {
+	start := p.pos
+	for p.err == nil && p.pos < len(p.sig) && !strings.ContainsRune(".;[/<>:", rune(p.sig[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.err = errBadSignature
+	}
+	return p.sig[start:p.pos]
+}
+
+// parses the optional type parameters at the start of a class or method signature
+func (p *signatureParser) typeParams() []TypeParameter {
+	if p.peek() != '<' {
+		return nil
+	}
+	p.pos++
+	var params []TypeParameter
+	for p.err == nil && p.peek() != '>' {
+		param := TypeParameter{Name: p.identifier()}
+		p.expect(':')
+		if c := p.peek(); c == 'L' || c == 'T' || c == '[' {
+			param.ClassBound = p.referenceType()
+		}
+		for p.err == nil && p.peek() == ':' {
+			p.pos++
+			param.InterfaceBounds = append(param.InterfaceBounds, p.referenceType())
+		}
+		params = append(params, param)
+	}
+	p.expect('>')
+	if len(params) == 0 {
+		p.err = errBadSignature
+	}
+	return params
+}
+
+// parses a base type or a reference type
+func (p *signatureParser) javaType() *TypeSignature {
+	c := p.peek()
+	if _, isBase := baseTypeNames[c]; isBase && c != 'V' {
+		p.pos++
+		return &TypeSignature{Kind: SigBaseType, Name: string(c)}
+	}
+	return p.referenceType()
+}
+
+// parses a class type, type variable, or array type
+func (p *signatureParser) referenceType() *TypeSignature {
+	switch p.peek() {
+	case 'L':
+		return p.classType()
+	case 'T':
+		p.pos++
+		ts := &TypeSignature{Kind: SigTypeVariable, Name: p.identifier()}
+		p.expect(';')
+		return ts
+	case '[':
+		p.pos++
+		return &TypeSignature{Kind: SigArrayType, Component: p.javaType()}
+	}
+	p.err = errBadSignature
+	return nil
+}
+
+// parses a class type, such as Ljava/util/Map<TK;TV;>.Entry<TK;TV;>;
+func (p *signatureParser) classType() *TypeSignature {
+	p.expect('L')
+	ts := &TypeSignature{Kind: SigClassType, Name: p.identifier()}
+	for p.err == nil && p.peek() == '/' {
+		p.pos++
+		ts.Name += "/" + p.identifier()
+	}
+	ts.Args = p.typeArgs()
+
+	for p.err == nil && p.peek() == '.' { // an inner class
+		p.pos++
+		inner := &TypeSignature{Kind: SigClassType, Name: ts.Name + "$" + p.identifier()}
+		if len(ts.Args) > 0 || ts.Outer != nil {
+			inner.Outer = ts
+		}
+		inner.Args = p.typeArgs()
+		ts = inner
+	}
+	p.expect(';')
+	return ts
+}
+
+// parses the optional type arguments of a class type
+func (p *signatureParser) typeArgs() []TypeArgument {
+	if p.peek() != '<' {
+		return nil
+	}
+	p.pos++
+	var args []TypeArgument
+	for p.err == nil && p.peek() != '>' {
+		switch c := p.peek(); c {
+		case '*':
+			p.pos++
+			args = append(args, TypeArgument{Wildcard: '*'})
+		case '+', '-':
+			p.pos++
+			args = append(args, TypeArgument{Wildcard: c, Type: p.referenceType()})
+		default:
+			args = append(args, TypeArgument{Type: p.referenceType()})
+		}
+	}
+	p.expect('>')
+	if len(args) == 0 {
+		p.err = errBadSignature
+	}
+	return args
+}
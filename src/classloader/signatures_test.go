@@ -0,0 +1,186 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldSignature(t *testing.T) {
+	tests := map[string]string{
+		"Ljava/util/List<Ljava/lang/String;>;":         "java/util/List<java/lang/String>",
+		"Ljava/util/Map<TK;+Ljava/lang/Number;>;":      "java/util/Map<K, ? extends java/lang/Number>",
+		"Ljava/util/List<-TT;>;":                       "java/util/List<? super T>",
+		"Ljava/lang/Class<*>;":                         "java/lang/Class<?>",
+		"[[Ljava/util/List<Ljava/lang/String;>;":       "java/util/List<java/lang/String>[][]",
+		"[I":                                           "int[]",
+		"TT;":                                          "T",
+		"LOuter<TT;>.Inner<Ljava/lang/String;>;":       "Outer<T>.Inner<java/lang/String>",
+		"Ljava/util/Map$Entry<Ljava/lang/String;TV;>;": "java/util/Map$Entry<java/lang/String, V>",
+		"Ljava/util/Map<Ljava/lang/String;[TT;>;":      "java/util/Map<java/lang/String, T[]>",
+		"Ljava/util/List<Ljava/util/List<TE;>;>;":      "java/util/List<java/util/List<E>>",
+		"Lcom/example/Outer.Inner;":                    "com/example/Outer$Inner",
+	}
+	for sig, expected := range tests {
+		ts, err := ParseFieldSignature(sig)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", sig, err)
+		} else if ts.String() != expected {
+			t.Errorf("Expected %s to be %s, got: %s", sig, expected, ts.String())
+		}
+	}
+
+	ts, _ := ParseFieldSignature("Ljava/util/Map<TK;+Ljava/lang/Number;>;")
+	if ts.Kind != SigClassType || ts.Name != "java/util/Map" || len(ts.Args) != 2 ||
+		ts.Args[0].Wildcard != 0 || ts.Args[0].Type.Kind != SigTypeVariable ||
+		ts.Args[1].Wildcard != '+' || ts.Args[1].Type.Name != "java/lang/Number" {
+		t.Errorf("Unexpected parse of Map<K, ? extends Number>: %+v", ts)
+	}
+}
+
+func TestParseSignaturesInvalid(t *testing.T) {
+	fields := []string{"", "I", "Ljava/util/List", "Ljava/util/List<>;", "Ljava/util/List<I>;",
+		"TT", "[", "Ljava/lang/String;X", "L;", "Ljava//String;", "Ljava/util/List<TT;;"}
+	for _, sig := range fields {
+		if _, err := ParseFieldSignature(sig); err == nil {
+			t.Errorf("Expected an error parsing the field signature %q, but got none", sig)
+		}
+	}
+
+	classes := []string{"", "<>Ljava/lang/Object;", "<T>Ljava/lang/Object;", "TT;", "[Ljava/lang/Object;",
+		"Ljava/lang/Object;I"}
+	for _, sig := range classes {
+		if _, err := ParseClassSignature(sig); err == nil {
+			t.Errorf("Expected an error parsing the class signature %q, but got none", sig)
+		}
+	}
+
+	methods := []string{"", "()", "(V)V", "(I", "()VV", "()V^[Ljava/lang/Exception;", "()V^I"}
+	for _, sig := range methods {
+		if _, err := ParseMethodSignature(sig); err == nil {
+			t.Errorf("Expected an error parsing the method signature %q, but got none", sig)
+		}
+	}
+}
+
+func TestParseClassSignature(t *testing.T) {
+	cs, err := ParseClassSignature(
+		"<K::Ljava/lang/Comparable<TK;>;V:Ljava/lang/Object;>Ljava/util/AbstractMap<TK;TV;>;" +
+			"Ljava/util/SortedMap<TK;TV;>;Ljava/io/Serializable;")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the class signature: %v", err)
+	}
+
+	if len(cs.TypeParams) != 2 {
+		t.Fatalf("Expected 2 type parameters, got: %+v", cs.TypeParams)
+	}
+	k, v := cs.TypeParams[0], cs.TypeParams[1]
+	if k.Name != "K" || k.ClassBound != nil || len(k.InterfaceBounds) != 1 ||
+		k.InterfaceBounds[0].String() != "java/lang/Comparable<K>" {
+		t.Errorf("Expected K extends Comparable<K>, got: %+v", k)
+	}
+	if v.Name != "V" || v.ClassBound.String() != "java/lang/Object" || len(v.InterfaceBounds) != 0 {
+		t.Errorf("Expected V extends Object, got: %+v", v)
+	}
+
+	if cs.Superclass.String() != "java/util/AbstractMap<K, V>" {
+		t.Errorf("Expected the superclass to be AbstractMap<K, V>, got: %s", cs.Superclass.String())
+	}
+	if len(cs.Interfaces) != 2 || cs.Interfaces[0].String() != "java/util/SortedMap<K, V>" ||
+		cs.Interfaces[1].String() != "java/io/Serializable" {
+		t.Errorf("Expected the interfaces to be SortedMap<K, V> and Serializable, got: %v", cs.Interfaces)
+	}
+
+	cs, err = ParseClassSignature("<T:Ljava/lang/Object;>Ljava/util/List<TT;>;")
+	if err != nil || len(cs.TypeParams) != 1 || cs.TypeParams[0].ClassBound.String() != "java/lang/Object" ||
+		cs.Superclass.String() != "java/util/List<T>" {
+		t.Errorf("Unexpected parse of a class extending List<T>: %+v, %v", cs, err)
+	}
+
+	cs, err = ParseClassSignature("Ljava/lang/Object;")
+	if err != nil || cs.TypeParams != nil || cs.Superclass.Name != "java/lang/Object" || cs.Interfaces != nil {
+		t.Errorf("Unexpected parse of a signature with no type parameters or interfaces: %+v, %v", cs, err)
+	}
+}
+
+func TestParseMethodSignature(t *testing.T) {
+	ms, err := ParseMethodSignature(
+		"<T:Ljava/lang/Object;X:Ljava/lang/Exception;>(ILjava/util/List<+TT;>;[TT;)TT;^TX;^Ljava/io/IOException;")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the method signature: %v", err)
+	}
+
+	if len(ms.TypeParams) != 2 || ms.TypeParams[0].Name != "T" || ms.TypeParams[1].Name != "X" {
+		t.Errorf("Expected the type parameters T and X, got: %+v", ms.TypeParams)
+	}
+	params := []string{"int", "java/util/List<? extends T>", "T[]"}
+	if len(ms.Params) != len(params) {
+		t.Fatalf("Expected %d parameters, got: %v", len(params), ms.Params)
+	}
+	for i, param := range params {
+		if ms.Params[i].String() != param {
+			t.Errorf("Expected parameter %d to be %s, got: %s", i, param, ms.Params[i].String())
+		}
+	}
+	if ms.Result.Kind != SigTypeVariable || ms.Result.Name != "T" {
+		t.Errorf("Expected the result to be T, got: %+v", ms.Result)
+	}
+	if len(ms.Throws) != 2 || ms.Throws[0].String() != "X" || ms.Throws[1].String() != "java/io/IOException" {
+		t.Errorf("Expected the method to throw X and IOException, got: %v", ms.Throws)
+	}
+
+	ms, err = ParseMethodSignature("()V")
+	if err != nil || ms.Params != nil || ms.Result.String() != "void" || ms.Throws != nil {
+		t.Errorf("Unexpected parse of ()V: %+v, %v", ms, err)
+	}
+}
+
+// a malformed signature is dropped with a warning, and the class loads without it
+func TestCheckSignaturesDropsInvalidSignature(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := ParseAndPostClass(AppCL, "Names.class", genericSignaturesClass(1)) // the field's signature is "Names"
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Fatalf("Unexpected error loading a class with an invalid signature: %s", err.Error())
+	}
+	if !strings.Contains(string(out), "Invalid generic signature Names of field names of Names ignored") {
+		t.Errorf("Expected a warning about the field's signature, got: %s", string(out))
+	}
+
+	k, ok := LookupClass("Names")
+	if !ok {
+		t.Fatalf("Expected Names to be in the method area")
+	}
+	if k.Data.Fields[0].Signature != "" {
+		t.Errorf("Expected the invalid signature to be dropped, got: %s", k.Data.Fields[0].Signature)
+	}
+	if k.Data.Signature == "" || k.Data.Methods[0].Signature == "" {
+		t.Errorf("Expected the valid signatures to be kept, got: %q and %q",
+			k.Data.Signature, k.Data.Methods[0].Signature)
+	}
+
+	stats, _ := LookupClassStats("Names")
+	if stats.Signature != "<T::Ljava/lang/Comparable<TT;>;>Ljava/lang/Object;" {
+		t.Errorf("Expected the class's signature in its stats, got: %q", stats.Signature)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strconv"
+)
+
+// The StackMapTable attribute of a method's Code attribute gives the types of the
+// local variables and the operand stack at various points in the method's bytecode,
+// which the bytecode verifier checks the code against. Each frame is stored as it
+// appears in the class file, that is, as a change from the previous frame. See:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.4
+
+// the tags of the verification types
+const (
+	ItemTop               = 0
+	ItemInteger           = 1
+	ItemFloat             = 2
+	ItemDouble            = 3
+	ItemLong              = 4
+	ItemNull              = 5
+	ItemUninitializedThis = 6
+	ItemObject            = 7
+	ItemUninitialized     = 8
+)
+
+// the frame types, which are the first byte of each frame, that end each range of
+// kinds of frame. Types 128-246 are reserved for future use.
+const (
+	sameFrameMax                      = 63  // same_frame
+	sameLocals1StackItemFrameMax      = 127 // same_locals_1_stack_item_frame
+	reservedFrameMax                  = 246
+	sameLocals1StackItemFrameExtended = 247
+	sameFrameExtended                 = 251 // preceded by the chop frames, 248-250
+	appendFrameMax                    = 254 // followed by full_frame, 255
+)
+
+// StackMapFrame is a frame in the StackMapTable attribute. FrameType is the frame's
+// first byte, which gives its kind. Locals and Stack hold the verification types the
+// frame gives: for an append frame, the locals it adds to the previous frame; for a
+// full frame, all the locals and the whole stack; for a same_locals_1_stack_item frame,
+// the one item on the stack. A chop frame removes 251-FrameType locals from the
+// previous frame.
+type StackMapFrame struct {
+	FrameType   int
+	OffsetDelta int // the bytecode offset of the frame, less that of the previous frame plus 1
+	Locals      []VerificationType
+	Stack       []VerificationType
+}
+
+// VerificationType is the type of a local variable or stack entry in a StackMapFrame.
+// For ItemObject, Index is the CP index of the ClassRef of the type; for
+// ItemUninitialized, it's the offset of the new instruction that created the object.
+type VerificationType struct {
+	Tag   int
+	Index int
+}
+
+// the opcode of the new instruction, which creates the objects of ItemUninitialized types
+const opNew = 0xBB
+
+// parseStackMapTable parses the content of a StackMapTable attribute into its frames.
+// Each frame must be at an offset within code, the method's bytecode, and each
+// ItemUninitialized type must give the offset of a new instruction.
+func parseStackMapTable(content, code []byte, klass *ParsedClass, methodName string) ([]StackMapFrame, error) {
+	r := &classReader{b: content}
+	invalid := func(frame int, what string) error {
+		return cfe("Invalid " + what + " in frame #" + strconv.Itoa(frame) +
+			" of StackMapTable in " + methodName + "() of " + klass.className)
+	}
+
+	count := r.u2()
+	frames := make([]StackMapFrame, 0, count)
+	offset := -1 // the bytecode offset of the previous frame
+	for i := 0; i < count && r.err == nil; i++ {
+		frame := StackMapFrame{FrameType: int(r.u1())}
+		var err error
+		switch {
+		case frame.FrameType <= sameFrameMax:
+			frame.OffsetDelta = frame.FrameType
+
+		case frame.FrameType <= sameLocals1StackItemFrameMax:
+			frame.OffsetDelta = frame.FrameType - (sameFrameMax + 1)
+			frame.Stack, err = parseVerificationTypes(r, 1, code, klass)
+
+		case frame.FrameType <= reservedFrameMax:
+			return nil, invalid(i, "frame type "+strconv.Itoa(frame.FrameType))
+
+		case frame.FrameType == sameLocals1StackItemFrameExtended:
+			frame.OffsetDelta = r.u2()
+			frame.Stack, err = parseVerificationTypes(r, 1, code, klass)
+
+		case frame.FrameType <= sameFrameExtended: // chop frames and same_frame_extended
+			frame.OffsetDelta = r.u2()
+
+		case frame.FrameType <= appendFrameMax:
+			frame.OffsetDelta = r.u2()
+			frame.Locals, err = parseVerificationTypes(r, frame.FrameType-sameFrameExtended, code, klass)
+
+		default: // full_frame
+			frame.OffsetDelta = r.u2()
+			frame.Locals, err = parseVerificationTypes(r, r.u2(), code, klass)
+			if err == nil {
+				frame.Stack, err = parseVerificationTypes(r, r.u2(), code, klass)
+			}
+		}
+		if err != nil {
+			return nil, invalid(i, err.Error())
+		}
+
+		offset += frame.OffsetDelta + 1
+		if r.err == nil && offset >= len(code) {
+			return nil, invalid(i, "offset "+strconv.Itoa(offset)+" (the code is "+strconv.Itoa(len(code))+
+				" bytes long)")
+		}
+		frames = append(frames, frame)
+	}
+
+	if r.err != nil {
+		return nil, cfe("StackMapTable is truncated in " + methodName + "() of " + klass.className)
+	}
+	if r.pos != len(content) {
+		return nil, cfe("StackMapTable has " + strconv.Itoa(len(content)-r.pos) + " extra byte(s) in " +
+			methodName + "() of " + klass.className)
+	}
+	return frames, nil
+}
+
+// parses count verification types
+func parseVerificationTypes(r *classReader, count int, code []byte, klass *ParsedClass) ([]VerificationType, error) {
+	types := make([]VerificationType, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		vt := VerificationType{Tag: int(r.u1())}
+		switch vt.Tag {
+		case ItemObject:
+			vt.Index = r.u2()
+			if r.err == nil && !cpEntryIs(klass, vt.Index, ClassRef) {
+				return nil, errors.New("class index " + strconv.Itoa(vt.Index))
+			}
+		case ItemUninitialized:
+			vt.Index = r.u2()
+			if r.err == nil && (vt.Index >= len(code) || code[vt.Index] != opNew) {
+				return nil, errors.New("offset of new instruction " + strconv.Itoa(vt.Index))
+			}
+		default:
+			if vt.Tag > ItemUninitialized {
+				return nil, errors.New("verification type " + strconv.Itoa(vt.Tag))
+			}
+		}
+		types = append(types, vt)
+	}
+	return types, nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "unsafe"
+
+// DeduplicateStrings makes all live StringObjects with equal values share a single
+// backing Go string, so that only one copy of each value is kept in memory. It's run
+// after garbage collections when -XX:+UseStringDeduplication is specified. It returns
+// the number of StringObjects whose backing string was replaced. The canonical backing
+// string of each value is chosen afresh from the live Strings on each run, so nothing
+// is kept between runs: a value whose Strings have all been collected is forgotten.
+func DeduplicateStrings() int {
+	// collect the candidates first, so the objects table isn't locked while they're updated
+	var candidates []*StringObject
+	liveObjectsMutex.RLock()
+	for _, obj := range liveObjects {
+		if str, ok := obj.(*StringObject); ok {
+			candidates = append(candidates, str)
+		}
+	}
+	liveObjectsMutex.RUnlock()
+
+	// the canonical backing string of each value, which is that of the first String
+	// with the value
+	canonical := make(map[string]string)
+	replaced := 0
+	for _, str := range candidates {
+		value, present := canonical[str.Value]
+		if !present {
+			canonical[str.Value] = str.Value
+			continue
+		}
+		// the values are equal, but they might already share their backing string
+		if stringData(str.Value) != stringData(value) {
+			str.Value = value
+			replaced++
+		}
+	}
+	return replaced
+}
+
+// stringData returns the address of a string's bytes, which is the first word of
+// its header
+func stringData(s string) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&s))
+}
@@ -0,0 +1,165 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/log"
+	"jacobin/management"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Unloading removes classes from the method area, so that long-running programs, and
+// programs that embed Jacobin and run it many times, don't keep every class they've
+// ever loaded. An unloaded class is removed from the method area, from the Classes of
+// the classloader that defined it, and from the MTable (its Java methods only), the
+// class-bytes cache, and the class statistics. If it's loaded again later, it's loaded
+// afresh. Only classes that aren't in use can be unloaded (see ClassInUseError).
+
+// ClassInUseError reports a class that can't be unloaded because of its status: it's
+// being loaded ('I'), it's been linked ('L'), so its methods might be executing or its
+// statics be in use, or it has instances ('N'). The interpreter records the last two
+// with AdvanceClassStatus(), when it first fetches one of the class's methods or sets
+// up its statics, and when it first instantiates the class.
+type ClassInUseError struct {
+	Class  string
+	Status byte
+}
+
+func (e *ClassInUseError) Error() string {
+	var reason string
+	switch e.Status {
+	case 'I':
+		reason = "it is being loaded"
+	case 'L':
+		reason = "it has been linked"
+	default:
+		reason = "it has instances"
+	}
+	return "cannot unload class " + e.Class + ", as " + reason
+}
+
+// reports whether a class with the given status is in use, and so can't be unloaded
+func isInUse(status byte) bool {
+	return status == 'I' || status == 'L' || status == 'N'
+}
+
+// Unload unloads the named class (in java/lang/Object format), however many
+// classloaders have defined a class of that name. If the class, as defined by any of
+// them, is in use, nothing is unloaded, and a *ClassInUseError is returned.
+func Unload(className string) error {
+	classloadersMutex.Lock()
+	defer classloadersMutex.Unlock()
+	methodAreaMutex.Lock()
+	defer methodAreaMutex.Unlock()
+
+	k, present := LookupClass(className)
+	if present && isInUse(k.Status) {
+		return &ClassInUseError{Class: className, Status: k.Status}
+	}
+	var definers []*Classloader
+	for _, cl := range []*Classloader{&BootstrapCL, &ExtensionCL, &AppCL} {
+		if k, defined := cl.Classes[className]; defined {
+			if isInUse(k.Status) {
+				return &ClassInUseError{Class: className, Status: k.Status}
+			}
+			definers = append(definers, cl)
+		}
+	}
+	if !present && len(definers) == 0 {
+		return errors.New("cannot unload class " + className + ", which has not been loaded")
+	}
+
+	Classes.Delete(className)
+	for _, cl := range definers {
+		delete(cl.Classes, className)
+		forgetClassStats(cl.Name, className)
+		management.RecordClassUnload()
+	}
+	forgetClass(className)
+	_ = log.Log("Unloaded class: "+className, log.CLASS)
+	return nil
+}
+
+// UnloadByLoader unloads all the classes that the named classloader (e.g., app) has
+// defined, except those that are in use. It returns the number of classes unloaded
+// and, if any classes were in use, an error that names them. Where another classloader
+// has defined a class of the same name as one that's unloaded, the method area then
+// holds that class instead.
+func UnloadByLoader(loaderName string) (int, error) {
+	var cl *Classloader
+	switch loaderName {
+	case BootstrapCL.Name:
+		cl = &BootstrapCL
+	case ExtensionCL.Name:
+		cl = &ExtensionCL
+	case AppCL.Name:
+		cl = &AppCL
+	default:
+		return 0, errors.New("cannot unload the classes of unknown classloader " + loaderName)
+	}
+
+	classloadersMutex.Lock()
+	defer classloadersMutex.Unlock()
+	methodAreaMutex.Lock()
+	defer methodAreaMutex.Unlock()
+
+	unloaded := 0
+	var inUse []string
+	for name, k := range cl.Classes {
+		current, present := LookupClass(name)
+		if isInUse(k.Status) || (present && current.Loader == cl.Name && isInUse(current.Status)) {
+			inUse = append(inUse, name)
+			continue
+		}
+
+		delete(cl.Classes, name)
+		forgetClassStats(cl.Name, name)
+		management.RecordClassUnload()
+		unloaded++
+		if !present || current.Loader != cl.Name {
+			continue // the method area holds another classloader's class of this name
+		}
+
+		// the class defined highest in the parent chain, if any, takes its place
+		if replacement, defined := lookupClassInLocked(&AppCL, name); defined {
+			Classes.Store(name, replacement)
+			removeJavaMethods(name) // so they're fetched from the replacement
+		} else {
+			Classes.Delete(name)
+			forgetClass(name)
+		}
+	}
+	_ = log.Log("Unloaded "+strconv.Itoa(unloaded)+" classes of the "+cl.Name+" classloader", log.CLASS)
+
+	if len(inUse) > 0 {
+		sort.Strings(inUse)
+		return unloaded, errors.New("cannot unload " + strconv.Itoa(len(inUse)) +
+			" classes of the " + cl.Name + " classloader, which are in use: " + strings.Join(inUse, ", "))
+	}
+	return unloaded, nil
+}
+
+// removes what's kept about an unloaded class outside the method area and classloaders,
+// other than its stats, which are kept for each classloader (see forgetClassStats())
+func forgetClass(name string) {
+	removeJavaMethods(name)
+
+	classBytesMutex.Lock()
+	delete(classBytesCache, name)
+	classBytesMutex.Unlock()
+}
+
+// removes the stats of the named class defined by the named classloader, which has
+// unloaded it
+func forgetClassStats(loader, name string) {
+	classStatsMutex.Lock()
+	delete(classStats, classStatsKey(loader, name))
+	classStatsMutex.Unlock()
+}
@@ -0,0 +1,169 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/management"
+	"strings"
+	"testing"
+)
+
+// reports whether there are stats for the named class defined by the named classloader
+func hasClassStats(loader, name string) bool {
+	_, err := LookupClassStats(classStatsKey(loader, name))
+	return err == nil
+}
+
+// returns the count of the named key from the "classes" management provider
+func classesCount(t *testing.T, key string) string {
+	provider, _ := management.Provider("classes")
+	detail, err := provider.Detail(key)
+	if err != nil {
+		t.Fatalf("Unexpected error getting the %s count: %s", key, err.Error())
+	}
+	return detail["count"]
+}
+
+func TestUnload(t *testing.T) {
+	setUpDelegation(t)
+	const name = "com/example/Unloadable"
+	unloadedBefore := classesCount(t, "unloaded")
+
+	if _, err := ParseAndPostClass(AppCL, "Unloadable.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error defining %s: %s", name, err.Error())
+	}
+	MTable[name+".run()V"] = MTentry{MType: 'J'}
+	if !hasClassStats("app", name) {
+		t.Fatalf("Expected stats for %s once it was defined", name)
+	}
+
+	if err := Unload(name); err != nil {
+		t.Fatalf("Unexpected error unloading %s: %s", name, err.Error())
+	}
+	if _, present := LookupClass(name); present {
+		t.Errorf("Expected %s to be removed from the method area", name)
+	}
+	if _, defined := AppCL.Classes[name]; defined {
+		t.Errorf("Expected %s to be removed from the app classloader", name)
+	}
+	if _, ok := MTable[name+".run()V"]; ok {
+		t.Errorf("Expected the methods of %s to be removed from the MTable", name)
+	}
+	if _, ok := OriginalClassBytes(name); ok {
+		t.Errorf("Expected the bytes of %s to be discarded", name)
+	}
+	if hasClassStats("app", name) {
+		t.Errorf("Expected the stats of %s to be removed", name)
+	}
+	if unloaded := classesCount(t, "unloaded"); unloaded == unloadedBefore {
+		t.Errorf("Expected the unloaded count to increase from %s", unloadedBefore)
+	}
+
+	if err := Unload(name); err == nil {
+		t.Errorf("Expected an error unloading %s a second time", name)
+	}
+}
+
+func TestUnloadRefusesClassInUse(t *testing.T) {
+	setUpDelegation(t)
+	const name = "com/example/InUse"
+	if _, err := ParseAndPostClass(AppCL, "InUse.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error defining %s: %s", name, err.Error())
+	}
+	// fetching a method to run links the class
+	if _, err := FetchMethodAndCP(name, "run", "()V"); err != nil {
+		t.Fatalf("Unexpected error fetching %s.run(): %s", name, err.Error())
+	}
+	if k, _ := LookupClass(name); k.Status != 'L' {
+		t.Fatalf("Expected %s to be linked once its method was fetched, got status: %c", name, k.Status)
+	}
+	if k := AppCL.Classes[name]; k.Status != 'L' {
+		t.Errorf("Expected the app classloader's %s to be linked too, got status: %c", name, k.Status)
+	}
+
+	err := Unload(name)
+	var inUse *ClassInUseError
+	if !errors.As(err, &inUse) || inUse.Class != name || inUse.Status != 'L' {
+		t.Fatalf("Expected a ClassInUseError for %s, got: %v", name, err)
+	}
+	if _, present := LookupClass(name); !present {
+		t.Errorf("Expected %s to stay in the method area", name)
+	}
+	if _, defined := AppCL.Classes[name]; !defined {
+		t.Errorf("Expected %s to stay in the app classloader", name)
+	}
+}
+
+func TestUnloadByLoader(t *testing.T) {
+	setUpDelegation(t)
+	const shared, extOnly, busy = "com/example/Shared", "com/example/ExtOnly", "com/example/Busy"
+	for _, def := range []struct {
+		cl   Classloader
+		name string
+	}{{AppCL, shared}, {ExtensionCL, shared}, {ExtensionCL, extOnly}, {ExtensionCL, busy}} {
+		if _, err := ParseAndPostClass(def.cl, def.name+".class", versionedClass(t, def.name, 55)); err != nil {
+			t.Fatalf("Unexpected error defining %s: %s", def.name, err.Error())
+		}
+	}
+	AdvanceClassStatus(busy, 'N') // as instantiating it does
+
+	unloaded, err := UnloadByLoader("extension")
+	if unloaded != 2 {
+		t.Errorf("Expected 2 classes to be unloaded, got: %d", unloaded)
+	}
+	if err == nil || !strings.Contains(err.Error(), busy) {
+		t.Errorf("Expected an error naming %s, which is in use, got: %v", busy, err)
+	}
+
+	// the app's class of the same name takes the place of the extension's
+	if k, present := LookupClass(shared); !present || k.Loader != "app" {
+		t.Errorf("Expected the method area to hold the app's %s, got: %+v", shared, k)
+	}
+	if _, present := LookupClass(extOnly); present {
+		t.Errorf("Expected %s to be removed from the method area", extOnly)
+	}
+	// the stats of the extension's classes go with them, but not those of the app's
+	if hasClassStats("extension", shared) || hasClassStats("extension", extOnly) {
+		t.Errorf("Expected the stats of the extension's %s and %s to be removed", shared, extOnly)
+	}
+	if !hasClassStats("app", shared) {
+		t.Errorf("Expected the stats of the app's %s to be kept", shared)
+	}
+	if !hasClassStats("extension", busy) {
+		t.Errorf("Expected the stats of %s, which is in use, to be kept", busy)
+	}
+	if _, defined := ExtensionCL.Classes[busy]; !defined {
+		t.Errorf("Expected %s, which is in use, to stay in the extension classloader", busy)
+	}
+
+	if _, err = UnloadByLoader("nonexistent"); err == nil {
+		t.Error("Expected an error unloading the classes of an unknown classloader")
+	}
+}
+
+// a class's status only moves forward, and only once it's loaded
+func TestAdvanceClassStatus(t *testing.T) {
+	setUpDelegation(t)
+	const name = "com/example/Advancing"
+	if _, err := ParseAndPostClass(AppCL, "Advancing.class", versionedClass(t, name, 55)); err != nil {
+		t.Fatalf("Unexpected error defining %s: %s", name, err.Error())
+	}
+
+	AdvanceClassStatus(name, 'N')
+	AdvanceClassStatus(name, 'L')
+	if k, _ := LookupClass(name); k.Status != 'N' {
+		t.Errorf("Expected %s to stay instantiated, got status: %c", name, k.Status)
+	}
+
+	Classes.Store("com/example/Loading", Klass{Status: 'I'})
+	AdvanceClassStatus("com/example/Loading", 'L')
+	if k, _ := LookupClass("com/example/Loading"); k.Status != 'I' {
+		t.Errorf("Expected a class that's being loaded to keep its status, got: %c", k.Status)
+	}
+	Classes.Delete("com/example/Loading")
+}
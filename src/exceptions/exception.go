@@ -43,6 +43,7 @@ const (
 	IllegalMonitorStateException
 	IllegalPathStateException
 	IllegalStateException
+	IllegalThreadStateException
 	IllformedLocaleException
 	ImagingOpException
 	InaccessibleObjectException
@@ -81,6 +82,7 @@ const (
 	ResolutionException
 	SecurityException
 	SPIResolutionException
+	StringIndexOutOfBoundsException
 	TypeNotPresentException
 	UncheckedIOException
 	UndeclaredThrowableException
@@ -111,6 +113,7 @@ const (
 	BrokenBarrierException
 	CardException
 	CertificateException
+	ClassNotFoundException
 	ClassNotLoadedException
 	CloneNotSupportedException
 	DataFormatException
@@ -183,14 +186,21 @@ const (
 	AnnotationFormatError
 	AssertionError
 	AWTError
+	BootstrapMethodError
+	ClassCircularityError
 	CoderMalfunctionError
 	FactoryConfigurationError
 	IOError
+	IncompatibleClassChangeError
 	LinkageError
+	NoClassDefFoundError
+	OutOfMemoryError
 	SchemaFactoryConfigurationError
 	ServiceConfigurationError
+	StackOverflowError
 	ThreadDeath
 	TransformerFactoryConfigurationError
+	VerifyError
 	VirtualMachineError
 )
 
@@ -20,52 +20,55 @@ type Number interface {
 }
 
 // Frame is the fundamental execution environment for a single function/method call.
-// Note that the operand stack (opStack) is made up of int64 items, rather than the JVM-
-// prescribed 32-bit entries. The rationale is that longs and doubles can be stored
-// without manipulation at this width. (However, there will still be need for the dummy
-// second stack entry for these data items.
+// Note that the slots of the operand stack (Operands) hold 64-bit values, rather than
+// the JVM-prescribed 32-bit entries. The rationale is that longs and doubles can be
+// stored without manipulation at this width. (However, they still take up two slots,
+// as max_stack requires.)
 type Frame struct {
 	Thread   int
 	MethName string             // method name
 	ClName   string             // class name
 	Meth     []byte             // bytecode of method
 	CP       *classloader.CPool // constant pool of class
-	Locals   []interface{}      // local variables
-	OpStack  []interface{}      // operand stack
-	TOS      int                // top of the operand stack
+	Locals   *LocalVars         // local variables
+	Operands *OperandStack      // operand stack
 	PC       int                // program counter (index into the bytecode of the method)
 	Ftype    byte               // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+
+	LineNumbers   classloader.LineNumberTable    // the method's source line numbers, for stack traces
+	LocalVarTable classloader.LocalVariableTable // the names of the method's local variables, for tracing
+}
+
+// FrameStack is the stack of frames of a thread, which is its chain of method calls.
+// It's a list in which the current running frame is always the frame at the head.
+type FrameStack struct {
+	list.List
 }
 
-// CreateFrameStack creates a stack of frames. Implemented as a list in which
-// the current running frame is always the frame at the head
-func CreateFrameStack() *list.List {
-	l := list.New()
-	return l
+// CreateFrameStack creates an empty stack of frames
+func CreateFrameStack() *FrameStack {
+	return &FrameStack{}
 }
 
-// CreateFrame creates a raw frame and allocates an opStack of the passed-in size.
+// CreateFrame creates a raw frame and allocates an empty operand stack of the passed-in
+// size. A negative size, which only a malformed class could have, is treated as 0.
 func CreateFrame(opStackSize int) *Frame {
 	fram := Frame{}
-	// fram.OpStack = *new(opStack)
-
-	if opStackSize < 0 { // TODO: Check if this is possible. If so, decide what to do. Class is clearly malformed.
-		opStackSize = 0
-	}
-
-	// allocate the operand stack
-	for j := 0; j < opStackSize; j++ {
-		fram.OpStack = append(fram.OpStack, 0)
-	}
-
-	// set top of stack to an empty stack
-	fram.TOS = -1
+	fram.Operands = NewOperandStack(opStackSize)
 	fram.PC = 0
 	return &fram
 }
 
+// NewFrame creates a frame for a Java method whose max_stack and max_locals are maxStack
+// and maxLocals, with an empty operand stack and local variables that are all null.
+func NewFrame(maxStack, maxLocals int) *Frame {
+	fram := CreateFrame(maxStack)
+	fram.Locals = NewLocalVars(maxLocals)
+	return fram
+}
+
 // PushFrame pushes a frame. This simply adds a frame to the head of the list.
-func PushFrame(fs *list.List, f *Frame) error {
+func PushFrame(fs *FrameStack, f *Frame) error {
 	fs.PushFront(f)
 	// TODO: move this to instrumentation system
 	if log.Level == log.FINEST {
@@ -80,7 +83,7 @@ func PushFrame(fs *list.List, f *Frame) error {
 }
 
 // PopFrame deletes the frame at the head of the list.
-func PopFrame(fs *list.List) error {
+func PopFrame(fs *FrameStack) error {
 	if fs.Len() == 0 {
 		return fmt.Errorf("invalid PopFrame of empty JVM frame stack")
 	}
@@ -92,7 +95,7 @@ func PopFrame(fs *list.List) error {
 // PeekFrame peeks at a given frame without popping or deleting it.
 // The current frame (so, top of stack) is 0, the one below it is 1, etc.
 // Pass that value in and you receive back a pointer to the frame.
-func PeekFrame(fs *list.List, which int) *Frame {
+func PeekFrame(fs *FrameStack, which int) *Frame {
 	var e *list.Element
 	i := 0
 	for e = fs.Front(); e != nil; e = e.Next() {
@@ -10,11 +10,25 @@ import "testing"
 
 func TestNewFrame(t *testing.T) {
 	f := CreateFrame(6)
-	if len(f.OpStack) != 6 || f.TOS != -1 || f.PC != 0 {
+	if f.Operands == nil || f.Operands.Depth() != 0 || f.PC != 0 {
 		t.Error("Created frame is invalid")
 	}
 }
 
+func TestNewFrameWithTypedStackAndLocals(t *testing.T) {
+	f := NewFrame(2, 3)
+	if f.Operands.Depth() != 0 || f.Locals.Size() != 3 {
+		t.Fatalf("Expected an empty operand stack and 3 locals, got %d slots and %d locals",
+			f.Operands.Depth(), f.Locals.Size())
+	}
+	if CreateFrame(2).Locals.Size() != 0 {
+		t.Error("Expected a frame without local variables to have 0 of them")
+	}
+	if f.Operands.PushLong(1) != nil || f.Operands.Push(int64(1)) == nil {
+		t.Error("Expected the operand stack to hold one long and nothing more")
+	}
+}
+
 func TestFrameStack(t *testing.T) {
 	fs := CreateFrameStack()
 	if fs.Len() != 0 {
@@ -48,7 +62,7 @@ func TestFramePeek(t *testing.T) {
 	_ = PushFrame(fs, f2)
 
 	peek := PeekFrame(fs, 1)
-	if len(peek.OpStack) != 1 {
-		t.Errorf("Peeked at prior frame. Expected size of opstack to be 1, got: %d", len(peek.OpStack))
+	if peek != f1 {
+		t.Error("Peeked at prior frame, but did not get the first frame pushed")
 	}
 }
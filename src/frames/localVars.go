@@ -0,0 +1,112 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package frames
+
+import "fmt"
+
+// LocalVars is the array of local variables of a method, whose size is the method's
+// max_locals. Each variable is tagged with its type, as on an OperandStack, so that an
+// int isn't read as a reference or the other way around. Accessing a variable outside
+// the array, or one of the wrong type, with the getters and setters for a type returns
+// an error rather than panicking. As on an OperandStack, a long or double takes up two
+// variables, both of which hold it.
+type LocalVars struct {
+	vars []StackValue
+}
+
+// NewLocalVars creates the local variables of a method whose max_locals is maxLocals.
+// All of them start out as null references.
+func NewLocalVars(maxLocals int) *LocalVars {
+	if maxLocals < 0 {
+		maxLocals = 0
+	}
+	vars := make([]StackValue, maxLocals)
+	for i := range vars {
+		vars[i].Tag = RefValue
+	}
+	return &LocalVars{vars: vars}
+}
+
+// Size returns the number of local variables, which is the method's max_locals. A frame
+// without local variables, such as that of a Go method, has a nil LocalVars of size 0.
+func (l *LocalVars) Size() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.vars)
+}
+
+// Load returns the tagged value of the local variable at index, as a load instruction
+// pushes it. Unlike the getters for a type, it doesn't check the index, which the
+// interpreter verifies before it executes an instruction that uses local variables.
+func (l *LocalVars) Load(index int) StackValue {
+	return l.vars[index]
+}
+
+// Store sets the local variable at index to a tagged value, as a store instruction pops
+// it. Like Load(), it doesn't check the index.
+func (l *LocalVars) Store(index int, value StackValue) {
+	l.vars[index] = value
+}
+
+// Slots returns the local variables, by index. The slice is the LocalVars' own, so it
+// must not be changed.
+func (l *LocalVars) Slots() []StackValue {
+	if l == nil {
+		return nil
+	}
+	return l.vars
+}
+
+// SetInt sets the local variable at index to an int (or a boolean, byte, char, or short)
+func (l *LocalVars) SetInt(index int, value int64) error {
+	if err := l.checkIndex(index); err != nil {
+		return err
+	}
+	l.vars[index] = StackValue{Tag: IntValue, IVal: value}
+	return nil
+}
+
+// GetInt returns the int in the local variable at index
+func (l *LocalVars) GetInt(index int) (int64, error) {
+	if err := l.checkIndex(index); err != nil {
+		return 0, err
+	}
+	if l.vars[index].Tag != IntValue {
+		return 0, fmt.Errorf("local variable %d does not hold an int", index)
+	}
+	return l.vars[index].IVal, nil
+}
+
+// SetRef sets the local variable at index to a reference
+func (l *LocalVars) SetRef(index int, ref interface{}) error {
+	if err := l.checkIndex(index); err != nil {
+		return err
+	}
+	l.vars[index] = StackValue{Tag: RefValue, AVal: ref}
+	return nil
+}
+
+// GetRef returns the reference in the local variable at index
+func (l *LocalVars) GetRef(index int) (interface{}, error) {
+	if err := l.checkIndex(index); err != nil {
+		return nil, err
+	}
+	if l.vars[index].Tag != RefValue {
+		return nil, fmt.Errorf("local variable %d does not hold a reference", index)
+	}
+	return l.vars[index].AVal, nil
+}
+
+// returns an error if index is outside the local variables
+func (l *LocalVars) checkIndex(index int) error {
+	if index < 0 || index >= len(l.vars) {
+		return fmt.Errorf("local variable index %d is out of bounds: the method has %d local variables",
+			index, len(l.vars))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package frames
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocalVarsRoundTrip(t *testing.T) {
+	l := NewLocalVars(2)
+	if l.Size() != 2 {
+		t.Fatalf("Expected 2 local variables, got: %d", l.Size())
+	}
+	if ref, err := l.GetRef(1); err != nil || ref != nil {
+		t.Errorf("Expected a new local variable to be null, got: %v, %v", ref, err)
+	}
+
+	if err := l.SetInt(0, -42); err != nil {
+		t.Fatalf("Unexpected error setting an int: %s", err.Error())
+	}
+	obj := &Frame{}
+	if err := l.SetRef(1, obj); err != nil {
+		t.Fatalf("Unexpected error setting a reference: %s", err.Error())
+	}
+	if i, err := l.GetInt(0); err != nil || i != -42 {
+		t.Errorf("Expected local variable 0 to be -42, got: %d, %v", i, err)
+	}
+	if ref, err := l.GetRef(1); err != nil || ref != obj {
+		t.Errorf("Expected local variable 1 to be the reference set, got: %v, %v", ref, err)
+	}
+
+	// a variable holds only the type last set
+	if _, err := l.GetRef(0); err == nil {
+		t.Error("Expected an error getting an int as a reference, but got none")
+	}
+	if _, err := l.GetInt(1); err == nil {
+		t.Error("Expected an error getting a reference as an int, but got none")
+	}
+}
+
+func TestLocalVarsOutOfBounds(t *testing.T) {
+	l := NewLocalVars(1)
+	for _, index := range []int{-1, 1, 100} {
+		err := l.SetInt(index, 1)
+		if err == nil || !strings.Contains(err.Error(), "out of bounds") {
+			t.Errorf("Expected an out-of-bounds error setting local variable %d, got: %v", index, err)
+		}
+		if _, err = l.GetInt(index); err == nil {
+			t.Errorf("Expected an error getting local variable %d, but got none", index)
+		}
+		if err = l.SetRef(index, nil); err == nil {
+			t.Errorf("Expected an error setting local variable %d, but got none", index)
+		}
+		if _, err = l.GetRef(index); err == nil {
+			t.Errorf("Expected an error getting local variable %d, but got none", index)
+		}
+	}
+
+	if _, err := NewLocalVars(0).GetInt(0); err == nil {
+		t.Error("Expected an error getting a local variable of a method with none, but got none")
+	}
+}
+
+// the interpreter loads and stores tagged values, which keep the tag they're stored with
+func TestLocalVarsLoadAndStore(t *testing.T) {
+	l := NewLocalVars(3)
+	l.Store(0, StackValueOf(int64(7)))
+	long := StackValue{Tag: LongValue, IVal: 1 << 40}
+	l.Store(1, long)
+	l.Store(2, long)
+
+	if v := l.Load(0); v.Tag != IntValue || v.Value() != int64(7) {
+		t.Errorf("Expected local variable 0 to hold the int 7, got: %v", v)
+	}
+	for _, index := range []int{1, 2} {
+		if v := l.Load(index); v != long {
+			t.Errorf("Expected local variable %d to hold the long, got: %v", index, v)
+		}
+	}
+	if i, err := l.GetInt(0); err != nil || i != 7 {
+		t.Errorf("Expected the stored int to be read as an int, got: %d, %v", i, err)
+	}
+	if len(l.Slots()) != 3 || l.Slots()[2] != long {
+		t.Errorf("Expected Slots() to return the 3 local variables, got: %v", l.Slots())
+	}
+}
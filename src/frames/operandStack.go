@@ -0,0 +1,169 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package frames
+
+import "errors"
+
+// The tags of the values on an OperandStack, which are the JVM's computational types.
+// See: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-2.html#jvms-2.11.1
+const (
+	IntValue    = iota // an int, or a boolean, byte, char, or short, which are held as ints
+	FloatValue         // a float
+	LongValue          // a long, which is a category-2 value
+	DoubleValue        // a double, which is a category-2 value
+	RefValue           // a reference or a returnAddress
+)
+
+// StackValue is a value on an OperandStack, tagged with its type. Only the field for
+// its type is set: IVal for ints and longs, FVal for floats, DVal for doubles, and AVal
+// for references.
+type StackValue struct {
+	Tag  int
+	IVal int64
+	FVal float64
+	DVal float64
+	AVal interface{}
+}
+
+// ErrOperandStackOverflow is returned by the pushes when the value doesn't fit on the
+// operand stack. Like a frame stack that's too deep, it's a StackOverflowError.
+var ErrOperandStackOverflow = errors.New("java.lang.StackOverflowError: operand stack overflow")
+
+// StackValueOf returns the StackValue for a value as the interpreter holds it: the int
+// types as int64s and floats as float64s. Since longs and doubles are held the same way,
+// they're tagged by pushing them with PushLong() and PushDouble(), and references,
+// which are held as int64 addresses, by pushing them with PushRef(). A StackValue is
+// returned unchanged, and any other value is a reference.
+func StackValueOf(value interface{}) StackValue {
+	switch v := value.(type) {
+	case StackValue:
+		return v
+	case int64:
+		return StackValue{Tag: IntValue, IVal: v}
+	case float64:
+		return StackValue{Tag: FloatValue, FVal: v}
+	}
+	return StackValue{Tag: RefValue, AVal: value}
+}
+
+// Value returns the value of a StackValue as the interpreter holds it: an int64 for
+// ints and longs, a float64 for floats and doubles, and the reference itself for a
+// reference.
+func (v StackValue) Value() interface{} {
+	switch v.Tag {
+	case IntValue, LongValue:
+		return v.IVal
+	case FloatValue:
+		return v.FVal
+	case DoubleValue:
+		return v.DVal
+	}
+	return v.AVal
+}
+
+// OperandStack is the operand stack of a method, whose size is the method's max_stack.
+// Each slot holds a tagged value. A long or double takes up two slots, both of which
+// hold it, so the instructions that pop one can pop either slot first.
+type OperandStack struct {
+	slots    []StackValue
+	maxSlots int
+}
+
+// NewOperandStack creates an empty operand stack for a method whose max_stack is maxStack
+func NewOperandStack(maxStack int) *OperandStack {
+	if maxStack < 0 {
+		maxStack = 0
+	}
+	return &OperandStack{slots: make([]StackValue, 0, maxStack), maxSlots: maxStack}
+}
+
+// Push pushes a value onto one slot of the stack, tagged with its type (see
+// StackValueOf()). If the stack is full, it's unchanged and ErrOperandStackOverflow
+// is returned.
+func (s *OperandStack) Push(value interface{}) error {
+	return s.push(StackValueOf(value), 1)
+}
+
+// PushLong pushes a long, which takes up two slots
+func (s *OperandStack) PushLong(value int64) error {
+	return s.push(StackValue{Tag: LongValue, IVal: value}, 2)
+}
+
+// PushDouble pushes a double, which takes up two slots
+func (s *OperandStack) PushDouble(value float64) error {
+	return s.push(StackValue{Tag: DoubleValue, DVal: value}, 2)
+}
+
+// PushRef pushes a reference or a returnAddress
+func (s *OperandStack) PushRef(ref interface{}) error {
+	return s.push(StackValue{Tag: RefValue, AVal: ref}, 1)
+}
+
+// pushes v into count slots, or none of them if they don't all fit
+func (s *OperandStack) push(v StackValue, count int) error {
+	if len(s.slots)+count > s.maxSlots {
+		return ErrOperandStackOverflow
+	}
+	for i := 0; i < count; i++ {
+		s.slots = append(s.slots, v)
+	}
+	return nil
+}
+
+// Pop removes the slot at the top of the stack and returns its value as the interpreter
+// holds it (see StackValue.Value()), or nil if the stack is empty.
+func (s *OperandStack) Pop() interface{} {
+	if len(s.slots) == 0 {
+		return nil
+	}
+	return s.PopValue().Value()
+}
+
+// PopValue removes the slot at the top of the stack and returns its tagged value, or
+// a zero StackValue if the stack is empty.
+func (s *OperandStack) PopValue() StackValue {
+	if len(s.slots) == 0 {
+		return StackValue{}
+	}
+	v := s.slots[len(s.slots)-1]
+	s.slots = s.slots[:len(s.slots)-1]
+	return v
+}
+
+// Peek returns the value of the slot at the top of the stack without removing it, or
+// nil if the stack is empty.
+func (s *OperandStack) Peek() interface{} {
+	if len(s.slots) == 0 {
+		return nil
+	}
+	return s.slots[len(s.slots)-1].Value()
+}
+
+// PeekValue returns the tagged value of the slot at the top of the stack without
+// removing it, or a zero StackValue if the stack is empty.
+func (s *OperandStack) PeekValue() StackValue {
+	if len(s.slots) == 0 {
+		return StackValue{}
+	}
+	return s.slots[len(s.slots)-1]
+}
+
+// Depth returns the number of slots in use, in which a long or double counts twice
+func (s *OperandStack) Depth() int {
+	return len(s.slots)
+}
+
+// Clear empties the stack
+func (s *OperandStack) Clear() {
+	s.slots = s.slots[:0]
+}
+
+// Slots returns the slots in use, from the bottom of the stack to the top. The slice
+// is the stack's own, so it must not be changed.
+func (s *OperandStack) Slots() []StackValue {
+	return s.slots
+}
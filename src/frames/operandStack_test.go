@@ -0,0 +1,119 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package frames
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOperandStackPushAndPop(t *testing.T) {
+	s := NewOperandStack(3)
+	if s.Depth() != 0 || s.Pop() != nil || s.Peek() != nil {
+		t.Fatal("Expected a new operand stack to be empty")
+	}
+
+	_ = s.Push(int64(1))
+	_ = s.Push(int64(2))
+	if s.Depth() != 2 || s.Peek() != int64(2) {
+		t.Errorf("Expected 2 values with 2 on top, got %d values with %v on top", s.Depth(), s.Peek())
+	}
+	if s.Pop() != int64(2) || s.Pop() != int64(1) {
+		t.Error("Expected values to be popped in the reverse of the order they were pushed")
+	}
+	if s.Depth() != 0 {
+		t.Errorf("Expected the stack to be empty, but it holds %d values", s.Depth())
+	}
+
+	_ = s.Push(int64(3))
+	s.Clear()
+	if s.Depth() != 0 {
+		t.Errorf("Expected Clear() to empty the stack, but it holds %d values", s.Depth())
+	}
+}
+
+// a long or double fills two slots, either of which can be popped to get it
+func TestOperandStackCategory2Values(t *testing.T) {
+	s := NewOperandStack(4)
+	_ = s.PushLong(1 << 40)
+	_ = s.PushDouble(0.25)
+	if s.Depth() != 4 {
+		t.Fatalf("Expected a long and a double to take up 4 slots, got: %d", s.Depth())
+	}
+	for i := 0; i < 2; i++ {
+		if v := s.PopValue(); v.Tag != DoubleValue || v.Value() != 0.25 {
+			t.Errorf("Expected slot %d from the top to hold the double, got: %v", i, v)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if v := s.PopValue(); v.Tag != LongValue || v.Value() != int64(1<<40) {
+			t.Errorf("Expected slot %d below the double to hold the long, got: %v", i, v)
+		}
+	}
+}
+
+// max_stack counts longs and doubles as two slots, so they overflow the stack sooner
+func TestOperandStackOverflow(t *testing.T) {
+	s := NewOperandStack(3)
+	if err := s.PushLong(1); err != nil {
+		t.Fatalf("Unexpected error pushing a long: %s", err.Error())
+	}
+	if err := s.PushDouble(1.5); !errors.Is(err, ErrOperandStackOverflow) {
+		t.Errorf("Expected a double to overflow the stack, got: %v", err)
+	}
+	if s.Depth() != 2 {
+		t.Errorf("Expected a value that overflows the stack not to be pushed, got %d slots", s.Depth())
+	}
+	if err := s.Push(int64(2)); err != nil {
+		t.Errorf("Unexpected error pushing an int into the last slot: %s", err.Error())
+	}
+	if err := s.PushRef(nil); !errors.Is(err, ErrOperandStackOverflow) {
+		t.Errorf("Expected a reference to overflow a full stack, got: %v", err)
+	}
+
+	// once values are popped, there's room for more
+	s.Pop()
+	s.Pop()
+	if err := s.PushDouble(2.5); err != nil {
+		t.Errorf("Unexpected error pushing a double after popping: %s", err.Error())
+	}
+}
+
+// values are tagged by the way the interpreter holds them: ints as int64s, floats as
+// float64s, and references as anything else, unless they're pushed as references
+func TestOperandStackTagsRoundTrip(t *testing.T) {
+	ref := &struct{ name string }{"an object"}
+	tests := []struct {
+		push   func(s *OperandStack) error
+		tag    int
+		popped interface{}
+	}{
+		{func(s *OperandStack) error { return s.Push(int64(-7)) }, IntValue, int64(-7)},
+		{func(s *OperandStack) error { return s.Push(0.5) }, FloatValue, 0.5},
+		{func(s *OperandStack) error { return s.Push(ref) }, RefValue, ref},
+		{func(s *OperandStack) error { return s.PushRef(int64(0x100)) }, RefValue, int64(0x100)},
+		{func(s *OperandStack) error { return s.PushLong(-1 << 40) }, LongValue, int64(-1 << 40)},
+		{func(s *OperandStack) error { return s.PushDouble(0.25) }, DoubleValue, 0.25},
+		{func(s *OperandStack) error { return s.Push(StackValue{Tag: RefValue, AVal: int64(3)}) },
+			RefValue, int64(3)},
+	}
+
+	for _, test := range tests {
+		s := NewOperandStack(2)
+		if err := test.push(s); err != nil {
+			t.Errorf("Unexpected error pushing %v: %s", test.popped, err.Error())
+			continue
+		}
+		if tag := s.PeekValue().Tag; tag != test.tag {
+			t.Errorf("Expected %v (%T) to have tag %d, got: %d", test.popped, test.popped, test.tag, tag)
+		}
+		if popped := s.Pop(); popped != test.popped {
+			t.Errorf("Expected %v (%T) to pop as itself, got: %v (%T)",
+				test.popped, test.popped, popped, popped)
+		}
+	}
+}
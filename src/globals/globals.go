@@ -8,6 +8,7 @@ package globals
 
 import (
 	"container/list"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -36,6 +37,10 @@ type Globals struct {
 	StartingJar   string
 	AppArgs       []string
 	Options       map[string]Option
+	ModulePath    string   // the --module-path directories, separated by os.PathListSeparator
+	ClassPath     []string // the -cp (or -classpath) directories and JARs, in search order
+
+	SystemProperties map[string]string // the -Dkey=value system properties
 
 	// ---- classloading items ----
 	MaxJavaVersion    int // the Java version as commonly known, i.e. Java 11
@@ -52,6 +57,31 @@ type Globals struct {
 	// ---- execution context ----
 	JacobinBuildData map[string]string
 
+	// ---- standard streams ----
+	// Stdout is where System.out writes. If nil, output goes to whatever os.Stdout
+	// is at the time of the write. Tests can set this to capture program output.
+	Stdout io.Writer
+
+	// ---- garbage collection logging (-XX:+PrintGC, -XX:+PrintGCDetails) ----
+	PrintGC        bool // log one line per collection
+	PrintGCDetails bool // also log the heap statistics for each collection
+
+	// after each collection, make equal Strings share one backing string (-XX:+UseStringDeduplication)
+	UseStringDeduplication bool
+
+	// load all the classes the main class references before execution starts, rather than
+	// each one when it's first needed (-XX:+EagerClassLoading)
+	EagerClassLoading bool
+
+	// load the base classes from the class cache, if it's valid (-XX:+UseClassCache, the default)
+	UseClassCache bool
+
+	// stream each executed instruction to the /trace/instructions endpoint (-XX:+TraceInstructions)
+	TraceInstructions bool
+
+	// ---- agents ----
+	AttachAddress string // where the attach endpoint listens (-agentlib:jacobin-attach=<address>)
+
 	// ---- special switches ----
 	StrictJDK bool // hew closely to actions and error messages of the JDK
 }
@@ -71,6 +101,7 @@ func InitGlobals(progName string) Globals {
 		JacobinHome:       "",
 		JavaHome:          "",
 		Options:           make(map[string]Option),
+		SystemProperties:  make(map[string]string),
 		StartingClass:     "",
 		StartingJar:       "",
 		MaxJavaVersion:    17, // this value and MaxJavaVersionRaw must *always* be in sync
@@ -78,6 +109,7 @@ func InitGlobals(progName string) Globals {
 		Threads:           ThreadList{list.New(), sync.Mutex{}},
 		JacobinBuildData:  nil,
 		StrictJDK:         false,
+		UseClassCache:     true,
 	}
 
 	InitJavaHome()
@@ -158,7 +158,8 @@ func TestHexHello2InvalidJavaVersion(t *testing.T) {
 	_ = log.SetLogLevel(log.WARNING)
 	err := classloader.Init()
 
-	var testBytes = Hello2Bytes[0:8]
+	var testBytes = make([]byte, 8) // copy, so as not to change Hello2Bytes for other tests
+	copy(testBytes, Hello2Bytes[0:8])
 	testBytes[7] = 99 // change class to unsupported version of Java class files
 
 	_, err = classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", testBytes)
@@ -0,0 +1,199 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/shutdown"
+	"math"
+)
+
+// The arithmetic instructions of the interpreter. Java ints are held on the operand
+// stack as int64s, so the int instructions do their arithmetic on int32s and push the
+// result back as an int64. That way, results overflow and wrap around just as they do
+// in Java, so that Integer.MAX_VALUE + 1 is Integer.MIN_VALUE. Likewise, floats are held
+// as float64s, and the float instructions round their operands and result to float32s.
+//
+// Float and double arithmetic follows IEEE 754, as Go's does, so none of those instructions
+// throws an exception: dividing a finite non-zero number by zero yields an infinity, and
+// 0.0 / 0.0 and any operation on a NaN yield NaN.
+
+// reports the ArithmeticException for an integer division or remainder by zero
+func divideByZero() error {
+	exceptions.Throw(exceptions.ArithmeticException, "Arithmetic Exception: divide by zero")
+	shutdown.Exit(shutdown.APP_EXCEPTION)
+	return errors.New("divide by zero")
+}
+
+// execIntArith executes one of the int arithmetic, shift, and bitwise instructions
+// (iadd through ixor), popping its operands off the operand stack and pushing its
+// result. Division by zero (in idiv and irem) throws an ArithmeticException.
+func execIntArith(opcode byte, stack *frames.OperandStack) error {
+	if opcode == INEG { // the only one of these with a single operand
+		return stack.Push(int64(-int32(stack.Pop().(int64))))
+	}
+
+	value2 := int32(stack.Pop().(int64))
+	value1 := int32(stack.Pop().(int64))
+	shift := uint32(value2) & 0x1F // only the bottom five bits of a shift count are used
+
+	var result int32
+	switch opcode {
+	case IADD:
+		result = value1 + value2
+	case ISUB:
+		result = value1 - value2
+	case IMUL:
+		result = value1 * value2
+	case IDIV: // Integer.MIN_VALUE / -1 overflows to Integer.MIN_VALUE, as in Java
+		if value2 == 0 {
+			return divideByZero()
+		}
+		result = value1 / value2
+	case IREM:
+		if value2 == 0 {
+			return divideByZero()
+		}
+		result = value1 % value2
+	case ISHL:
+		result = value1 << shift
+	case ISHR:
+		result = value1 >> shift
+	case IUSHR: // zeros are shifted in, rather than copies of the sign bit
+		result = int32(uint32(value1) >> shift)
+	case IAND:
+		result = value1 & value2
+	case IOR:
+		result = value1 | value2
+	case IXOR:
+		result = value1 ^ value2
+	default:
+		return fmt.Errorf("execIntArith: %s is not an int arithmetic instruction", BytecodeNames[opcode])
+	}
+	return stack.Push(int64(result))
+}
+
+// execLongArith executes one of the long arithmetic, shift, and bitwise instructions
+// (ladd through lxor), popping its operands off the operand stack and pushing its
+// result. A long takes up two slots on the operand stack, but the shift count of lshl,
+// lshr, and lushr is an int, which takes up one. Division by zero (in ldiv and lrem)
+// throws an ArithmeticException.
+func execLongArith(opcode byte, stack *frames.OperandStack) error {
+	var value2 int64
+	switch opcode {
+	case LNEG: // the only one of these with a single operand
+	case LSHL, LSHR, LUSHR:
+		value2 = stack.Pop().(int64)
+	default:
+		value2 = stack.Pop().(int64)
+		stack.Pop()
+	}
+	value1 := stack.Pop().(int64)
+	stack.Pop()
+	shift := uint64(value2) & 0x3F // only the bottom six bits of a shift count are used
+
+	var result int64
+	switch opcode {
+	case LADD:
+		result = value1 + value2
+	case LSUB:
+		result = value1 - value2
+	case LMUL:
+		result = value1 * value2
+	case LDIV: // Long.MIN_VALUE / -1 overflows to Long.MIN_VALUE, as in Java
+		if value2 == 0 {
+			return divideByZero()
+		}
+		result = value1 / value2
+	case LREM:
+		if value2 == 0 {
+			return divideByZero()
+		}
+		result = value1 % value2
+	case LNEG: // -Long.MIN_VALUE is Long.MIN_VALUE
+		result = -value1
+	case LSHL:
+		result = value1 << shift
+	case LSHR:
+		result = value1 >> shift
+	case LUSHR: // zeros are shifted in, rather than copies of the sign bit
+		result = int64(uint64(value1) >> shift)
+	case LAND:
+		result = value1 & value2
+	case LOR:
+		result = value1 | value2
+	case LXOR:
+		result = value1 ^ value2
+	default:
+		return fmt.Errorf("execLongArith: %s is not a long arithmetic instruction", BytecodeNames[opcode])
+	}
+	return stack.PushLong(result)
+}
+
+// execFloatArith executes one of the float arithmetic instructions (fadd, fsub, fmul,
+// fdiv, frem, and fneg), popping its operands off the operand stack and pushing its
+// result.
+func execFloatArith(opcode byte, stack *frames.OperandStack) error {
+	if opcode == FNEG { // the only one of these with a single operand
+		return stack.Push(float64(-float32(stack.Pop().(float64))))
+	}
+
+	value2 := float32(stack.Pop().(float64))
+	value1 := float32(stack.Pop().(float64))
+
+	var result float32
+	switch opcode {
+	case FADD:
+		result = value1 + value2
+	case FSUB:
+		result = value1 - value2
+	case FMUL:
+		result = value1 * value2
+	case FDIV:
+		result = value1 / value2
+	case FREM: // as with C's fmod, the result has the sign of value1, unlike IEEE's remainder
+		result = float32(math.Mod(float64(value1), float64(value2)))
+	default:
+		return fmt.Errorf("execFloatArith: %s is not a float arithmetic instruction", BytecodeNames[opcode])
+	}
+	return stack.Push(float64(result))
+}
+
+// execDoubleArith executes one of the double arithmetic instructions (dadd, dsub, dmul,
+// ddiv, drem, and dneg), popping its operands off the operand stack and pushing its
+// result. A double takes up two slots on the operand stack.
+func execDoubleArith(opcode byte, stack *frames.OperandStack) error {
+	var value2 float64
+	if opcode != DNEG { // the only one of these with a single operand
+		value2 = stack.Pop().(float64)
+		stack.Pop()
+	}
+	value1 := stack.Pop().(float64)
+	stack.Pop()
+
+	var result float64
+	switch opcode {
+	case DADD:
+		result = value1 + value2
+	case DSUB:
+		result = value1 - value2
+	case DMUL:
+		result = value1 * value2
+	case DDIV:
+		result = value1 / value2
+	case DREM: // as with C's fmod, the result has the sign of value1, unlike IEEE's remainder
+		result = math.Mod(value1, value2)
+	case DNEG:
+		result = -value1
+	default:
+		return fmt.Errorf("execDoubleArith: %s is not a double arithmetic instruction", BytecodeNames[opcode])
+	}
+	return stack.PushDouble(result)
+}
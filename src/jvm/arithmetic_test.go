@@ -0,0 +1,349 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"math"
+	"testing"
+)
+
+func TestExecIntArith(t *testing.T) {
+	tests := []struct {
+		opcode         byte
+		value1, value2 int64
+		expected       int64
+	}{
+		{IADD, 22, 20, 42},
+		{IADD, math.MaxInt32, 1, math.MinInt32}, // overflow wraps around, as in Java
+		{ISUB, 22, 20, 2},
+		{ISUB, math.MinInt32, 1, math.MaxInt32},
+		{IMUL, -6, 7, -42},
+		{IMUL, 0x10000, 0x10000, 0}, // 2^32 is all zeros in the low 32 bits
+		{IDIV, 22, 5, 4},
+		{IDIV, -22, 5, -4}, // rounds toward zero
+		{IDIV, math.MinInt32, -1, math.MinInt32},
+		{IREM, 22, 5, 2},
+		{IREM, -22, 5, -2}, // takes the sign of the dividend
+		{IREM, math.MinInt32, -1, 0},
+		{ISHL, 1, 31, math.MinInt32},
+		{ISHL, 1, 33, 2}, // only the bottom five bits of the shift count are used
+		{ISHR, -200, 3, -25},
+		{IUSHR, -200, 3, 536870887},
+		{IUSHR, -1, 0, -1},
+		{IAND, 0x0F0F, 0x00FF, 0x000F},
+		{IOR, 0x0F00, 0x00F0, 0x0FF0},
+		{IXOR, -1, 0x0F0F, -0x0F10},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		push(&f, test.value1)
+		push(&f, test.value2)
+		if err := execIntArith(test.opcode, f.Operands); err != nil {
+			t.Errorf("%s %d, %d: unexpected error: %s", BytecodeNames[test.opcode], test.value1, test.value2,
+				err.Error())
+			continue
+		}
+		if result := pop(&f).(int64); result != test.expected {
+			t.Errorf("%s %d, %d: expected %d, got: %d", BytecodeNames[test.opcode], test.value1, test.value2,
+				test.expected, result)
+		}
+		if f.Operands.Depth() != 0 {
+			t.Errorf("%s: expected an empty stack, got a TOS of: %d", BytecodeNames[test.opcode], f.Operands.Depth()-1)
+		}
+	}
+}
+
+func TestExecIntArithIneg(t *testing.T) {
+	for value, expected := range map[int64]int64{5: -5, -5: 5, 0: 0, math.MinInt32: math.MinInt32} {
+		f := newFrame(INEG)
+		push(&f, value)
+		if err := execIntArith(INEG, f.Operands); err != nil {
+			t.Fatalf("INEG: unexpected error: %s", err.Error())
+		}
+		if result := pop(&f).(int64); result != expected {
+			t.Errorf("INEG %d: expected %d, got: %d", value, expected, result)
+		}
+	}
+}
+
+// IDIV, IREM: division by zero throws ArithmeticException
+func TestExecIntArithDivideByZero(t *testing.T) {
+	globals.InitGlobals("test")
+	globals.GetGlobalRef().JacobinName = "test" // prevents a shutdown when the exception hits
+	log.Init()
+
+	for _, opcode := range []byte{IDIV, IREM} {
+		f := newFrame(opcode)
+		push(&f, int64(220))
+		push(&f, int64(0))
+
+		var err error
+		msg := captureStderr(func() {
+			err = execIntArith(opcode, f.Operands)
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error for division by zero", BytecodeNames[opcode])
+		}
+		if msg != "Arithmetic Exception: divide by zero\n" {
+			t.Errorf("%s: expected an ArithmeticException, got: %s", BytecodeNames[opcode], msg)
+		}
+	}
+}
+
+func TestExecLongArith(t *testing.T) {
+	tests := []struct {
+		opcode         byte
+		value1, value2 int64
+		expected       int64
+	}{
+		{LADD, 0x100000000, 2, 0x100000002}, // beyond the range of an int
+		{LADD, math.MaxInt64, 1, math.MinInt64},
+		{LSUB, math.MinInt64, 1, math.MaxInt64},
+		{LMUL, 0x100000000, 0x100000000, 0},
+		{LDIV, -22, 5, -4},
+		{LDIV, math.MinInt64, -1, math.MinInt64},
+		{LREM, -22, 5, -2},
+		{LREM, math.MinInt64, -1, 0},
+		{LAND, 0x0F0F0F0F0F, 0x00FF00FF00, 0x000F000F00},
+		{LOR, 0x0F00000000, 0x00F0, 0x0F000000F0},
+		{LXOR, -1, 0x0F0F, -0x0F10},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		pushLong(&f, test.value1)
+		pushLong(&f, test.value2)
+		if err := execLongArith(test.opcode, f.Operands); err != nil {
+			t.Errorf("%s %d, %d: unexpected error: %s", BytecodeNames[test.opcode], test.value1, test.value2,
+				err.Error())
+			continue
+		}
+		if f.Operands.Depth() != 2 {
+			t.Errorf("%s: expected the result in two slots, got a TOS of: %d", BytecodeNames[test.opcode], f.Operands.Depth()-1)
+			continue
+		}
+		if result := pop(&f).(int64); result != test.expected || pop(&f).(int64) != test.expected {
+			t.Errorf("%s %d, %d: expected %d, got: %d", BytecodeNames[test.opcode], test.value1, test.value2,
+				test.expected, result)
+		}
+	}
+}
+
+// LSHL, LSHR, LUSHR: the shift count is an int, so it takes up only one slot
+func TestExecLongArithShifts(t *testing.T) {
+	tests := []struct {
+		opcode   byte
+		value    int64
+		shift    int64
+		expected int64
+	}{
+		{LSHL, 1, 63, math.MinInt64},
+		{LSHL, 1, 64, 1}, // only the bottom six bits of the shift count are used
+		{LSHL, 1, 65, 2},
+		{LSHR, -200, 3, -25},
+		{LSHR, math.MinInt64, 63, -1},
+		{LUSHR, -200, 3, 0x1FFFFFFFFFFFFFE7},
+		{LUSHR, math.MinInt64, 63, 1},
+		{LUSHR, -1, 64, -1},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		pushLong(&f, test.value)
+		push(&f, test.shift)
+		if err := execLongArith(test.opcode, f.Operands); err != nil {
+			t.Fatalf("%s: unexpected error: %s", BytecodeNames[test.opcode], err.Error())
+		}
+		if f.Operands.Depth() != 2 {
+			t.Errorf("%s: expected the result in two slots, got a TOS of: %d", BytecodeNames[test.opcode], f.Operands.Depth()-1)
+			continue
+		}
+		if result := pop(&f).(int64); result != test.expected {
+			t.Errorf("%s %d, %d: expected %d, got: %d", BytecodeNames[test.opcode], test.value, test.shift,
+				test.expected, result)
+		}
+	}
+}
+
+func TestExecLongArithLneg(t *testing.T) {
+	for value, expected := range map[int64]int64{5: -5, 0x100000000: -0x100000000, math.MinInt64: math.MinInt64} {
+		f := newFrame(LNEG)
+		pushLong(&f, value)
+		if err := execLongArith(LNEG, f.Operands); err != nil {
+			t.Fatalf("LNEG: unexpected error: %s", err.Error())
+		}
+		if result := pop(&f).(int64); result != expected || f.Operands.Depth() != 1 {
+			t.Errorf("LNEG %d: expected %d in two slots, got: %d (TOS: %d)", value, expected, result, f.Operands.Depth()-1)
+		}
+	}
+}
+
+// LDIV, LREM: division by zero throws ArithmeticException
+func TestExecLongArithDivideByZero(t *testing.T) {
+	globals.InitGlobals("test")
+	globals.GetGlobalRef().JacobinName = "test" // prevents a shutdown when the exception hits
+	log.Init()
+
+	for _, opcode := range []byte{LDIV, LREM} {
+		f := newFrame(opcode)
+		pushLong(&f, 220)
+		pushLong(&f, 0)
+
+		var err error
+		msg := captureStderr(func() {
+			err = execLongArith(opcode, f.Operands)
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error for division by zero", BytecodeNames[opcode])
+		}
+		if msg != "Arithmetic Exception: divide by zero\n" {
+			t.Errorf("%s: expected an ArithmeticException, got: %s", BytecodeNames[opcode], msg)
+		}
+	}
+}
+
+// float and double arithmetic follows IEEE 754: no exceptions, but infinities and NaNs
+func TestExecFloatArith(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	tests := []struct {
+		opcode         byte
+		value1, value2 float64
+		expected       float64
+	}{
+		{FADD, 1.5, 2.25, 3.75},
+		{FADD, inf, 1, inf},
+		{FADD, inf, -inf, nan},
+		{FADD, math.MaxFloat32, math.MaxFloat32, inf}, // overflows a float, though not a double
+		{FSUB, 1.5, 2.25, -0.75},
+		{FSUB, nan, 1, nan},
+		{FMUL, -1.5, 4, -6},
+		{FMUL, 0, inf, nan},
+		{FMUL, math.SmallestNonzeroFloat32, 0.5, 0}, // underflows a denormal to zero
+		{FDIV, 3, 2, 1.5},
+		{FDIV, 1, 0, inf},
+		{FDIV, -1, 0, -inf},
+		{FDIV, 1, math.Copysign(0, -1), -inf},
+		{FDIV, 0, 0, nan},
+		{FDIV, math.SmallestNonzeroFloat32, 2, 0},
+		{FREM, 23.5, 3, 2.5},
+		{FREM, -23.5, 3, -2.5}, // takes the sign of the dividend
+		{FREM, 1, 0, nan},
+		{FREM, 1.5, inf, 1.5},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		push(&f, test.value1)
+		push(&f, test.value2)
+		if err := execFloatArith(test.opcode, f.Operands); err != nil {
+			t.Errorf("%s %g, %g: unexpected error: %s", BytecodeNames[test.opcode], test.value1, test.value2,
+				err.Error())
+			continue
+		}
+		result := pop(&f).(float64)
+		if !sameFloat(result, test.expected) {
+			t.Errorf("%s %g, %g: expected %g, got: %g", BytecodeNames[test.opcode], test.value1, test.value2,
+				test.expected, result)
+		}
+		if f.Operands.Depth() != 0 {
+			t.Errorf("%s: expected an empty stack, got a TOS of: %d", BytecodeNames[test.opcode], f.Operands.Depth()-1)
+		}
+	}
+}
+
+func TestExecFloatArithFneg(t *testing.T) {
+	for _, value := range []float64{1.5, 0, math.Inf(-1), math.NaN()} {
+		f := newFrame(FNEG)
+		push(&f, value)
+		_ = execFloatArith(FNEG, f.Operands)
+		result := pop(&f).(float64)
+		if math.IsNaN(value) {
+			if !math.IsNaN(result) {
+				t.Errorf("FNEG NaN: expected NaN, got: %g", result)
+			}
+		} else if result != -value || math.Signbit(result) == math.Signbit(value) {
+			t.Errorf("FNEG %g: expected %g, got: %g", value, -value, result)
+		}
+	}
+}
+
+func TestExecDoubleArith(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	tests := []struct {
+		opcode         byte
+		value1, value2 float64
+		expected       float64
+	}{
+		{DADD, 0.1, 0.2, 0.30000000000000004}, // rounded as a double, as in Java
+		{DADD, math.MaxFloat32, math.MaxFloat32, 2 * math.MaxFloat32},
+		{DADD, inf, -inf, nan},
+		{DSUB, 1.5, 2.25, -0.75},
+		{DSUB, -inf, nan, nan},
+		{DMUL, -1.5, 4, -6},
+		{DMUL, math.MaxFloat64, 2, inf},
+		{DMUL, math.SmallestNonzeroFloat64, 0.5, 0}, // underflows a denormal to zero
+		{DDIV, 3, 2, 1.5},
+		{DDIV, 1, 0, inf},
+		{DDIV, -1, 0, -inf},
+		{DDIV, -1, math.Copysign(0, -1), inf},
+		{DDIV, 0, 0, nan},
+		{DDIV, math.SmallestNonzeroFloat64, 1, math.SmallestNonzeroFloat64},
+		{DREM, 23.5, 3, 2.5},
+		{DREM, -23.5, 3, -2.5}, // takes the sign of the dividend
+		{DREM, inf, 2, nan},
+		{DREM, 1.5, -inf, 1.5},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		push(&f, test.value1)
+		push(&f, test.value1)
+		push(&f, test.value2)
+		push(&f, test.value2)
+		if err := execDoubleArith(test.opcode, f.Operands); err != nil {
+			t.Errorf("%s %g, %g: unexpected error: %s", BytecodeNames[test.opcode], test.value1, test.value2,
+				err.Error())
+			continue
+		}
+		if f.Operands.Depth() != 2 {
+			t.Errorf("%s: expected the result to take up two slots, got a TOS of: %d",
+				BytecodeNames[test.opcode], f.Operands.Depth()-1)
+			continue
+		}
+		pop(&f)
+		result := pop(&f).(float64)
+		if !sameFloat(result, test.expected) {
+			t.Errorf("%s %g, %g: expected %g, got: %g", BytecodeNames[test.opcode], test.value1, test.value2,
+				test.expected, result)
+		}
+	}
+}
+
+func TestExecDoubleArithDneg(t *testing.T) {
+	f := newFrame(DNEG)
+	push(&f, math.Inf(1))
+	push(&f, math.Inf(1))
+	_ = execDoubleArith(DNEG, f.Operands)
+	if f.Operands.Depth() != 2 {
+		t.Fatalf("DNEG: expected the result to take up two slots, got a TOS of: %d", f.Operands.Depth()-1)
+	}
+	if result := pop(&f).(float64); !math.IsInf(result, -1) {
+		t.Errorf("DNEG: expected -Infinity, got: %g", result)
+	}
+}
+
+// reports whether two floating-point results are the same, where any NaN is the same
+// as any other, and 0.0 differs from -0.0
+func sameFloat(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b && math.Signbit(a) == math.Signbit(b)
+}
@@ -0,0 +1,184 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"jacobin/log"
+	"jacobin/management"
+	"jacobin/shutdown"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// The attach endpoint, POST /management/attach, replaces a loaded class with a new
+// version, as a tool attached via jdk.attach would. This is a stub of the attach
+// mechanism: Jacobin can attach only to itself, so rather than being read from the
+// class path of another process, the new class is given in the request as
+// base64-encoded bytes. The request body is JSON:
+//
+//	{"pid": 1234, "className": "Hello2", "bytes": "yv66vg..."}
+//
+// The pid is optional; if given, it must be Jacobin's own. The class is replaced using
+// VMInstrumentation.Redefine(), so methods that are executing complete with the old
+// bytecode, and later invocations run the new bytecode. The endpoint is started by the
+// option -agentlib:jacobin-attach=<address>, where the address must be a loopback
+// address, such as localhost:7000.
+//
+// Because the endpoint replaces code in the running VM, every request must carry the
+// header "Authorization: Bearer <token>". The token is generated when the endpoint is
+// started and written to the file .jacobin_attach_<pid> in the temporary directory,
+// which only the user running Jacobin can read, and which is removed when Jacobin
+// exits. This is much as the JDK's attach mechanism
+// relies on the permissions of the files in its temporary directory.
+
+type attachRequest struct {
+	Pid       int    `json:"pid"`
+	ClassName string `json:"className"`
+	Bytes     string `json:"bytes"`
+}
+
+var registerAttachOnce sync.Once
+
+// the token that attach requests must present; while it's empty, all requests are refused
+var attachToken string
+var attachTokenMutex sync.RWMutex
+
+// registerAttachEndpoint adds the attach endpoint to the management endpoints
+func registerAttachEndpoint() {
+	registerAttachOnce.Do(func() {
+		management.Handle("/management/attach", attachHandler)
+	})
+}
+
+// startAttachEndpoint serves the management endpoints, including the attach endpoint,
+// at the given address, which must be a loopback address
+func startAttachEndpoint(addr string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	token, err := newAttachToken()
+	if err != nil {
+		return err
+	}
+	tokenFile := filepath.Join(os.TempDir(), ".jacobin_attach_"+strconv.Itoa(os.Getpid()))
+	if err = os.WriteFile(tokenFile, []byte(token), 0600); err != nil {
+		return errors.New("cannot write the attach token: " + err.Error())
+	}
+	setAttachToken(token)
+
+	registerAttachEndpoint()
+	listening, err := management.Serve(addr)
+	if err != nil {
+		_ = os.Remove(tokenFile)
+		return err
+	}
+	shutdown.AddShutdownHook(func() { _ = os.Remove(tokenFile) })
+	_ = log.Log("Attach endpoint listening at: http://"+listening+"/management/attach"+
+		", with its token in: "+tokenFile, log.INFO)
+	return nil
+}
+
+// checks that addr (host:port) is on a loopback interface, so that the attach endpoint
+// can't be reached from other hosts. A missing host, which would listen on all
+// interfaces, is refused.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.New("invalid attach address " + addr + ": " + err.Error())
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return errors.New("attach address " + addr + " is not a loopback address")
+}
+
+// returns a random token of 32 hex digits
+func newAttachToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("cannot generate the attach token: " + err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func setAttachToken(token string) {
+	attachTokenMutex.Lock()
+	attachToken = token
+	attachTokenMutex.Unlock()
+}
+
+// reports whether the request carries the attach token
+func authorized(r *http.Request) bool {
+	attachTokenMutex.RLock()
+	token := attachToken
+	attachTokenMutex.RUnlock()
+	if token == "" {
+		return false
+	}
+	given := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) == 1
+}
+
+func attachHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "attach requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "attach requires the token", http.StatusUnauthorized)
+		return
+	}
+
+	var req attachRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid attach request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Pid != 0 && req.Pid != os.Getpid() {
+		http.Error(w, "can only attach to this process", http.StatusNotFound)
+		return
+	}
+	if req.ClassName == "" {
+		http.Error(w, "attach request has no className", http.StatusBadRequest)
+		return
+	}
+
+	if req.Bytes == "" {
+		http.Error(w, "attach request has no bytes", http.StatusBadRequest)
+		return
+	}
+	classBytes, err := base64.StdEncoding.DecodeString(req.Bytes)
+	if err != nil {
+		http.Error(w, "cannot read class "+req.ClassName+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = VMInstrumentation.Redefine(req.ClassName, classBytes); err != nil {
+		http.Error(w, "cannot replace class "+req.ClassName+": "+err.Error(),
+			http.StatusUnprocessableEntity)
+		return
+	}
+	_ = log.Log("Class replaced via attach: "+req.ClassName, log.INFO)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"className": req.ClassName, "status": "replaced"})
+}
@@ -0,0 +1,156 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/base64"
+	"jacobin/classbuilder"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/management"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// returns the bytes of class AttachTest, whose static method value() returns the given constant
+func attachTestClass(t *testing.T, valueOpcode byte) []byte {
+	bytes, err := classbuilder.NewClassBuilder("AttachTest").
+		AddMethod("value", "()I").AddOpcode(valueOpcode).AddOpcode(IRETURN).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building AttachTest: %s", err.Error())
+	}
+	return bytes
+}
+
+const testAttachToken = "0123456789abcdef0123456789abcdef"
+
+func postAttach(body string) *httptest.ResponseRecorder {
+	setAttachToken(testAttachToken)
+	req := httptest.NewRequest(http.MethodPost, "/management/attach", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAttachToken)
+	rec := httptest.NewRecorder()
+	management.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAttachReplacesClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	registerAttachEndpoint()
+
+	_, err := classloader.ParseAndPostClass(classloader.AppCL, "AttachTest.class", attachTestClass(t, ICONST_1))
+	if err != nil {
+		t.Fatalf("Unexpected error loading AttachTest: %s", err.Error())
+	}
+	if ret, _ := invokeMethod("AttachTest", "value", "()I", nil); ret != int64(1) {
+		t.Fatalf("Expected AttachTest.value() to return 1 before attach, got: %v", ret)
+	}
+
+	newBytes := base64.StdEncoding.EncodeToString(attachTestClass(t, ICONST_2))
+	rec := postAttach(`{"className":"AttachTest","bytes":"` + newBytes + `"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from attach, got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	k, _ := classloader.LookupClass("AttachTest")
+	if k.Loader != "app" {
+		t.Errorf("Expected AttachTest to stay in the app classloader, got: %s", k.Loader)
+	}
+	if ret, _ := invokeMethod("AttachTest", "value", "()I", nil); ret != int64(2) {
+		t.Errorf("Expected AttachTest.value() to return 2 after attach, got: %v", ret)
+	}
+
+	// later retransformations start from the new definition
+	if err = VMInstrumentation.Retransform("AttachTest"); err != nil {
+		t.Fatalf("Unexpected error retransforming AttachTest: %s", err.Error())
+	}
+	if ret, _ := invokeMethod("AttachTest", "value", "()I", nil); ret != int64(2) {
+		t.Errorf("Expected AttachTest.value() to return 2 after retransform, got: %v", ret)
+	}
+}
+
+func TestAttachErrors(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	registerAttachEndpoint()
+
+	req := httptest.NewRequest(http.MethodGet, "/management/attach", nil)
+	rec := httptest.NewRecorder()
+	management.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got: %d", rec.Code)
+	}
+
+	if rec = postAttach(`{"className":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for malformed JSON, got: %d", rec.Code)
+	}
+	if rec = postAttach(`{"className":"AttachTest"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a request without bytes, got: %d", rec.Code)
+	}
+	if rec = postAttach(`{"className":"AttachTest","path":"/etc/passwd"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a request that gives a path, got: %d", rec.Code)
+	}
+	if rec = postAttach(`{"pid":-1,"className":"AttachTest","bytes":""}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another process, got: %d", rec.Code)
+	}
+
+	newBytes := base64.StdEncoding.EncodeToString(attachTestClass(t, ICONST_2))
+	rec = postAttach(`{"className":"NeverLoaded","bytes":"` + newBytes + `"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a class that isn't loaded, got: %d", rec.Code)
+	}
+}
+
+func TestAttachRequiresToken(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	registerAttachEndpoint()
+	body := `{"className":"AttachTest","bytes":"yv66vg=="}`
+
+	for _, header := range []string{"", "Bearer wrong", testAttachToken} {
+		setAttachToken(testAttachToken)
+		req := httptest.NewRequest(http.MethodPost, "/management/attach", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		management.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for Authorization %q, got: %d", header, rec.Code)
+		}
+	}
+
+	// with no token set, even a request that presents an empty token is refused
+	setAttachToken("")
+	req := httptest.NewRequest(http.MethodPost, "/management/attach", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	management.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 when no token is set, got: %d", rec.Code)
+	}
+}
+
+func TestAttachAddressMustBeLoopback(t *testing.T) {
+	for _, addr := range []string{"localhost:7000", "127.0.0.1:0", "[::1]:7000"} {
+		if err := checkLoopback(addr); err != nil {
+			t.Errorf("Expected %s to be accepted, got: %s", addr, err.Error())
+		}
+	}
+	for _, addr := range []string{":7000", "0.0.0.0:7000", "192.168.1.10:7000", "example.com:7000", "localhost"} {
+		if err := checkLoopback(addr); err == nil {
+			t.Errorf("Expected %s to be refused", addr)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/frames"
+	"strings"
+)
+
+// The branch instructions of the interpreter. Their jump targets are given as offsets
+// from the PC of the branch instruction itself: 2 bytes for most of them, and 4 bytes
+// for goto_w and the entries of tableswitch and lookupswitch.
+
+// execBranch executes the conditional or unconditional branch instruction at pc in
+// code, the method's bytecode (ifeq through goto, tableswitch, lookupswitch, ifnull,
+// ifnonnull, or goto_w), popping the values it tests off the operand stack. It returns
+// the PC of the next instruction to execute: the jump target if the branch is taken,
+// otherwise the instruction that follows the branch. A jump target outside the method's
+// bytecode, or an instruction that's cut off, returns an error that describes the
+// problem, which the caller throws as a VerifyError (see branchVerifyError()).
+func execBranch(opcode byte, pc int, code []byte, stack *frames.OperandStack) (newPC int, err error) {
+	var taken bool
+	length := 3 // the length of the instruction, including its 2-byte offset
+	switch opcode {
+	case IFEQ, IFNE, IFLT, IFGE, IFGT, IFLE:
+		value := int32(stack.Pop().(int64))
+		taken = intCondition(opcode-IFEQ, value, 0)
+	case IF_ICMPEQ, IF_ICMPNE, IF_ICMPLT, IF_ICMPGE, IF_ICMPGT, IF_ICMPLE:
+		value2 := int32(stack.Pop().(int64))
+		value1 := int32(stack.Pop().(int64))
+		taken = intCondition(opcode-IF_ICMPEQ, value1, value2)
+	case IF_ACMPEQ, IF_ACMPNE:
+		value2 := stack.Pop()
+		value1 := stack.Pop()
+		taken = (value1 == value2) == (opcode == IF_ACMPEQ)
+	case IFNULL, IFNONNULL: // null is the address 0
+		value := stack.Pop()
+		taken = (value == nil || value == int64(0)) == (opcode == IFNULL)
+	case GOTO:
+		taken = true
+	case GOTO_W:
+		taken = true
+		length = 5
+	case TABLESWITCH, LOOKUPSWITCH:
+		return execSwitch(opcode, pc, code, stack)
+	default:
+		return 0, errors.New("is not a branch instruction")
+	}
+
+	if pc+length > len(code) {
+		return 0, errors.New("is truncated")
+	}
+	if !taken {
+		return pc + length, nil
+	}
+	var offset int
+	if length == 5 {
+		offset = int(int32At(code, pc+1))
+	} else {
+		offset = int(int16(code[pc+1])<<8 | int16(code[pc+2]))
+	}
+	return branchTarget(pc, offset, code)
+}
+
+// intCondition reports whether value1 and value2 meet the condition of an if or
+// if_icmp instruction, given by its position in the instructions' shared order:
+// eq, ne, lt, ge, gt, le
+func intCondition(condition byte, value1, value2 int32) bool {
+	switch condition {
+	case 0:
+		return value1 == value2
+	case 1:
+		return value1 != value2
+	case 2:
+		return value1 < value2
+	case 3:
+		return value1 >= value2
+	case 4:
+		return value1 > value2
+	default:
+		return value1 <= value2
+	}
+}
+
+// execSwitch executes a tableswitch or lookupswitch, which pop an int key and jump to
+// the offset that the instruction gives for it, or to its default offset if there's
+// none. The instruction's operands start at the first multiple of 4 after its opcode.
+// tableswitch has the default offset, the lowest and highest keys, and the offsets
+// for each key from lowest to highest. lookupswitch has the default offset, a count of
+// pairs, and the pairs of a key and its offset, sorted by key.
+func execSwitch(opcode byte, pc int, code []byte, stack *frames.OperandStack) (int, error) {
+	key := int32(stack.Pop().(int64))
+	pos := (pc + 4) &^ 3 // skips the 0-3 bytes of padding after the opcode
+
+	if pos+12 > len(code) {
+		return 0, errors.New("is truncated")
+	}
+	offset := int32At(code, pos)
+	if opcode == TABLESWITCH {
+		low, high := int32At(code, pos+4), int32At(code, pos+8)
+		if low > high || pos+12+4*(int(high)-int(low)+1) > len(code) {
+			return 0, errors.New("has an invalid jump table")
+		}
+		if key >= low && key <= high {
+			offset = int32At(code, pos+12+4*int(key-low))
+		}
+	} else {
+		pairs := int(int32At(code, pos+4))
+		if pairs < 0 || pos+8+8*pairs > len(code) {
+			return 0, errors.New("has an invalid jump table")
+		}
+		for i := 0; i < pairs; i++ {
+			if match := int32At(code, pos+8+8*i); match == key {
+				offset = int32At(code, pos+12+8*i)
+				break
+			}
+		}
+	}
+	return branchTarget(pc, int(offset), code)
+}
+
+// returns the PC that the branch instruction at pc jumps to, given its offset
+func branchTarget(pc, offset int, code []byte) (int, error) {
+	target := pc + offset
+	if target < 0 || target >= len(code) {
+		return 0, fmt.Errorf("jumps to %d, outside the method", target)
+	}
+	return target, nil
+}
+
+// returns the signed 4-byte big-endian value at pos in code
+func int32At(code []byte, pos int) int32 {
+	return int32(code[pos])<<24 | int32(code[pos+1])<<16 | int32(code[pos+2])<<8 | int32(code[pos+3])
+}
+
+// branchVerifyError throws the VerifyError for the problem that execBranch() found with
+// the branch instruction at f.PC
+func branchVerifyError(f *frames.Frame, problem string) error {
+	return throwVerifyError(fmt.Sprintf("%s at %d in method %s.%s %s", BytecodeNames[f.Meth[f.PC]], f.PC,
+		strings.ReplaceAll(f.ClName, "/", "."), f.MethName, problem))
+}
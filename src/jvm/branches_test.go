@@ -0,0 +1,179 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// returns a frame for the branch instruction in code, which is at pc and is followed by
+// NOPs up to a length of 64 bytes, so that jumps have somewhere to go
+func branchFrame(code []byte, pc int, stack ...interface{}) *frames.Frame {
+	f := newFrame(NOP)
+	f.Meth = make([]byte, 64)
+	copy(f.Meth[pc:], code)
+	f.PC = pc
+	f.ClName = "Test"
+	f.MethName = "run"
+	for _, value := range stack {
+		push(&f, value)
+	}
+	return &f
+}
+
+func TestExecBranchConditions(t *testing.T) {
+	tests := []struct {
+		opcode   byte
+		stack    []interface{}
+		expected int // the next PC, from a branch at 10 with an offset of 6
+	}{
+		{IFEQ, []interface{}{int64(0)}, 16},
+		{IFEQ, []interface{}{int64(1)}, 13},
+		{IFNE, []interface{}{int64(-1)}, 16},
+		{IFLT, []interface{}{int64(-1)}, 16},
+		{IFLT, []interface{}{int64(0)}, 13},
+		{IFGE, []interface{}{int64(0)}, 16},
+		{IFGT, []interface{}{int64(0)}, 13},
+		{IFLE, []interface{}{int64(0)}, 16},
+		{IF_ICMPEQ, []interface{}{int64(7), int64(7)}, 16},
+		{IF_ICMPNE, []interface{}{int64(7), int64(7)}, 13},
+		{IF_ICMPLT, []interface{}{int64(-8), int64(7)}, 16},
+		{IF_ICMPGE, []interface{}{int64(-8), int64(7)}, 13},
+		{IF_ICMPGT, []interface{}{int64(7), int64(-8)}, 16},
+		{IF_ICMPLE, []interface{}{int64(7), int64(7)}, 16},
+		{IF_ACMPEQ, []interface{}{int64(0x1000), int64(0x1000)}, 16},
+		{IF_ACMPNE, []interface{}{int64(0x1000), int64(0x1000)}, 13},
+		{IFNULL, []interface{}{int64(0)}, 16},
+		{IFNULL, []interface{}{int64(0x1000)}, 13},
+		{IFNONNULL, []interface{}{int64(0x1000)}, 16},
+		{GOTO, nil, 16},
+	}
+
+	for _, test := range tests {
+		fb := branchFrame([]byte{test.opcode, 0x00, 0x06}, 10, test.stack...)
+		next, err := execBranch(test.opcode, fb.PC, fb.Meth, fb.Operands)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error: %s", BytecodeNames[test.opcode], test.stack, err.Error())
+			continue
+		}
+		if next != test.expected {
+			t.Errorf("%s %v: expected the next PC to be %d, got: %d", BytecodeNames[test.opcode], test.stack,
+				test.expected, next)
+		}
+		if fb.Operands.Depth() != 0 {
+			t.Errorf("%s: expected an empty stack, got a TOS of: %d", BytecodeNames[test.opcode], fb.Operands.Depth()-1)
+		}
+	}
+}
+
+func TestExecBranchBackward(t *testing.T) {
+	fb := branchFrame([]byte{GOTO, 0xFF, 0xF6}, 30) // -10
+	if next, err := execBranch(GOTO, fb.PC, fb.Meth, fb.Operands); err != nil || next != 20 {
+		t.Errorf("GOTO -10 from 30: expected the next PC to be 20, got: %d, %v", next, err)
+	}
+
+	fb = branchFrame([]byte{GOTO_W, 0xFF, 0xFF, 0xFF, 0xE2}, 40) // -30
+	if next, err := execBranch(GOTO_W, fb.PC, fb.Meth, fb.Operands); err != nil || next != 10 {
+		t.Errorf("GOTO_W -30 from 40: expected the next PC to be 10, got: %d, %v", next, err)
+	}
+}
+
+// the operands of a tableswitch start at the next multiple of 4, so its padding depends
+// on where it is
+func TestExecTableswitch(t *testing.T) {
+	for _, pc := range []int{0, 1, 2, 3} {
+		code := []byte{TABLESWITCH, 0, 0, 0}[:4-pc%4]            // the opcode and its padding
+		code = append(code, 0, 0, 0, 40, 0, 0, 0, 1, 0, 0, 0, 3) // default 40, low 1, high 3
+		code = append(code, 0, 0, 0, 30, 0, 0, 0, 31, 0, 0, 0, 32)
+		for key, offset := range map[int64]int{0: 40, 1: 30, 2: 31, 3: 32, 4: 40, -1: 40} {
+			fb := branchFrame(code, pc, key)
+			next, err := execBranch(TABLESWITCH, fb.PC, fb.Meth, fb.Operands)
+			if err != nil || next != pc+offset {
+				t.Errorf("TABLESWITCH at %d on %d: expected the next PC to be %d, got: %d, %v",
+					pc, key, pc+offset, next, err)
+			}
+		}
+	}
+}
+
+func TestExecLookupswitch(t *testing.T) {
+	code := []byte{LOOKUPSWITCH, 0, 0}                       // at 1, with 2 bytes of padding
+	code = append(code, 0, 0, 0, 50, 0, 0, 0, 2)             // default 50, 2 pairs
+	code = append(code, 0xFF, 0xFF, 0xFF, 0xF6, 0, 0, 0, 20) // -10: 20
+	code = append(code, 0, 0, 0x03, 0xE8, 0, 0, 0, 30)       // 1000: 30
+	for key, offset := range map[int64]int{-10: 20, 1000: 30, 0: 50, 999: 50} {
+		fb := branchFrame(code, 1, key)
+		next, err := execBranch(LOOKUPSWITCH, fb.PC, fb.Meth, fb.Operands)
+		if err != nil || next != 1+offset {
+			t.Errorf("LOOKUPSWITCH on %d: expected the next PC to be %d, got: %d, %v", key, 1+offset, next, err)
+		}
+	}
+}
+
+// execBranch() reports what's wrong with a bad branch, which the interpreter throws as a
+// VerifyError that names the method
+func TestExecBranchInvalid(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	tests := []struct {
+		code     []byte
+		pc       int
+		expected string
+	}{
+		{[]byte{GOTO, 0x00, 0x40}, 10, "java.lang.VerifyError: GOTO at 10 in method Test.run jumps to 74, " +
+			"outside the method"},
+		{[]byte{IFEQ, 0xFF, 0xF0}, 10, "java.lang.VerifyError: IFEQ at 10 in method Test.run jumps to -6, " +
+			"outside the method"},
+		{[]byte{GOTO_W, 0x00}, 62, "java.lang.VerifyError: GOTO_W at 62 in method Test.run is truncated"},
+		{[]byte{TABLESWITCH, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 2, 0, 0, 0, 1}, 0,
+			"java.lang.VerifyError: TABLESWITCH at 0 in method Test.run has an invalid jump table"},
+	}
+	for _, test := range tests {
+		fb := branchFrame(test.code, test.pc, int64(0))
+		fs := frames.CreateFrameStack()
+		fs.PushFront(fb)
+		var err error
+		captureStderr(func() {
+			err = runFrame(fs)
+		})
+
+		var thrown *JavaThrowable
+		if !errors.As(err, &thrown) {
+			t.Errorf("%s: expected a VerifyError, got: %v", BytecodeNames[test.code[0]], err)
+			continue
+		}
+		if thrown.Error() != test.expected {
+			t.Errorf("%s: expected %s, got: %s", BytecodeNames[test.code[0]], test.expected, thrown.Error())
+		}
+	}
+}
+
+// runs a method that sums the ints 1 through 5 in a loop, which branches backward
+func TestBranchLoop(t *testing.T) {
+	f := newFrame(ICONST_0)
+	f.Meth = append(f.Meth,
+		ISTORE_0,           // 1: sum = 0
+		ICONST_5, ISTORE_1, // 2: i = 5
+		ILOAD_0, ILOAD_1, IADD, // 4: sum += i
+		ISTORE_0,
+		IINC, 1, 0xFF, // 8: i--
+		ILOAD_1, IFGT, 0xFF, 0xF8, // 11: if i > 0, go back from 12 to 4
+		ILOAD_0) // 15: push sum, and run off the end of the method
+	setLocals(&f, zero, zero)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	_ = runFrame(fs)
+	if result := pop(&f).(int64); result != 15 {
+		t.Errorf("Expected the loop to sum to 15, got: %d", result)
+	}
+}
@@ -37,7 +37,11 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 
 	// pull out all the arguments into an array of strings. Note that an arg with spaces but
 	// within quotes is treated as a single arg
-	args := strings.Fields(javaEnvOptions)
+	args, err := TokenizeOptions(javaEnvOptions)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error in Java environment options: %s. Ignored.\n", err.Error())
+		args, err = nil, nil
+	}
 	for _, v := range osArgs[1:] {
 		//		fmt.Printf("\t%q\n", v)
 		args = append(args, v)
@@ -45,11 +49,29 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 	Global.Args = args
 	showCopyright(Global)
 
+	expandedTo := 0 // args before this index came from an @file, so aren't expanded again
 	for i := 0; i < len(args); i++ {
 		var option, arg string
+
+		// @filename is replaced by the args in the file
+		if strings.HasPrefix(args[i], "@") && i >= expandedTo {
+			fileArgs, err := readArgFile(args[i][1:])
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				return err
+			}
+			args = append(args[:i:i], append(fileArgs, args[i+1:]...)...)
+			Global.Args = args
+			expandedTo = i + len(fileArgs)
+			i--
+			continue
+		}
+
 		// if it's a JVM option (so, it begins with a hyphen)
 		// break the option into the option and any embedded arg values, if any
-		if strings.HasPrefix(args[i], "-") {
+		if strings.HasPrefix(args[i], "-D") { // -Dkey=value: the whole key=value is the arg
+			option, arg = "-D", args[i][2:]
+		} else if strings.HasPrefix(args[i], "-") {
 			option, arg, err = getOptionRootAndArgs(args[i])
 		} else {
 			option = args[i]
@@ -59,9 +81,10 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 			continue // skip the arg if there was a problem. (Might want to revisit this.)
 		}
 
-		// if the option is the name of the class to execute, note that then get
-		// all successive arguments and store them as app args in Global
-		if strings.HasSuffix(option, ".class") {
+		// if the option is the class to execute, either a class file or (as with
+		// any argument that's not an option) a class name, such as com.example.Main,
+		// note that then get all successive arguments and store them as app args in Global
+		if strings.HasSuffix(option, ".class") || !strings.HasPrefix(args[i], "-") {
 			Global.StartingClass = option
 			for i = i + 1; i < len(args); i++ {
 				Global.AppArgs = append(Global.AppArgs, args[i])
@@ -87,6 +110,24 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 	return nil
 }
 
+// reads the args in an @file. The args are separated by whitespace, including newlines,
+// and quotes keep an arg that contains spaces together, as in the options in the
+// JAVA_TOOL_OPTIONS environment variable. Args in the file are not expanded further.
+func readArgFile(filename string) ([]string, error) {
+	if filename == "" {
+		return nil, errors.New("no file name follows @")
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open argument file %s: %w", filename, err)
+	}
+	args, err := splitTokens(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("in argument file %s: %w", filename, err)
+	}
+	return args, nil
+}
+
 // pass in the option potentially with embedded arguments and get back
 // the option name and the embedded argument(s), if any
 func getOptionRootAndArgs(option string) (string, string, error) {
@@ -94,25 +135,28 @@ func getOptionRootAndArgs(option string) (string, string, error) {
 		return "", "", errors.New("empty option error")
 	}
 
-	// if the option has an embedded arg value, it'll come after a : or an =
-	argMarker := strings.Index(option, ":")
-	if argMarker == -1 {
-		argMarker = strings.Index(option, "=")
-	}
+	// if the option has an embedded arg value, it'll come after the first : or =
+	// Any later : or = characters are part of the arg value, as in -Dkey=a:b
+	argMarker := strings.IndexAny(option, ":=")
 
 	// if there's no embedded : or = then the option doesn't contain an arg value
 	if argMarker == -1 {
 		return option, "", nil
 	}
 
-	return option[:argMarker], option[argMarker+1:], nil
+	if argMarker == 0 {
+		return "", "", errors.New("option has an arg value but no name: " + option)
+	}
 
+	return option[:argMarker], option[argMarker+1:], nil
 }
 
 // you can can set JVM options using the three environment variables that are
 // inspected in this function. Note: order is important because later options
 // can override earlier ones. These are checked before any of the command-line
 // options are processed.
+// The options are returned as a single string, which HandleCli() splits into
+// individual options using TokenizeOptions().
 func getEnvArgs() string {
 	envArgs := ""
 	javaEnvKeys := [3]string{"JAVA_TOOL_OPTIONS", "_JAVA_OPTIONS", "JDK_JAVA_OPTIONS"}
@@ -154,6 +198,13 @@ are passed as the arguments to main class.
 
 where options include:
 	-client       to select the "client" VM
+	-cp <class search path of directories and jar files>
+	-classpath <class search path of directories and jar files>
+	--class-path <class search path of directories and jar files>
+	              A ` + string(os.PathListSeparator) + ` separated list of directories and JAR archives
+	              to search for class files.
+	-D<name>=<value>
+	              set a system property
 	-verbose:[class|info|fine|finest]  enable verbose output
                   info, fine, finest are Jacobin-specific options providing
                     increasing amounts of detail. The finest level is used
@@ -165,7 +216,9 @@ where options include:
 	-showversion  print product version to the error stream and continue
 	--show-version
 				  print product version to the output stream and continue
-	-strictJDK    make user messages conform closely to the JDK's format'`
+	-strictJDK    make user messages conform closely to the JDK's format
+	@argument files
+	              one or more argument files containing options'`
 
 	_, _ = fmt.Fprintln(outStream, userMessage)
 }
@@ -11,6 +11,8 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -45,6 +47,49 @@ func TestGetJVMenvVariablesWhenTwoArePresent(t *testing.T) {
 	_ = os.Unsetenv("JDK_JAVA_OPTIONS")
 }
 
+func TestTokenizeOptions(t *testing.T) {
+	tests := []struct {
+		options  string
+		expected []string
+		fails    bool
+	}{
+		{`-Dfoo=bar -Dbaz=hello world`, nil, true},
+		{`-Dfoo=bar "-Dbaz=hello world"`, []string{"-Dfoo=bar", "-Dbaz=hello world"}, false},
+		{`'-Dfoo=bar'`, []string{"-Dfoo=bar"}, false},
+		{`-Djava.library.path="/path with spaces"`, []string{"-Djava.library.path=/path with spaces"}, false},
+		{`"-Dquote=a \"b\" c\ d"`, []string{`-Dquote=a "b" c d`}, false},
+		{`-Dempty="" -verbose:class`, []string{"-Dempty=", "-verbose:class"}, false},
+		{`-cp  /some/path   -ea`, []string{"-cp", "/some/path", "-ea"}, false},
+		{`""`, []string{""}, false},
+		{``, nil, false},
+		{`"-Dunterminated=x`, nil, true},
+	}
+
+	for _, test := range tests {
+		tokens, err := TokenizeOptions(test.options)
+		if test.fails {
+			if err == nil {
+				t.Errorf("TokenizeOptions(%s): expected an error, got: %q", test.options, tokens)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TokenizeOptions(%s): unexpected error: %s", test.options, err.Error())
+			continue
+		}
+		if len(tokens) != len(test.expected) {
+			t.Errorf("TokenizeOptions(%s): expected %q, got: %q", test.options, test.expected, tokens)
+			continue
+		}
+		for i := range tokens {
+			if tokens[i] != test.expected[i] {
+				t.Errorf("TokenizeOptions(%s): expected %q, got: %q", test.options, test.expected, tokens)
+				break
+			}
+		}
+	}
+}
+
 // verify the output to stderr -help option is used
 func TestHandleUsageMessage(t *testing.T) {
 	// set the logger to low granularity, so that logging messages are not also captured in this test
@@ -80,6 +125,10 @@ func TestHandleUsageMessage(t *testing.T) {
 		t.Error("jacobin -help did not generate the usage message to stderr. msg was: " + msg)
 	}
 
+	if !strings.Contains(msg, "-cp <class search path") || !strings.Contains(msg, "-classpath") {
+		t.Error("jacobin -help did not describe the -cp and -classpath options. msg was: " + msg)
+	}
+
 	if global.ExitNow != true {
 		t.Error("'jacobin -help' should have set Global.exitNow to true to signal end of processing")
 	}
@@ -541,3 +590,368 @@ func TestEmptyOptionForEmbeddedArg(t *testing.T) {
 		t.Error("Empty option should fail test for embedded args, but did not.")
 	}
 }
+
+func TestOptionWithMultipleArgMarkers(t *testing.T) {
+	option, arg, err := getOptionRootAndArgs("--module-path=lib:mods")
+	if err != nil || option != "--module-path" || arg != "lib:mods" {
+		t.Errorf("Expected --module-path with arg lib:mods, got: %s with arg %s", option, arg)
+	}
+
+	option, arg, err = getOptionRootAndArgs("-Dfoo=a:b=c")
+	if err != nil || option != "-Dfoo" || arg != "a:b=c" {
+		t.Errorf("Expected -Dfoo with arg a:b=c, got: %s with arg %s", option, arg)
+	}
+}
+
+func TestOptionWithNoNameBeforeArgMarker(t *testing.T) {
+	_, _, err := getOptionRootAndArgs(":class")
+	if err == nil {
+		t.Error("Option with no name before the arg value should fail, but did not.")
+	}
+}
+
+// the fuzzer feeds arbitrary strings to getOptionRootAndArgs(). Beyond not panicking,
+// a successful parse must not drop any bytes from the option: the option root and its
+// arg value, joined by the marker that separated them, must reproduce the input.
+func FuzzGetOptionRootAndArgs(f *testing.F) {
+	seeds := []string{"-verbose:class", "--module-path=foo", "-D=", "-", "-Dfoo=a:b", "-XX:+PrintGC", ":"}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		option, arg, err := getOptionRootAndArgs(input)
+		if err != nil {
+			return
+		}
+
+		if len(input) > 0 && option == "" {
+			t.Errorf("Empty option returned for non-empty input %q", input)
+		}
+
+		if option == input {
+			if arg != "" {
+				t.Errorf("Unexpected arg %q returned for input %q with no arg value", arg, input)
+			}
+			return
+		}
+
+		marker := input[len(option)]
+		if (marker != ':' && marker != '=') || option+string(marker)+arg != input {
+			t.Errorf("Input %q was split into option %q and arg %q, which loses data",
+				input, option, arg)
+		}
+	})
+}
+
+func TestPrintGCOptions(t *testing.T) {
+	global := globals.InitGlobals("test")
+	log.Init()
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-XX:+PrintGC", "-XX:+PrintGCDetails", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	if !global.PrintGC {
+		t.Error("-XX:+PrintGC did not set Global.PrintGC")
+	}
+	if !global.PrintGCDetails {
+		t.Error("-XX:+PrintGCDetails did not set Global.PrintGCDetails")
+	}
+	if log.Level != log.WARNING { // the collections are logged whatever the logging level
+		t.Errorf("-XX:+PrintGC should not have changed the logging level, got: %d", log.Level)
+	}
+
+	args = []string{"jacobin", "-XX:-PrintGCDetails", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	if global.PrintGCDetails {
+		t.Error("-XX:-PrintGCDetails did not clear Global.PrintGCDetails")
+	}
+	if !global.PrintGC {
+		t.Error("-XX:-PrintGCDetails should not have changed Global.PrintGC")
+	}
+}
+
+func TestUseStringDeduplicationOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-XX:+UseStringDeduplication", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if !global.UseStringDeduplication {
+		t.Error("-XX:+UseStringDeduplication did not set Global.UseStringDeduplication")
+	}
+
+	args = []string{"jacobin", "-XX:-UseStringDeduplication", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if global.UseStringDeduplication {
+		t.Error("-XX:-UseStringDeduplication did not clear Global.UseStringDeduplication")
+	}
+}
+
+func TestClasspathOptionFollowingEquals(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-cp=foo.jar", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if len(global.ClassPath) != 1 || global.ClassPath[0] != "foo.jar" {
+		t.Errorf("Expected -cp=foo.jar to set the classpath to [foo.jar], got: %v", global.ClassPath)
+	}
+	if global.StartingClass != "Hello.class" {
+		t.Errorf("Expected starting class Hello.class, got: %s", global.StartingClass)
+	}
+}
+
+func TestClasspathOptionAsNextArg(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-classpath", "foo.jar", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if len(global.ClassPath) != 1 || global.ClassPath[0] != "foo.jar" {
+		t.Errorf("Expected -classpath foo.jar to set the classpath to [foo.jar], got: %v", global.ClassPath)
+	}
+	if global.StartingClass != "Hello.class" {
+		t.Errorf("Expected starting class Hello.class, got: %s", global.StartingClass)
+	}
+}
+
+func TestClasspathOptionWithMultipleEntries(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	sep := string(os.PathListSeparator)
+	args := []string{"jacobin", "-cp=classes" + sep + "lib/a.jar" + sep + "lib/b.jar", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	expected := []string{"classes", "lib/a.jar", "lib/b.jar"}
+	if len(global.ClassPath) != len(expected) {
+		t.Fatalf("Expected classpath %v, got: %v", expected, global.ClassPath)
+	}
+	for i := range expected {
+		if global.ClassPath[i] != expected[i] {
+			t.Errorf("Expected classpath %v, got: %v", expected, global.ClassPath)
+		}
+	}
+}
+
+func TestClasspathOptionMissingValue(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"-cp"}
+
+	if _, err := getClassPath(0, "", &global); err != os.ErrInvalid {
+		t.Errorf("Expected a missing classpath after -cp to return os.ErrInvalid, got: %v", err)
+	}
+}
+
+func TestClasspathOptionAndMainClassName(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	classPath := "build/classes" + string(os.PathListSeparator) + "lib/util.jar"
+	args := []string{"jacobin", "-cp", classPath, "com.example.Main", "arg1", "-arg2"}
+	_ = HandleCli(args, &global)
+
+	if len(global.ClassPath) != 2 || global.ClassPath[0] != "build/classes" ||
+		global.ClassPath[1] != "lib/util.jar" {
+		t.Errorf("Expected classpath [build/classes lib/util.jar], got: %v", global.ClassPath)
+	}
+	if global.StartingClass != "com.example.Main" {
+		t.Errorf("Expected starting class com.example.Main, got: %s", global.StartingClass)
+	}
+	if len(global.AppArgs) != 2 || global.AppArgs[0] != "arg1" || global.AppArgs[1] != "-arg2" {
+		t.Errorf("Expected app args [arg1 -arg2], got: %v", global.AppArgs)
+	}
+}
+
+func TestTraceInstructionsOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-XX:+TraceInstructions", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if !global.TraceInstructions {
+		t.Error("-XX:+TraceInstructions did not set Global.TraceInstructions")
+	}
+}
+
+func TestEagerClassLoadingOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-XX:+EagerClassLoading", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if !global.EagerClassLoading {
+		t.Error("-XX:+EagerClassLoading did not set Global.EagerClassLoading")
+	}
+}
+
+func TestUseClassCacheOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	if !global.UseClassCache {
+		t.Error("Expected the class cache to be used by default")
+	}
+
+	args := []string{"jacobin", "-XX:-UseClassCache", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if global.UseClassCache {
+		t.Error("-XX:-UseClassCache did not clear Global.UseClassCache")
+	}
+}
+
+func TestAgentlibAttachOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-agentlib:jacobin-attach=localhost:7000", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if global.AttachAddress != "localhost:7000" {
+		t.Errorf("Expected attach address localhost:7000, got: %s", global.AttachAddress)
+	}
+}
+
+func TestUnrecognizedXXOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "-XX:+NoSuchFlag", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if !strings.Contains(string(out), "-XX:+NoSuchFlag is not a recognized option") {
+		t.Errorf("Expected an unrecognized option message, got: %s", string(out))
+	}
+}
+
+func TestModulePathOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "--module-path=mods:lib", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if global.ModulePath != "mods:lib" {
+		t.Errorf("Expected module path 'mods:lib', got: '%s'", global.ModulePath)
+	}
+
+	args = []string{"jacobin", "-p", "mods", "Hello.class"}
+	_ = HandleCli(args, &global)
+	if global.ModulePath != "mods" {
+		t.Errorf("Expected module path 'mods', got: '%s'", global.ModulePath)
+	}
+	if global.StartingClass != "Hello.class" {
+		t.Errorf("Expected starting class after module path to be 'Hello.class', got: '%s'",
+			global.StartingClass)
+	}
+}
+
+func TestSystemPropertyOptions(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-Dfile.encoding=UTF-8", "-Dfoo=bar", "-Dempty", "-Durl=http://a=b", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	expected := map[string]string{"file.encoding": "UTF-8", "foo": "bar", "empty": "", "url": "http://a=b"}
+	if !reflect.DeepEqual(global.SystemProperties, expected) {
+		t.Errorf("Expected system properties %v, got: %v", expected, global.SystemProperties)
+	}
+	if global.StartingClass != "Hello.class" {
+		t.Errorf("Expected starting class Hello.class, got: %s", global.StartingClass)
+	}
+}
+
+func TestArgFileExpansion(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	argFile := filepath.Join(t.TempDir(), "args.txt")
+	contents := "-cp lib\n  -Dfoo=bar\t-Dbaz=\"hello world\"\r\n" +
+		"com.example.Main appArg1\n"
+	if err := os.WriteFile(argFile, []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write argument file: %s", err.Error())
+	}
+
+	args := []string{"jacobin", "-Dfirst=1", "@" + argFile, "appArg2", "@notExpanded"}
+	if err := HandleCli(args, &global); err != nil {
+		t.Fatalf("Unexpected error from HandleCli(): %s", err.Error())
+	}
+
+	if len(global.ClassPath) != 1 || global.ClassPath[0] != "lib" {
+		t.Errorf("Expected classpath [lib], got: %v", global.ClassPath)
+	}
+	expected := map[string]string{"first": "1", "foo": "bar", "baz": "hello world"}
+	if !reflect.DeepEqual(global.SystemProperties, expected) {
+		t.Errorf("Expected system properties %v, got: %v", expected, global.SystemProperties)
+	}
+	if global.StartingClass != "com.example.Main" {
+		t.Errorf("Expected starting class com.example.Main, got: %s", global.StartingClass)
+	}
+	// args after the main class are app args, which are never expanded
+	expectedAppArgs := []string{"appArg1", "appArg2", "@notExpanded"}
+	if !reflect.DeepEqual(global.AppArgs, expectedAppArgs) {
+		t.Errorf("Expected app args %v, got: %v", expectedAppArgs, global.AppArgs)
+	}
+}
+
+func TestArgFileWithNestedQuotes(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	dir := t.TempDir()
+	argFile := filepath.Join(dir, "args.txt")
+	contents := `-Dsingle="it's here" -Ddouble='say "hi"' -Descaped="a \"b\" c" @` +
+		filepath.Join(dir, "other.txt") + " Hello.class"
+	if err := os.WriteFile(argFile, []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write argument file: %s", err.Error())
+	}
+
+	if err := HandleCli([]string{"jacobin", "@" + argFile}, &global); err != nil {
+		t.Fatalf("Unexpected error from HandleCli(): %s", err.Error())
+	}
+	expected := map[string]string{"single": "it's here", "double": `say "hi"`, "escaped": `a "b" c`}
+	if !reflect.DeepEqual(global.SystemProperties, expected) {
+		t.Errorf("Expected system properties %v, got: %v", expected, global.SystemProperties)
+	}
+	// as in the JDK, an @file named in an @file isn't expanded, so here it's taken as
+	// the name of the main class
+	if global.StartingClass != "@"+filepath.Join(dir, "other.txt") {
+		t.Errorf("Expected the nested @file to be the starting class, got: %s", global.StartingClass)
+	}
+}
+
+func TestArgFileMissing(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	err := HandleCli([]string{"jacobin", "@" + missing, "Hello.class"}, &global)
+
+	_ = w.Close()
+	msg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Fatal("Expected an error for a missing argument file, got none")
+	}
+	if !strings.Contains(err.Error(), missing) || !strings.Contains(string(msg), "could not open argument file") {
+		t.Errorf("Expected an error naming the missing file, got: %s\nstderr: %s", err.Error(), string(msg))
+	}
+	if global.StartingClass != "" {
+		t.Errorf("Expected no starting class after the error, got: %s", global.StartingClass)
+	}
+}
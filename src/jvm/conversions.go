@@ -0,0 +1,98 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/frames"
+	"math"
+)
+
+// The type-conversion instructions of the interpreter. As elsewhere, ints (and bytes,
+// chars, and shorts) are held on the operand stack as int64s, floats as float64s, and
+// longs and doubles take up two slots. The rules for the conversions are in:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-2.html#jvms-2.11.4
+
+// execConvert executes one of the type-conversion instructions (i2l through i2s),
+// popping the value to convert off the operand stack and pushing the result.
+func execConvert(opcode byte, stack *frames.OperandStack) error {
+	var value interface{}
+	switch opcode {
+	case I2L, I2F, I2D, I2B, I2C, I2S, F2I, F2L, F2D:
+		value = stack.Pop()
+	case L2I, L2F, L2D, D2I, D2L, D2F:
+		value = stack.Pop()
+		stack.Pop()
+	default:
+		return fmt.Errorf("execConvert: %s is not a type-conversion instruction", BytecodeNames[opcode])
+	}
+
+	switch opcode {
+	case I2L:
+		return stack.PushLong(int64(int32(value.(int64))))
+	case I2F: // rounds to the nearest float, so large ints lose precision
+		return stack.Push(float64(float32(int32(value.(int64)))))
+	case I2D:
+		return stack.PushDouble(float64(int32(value.(int64))))
+	case L2I: // keeps the low 32 bits, which might change the sign
+		return stack.Push(int64(int32(value.(int64))))
+	case L2F:
+		return stack.Push(float64(float32(value.(int64))))
+	case L2D:
+		return stack.PushDouble(float64(value.(int64)))
+	case F2I:
+		return stack.Push(floatToInt(float64(float32(value.(float64)))))
+	case F2L:
+		return stack.PushLong(floatToLong(float64(float32(value.(float64)))))
+	case F2D:
+		return stack.PushDouble(float64(float32(value.(float64))))
+	case D2I:
+		return stack.Push(floatToInt(value.(float64)))
+	case D2L:
+		return stack.PushLong(floatToLong(value.(float64)))
+	case D2F: // too large a double becomes an infinity, too small a one zero
+		return stack.Push(float64(float32(value.(float64))))
+	case I2B: // keeps the low 8 bits, sign-extended
+		return stack.Push(int64(int8(value.(int64))))
+	case I2C: // keeps the low 16 bits, zero-extended, since chars are unsigned
+		return stack.Push(int64(uint16(value.(int64))))
+	case I2S: // keeps the low 16 bits, sign-extended
+		return stack.Push(int64(int16(value.(int64))))
+	}
+	return nil
+}
+
+// floatToInt converts a float or double to an int as f2i and d2i do: rounding toward
+// zero, with NaN becoming 0 and values beyond the range of an int becoming the closest
+// int, Integer.MIN_VALUE or Integer.MAX_VALUE. (Go leaves such conversions undefined.)
+func floatToInt(value float64) int64 {
+	switch {
+	case math.IsNaN(value):
+		return 0
+	case value >= math.MaxInt32:
+		return math.MaxInt32
+	case value <= math.MinInt32:
+		return math.MinInt32
+	default:
+		return int64(int32(value))
+	}
+}
+
+// floatToLong converts a float or double to a long as f2l and d2l do, with the same
+// rules as floatToInt(), but with the range of a long
+func floatToLong(value float64) int64 {
+	switch {
+	case math.IsNaN(value):
+		return 0
+	case value >= 1<<63:
+		return math.MaxInt64
+	case value <= -1<<63:
+		return math.MinInt64
+	default:
+		return int64(value)
+	}
+}
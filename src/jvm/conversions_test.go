@@ -0,0 +1,94 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"math"
+	"testing"
+)
+
+// the slots a value of each type takes up on the operand stack
+var slotsOfType = map[byte]int{'I': 1, 'F': 1, 'J': 2, 'D': 2}
+
+func TestExecConvert(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	tests := []struct {
+		opcode   byte
+		from, to byte // the types converted from and to, as in descriptors
+		value    interface{}
+		expected interface{}
+	}{
+		{I2L, 'I', 'J', int64(math.MaxInt32), int64(math.MaxInt32)},
+		{I2L, 'I', 'J', int64(-1), int64(-1)},
+		{I2F, 'I', 'F', int64(math.MaxInt32), float64(1 << 31)}, // rounded to the nearest float
+		{I2F, 'I', 'F', int64(16777217), float64(16777216)},
+		{I2D, 'I', 'D', int64(math.MinInt32), float64(math.MinInt32)},
+		{L2I, 'J', 'I', int64(math.MaxInt64), int64(-1)},
+		{L2I, 'J', 'I', int64(1 << 31), int64(math.MinInt32)},
+		{L2F, 'J', 'F', int64(math.MaxInt64), float64(1 << 63)},
+		{L2D, 'J', 'D', int64(1<<53 + 1), float64(1 << 53)},
+		{F2I, 'F', 'I', -2.9, int64(-2)}, // rounds toward zero
+		{F2I, 'F', 'I', nan, int64(0)},
+		{F2I, 'F', 'I', inf, int64(math.MaxInt32)},
+		{F2I, 'F', 'I', -1e20, int64(math.MinInt32)},
+		{F2L, 'F', 'J', 2.9, int64(2)},
+		{F2L, 'F', 'J', -inf, int64(math.MinInt64)},
+		{F2L, 'F', 'J', 1e20, int64(math.MaxInt64)},
+		{F2D, 'F', 'D', 0.1, float64(float32(0.1))}, // the float's value, not the closest double
+		{F2D, 'F', 'D', nan, nan},
+		{D2I, 'D', 'I', 2147483647.9, int64(math.MaxInt32)},
+		{D2I, 'D', 'I', 2147483648.5, int64(math.MaxInt32)},
+		{D2I, 'D', 'I', -2147483648.9, int64(math.MinInt32)},
+		{D2I, 'D', 'I', nan, int64(0)},
+		{D2L, 'D', 'J', -2.9, int64(-2)},
+		{D2L, 'D', 'J', 1e19, int64(math.MaxInt64)},
+		{D2L, 'D', 'J', -1e19, int64(math.MinInt64)},
+		{D2L, 'D', 'J', nan, int64(0)},
+		{D2F, 'D', 'F', 1e300, inf},
+		{D2F, 'D', 'F', 1e-300, 0.0},
+		{D2F, 'D', 'F', 2.5, 2.5},
+		{I2B, 'I', 'I', int64(2100), int64(52)},
+		{I2B, 'I', 'I', int64(128), int64(-128)}, // sign-extended
+		{I2B, 'I', 'I', int64(-129), int64(127)},
+		{I2C, 'I', 'I', int64(-1), int64(0xFFFF)}, // zero-extended
+		{I2C, 'I', 'I', int64(0x10041), int64('A')},
+		{I2S, 'I', 'I', int64(32768), int64(math.MinInt16)}, // sign-extended
+		{I2S, 'I', 'I', int64(-32769), int64(math.MaxInt16)},
+	}
+
+	for _, test := range tests {
+		f := newFrame(test.opcode)
+		for i := 0; i < slotsOfType[test.from]; i++ {
+			push(&f, test.value)
+		}
+		if err := execConvert(test.opcode, f.Operands); err != nil {
+			t.Errorf("%s %v: unexpected error: %s", BytecodeNames[test.opcode], test.value, err.Error())
+			continue
+		}
+		if f.Operands.Depth() != slotsOfType[test.to] {
+			t.Errorf("%s %v: expected the result to take up %d slot(s), got a TOS of: %d",
+				BytecodeNames[test.opcode], test.value, slotsOfType[test.to], f.Operands.Depth()-1)
+			continue
+		}
+
+		result := pop(&f)
+		same := result == test.expected
+		if expected, ok := test.expected.(float64); ok {
+			same = sameFloat(result.(float64), expected)
+		}
+		if !same {
+			t.Errorf("%s %v: expected %v, got: %v", BytecodeNames[test.opcode], test.value, test.expected, result)
+		}
+	}
+}
+
+func TestExecConvertNotAConversion(t *testing.T) {
+	f := newFrame(IADD)
+	if err := execConvert(IADD, f.Operands); err == nil {
+		t.Error("Expected an error for IADD, which is not a type-conversion instruction")
+	}
+}
@@ -7,7 +7,6 @@
 package jvm
 
 import (
-	"container/list"
 	"errors"
 	"jacobin/classloader"
 	"jacobin/frames"
@@ -24,20 +23,25 @@ import (
 // by run() on the operand stack of the calling function.
 func runGframe(fr *frames.Frame) (interface{}, int, error) {
 	// get the go method from the MTable
-	me := classloader.MTable[fr.MethName]
+	me, _ := classloader.FetchMTableEntry(fr.MethName)
 	if me.Meth == nil {
 		return nil, 0, errors.New("go method not found: " + fr.MethName)
 	}
 
 	// pull arguments for the function off the frame's operand stack and put them in a slice
 	var params = new([]interface{})
-	for _, v := range fr.OpStack {
-		*params = append(*params, v)
+	for _, v := range fr.Operands.Slots() {
+		*params = append(*params, v.Value())
 	}
 
 	// call the function passing a pointer to the slice of arguments
 	ret := me.Meth.(classloader.GmEntry).Fu(*params)
 
+	// a go method signals that it has thrown an exception by returning an error
+	if err, isErr := ret.(error); isErr {
+		return nil, 0, err
+	}
+
 	// how many slots does the return value consume on the op stack?
 	// the last char in the method name indicates the data type of the return
 	// value. If it's 'J' (a long) or 'D' (a double), it will require two
@@ -60,7 +64,7 @@ func runGframe(fr *frames.Frame) (interface{}, int, error) {
 // its stack, pushes the frame onto the head of the frame stack and then calls run() to
 // execute it. This eventually calls runGFrame(), which handles any return value. After
 // the function is run, this method pops the frame off the frame stack and returns.
-func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, methodType string) (*frames.Frame, error) {
+func runGmethod(mt classloader.MTentry, fs *frames.FrameStack, className, methodName, methodType string) (*frames.Frame, error) {
 	f := fs.Front().Value.(*frames.Frame)
 
 	// create a frame (gf for 'go frame') for this function
@@ -72,21 +76,18 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	gf.ClName = className
 	gf.Meth = nil
 	gf.CP = nil
-	gf.Locals = nil
 	gf.Ftype = 'G' // a golang function
 
 	// get the args (if any) from the operand stack of the current frame(f)
 	// then push them onto the stack of the go function
-	var argList []int64
+	// (doubles and floats are passed as float64s, everything else as int64s)
+	var argList []frames.StackValue
 	for i := 0; i < paramSlots; i++ {
-		arg := pop(f).(int64)
-		intArg := arg
-		argList = append(argList, intArg)
+		argList = append(argList, f.Operands.PopValue())
 	}
 	for j := len(argList) - 1; j >= 0; j-- {
 		push(gf, argList[j])
 	}
-	gf.TOS = len(gf.OpStack) - 1
 
 	// push this new frame onto the frame stack for this thread
 	fs.PushFront(gf)                     // push the new frame
@@ -95,7 +96,13 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	// then run the frame, which will call run(), which will eventually call runGFrame()
 	err := runFrame(fs)
 	if err != nil {
-		_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		if !isSystemExit(err) {
+			_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		}
+		var throwable *JavaThrowable
+		if errors.As(err, &throwable) {
+			logStackTrace(fs)
+		}
 		return nil, err
 	}
 
@@ -105,3 +112,9 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	f = fs.Front().Value.(*frames.Frame) // point f the head again
 	return f, nil
 }
+
+// isSystemExit reports whether err is the error returned by System.exit()
+func isSystemExit(err error) bool {
+	var exit *classloader.SystemExit
+	return errors.As(err, &exit)
+}
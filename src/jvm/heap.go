@@ -0,0 +1,194 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Jacobin's objects are allocated and collected by Go. The heap here is the accounting
+// that caps how much of Go's memory they may occupy, so that a program that keeps
+// allocating gets an OutOfMemoryError rather than exhausting the memory of the host.
+// It counts both the objects the interpreter instantiates and those that Go methods
+// create, such as strings and arrays. All of them are held in the objects table until
+// a collection (System.gc(), or an emergency collection when the heap is full) finds
+// them unreachable (see classloader.CollectObjects()), so until then, they count
+// against the heap even if they're garbage.
+
+// MaxHeapBytes is the most that live objects may occupy, in bytes, as estimated by
+// objectSize() and classloader.GoObjectBytes(). Zero, the default, means there's no
+// limit beyond the memory Go can get.
+var MaxHeapBytes int64
+
+// the estimated size of the interpreter's objects that have been allocated and not
+// yet collected
+var heapBytes atomic.Int64
+
+// the OutOfMemoryError that's thrown when the heap is full. It's allocated at startup,
+// as once the heap is full, there might be no room to allocate it.
+var outOfMemoryError int64
+
+func preallocateOutOfMemoryError() {
+	outOfMemoryError = classloader.NewThrowableWithMessage(
+		"java/lang/OutOfMemoryError", "Java heap space")
+}
+
+// heapAlloc reserves size bytes in the heap for a new object. If that would exceed
+// MaxHeapBytes, an emergency collection is run, which frees the space of unreachable
+// objects, and the reservation is retried once. If it still fails, OutOfMemoryError
+// is thrown.
+func heapAlloc(size int64) error {
+	if reserveHeap(size) {
+		return nil
+	}
+	emergencyCollection()
+	if reserveHeap(size) {
+		return nil
+	}
+	return throwOutOfMemoryError()
+}
+
+// heapFree returns the space of a collected object to the heap. It's called by the
+// collection that removes the object from the objects table (see Object.Collected()).
+func heapFree(size int64) {
+	heapBytes.Add(-size)
+}
+
+// returns the estimated size of all the live objects
+func heapInUse() int64 {
+	return heapBytes.Load() + classloader.GoObjectBytes()
+}
+
+func reserveHeap(size int64) bool {
+	for {
+		current := heapBytes.Load()
+		if MaxHeapBytes > 0 && current+classloader.GoObjectBytes()+size > MaxHeapBytes {
+			return false
+		}
+		if heapBytes.CompareAndSwap(current, current+size) {
+			return true
+		}
+	}
+}
+
+// an emergency collection removes the unreachable objects from the objects table, as
+// System.gc() does, which returns their space to the heap
+func emergencyCollection() {
+	logCollection("Allocation Failure", func() {
+		classloader.CollectObjects(gcRoots())
+		runtime.GC()
+	})
+}
+
+// the frame stacks whose frames hold the roots of a collection: the stacks of the
+// threads, and those on which Go methods run Java methods (see invokeMethod())
+var frameStacks = make(map[*frames.FrameStack]bool)
+var frameStacksMutex sync.Mutex
+
+func registerFrameStack(fs *frames.FrameStack) {
+	frameStacksMutex.Lock()
+	frameStacks[fs] = true
+	frameStacksMutex.Unlock()
+}
+
+func unregisterFrameStack(fs *frames.FrameStack) {
+	frameStacksMutex.Lock()
+	delete(frameStacks, fs)
+	frameStacksMutex.Unlock()
+}
+
+// collectGarbage removes the objects that the interpreter can no longer reach from the
+// objects table (see classloader.CollectObjects()), and then runs a Go collection,
+// which collects them.
+// It's run by System.gc() and Runtime.gc(), when all the values that refer to objects
+// are held by the interpreter, rather than by Go methods.
+func collectGarbage() {
+	logCollection("System.gc()", func() {
+		classloader.CollectObjects(gcRoots())
+		runtime.GC()
+	})
+}
+
+// runs the collection, and if -XX:+PrintGC or -XX:+PrintGCDetails was specified, logs a
+// line formatted as an INFO message, whatever the logging level, in the JDK's format, e.g.:
+//
+//	[GC (System.gc()) 1024K->512K(4096K), 0.0012345 secs]
+//
+// The heap sizes are those of the heap accounting, and the capacity is MaxHeapBytes (0
+// when there's no limit). Jacobin's heap has a single generation, so -XX:+PrintGCDetails
+// adds that heap's statistics: the number of live objects and the bytes collected.
+func logCollection(cause string, collect func()) {
+	global := globals.GetGlobalRef()
+	if !global.PrintGC && !global.PrintGCDetails {
+		collect()
+		return
+	}
+
+	before := heapInUse()
+	start := time.Now()
+	collect()
+	elapsed := time.Since(start)
+
+	after := heapInUse()
+	msg := fmt.Sprintf("[GC (%s) %dK->%dK(%dK), %.7f secs]", cause, before/1024,
+		after/1024, MaxHeapBytes/1024, elapsed.Seconds())
+	if global.PrintGCDetails {
+		msg += fmt.Sprintf(" [Heap: %d live objects, %d bytes collected]",
+			classloader.LiveObjectCount(), before-after)
+	}
+	_ = log.LogRequested(msg, log.INFO)
+}
+
+// returns the values on the operand stacks and in the local variables of all frames on
+// the registered frame stacks, and those of the statics, that might be addresses
+func gcRoots() []int64 {
+	roots := []int64{outOfMemoryError}
+	frameStacksMutex.Lock()
+	for fs := range frameStacks {
+		for e := fs.Front(); e != nil; e = e.Next() {
+			f := e.Value.(*frames.Frame)
+			for _, slot := range f.Operands.Slots() {
+				if v, ok := slot.Value().(int64); ok {
+					roots = append(roots, v)
+				}
+			}
+			for _, local := range f.Locals.Slots() {
+				if v, ok := local.Value().(int64); ok {
+					roots = append(roots, v)
+				}
+			}
+		}
+	}
+	frameStacksMutex.Unlock()
+
+	for _, static := range classloader.StaticsArray {
+		roots = append(roots, static.ValueInt)
+	}
+	return roots
+}
+
+// java/lang/System.gc() and java/lang/Runtime.gc() run a collection
+func systemGC([]interface{}) interface{} {
+	collectGarbage()
+	return nil
+}
+
+func throwOutOfMemoryError() error {
+	if outOfMemoryError == 0 { // not yet preallocated, as in tests that don't run StartExec()
+		preallocateOutOfMemoryError()
+	}
+	return throwJavaThrowable(exceptions.OutOfMemoryError, outOfMemoryError)
+}
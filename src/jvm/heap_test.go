@@ -0,0 +1,354 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classbuilder"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// collects the Hello2 objects a test allocated, so that they're not counted by
+// later tests
+func collectHello2s(t *testing.T) {
+	emergencyCollection()
+	if count := hello2Count(t); count != "0" {
+		t.Errorf("Expected the collection to leave no Hello2 objects, got: %s", count)
+	}
+}
+
+// holds the objects at addrs in the local variables of a frame on a registered frame
+// stack, as the interpreter would, so that collections can't make room by collecting
+// them. The returned func lets go of them.
+func holdObjects(addrs []int64) func() {
+	f := frames.NewFrame(0, len(addrs))
+	for i, addr := range addrs {
+		_ = f.Locals.SetRef(i, addr)
+	}
+	fs := frames.CreateFrameStack()
+	_ = frames.PushFrame(fs, f)
+	registerFrameStack(fs)
+	return func() { unregisterFrameStack(fs) }
+}
+
+// with a heap that holds only 10 Hello2 objects, the 11th allocation throws
+// OutOfMemoryError, rather than panicking
+func TestOutOfMemoryErrorWhenHeapIsFull(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	obj, _ := instantiateClass("Hello2")
+	size := objectSize(obj)
+	obj = nil
+	emergencyCollection() // so objects from earlier tests aren't freed mid-test
+
+	MaxHeapBytes = heapInUse() + 10*size
+	defer func() { MaxHeapBytes = 0 }()
+
+	var live []int64
+	release := holdObjects(nil)
+	_ = captureStderr(func() {
+		for i := 0; i < 20 && err == nil; i++ {
+			obj, err = instantiateClass("Hello2")
+			if err == nil {
+				live = append(live, obj.addr)
+				release() // hold the objects allocated so far, so the collection can't make room
+				release = holdObjects(live)
+			}
+		}
+	})
+
+	allocated := len(live)
+	release()
+	collectHello2s(t)
+
+	if allocated != 10 {
+		t.Errorf("Expected 10 objects to be allocated before the heap was full, got: %d", allocated)
+	}
+	var throwable *JavaThrowable
+	if !errors.As(err, &throwable) {
+		t.Fatalf("Expected a JavaThrowable, got: %v", err)
+	}
+	if name := classloader.ObjectClassName(throwable.Ref); name != "java/lang/OutOfMemoryError" {
+		t.Errorf("Expected java/lang/OutOfMemoryError, got: %s", name)
+	}
+	if classloader.ThrowableToString(throwable.Ref) != "java.lang.OutOfMemoryError: Java heap space" {
+		t.Errorf("Expected message 'Java heap space', got: %s",
+			classloader.ThrowableToString(throwable.Ref))
+	}
+}
+
+// once the objects filling the heap are unreachable, the emergency collection frees
+// their space and the allocation succeeds
+func TestEmergencyCollectionMakesRoom(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	obj, _ := instantiateClass("Hello2")
+	size := objectSize(obj)
+	obj = nil
+	emergencyCollection()
+
+	MaxHeapBytes = heapInUse() + 10*size
+	defer func() { MaxHeapBytes = 0 }()
+
+	// none of these objects is kept, so all of them can be collected
+	for i := 0; i < 100; i++ {
+		if _, err = instantiateClass("Hello2"); err != nil {
+			t.Fatalf("Expected the emergency collection to make room, got: %s", err.Error())
+		}
+	}
+	collectHello2s(t)
+}
+
+// System.gc() keeps an object that's reachable only through a field of an interpreter
+// object, and collecting the interpreter object returns its space to the heap
+func TestCollectionTracesObjectFields(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+	collectHello2s(t)
+
+	obj, _ := instantiateClass("Hello2")
+	allocated := heapBytes.Load()
+	str := classloader.NewStringObject("held by a field")
+	obj.fields = append(obj.fields, Field{value: str})
+
+	release := holdObjects([]int64{obj.addr})
+	collectGarbage()
+	if classloader.ObjectAt(str) == nil {
+		t.Errorf("Expected the String held by the object's field to survive System.gc()")
+	}
+
+	release()
+	obj = nil
+	collectGarbage()
+	if classloader.ObjectAt(str) != nil {
+		t.Errorf("Expected the String to be collected once the object that held it was")
+	}
+	if after := heapBytes.Load(); after >= allocated {
+		t.Errorf("Expected the collection to return the object's space to the heap, got %d bytes in use, was %d",
+			after, allocated)
+	}
+	collectHello2s(t)
+}
+
+// returns the bytes of class RefTest, whose static methods create a WeakReference to a
+// String from bytecode, run System.gc(), and then return:
+//
+//	cleared(): the reference polled from its queue, once the String is no longer held
+//	held():    the reference's referent, which is still held in a local
+func refTestClass(t *testing.T) []byte {
+	const queueClass, weakClass = "java/lang/ref/ReferenceQueue", "java/lang/ref/WeakReference"
+	cb := classbuilder.NewClassBuilder("RefTest")
+	for _, method := range []string{"cleared", "held"} {
+		cb.AddMethod(method, "()Ljava/lang/Object;").MaxStack(4).MaxLocals(3).
+			AddOpcode(NEW, queueClass).AddOpcode(DUP).
+			AddOpcode(INVOKESPECIAL, queueClass, "<init>", "()V").AddOpcode(ASTORE_0).
+			AddOpcode(ICONST_5).
+			AddOpcode(INVOKESTATIC, "java/lang/Integer", "toHexString", "(I)Ljava/lang/String;").
+			AddOpcode(ASTORE_1).
+			AddOpcode(NEW, weakClass).AddOpcode(DUP).AddOpcode(ALOAD_1).AddOpcode(ALOAD_0).
+			AddOpcode(INVOKESPECIAL, weakClass, "<init>", "(Ljava/lang/Object;Ljava/lang/ref/ReferenceQueue;)V").
+			AddOpcode(ASTORE_2)
+		if method == "cleared" {
+			cb.AddOpcode(ACONST_NULL).AddOpcode(ASTORE_1). // the String is now reachable only through the reference
+									AddOpcode(INVOKESTATIC, "java/lang/System", "gc", "()V").
+									AddOpcode(ALOAD_0).AddOpcode(LDC2_W, int64(5000)).
+									AddOpcode(INVOKEVIRTUAL, queueClass, "remove", "(J)Ljava/lang/ref/Reference;").
+									AddOpcode(ARETURN)
+		} else {
+			cb.AddOpcode(INVOKESTATIC, "java/lang/System", "gc", "()V").
+				AddOpcode(ALOAD_2).
+				AddOpcode(INVOKEVIRTUAL, weakClass, "get", "()Ljava/lang/Object;").
+				AddOpcode(ARETURN)
+		}
+	}
+	bytes, err := cb.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building RefTest: %s", err.Error())
+	}
+	return bytes
+}
+
+// a WeakReference created by bytecode is cleared and enqueued by System.gc() once its
+// referent is no longer held, and not before
+func TestWeakReferenceFromBytecode(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+	classloader.AddGoMethods(jvmGoMethods())
+	if _, err := classloader.ParseAndPostClass(classloader.AppCL, "RefTest.class", refTestClass(t)); err != nil {
+		t.Fatalf("Unexpected error loading RefTest: %s", err.Error())
+	}
+
+	held, err := invokeMethod("RefTest", "held", "()Ljava/lang/Object;", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error running RefTest.held(): %s", err.Error())
+	}
+	if str := held.(int64); str == 0 || classloader.GoStringFromAddr(str) != "5" {
+		t.Errorf("Expected the referent that's still held to be returned, got: %v", held)
+	}
+
+	cleared, err := invokeMethod("RefTest", "cleared", "()Ljava/lang/Object;", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error running RefTest.cleared(): %s", err.Error())
+	}
+	ref := cleared.(int64)
+	if name := classloader.ObjectClassName(ref); name != "java/lang/ref/WeakReference" {
+		t.Fatalf("Expected the WeakReference to be enqueued, got: %v (%s)", ref, name)
+	}
+	if got, _ := invokeMethod("java/lang/ref/WeakReference", "get", "()Ljava/lang/Object;",
+		[]interface{}{ref}); got != int64(0) {
+		t.Errorf("Expected the enqueued WeakReference to be cleared, got: %v", got)
+	}
+}
+
+// a WeakReference to an interpreter object isn't cleared while the program holds the
+// object, however often Go collects, and is cleared by the first collection after
+func TestWeakReferenceToInterpreterObject(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	const weakClass = "java/lang/ref/WeakReference"
+	obj, _ := instantiateClass("Hello2")
+	ref := classloader.NewReferenceObject(weakClass)
+	if _, err = invokeMethod(weakClass, "<init>", "(Ljava/lang/Object;)V",
+		[]interface{}{ref, obj.addr}); err != nil {
+		t.Fatalf("Unexpected error constructing the WeakReference: %s", err.Error())
+	}
+	referent := obj.addr
+	obj = nil
+
+	release := holdObjects([]int64{ref, referent})
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		collectGarbage()
+	}
+	if got, _ := invokeMethod(weakClass, "get", "()Ljava/lang/Object;", []interface{}{ref}); got != referent {
+		t.Errorf("Expected the WeakReference to the held object not to be cleared, got: %v", got)
+	}
+
+	release()
+	release = holdObjects([]int64{ref})
+	defer release()
+	collectGarbage()
+	if got, _ := invokeMethod(weakClass, "get", "()Ljava/lang/Object;", []interface{}{ref}); got != int64(0) {
+		t.Errorf("Expected the WeakReference to be cleared once the object isn't held, got: %v", got)
+	}
+}
+
+// -XX:+PrintGC logs a line for each collection, and -XX:+PrintGCDetails adds the
+// heap's statistics to it. With a small heap, allocating garbage fills it, which
+// triggers an emergency collection that frees the garbage.
+func TestPrintGCLogsCollections(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	if out := captureStderr(collectGarbage); out != "" {
+		t.Errorf("Expected no output without -XX:+PrintGC, got: %s", out)
+	}
+
+	// an array that stays live, so that the heap isn't empty after a collection
+	release := holdObjects([]int64{classloader.NewArrayObject(classloader.T_INT, 1024)})
+	defer release()
+	obj, _ := instantiateClass("Hello2")
+	size := objectSize(obj)
+	MaxHeapBytes = heapInUse() + 64*1024
+	defer func() { MaxHeapBytes = 0 }()
+
+	// allocates enough garbage Hello2 objects to fill the heap twice over
+	allocate := func() {
+		for i := int64(0); i < 2*64*1024/size; i++ {
+			if _, err := instantiateClass("Hello2"); err != nil {
+				t.Errorf("Unexpected error allocating a Hello2 object: %s", err.Error())
+				return
+			}
+		}
+	}
+
+	// the line is logged whatever the logging level, which the option leaves as it is
+	globals.GetGlobalRef().PrintGC = true
+	defer func() {
+		globals.GetGlobalRef().PrintGC = false
+		globals.GetGlobalRef().PrintGCDetails = false
+	}()
+	out := captureStderr(allocate)
+	if log.Level != log.WARNING {
+		t.Errorf("Expected the logging level to stay WARNING, got: %d", log.Level)
+	}
+	if !strings.Contains(out, "[GC (Allocation Failure) ") {
+		t.Fatalf("Expected a [GC ...] line for the collection of a full heap, got: %s", out)
+	}
+	if strings.Contains(out, "[Heap: ") {
+		t.Errorf("Expected no heap details without -XX:+PrintGCDetails, got: %s", out)
+	}
+	var before, after, capacity int64
+	var secs float64
+	line := out[strings.Index(out, "[GC"):]
+	if _, err := fmt.Sscanf(line, "[GC (Allocation Failure) %dK->%dK(%dK), %f secs]",
+		&before, &after, &capacity, &secs); err != nil {
+		t.Fatalf("Unexpected format of the [GC ...] line: %s (%s)", line, err.Error())
+	}
+	if after <= 0 || before <= after || capacity != MaxHeapBytes/1024 {
+		t.Errorf("Expected a positive heap size, smaller after the collection, got: %s", line)
+	}
+
+	globals.GetGlobalRef().PrintGC = false
+	globals.GetGlobalRef().PrintGCDetails = true
+	out = captureStderr(allocate)
+	if !strings.Contains(out, "[GC (Allocation Failure) ") {
+		t.Fatalf("Expected a detailed [GC ...] line for the collection of a full heap, got: %s", out)
+	}
+	var live, collected int64
+	line = out[strings.Index(out, "[GC"):]
+	if _, err := fmt.Sscanf(line, "[GC (Allocation Failure) %dK->%dK(%dK), %f secs] [Heap: %d live objects, %d bytes collected]",
+		&before, &after, &capacity, &secs, &live, &collected); err != nil {
+		t.Fatalf("Unexpected format of the [GC ...] line: %s (%s)", line, err.Error())
+	}
+	if live <= 0 || collected <= 0 || after >= before {
+		t.Errorf("Expected live objects, bytes collected, and a smaller heap after the collection, got: %s", line)
+	}
+
+	globals.GetGlobalRef().PrintGCDetails = false
+	collectHello2s(t)
+}
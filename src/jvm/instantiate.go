@@ -7,15 +7,19 @@
 package jvm
 
 import (
+	"errors"
 	"fmt"
 	"jacobin/classloader"
 	"jacobin/log"
+	"jacobin/management"
 	"os"
+	"unsafe"
 )
 
 // Object is the layout of the data fields of an object. It's explained in more detail
 // in the comments for initializeField()
 type Object struct {
+	addr   int64 // the object's address in the objects table, which the interpreter pushes for it
 	klass  classloader.Klass
 	mark   *MarkWord
 	fields []Field
@@ -37,18 +41,26 @@ type Field struct {
 
 func instantiateClass(classname string) (*Object, error) {
 	_ = log.Log("Instantiating class: "+classname, log.FINE)
-recheck:
-	k, present := classloader.Classes[classname] // TODO: Put a mutex around this the same one used for writing.
-	if k.Status == 'I' {                         // the class is being loaded
-		goto recheck // recheck the status until it changes (i.e., until the class is loaded)
-	} else if !present { // the class has not yet been loaded
-		if classloader.LoadClassFromNameOnly(classname) != nil {
-			_ = log.Log("Error loading class: "+classname+". Exiting.", log.SEVERE)
+	k, present := classloader.LookupClass(classname)
+	if !present || k.Status == 'I' || k.Status == 'E' { // the class is not yet loaded (or its load failed)
+		// if another goroutine is loading the class, this waits for it to finish
+		// a class that's its own superclass, or that extends a sealed class that doesn't
+		// permit it to, fails to link rather than to load
+		err := classloader.LoadClassFromNameOnly(classname)
+		var circularity *classloader.ClassCircularityError
+		var incompatible *classloader.IncompatibleClassChangeError
+		switch {
+		case errors.As(err, &circularity):
+			return nil, throwClassCircularityError(circularity.Class)
+		case errors.As(err, &incompatible):
+			return nil, throwIncompatibleClassChangeError(incompatible.Msg)
+		case err != nil:
+			return nil, throwNoClassDefFoundError(classname)
 		}
 	}
 
 	// at this point the class has been loaded into the method area (Classes).
-	k, _ = classloader.Classes[classname]
+	k, _ = classloader.LookupClass(classname)
 
 	obj := Object{
 		klass:  k,
@@ -62,9 +74,44 @@ recheck:
 			initializeField(f, &k.Data.CP, classname, &obj)
 		}
 	}
+
+	// reserve the object's space in the heap and record it in the heap counts. Both
+	// are released when a collection removes the object (see Collected()).
+	size := objectSize(&obj)
+	if err := heapAlloc(size); err != nil {
+		return nil, err
+	}
+	management.RecordAlloc(classname, size)
+	obj.addr = classloader.AddInterpreterObject(&obj)
+	classloader.AdvanceClassStatus(classname, 'N') // so it's not unloaded while it has instances
 	return &obj, nil
 }
 
+// HeldAddresses returns the values of the object's fields that might be addresses of
+// other objects, which keep those objects reachable (see classloader.CollectObjects())
+func (obj *Object) HeldAddresses() []int64 {
+	var addrs []int64
+	for _, f := range obj.fields {
+		if v, ok := f.value.(int64); ok {
+			addrs = append(addrs, v)
+		}
+	}
+	return addrs
+}
+
+// Collected returns the space of an object that a collection has removed to the heap,
+// and removes the object from the heap counts
+func (obj *Object) Collected() {
+	size := objectSize(obj)
+	heapFree(size)
+	management.RecordFree(obj.klass.Data.Name, size)
+}
+
+// objectSize estimates the number of bytes an object occupies: its header plus its fields
+func objectSize(obj *Object) int64 {
+	return int64(unsafe.Sizeof(*obj)) + int64(len(obj.fields))*int64(unsafe.Sizeof(Field{}))
+}
+
 // the only fields allocated during class instantiation are instance fields--
 // method-local fields are created on the stack during method execution.
 // The allocated fields are in a structure that starts with a header area containing fields
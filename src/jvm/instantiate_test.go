@@ -0,0 +1,176 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classbuilder"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/management"
+	"testing"
+)
+
+// returns the "heap" provider's count of live Hello2 objects
+func hello2Count(t *testing.T) string {
+	heap, _ := management.Provider("heap")
+	detail, err := heap.Detail("Hello2")
+	if err != nil {
+		t.Fatalf("Unexpected error from heap provider: %s", err.Error())
+	}
+	return detail["count"]
+}
+
+// objects are counted by the heap provider when they're instantiated and no longer
+// counted once they've been garbage collected
+func TestHeapProviderCountsInstances(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := instantiateClass("Hello2"); err != nil {
+			t.Fatalf("Unexpected error instantiating Hello2: %s", err.Error())
+		}
+	}
+
+	heap, ok := management.Provider("heap")
+	if !ok {
+		t.Fatalf("Expected a heap InstrumentationProvider")
+	}
+	found := false
+	for _, entry := range heap.List() {
+		if entry.Key == "Hello2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected Hello2 in the heap provider's list, got: %v", heap.List())
+	}
+	if count := hello2Count(t); count != "10" {
+		t.Errorf("Expected 10 Hello2 objects, got: %s", count)
+	}
+
+	collectHello2s(t)
+	if count := hello2Count(t); count != "0" {
+		t.Errorf("Expected 0 Hello2 objects after GC, got: %s", count)
+	}
+}
+
+// a class that has been instantiated can't be unloaded
+func TestInstantiatedClassIsNotUnloaded(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	if _, err = instantiateClass("Hello2"); err != nil {
+		t.Fatalf("Unexpected error instantiating Hello2: %s", err.Error())
+	}
+	if k, _ := classloader.LookupClass("Hello2"); k.Status != 'N' {
+		t.Errorf("Expected Hello2 to be marked as instantiated, got status: %c", k.Status)
+	}
+
+	var inUse *classloader.ClassInUseError
+	if err = classloader.Unload("Hello2"); !errors.As(err, &inUse) {
+		t.Errorf("Expected a ClassInUseError unloading Hello2, got: %v", err)
+	}
+}
+
+// new String(char[]) from bytecode: the new bytecode creates a String, which the Go
+// constructor String(char[]) then initializes
+func TestNewStringFromChars(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	bytes, err := classbuilder.NewClassBuilder("NewString").
+		AddMethod("make", "()Ljava/lang/String;").MaxStack(3).MaxLocals(1).
+		AddOpcode(LDC, "h\u00e9llo").
+		AddOpcode(INVOKEVIRTUAL, "java/lang/String", "toCharArray", "()[C").AddOpcode(ASTORE_0).
+		AddOpcode(NEW, "java/lang/String").AddOpcode(DUP).AddOpcode(ALOAD_0).
+		AddOpcode(INVOKESPECIAL, "java/lang/String", "<init>", "([C)V").
+		AddOpcode(ARETURN).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building NewString: %s", err.Error())
+	}
+	if _, err = classloader.ParseAndPostClass(classloader.AppCL, "NewString.class", bytes); err != nil {
+		t.Fatalf("Unexpected error loading NewString: %s", err.Error())
+	}
+
+	ret, err := invokeMethod("NewString", "make", "()Ljava/lang/String;", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error running NewString.make(): %s", err.Error())
+	}
+	if str := classloader.GoStringFromAddr(ret.(int64)); str != "h\u00e9llo" {
+		t.Errorf("Expected new String(char[]) to hold %q, got: %q", "h\u00e9llo", str)
+	}
+	if name := classloader.ObjectClassName(ret.(int64)); name != "java/lang/String" {
+		t.Errorf("Expected a java/lang/String, got: %s", name)
+	}
+}
+
+// new RuntimeException("oops").getMessage() from bytecode: the new bytecode creates a
+// Throwable, whose constructor chain ends in the Go constructor Throwable(String), and
+// getMessage() is the Go method inherited from Throwable. So that the test doesn't
+// need a JDK, RuntimeException and Exception are stand-ins whose constructors, like
+// the JDK's, pass the message on to their superclass's constructor.
+func TestNewRuntimeExceptionGetMessage(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	for _, class := range []struct{ name, super string }{
+		{"java/lang/Exception", "java/lang/Throwable"},
+		{"java/lang/RuntimeException", "java/lang/Exception"},
+	} {
+		bytes, err := classbuilder.NewClassBuilder(class.name).SuperClass(class.super).
+			AddMethod("<init>", "(Ljava/lang/String;)V").MethodAccessFlags(0x0001).
+			AddOpcode(ALOAD_0).AddOpcode(ALOAD_1).
+			AddOpcode(INVOKESPECIAL, class.super, "<init>", "(Ljava/lang/String;)V").
+			AddOpcode(RETURN).Build()
+		if err != nil {
+			t.Fatalf("Unexpected error building %s: %s", class.name, err.Error())
+		}
+		if _, err = classloader.ParseAndPostClass(classloader.BootstrapCL, class.name+".class", bytes); err != nil {
+			t.Fatalf("Unexpected error loading %s: %s", class.name, err.Error())
+		}
+	}
+
+	bytes, err := classbuilder.NewClassBuilder("NewException").
+		AddMethod("message", "()Ljava/lang/String;").MaxStack(3).MaxLocals(0).
+		AddOpcode(NEW, "java/lang/RuntimeException").AddOpcode(DUP).AddOpcode(LDC, "oops").
+		AddOpcode(INVOKESPECIAL, "java/lang/RuntimeException", "<init>", "(Ljava/lang/String;)V").
+		AddOpcode(INVOKEVIRTUAL, "java/lang/RuntimeException", "getMessage", "()Ljava/lang/String;").
+		AddOpcode(ARETURN).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building NewException: %s", err.Error())
+	}
+	if _, err = classloader.ParseAndPostClass(classloader.AppCL, "NewException.class", bytes); err != nil {
+		t.Fatalf("Unexpected error loading NewException: %s", err.Error())
+	}
+
+	ret, err := invokeMethod("NewException", "message", "()Ljava/lang/String;", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error running NewException.message(): %s", err.Error())
+	}
+	if msg, ok := ret.(int64); !ok || classloader.GoStringFromAddr(msg) != "oops" {
+		t.Errorf("Expected getMessage() to return %q, got: %v", "oops", ret)
+	}
+}
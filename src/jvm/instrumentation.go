@@ -0,0 +1,86 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classloader"
+	"sync"
+)
+
+// ClassTransformer rewrites the bytes of a class, as a Java agent's
+// java.lang.instrument.ClassFileTransformer does. It returns the transformed bytes,
+// or the bytes it was given if it makes no change to the class.
+type ClassTransformer interface {
+	Transform(className string, classBytes []byte) ([]byte, error)
+}
+
+// VMInstrumentation is the Instrumentation used by the attach endpoint
+var VMInstrumentation Instrumentation
+
+// Instrumentation is the counterpart of java.lang.instrument.Instrumentation, which
+// is passed to Java agents. At present, it supports only the retransformation and
+// redefinition of loaded classes.
+type Instrumentation struct {
+	transformers []ClassTransformer
+	mutex        sync.Mutex
+}
+
+// AddTransformer registers a transformer. Transformers are run in the order they
+// were added.
+func (inst *Instrumentation) AddTransformer(t ClassTransformer) {
+	inst.mutex.Lock()
+	inst.transformers = append(inst.transformers, t)
+	inst.mutex.Unlock()
+}
+
+// Retransform runs the bytes the class was originally loaded from through all the
+// registered transformers, then replaces the loaded class with the result. Methods
+// of the class that are executing complete with the old bytecode.
+func (inst *Instrumentation) Retransform(className string) error {
+	classBytes, ok := classloader.OriginalClassBytes(className)
+	if !ok {
+		return errors.New("cannot retransform class " + className +
+			", which has not been loaded or can't be redefined")
+	}
+
+	transformed, err := inst.transform(className, classBytes)
+	if err != nil {
+		return err
+	}
+	return classloader.ReplaceClass(className, transformed)
+}
+
+// Redefine replaces a loaded class with a new definition, classBytes, which is run
+// through the registered transformers. Later retransformations start from classBytes.
+// As with Retransform(), methods that are executing complete with the old bytecode.
+func (inst *Instrumentation) Redefine(className string, classBytes []byte) error {
+	transformed, err := inst.transform(className, classBytes)
+	if err != nil {
+		return err
+	}
+	if err = classloader.ReplaceClass(className, transformed); err != nil {
+		return err
+	}
+	classloader.SetOriginalClassBytes(className, classBytes)
+	return nil
+}
+
+// runs the bytes of a class through all the registered transformers
+func (inst *Instrumentation) transform(className string, classBytes []byte) ([]byte, error) {
+	inst.mutex.Lock()
+	transformers := append([]ClassTransformer(nil), inst.transformers...)
+	inst.mutex.Unlock()
+
+	var err error
+	for _, t := range transformers {
+		if classBytes, err = t.Transform(className, classBytes); err != nil {
+			return nil, err
+		}
+	}
+	return classBytes, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"strings"
+	"testing"
+)
+
+// prefixTransformer adds a prefix to the UTF-8 entries of the CP that hold a
+// class's source file name
+type prefixTransformer struct {
+	prefix string
+}
+
+func (p prefixTransformer) Transform(className string, in []byte) ([]byte, error) {
+	// the sizes of the CP entries, other than UTF-8 entries, by tag
+	sizes := map[byte]int{3: 4, 4: 4, 5: 8, 6: 8, 7: 2, 8: 2, 9: 4, 10: 4, 11: 4,
+		12: 4, 15: 3, 16: 2, 17: 4, 18: 4, 19: 2, 20: 2}
+
+	out := append([]byte(nil), in[:10]...) // magic number, versions, and CP count
+	cpCount := int(in[8])<<8 | int(in[9])
+	pos := 10
+	for i := 1; i < cpCount; i++ {
+		tag := in[pos]
+		if tag != classloader.UTF8 {
+			out = append(out, in[pos:pos+1+sizes[tag]]...)
+			pos += 1 + sizes[tag]
+			if tag == classloader.LongConst || tag == classloader.DoubleConst {
+				i++ // longs and doubles take two CP slots
+			}
+			continue
+		}
+
+		length := int(in[pos+1])<<8 | int(in[pos+2])
+		content := string(in[pos+3 : pos+3+length])
+		if strings.HasSuffix(content, ".java") {
+			content = p.prefix + content
+		}
+		out = append(out, tag, byte(len(content)>>8), byte(len(content)))
+		out = append(out, content...)
+		pos += 3 + length
+	}
+	return append(out, in[pos:]...), nil
+}
+
+func TestRetransform(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.Classes.Delete("Hello2") // which other tests define in the bootstrap classloader
+	_, err := classloader.ParseAndPostClass(classloader.AppCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+	k, _ := classloader.LookupClass("Hello2")
+	original := k.Data
+
+	inst := Instrumentation{}
+	inst.AddTransformer(prefixTransformer{prefix: "Transformed"})
+	if err = inst.Retransform("Hello2"); err != nil {
+		t.Fatalf("Unexpected error retransforming Hello2: %s", err.Error())
+	}
+
+	k, _ = classloader.LookupClass("Hello2")
+	if !strings.HasPrefix(k.Data.SourceFile, "Transformed") {
+		t.Errorf("Expected the source file to start with 'Transformed', got: %s", k.Data.SourceFile)
+	}
+	if k.Loader != "app" {
+		t.Errorf("Expected Hello2 to stay in the application classloader, got: %s", k.Loader)
+	}
+	if original.SourceFile != "Hello2.java" {
+		t.Errorf("Expected the original class data to be unchanged, got source file: %s",
+			original.SourceFile)
+	}
+
+	// retransformation starts from the original bytes, so the prefix isn't added twice
+	if err = inst.Retransform("Hello2"); err != nil {
+		t.Fatalf("Unexpected error retransforming Hello2 again: %s", err.Error())
+	}
+	if k, _ = classloader.LookupClass("Hello2"); k.Data.SourceFile != "TransformedHello2.java" {
+		t.Errorf("Expected source file TransformedHello2.java, got: %s", k.Data.SourceFile)
+	}
+}
+
+func TestRetransformClassNotLoaded(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	inst := Instrumentation{}
+	if err := inst.Retransform("NoSuchClass"); err == nil {
+		t.Errorf("Expected an error retransforming a class that was never loaded, got none")
+	}
+}
+
+// the bytes of the bootstrap classloader's classes aren't kept, so they can't be
+// retransformed
+func TestRetransformBootstrapClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	if _, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes); err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+
+	if _, ok := classloader.OriginalClassBytes("Hello2"); ok {
+		t.Error("Expected the bytes of a bootstrap class not to be kept")
+	}
+	inst := Instrumentation{}
+	if err := inst.Retransform("Hello2"); err == nil {
+		t.Error("Expected an error retransforming a bootstrap class, got none")
+	}
+}
@@ -0,0 +1,422 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"jacobin/classbuilder"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/shutdown"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// End-to-end tests that start the JVM with JVMrun(), just as main() does, and
+// check what the executed program writes to stdout. These exercise the classloader,
+// the interpreter, and the native methods together.
+
+// Hello2's main() prints the results of addTwo() in a loop. The class (in Hello2Bytes)
+// is written to a temporary .class file, which is then run as the starting class.
+func TestHello2Integration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	classFile := filepath.Join(t.TempDir(), "Hello2.class")
+	if err := os.WriteFile(classFile, Hello2Bytes, 0644); err != nil {
+		t.Fatalf("Unable to write temporary class file: %s", err.Error())
+	}
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	globals.GetGlobalRef().StartingClass = classFile
+
+	// JVMrun() processes the command line, so make sure it doesn't see the test flags
+	normalArgs := os.Args
+	os.Args = []string{"jacobin"}
+	defer func() { os.Args = normalArgs }()
+
+	// redirect stderr & stdout to capture the program's output
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	normalStdout := os.Stdout
+	rout, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	exitStatus := JVMrun()
+
+	_ = w.Close()
+	errMsg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	_ = wout.Close()
+	msgOut, _ := io.ReadAll(rout)
+	os.Stdout = normalStdout
+
+	if exitStatus != 0 {
+		t.Errorf("Expected JVMrun() to return 0, got: %d. Stderr: %s", exitStatus, string(errMsg))
+	}
+
+	// addTwo(i, i-1) for i = 0..9
+	expected := "-1\n1\n3\n5\n7\n9\n11\n13\n15\n17\n"
+	if !strings.Contains(string(msgOut), expected) {
+		t.Errorf("Expected output of Hello2 to be:\n%s got:\n%s", expected, string(msgOut))
+	}
+	// Hello2 runs without any warnings or errors, such as a class that can't be loaded
+	if len(errMsg) != 0 {
+		t.Errorf("Expected nothing written to stderr, got: %s", string(errMsg))
+	}
+}
+
+// ExitTest's main() calls System.exit(42). If execution continued past that call, the
+// following System.exit(0) would make JVMrun() return 0.
+func TestSystemExitIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	classBytes, err := classbuilder.NewClassBuilder("ExitTest").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(BIPUSH, 42).
+		AddOpcode(INVOKESTATIC, "java/lang/System", "exit", "(I)V").
+		AddOpcode(ICONST_0).
+		AddOpcode(INVOKESTATIC, "java/lang/System", "exit", "(I)V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building ExitTest: %s", err.Error())
+	}
+	classFile := filepath.Join(t.TempDir(), "ExitTest.class")
+	if err = os.WriteFile(classFile, classBytes, 0644); err != nil {
+		t.Fatalf("Unable to write temporary class file: %s", err.Error())
+	}
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	globals.GetGlobalRef().StartingClass = classFile
+
+	hookRan := false
+	shutdown.AddShutdownHook(func() { hookRan = true })
+
+	// JVMrun() processes the command line, so make sure it doesn't see the test flags
+	normalArgs := os.Args
+	os.Args = []string{"jacobin"}
+	defer func() { os.Args = normalArgs }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	exitStatus := JVMrun()
+
+	_ = w.Close()
+	errMsg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if exitStatus != 42 {
+		t.Errorf("Expected JVMrun() to return 42, got: %d. Stderr: %s", exitStatus, string(errMsg))
+	}
+	if !hookRan {
+		t.Error("Expected the shutdown hook to run before the JVM exited")
+	}
+}
+
+// runs com.example.Main, found on the classpath given by -cp. Its main() calls
+// System.exit(7), so that the test can tell it ran.
+func TestClasspathIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	classBytes, err := classbuilder.NewClassBuilder("com/example/Main").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(BIPUSH, 7).
+		AddOpcode(INVOKESTATIC, "java/lang/System", "exit", "(I)V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/Main: %s", err.Error())
+	}
+	classDir := t.TempDir()
+	if err = os.MkdirAll(filepath.Join(classDir, "com", "example"), 0755); err != nil {
+		t.Fatalf("Unable to create package directory: %s", err.Error())
+	}
+	err = os.WriteFile(filepath.Join(classDir, "com", "example", "Main.class"), classBytes, 0644)
+	if err != nil {
+		t.Fatalf("Unable to write temporary class file: %s", err.Error())
+	}
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalArgs := os.Args
+	os.Args = []string{"jacobin", "-cp", t.TempDir() + string(os.PathListSeparator) + classDir,
+		"com.example.Main"}
+	defer func() { os.Args = normalArgs }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	exitStatus := JVMrun()
+
+	_ = w.Close()
+	errMsg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if exitStatus != 7 {
+		t.Errorf("Expected JVMrun() to return 7, got: %d. Stderr: %s", exitStatus, string(errMsg))
+	}
+}
+
+// writes a JAR holding the given entries
+func writeIntegrationJar(t *testing.T, filename string, entries map[string][]byte) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, contents := range entries {
+		f, _ := w.Create(name)
+		_, _ = f.Write(contents)
+	}
+	_ = w.Close()
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Unable to write JAR: %s", err.Error())
+	}
+}
+
+// returns the classes of a program whose main class, com/example/Main, exits with
+// the status returned by com/example/lib/Status.code(), which is 9
+func statusProgram(t *testing.T) ([]byte, []byte) {
+	mainBytes, err := classbuilder.NewClassBuilder("com/example/Main").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(INVOKESTATIC, "com/example/lib/Status", "code", "()I").
+		AddOpcode(INVOKESTATIC, "java/lang/System", "exit", "(I)V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/Main: %s", err.Error())
+	}
+	libBytes, err := classbuilder.NewClassBuilder("com/example/lib/Status").
+		AddMethod("code", "()I").
+		AddOpcode(BIPUSH, 9).
+		AddOpcode(IRETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/lib/Status: %s", err.Error())
+	}
+	return mainBytes, libBytes
+}
+
+// runs a program whose classes are split across two JARs on the classpath
+func TestClasspathOfJarsIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	mainBytes, libBytes := statusProgram(t)
+
+	dir := t.TempDir()
+	mainJar := filepath.Join(dir, "main.jar")
+	writeIntegrationJar(t, mainJar, map[string][]byte{"com/example/Main.class": mainBytes})
+	libJar := filepath.Join(dir, "lib.jar")
+	writeIntegrationJar(t, libJar, map[string][]byte{"com/example/lib/Status.class": libBytes})
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalArgs := os.Args
+	os.Args = []string{"jacobin", "-cp", mainJar + string(os.PathListSeparator) + libJar,
+		"com.example.Main"}
+	defer func() { os.Args = normalArgs }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	exitStatus := JVMrun()
+
+	_ = w.Close()
+	errMsg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if exitStatus != 9 {
+		t.Errorf("Expected JVMrun() to return 9, got: %d. Stderr: %s", exitStatus, string(errMsg))
+	}
+}
+
+// runs a program from a JAR whose manifest's Class-Path names the JAR of its library
+func TestJarManifestClassPathIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	mainBytes, libBytes := statusProgram(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatalf("Unable to create lib directory: %s", err.Error())
+	}
+	writeIntegrationJar(t, filepath.Join(dir, "lib", "status.jar"),
+		map[string][]byte{"com/example/lib/Status.class": libBytes})
+	mainJar := filepath.Join(dir, "main.jar")
+	writeIntegrationJar(t, mainJar, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\r\n" +
+			"Main-Class: com.example.Main\r\n" +
+			"Class-Path: lib/status.jar\r\n\r\n"),
+		"com/example/Main.class": mainBytes,
+	})
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalArgs := os.Args
+	os.Args = []string{"jacobin", "-jar", mainJar}
+	defer func() { os.Args = normalArgs }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	exitStatus := JVMrun()
+
+	_ = w.Close()
+	errMsg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if exitStatus != 9 {
+		t.Errorf("Expected JVMrun() to return 9, got: %d. Stderr: %s", exitStatus, string(errMsg))
+	}
+}
+
+// runs a program whose main() references com/example/Never only in a branch that's
+// not taken. By default, classes are loaded when they're first needed, so Never is
+// never loaded; with -XX:+EagerClassLoading, it's loaded before execution starts.
+func TestLazyClassLoadingIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	mainBytes, err := classbuilder.NewClassBuilder("com/example/Main").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(ICONST_0).
+		AddOpcode(IFEQ, 0, 6). // to the invokestatic of Status.code()
+		AddOpcode(INVOKESTATIC, "com/example/Never", "run", "()V").
+		AddOpcode(INVOKESTATIC, "com/example/lib/Status", "code", "()I").
+		AddOpcode(INVOKESTATIC, "java/lang/System", "exit", "(I)V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/Main: %s", err.Error())
+	}
+	neverBytes, err := classbuilder.NewClassBuilder("com/example/Never").
+		AddMethod("run", "()V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/Never: %s", err.Error())
+	}
+	_, statusBytes := statusProgram(t)
+
+	classDir := t.TempDir()
+	for name, classBytes := range map[string][]byte{"com/example/Main": mainBytes,
+		"com/example/Never": neverBytes, "com/example/lib/Status": statusBytes} {
+		filename := filepath.Join(classDir, filepath.FromSlash(name)+".class")
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("Unable to create package directory: %s", err.Error())
+		}
+		if err = os.WriteFile(filename, classBytes, 0644); err != nil {
+			t.Fatalf("Unable to write class file: %s", err.Error())
+		}
+	}
+
+	for _, eager := range []bool{false, true} {
+		globals.InitGlobals("test")
+		log.Init()
+		_ = log.SetLogLevel(log.SEVERE)
+
+		normalArgs := os.Args
+		os.Args = []string{"jacobin", "-cp", classDir, "com.example.Main"}
+		if eager {
+			os.Args = []string{"jacobin", "-XX:+EagerClassLoading", "-cp", classDir, "com.example.Main"}
+		}
+
+		normalStderr := os.Stderr
+		r, w, _ := os.Pipe()
+		os.Stderr = w
+
+		exitStatus := JVMrun()
+		globals.LoaderWg.Wait() // for the eager loads to finish
+
+		_ = w.Close()
+		errMsg, _ := io.ReadAll(r)
+		os.Stderr = normalStderr
+		os.Args = normalArgs
+
+		if exitStatus != 9 {
+			t.Errorf("Expected JVMrun() to return 9 (eager: %v), got: %d. Stderr: %s",
+				eager, exitStatus, string(errMsg))
+		}
+		if _, loaded := classloader.LookupClass("com/example/lib/Status"); !loaded {
+			t.Errorf("Expected com/example/lib/Status to be loaded (eager: %v)", eager)
+		}
+		if _, loaded := classloader.LookupClass("com/example/Never"); loaded != eager {
+			t.Errorf("Expected com/example/Never to be loaded: %v, but it was: %v", eager, loaded)
+		}
+	}
+}
+
+// invokestatic of a method in a class that's not on the classpath throws NoClassDefFoundError
+func TestInvokeOfMissingClassIntegration(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only.
+		t.Skip()
+	}
+
+	mainBytes, err := classbuilder.NewClassBuilder("com/example/Main").
+		AddMethod("main", "([Ljava/lang/String;)V").
+		AddOpcode(INVOKESTATIC, "com/example/Missing", "run", "()V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building com/example/Main: %s", err.Error())
+	}
+	classDir := t.TempDir()
+	if err = os.MkdirAll(filepath.Join(classDir, "com", "example"), 0755); err != nil {
+		t.Fatalf("Unable to create package directory: %s", err.Error())
+	}
+	if err = os.WriteFile(filepath.Join(classDir, "com", "example", "Main.class"), mainBytes, 0644); err != nil {
+		t.Fatalf("Unable to write class file: %s", err.Error())
+	}
+
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalArgs := os.Args
+	os.Args = []string{"jacobin", "-cp", classDir, "com.example.Main"}
+	defer func() { os.Args = normalArgs }()
+
+	var exitStatus int
+	errMsg := captureStderr(func() { exitStatus = JVMrun() })
+
+	if exitStatus == 0 {
+		t.Error("Expected JVMrun() to fail")
+	}
+	if !strings.Contains(errMsg, "java.lang.NoClassDefFoundError: com.example.Missing") {
+		t.Errorf("Expected NoClassDefFoundError for com.example.Missing, got: %s", errMsg)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/log"
+	"strings"
+)
+
+// JavaThrowable is the error returned by the interpreter when a Java exception or
+// error is thrown. Ref is the address of the Throwable. Until athrow and exception
+// tables are implemented, a thrown Throwable is never caught, so it ends execution.
+type JavaThrowable struct {
+	Ref int64
+}
+
+func (e *JavaThrowable) Error() string {
+	return classloader.ThrowableToString(e.Ref)
+}
+
+// reports the Throwable at ref, whose type is excType, and returns it as an error
+func throwJavaThrowable(excType int, ref int64) error {
+	exceptions.Throw(excType, classloader.ThrowableToString(ref))
+	return &JavaThrowable{Ref: ref}
+}
+
+// the most frames a stack trace shows, which is HotSpot's default. After a
+// StackOverflowError, the frame stack is much deeper than this.
+const maxStackTraceDepth = 1024
+
+// logStackTrace shows where a Throwable that ends execution was thrown, with a line
+// for each frame on the frame stack, innermost first, as Java's stack traces do:
+//
+//	at Hello2.main(Hello2.java:12)
+func logStackTrace(fs *frames.FrameStack) {
+	depth := 0
+	for e := fs.Front(); e != nil && depth < maxStackTraceDepth; e = e.Next() {
+		depth += 1
+		_ = log.Log("\tat "+frameLocation(e.Value.(*frames.Frame)), log.SEVERE)
+	}
+}
+
+// throwClassNotFoundException is used when a class that's requested by name, via
+// Class.forName() for example, cannot be found. ClassNotFoundException is a checked
+// exception. The message is the binary name of the class, as in java.lang.Object.
+func throwClassNotFoundException(name string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/ClassNotFoundException",
+		strings.ReplaceAll(name, "/", "."))
+	return throwJavaThrowable(exceptions.ClassNotFoundException, ref)
+}
+
+// throwNoClassDefFoundError is used when a class that's referenced by executing
+// bytecode (by new or invokestatic, for example) cannot be found. Unlike
+// ClassNotFoundException, NoClassDefFoundError is an unchecked Error.
+func throwNoClassDefFoundError(name string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/NoClassDefFoundError",
+		strings.ReplaceAll(name, "/", "."))
+	return throwJavaThrowable(exceptions.NoClassDefFoundError, ref)
+}
+
+// throwClassCircularityError is used when a class turns out to be its own superclass
+// while its superclasses are being loaded.
+func throwClassCircularityError(name string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/ClassCircularityError",
+		strings.ReplaceAll(name, "/", "."))
+	return throwJavaThrowable(exceptions.ClassCircularityError, ref)
+}
+
+// throwIncompatibleClassChangeError is used when a class turns out to extend a sealed
+// class, or implement a sealed interface, that does not permit it to
+func throwIncompatibleClassChangeError(msg string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/IncompatibleClassChangeError", msg)
+	return throwJavaThrowable(exceptions.IncompatibleClassChangeError, ref)
+}
+
+// throwVerifyError is used when bytecode breaks the rules that the verifier would
+// have enforced, such as invoking a method without enough arguments on the stack.
+func throwVerifyError(msg string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/VerifyError", msg)
+	return throwJavaThrowable(exceptions.VerifyError, ref)
+}
+
+// throwStackOverflowError is used when a method is invoked while the frame stack is
+// already maxFrameDepth frames deep, which is usually due to unbounded recursion.
+// Like HotSpot's, the StackOverflowError has no message.
+func throwStackOverflowError() error {
+	ref := classloader.NewThrowableObject("java/lang/StackOverflowError")
+	return throwJavaThrowable(exceptions.StackOverflowError, ref)
+}
+
+// throwBootstrapMethodError is used when the bootstrap method that computes a dynamic
+// constant cannot be run. Jacobin does not yet run bootstrap methods, so this is
+// thrown whenever an ldc loads a CONSTANT_Dynamic entry.
+func throwBootstrapMethodError(msg string) error {
+	ref := classloader.NewThrowableWithMessage("java/lang/BootstrapMethodError", msg)
+	return throwJavaThrowable(exceptions.BootstrapMethodError, ref)
+}
+
+// Go methods that need the interpreter, so they're defined here rather than in
+// the classloader package with the other Go methods. They're added to the MTable
+// by StartExec().
+func jvmGoMethods() map[string]classloader.GMeth {
+	return map[string]classloader.GMeth{
+		"java/lang/Class.forName(Ljava/lang/String;)Ljava/lang/Class;": {
+			ParamSlots: 1, // [0] = the name of the class, as in java.lang.Object
+			GFunction:  classForName,
+		},
+		"java/lang/System.gc()V": {
+			ParamSlots: 0,
+			GFunction:  systemGC,
+		},
+		"java/lang/Runtime.gc()V": {
+			ParamSlots: 1, // [0] = the Runtime
+			GFunction:  systemGC,
+		},
+		"java/util/Arrays.sort([Ljava/lang/Object;)V": {
+			ParamSlots: 1, // [0] = the array
+			GFunction:  arraysSortObjects,
+		},
+		"java/util/Arrays.sort([Ljava/lang/Object;Ljava/util/Comparator;)V": {
+			ParamSlots: 2, // [0] = the array, [1] = the Comparator
+			GFunction:  arraysSortWithComparator,
+		},
+	}
+}
+
+// java/lang/Class.forName(String className) loads the named class, if it's not already
+// loaded, and returns its Class object.
+func classForName(params []interface{}) interface{} {
+	if params[0].(int64) == 0 {
+		ref := classloader.NewThrowableObject("java/lang/NullPointerException")
+		return throwJavaThrowable(exceptions.NullPointerException, ref)
+	}
+	name := strings.ReplaceAll(classloader.GoStringFromAddr(params[0].(int64)), ".", "/")
+
+	k, present := classloader.LookupClass(name)
+
+	if !present || k.Data == nil {
+		if classloader.LoadClassFromNameOnly(name) != nil {
+			return throwClassNotFoundException(name)
+		}
+	}
+	return classloader.NewClassObject(name)
+}
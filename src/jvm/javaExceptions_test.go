@@ -0,0 +1,160 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"io"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runs fn with stderr captured and returns what fn wrote to stderr
+func captureStderr(fn func()) string {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fn()
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+	return string(out)
+}
+
+// Class.forName() on a class that can't be found throws ClassNotFoundException
+func TestClassForNameThrowsClassNotFoundException(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	var ret interface{}
+	msg := captureStderr(func() {
+		ret = classForName([]interface{}{classloader.NewStringObject("com.example.Missing")})
+	})
+
+	var thrown *JavaThrowable
+	if err, isErr := ret.(error); !isErr || !errors.As(err, &thrown) {
+		t.Fatalf("Expected Class.forName() to throw, got: %v", ret)
+	}
+
+	expected := "java.lang.ClassNotFoundException: com.example.Missing"
+	if thrown.Error() != expected {
+		t.Errorf("Expected '%s', got: '%s'", expected, thrown.Error())
+	}
+	if !strings.Contains(msg, expected) {
+		t.Errorf("Expected '%s' in the error output, got: %s", expected, msg)
+	}
+
+	// a second attempt must fail the same way, rather than wait for the failed load
+	captureStderr(func() {
+		ret = classForName([]interface{}{classloader.NewStringObject("com.example.Missing")})
+	})
+	if _, isErr := ret.(error); !isErr {
+		t.Errorf("Expected second Class.forName() to throw, got: %v", ret)
+	}
+}
+
+// new of a class that can't be found throws NoClassDefFoundError
+func TestNewThrowsNoClassDefFoundError(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	var err error
+	msg := captureStderr(func() {
+		_, err = instantiateClass("com/example/AlsoMissing")
+	})
+
+	var thrown *JavaThrowable
+	if !errors.As(err, &thrown) {
+		t.Fatalf("Expected instantiating a missing class to throw, got: %v", err)
+	}
+
+	expected := "java.lang.NoClassDefFoundError: com.example.AlsoMissing"
+	if thrown.Error() != expected {
+		t.Errorf("Expected '%s', got: '%s'", expected, thrown.Error())
+	}
+	if !strings.Contains(msg, expected) {
+		t.Errorf("Expected '%s' in the error output, got: %s", expected, msg)
+	}
+
+	// a second attempt must fail the same way, rather than use the failed load
+	captureStderr(func() {
+		_, err = instantiateClass("com/example/AlsoMissing")
+	})
+	if !errors.As(err, &thrown) {
+		t.Errorf("Expected second instantiation of a missing class to throw, got: %v", err)
+	}
+}
+
+// a stack trace shows where each frame is in its class's source file
+func TestFrameLocation(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	_, err := classloader.ParseAndPostClass(classloader.BootstrapCL, "Hello2", Hello2Bytes)
+	if err != nil {
+		t.Fatalf("Got error from classloader.ParseAndPostCLass: %s", err.Error())
+	}
+	mt, err := classloader.FetchMethodAndCP("Hello2", "main", "([Ljava/lang/String;)V")
+	if err != nil {
+		t.Fatalf("Unexpected error fetching Hello2.main(): %s", err.Error())
+	}
+
+	f := frames.CreateFrame(4)
+	f.ClName = "Hello2"
+	f.MethName = "main"
+	f.LineNumbers = mt.Meth.(classloader.JmEntry).LineNumbers
+	f.PC = 13
+	if loc := frameLocation(f); loc != "Hello2.main(Hello2.java:8)" {
+		t.Errorf("Expected Hello2.main(Hello2.java:8), got: %s", loc)
+	}
+
+	f.LineNumbers = nil // without line numbers, only the source file is known
+	if loc := frameLocation(f); loc != "Hello2.main(Hello2.java)" {
+		t.Errorf("Expected Hello2.main(Hello2.java), got: %s", loc)
+	}
+
+	f.ClName = "com/example/Missing"
+	if loc := frameLocation(f); loc != "com.example.Missing.main(Unknown Source)" {
+		t.Errorf("Expected com.example.Missing.main(Unknown Source), got: %s", loc)
+	}
+
+	g := frames.CreateFrame(0)
+	g.Ftype = 'G'
+	g.ClName = "java/io/PrintStream"
+	g.MethName = "println(Ljava/lang/String;)V"
+	if loc := frameLocation(g); loc != "java.io.PrintStream.println(Native Method)" {
+		t.Errorf("Expected java.io.PrintStream.println(Native Method), got: %s", loc)
+	}
+}
+
+// a stack trace shows at most maxStackTraceDepth frames, however deep the frame stack
+func TestStackTraceDepthIsLimited(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	fs := frames.CreateFrameStack()
+	for i := 0; i < maxStackTraceDepth+10; i++ {
+		f := frames.CreateFrame(0)
+		f.ClName = "Recursion"
+		f.MethName = "down"
+		fs.PushFront(f)
+	}
+
+	msg := captureStderr(func() { logStackTrace(fs) })
+	if lines := strings.Count(msg, "\tat Recursion.down"); lines != maxStackTraceDepth {
+		t.Errorf("Expected %d lines in the stack trace, got: %d", maxStackTraceDepth, lines)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"sort"
+)
+
+// Go methods for java.util.Arrays that call Java methods--compareTo() and compare()--
+// on the objects being sorted. The sorts of arrays of primitives are in the classloader
+// package. Like Java's, these sorts are stable.
+
+// java/util/Arrays.sort(Object[] a) sorts the array into the natural order of its
+// elements, all of which must implement Comparable
+func arraysSortObjects(params []interface{}) interface{} {
+	return sortObjects(params[0].(int64), func(a, b int64) (int64, error) {
+		if a == 0 || b == 0 {
+			ref := classloader.NewThrowableObject("java/lang/NullPointerException")
+			return 0, throwJavaThrowable(exceptions.NullPointerException, ref)
+		}
+		ret, err := invokeVirtual(a, "compareTo", "(Ljava/lang/Object;)I", []interface{}{a, b})
+		if err != nil {
+			return 0, err
+		}
+		return ret.(int64), nil
+	})
+}
+
+// java/util/Arrays.sort(T[] a, Comparator c) sorts the array into the order given by
+// the Comparator. If the Comparator is null, the elements' natural order is used.
+func arraysSortWithComparator(params []interface{}) interface{} {
+	comparator := params[1].(int64)
+	if comparator == 0 {
+		return arraysSortObjects(params)
+	}
+
+	return sortObjects(params[0].(int64), func(a, b int64) (int64, error) {
+		ret, err := invokeVirtual(comparator, "compare", "(Ljava/lang/Object;Ljava/lang/Object;)I",
+			[]interface{}{comparator, a, b})
+		if err != nil {
+			return 0, err
+		}
+		return ret.(int64), nil
+	})
+}
+
+// sorts the elements of the array of objects at arrAddr using compare, which returns
+// a negative number, zero, or a positive number as its first argument is less than,
+// equal to, or greater than the second. Sorting stops at the first error.
+func sortObjects(arrAddr int64, compare func(a, b int64) (int64, error)) interface{} {
+	arr := classloader.ArrayAt(arrAddr)
+	if arr == nil {
+		ref := classloader.NewThrowableObject("java/lang/NullPointerException")
+		return throwJavaThrowable(exceptions.NullPointerException, ref)
+	}
+
+	var err error
+	sort.SliceStable(arr.Elements, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var result int64
+		result, err = compare(arr.Elements[i], arr.Elements[j])
+		return result < 0
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// classOfObject returns the name of the class of the object at addr, or "" if there's no
+// object at addr. Objects are either created by Go methods (Strings, for example) or
+// instantiated by the new bytecode; both are found in the objects table.
+func classOfObject(addr int64) string {
+	if name := classloader.ObjectClassName(addr); name != "" {
+		return name
+	}
+	if obj, ok := classloader.ObjectAt(addr).(*Object); ok {
+		return obj.klass.Data.Name
+	}
+	return ""
+}
+
+// invokeVirtual calls the named method on the object at addr, starting the search for
+// the method in the object's class. args holds the object reference followed by the
+// method's arguments, with longs and doubles taking two entries each. It returns the
+// method's return value, or nil for a void method.
+func invokeVirtual(addr int64, methName, methType string, args []interface{}) (interface{}, error) {
+	return invokeMethod(classOfObject(addr), methName, methType, args)
+}
+
+// invokeMethod runs the named method, found by searching from className up through its
+// superclasses, with the given arguments (including the object, for instance methods),
+// and returns the method's return value, if any
+func invokeMethod(className, methName, methType string, args []interface{}) (interface{}, error) {
+	mtEntry, declaringClass, err := classloader.FetchMethodFromHierarchy(className, methName, methType)
+	if err != nil {
+		return nil, errors.New("Method not found: " + className + "." + methName + methType)
+	}
+
+	if mtEntry.MType == 'G' {
+		ret := mtEntry.Meth.(classloader.GmEntry).Fu(args)
+		if err, isErr := ret.(error); isErr {
+			return nil, err
+		}
+		return ret, nil
+	}
+
+	// a Java method is run in a frame of its own, on top of a frame that receives
+	// the return value
+	m := mtEntry.Meth.(classloader.JmEntry)
+	caller := frames.CreateFrame(2)
+	caller.Thread = MainThread.ID
+
+	fram := frames.NewFrame(m.MaxStack, m.MaxLocals)
+	fram.Thread = MainThread.ID
+	fram.ClName = declaringClass
+	fram.MethName = methName
+	fram.CP = m.Cp
+	fram.LineNumbers = m.LineNumbers
+	fram.LocalVarTable = m.LocalVars
+	fram.Meth = append(fram.Meth, m.Code...)
+	for k, arg := range args {
+		fram.Locals.Store(k, frames.StackValueOf(arg))
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(caller)
+	fs.PushFront(fram)
+	registerFrameStack(fs)
+	defer unregisterFrameStack(fs)
+	if err := runFrame(fs); err != nil {
+		return nil, err
+	}
+
+	if caller.Operands.Depth() == 0 {
+		return nil, nil
+	}
+	return pop(caller), nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// creates a String[] holding the given strings and returns its address
+func newStringArray(strs ...string) int64 {
+	addr := classloader.NewArrayObject(classloader.T_REF, len(strs))
+	arr := classloader.ArrayAt(addr)
+	for i, s := range strs {
+		arr.Elements[i] = classloader.NewStringObject(s)
+	}
+	return addr
+}
+
+// returns the strings in the String[] at addr
+func stringsInArray(addr int64) []string {
+	var strs []string
+	for _, e := range classloader.ArrayAt(addr).Elements {
+		strs = append(strs, classloader.GoStringFromAddr(e))
+	}
+	return strs
+}
+
+func checkStrings(t *testing.T, expected, got []string) {
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got: %v", expected, got)
+			return
+		}
+	}
+}
+
+func TestArraysSortStrings(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	arr := newStringArray("pear", "apple", "fig", "Banana", "apples")
+	if ret := arraysSortObjects([]interface{}{arr}); ret != nil {
+		t.Fatalf("Unexpected error sorting String[]: %v", ret)
+	}
+	checkStrings(t, []string{"Banana", "apple", "apples", "fig", "pear"}, stringsInArray(arr))
+
+	// a null Comparator means natural order
+	arr = newStringArray("b", "c", "a")
+	if ret := arraysSortWithComparator([]interface{}{arr, int64(0)}); ret != nil {
+		t.Fatalf("Unexpected error sorting String[] with a null Comparator: %v", ret)
+	}
+	checkStrings(t, []string{"a", "b", "c"}, stringsInArray(arr))
+}
+
+// posts a Comparator whose compare(a, b) is b.compareTo(a), so it sorts in reverse
+func postReverseComparator() {
+	cp := classloader.CPool{}
+	cp.CpIndex = []classloader.CpEntry{
+		{Type: 0, Slot: 0},
+		{Type: classloader.MethodRef, Slot: 0},   // #1 String.compareTo(String)I
+		{Type: classloader.ClassRef, Slot: 0},    // #2 -> #3
+		{Type: classloader.UTF8, Slot: 0},        // #3 java/lang/String
+		{Type: classloader.NameAndType, Slot: 0}, // #4 #5:#6
+		{Type: classloader.UTF8, Slot: 1},        // #5 compareTo
+		{Type: classloader.UTF8, Slot: 2},        // #6 (Ljava/lang/String;)I
+		{Type: classloader.UTF8, Slot: 3},        // #7 compare
+		{Type: classloader.UTF8, Slot: 4},        // #8 (Ljava/lang/Object;Ljava/lang/Object;)I
+	}
+	cp.MethodRefs = []classloader.MethodRefEntry{{ClassIndex: 2, NameAndType: 4}}
+	cp.ClassRefs = []uint16{3}
+	cp.NameAndTypes = []classloader.NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+	cp.Utf8Refs = []string{"java/lang/String", "compareTo", "(Ljava/lang/String;)I",
+		"compare", "(Ljava/lang/Object;Ljava/lang/Object;)I"}
+
+	k := classloader.ClData{
+		Name:       "test/ReverseComparator",
+		Superclass: "java/lang/Object",
+		Methods: []classloader.Method{{
+			Name: 3,
+			Desc: 4,
+			CodeAttr: classloader.CodeAttrib{
+				MaxStack:  2,
+				MaxLocals: 3,
+				Code: []byte{
+					ALOAD_2,
+					ALOAD_1,
+					INVOKEVIRTUAL, 0x00, 0x01,
+					IRETURN,
+				},
+			},
+		}},
+		CP: cp,
+	}
+	classloader.Classes.Store(k.Name, classloader.Klass{Status: 'F', Loader: "app", Data: &k})
+}
+
+func TestArraysSortWithReverseComparator(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+	postReverseComparator()
+
+	comparator, err := instantiateClass("test/ReverseComparator")
+	if err != nil {
+		t.Fatalf("Unexpected error instantiating the Comparator: %s", err.Error())
+	}
+
+	arr := newStringArray("b", "d", "a", "c")
+	if ret := arraysSortWithComparator([]interface{}{arr, comparator.addr}); ret != nil {
+		t.Fatalf("Unexpected error sorting with a Comparator: %v", ret)
+	}
+	checkStrings(t, []string{"d", "c", "b", "a"}, stringsInArray(arr))
+}
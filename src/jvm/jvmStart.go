@@ -7,12 +7,14 @@
 package jvm
 
 import (
+	"errors"
 	"fmt"
 	"jacobin/classloader"
 	"jacobin/globals"
 	"jacobin/log"
 	"jacobin/shutdown"
 	"os"
+	"strings"
 )
 
 var Global globals.Globals
@@ -39,6 +41,13 @@ func JVMrun() int {
 	if err != nil {
 		return shutdown.Exit(shutdown.APP_EXCEPTION)
 	}
+	// the classloader finds the application's classes using the global ref, which
+	// HandleCli() doesn't update, so copy over the settings it uses
+	globalRef := globals.GetGlobalRef()
+	globalRef.ClassPath = Global.ClassPath
+	globalRef.StartingClass = Global.StartingClass
+	globalRef.StartingJar = Global.StartingJar
+
 	// some CLI options, like -version, show data and immediately exit. This tests for that.
 	if Global.ExitNow == true {
 		return shutdown.Exit(shutdown.OK)
@@ -46,12 +55,13 @@ func JVMrun() int {
 
 	// Init classloader and load base classes
 	_ = classloader.Init()
+	classloader.InitModuleLoaders(&Global)
 	classloader.LoadBaseClasses(&Global)
 
 	var mainClass string
 
 	if Global.StartingJar != "" {
-		manifestClass, err := classloader.GetMainClassFromJar(classloader.BootstrapCL, Global.StartingJar)
+		manifestClass, classPath, err := classloader.GetMainClassFromJar(classloader.BootstrapCL, Global.StartingJar)
 
 		if err != nil {
 			_ = log.Log(err.Error(), log.INFO)
@@ -62,26 +72,50 @@ func JVMrun() int {
 			_ = log.Log(fmt.Sprintf("no main manifest attribute, in %s", Global.StartingJar), log.INFO)
 			return shutdown.Exit(shutdown.APP_EXCEPTION)
 		}
-		mainClass, err = classloader.LoadClassFromJar(classloader.BootstrapCL, manifestClass, Global.StartingJar)
+		// the classpath is the JAR and the entries of its manifest's Class-Path; as in
+		// the JDK, any -cp entries are ignored
+		Global.ClassPath = classPath
+		globals.GetGlobalRef().ClassPath = classPath
+		mainClass, err = classloader.LoadClassFromJar(classloader.AppCL, manifestClass, Global.StartingJar)
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
 		}
-	} else if Global.StartingClass != "" {
-		mainClass, err = classloader.LoadClassFromFile(classloader.BootstrapCL, Global.StartingClass)
+	} else if strings.HasSuffix(Global.StartingClass, ".class") {
+		mainClass, err = classloader.LoadClassFromFile(classloader.AppCL, Global.StartingClass)
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
 		}
+	} else if Global.StartingClass != "" { // a class name, which is found on the classpath
+		mainClass, err = classloader.LoadClassFromClasspath(classloader.AppCL, Global.StartingClass)
+		if err != nil {
+			_ = log.Log("Error: Could not find or load main class "+Global.StartingClass, log.SEVERE)
+			return shutdown.Exit(shutdown.JVM_EXCEPTION)
+		}
 	} else {
 		_ = log.Log("Error: No executable program specified. Exiting.", log.INFO)
 		ShowUsage(os.Stdout)
 		return shutdown.Exit(shutdown.APP_EXCEPTION)
 	}
 
-	classloader.LoadReferencedClasses(mainClass)
+	// the main class is loaded directly, rather than by name, so it's linked here
+	if err = classloader.LinkClass(mainClass); err != nil {
+		_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		return shutdown.Exit(shutdown.JVM_EXCEPTION)
+	}
+
+	// classes are loaded when they're first needed, unless eager loading was requested
+	if Global.EagerClassLoading {
+		classloader.LoadReferencedClasses(mainClass)
+	}
 
 	// begin execution
 	_ = log.Log("Starting execution with: "+mainClass, log.INFO)
-	if StartExec(mainClass, &Global) != nil {
+	if err = StartExec(mainClass, &Global); err != nil {
+		// System.exit() has already run the shutdown hooks and, except in test mode, exited
+		var exit *classloader.SystemExit
+		if errors.As(err, &exit) {
+			return exit.Status
+		}
 		return shutdown.Exit(shutdown.APP_EXCEPTION)
 	}
 
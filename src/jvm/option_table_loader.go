@@ -13,6 +13,8 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // This set of routines loads the Global.Options table with the various
@@ -62,10 +64,22 @@ import (
 // LoadOptionsTable loads the table with all the options Jacobin recognizes.
 func LoadOptionsTable(Global globals.Globals) {
 
+	agentlib := globals.Option{true, false, 1, agentLib}
+	Global.Options["-agentlib"] = agentlib
+
 	client := globals.Option{true, false, 0, clientVM}
 	Global.Options["-client"] = client
 	client.Set = true
 
+	classPath := globals.Option{true, false, 6, getClassPath}
+	Global.Options["-cp"] = classPath
+	Global.Options["-classpath"] = classPath
+	Global.Options["--class-path"] = classPath
+
+	// -D options are dispatched to this entry by HandleCli(), with the key=value as the arg
+	systemProp := globals.Option{true, false, 2, systemProperty}
+	Global.Options["-D"] = systemProp
+
 	dryRun := globals.Option{false, false, 0, notSupported}
 	Global.Options["--dry-run"] = dryRun
 	dryRun.Set = true
@@ -82,6 +96,10 @@ func LoadOptionsTable(Global globals.Globals) {
 	Global.Options["-jar"] = jarFile
 	jarFile.Set = true
 
+	modulePath := globals.Option{true, false, 6, getModulePath}
+	Global.Options["--module-path"] = modulePath
+	Global.Options["-p"] = modulePath
+
 	showversion := globals.Option{true, false, 0, showVersionStderr}
 	Global.Options["-showversion"] = showversion
 
@@ -102,10 +120,28 @@ func LoadOptionsTable(Global globals.Globals) {
 
 	vversion := globals.Option{true, false, 1, versionStdoutThenExit}
 	Global.Options["--version"] = vversion
+
+	// all the -XX: options share this entry. xxOption() dispatches on the argument.
+	xx := globals.Option{true, false, 1, xxOption}
+	Global.Options["-XX"] = xx
 }
 
 // ---- the functions for the supported CLI options, in alphabetic order ----
 
+// for -agentlib:<name>=<options>. The only agent library is jacobin-attach, whose option
+// is the address at which the attach endpoint listens, as in:
+// -agentlib:jacobin-attach=localhost:7000
+func agentLib(pos int, argValue string, gl *globals.Globals) (int, error) {
+	name, address, _ := strings.Cut(argValue, "=")
+	if name != "jacobin-attach" || address == "" {
+		fmt.Fprintf(os.Stderr, "-agentlib:%s is not a recognized option. Ignored.\n", argValue)
+		return pos, errors.New("Invalid -agentlib option specified: " + argValue)
+	}
+	gl.AttachAddress = address
+	setOptionToSeen("-agentlib", gl)
+	return pos, nil
+}
+
 // client VM function, simply changes the wording of the version
 // info. (This is the same behavior as the OpenJDK JVM.)
 func clientVM(pos int, name string, gl *globals.Globals) (int, error) {
@@ -130,6 +166,37 @@ func getJarFilename(pos int, name string, gl *globals.Globals) (int, error) {
 	}
 }
 
+// for -cp, -classpath, and --class-path. The directories and JARs, separated by the
+// platform's path separator (: on Unix, ; on Windows), can follow an = or be the next arg.
+func getClassPath(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			return pos, os.ErrInvalid
+		}
+		pos += 1
+		argValue = gl.Args[pos]
+	}
+	gl.ClassPath = filepath.SplitList(argValue)
+	setOptionToSeen("-cp", gl)
+	log.Log("Classpath: "+strings.Join(gl.ClassPath, string(os.PathListSeparator)), log.FINE)
+	return pos, nil
+}
+
+// for --module-path and -p. The directories can follow an = or be the next arg.
+func getModulePath(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			return pos, os.ErrInvalid
+		}
+		pos += 1
+		argValue = gl.Args[pos]
+	}
+	gl.ModulePath = argValue
+	setOptionToSeen("--module-path", gl)
+	log.Log("Module path: "+gl.ModulePath, log.FINE)
+	return pos, nil
+}
+
 // generic notification function that an option is not supported
 func notSupported(pos int, arg string, gl *globals.Globals) (int, error) {
 	name := gl.Args[pos]
@@ -167,6 +234,23 @@ func strictJDK(pos int, name string, gl *globals.Globals) (int, error) {
 	return pos, nil
 }
 
+// for -Dkey=value, which sets a system property. -Dkey (with no =) sets the property
+// to the empty string. Each -D option adds one property; a later one for the same
+// key replaces the earlier value.
+func systemProperty(pos int, argValue string, gl *globals.Globals) (int, error) {
+	key, value, _ := strings.Cut(argValue, "=")
+	if key == "" {
+		fmt.Fprintf(os.Stderr, "-D%s is not a valid system property. Ignored.\n", argValue)
+		return pos, errors.New("Invalid -D option specified: -D" + argValue)
+	}
+	if gl.SystemProperties == nil {
+		gl.SystemProperties = make(map[string]string)
+	}
+	gl.SystemProperties[key] = value
+	setOptionToSeen("-D", gl)
+	return pos, nil
+}
+
 // note that the -version option prints the version then exits the VM
 func versionStderrThenExit(pos int, name string, gl *globals.Globals) (int, error) {
 	showVersion(os.Stderr, gl)
@@ -215,6 +299,37 @@ func verbosityLevel(pos int, argValue string, gl *globals.Globals) (int, error)
 	return pos, nil
 }
 
+// -XX options are the JVM's advanced options. They come in the form -XX:+Flag to turn
+// on a boolean flag and -XX:-Flag to turn it off. Unrecognized flags are ignored.
+func xxOption(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if len(argValue) < 2 || (argValue[0] != '+' && argValue[0] != '-') {
+		fmt.Fprintf(os.Stderr, "-XX:%s is not a recognized option. Ignored.\n", argValue)
+		return pos, errors.New("Invalid -XX option specified: " + argValue)
+	}
+
+	enable := argValue[0] == '+'
+	switch argValue[1:] {
+	case "EagerClassLoading":
+		gl.EagerClassLoading = enable
+	case "PrintGC":
+		gl.PrintGC = enable
+	case "PrintGCDetails":
+		gl.PrintGCDetails = enable
+	case "TraceInstructions":
+		gl.TraceInstructions = enable
+	case "UseClassCache":
+		gl.UseClassCache = enable
+	case "UseStringDeduplication":
+		gl.UseStringDeduplication = enable
+	default:
+		fmt.Fprintf(os.Stderr, "-XX:%s is not a recognized option. Ignored.\n", argValue)
+		return pos, errors.New("Invalid -XX option specified: " + argValue)
+	}
+
+	setOptionToSeen("-XX", gl)
+	return pos, nil
+}
+
 // Marks the given option as having been 'set' that is, specified on the command line
 func setOptionToSeen(optionKey string, gl *globals.Globals) {
 	o := gl.Options[optionKey]
@@ -7,7 +7,6 @@
 package jvm
 
 import (
-	"container/list"
 	"errors"
 	"fmt"
 	"jacobin/classloader"
@@ -17,10 +16,9 @@ import (
 	"jacobin/log"
 	"jacobin/shutdown"
 	"jacobin/thread"
-	"jacobin/util"
 	"math"
 	"strconv"
-	"unsafe"
+	"strings"
 )
 
 var MainThread thread.ExecThread
@@ -34,6 +32,19 @@ func StartExec(className string, globals *globals.Globals) error {
 	// initialize the MTable
 	classloader.MTable = make(map[string]classloader.MTentry)
 	classloader.MTableLoadNatives()
+	classloader.AddGoMethods(jvmGoMethods())
+	preallocateOutOfMemoryError() // before any allocations, so it never needs heap space
+	if globals.UseStringDeduplication {
+		startStringDeduplication()
+	}
+	if globals.TraceInstructions {
+		startInstructionTracing() // before the endpoints are served
+	}
+	if globals.AttachAddress != "" {
+		if err := startAttachEndpoint(globals.AttachAddress); err != nil {
+			_ = log.Log("Could not start the attach endpoint: "+err.Error(), log.WARNING)
+		}
+	}
 
 	me, err := classloader.FetchMethodAndCP(className, "main", "([Ljava/lang/String;)V")
 	if err != nil {
@@ -41,23 +52,21 @@ func StartExec(className string, globals *globals.Globals) error {
 	}
 
 	m := me.Meth.(classloader.JmEntry)
-	f := frames.CreateFrame(m.MaxStack) // create a new frame
+	f := frames.NewFrame(m.MaxStack, m.MaxLocals) // create a new frame, with its local variables
 	f.MethName = "main"
 	f.ClName = className
+	f.LineNumbers = m.LineNumbers
+	f.LocalVarTable = m.LocalVars
 	f.CP = m.Cp                        // add its pointer to the class CP
 	for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
 		f.Meth = append(f.Meth, m.Code[i])
 	}
 
-	// allocate the local variables
-	for k := 0; k < m.MaxLocals; k++ {
-		f.Locals = append(f.Locals, 0)
-	}
-
 	// create the first thread and place its first frame on it
 	MainThread = thread.CreateThread()
 	MainThread.Stack = frames.CreateFrameStack()
 	MainThread.ID = thread.AddThreadToTable(&MainThread, &globals.Threads)
+	registerFrameStack(MainThread.Stack)
 
 	tracing := false
 	trace, exists := globals.Options["-trace"]
@@ -84,6 +93,10 @@ func runThread(t *thread.ExecThread) error {
 	for t.Stack.Len() > 0 {
 		err := runFrame(t.Stack)
 		if err != nil {
+			var throwable *JavaThrowable
+			if errors.As(err, &throwable) {
+				logStackTrace(t.Stack)
+			}
 			return err
 		}
 
@@ -94,15 +107,22 @@ func runThread(t *thread.ExecThread) error {
 	return nil
 }
 
+// the deepest the frame stack can get. Each Java method invocation is a nested call
+// of runFrame(), so without a limit, unbounded recursion in Java code would exhaust
+// the goroutine's stack and crash the JVM, rather than throw StackOverflowError.
+const maxFrameDepth = 4096
+
 // runFrame() is the principal execution function in Jacobin. It first tests for a
 // golang function in the present frame. If it is a golang function, it's sent to
 // a different function for execution. Otherwise, bytecode interpretation takes
 // place through a giant switch statement.
-func runFrame(fs *list.List) error {
+func runFrame(fs *frames.FrameStack) error {
 	// the current frame is always the head of the linked list of frames.
 	// the next statement converts the address of that frame to the more readable 'f'
 	f := fs.Front().Value.(*frames.Frame)
 
+	deduplicateIfPending() // a safe point for String deduplication, if it's enabled
+
 	// if the frame contains a golang method, execute it using runGframe(),
 	// which returns a value (possibly nil) and an exceptions code. Presuming no exceptions,
 	// if the return value (here, retval) is not nil, it is placed on the stack
@@ -111,16 +131,19 @@ func runFrame(fs *list.List) error {
 		retval, slotCount, err := runGframe(f)
 
 		if retval != nil {
+			methName := f.MethName
 			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, retval.(int64)) // if slotCount = 1
-
-			if slotCount == 2 {
-				push(f, retval.(int64)) // push a second time, if a long, double, etc.
+			if err := pushReturnValue(f, retval, slotCount, methName); err != nil {
+				return err
 			}
 		}
 		return err
 	}
 
+	if fs.Len() > maxFrameDepth {
+		return throwStackOverflowError()
+	}
+
 	// the frame's method is not a golang method, so it's Java bytecode, which
 	// is interpreted in the rest of this function.
 	for f.PC < len(f.Meth) {
@@ -129,58 +152,99 @@ func runFrame(fs *list.List) error {
 				", meth: "+f.MethName+
 				", pc: "+strconv.Itoa(f.PC)+
 				", inst: "+BytecodeNames[int(f.Meth[f.PC])]+
-				", tos: "+strconv.Itoa(f.TOS),
+				", tos: "+strconv.Itoa(f.Operands.Depth()-1)+
+				localVarTrace(f),
 				log.TRACE_INST)
 		}
+		if instructionTracing.Load() {
+			traceInstruction(f)
+		}
+		if err := verifyLocalVar(f); err != nil {
+			return err
+		}
 		switch f.Meth[f.PC] { // cases listed in numerical value of opcode
 		case NOP:
 			break
 		case ACONST_NULL: // 0x01   (push null onto opStack)
-			push(f, int64(0))
+			if err := pushRef(f, int64(0)); err != nil {
+				return err
+			}
 		case ICONST_N1: //	x02	(push -1 onto opStack)
-			push(f, int64(-1))
+			if err := push(f, int64(-1)); err != nil {
+				return err
+			}
 		case ICONST_0: // 	0x03	(push int 0 onto opStack)
-			push(f, int64(0))
+			if err := push(f, int64(0)); err != nil {
+				return err
+			}
 		case ICONST_1: //  	0x04	(push int 1 onto opStack)
-			push(f, int64(1))
+			if err := push(f, int64(1)); err != nil {
+				return err
+			}
 		case ICONST_2: //   0x05	(push 2 onto opStack)
-			push(f, int64(2))
+			if err := push(f, int64(2)); err != nil {
+				return err
+			}
 		case ICONST_3: //   0x06	(push 3 onto opStack)
-			push(f, int64(3))
+			if err := push(f, int64(3)); err != nil {
+				return err
+			}
 		case ICONST_4: //   0x07	(push 4 onto opStack)
-			push(f, int64(4))
+			if err := push(f, int64(4)); err != nil {
+				return err
+			}
 		case ICONST_5: //   0x08	(push 5 onto opStack)
-			push(f, int64(5))
+			if err := push(f, int64(5)); err != nil {
+				return err
+			}
 		case LCONST_0: //   0x09    (push long 0 onto opStack)
-			push(f, int64(0)) // b/c longs take two slots on the stack, it's pushed twice
-			push(f, int64(0))
+			// b/c longs take two slots on the stack, it fills both
+			if err := pushLong(f, 0); err != nil {
+				return err
+			}
 		case LCONST_1: //   0x0A    (push long 1 on to opStack)
-			push(f, int64(1)) // b/c longs take two slots on the stack, it's pushed twice
-			push(f, int64(1))
+			if err := pushLong(f, 1); err != nil {
+				return err
+			}
 		case FCONST_0: // 0x0B
-			push(f, 0.0)
+			if err := push(f, 0.0); err != nil {
+				return err
+			}
 		case FCONST_1: // 0x0C
-			push(f, 1.0)
+			if err := push(f, 1.0); err != nil {
+				return err
+			}
 		case FCONST_2: // 0x0D
-			push(f, 2.0)
+			if err := push(f, 2.0); err != nil {
+				return err
+			}
 		case DCONST_0: // 0x0E
-			push(f, 0.0)
-			push(f, 0.0)
+			if err := pushDouble(f, 0.0); err != nil {
+				return err
+			}
 		case DCONST_1: // 0xoF
-			push(f, 1.0)
-			push(f, 1.0)
+			if err := pushDouble(f, 1.0); err != nil {
+				return err
+			}
 		case BIPUSH: //	0x10	(push the following byte as an int onto the stack)
-			push(f, int64(f.Meth[f.PC+1]))
+			if err := push(f, int64(f.Meth[f.PC+1])); err != nil {
+				return err
+			}
 			f.PC += 1
 		case SIPUSH: //	0x11	(create int from next two bytes and push the int)
 			value := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
 			f.PC += 2
-			push(f, int64(value))
+			if err := push(f, int64(value)); err != nil {
+				return err
+			}
 		case LDC: // 	0x12   	(push constant from CP indexed by next byte)
 			idx := f.Meth[f.PC+1]
 			f.PC += 1
 
 			CPe := FetchCPentry(f.CP, int(idx))
+			if CPe.entryType == classloader.Dynamic {
+				return resolveDynamicConstant(f.CP, int(idx))
+			}
 			if CPe.entryType != 0 && // 0 = error
 				// Note: an invalid CP entry causes a java.lang.Verify error and
 				//       is caught before execution of the program beings.
@@ -188,11 +252,17 @@ func runFrame(fs *list.List) error {
 				CPe.entryType != classloader.DoubleConst &&
 				CPe.entryType != classloader.LongConst { // if no error
 				if CPe.retType == IS_INT64 {
-					push(f, CPe.intVal)
+					if err := push(f, CPe.intVal); err != nil {
+						return err
+					}
 				} else if CPe.retType == IS_FLOAT64 {
-					push(f, CPe.floatVal)
+					if err := push(f, CPe.floatVal); err != nil {
+						return err
+					}
 				} else if CPe.retType == IS_STRUCT_ADDR || CPe.retType == IS_STRING_ADDR {
-					push(f, int64(CPe.addrVal))
+					if err := pushRef(f, int64(CPe.addrVal)); err != nil {
+						return err
+					}
 				}
 			} else { // TODO: Determine what exception to throw
 				exceptions.Throw(exceptions.InaccessibleObjectException, "Invalid type for LDC2_W instruction")
@@ -204,15 +274,24 @@ func runFrame(fs *list.List) error {
 			f.PC += 2
 
 			CPe := FetchCPentry(f.CP, idx)
+			if CPe.entryType == classloader.Dynamic {
+				return resolveDynamicConstant(f.CP, idx)
+			}
 			if CPe.entryType != 0 && // this instruction does not load longs or doubles
 				CPe.entryType != classloader.DoubleConst &&
 				CPe.entryType != classloader.LongConst { // if no error
 				if CPe.retType == IS_INT64 {
-					push(f, CPe.intVal)
+					if err := push(f, CPe.intVal); err != nil {
+						return err
+					}
 				} else if CPe.retType == IS_FLOAT64 {
-					push(f, CPe.floatVal)
+					if err := push(f, CPe.floatVal); err != nil {
+						return err
+					}
 				} else {
-					push(f, CPe.addrVal)
+					if err := pushRef(f, int64(CPe.addrVal)); err != nil {
+						return err
+					}
 				}
 			} else { // TODO: Determine what exception to throw
 				exceptions.Throw(exceptions.InaccessibleObjectException, "Invalid type for LDC2_W instruction")
@@ -223,12 +302,17 @@ func runFrame(fs *list.List) error {
 			f.PC += 2
 
 			CPe := FetchCPentry(f.CP, idx)
-			if CPe.retType == IS_INT64 { // push value twice (due to 64-bit width)
-				push(f, CPe.intVal)
-				push(f, CPe.intVal)
+			if CPe.entryType == classloader.Dynamic {
+				return resolveDynamicConstant(f.CP, idx)
+			}
+			if CPe.retType == IS_INT64 { // fills two slots (due to 64-bit width)
+				if err := pushLong(f, CPe.intVal); err != nil {
+					return err
+				}
 			} else if CPe.retType == IS_FLOAT64 {
-				push(f, CPe.floatVal)
-				push(f, CPe.floatVal)
+				if err := pushDouble(f, CPe.floatVal); err != nil {
+					return err
+				}
 			} else { // TODO: Determine what exception to throw
 				exceptions.Throw(exceptions.InaccessibleObjectException, "Invalid type for LDC2_W instruction")
 				shutdown.Exit(shutdown.APP_EXCEPTION)
@@ -238,516 +322,291 @@ func runFrame(fs *list.List) error {
 			ALOAD: //  0x19 (push ref from local var, using next byte as index)
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			push(f, f.Locals[index])
+			// with the type it was stored with
+			if err := push(f, f.Locals.Load(index)); err != nil {
+				return err
+			}
 		case LLOAD: // 0x16 (push long from local var, using next byte as index)
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			val := f.Locals[index].(int64)
-			push(f, val)
-			push(f, val) // push twice due to item being 64 bits wide
+			// fills two slots due to item being 64 bits wide
+			if err := pushLong(f, f.Locals.Load(index).Value().(int64)); err != nil {
+				return err
+			}
 		case DLOAD: // 0x18 (push double from local var, using next byte as index)
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			val := f.Locals[index].(float64)
-			push(f, val)
-			push(f, val) // push twice due to item being 64 bits wide
+			// fills two slots due to item being 64 bits wide
+			if err := pushDouble(f, f.Locals.Load(index).Value().(float64)); err != nil {
+				return err
+			}
 		case ILOAD_0: // 	0x1A    (push local variable 0)
-			push(f, f.Locals[0].(int64))
+			if err := push(f, f.Locals.Load(0)); err != nil {
+				return err
+			}
 		case ILOAD_1: //    OX1B    (push local variable 1)
-			push(f, f.Locals[1].(int64))
+			if err := push(f, f.Locals.Load(1)); err != nil {
+				return err
+			}
 		case ILOAD_2: //    0X1C    (push local variable 2)
-			push(f, f.Locals[2].(int64))
+			if err := push(f, f.Locals.Load(2)); err != nil {
+				return err
+			}
 		case ILOAD_3: //  	0x1D   	(push local variable 3)
-			push(f, f.Locals[3].(int64))
-		// LLOAD use two slots, so the same value is pushed twice
+			if err := push(f, f.Locals.Load(3)); err != nil {
+				return err
+			}
+		// LLOAD use two slots, so the same value fills both
 		case LLOAD_0: //	0x1E	(push local variable 0, as long)
-			push(f, f.Locals[0].(int64))
-			push(f, f.Locals[0].(int64))
+			if err := pushLong(f, f.Locals.Load(0).Value().(int64)); err != nil {
+				return err
+			}
 		case LLOAD_1: //	0x1F	(push local variable 1, as long)
-			push(f, f.Locals[1].(int64))
-			push(f, f.Locals[1].(int64))
+			if err := pushLong(f, f.Locals.Load(1).Value().(int64)); err != nil {
+				return err
+			}
 		case LLOAD_2: //	0x20	(push local variable 2, as long)
-			push(f, f.Locals[2].(int64))
-			push(f, f.Locals[2].(int64))
+			if err := pushLong(f, f.Locals.Load(2).Value().(int64)); err != nil {
+				return err
+			}
 		case LLOAD_3: //	0x21	(push local variable 3, as long)
-			push(f, f.Locals[3].(int64))
-			push(f, f.Locals[3].(int64))
+			if err := pushLong(f, f.Locals.Load(3).Value().(int64)); err != nil {
+				return err
+			}
 		case FLOAD_0: // 0x22
-			push(f, f.Locals[0])
+			if err := push(f, f.Locals.Load(0)); err != nil {
+				return err
+			}
 		case FLOAD_1: // 0x23
-			push(f, f.Locals[1])
+			if err := push(f, f.Locals.Load(1)); err != nil {
+				return err
+			}
 		case FLOAD_2: // 0x24
-			push(f, f.Locals[2])
+			if err := push(f, f.Locals.Load(2)); err != nil {
+				return err
+			}
 		case FLOAD_3: // 0x25
-			push(f, f.Locals[3])
+			if err := push(f, f.Locals.Load(3)); err != nil {
+				return err
+			}
 		case DLOAD_0: //	0x26	(push local variable 0, as double)
-			push(f, f.Locals[0])
-			push(f, f.Locals[0])
+			if err := pushDouble(f, f.Locals.Load(0).Value().(float64)); err != nil {
+				return err
+			}
 		case DLOAD_1: //	0x27	(push local variable 1, as double)
-			push(f, f.Locals[1])
-			push(f, f.Locals[1])
+			if err := pushDouble(f, f.Locals.Load(1).Value().(float64)); err != nil {
+				return err
+			}
 		case DLOAD_2: //	0x28	(push local variable 2, as double)
-			push(f, f.Locals[2])
-			push(f, f.Locals[2])
+			if err := pushDouble(f, f.Locals.Load(2).Value().(float64)); err != nil {
+				return err
+			}
 		case DLOAD_3: //	0x29	(push local variable 3, as double)
-			push(f, f.Locals[3])
-			push(f, f.Locals[3])
+			if err := pushDouble(f, f.Locals.Load(3).Value().(float64)); err != nil {
+				return err
+			}
 		case ALOAD_0: //	0x2A	(push reference stored in local variable 0)
-			push(f, f.Locals[0])
+			if err := push(f, f.Locals.Load(0)); err != nil {
+				return err
+			}
 		case ALOAD_1: //	0x2B	(push reference stored in local variable 1)
-			push(f, f.Locals[1])
+			if err := push(f, f.Locals.Load(1)); err != nil {
+				return err
+			}
 		case ALOAD_2: //	0x2C    (push reference stored in local variable 2)
-			push(f, f.Locals[2])
+			if err := push(f, f.Locals.Load(2)); err != nil {
+				return err
+			}
 		case ALOAD_3: //	0x2D	(push reference stored in local variable 3)
-			push(f, f.Locals[3])
+			if err := push(f, f.Locals.Load(3)); err != nil {
+				return err
+			}
 		case ISTORE, //  0x36 	(store popped top of stack int into local[index])
 			LSTORE, //  0x37 (store popped top of stack long into local[index])
 			ASTORE: //  0x3A (store popped top of stack ref into localc[index])
 			bytecode := f.Meth[f.PC]
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			f.Locals[index] = pop(f).(int64)
+			f.Locals.Store(index, f.Operands.PopValue())
 			// longs and doubles are stored in localvar[x] and again in localvar[x+1]
 			if bytecode == LSTORE {
-				f.Locals[index+1] = pop(f).(int64)
+				f.Locals.Store(index+1, f.Operands.PopValue())
 			}
 		case FSTORE: //  0x38 (store popped top of stack float into local[index])
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			f.Locals[index] = pop(f).(float64)
+			f.Locals.Store(index, f.Operands.PopValue())
 		case DSTORE: //  0x39 (store popped top of stack double into local[index])
 			index := int(f.Meth[f.PC+1])
 			f.PC += 1
-			f.Locals[index] = pop(f).(float64)
+			f.Locals.Store(index, f.Operands.PopValue())
 			// longs and doubles are stored in localvar[x] and again in localvar[x+1]
-			f.Locals[index+1] = pop(f).(float64)
+			f.Locals.Store(index+1, f.Operands.PopValue())
 		case ISTORE_0: //   0x3B    (store popped top of stack int into local 0)
-			f.Locals[0] = pop(f).(int64)
+			f.Locals.Store(0, f.Operands.PopValue())
 		case ISTORE_1: //   0x3C   	(store popped top of stack int into local 1)
-			f.Locals[1] = pop(f).(int64)
+			f.Locals.Store(1, f.Operands.PopValue())
 		case ISTORE_2: //   0x3D   	(store popped top of stack int into local 2)
-			f.Locals[2] = pop(f).(int64)
+			f.Locals.Store(2, f.Operands.PopValue())
 		case ISTORE_3: //   0x3E    (store popped top of stack int into local 3)
-			f.Locals[3] = pop(f).(int64)
+			f.Locals.Store(3, f.Operands.PopValue())
 		case LSTORE_0: //   0x3F    (store long from top of stack into locals 0 and 1)
-			var v = pop(f).(int64)
-			f.Locals[0] = v
-			f.Locals[1] = v
+			v := f.Operands.PopValue()
+			f.Locals.Store(0, v)
+			f.Locals.Store(1, v)
 			pop(f)
 		case LSTORE_1: //   0x40    (store long from top of stack into locals 1 and 2)
-			var v = pop(f).(int64)
-			f.Locals[1] = v
-			f.Locals[2] = v
+			v := f.Operands.PopValue()
+			f.Locals.Store(1, v)
+			f.Locals.Store(2, v)
 			pop(f)
 		case LSTORE_2: //   0x41    (store long from top of stack into locals 2 and 3)
-			var v = pop(f).(int64)
-			f.Locals[2] = v
-			f.Locals[3] = v
+			v := f.Operands.PopValue()
+			f.Locals.Store(2, v)
+			f.Locals.Store(3, v)
 			pop(f)
 		case LSTORE_3: //   0x42    (store long from top of stack into locals 3 and 4)
-			var v = pop(f).(int64)
-			f.Locals[3] = v
-			f.Locals[4] = v
+			v := f.Operands.PopValue()
+			f.Locals.Store(3, v)
+			f.Locals.Store(4, v)
 			pop(f)
 		case FSTORE_0: // 0x43
-			f.Locals[0] = pop(f).(float64)
+			f.Locals.Store(0, f.Operands.PopValue())
 		case FSTORE_1: // 0x44
-			f.Locals[1] = pop(f).(float64)
+			f.Locals.Store(1, f.Operands.PopValue())
 		case FSTORE_2: // 0x45
-			f.Locals[2] = pop(f).(float64)
+			f.Locals.Store(2, f.Operands.PopValue())
 		case FSTORE_3: // 0x46
-			f.Locals[3] = pop(f).(float64)
+			f.Locals.Store(3, f.Operands.PopValue())
 		case DSTORE_0: // 0x47
 			pop(f)
-			f.Locals[0] = pop(f).(float64)
+			f.Locals.Store(0, f.Operands.PopValue())
 		case DSTORE_1: // 0x48
 			pop(f)
-			f.Locals[1] = pop(f).(float64)
+			f.Locals.Store(1, f.Operands.PopValue())
 		case DSTORE_2: // 0x49
 			pop(f)
-			f.Locals[2] = pop(f).(float64)
+			f.Locals.Store(2, f.Operands.PopValue())
 		case DSTORE_3: // 0x4A
 			pop(f)
-			f.Locals[3] = pop(f).(float64)
+			f.Locals.Store(3, f.Operands.PopValue())
 		case ASTORE_0: //	0x4B	(pop reference into local variable 0)
-			f.Locals[0] = pop(f).(int64)
+			f.Locals.Store(0, f.Operands.PopValue())
 		case ASTORE_1: //   0x4C	(pop reference into local variable 1)
-			f.Locals[1] = pop(f).(int64)
+			f.Locals.Store(1, f.Operands.PopValue())
 		case ASTORE_2: // 	0x4D	(pop reference into local variable 2)
-			f.Locals[2] = pop(f).(int64)
+			f.Locals.Store(2, f.Operands.PopValue())
 		case ASTORE_3: //	0x4E	(pop reference into local variable 3)
-			f.Locals[3] = pop(f).(int64)
+			f.Locals.Store(3, f.Operands.PopValue())
+		// the stack-manipulation instructions move slots, keeping the type of each value
 		case DUP: // 0x59 			(push an item equal to the current top of the stack
-			push(f, peek(f))
+			if err := push(f, f.Operands.PeekValue()); err != nil {
+				return err
+			}
 		case DUP_X1: // 0x5A		(Duplicate the top stack value and insert two values down)
-			top := pop(f)
-			next := pop(f)
-			push(f, top)
-			push(f, next)
-			push(f, top)
+			top := f.Operands.PopValue()
+			next := f.Operands.PopValue()
+			if err := push(f, top); err != nil {
+				return err
+			}
+			if err := push(f, next); err != nil {
+				return err
+			}
+			if err := push(f, top); err != nil {
+				return err
+			}
 		case DUP_X2: // 0x5B		(Duplicate top stack value and insert it three slots earlier)
-			top := pop(f)
-			next := pop(f)
-			third := pop(f)
-			push(f, top)
-			push(f, third)
-			push(f, next)
-			push(f, top)
+			top := f.Operands.PopValue()
+			next := f.Operands.PopValue()
+			third := f.Operands.PopValue()
+			if err := push(f, top); err != nil {
+				return err
+			}
+			if err := push(f, third); err != nil {
+				return err
+			}
+			if err := push(f, next); err != nil {
+				return err
+			}
+			if err := push(f, top); err != nil {
+				return err
+			}
 		case DUP2: // 0x5C			(Duplicate the top two stack values)
-			top := pop(f)
-			next := peek(f)
-			push(f, top)
-			push(f, next)
-			push(f, top)
+			top := f.Operands.PopValue()
+			next := f.Operands.PeekValue()
+			if err := push(f, top); err != nil {
+				return err
+			}
+			if err := push(f, next); err != nil {
+				return err
+			}
+			if err := push(f, top); err != nil {
+				return err
+			}
 		case SWAP: // 0x5C 	(swap top two items on stack)
-			top := pop(f)
-			next := pop(f)
-			push(f, top)
-			push(f, next)
+			top := f.Operands.PopValue()
+			next := f.Operands.PopValue()
+			if err := push(f, top); err != nil {
+				return err
+			}
+			if err := push(f, next); err != nil {
+				return err
+			}
 		case POP: // 0x57 	(pop an item off the stack and discard it)
 			pop(f)
 		case POP2: // 0x58	(pop 2 itmes from stack and discard them)
 			pop(f)
 			pop(f)
-		case IADD: //  0x60		(add top 2 integers on operand stack, push result)
-			i2 := pop(f).(int64)
-			i1 := pop(f).(int64)
-			sum := add(i1, i2)
-			push(f, sum)
-		case LADD: //  0x61     (add top 2 longs on operand stack, push result)
-			l2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
-			pop(f)
-			l1 := pop(f).(int64)
-			pop(f)
-			sum := add(l1, l2)
-			push(f, sum)
-			push(f, sum)
-		case FADD: // 0x62
-			lhs := float32(pop(f).(float64))
-			rhs := float32(pop(f).(float64))
-			push(f, float64(lhs+rhs))
-		case DADD: // 0x63
-			lhs := pop(f).(float64)
-			pop(f)
-			rhs := pop(f).(float64)
-			pop(f)
-			res := add(lhs, rhs)
-			push(f, res)
-			push(f, res)
-		case ISUB: //  0x64	(subtract top 2 integers on operand stack, push result)
-			i2 := pop(f).(int64)
-			i1 := pop(f).(int64)
-			diff := subtract(i1, i2)
-			push(f, diff)
-		case LSUB: //  0x65 (subtract top 2 longs on operand stack, push result)
-			i2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
-			pop(f)
-			i1 := pop(f).(int64)
-			pop(f)
-			diff := subtract(i1, i2)
-
-			push(f, diff)
-			push(f, diff)
-		case FSUB: // 0x66
-			i2 := float32(pop(f).(float64))
-			i1 := float32(pop(f).(float64))
-			push(f, float64(i1-i2))
-		case DSUB: // 0x67
-			val2 := pop(f).(float64)
-			pop(f)
-			val1 := pop(f).(float64)
-			pop(f)
-			res := val1 - val2
-			push(f, res)
-			push(f, res)
-		case IMUL: //  0x68  	(multiply 2 integers on operand stack, push result)
-			i2 := pop(f).(int64)
-			i1 := pop(f).(int64)
-			product := multiply(i1, i2)
-
-			push(f, product)
-		case LMUL: //  0x69     (multiply 2 longs on operand stack, push result)
-			l2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
-			pop(f)
-			l1 := pop(f).(int64)
-			pop(f)
-			product := multiply(l1, l2)
-
-			push(f, product)
-			push(f, product)
-		case FMUL: // 0x6A
-			val1 := float32(pop(f).(float64))
-			val2 := float32(pop(f).(float64))
-			push(f, float64(val1*val2))
-		case DMUL: // 0x6B
-			val1 := pop(f).(float64)
-			pop(f)
-			val2 := pop(f).(float64)
-			pop(f)
-			res := multiply(val1, val2)
-			push(f, res)
-			push(f, res)
-		case IDIV: //  0x6C (integer divide tos-1 by tos)
-			val1 := pop(f).(int64)
-			if val1 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, "Arithmetic Exception: divide by zero")
-				shutdown.Exit(shutdown.APP_EXCEPTION)
-			} else {
-				val2 := pop(f).(int64)
-				push(f, val2/val1)
-			}
-		case LDIV: //  0x6D   (long divide tos-2 by tos)
-			val2 := pop(f).(int64)
-			pop(f) //    longs occupy two slots, hence double pushes and pops
-			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, "Arithmetic Exception: divide by zero")
-				shutdown.Exit(shutdown.APP_EXCEPTION)
-			} else {
-				val1 := pop(f).(int64)
-				pop(f)
-				res := val1 / val2
-				push(f, res)
-				push(f, res)
-			}
-		case FDIV: // 0x6E
-			val1 := pop(f).(float64)
-			val2 := pop(f).(float64)
-			if val1 == 0.0 {
-				if val2 == 0.0 {
-					push(f, math.NaN())
-				} else if math.Signbit(val1) {
-					push(f, math.Inf(1))
-				} else {
-					push(f, math.Inf(-1))
-				}
-			} else {
-				push(f, float64(float32(val2)/float32(val1)))
+		case IADD, // 0x60 (the int arithmetic, shift, and bitwise instructions)
+			ISUB, IMUL, IDIV, IREM, INEG, ISHL, ISHR, IUSHR, IAND, IOR, IXOR:
+			if err := execIntArith(f.Meth[f.PC], f.Operands); err != nil {
+				return operandStackError(err)
+			}
+		case LADD, // 0x61 (the long arithmetic, shift, and bitwise instructions)
+			LSUB, LMUL, LDIV, LREM, LNEG, LSHL, LSHR, LUSHR, LAND, LOR, LXOR:
+			if err := execLongArith(f.Meth[f.PC], f.Operands); err != nil {
+				return operandStackError(err)
+			}
+		case FADD, // 0x62 (the float arithmetic instructions)
+			FSUB, FMUL, FDIV, FREM, FNEG:
+			if err := execFloatArith(f.Meth[f.PC], f.Operands); err != nil {
+				return operandStackError(err)
+			}
+		case DADD, // 0x63 (the double arithmetic instructions)
+			DSUB, DMUL, DDIV, DREM, DNEG:
+			if err := execDoubleArith(f.Meth[f.PC], f.Operands); err != nil {
+				return operandStackError(err)
 			}
-		case DDIV: // 0x6F
-			val1 := pop(f).(float64)
-			pop(f)
-			val2 := pop(f).(float64)
-			pop(f)
-			if val1 == 0.0 {
-				if val2 == 0.0 {
-					push(f, math.NaN())
-				} else if math.Signbit(val1) {
-					push(f, math.Inf(1))
-				} else {
-					push(f, math.Inf(-1))
-				}
-			} else {
-				res := val2 / val1
-				push(f, res)
-				push(f, res)
-			}
-		case IREM: // 	0x70	(remainder after int division, modulo)
-			val2 := pop(f).(int64)
-			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, "Arithmetic Exception: divide by zero")
-				shutdown.Exit(shutdown.APP_EXCEPTION)
-			} else {
-				val1 := pop(f).(int64)
-				res := val1 % val2
-				push(f, res)
-			}
-		case LREM: // 	0x71	(remainder after long division)
-			val2 := pop(f).(int64)
-			pop(f) //    longs occupy two slots, hence double pushes and pops
-			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, "Arithmetic Exception: divide by zero")
-				shutdown.Exit(shutdown.APP_EXCEPTION)
-			} else {
-				val1 := pop(f).(int64)
-				pop(f)
-				res := val1 % val2
-				push(f, res)
-				push(f, res)
-			}
-		case FREM: // 0x72
-			val2 := pop(f).(float64)
-			val1 := pop(f).(float64)
-			push(f, float64(float32(math.Remainder(val1, val2))))
-		case DREM: // 0x73
-			val2 := pop(f).(float64)
-			pop(f)
-			val1 := pop(f).(float64)
-			pop(f)
-			push(f, math.Remainder(val1, val2))
-		case INEG: //	0x74 	(negate an int)
-			val := pop(f).(int64)
-			push(f, -val)
-		case LNEG: //   0x75	(negate a long)
-			val := pop(f).(int64)
-			pop(f) // pop a second time because it's a long, which occupies 2 slots
-			val = val * (-1)
-			push(f, val)
-			push(f, val)
-		case FNEG: //	0x76	(negate a float)
-			val := pop(f).(float64)
-			push(f, -val)
-
-		case DNEG: // 0x77
-			pop(f)
-			val := pop(f).(float64)
-			push(f, -val)
-			push(f, -val)
-		case ISHL: //	0x78 	(shift int left)
-			shiftBy := pop(f).(int64)
-			val1 := pop(f).(int64)
-			push(f, val1<<(shiftBy&0x1F)) // only the bottom five bits are used
-
-		case LSHL: // 	0x79	(shift value1 (long) left by value2 (int) bits)
-			shiftBy := pop(f).(int64)
-			ushiftBy := uint64(shiftBy) & 0x3f // must be unsigned in golang; 0-63 bits per JVM
-			val1 := pop(f).(int64)
-			pop(f)
-			val3 := val1 << ushiftBy
-			push(f, val3)
-			push(f, val3)
-		case ISHR: //  0x7A	(shift int value right)
-			shiftBy := pop(f).(int64)
-			val1 := pop(f).(int64)
-			push(f, val1>>(shiftBy&0x1F)) // only the bottom five bits are used
-		case LSHR, // 	0x7B	(shift value1 (long) right by value2 (int) bits)
-			LUSHR: // 	0x70
-			shiftBy := pop(f).(int64)
-			ushiftBy := uint64(shiftBy) & 0x3f // must be unsigned in golang; 0-63 bits per JVM
-			val1 := pop(f).(int64)
-			pop(f)
-			val3 := val1 >> ushiftBy
-			push(f, val3)
-			push(f, val3)
-		case IUSHR: // 0x7C (unsigned shift right of int)
-			shiftBy := pop(f).(int64) // TODO: verify the result against JDK
-			val1 := pop(f).(int64)
-			if val1 < 0 {
-				val1 = -val1
-			}
-			push(f, val1>>(shiftBy&0x1F)) // only the bottom five bits are used
-		case IAND: //	0x7E	(logical and of two ints, push result)
-			val1 := pop(f).(int64)
-			val2 := pop(f).(int64)
-			push(f, val1&val2)
-		case LAND: //   0x7F    (logical and of two longs, push result)
-			val1 := pop(f).(int64)
-			pop(f)
-			val2 := pop(f).(int64)
-			pop(f)
-			val3 := val1 & val2
-			push(f, val3)
-			push(f, val3)
-		case IOR: // 0x 80 (logical OR of two ints, push result)
-			val1 := pop(f).(int64)
-			val2 := pop(f).(int64)
-			push(f, val1|val2)
-		case LOR: // 0x81  (logical OR of two longs, push result)
-			val1 := pop(f).(int64)
-			pop(f)
-			val2 := pop(f).(int64)
-			pop(f)
-			val3 := val1 | val2
-			push(f, val3)
-			push(f, val3)
-		case IXOR: // 	0x82	(logical XOR of two ints, push result)
-			val1 := pop(f).(int64)
-			val2 := pop(f).(int64)
-			push(f, val1^val2)
-		case LXOR: // 	0x83  	(logical XOR of two longs, push result)
-			val1 := pop(f).(int64)
-			pop(f)
-			val2 := pop(f).(int64)
-			pop(f)
-			val3 := val1 ^ val2
-			push(f, val3)
-			push(f, val3)
 		case IINC: // 	0x84    (increment local variable by a constant)
-			localVarIndex := int64(f.Meth[f.PC+1])
-			constAmount := int64(f.Meth[f.PC+2])
+			// the constant is a signed byte and the sum wraps around as a Java int does,
+			// so Integer.MAX_VALUE + 1 is Integer.MIN_VALUE
+			localVarIndex := int(f.Meth[f.PC+1])
+			constAmount := int32(int8(f.Meth[f.PC+2]))
 			f.PC += 2
-			orig := f.Locals[localVarIndex].(int64)
-			f.Locals[localVarIndex] = orig + constAmount
-		case I2F: //	0x86 	( convert int to float)
-			intVal := pop(f).(int64)
-			push(f, float64(intVal))
-		case I2L: // 	0x85     (convert int to long)
-			// 	ints are already 64-bits, so this just pushes a second instance
-			val := peek(f).(int64) // look without popping
-			push(f, val)           // push the int a second time
-		case I2D: // 	0x87	(convert int to double)
-			intVal := pop(f).(int64)
-			dval := float64(intVal)
-			push(f, dval) // doubles use two slots, hence two pushes
-			push(f, dval)
-		case L2I: // 	0x88 	(convert long to int)
-			longVal := pop(f).(int64)
-			pop(f)
-			intVal := longVal << 32 // remove high-end 4 bytes. this maintains the sign
-			intVal >>= 32
-			push(f, intVal)
-		case L2F: // 	0x89 	(convert long to float)
-			longVal := pop(f).(int64)
-			pop(f)
-			float32Val := float32(longVal) //
-			float64Val := float64(float32Val)
-			push(f, float64Val) // floats tke up only 1 slot in the JVM
-		case L2D: // 	0x8A (convert long to double)
-			longVal := pop(f).(int64)
-			pop(f)
-			dblVal := float64(longVal)
-			push(f, dblVal)
-			push(f, dblVal)
-		case D2I: // 0xBE
-			pop(f)
-			fallthrough
-		case F2I: // 0x8B
-			floatVal := pop(f).(float64)
-			push(f, int64(math.Trunc(floatVal)))
-		case F2D: // 0x8D
-			floatVal := pop(f).(float64)
-			push(f, floatVal)
-			push(f, floatVal)
-		case D2L: // 	0x8F convert double to long
-			pop(f)
-			fallthrough
-		case F2L: // 	0x8C convert float to long
-			floatVal := pop(f).(float64)
-			truncated := int64(math.Trunc(floatVal))
-			push(f, truncated)
-			push(f, truncated)
-
-		case D2F: // 	0x90 Double to float
-			floatVal := float32(pop(f).(float64))
-			pop(f)
-			push(f, float64(floatVal))
-		case I2B: //	0x91 convert into to byte preserving sign
-			intVal := pop(f).(int64)
-			byteVal := intVal & 0xFF
-			if !(intVal > 0 && byteVal > 0) &&
-				!(intVal < 0 && byteVal < 0) {
-				byteVal = -byteVal
-			}
-			push(f, byteVal)
-		case I2C: //	0x92 convert to 16-bit char
-			// determine what happens in Java if the int is negative
-			intVal := pop(f).(int64)
-			charVal := uint16(intVal) // Java chars are 16-bit unsigned value
-			push(f, int64(charVal))
-		case I2S: //	0x93 convert int to short
-			intVal := pop(f).(int64)
-			shortVal := int32(intVal)
-			push(f, int64(shortVal))
+			orig := int32(f.Locals.Load(localVarIndex).Value().(int64))
+			f.Locals.Store(localVarIndex, frames.StackValueOf(int64(orig+constAmount)))
+		case I2L, // 0x85 (the type-conversion instructions)
+			I2F, I2D, L2I, L2F, L2D, F2I, F2L, F2D, D2I, D2L, D2F, I2B, I2C, I2S:
+			if err := execConvert(f.Meth[f.PC], f.Operands); err != nil {
+				return operandStackError(err)
+			}
 		case LCMP: // 	0x94 (compare two longs, push int -1, 0, or 1, depending on result)
 			value2 := pop(f).(int64)
 			pop(f)
 			value1 := pop(f).(int64)
 			pop(f)
 			if value1 == value2 {
-				push(f, int64(0))
+				if err := push(f, int64(0)); err != nil {
+					return err
+				}
 			} else if value1 > value2 {
-				push(f, int64(1))
+				if err := push(f, int64(1)); err != nil {
+					return err
+				}
 			} else {
-				push(f, int64(-1))
+				if err := push(f, int64(-1)); err != nil {
+					return err
+				}
 			}
 		case DCMPL, DCMPG: // 0x98, 0x97 - double comparison - they only differ in NaN treatment
 			value2 := pop(f).(float64)
@@ -757,179 +616,94 @@ func runFrame(fs *list.List) error {
 
 			if math.IsNaN(value1) || math.IsNaN(value2) {
 				if f.Meth[f.PC] == DCMPG {
-					push(f, int64(1))
+					if err := push(f, int64(1)); err != nil {
+						return err
+					}
 				} else {
-					push(f, int64(-1))
+					if err := push(f, int64(-1)); err != nil {
+						return err
+					}
 				}
 			} else if value1 > value2 {
-				push(f, int64(1))
+				if err := push(f, int64(1)); err != nil {
+					return err
+				}
 			} else if value1 < value2 {
-				push(f, int64(-1))
-			} else {
-				push(f, int64(0))
-			}
-		case IFEQ: // 0x99 pop int, if it's == 0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value == 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFNE: // 0x9A pop int, it it's !=0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value != 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFLT: // 0x9B pop int, if it's < 0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value < 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFGE: // 0x9C pop int, if it's >= 0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value >= 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFGT: // 0x9D pop int, if it's > 0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value > 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFLE: // 0x9E pop int, if it's <= 0, go to the jump location
-			// specified in the next two bytes
-			value := pop(f).(int64)
-			if value <= 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPEQ: //  0x9F 	(jump if top two ints are equal)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if int32(val1) == int32(val2) { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPNE: //  0xA0    (jump if top two ints are not equal)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if int32(val1) != int32(val2) { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPLT: //  0xA1    (jump if popped val1 < popped val2)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			val1a := val1
-			val2a := val2
-			if val1a < val2a { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPGE: //  0xA2    (jump if popped val1 >= popped val2)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if val1 >= val2 { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPGT: //  0xA3    (jump if popped val1 > popped val2)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if int32(val1) > int32(val2) { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ICMPLE: //	0xA4	(jump if popped val1 <= popped val2)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if val1 <= val2 { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ACMPEQ: // 0xA5		(jump if two addresses are equal)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if val1 == val2 { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
-			} else {
-				f.PC += 2
-			}
-		case IF_ACMPNE: // 0xA6		(jump if two addresses are note equal)
-			val2 := pop(f).(int64)
-			val1 := pop(f).(int64)
-			if val1 != val2 { // if comp succeeds, next 2 bytes hold instruction index
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+				if err := push(f, int64(-1)); err != nil {
+					return err
+				}
 			} else {
-				f.PC += 2
+				if err := push(f, int64(0)); err != nil {
+					return err
+				}
+			}
+		case IFEQ, // 0x99 (the branch instructions)
+			IFNE, IFLT, IFGE, IFGT, IFLE, IF_ICMPEQ, IF_ICMPNE, IF_ICMPLT, IF_ICMPGE, IF_ICMPGT,
+			IF_ICMPLE, IF_ACMPEQ, IF_ACMPNE, GOTO, TABLESWITCH, LOOKUPSWITCH, IFNULL, IFNONNULL, GOTO_W:
+			newPC, err := execBranch(f.Meth[f.PC], f.PC, f.Meth, f.Operands)
+			if err != nil {
+				return branchVerifyError(f, err.Error())
+			}
+			f.PC = newPC - 1 // -1 because this loop will increment f.PC by 1
+		case JSR: // 0xA8     (jump to subroutine, pushing the return address. Pre-Java 6 finally blocks.)
+			// the return address is the instruction following the 3-byte jsr. It's stored
+			// as an int64, so that the subroutine's astore/ret can handle it like a reference.
+			if err := pushRef(f, int64(f.PC+3)); err != nil {
+				return err
 			}
-		case GOTO: // 0xA7     (goto an instruction)
 			jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
 			f.PC = f.PC + int(jumpTo) - 1 // -1 because this loop will increment f.PC by 1
+		case RET: // 0xA9     (return from subroutine to the address in local[index])
+			index := int(f.Meth[f.PC+1])
+			returnAddress := f.Locals.Load(index).Value().(int64)
+			f.PC = int(returnAddress) - 1 // -1 because this loop will increment f.PC by 1
 		case IRETURN: // 0xAC (return an int and exit current frame)
-			valToReturn := pop(f)
+			valToReturn := f.Operands.PopValue()
 			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // TODO: check what happens when main() ends on IRETURN
+			// TODO: check what happens when main() ends on IRETURN
+			if err := push(f, valToReturn); err != nil {
+				return err
+			}
 			return nil
 		case LRETURN: // 0xAD (return a long and exit current frame)
 			valToReturn := pop(f).(int64)
 			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // pushed twice b/c a long uses two slots
-			push(f, valToReturn)
+			// fills two slots b/c a long uses two slots
+			if err := pushLong(f, valToReturn); err != nil {
+				return err
+			}
 			return nil
 		case FRETURN: // 0xAE
 			valToReturn := pop(f).(float64)
 			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn)
+			if err := push(f, valToReturn); err != nil {
+				return err
+			}
 			return nil
 		case DRETURN: // 0xAF (return a double and exit current frame)
 			valToReturn := pop(f).(float64)
 			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // pushed twice b/c a float uses two slots
-			push(f, valToReturn)
+			// fills two slots b/c a double uses two slots
+			if err := pushDouble(f, valToReturn); err != nil {
+				return err
+			}
+			return nil
+		case ARETURN: // 0xB0 (return an object reference and exit current frame)
+			valToReturn := f.Operands.PopValue()
+			f = fs.Front().Next().Value.(*frames.Frame)
+			if err := push(f, valToReturn); err != nil {
+				return err
+			}
 			return nil
 		case RETURN: // 0xB1    (return from void function)
-			f.TOS = -1 // empty the stack
+			f.Operands.Clear() // empty the stack
 			return nil
 		case GETSTATIC: // 0xB2		(get static field)
-			// TODO: getstatic will instantiate a static class if it's not already instantiated
-			// that logic has not yet been implemented and the code here is simply a reasonable
-			// placeholder, which consists of creating a struct that holds most of the needed info
-			// puts it into a slice of such static fields and pushes the index of this item in the slice
-			// onto the stack of the frame.
+			// TODO: getstatic will initialize the field's class (running <clinit>) if it's not
+			// already initialized. That logic has not yet been implemented, so a static field
+			// has its default value, or the value in its ConstantValue attribute, if it has one.
+			// The fields are kept in a slice of structs that hold most of the needed info, and
+			// getstatic pushes the value of the field onto the stack of the frame.
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
 			f.PC += 2
 			CPentry := f.CP.CpIndex[CPslot]
@@ -955,21 +729,46 @@ func runFrame(fs *list.List) error {
 			nAndTslot := nAndTentry.Slot
 			nAndT := f.CP.NameAndTypes[nAndTslot]
 			fieldNameIndex := nAndT.NameIndex
-			fieldName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
-			fieldName = className + "." + fieldName
+			simpleName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
+			fieldName := className + "." + simpleName
+
+			// System.out is a PrintStream implemented in Go, so push the address of that object
+			if fieldName == "java/lang/System.out" {
+				if err := pushRef(f, classloader.SystemOut); err != nil {
+					return err
+				}
+				break
+			}
 
-			// was this static field previously loaded? Is so, get its location and move on.
+			// was this static field previously loaded? Is so, push its value and move on.
 			prevLoaded, ok := classloader.Statics[fieldName]
-			if ok { // if preloaded, then push the index into the array of constant fields
-				push(f, prevLoaded)
+			if ok {
+				if err := pushStatic(f, classloader.StaticsArray[prevLoaded]); err != nil {
+					return err
+				}
+				break
+			}
+
+			// the field's class is loaded, and its statics set up, the first time one of
+			// its static fields is used. The field can be inherited, in which case it's
+			// the same static as in the class that declares it.
+			if err := classloader.LoadClassFromNameOnly(className); err != nil {
+				return throwNoClassDefFoundError(className)
+			}
+			if index, ok := resolveStatic(className, simpleName, make(map[string]bool)); ok {
+				classloader.Statics[fieldName] = index
+				if err := pushStatic(f, classloader.StaticsArray[index]); err != nil {
+					return err
+				}
 				break
 			}
 
+			// otherwise, the field is not declared in the class or any of its supertypes
 			fieldTypeIndex := nAndT.DescIndex
 			fieldType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldTypeIndex)
 			// println("full field name: " + fieldName + ", type: " + fieldType)
 			newStatic := classloader.Static{
-				Class:     'L',
+				Class:     fieldType[0],
 				Type:      fieldType,
 				ValueRef:  "",
 				ValueInt:  0,
@@ -980,9 +779,9 @@ func runFrame(fs *list.List) error {
 			}
 			classloader.StaticsArray = append(classloader.StaticsArray, newStatic)
 			classloader.Statics[fieldName] = int64(len(classloader.StaticsArray) - 1)
-
-			// push the pointer to the stack of the frame
-			push(f, int64(len(classloader.StaticsArray)-1))
+			if err := pushStatic(f, newStatic); err != nil {
+				return err
+			}
 
 		case INVOKEVIRTUAL: // 	0xB6 invokevirtual (create new frame, invoke function)
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
@@ -1009,22 +808,108 @@ func runFrame(fs *list.List) error {
 			nAndTslot := nAndTentry.Slot
 			nAndT := f.CP.NameAndTypes[nAndTslot]
 			methodNameIndex := nAndT.NameIndex
-			methodName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodNameIndex)
-			methodName = className + "." + methodName
+			simpleName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodNameIndex)
+			methodName := className + "." + simpleName
 
 			// get the signature for this method
 			methodSigIndex := nAndT.DescIndex
 			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodSigIndex)
 			// println("Method signature for invokevirtual: " + methodName + methodType)
+			if err := verifyArgsOnStack(f, methodName, methodType, true); err != nil {
+				return err
+			}
 
-			v := classloader.MTable[methodName+methodType]
-			if v.Meth != nil && v.MType == 'G' { // so we have a golang function
-				_, err := runGmethod(v, fs, className, methodName, methodType)
-				if err != nil {
+			// the method can be a Go method inherited from a superclass, as
+			// RuntimeException.getMessage() is inherited from Throwable
+			v, declaringClass, err := classloader.FetchMethodFromHierarchy(className, simpleName, methodType)
+			if err == nil && v.MType == 'G' { // so we have a golang function
+				_, err := runGmethod(v, fs, declaringClass, declaringClass+"."+simpleName, methodType)
+				if isSystemExit(err) { // System.exit() was called, so stop executing
+					return err
+				} else if err != nil {
 					shutdown.Exit(shutdown.APP_EXCEPTION) // any exceptions message will already have been displayed to the user
 				}
 				break
 			}
+		case INVOKESPECIAL: // 	0xB7 invokespecial (invoke constructors, private methods, and superclass methods)
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
+			f.PC += 2
+			CPentry := f.CP.CpIndex[CPslot]
+			if CPentry.Type != classloader.MethodRef { // the pointed-to CP entry must be a method reference
+				return fmt.Errorf("Expected a method ref for invokespecial, but got %d in"+
+					"location %d in method %s of class %s\n",
+					CPentry.Type, f.PC, f.MethName, f.ClName)
+			}
+
+			// get the methodRef entry
+			method := f.CP.MethodRefs[CPentry.Slot]
+
+			// get the class entry from this method
+			classRef := method.ClassIndex
+			classNameIndex := f.CP.ClassRefs[f.CP.CpIndex[classRef].Slot]
+			classNameEntry := f.CP.CpIndex[classNameIndex]
+			className := f.CP.Utf8Refs[classNameEntry.Slot]
+
+			// get the method name and signature for this method
+			nAndTindex := method.NameAndType
+			nAndTentry := f.CP.CpIndex[nAndTindex]
+			nAndTslot := nAndTentry.Slot
+			nAndT := f.CP.NameAndTypes[nAndTslot]
+			methodName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.NameIndex)
+			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.DescIndex)
+			if err := verifyArgsOnStack(f, className+"."+methodName, methodType, true); err != nil {
+				return err
+			}
+
+			// the class in which the search for the method begins depends on ACC_SUPER
+			startClass := classloader.InvokespecialStartClass(f.ClName, className, methodName)
+			mtEntry, declaringClass, err := classloader.FetchMethodFromHierarchy(startClass, methodName, methodType)
+			if err != nil {
+				return errors.New("Method not found: " + className + "." + methodName + methodType)
+			}
+
+			if mtEntry.MType == 'G' {
+				f, err = runGmethod(mtEntry, fs, declaringClass, declaringClass+"."+methodName, methodType)
+				if isSystemExit(err) { // System.exit() was called, so stop executing
+					return err
+				} else if err != nil {
+					shutdown.Exit(shutdown.APP_EXCEPTION) // any exceptions message will already have been displayed to the user
+				}
+			} else if mtEntry.MType == 'J' {
+				m := mtEntry.Meth.(classloader.JmEntry)
+				fram := frames.NewFrame(m.MaxStack, m.MaxLocals)
+
+				fram.Thread = f.Thread
+				fram.ClName = declaringClass
+				fram.MethName = methodName
+				fram.LineNumbers = m.LineNumbers
+				fram.LocalVarTable = m.LocalVars
+				fram.CP = m.Cp                     // add its pointer to the class CP
+				for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
+					fram.Meth = append(fram.Meth, m.Code[i])
+				}
+
+				// pop the parameters and then the object reference off the present stack.
+				// The object reference goes into local 0, followed by the parameters.
+				argList := popMethodArgs(f, methodType)
+				fram.Locals.Store(0, f.Operands.PopValue())
+
+				destLocal := 1
+				for j := len(argList) - 1; j >= 0; j-- {
+					fram.Locals.Store(destLocal, argList[j])
+					destLocal += 1
+				}
+
+				fs.PushFront(fram)                   // push the new frame
+				f = fs.Front().Value.(*frames.Frame) // point f to the new head
+				err = runFrame(fs)
+				if err != nil {
+					return err
+				}
+
+				fs.Remove(fs.Front()) // pop the frame off
+				f = fs.Front().Value.(*frames.Frame)
+			}
 		case INVOKESTATIC: // 	0xB8 invokestatic (create new frame, invoke static function)
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
 			f.PC += 2
@@ -1051,67 +936,48 @@ func runFrame(fs *list.List) error {
 			methodSigIndex := nAndT.DescIndex
 			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodSigIndex)
 			// println("Method signature for invokestatic: " + methodName + methodType)
+			if err := verifyArgsOnStack(f, className+"."+methodName, methodType, false); err != nil {
+				return err
+			}
 
 			// m, cpp, err := fetchMethodAndCP(className, methodName, methodType)
 			mtEntry, err := classloader.FetchMethodAndCP(className, methodName, methodType)
 			if err != nil {
-				return errors.New("Class not found: " + className + methodName)
+				var notLoaded *classloader.ClassNotLoadedError
+				if errors.As(err, &notLoaded) {
+					return throwNoClassDefFoundError(className)
+				}
+				return errors.New("Method not found: " + className + "." + methodName + methodType)
 			}
 
 			if mtEntry.MType == 'G' {
 				f, err = runGmethod(mtEntry, fs, className, className+"."+methodName, methodType)
-				if err != nil {
+				if isSystemExit(err) { // System.exit() was called, so stop executing
+					return err
+				} else if err != nil {
 					shutdown.Exit(shutdown.APP_EXCEPTION) // any exceptions message will already have been displayed to the user
 				}
 			} else if mtEntry.MType == 'J' {
 				m := mtEntry.Meth.(classloader.JmEntry)
-				maxStack := m.MaxStack
-				fram := frames.CreateFrame(maxStack)
+				fram := frames.NewFrame(m.MaxStack, m.MaxLocals)
 
 				fram.ClName = className
 				fram.MethName = methodName
+				fram.LineNumbers = m.LineNumbers
+				fram.LocalVarTable = m.LocalVars
 				fram.CP = m.Cp                     // add its pointer to the class CP
 				for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
 					fram.Meth = append(fram.Meth, m.Code[i])
 				}
 
-				// allocate the local variables
-				for k := 0; k < m.MaxLocals; k++ {
-					fram.Locals = append(fram.Locals, 0)
-				}
-
 				// pop the parameters off the present stack and put them in the new frame's locals
-				var argList []interface{}
-				paramsToPass := util.ParseIncomingParamsFromMethTypeString(methodType)
-				if len(paramsToPass) > 0 {
-					for i := len(paramsToPass) - 1; i > -1; i-- {
-						switch paramsToPass[i] {
-						case 'D':
-							arg := pop(f).(float64)
-							argList = append(argList, arg)
-							argList = append(argList, arg)
-							pop(f)
-						case 'F':
-							arg := pop(f).(float64)
-							argList = append(argList, arg)
-						case 'J': // long
-							arg := pop(f).(int64)
-							argList = append(argList, arg)
-							argList = append(argList, arg)
-							pop(f)
-						default:
-							arg := pop(f).(int64)
-							argList = append(argList, arg)
-						}
-					}
-				}
+				argList := popMethodArgs(f, methodType)
 
 				destLocal := 0
 				for j := len(argList) - 1; j >= 0; j-- {
-					fram.Locals[destLocal] = argList[j]
+					fram.Locals.Store(destLocal, argList[j])
 					destLocal += 1
 				}
-				fram.TOS = -1
 
 				fs.PushFront(fram)                   // push the new frame
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
@@ -1153,34 +1019,32 @@ func runFrame(fs *list.List) error {
 				className = classloader.FetchUTF8stringFromCPEntryNumber(f.CP, utf8Index)
 			}
 
+			// Strings, References, and ReferenceQueues are Go objects, which their Go
+			// constructors initialize
+			if addr, ok := classloader.NewGoObject(className); ok {
+				if err := pushRef(f, addr); err != nil {
+					return err
+				}
+				break
+			}
+
 			ref, err := instantiateClass(className)
 			if err != nil {
 				_ = log.Log("Error instantiating class: "+className, log.SEVERE)
-				return errors.New("error instantiating class")
+				return err
 			}
 
-			// to push the object reference as an int64, it must first be converted to an unsafe pointer
-			rawRef := uintptr(unsafe.Pointer(ref))
-			push(f, int64(rawRef))
+			if err := pushRef(f, ref.addr); err != nil {
+				return err
+			}
 
-		case IFNULL: // 0xC6 jump if TOS holds a null address
-			// null = 0, so we duplicate logic of IFEQ instruction
-			value := pop(f).(int64)
-			if value == 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
-			}
-		case IFNONNULL: // 0xC7 jump if TOS does not hold a null address
-			// null = 0, so we duplicate logic of IFNE instruction
-			value := pop(f).(int64)
-			if value != 0 {
-				jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
-				f.PC = f.PC + int(jumpTo) - 1
-			} else {
-				f.PC += 2
+		case JSR_W: // 0xC9     (same as jsr, but with a 4-byte offset)
+			if err := pushRef(f, int64(f.PC+5)); err != nil {
+				return err
 			}
+			jumpTo := int32(f.Meth[f.PC+1])<<24 | int32(f.Meth[f.PC+2])<<16 |
+				int32(f.Meth[f.PC+3])<<8 | int32(f.Meth[f.PC+4])
+			f.PC = f.PC + int(jumpTo) - 1
 		default:
 			missingOpCode := fmt.Sprintf("%d (0x%X)", f.Meth[f.PC], f.Meth[f.PC])
 
@@ -1188,8 +1052,8 @@ func runFrame(fs *list.List) error {
 				missingOpCode += fmt.Sprintf(" (%s)", BytecodeNames[f.Meth[f.PC]])
 			}
 
-			msg := fmt.Sprintf("Invalid bytecode found: %s at location %d in method %s() of class %s\n",
-				missingOpCode, f.PC, f.MethName, f.ClName)
+			msg := fmt.Sprintf("Invalid bytecode found: %s at location %d in method %s() of class %s\n\tat %s\n",
+				missingOpCode, f.PC, f.MethName, f.ClName, frameLocation(f))
 			_ = log.Log(msg, log.SEVERE)
 			return errors.New("invalid bytecode encountered")
 		}
@@ -1198,33 +1062,65 @@ func runFrame(fs *list.List) error {
 	return nil
 }
 
-// pop from the operand stack. TODO: need to put in checks for invalid pops
+// pop from the operand stack. The value is returned as the interpreter holds it: an
+// int64 for the int types, longs, and references, and a float64 for floats and doubles.
+// A long or double takes up two slots, so it's popped twice. TODO: need to put in checks
+// for invalid pops
 func pop(f *frames.Frame) interface{} {
-	value := f.OpStack[f.TOS]
-	f.TOS -= 1
-	return value
+	return f.Operands.Pop()
 }
 
 // returns the value at the top of the stack without popping it off.
 func peek(f *frames.Frame) interface{} {
-	return f.OpStack[f.TOS]
+	return f.Operands.Peek()
+}
+
+// push onto the operand stack. An int64 is tagged as an int and a float64 as a float
+// (a frames.StackValue keeps its tag), so longs, doubles, and references are pushed
+// with pushLong(), pushDouble(), and pushRef() instead. If the value doesn't fit, a
+// StackOverflowError is thrown and returned (see operandStackError()).
+func push(f *frames.Frame, x interface{}) error {
+	return operandStackError(f.Operands.Push(x))
 }
 
-// push onto the operand stack
-func push(f *frames.Frame, x interface{}) {
-	f.TOS += 1
-	f.OpStack[f.TOS] = x
+// pushes a long, which takes up two slots on the operand stack
+func pushLong(f *frames.Frame, value int64) error {
+	return operandStackError(f.Operands.PushLong(value))
 }
 
-func add[N frames.Number](num1, num2 N) N {
-	return num1 + num2
+// pushes a double, which takes up two slots on the operand stack
+func pushDouble(f *frames.Frame, value float64) error {
+	return operandStackError(f.Operands.PushDouble(value))
 }
 
-// multiply two numbers
-func multiply[N frames.Number](num1, num2 N) N {
-	return num1 * num2
+// pushes a reference (an object's address, or null as 0) or a returnAddress
+func pushRef(f *frames.Frame, ref interface{}) error {
+	return operandStackError(f.Operands.PushRef(ref))
 }
 
-func subtract[N frames.Number](num1, num2 N) N {
-	return num1 - num2
+// A method's max_stack is computed so that its bytecode never overflows the operand
+// stack, so an overflow means the class file is broken. Like a frame stack that's too
+// deep, it's thrown as a StackOverflowError. Other errors are returned unchanged.
+func operandStackError(err error) error {
+	if errors.Is(err, frames.ErrOperandStackOverflow) {
+		return throwStackOverflowError()
+	}
+	return err
+}
+
+// pushReturnValue pushes the value returned by the Go method methName onto the operand
+// stack of its caller, f. slotCount is the number of slots it takes up (see runGframe()),
+// and the method's return type tells a reference from an int.
+func pushReturnValue(f *frames.Frame, retval interface{}, slotCount int, methName string) error {
+	returnType := methName[strings.LastIndex(methName, ")")+1:]
+	switch {
+	case slotCount == 2 && strings.HasPrefix(returnType, "D"):
+		return pushDouble(f, retval.(float64))
+	case slotCount == 2:
+		return pushLong(f, retval.(int64))
+	case strings.HasPrefix(returnType, "L") || strings.HasPrefix(returnType, "["):
+		return pushRef(f, retval)
+	default: // an int64, or a float64 for floats
+		return push(f, retval)
+	}
 }
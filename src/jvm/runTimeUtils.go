@@ -7,7 +7,12 @@
 package jvm
 
 import (
+	"fmt"
 	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/util"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -79,13 +84,13 @@ func FetchCPentry(cpp *classloader.CPool, index int) cpType {
 		retFloat := cp.Doubles[entry.Slot]
 		return cpType{entryType: int(entry.Type), retType: IS_FLOAT64, floatVal: retFloat}
 
-	// addresses of strings
+	// addresses of strings, which are the interned String for the UTF-8 entry
 	case classloader.ClassRef: // points to a UTF-8 string
-		v := unsafe.Pointer(&(cp.Utf8Refs[entry.Slot]))
+		v := classloader.StringConstant(cp.Utf8Refs[entry.Slot])
 		return cpType{entryType: int(entry.Type), retType: IS_STRING_ADDR, addrVal: uintptr(v)}
 
 	case classloader.UTF8: // same code as for ClassRef
-		v := unsafe.Pointer(&(cp.Utf8Refs[entry.Slot]))
+		v := classloader.StringConstant(cp.Utf8Refs[entry.Slot])
 		return cpType{entryType: int(entry.Type), retType: IS_STRING_ADDR, addrVal: uintptr(v)}
 
 	// addresses of structures or other elements
@@ -122,3 +127,130 @@ func FetchCPentry(cpp *classloader.CPool, index int) cpType {
 
 	return cpType{entryType: 0, retType: IS_ERROR}
 }
+
+// resolveDynamicConstant is called when ldc, ldc_w, or ldc2_w loads a CONSTANT_Dynamic
+// entry. Computing the constant requires running its bootstrap method, which is not
+// yet supported, so the class loads but this throws a BootstrapMethodError naming the
+// constant.
+func resolveDynamicConstant(cp *classloader.CPool, index int) error {
+	dyn := cp.Dynamics[cp.CpIndex[index].Slot]
+	name, desc := "", ""
+	if int(dyn.NameAndType) < len(cp.CpIndex) && cp.CpIndex[dyn.NameAndType].Type == classloader.NameAndType {
+		nAndT := cp.NameAndTypes[cp.CpIndex[dyn.NameAndType].Slot]
+		name = classloader.FetchUTF8stringFromCPEntryNumber(cp, nAndT.NameIndex)
+		desc = classloader.FetchUTF8stringFromCPEntryNumber(cp, nAndT.DescIndex)
+	}
+	return throwBootstrapMethodError(fmt.Sprintf(
+		"cannot compute dynamic constant %s:%s (CP entry %d, bootstrap method %d): "+
+			"bootstrap methods are not yet supported", name, desc, index, dyn.BootstrapIndex))
+}
+
+// verifyArgsOnStack checks, before any arguments are popped, that the operand stack
+// holds all the arguments of the method being invoked, plus the object reference if
+// hasReceiver is set. Bytecode that passed verification always does, so a shortfall
+// is reported as a VerifyError rather than allowed to cause a panic when popping.
+func verifyArgsOnStack(f *frames.Frame, methodName, methodType string, hasReceiver bool) error {
+	need := util.ParamSlotsFromMethTypeString(methodType)
+	if hasReceiver {
+		need += 1
+	}
+	if have := f.Operands.Depth(); have < need {
+		return throwVerifyError(fmt.Sprintf("insufficient stack for method %s%s: need %d, have %d",
+			strings.ReplaceAll(methodName, "/", "."), methodType, need, have))
+	}
+	return nil
+}
+
+// localVarOperand returns the index of the first local variable used by the
+// instruction at f.PC and how many slots the interpreter accesses there: 2 for the
+// stores of a long or double that fill both of its slots, else 1. It returns 0 slots
+// for an instruction that doesn't use the local variables.
+func localVarOperand(f *frames.Frame) (index, slots int) {
+	opcode := int(f.Meth[f.PC])
+	hasIndexByte := f.PC+1 < len(f.Meth)
+	switch {
+	case (opcode >= ILOAD && opcode <= ALOAD || opcode == IINC || opcode == RET) && hasIndexByte:
+		return int(f.Meth[f.PC+1]), 1
+	case opcode >= ISTORE && opcode <= ASTORE && hasIndexByte:
+		if opcode == LSTORE || opcode == DSTORE {
+			return int(f.Meth[f.PC+1]), 2
+		}
+		return int(f.Meth[f.PC+1]), 1
+	case opcode >= ILOAD_0 && opcode <= ALOAD_3: // an instruction for each of locals 0-3
+		return (opcode - ILOAD_0) % 4, 1
+	case opcode >= LSTORE_0 && opcode <= LSTORE_3:
+		return opcode - LSTORE_0, 2
+	case opcode >= ISTORE_0 && opcode <= ASTORE_3:
+		return (opcode - ISTORE_0) % 4, 1
+	}
+	return 0, 0
+}
+
+// verifyLocalVar checks that the local variables used by the instruction at f.PC are
+// within the frame's local variables, whose number is the method's max_locals. As with
+// verifyArgsOnStack, bytecode that passed verification always is, so a bad index is
+// reported as a VerifyError rather than allowed to cause a panic.
+func verifyLocalVar(f *frames.Frame) error {
+	index, slots := localVarOperand(f)
+	if slots > 0 && index+slots > f.Locals.Size() {
+		return throwVerifyError(fmt.Sprintf("illegal local variable number %d in method %s.%s at %d: "+
+			"the method has %d local variables", index+slots-1, strings.ReplaceAll(f.ClName, "/", "."),
+			f.MethName, f.PC, f.Locals.Size()))
+	}
+	return nil
+}
+
+// localVarTrace returns what the instruction trace shows about the local variable used
+// by the instruction at f.PC, if any: its slot and, if the method has a
+// LocalVariableTable, its name, as in ", local: 1 (args)". A variable that's stored
+// to comes into scope only after the store, so the following instruction (which is
+// at most two bytes later) is checked as well.
+func localVarTrace(f *frames.Frame) string {
+	index, slots := localVarOperand(f)
+	if slots == 0 {
+		return ""
+	}
+	trace := ", local: " + strconv.Itoa(index)
+	for pc := f.PC; pc <= f.PC+2; pc++ {
+		if v := f.LocalVarTable.At(index, pc); v != nil {
+			return trace + " (" + f.CP.Utf8Refs[v.Name] + ")"
+		}
+	}
+	return trace
+}
+
+// popMethodArgs pops the arguments of a method with the given signature off the
+// operand stack of frame f, with their types. The arguments are returned in the order
+// they were popped, that is, last argument first. Longs and doubles take two entries,
+// as they do on the operand stack and in the locals of the called method.
+func popMethodArgs(f *frames.Frame, methodType string) []frames.StackValue {
+	var argList []frames.StackValue
+	for i := util.ParamSlotsFromMethTypeString(methodType); i > 0; i-- {
+		argList = append(argList, f.Operands.PopValue())
+	}
+	return argList
+}
+
+// frameLocation returns the location of the instruction being executed in the frame,
+// in the form Java's stack traces use: Hello2.main(Hello2.java:12). The line is left
+// out if the method has no line numbers, and the source file is Unknown Source if
+// the class has no SourceFile attribute. Methods implemented in Go are Native Methods.
+func frameLocation(f *frames.Frame) string {
+	methName := f.MethName
+	if paren := strings.Index(methName, "("); paren >= 0 { // Go methods' names include their type
+		methName = methName[:paren]
+	}
+	location := strings.ReplaceAll(f.ClName, "/", ".") + "." + methName
+	if f.Ftype == 'G' {
+		return location + "(Native Method)"
+	}
+
+	k, ok := classloader.LookupClass(f.ClName)
+	if !ok || k.Data == nil || k.Data.SourceFile == "" {
+		return location + "(Unknown Source)"
+	}
+	if line := f.LineNumbers.Line(f.PC); line >= 0 {
+		return location + "(" + k.Data.SourceFile + ":" + strconv.Itoa(line) + ")"
+	}
+	return location + "(" + k.Data.SourceFile + ")"
+}
@@ -7,7 +7,9 @@
 package jvm
 
 import (
+	"errors"
 	"io"
+	"jacobin/classbuilder"
 	"jacobin/classloader"
 	"jacobin/frames"
 	"jacobin/globals"
@@ -31,6 +33,20 @@ func newFrame(code byte) frames.Frame {
 	return *f
 }
 
+// sets the local variables of f to values, each tagged with its type as the interpreter
+// holds it (see frames.StackValueOf())
+func setLocals(f *frames.Frame, values ...interface{}) {
+	f.Locals = frames.NewLocalVars(len(values))
+	for i, v := range values {
+		f.Locals.Store(i, frames.StackValueOf(v))
+	}
+}
+
+// returns the value of the local variable at index in f, as the interpreter holds it
+func local(f *frames.Frame, index int) interface{} {
+	return f.Locals.Load(index).Value()
+}
+
 var zero = int64(0)
 var zerof = float64(0)
 
@@ -54,8 +70,8 @@ func TestAconstNull(t *testing.T) {
 	if x != 0 {
 		t.Errorf("ACONST_NULL: Expecting 0 on stack, got: %d", x)
 	}
-	if f.TOS != 0 {
-		t.Errorf("ACONST_NULL: Expecting TOS = 0, but tos is: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("ACONST_NULL: Expecting TOS = 0, but tos is: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -63,11 +79,7 @@ func TestAconstNull(t *testing.T) {
 func TestAload(t *testing.T) {
 	f := newFrame(ALOAD)
 	f.Meth = append(f.Meth, 0x04) // use local var #4
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234562)) // put value in locals[4]
+	setLocals(&f, zero, zero, zero, zero, int64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -76,8 +88,8 @@ func TestAload(t *testing.T) {
 	if x != 0x1234562 {
 		t.Errorf("ALOAD: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ALOAD: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ALOAD: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 	if f.PC != 2 {
 		t.Errorf("ALOAD: Expected pc to be pointing at byte 2, got: %d", f.PC)
@@ -87,7 +99,7 @@ func TestAload(t *testing.T) {
 // ALOAD_0: test load of reference in locals[0] on to stack
 func TestAload0(t *testing.T) {
 	f := newFrame(ALOAD_0)
-	f.Locals = append(f.Locals, int64(0x1234560)) // put value in locals[0]
+	setLocals(&f, int64(0x1234560)) // put value in locals[0]
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -96,16 +108,15 @@ func TestAload0(t *testing.T) {
 	if x != 0x1234560 {
 		t.Errorf("ALOAD_0: Expecting 0x1234560 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ALOAD_0: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ALOAD_0: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ALOAD_1: test load of reference in locals[1] on to stack
 func TestAload1(t *testing.T) {
 	f := newFrame(ALOAD_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234561)) // put value in locals[1]
+	setLocals(&f, zero, int64(0x1234561))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -114,17 +125,15 @@ func TestAload1(t *testing.T) {
 	if x != 0x1234561 {
 		t.Errorf("ALOAD_1: Expecting 0x1234561 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ALOAD_1: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ALOAD_1: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ALOAD_2: test load of reference in locals[2] on to stack
 func TestAload2(t *testing.T) {
 	f := newFrame(ALOAD_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234562)) // put value in locals[2]
+	setLocals(&f, zero, zero, int64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -133,18 +142,15 @@ func TestAload2(t *testing.T) {
 	if x != 0x1234562 {
 		t.Errorf("ALOAD_2: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ALOAD_2: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ALOAD_2: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ALOAD_3: test load of reference in locals[3] on to stack
 func TestAload3(t *testing.T) {
 	f := newFrame(ALOAD_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234563)) // put value in locals[3]
+	setLocals(&f, zero, zero, zero, int64(0x1234563))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -153,8 +159,8 @@ func TestAload3(t *testing.T) {
 	if x != 0x1234563 {
 		t.Errorf("ALOAD_3: Expecting 0x1234563 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ALOAD_3: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ALOAD_3: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -163,99 +169,90 @@ func TestAstore(t *testing.T) {
 	f := newFrame(ASTORE)
 	f.Meth = append(f.Meth, 0x03) // use local var #4
 
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(0x22223))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[3] != int64(0x22223) {
-		t.Errorf("ASTORE: Expecting 0x22223 in locals[3], got: 0x%x", f.Locals[3])
+	if local(&f, 3) != int64(0x22223) {
+		t.Errorf("ASTORE: Expecting 0x22223 in locals[3], got: 0x%x", local(&f, 3))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ASTORE: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ASTORE: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ASTORE_0: test store of reference from stack into locals[0]
 func TestAstore0(t *testing.T) {
 	f := newFrame(ASTORE_0)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero)
 	push(&f, int64(0x22220))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[0] != int64(0x22220) {
-		t.Errorf("ASTORE_0: Expecting 0x22220 on stack, got: 0x%x", f.Locals[0])
+	if local(&f, 0) != int64(0x22220) {
+		t.Errorf("ASTORE_0: Expecting 0x22220 on stack, got: 0x%x", local(&f, 0))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ASTORE_0: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ASTORE_0: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ASTORE_1: test store of reference from stack into locals[1]
 func TestAstore1(t *testing.T) {
 	f := newFrame(ASTORE_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero)
 	push(&f, int64(0x22221))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[1] != int64(0x22221) {
-		t.Errorf("ASTORE_1: Expecting 0x22221 on stack, got: 0x%x", f.Locals[0])
+	if local(&f, 1) != int64(0x22221) {
+		t.Errorf("ASTORE_1: Expecting 0x22221 on stack, got: 0x%x", local(&f, 0))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ASTORE_1: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ASTORE_1: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ASTORE_2: test store of reference from stack into locals[2]
 func TestAstore2(t *testing.T) {
 	f := newFrame(ASTORE_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero)
 	push(&f, int64(0x22222))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != int64(0x22222) {
-		t.Errorf("ASTORE_2: Expecting 0x22222 on stack, got: 0x%x", f.Locals[0])
+	if local(&f, 2) != int64(0x22222) {
+		t.Errorf("ASTORE_2: Expecting 0x22222 on stack, got: 0x%x", local(&f, 0))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ASTORE_2: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ASTORE_2: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ASTORE3: store of reference from stack into locals[3]
 func TestAstore3(t *testing.T) {
 	f := newFrame(ASTORE_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(0x22223))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[3] != int64(0x22223) {
-		t.Errorf("ASTORE_3: Expecting 0x22223 on stack, got: 0x%x", f.Locals[0])
+	if local(&f, 3) != int64(0x22223) {
+		t.Errorf("ASTORE_3: Expecting 0x22223 on stack, got: 0x%x", local(&f, 0))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ASTORE_3: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ASTORE_3: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -266,8 +263,8 @@ func TestBipush(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("BIPUSH: Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("BIPUSH: Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 5 {
@@ -289,8 +286,8 @@ func TestD2f(t *testing.T) {
 	if math.Abs(val-2.9) > maxFloatDiff {
 		t.Errorf("D2F: expected a result of 2.9, but got: %f", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("D2F: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("D2F: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -308,8 +305,8 @@ func TestD2iPositive(t *testing.T) {
 	if val != 2 {
 		t.Errorf("D2I: expected a result of 2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("D2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("D2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -327,8 +324,8 @@ func TestD2iNegative(t *testing.T) {
 	if val != -2 {
 		t.Errorf("D2I: expected a result of -2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("D2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("D2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -347,8 +344,8 @@ func TestD2lPositive(t *testing.T) {
 	if val != 2 {
 		t.Errorf("D2L: expected a result of 2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("D2L: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("D2L: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -367,8 +364,8 @@ func TestD2lNegative(t *testing.T) {
 	if val != -2 {
 		t.Errorf("D2L: expected a result of -2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("D2L: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("D2L: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -387,8 +384,8 @@ func TestDadd(t *testing.T) {
 	pop(&f)
 	val := pop(&f).(float64)
 	validateFloatingPoint(t, "DADD", 37.4, val)
-	if f.TOS != -1 {
-		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -411,8 +408,8 @@ func TestDaddNan(t *testing.T) {
 		t.Errorf("DADD: Expected NaN, got: %f", val)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -435,8 +432,8 @@ func TestDaddInf(t *testing.T) {
 		t.Errorf("DADD: Expected Inf, got: %f", val)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DADD: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -458,8 +455,8 @@ func TestDcmpg1(t *testing.T) {
 		t.Errorf("DCMPG: Expected value to be 1, got: %d", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -481,8 +478,8 @@ func TestDcmpgMinus1(t *testing.T) {
 		t.Errorf("DCMPG: Expected value to be -1, got: %d", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -504,8 +501,8 @@ func TestDcmpg0(t *testing.T) {
 		t.Errorf("DCMPG: Expected value to be 0, got: %d", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -526,8 +523,8 @@ func TestDcmpgNan(t *testing.T) {
 		t.Errorf("DCMPG: Expected value to be 1, got: %d", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -549,8 +546,8 @@ func TestDcmplNan(t *testing.T) {
 		t.Errorf("DCMPL: Expected value to be -1, got: %d", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -568,8 +565,8 @@ func TestDconst0(t *testing.T) {
 		t.Errorf("DCONST_0: Expected popped value to be 0.0, got: %f", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DCONST_0: Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DCONST_0: Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -587,8 +584,8 @@ func TestDconst1(t *testing.T) {
 		t.Errorf("DCONST_1: Expected popped value to be 1.0, got: %f", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -607,8 +604,8 @@ func TestDdiv(t *testing.T) {
 	pop(&f)
 	value := pop(&f).(float64)
 	validateFloatingPoint(t, "DDIV", 1.5, value)
-	if f.TOS != -1 {
-		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DDIV: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -616,11 +613,7 @@ func TestDdiv(t *testing.T) {
 func TestDload(t *testing.T) {
 	f := newFrame(DLOAD)
 	f.Meth = append(f.Meth, 0x04) // use local var #4
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, float64(0x1234562)) // put value in locals[4]
+	setLocals(&f, zerof, zerof, zerof, zerof, float64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -630,8 +623,8 @@ func TestDload(t *testing.T) {
 	if x != float64(0x1234562) {
 		t.Errorf("DLOAD: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("DLOAD: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DLOAD: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 	if f.PC != 2 {
 		t.Errorf("DLOAD: Expected pc to be pointing at byte 2, got: %d", f.PC)
@@ -641,7 +634,7 @@ func TestDload(t *testing.T) {
 // DLOAD_0: load of double in locals[0] onto stack
 func TestDload0(t *testing.T) {
 	f := newFrame(DLOAD_0)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.2)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -651,16 +644,15 @@ func TestDload0(t *testing.T) {
 	value := pop(&f).(float64)
 	validateFloatingPoint(t, "DLOAD_0", 1.2, value)
 
-	if f.TOS != -1 {
-		t.Errorf("DLOAD_0: Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DLOAD_0: Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DLOAD_1: load of double in locals[1] onto stack
 func TestDload1(t *testing.T) {
 	f := newFrame(DLOAD_1)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.3, 1.2)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -670,17 +662,15 @@ func TestDload1(t *testing.T) {
 	value := pop(&f).(float64)
 	validateFloatingPoint(t, "DLOAD_1", 1.2, value)
 
-	if f.TOS != -1 {
-		t.Errorf("DLOAD_1: Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DLOAD_1: Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DLOAD_2: load of double in locals[2] onto stack
 func TestDload2(t *testing.T) {
 	f := newFrame(DLOAD_2)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.3, 1.3, 1.2)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -690,18 +680,15 @@ func TestDload2(t *testing.T) {
 	value := pop(&f).(float64)
 	validateFloatingPoint(t, "DLOAD_2", 1.2, value)
 
-	if f.TOS != -1 {
-		t.Errorf("DLOAD_2: Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DLOAD_2: Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DLOAD_3: load of double in locals[3] onto stack
 func TestDload3(t *testing.T) {
 	f := newFrame(DLOAD_3)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.3)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.3, 1.3, 1.3, 1.2)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -711,8 +698,8 @@ func TestDload3(t *testing.T) {
 	value := pop(&f).(float64)
 	validateFloatingPoint(t, "DLOAD_3", 1.2, value)
 
-	if f.TOS != -1 {
-		t.Errorf("DLOAD_3: Expected empty stack, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DLOAD_3: Expected empty stack, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -731,8 +718,8 @@ func TestDmul(t *testing.T) {
 	pop(&f)
 	validateFloatingPoint(t, "DMUL", 3.0, pop(&f).(float64))
 
-	if f.TOS != -1 {
-		t.Errorf("DMUL, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DMUL, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -749,8 +736,8 @@ func TestDneg(t *testing.T) {
 	pop(&f)
 	validateFloatingPoint(t, "DNEG", -1.5, pop(&f).(float64))
 
-	if f.TOS != -1 {
-		t.Errorf("DNEG, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DNEG, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -771,8 +758,8 @@ func TestDnegInf(t *testing.T) {
 		t.Errorf("Expected negative infinity, got %f", val)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -788,10 +775,11 @@ func TestDrem(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 {
-		t.Errorf("DREM, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 { // a double takes up two slots
+		t.Errorf("DREM, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
+	pop(&f)
 	value := pop(&f).(float64)
 	if math.Abs(value-0.40000033) > maxFloatDiff {
 		t.Errorf("DREM: Expected popped value to be 0.40000033, got: %f", value)
@@ -827,10 +815,7 @@ func TestDreturn(t *testing.T) {
 func TestDstore(t *testing.T) {
 	f := newFrame(DSTORE)
 	f.Meth = append(f.Meth, 0x02) // use local var #2
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
+	setLocals(&f, zerof, zerof, zerof, zerof)
 
 	push(&f, float64(0x22223)) // pushed twice due to double using two slots
 	push(&f, float64(0x22223))
@@ -838,23 +823,23 @@ func TestDstore(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != float64(0x22223) {
-		t.Errorf("DSTORE: Expecting 0x22223 in locals[2], got: 0x%x", f.Locals[2])
+	if local(&f, 2) != float64(0x22223) {
+		t.Errorf("DSTORE: Expecting 0x22223 in locals[2], got: 0x%x", local(&f, 2))
 	}
 
-	if f.Locals[3] != float64(0x22223) {
-		t.Errorf("DSTORE: Expecting 0x22223 in locals[3], got: 0x%x", f.Locals[3])
+	if local(&f, 3) != float64(0x22223) {
+		t.Errorf("DSTORE: Expecting 0x22223 in locals[3], got: 0x%x", local(&f, 3))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSTORE: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSTORE: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DSTORE_0: Store double from stack into localVar[0]
 func TestDstore0(t *testing.T) {
 	f := newFrame(DSTORE_0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0)
 	push(&f, 1.0)
 	push(&f, 1.0)
 
@@ -862,20 +847,19 @@ func TestDstore0(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[0].(float64) != 1.0 {
-		t.Errorf("DSTORE_0: expected locals[0] to be 1.0, got: %f", f.Locals[0].(float64))
+	if local(&f, 0).(float64) != 1.0 {
+		t.Errorf("DSTORE_0: expected locals[0] to be 1.0, got: %f", local(&f, 0).(float64))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSTORE_0: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSTORE_0: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DSTORE_1: Store double from stack into localVar[1]
 func TestDstore1(t *testing.T) {
 	f := newFrame(DSTORE_1)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0)
 	push(&f, 1.0)
 	push(&f, 1.0)
 
@@ -883,21 +867,19 @@ func TestDstore1(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[1].(float64) != 1.0 {
-		t.Errorf("DSTORE_1: expected locals[1] to be 1.0, got: %f", f.Locals[1].(float64))
+	if local(&f, 1).(float64) != 1.0 {
+		t.Errorf("DSTORE_1: expected locals[1] to be 1.0, got: %f", local(&f, 1).(float64))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSTORE_1: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSTORE_1: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DSTORE_2: Store double from stack into localVar[2]
 func TestDstore2(t *testing.T) {
 	f := newFrame(DSTORE_2)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0, 0.0)
 	push(&f, 1.0)
 	push(&f, 1.0)
 
@@ -905,22 +887,19 @@ func TestDstore2(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2].(float64) != 1.0 {
-		t.Errorf("DSTORE_2: expected locals[2] to be 1.0, got: %f", f.Locals[2].(float64))
+	if local(&f, 2).(float64) != 1.0 {
+		t.Errorf("DSTORE_2: expected locals[2] to be 1.0, got: %f", local(&f, 2).(float64))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSTORE_2: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSTORE_2: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // DSTORE_3: Store double from stack into localVar[3]
 func TestDstore3(t *testing.T) {
 	f := newFrame(DSTORE_3)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0, 0.0, 0.0)
 	push(&f, 1.0)
 	push(&f, 1.0)
 
@@ -928,12 +907,12 @@ func TestDstore3(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[3].(float64) != 1.0 {
-		t.Errorf("DSTORE_3: expected locals[3] to be 1.0, got: %f", f.Locals[3].(float64))
+	if local(&f, 3).(float64) != 1.0 {
+		t.Errorf("DSTORE_3: expected locals[3] to be 1.0, got: %f", local(&f, 3).(float64))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSTORE_3: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSTORE_3: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -956,8 +935,8 @@ func TestDsub(t *testing.T) {
 		t.Errorf("DSUB: Expected popped value to be 0.3, got: %f", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSUB, Empty stack expected, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSUB, Empty stack expected, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -969,8 +948,8 @@ func TestDup(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS < 1 {
-		t.Errorf("DUP: stack should have two elements with tos > 0, tos was: %d", f.TOS)
+	if f.Operands.Depth() < 2 {
+		t.Errorf("DUP: stack should have two elements with tos > 0, tos was: %d", f.Operands.Depth()-1)
 	}
 
 	a := pop(&f).(int64)
@@ -981,6 +960,32 @@ func TestDup(t *testing.T) {
 	}
 }
 
+// the operand stack tags each value with its type, which loads from local variables
+// and DUP carry along
+func TestOperandStackKeepsTypes(t *testing.T) {
+	f := newFrame(ALOAD_0)
+	f.Meth = append(f.Meth, DUP, DCONST_1)
+	f.Locals = frames.NewLocalVars(1)
+	_ = f.Locals.SetRef(0, int64(0x100)) // the address of an object
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+
+	expected := []int{frames.RefValue, frames.RefValue, frames.DoubleValue, frames.DoubleValue}
+	slots := f.Operands.Slots()
+	if len(slots) != len(expected) {
+		t.Fatalf("Expected %d slots on the stack, got: %d", len(expected), len(slots))
+	}
+	for i, tag := range expected {
+		if slots[i].Tag != tag {
+			t.Errorf("Expected slot %d to have tag %d, got: %d", i, tag, slots[i].Tag)
+		}
+	}
+	if pop(&f) != 1.0 || pop(&f) != 1.0 || pop(&f) != int64(0x100) {
+		t.Error("Expected the double 1.0 above the object's address")
+	}
+}
+
 // DUP2: Push duplicate of the top two items on the stack
 func TestDup2(t *testing.T) {
 	f := newFrame(DUP2)
@@ -991,8 +996,8 @@ func TestDup2(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 3 {
-		t.Errorf("DUP2: stack should have four elements, got tos was: %d", f.TOS)
+	if f.Operands.Depth() != 4 {
+		t.Errorf("DUP2: stack should have four elements, got tos was: %d", f.Operands.Depth()-1)
 	}
 
 	a := pop(&f).(int64)
@@ -1019,8 +1024,8 @@ func TestDupX1(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 3 {
-		t.Errorf("DUP_X1: Expecting a top of stack = 3 (so stack size 4), got: %d", f.TOS)
+	if f.Operands.Depth() != 4 {
+		t.Errorf("DUP_X1: Expecting a top of stack = 3 (so stack size 4), got: %d", f.Operands.Depth()-1)
 	}
 
 	a := pop(&f).(int64)
@@ -1042,8 +1047,8 @@ func TestDupX2(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 3 {
-		t.Errorf("DUP_X2: Expecting a top of stack = 3 (so stack size 4), got: %d", f.TOS)
+	if f.Operands.Depth() != 4 {
+		t.Errorf("DUP_X2: Expecting a top of stack = 3 (so stack size 4), got: %d", f.Operands.Depth()-1)
 	}
 
 	a := pop(&f).(int64)
@@ -1069,8 +1074,8 @@ func TestF2d(t *testing.T) {
 	if val != 2.0 {
 		t.Errorf("F2D: expected a result of 2.0, but got: %f", val)
 	}
-	if f.TOS != 0 {
-		t.Errorf("F2D: Expected stack with 1 item, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("F2D: Expected stack with 1 item, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1087,8 +1092,8 @@ func TestF2iPositive(t *testing.T) {
 	if val != 2 {
 		t.Errorf("F2I: expected a result of 2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("F2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("F2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1104,8 +1109,8 @@ func TestF2iNegative(t *testing.T) {
 	if val != -2 {
 		t.Errorf("F2I: expected a result of 2, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("F2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("F2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1122,8 +1127,8 @@ func TestF2l(t *testing.T) {
 	if val != 2 {
 		t.Errorf("F2L: expected a result of 2.0, but got: %d", val)
 	}
-	if f.TOS != 0 {
-		t.Errorf("F2L: Expected stack with 1 item, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("F2L: Expected stack with 1 item, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1139,8 +1144,8 @@ func TestFadd(t *testing.T) {
 	if math.Abs(value-5.2) > maxFloatDiff {
 		t.Errorf("FADD: expected a result of 5.2, but got: %f", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("FADD: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FADD: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1150,8 +1155,8 @@ func TestFconst0(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 0.0 {
@@ -1165,8 +1170,8 @@ func TestFconst1(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 1.0 {
@@ -1180,8 +1185,8 @@ func TestFconst2(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 2.0 {
@@ -1207,11 +1212,7 @@ func TestFdiv(t *testing.T) {
 func TestFload(t *testing.T) {
 	f := newFrame(FLOAD)
 	f.Meth = append(f.Meth, 0x04) // use local var #4
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, float64(0x1234562)) // put value in locals[4]
+	setLocals(&f, zero, zero, zero, zero, float64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -1220,8 +1221,8 @@ func TestFload(t *testing.T) {
 	if x != float64(0x1234562) {
 		t.Errorf("FLOAD: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("FLOAD: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FLOAD: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 	if f.PC != 2 {
 		t.Errorf("FLOAD: Expected pc to be pointing at byte 2, got: %d", f.PC)
@@ -1231,12 +1232,12 @@ func TestFload(t *testing.T) {
 // FLOAD_0: load of float in locals[0] onto stack
 func TestFload0(t *testing.T) {
 	f := newFrame(FLOAD_0)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.2)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 1.2 {
@@ -1247,13 +1248,12 @@ func TestFload0(t *testing.T) {
 // FLOAD_1: load of float in locals[1] onto stack
 func TestFload1(t *testing.T) {
 	f := newFrame(FLOAD_1)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.1, 1.2)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 1.2 {
@@ -1264,14 +1264,12 @@ func TestFload1(t *testing.T) {
 // FLOAD_2: load of float in locals[2] onto stack
 func TestFload2(t *testing.T) {
 	f := newFrame(FLOAD_2)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.1, 1.1, 1.2)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 1.2 {
@@ -1282,15 +1280,12 @@ func TestFload2(t *testing.T) {
 // FLOAD_3: load of fload in locals[3] onto stack
 func TestFload3(t *testing.T) {
 	f := newFrame(FLOAD_3)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.1)
-	f.Locals = append(f.Locals, 1.2)
+	setLocals(&f, 1.1, 1.1, 1.1, 1.2)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 1.2 {
@@ -1306,8 +1301,8 @@ func TestFmul(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("FMUL, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("FMUL, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 3.0 {
@@ -1324,8 +1319,8 @@ func TestFneg(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 {
-		t.Errorf("FNEG, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("FNEG, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(float64)
@@ -1345,8 +1340,8 @@ func TestFrem(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 {
-		t.Errorf("FREM, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("FREM, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(float64)
@@ -1359,93 +1354,83 @@ func TestFrem(t *testing.T) {
 func TestFstore(t *testing.T) {
 	f := newFrame(FSTORE)
 	f.Meth = append(f.Meth, 0x02) // use local var #2
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
-	f.Locals = append(f.Locals, zerof)
+	setLocals(&f, zerof, zerof, zerof, zerof)
 	push(&f, float64(0x22223))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != float64(0x22223) {
-		t.Errorf("FSTORE: Expecting 0x22223 in locals[2], got: 0x%x", f.Locals[2])
+	if local(&f, 2) != float64(0x22223) {
+		t.Errorf("FSTORE: Expecting 0x22223 in locals[2], got: 0x%x", local(&f, 2))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("FSTORE: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FSTORE: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // FSTORE_0: Store float from stack into localVar[0]
 func TestFstore0(t *testing.T) {
 	f := newFrame(FSTORE_0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0)
 	push(&f, 1.0)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[0].(float64) != 1.0 {
-		t.Errorf("FSTORE_0: expected lcoals[0] to be 1.0, got: %f", f.Locals[0].(float64))
+	if local(&f, 0).(float64) != 1.0 {
+		t.Errorf("FSTORE_0: expected lcoals[0] to be 1.0, got: %f", local(&f, 0).(float64))
 	}
-	if f.TOS != -1 {
-		t.Errorf("FSTORE_0: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FSTORE_0: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // FSTORE_1: Store float from stack into localVar[0]
 func TestFstore1(t *testing.T) {
 	f := newFrame(FSTORE_1)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0)
 	push(&f, 1.0)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[1].(float64) != 1.0 {
-		t.Errorf("FSTORE_1: expected lcoals[1] to be 1.0, got: %f", f.Locals[1].(float64))
+	if local(&f, 1).(float64) != 1.0 {
+		t.Errorf("FSTORE_1: expected lcoals[1] to be 1.0, got: %f", local(&f, 1).(float64))
 	}
-	if f.TOS != -1 {
-		t.Errorf("FSTORE_1: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FSTORE_1: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // FSTORE_2: Store float from stack into localVar[2]
 func TestFstore2(t *testing.T) {
 	f := newFrame(FSTORE_2)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0, 0.0)
 	push(&f, 1.0)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[2].(float64) != 1.0 {
-		t.Errorf("FSTORE_2: expected lcoals[2] to be 1.0, got: %f", f.Locals[2].(float64))
+	if local(&f, 2).(float64) != 1.0 {
+		t.Errorf("FSTORE_2: expected lcoals[2] to be 1.0, got: %f", local(&f, 2).(float64))
 	}
-	if f.TOS != -1 {
-		t.Errorf("FSTORE_2: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FSTORE_2: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // FSTORE_3: Store float from stack into localVar[3]
 func TestFstore3(t *testing.T) {
 	f := newFrame(FSTORE_3)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
-	f.Locals = append(f.Locals, 0.0)
+	setLocals(&f, 0.0, 0.0, 0.0, 0.0, 0.0)
 	push(&f, 1.0)
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[3].(float64) != 1.0 {
-		t.Errorf("FSTORE_3: expected lcoals[3] to be 1.0, got: %f", f.Locals[3].(float64))
+	if local(&f, 3).(float64) != 1.0 {
+		t.Errorf("FSTORE_3: expected lcoals[3] to be 1.0, got: %f", local(&f, 3).(float64))
 	}
-	if f.TOS != -1 {
-		t.Errorf("FSTORE_3: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("FSTORE_3: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1465,8 +1450,8 @@ func TestFsub(t *testing.T) {
 		t.Errorf("FSUB: Expected popped value to be 0.3, got: %f", value)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("DSUB, Empty stack expected, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("DSUB, Empty stack expected, got: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1502,7 +1487,72 @@ func TestGotoBackward(t *testing.T) {
 	}
 }
 
-// I2B: convert int to Java char (16-bit value)
+// JSR/RET: a pre-Java 6 finally block. locals[0] selects the branch, each branch
+// stores its value in locals[1], then both jsr to a subroutine that adds 100 to
+// locals[1] and rets to the return instruction.
+func runJsrFinally(branch int64) (frames.Frame, error) {
+	f := newFrame(ILOAD_0)
+	f.Meth = append(f.Meth,
+		IFEQ, 0x00, 0x09, // 1:  if locals[0] == 0 goto 10
+		BIPUSH, 0x01, // 4:  locals[1] = 1
+		ISTORE_1,         // 6
+		GOTO, 0x00, 0x06, // 7:  goto 13
+		BIPUSH, 0x02, // 10: locals[1] = 2
+		ISTORE_1,        // 12
+		JSR, 0x00, 0x04, // 13: jsr 17
+		RETURN,       // 16
+		ASTORE_2,     // 17: store the return address
+		ILOAD_1,      // 18
+		BIPUSH, 0x64, // 19: locals[1] += 100
+		IADD,      // 21
+		ISTORE_1,  // 22
+		RET, 0x02, // 23: return to the address in locals[2]
+	)
+	setLocals(&f, branch, zero, zero)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	return f, err
+}
+
+func TestJsrRetFinallyOnBothBranches(t *testing.T) {
+	f, err := runJsrFinally(1)
+	if err != nil {
+		t.Errorf("JSR/RET: unexpected error: %s", err.Error())
+	}
+	if local(&f, 1).(int64) != 101 {
+		t.Errorf("JSR/RET: expected locals[1] to be 101 on the first branch, got: %d", local(&f, 1).(int64))
+	}
+	if local(&f, 2).(int64) != 16 {
+		t.Errorf("JSR/RET: expected a return address of 16, got: %d", local(&f, 2).(int64))
+	}
+
+	f, err = runJsrFinally(0)
+	if err != nil {
+		t.Errorf("JSR/RET: unexpected error: %s", err.Error())
+	}
+	if local(&f, 1).(int64) != 102 {
+		t.Errorf("JSR/RET: expected locals[1] to be 102 on the second branch, got: %d", local(&f, 1).(int64))
+	}
+}
+
+// JSR_W: jsr with a 4-byte offset pushes the address of the instruction after it
+func TestJsrW(t *testing.T) {
+	f := newFrame(JSR_W)
+	f.Meth = append(f.Meth, 0x00, 0x00, 0x00, 0x07, NOP, RETURN, ASTORE_0, RET, 0x00)
+	setLocals(&f, zero)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	if local(&f, 0).(int64) != 5 {
+		t.Errorf("JSR_W: expected a return address of 5, got: %d", local(&f, 0).(int64))
+	}
+	if f.Meth[f.PC] != RETURN {
+		t.Errorf("JSR_W: Expected pc to point to RETURN, but instead it points to : %s", BytecodeNames[f.Meth[f.PC]])
+	}
+}
+
+// I2B: convert int to Java byte (8-bit value)
 func TestI2B(t *testing.T) {
 	f := newFrame(I2B)
 	push(&f, int64(2100))
@@ -1514,13 +1564,13 @@ func TestI2B(t *testing.T) {
 	if value != 52 {
 		t.Errorf("I2B: expected a result of 52, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("I2B: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("I2B: Expected stack with 1 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
-// I2B: convert int to Java char (16-bit value) using a negative value
-func TestI2Bneg(t *testing.T) { // TODO: check that this matches Java result
+// I2B: convert int to Java byte (8-bit value) using a negative value
+func TestI2Bneg(t *testing.T) {
 	f := newFrame(I2B)
 	push(&f, int64(-2100))
 
@@ -1528,11 +1578,11 @@ func TestI2Bneg(t *testing.T) { // TODO: check that this matches Java result
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 	value := pop(&f).(int64)
-	if value != -204 { // looks like 256-52
-		t.Errorf("I2B: expected a result of -204, but got: %d", value)
+	if value != -52 { // -2100 is 0xFFFFF7CC, and 0xCC as a signed byte is -52
+		t.Errorf("I2B: expected a result of -52, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("I2B: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("I2B: Expected stack with 1 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1548,8 +1598,8 @@ func TestI2C(t *testing.T) {
 	if value != 21 {
 		t.Errorf("I2C: expected a result of 21, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("I2C: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("I2C: Expected stack with 1 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1567,8 +1617,8 @@ func TestI2D(t *testing.T) {
 	if value != 21.0 {
 		t.Errorf("I2D: expected a result of 21.0, but got: %f", value)
 	}
-	if f.TOS != 0 {
-		t.Errorf("I2D: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("I2D: Expected stack with 1 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1584,8 +1634,8 @@ func TestI2f(t *testing.T) {
 	if value != 21.0 {
 		t.Errorf("I2F: expected a result of 21.0, but got: %f", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("I2F: Expected stack with 0 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("I2F: Expected stack with 0 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1604,8 +1654,8 @@ func TestI2l(t *testing.T) {
 	if value != 21 {
 		t.Errorf("I2L: expected a result of 21, but got: %d", value)
 	}
-	if f.TOS != 0 {
-		t.Errorf("I2L: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("I2L: Expected stack with 1 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1621,8 +1671,8 @@ func TestI2s(t *testing.T) {
 	if value != 21 {
 		t.Errorf("I2S: expected a result of 21, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("I2S: Expected stack with 0 entry, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("I2S: Expected stack with 0 entry, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1638,8 +1688,8 @@ func TestIadd(t *testing.T) {
 	if value != 43 {
 		t.Errorf("IADD: expected a result of 43, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("IADD: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("IADD: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1658,8 +1708,8 @@ func TestIand(t *testing.T) {
 	if value != 20 { // 21 & 22 = 20
 		t.Errorf("IAND: expected a result of 20, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("IAND: Expected an empty stack, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("IAND: Expected an empty stack, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -1730,8 +1780,8 @@ func TestIconstN1(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	var value = pop(&f).(int64)
 	if value != -1 {
@@ -1745,8 +1795,8 @@ func TestIconst0(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 0 {
@@ -1760,8 +1810,8 @@ func TestIconst1(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 1 {
@@ -1775,8 +1825,8 @@ func TestIconst2(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 2 {
@@ -1790,8 +1840,8 @@ func TestIconst3(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 3 {
@@ -1805,8 +1855,8 @@ func TestIconst4(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 4 {
@@ -1820,8 +1870,8 @@ func TestIconst5(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 5 {
@@ -2441,31 +2491,56 @@ func TestIfnullFallThrough(t *testing.T) {
 // IINC:
 func TestIinc(t *testing.T) {
 	f := newFrame(IINC)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(10)) // initialize local variable[1] to 10
-	f.Meth = append(f.Meth, 1)             // increment local variable[1]
-	f.Meth = append(f.Meth, 27)            // increment it by 27
+	setLocals(&f, zero, int64(10))
+	f.Meth = append(f.Meth, 1)  // increment local variable[1]
+	f.Meth = append(f.Meth, 27) // increment it by 27
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != -1 {
-		t.Errorf("Top of stack, expected -1, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("Top of stack, expected -1, got: %d", f.Operands.Depth()-1)
 	}
-	value := f.Locals[1]
+	value := local(&f, 1)
 	if value != int64(37) {
 		t.Errorf("IINC: Expected popped value to be 37, got: %d", value)
 	}
 }
 
+// IINC: increment Integer.MAX_VALUE by 1, which wraps around to Integer.MIN_VALUE
+func TestIincOverflow(t *testing.T) {
+	f := newFrame(IINC)
+	setLocals(&f, int64(math.MaxInt32)) // initialize local variable[0] to Integer.MAX_VALUE
+	f.Meth = append(f.Meth, 0)          // increment local variable[0]
+	f.Meth = append(f.Meth, 1)          // increment it by 1
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	value := local(&f, 0)
+	if value != int64(math.MinInt32) {
+		t.Errorf("IINC: Expected value to be -2147483648, got: %d", value)
+	}
+}
+
+// IINC: the constant is a signed byte, so 0xFF decrements the local by 1
+func TestIincNegative(t *testing.T) {
+	f := newFrame(IINC)
+	setLocals(&f, int64(math.MinInt32)) // initialize local variable[0] to Integer.MIN_VALUE
+	f.Meth = append(f.Meth, 0)          // decrement local variable[0]
+	f.Meth = append(f.Meth, 0xFF)       // by 1
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	value := local(&f, 0)
+	if value != int64(math.MaxInt32) {
+		t.Errorf("IINC: Expected value to be 2147483647, got: %d", value)
+	}
+}
+
 // ILOAD: test load of int in locals[index] on to stack
 func TestIload(t *testing.T) {
 	f := newFrame(ILOAD)
 	f.Meth = append(f.Meth, 0x04) // use local var #4
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234562)) // put value in locals[4]
+	setLocals(&f, zero, zero, zero, zero, int64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -2474,8 +2549,8 @@ func TestIload(t *testing.T) {
 	if x != 0x1234562 {
 		t.Errorf("ILOAD: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ILOAD: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ILOAD: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 	if f.PC != 2 {
 		t.Errorf("ILOAD: Expected pc to be pointing at byte 2, got: %d", f.PC)
@@ -2485,12 +2560,12 @@ func TestIload(t *testing.T) {
 // ILOAD_0: load of int in locals[0] onto stack
 func TestIload0(t *testing.T) {
 	f := newFrame(ILOAD_0)
-	f.Locals = append(f.Locals, int64(27))
+	setLocals(&f, int64(27))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != int64(27) {
@@ -2501,13 +2576,12 @@ func TestIload0(t *testing.T) {
 // ILOAD_1: load of int in locals[1] onto stack
 func TestIload1(t *testing.T) {
 	f := newFrame(ILOAD_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(27))
+	setLocals(&f, zero, int64(27))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 27 {
@@ -2518,14 +2592,12 @@ func TestIload1(t *testing.T) {
 // ILOAD_2: load of int in locals[2] onto stack
 func TestIload2(t *testing.T) {
 	f := newFrame(ILOAD_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(1))
-	f.Locals = append(f.Locals, int64(27))
+	setLocals(&f, zero, int64(1), int64(27))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != int64(27) {
@@ -2536,15 +2608,12 @@ func TestIload2(t *testing.T) {
 // ILOAD_3: load of int in locals[3] onto stack
 func TestIload3(t *testing.T) {
 	f := newFrame(ILOAD_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(1))
-	f.Locals = append(f.Locals, int64(2))
-	f.Locals = append(f.Locals, int64(27))
+	setLocals(&f, zero, int64(1), int64(2), int64(27))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 27 {
@@ -2560,8 +2629,8 @@ func TestImul(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("IMUL, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("IMUL, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 70 {
@@ -2578,8 +2647,8 @@ func TestIneg(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 {
-		t.Errorf("INEG, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("INEG, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -2603,8 +2672,8 @@ func TestIor(t *testing.T) {
 	if value != 23 { // 21 | 22 = 23
 		t.Errorf("IOR: expected a result of 23, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("IOR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("IOR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2618,8 +2687,8 @@ func TestIrem(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 { // product is pushed twice b/c it's a long, which occupies 2 slots
-		t.Errorf("IREM, Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 { // product is pushed twice b/c it's a long, which occupies 2 slots
+		t.Errorf("IREM, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -2665,8 +2734,8 @@ func TestIshl(t *testing.T) {
 	if value != 176 { // 22 << 3 = 176
 		t.Errorf("ISHL: expected a result of 176, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISHL: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISHL: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2685,8 +2754,8 @@ func TestIshr(t *testing.T) {
 	if value != 25 { // 200 >> 3 = 25
 		t.Errorf("ISHR: expected a result of 25, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISHR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISHR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2694,90 +2763,81 @@ func TestIshr(t *testing.T) {
 func TestIstore(t *testing.T) {
 	f := newFrame(ISTORE)
 	f.Meth = append(f.Meth, 0x02) // use local var #2
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(0x22223))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != int64(0x22223) {
-		t.Errorf("ISTORE: Expecting 0x22223 in locals[2], got: 0x%x", f.Locals[2])
+	if local(&f, 2) != int64(0x22223) {
+		t.Errorf("ISTORE: Expecting 0x22223 in locals[2], got: 0x%x", local(&f, 2))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("ISTORE: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISTORE: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ISTORE_0: Store integer from stack into localVar[0]
 func TestIstore0(t *testing.T) {
 	f := newFrame(ISTORE_0)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero)
 	push(&f, int64(220))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[0] != int64(220) {
-		t.Errorf("ISTORE_0: expected lcoals[0] to be 220, got: %d", f.Locals[0])
+	if local(&f, 0) != int64(220) {
+		t.Errorf("ISTORE_0: expected lcoals[0] to be 220, got: %d", local(&f, 0))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISTORE_0: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISTORE_0: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // ISTORE1
 func TestIstore1(t *testing.T) {
 	f := newFrame(ISTORE_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero)
 	push(&f, int64(221))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[1] != int64(221) {
-		t.Errorf("ISTORE_1: expected locals[1] to be 221, got: %d", f.Locals[1])
+	if local(&f, 1) != int64(221) {
+		t.Errorf("ISTORE_1: expected locals[1] to be 221, got: %d", local(&f, 1))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISTORE_1: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISTORE_1: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 func TestIstore2(t *testing.T) {
 	f := newFrame(ISTORE_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero)
 	push(&f, int64(222))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[2] != int64(222) {
-		t.Errorf("ISTORE_2: expected locals[2] to be 222, got: %d", f.Locals[2])
+	if local(&f, 2) != int64(222) {
+		t.Errorf("ISTORE_2: expected locals[2] to be 222, got: %d", local(&f, 2))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISTORE_2: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISTORE_2: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 func TestIstore3(t *testing.T) {
 	f := newFrame(ISTORE_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(223))
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.Locals[3] != int64(223) {
-		t.Errorf("ISTORE_3: expected locals[3] to be 223, got: %d", f.Locals[3])
+	if local(&f, 3) != int64(223) {
+		t.Errorf("ISTORE_3: expected locals[3] to be 223, got: %d", local(&f, 3))
 	}
-	if f.TOS != -1 {
-		t.Errorf("ISTORE_3: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("ISTORE_3: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2789,8 +2849,8 @@ func TestIsub(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("ISUB, Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("ISUB, Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 3 {
@@ -2810,11 +2870,11 @@ func TestIushr(t *testing.T) {
 
 	value := pop(&f).(int64) // longs require two slots, so popped twice
 
-	if value != 25 { // 200 >> 3 = 25
-		t.Errorf("IUSHR: expected a result of 25, but got: %d", value)
+	if value != 536870887 { // -200 >>> 3 = 0xFFFFFF38 >>> 3 = 0x1FFFFFE7
+		t.Errorf("IUSHR: expected a result of 536870887, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("IUSHR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("IUSHR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2832,8 +2892,8 @@ func TestIxor(t *testing.T) {
 	if value != 3 { // 21 ^ 22 = 3
 		t.Errorf("IXOR: expected a result of 3, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("IXOR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("IXOR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2851,8 +2911,8 @@ func TestL2d(t *testing.T) {
 	if val != 21.0 {
 		t.Errorf("L2D: expected a result of 21.0, but got: %f", val)
 	}
-	if f.TOS != 0 {
-		t.Errorf("L2D: Expected stack with 1 item, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("L2D: Expected stack with 1 item, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2870,8 +2930,8 @@ func TestL2f(t *testing.T) {
 	if val != 21.0 {
 		t.Errorf("L2D: expected a result of 21.0, but got: %f", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("L2D: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("L2D: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2889,8 +2949,8 @@ func TestL2i(t *testing.T) {
 	if val != 21 {
 		t.Errorf("L2I: expected a result of 21, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("L2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("L2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2908,8 +2968,8 @@ func TestL2ineg(t *testing.T) {
 	if val != -21 {
 		t.Errorf("L2I: expected a result of -21, but got: %d", val)
 	}
-	if f.TOS != -1 {
-		t.Errorf("L2I: Expected stack with 0 items, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("L2I: Expected stack with 0 items, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2932,8 +2992,8 @@ func TestLadd(t *testing.T) {
 	if value != 43 {
 		t.Errorf("LADD: expected a result of 43, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LADD: Expected an empty stack, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LADD: Expected an empty stack, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2956,8 +3016,8 @@ func TestLand(t *testing.T) {
 	if value != 20 { // 21 & 22 = 20
 		t.Errorf("LAND: expected a result of 20, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LAND: Expected an empty stack, but got a TOS of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LAND: Expected an empty stack, but got a TOS of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -2978,8 +3038,8 @@ func TestLcmpEQ(t *testing.T) {
 	if value != 0 {
 		t.Errorf("LCMP: Expected comparison to result in 0, got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3000,8 +3060,8 @@ func TestLcmpGT(t *testing.T) {
 	if value != 1 {
 		t.Errorf("LCMP: Expected comparison to result in 1, got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3022,8 +3082,8 @@ func TestLcmpLT(t *testing.T) {
 	if value != -1 {
 		t.Errorf("LCMP: Expected comparison to result in -1, got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LCMP: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3033,8 +3093,8 @@ func TestLconst0(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 1 {
-		t.Errorf("Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 {
+		t.Errorf("Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 0 {
@@ -3048,8 +3108,8 @@ func TestLconst1(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 1 {
-		t.Errorf("Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 {
+		t.Errorf("Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 1 {
@@ -3080,8 +3140,8 @@ func TestLdc(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 25 {
@@ -3113,8 +3173,8 @@ func TestLdcw(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 25 {
@@ -3146,8 +3206,8 @@ func TestLdcwFloat(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 25.0 {
@@ -3179,8 +3239,8 @@ func TestLdc2w(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 1 {
-		t.Errorf("Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 {
+		t.Errorf("Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(float64)
 	if value != 25.0 {
@@ -3188,6 +3248,49 @@ func TestLdc2w(t *testing.T) {
 	}
 }
 
+// LDC, LDC_W, LDC2_W: a dynamically-computed constant (CONSTANT_Dynamic) can't be computed
+// yet, so loading one throws a BootstrapMethodError that names it
+func TestLdcDynamicConstant(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	for _, code := range [][]byte{{LDC, 0x01}, {LDC_W, 0x00, 0x01}, {LDC2_W, 0x00, 0x01}} {
+		f := newFrame(code[0])
+		f.Meth = append(f.Meth, code[1:]...)
+
+		// a CP with a Dynamic entry whose NameAndType is answer:J
+		f.CP = &classloader.CPool{
+			CpIndex: []classloader.CpEntry{
+				{},
+				{Type: classloader.Dynamic, Slot: 0},
+				{Type: classloader.NameAndType, Slot: 0},
+				{Type: classloader.UTF8, Slot: 0},
+				{Type: classloader.UTF8, Slot: 1},
+			},
+			Dynamics:     []classloader.DynamicEntry{{BootstrapIndex: 0, NameAndType: 2}},
+			NameAndTypes: []classloader.NameAndTypeEntry{{NameIndex: 3, DescIndex: 4}},
+			Utf8Refs:     []string{"answer", "J"},
+		}
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f)
+		var err error
+		msg := captureStderr(func() { err = runFrame(fs) })
+
+		var thrown *JavaThrowable
+		if !errors.As(err, &thrown) {
+			t.Fatalf("Expected opcode 0x%02X on a Dynamic entry to throw, got: %v", code[0], err)
+		}
+		expected := "java.lang.BootstrapMethodError: cannot compute dynamic constant answer:J"
+		if !strings.HasPrefix(thrown.Error(), expected) || !strings.Contains(msg, expected) {
+			t.Errorf("Expected '%s', got: '%s' (output: %s)", expected, thrown.Error(), msg)
+		}
+		if f.Operands.Depth() != 0 {
+			t.Errorf("Expected nothing to be pushed for the Dynamic entry, but TOS is %d", f.Operands.Depth()-1)
+		}
+	}
+}
+
 // LDIV: (pop 2 longs, divide second term by top of stack, push result)
 func TestLdiv(t *testing.T) {
 	f := newFrame(LDIV)
@@ -3201,8 +3304,8 @@ func TestLdiv(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 1 { // product is pushed twice b/c it's a long, which occupies 2 slots
-		t.Errorf("LDIV, Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 { // product is pushed twice b/c it's a long, which occupies 2 slots
+		t.Errorf("LDIV, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -3216,11 +3319,7 @@ func TestLdiv(t *testing.T) {
 func TestLload(t *testing.T) {
 	f := newFrame(LLOAD)
 	f.Meth = append(f.Meth, 0x04) // use local var #4
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x1234562)) // put value in locals[4]
+	setLocals(&f, zero, zero, zero, zero, int64(0x1234562))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -3230,8 +3329,8 @@ func TestLload(t *testing.T) {
 	if x != 0x1234562 {
 		t.Errorf("LLOAD: Expecting 0x1234562 on stack, got: 0x%x", x)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LLOAD: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LLOAD: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 	if f.PC != 2 {
 		t.Errorf("LLOAD: Expected pc to be pointing at byte 2, got: %d", f.PC)
@@ -3242,8 +3341,7 @@ func TestLload(t *testing.T) {
 func TestLload0(t *testing.T) {
 	f := newFrame(LLOAD_0)
 
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[0]
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[1] // lload uses two local consecutive
+	setLocals(&f, int64(0x12345678), int64(0x12345678))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -3254,21 +3352,19 @@ func TestLload0(t *testing.T) {
 		t.Errorf("LLOAD_0: Expecting 0x12345678 on stack, got: 0x%x", x)
 	}
 
-	if f.Locals[1] != x {
-		t.Errorf("LLOAD_0: Local variable[1] holds invalid value: 0x%x", f.Locals[2])
+	if local(&f, 1) != x {
+		t.Errorf("LLOAD_0: Local variable[1] holds invalid value: 0x%x", local(&f, 2))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LLOAD_0: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LLOAD_0: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LLOAD_1: Load long from locals[1]
 func TestLload1(t *testing.T) {
 	f := newFrame(LLOAD_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[1]
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[2] // lload uses two local consecutive
+	setLocals(&f, zero, int64(0x12345678), int64(0x12345678))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -3279,22 +3375,19 @@ func TestLload1(t *testing.T) {
 		t.Errorf("LLOAD_1: Expecting 0x12345678 on stack, got: 0x%x", x)
 	}
 
-	if f.Locals[2] != x {
-		t.Errorf("LLOAD_1: Local variable[2] holds invalid value: 0x%x", f.Locals[2])
+	if local(&f, 2) != x {
+		t.Errorf("LLOAD_1: Local variable[2] holds invalid value: 0x%x", local(&f, 2))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LLOAD_1: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LLOAD_1: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LLOAD_2: Load long from locals[2]
 func TestLload2(t *testing.T) {
 	f := newFrame(LLOAD_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[2]
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[3] // lload uses two local consecutive
+	setLocals(&f, zero, zero, int64(0x12345678), int64(0x12345678))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -3305,23 +3398,19 @@ func TestLload2(t *testing.T) {
 		t.Errorf("LLOAD_12: Expecting 0x12345678 on stack, got: 0x%x", x)
 	}
 
-	if f.Locals[3] != x {
-		t.Errorf("LLOAD_2: Local variable[3] holds invalid value: 0x%x", f.Locals[3])
+	if local(&f, 3) != x {
+		t.Errorf("LLOAD_2: Local variable[3] holds invalid value: 0x%x", local(&f, 3))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LLOAD_1: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LLOAD_1: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LLOAD_3: Load long from locals[3]
 func TestLload3(t *testing.T) {
 	f := newFrame(LLOAD_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[3]
-	f.Locals = append(f.Locals, int64(0x12345678)) // put value in locals[4] // lload uses two local consecutive
+	setLocals(&f, zero, zero, zero, int64(0x12345678), int64(0x12345678))
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -3332,12 +3421,12 @@ func TestLload3(t *testing.T) {
 		t.Errorf("LLOAD_3: Expecting 0x12345678 on stack, got: 0x%x", x)
 	}
 
-	if f.Locals[4] != x {
-		t.Errorf("LLOAD_3: Local variable[4] holds invalid value: 0x%x", f.Locals[4])
+	if local(&f, 4) != x {
+		t.Errorf("LLOAD_3: Local variable[4] holds invalid value: 0x%x", local(&f, 4))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LLOAD_3: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LLOAD_3: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3354,8 +3443,8 @@ func TestLmul(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 1 { // product is pushed twice b/c it's a long, which occupies 2 slots
-		t.Errorf("LMUL, Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 { // product is pushed twice b/c it's a long, which occupies 2 slots
+		t.Errorf("LMUL, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -3375,8 +3464,8 @@ func TestLneg(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 1 { // product is pushed twice b/c it's a long, which occupies 2 slots
-		t.Errorf("LNEG, Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 { // product is pushed twice b/c it's a long, which occupies 2 slots
+		t.Errorf("LNEG, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -3405,8 +3494,8 @@ func TestLor(t *testing.T) {
 	if value != 23 { // 21 | 22 = 23
 		t.Errorf("LOR: expected a result of 23, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LOR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LOR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3423,8 +3512,8 @@ func TestLrem(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 1 { // product is pushed twice b/c it's a long, which occupies 2 slots
-		t.Errorf("LREM, Top of stack, expected 1, got: %d", f.TOS)
+	if f.Operands.Depth() != 2 { // product is pushed twice b/c it's a long, which occupies 2 slots
+		t.Errorf("LREM, Top of stack, expected 1, got: %d", f.Operands.Depth()-1)
 	}
 
 	value := pop(&f).(int64)
@@ -3477,8 +3566,8 @@ func TestLshl(t *testing.T) {
 	if value != 176 { // 22 << 3 = 176
 		t.Errorf("LSHL: expected a result of 176, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LSHL: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSHL: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3500,8 +3589,8 @@ func TestLshr(t *testing.T) {
 	if value != 25 { // 200 >> 3 = 25
 		t.Errorf("LSHR: expected a result of 25, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LSHR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSHR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3509,10 +3598,7 @@ func TestLshr(t *testing.T) {
 func TestLstore(t *testing.T) {
 	f := newFrame(LSTORE)
 	f.Meth = append(f.Meth, 0x02) // use local var #2
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(0x22223))
 	push(&f, int64(0x22223)) // push twice due to longs using two slots
 
@@ -3520,24 +3606,23 @@ func TestLstore(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != int64(0x22223) {
-		t.Errorf("LSTORE: Expecting 0x22223 in locals[2], got: 0x%x", f.Locals[2])
+	if local(&f, 2) != int64(0x22223) {
+		t.Errorf("LSTORE: Expecting 0x22223 in locals[2], got: 0x%x", local(&f, 2))
 	}
 
-	if f.Locals[3] != int64(0x22223) {
-		t.Errorf("LSTORE: Expecting 0x22223 in locals[3], got: 0x%x", f.Locals[3])
+	if local(&f, 3) != int64(0x22223) {
+		t.Errorf("LSTORE: Expecting 0x22223 in locals[3], got: 0x%x", local(&f, 3))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LSTORE: Expecting an empty stack, but tos points to item: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSTORE: Expecting an empty stack, but tos points to item: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LSTORE_0: Store long from stack in localVar[0] and again in localVar[1]
 func TestLstore0(t *testing.T) {
 	f := newFrame(LSTORE_0)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero) // LSTORE instructions fill two local variables (with the same value)
+	setLocals(&f, zero, zero)
 	push(&f, int64(0x12345678))
 	push(&f, int64(0x12345678))
 
@@ -3545,25 +3630,23 @@ func TestLstore0(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[0] != int64(0x12345678) {
-		t.Errorf("LSTORE_0: expected locals[0] to be 0x12345678, got: %d", f.Locals[0])
+	if local(&f, 0) != int64(0x12345678) {
+		t.Errorf("LSTORE_0: expected locals[0] to be 0x12345678, got: %d", local(&f, 0))
 	}
 
-	if f.Locals[1] != int64(0x12345678) {
-		t.Errorf("LSTORE_0: expected locals[1] to be 0x12345678, got: %d", f.Locals[1])
+	if local(&f, 1) != int64(0x12345678) {
+		t.Errorf("LSTORE_0: expected locals[1] to be 0x12345678, got: %d", local(&f, 1))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LSTORE_0: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSTORE_0: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LSTORE_1: Store long from stack in localVar[1] and again in localVar[2]
 func TestLstore1(t *testing.T) {
 	f := newFrame(LSTORE_1)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero) // LSTORE instructions fill two local variables (with the same value)
+	setLocals(&f, zero, zero, zero)
 	push(&f, int64(0x12345678))
 	push(&f, int64(0x12345678))
 
@@ -3571,26 +3654,23 @@ func TestLstore1(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[1] != int64(0x12345678) {
-		t.Errorf("LSTORE_1: expected locals[1] to be 0x12345678, got: %d", f.Locals[1])
+	if local(&f, 1) != int64(0x12345678) {
+		t.Errorf("LSTORE_1: expected locals[1] to be 0x12345678, got: %d", local(&f, 1))
 	}
 
-	if f.Locals[2] != int64(0x12345678) {
-		t.Errorf("LSTORE_1: expected locals[2] to be 0x12345678, got: %d", f.Locals[2])
+	if local(&f, 2) != int64(0x12345678) {
+		t.Errorf("LSTORE_1: expected locals[2] to be 0x12345678, got: %d", local(&f, 2))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LSTORE_1: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSTORE_1: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LSTORE_2: Store long from stack in localVar[2] and again in localVar[3]
 func TestLstore2(t *testing.T) {
 	f := newFrame(LSTORE_2)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero) // LSTORE instructions fill two local variables (with the same value)
+	setLocals(&f, zero, zero, zero, zero)
 	push(&f, int64(0x12345678))
 	push(&f, int64(0x12345678))
 
@@ -3598,27 +3678,23 @@ func TestLstore2(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[2] != int64(0x12345678) {
-		t.Errorf("LSTORE_2: expected locals[2] to be 0x12345678, got: %d", f.Locals[2])
+	if local(&f, 2) != int64(0x12345678) {
+		t.Errorf("LSTORE_2: expected locals[2] to be 0x12345678, got: %d", local(&f, 2))
 	}
 
-	if f.Locals[3] != int64(0x12345678) {
-		t.Errorf("LSTORE_2: expected locals[3] to be 0x12345678, got: %d", f.Locals[3])
+	if local(&f, 3) != int64(0x12345678) {
+		t.Errorf("LSTORE_2: expected locals[3] to be 0x12345678, got: %d", local(&f, 3))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LSTORE_2: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSTORE_2: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
 // LSTORE_3: Store long from stack in localVar[3] and again in localVar[]
 func TestLstore3(t *testing.T) {
 	f := newFrame(LSTORE_3)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero)
-	f.Locals = append(f.Locals, zero) // LSTORE instructions fill two local variables (with the same value)
+	setLocals(&f, zero, zero, zero, zero, zero)
 	push(&f, int64(0x12345678))
 	push(&f, int64(0x12345678))
 
@@ -3626,16 +3702,16 @@ func TestLstore3(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.Locals[3] != int64(0x12345678) {
-		t.Errorf("LSTORE_3: expected locals[3] to be 0x12345678, got: %d", f.Locals[3])
+	if local(&f, 3) != int64(0x12345678) {
+		t.Errorf("LSTORE_3: expected locals[3] to be 0x12345678, got: %d", local(&f, 3))
 	}
 
-	if f.Locals[4] != int64(0x12345678) {
-		t.Errorf("LSTORE_3: expected locals[4] to be 0x12345678, got: %d", f.Locals[4])
+	if local(&f, 4) != int64(0x12345678) {
+		t.Errorf("LSTORE_3: expected locals[4] to be 0x12345678, got: %d", local(&f, 4))
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("LSTORE_3: Expected op stack to be empty, got tos: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSTORE_3: Expected op stack to be empty, got tos: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3655,8 +3731,8 @@ func TestLsub(t *testing.T) {
 	value := pop(&f).(int64)
 	pop(&f)
 
-	if f.TOS != -1 {
-		t.Errorf("LSUB, Top of stack, expected -1, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LSUB, Top of stack, expected -1, got: %d", f.Operands.Depth()-1)
 	}
 
 	if value != 3 {
@@ -3682,8 +3758,8 @@ func TestLushr(t *testing.T) {
 	if value != 25 { // 200 >> 3 = 25
 		t.Errorf("LUSHR: expected a result of 25, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LUSHR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LUSHR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3706,8 +3782,8 @@ func TestLxor(t *testing.T) {
 	if value != 3 { // 21 ^ 22 = 3
 		t.Errorf("LXOR: expected a result of 3, but got: %d", value)
 	}
-	if f.TOS != -1 {
-		t.Errorf("LXOR: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("LXOR: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3722,8 +3798,8 @@ func TestPop(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 1 {
-		t.Errorf("POP: Expected stack with 2 items, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 2 {
+		t.Errorf("POP: Expected stack with 2 items, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 
 	top := pop(&f).(int64)
@@ -3744,8 +3820,8 @@ func TestPop2(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 
-	if f.TOS != 0 {
-		t.Errorf("POP2: Expected stack with 1 item, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("POP2: Expected stack with 1 item, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 
 	top := pop(&f).(int64)
@@ -3761,8 +3837,8 @@ func TestReturn(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	ret := runFrame(fs)
-	if f.TOS != -1 {
-		t.Errorf("Top of stack, expected -1, got: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("Top of stack, expected -1, got: %d", f.Operands.Depth()-1)
 	}
 
 	if ret != nil {
@@ -3778,8 +3854,8 @@ func TestSipush(t *testing.T) {
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
-	if f.TOS != 0 {
-		t.Errorf("BIPUSH: Top of stack, expected 0, got: %d", f.TOS)
+	if f.Operands.Depth() != 1 {
+		t.Errorf("BIPUSH: Top of stack, expected 0, got: %d", f.Operands.Depth()-1)
 	}
 	value := pop(&f).(int64)
 	if value != 258 {
@@ -3812,8 +3888,8 @@ func TestSwap(t *testing.T) {
 		t.Errorf("SWAP: expected next's value to be 21, but got: %d", next)
 	}
 
-	if f.TOS != -1 {
-		t.Errorf("SWAP: Expected an empty stack, but got a tos of: %d", f.TOS)
+	if f.Operands.Depth() != 0 {
+		t.Errorf("SWAP: Expected an empty stack, but got a tos of: %d", f.Operands.Depth()-1)
 	}
 }
 
@@ -3855,3 +3931,250 @@ func TestInvalidInstruction(t *testing.T) {
 		t.Errorf("Error message for invalid bytecode not as expected, got: %s", msg)
 	}
 }
+
+// INVOKESPECIAL: call the constructor of java.lang.Object, which is a Go method
+func TestInvokespecialObjectInit(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	f := newFrame(INVOKESPECIAL)
+	f.Meth = append(f.Meth, 0x00, 0x01) // CP entry #1
+	f.ClName = "Test"
+
+	CP := classloader.CPool{}
+	CP.CpIndex = []classloader.CpEntry{
+		{Type: 0, Slot: 0},
+		{Type: classloader.MethodRef, Slot: 0},   // #1 Object.<init>()V
+		{Type: classloader.ClassRef, Slot: 0},    // #2 -> #3
+		{Type: classloader.UTF8, Slot: 0},        // #3 java/lang/Object
+		{Type: classloader.NameAndType, Slot: 0}, // #4 #5:#6
+		{Type: classloader.UTF8, Slot: 1},        // #5 <init>
+		{Type: classloader.UTF8, Slot: 2},        // #6 ()V
+	}
+	CP.MethodRefs = []classloader.MethodRefEntry{{ClassIndex: 2, NameAndType: 4}}
+	CP.ClassRefs = []uint16{3}
+	CP.NameAndTypes = []classloader.NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+	CP.Utf8Refs = []string{"java/lang/Object", "<init>", "()V"}
+	f.CP = &CP
+
+	push(&f, int64(0x1234)) // the object reference
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	if err := runFrame(fs); err != nil {
+		t.Errorf("INVOKESPECIAL: unexpected error: %s", err.Error())
+	}
+
+	if f.Operands.Depth() != 0 {
+		t.Errorf("INVOKESPECIAL: expected the object reference to be popped, but TOS is: %d", f.Operands.Depth()-1)
+	}
+}
+
+// INVOKESTATIC: invoking a method that takes two ints with only one int on the stack
+// must throw a VerifyError, rather than panic when the missing argument is popped
+func TestInvokestaticInsufficientStack(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	f := newFrame(INVOKESTATIC)
+	f.Meth = append(f.Meth, 0x00, 0x01) // CP entry #1
+
+	CP := classloader.CPool{}
+	CP.CpIndex = []classloader.CpEntry{
+		{Type: 0, Slot: 0},
+		{Type: classloader.MethodRef, Slot: 0},   // #1 Integer.max(II)I
+		{Type: classloader.ClassRef, Slot: 0},    // #2 -> #3
+		{Type: classloader.UTF8, Slot: 0},        // #3 java/lang/Integer
+		{Type: classloader.NameAndType, Slot: 0}, // #4 #5:#6
+		{Type: classloader.UTF8, Slot: 1},        // #5 max
+		{Type: classloader.UTF8, Slot: 2},        // #6 (II)I
+	}
+	CP.MethodRefs = []classloader.MethodRefEntry{{ClassIndex: 2, NameAndType: 4}}
+	CP.ClassRefs = []uint16{3}
+	CP.NameAndTypes = []classloader.NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+	CP.Utf8Refs = []string{"java/lang/Integer", "max", "(II)I"}
+	f.CP = &CP
+
+	push(&f, int64(3)) // only one of the two arguments
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	var err error
+	captureStderr(func() {
+		err = runFrame(fs)
+	})
+
+	var thrown *JavaThrowable
+	if !errors.As(err, &thrown) {
+		t.Fatalf("INVOKESTATIC: expected a VerifyError, got: %v", err)
+	}
+	expected := "java.lang.VerifyError: insufficient stack for method java.lang.Integer.max(II)I: need 2, have 1"
+	if thrown.Error() != expected {
+		t.Errorf("INVOKESTATIC: expected %s, got: %s", expected, thrown.Error())
+	}
+}
+
+// INVOKESTATIC: a method that calls itself forever throws StackOverflowError once the
+// frame stack is maxFrameDepth frames deep, rather than exhausting the Go stack
+func TestInvokestaticUnboundedRecursion(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	classBytes, err := classbuilder.NewClassBuilder("Recursion").
+		AddMethod("down", "()V").
+		AddOpcode(INVOKESTATIC, "Recursion", "down", "()V").
+		AddOpcode(RETURN).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building Recursion: %s", err.Error())
+	}
+	if _, err = classloader.ParseAndPostClass(classloader.AppCL, "Recursion.class", classBytes); err != nil {
+		t.Fatalf("Unexpected error loading Recursion: %s", err.Error())
+	}
+
+	msg := captureStderr(func() {
+		_, err = invokeMethod("Recursion", "down", "()V", nil)
+	})
+
+	var thrown *JavaThrowable
+	if !errors.As(err, &thrown) {
+		t.Fatalf("INVOKESTATIC: expected a StackOverflowError, got: %v", err)
+	}
+	if thrown.Error() != "java.lang.StackOverflowError" {
+		t.Errorf("INVOKESTATIC: expected java.lang.StackOverflowError, got: %s", thrown.Error())
+	}
+	if !strings.Contains(msg, "java.lang.StackOverflowError") {
+		t.Errorf("INVOKESTATIC: expected the StackOverflowError to be reported, got: %s", msg)
+	}
+}
+
+// ICONST_0, I2L: pushing more than a method's max_stack throws a StackOverflowError,
+// rather than panicking, whether the interpreter or one of its helpers does the push
+func TestOperandStackOverflow(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	tests := []struct {
+		code     byte
+		maxStack int
+		values   []int64
+	}{
+		{ICONST_0, 1, []int64{7}},
+		{I2L, 1, []int64{7}}, // the int takes one slot, but the long takes two
+	}
+	for _, test := range tests {
+		f := frames.CreateFrame(test.maxStack)
+		f.Ftype = 'J'
+		f.Meth = []byte{test.code}
+		for _, v := range test.values {
+			push(f, v)
+		}
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(f)
+		var err error
+		captureStderr(func() {
+			err = runFrame(fs)
+		})
+
+		var thrown *JavaThrowable
+		if !errors.As(err, &thrown) {
+			t.Errorf("%s: expected a StackOverflowError, got: %v", BytecodeNames[test.code], err)
+			continue
+		}
+		if thrown.Error() != "java.lang.StackOverflowError" {
+			t.Errorf("%s: expected java.lang.StackOverflowError, got: %s", BytecodeNames[test.code], thrown.Error())
+		}
+	}
+}
+
+// ILOAD, LSTORE_3: using a local variable beyond the method's max_locals throws a
+// VerifyError, rather than panicking
+func TestLocalVariableOutOfRange(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	tests := []struct {
+		code     []byte
+		expected string
+	}{
+		{[]byte{ILOAD, 0x04}, "java.lang.VerifyError: illegal local variable number 4 in method Test.run at 0: " +
+			"the method has 4 local variables"},
+		{[]byte{LSTORE_3}, "java.lang.VerifyError: illegal local variable number 4 in method Test.run at 0: " +
+			"the method has 4 local variables"},
+	}
+	for _, test := range tests {
+		f := newFrame(test.code[0])
+		f.Meth = append(f.Meth, test.code[1:]...)
+		f.ClName = "Test"
+		f.MethName = "run"
+		setLocals(&f, zero, zero, zero, zero)
+		push(&f, int64(7)) // a long, for LSTORE_3
+		push(&f, int64(7))
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f)
+		var err error
+		captureStderr(func() {
+			err = runFrame(fs)
+		})
+
+		var thrown *JavaThrowable
+		if !errors.As(err, &thrown) {
+			t.Errorf("%s: expected a VerifyError, got: %v", BytecodeNames[test.code[0]], err)
+			continue
+		}
+		if thrown.Error() != test.expected {
+			t.Errorf("%s: expected %s, got: %s", BytecodeNames[test.code[0]], test.expected, thrown.Error())
+		}
+	}
+}
+
+// LSTORE_2: a long stored in the last two local variables is within range
+func TestLocalVariableLongInLastSlots(t *testing.T) {
+	f := newFrame(LSTORE_2)
+	setLocals(&f, zero, zero, zero, zero)
+	push(&f, int64(0x1234))
+	push(&f, int64(0x1234))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("LSTORE_2: unexpected error: %s", err.Error())
+	}
+	if local(&f, 2) != int64(0x1234) || local(&f, 3) != int64(0x1234) {
+		t.Errorf("LSTORE_2: expected the long in locals 2 and 3, got: %v", f.Locals.Slots())
+	}
+}
+
+// the instruction trace shows the names of local variables, from the LocalVariableTable
+func TestLocalVarTrace(t *testing.T) {
+	CP := classloader.CPool{Utf8Refs: []string{"args", "[Ljava/lang/String;", "count"}}
+	f := newFrame(ISTORE_1)
+	f.Meth = append(f.Meth, ILOAD_1, ALOAD_0, IINC, 0x02, 0x01, NOP)
+	f.CP = &CP
+	f.LocalVarTable = classloader.LocalVariableTable{
+		{StartPC: 0, Length: 7, Name: 0, Desc: 1, Slot: 0},
+		{StartPC: 1, Length: 6, Name: 2, Desc: 1, Slot: 1}, // count comes into scope after the store
+	}
+
+	for pc, expected := range map[int]string{
+		0: ", local: 1 (count)",
+		1: ", local: 1 (count)",
+		2: ", local: 0 (args)",
+		3: ", local: 2",
+		6: "",
+	} {
+		f.PC = pc
+		if trace := localVarTrace(&f); trace != expected {
+			t.Errorf("Expected the trace at pc %d to show '%s', got: '%s'", pc, expected, trace)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+)
+
+// setUpStatics adds the static fields declared in the class to the statics (see
+// classloader.Statics) the first time one of them is used. Each starts out with the
+// default value of its type, except for one with a ConstantValue attribute (a static
+// final primitive or String), which the JVM must initialize to that constant before the
+// class's <clinit> runs. From then on, the class is linked, so it can't be unloaded.
+func setUpStatics(className string) {
+	k, ok := classloader.LookupClass(className)
+	if !ok || k.Data == nil {
+		return
+	}
+	classloader.AdvanceClassStatus(className, 'L')
+
+	cp := &k.Data.CP
+	for _, fld := range k.Data.Fields {
+		if fld.AccessFlags&classloader.ACC_STATIC == 0 {
+			continue
+		}
+		name := className + "." + cp.Utf8Refs[fld.Name]
+		if _, present := classloader.Statics[name]; present {
+			continue
+		}
+
+		desc := cp.Utf8Refs[fld.Desc]
+		static := classloader.Static{Class: desc[0], Type: desc, CP: cp}
+		if fld.ConstantValue != 0 { // the same values ldc would push for the constant
+			constant := FetchCPentry(cp, int(fld.ConstantValue))
+			switch constant.retType {
+			case IS_INT64:
+				static.ValueInt = constant.intVal
+			case IS_FLOAT64:
+				static.ValueFP = constant.floatVal
+			case IS_STRING_ADDR:
+				static.ValueInt = int64(constant.addrVal)
+				static.ValueStr = cp.Utf8Refs[cp.CpIndex[fld.ConstantValue].Slot]
+			}
+		}
+		classloader.StaticsArray = append(classloader.StaticsArray, static)
+		classloader.Statics[name] = int64(len(classloader.StaticsArray) - 1)
+	}
+}
+
+// resolveStatic finds the static field named fieldName that's used through className.
+// As with any field reference (JVMS §5.4.3.2), the field is looked for in the class
+// itself, then in its superinterfaces, then in its superclass, and so on up the chain.
+// Each class searched is loaded and has its statics set up, so an inherited constant
+// has the value of its ConstantValue attribute. It returns the field's index in
+// classloader.StaticsArray, and false if no class in the chain declares it.
+func resolveStatic(className, fieldName string, seen map[string]bool) (int64, bool) {
+	if seen[className] { // a circular chain, which linking the class has already reported
+		return 0, false
+	}
+	seen[className] = true
+
+	if classloader.LoadClassFromNameOnly(className) != nil {
+		return 0, false
+	}
+	setUpStatics(className)
+	if index, ok := classloader.Statics[className+"."+fieldName]; ok {
+		return index, true
+	}
+
+	k, ok := classloader.LookupClass(className)
+	if !ok || k.Data == nil {
+		return 0, false
+	}
+	for _, iface := range k.Data.Interfaces {
+		if index, ok := resolveStatic(k.Data.CP.Utf8Refs[iface], fieldName, seen); ok {
+			return index, true
+		}
+	}
+	if k.Data.Superclass == "" {
+		return 0, false
+	}
+	return resolveStatic(k.Data.Superclass, fieldName, seen)
+}
+
+// pushStatic pushes the value of a static field onto the operand stack of f. Like
+// other longs and doubles, a static long or double takes up two slots.
+func pushStatic(f *frames.Frame, static classloader.Static) error {
+	switch static.Class {
+	case 'F':
+		return push(f, static.ValueFP)
+	case 'D':
+		return pushDouble(f, static.ValueFP)
+	case 'J':
+		return pushLong(f, static.ValueInt)
+	case 'L', '[': // references, whose address is in ValueInt
+		return pushRef(f, static.ValueInt)
+	default: // the int types
+		return push(f, static.ValueInt)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// static final fields with a ConstantValue attribute start out with that value; other
+// static fields start out with the default value of their type
+func TestSetUpStaticsWithConstantValues(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	cd := classloader.ClData{Name: "Consts"}
+	cd.CP.CpIndex = []classloader.CpEntry{{}, {Type: classloader.IntConst, Slot: 0},
+		{Type: classloader.LongConst, Slot: 0}, {}, {Type: classloader.DoubleConst, Slot: 0}}
+	cd.CP.IntConsts = []int32{42}
+	cd.CP.LongConsts = []int64{1 << 40}
+	cd.CP.Doubles = []float64{0.25}
+	cd.CP.Utf8Refs = []string{"MAX", "I", "BIG", "J", "RATE", "D", "count"}
+	cd.Fields = []classloader.Field{
+		{AccessFlags: classloader.ACC_STATIC, Name: 0, Desc: 1, ConstantValue: 1},
+		{AccessFlags: classloader.ACC_STATIC, Name: 2, Desc: 3, ConstantValue: 2},
+		{AccessFlags: classloader.ACC_STATIC, Name: 4, Desc: 5, ConstantValue: 4},
+		{AccessFlags: classloader.ACC_STATIC, Name: 6, Desc: 1},
+		{AccessFlags: 0, Name: 0, Desc: 1, ConstantValue: 1}, // not static, so not set up
+	}
+	classloader.Classes.Store("Consts", classloader.Klass{Status: 'F', Loader: "app", Data: &cd})
+	defer classloader.Classes.Delete("Consts")
+
+	setUpStatics("Consts")
+	defer func() {
+		for _, name := range []string{"Consts.MAX", "Consts.BIG", "Consts.RATE", "Consts.count"} {
+			delete(classloader.Statics, name)
+		}
+	}()
+
+	for _, expected := range []struct {
+		name  string
+		tag   int
+		stack []interface{}
+	}{
+		{"Consts.MAX", frames.IntValue, []interface{}{int64(42)}},
+		{"Consts.BIG", frames.LongValue, []interface{}{int64(1 << 40), int64(1 << 40)}},
+		{"Consts.RATE", frames.DoubleValue, []interface{}{0.25, 0.25}},
+		{"Consts.count", frames.IntValue, []interface{}{int64(0)}},
+	} {
+		index, ok := classloader.Statics[expected.name]
+		if !ok {
+			t.Errorf("Expected static %s to be set up, but it was not", expected.name)
+			continue
+		}
+		f := newFrame(GETSTATIC)
+		pushStatic(&f, classloader.StaticsArray[index])
+		if f.Operands.Depth() != len(expected.stack) {
+			t.Errorf("Expected %s to take up %d slots, got: %d", expected.name, len(expected.stack), f.Operands.Depth())
+			continue
+		}
+		for i, v := range expected.stack {
+			slot := f.Operands.Slots()[i]
+			if slot.Value() != v || slot.Tag != expected.tag {
+				t.Errorf("Expected slot %d of %s to be %v with tag %d, got: %v with tag %d",
+					i, expected.name, v, expected.tag, slot.Value(), slot.Tag)
+			}
+		}
+	}
+}
+
+// a static used through a subclass is the one declared in its superclass, or in one of
+// its superinterfaces, with the value of that class's ConstantValue attribute
+func TestResolveInheritedStatic(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	constant := func(className, fieldName string, value int32) *classloader.ClData {
+		cd := classloader.ClData{Name: className}
+		cd.CP.CpIndex = []classloader.CpEntry{{}, {Type: classloader.IntConst, Slot: 0}}
+		cd.CP.IntConsts = []int32{value}
+		cd.CP.Utf8Refs = []string{fieldName, "I"}
+		cd.Fields = []classloader.Field{
+			{AccessFlags: classloader.ACC_STATIC, Name: 0, Desc: 1, ConstantValue: 1}}
+		return &cd
+	}
+	sup, iface := constant("Super", "X", 7), constant("Iface", "Y", 9)
+	sub := &classloader.ClData{Name: "Sub", Superclass: "Super", Interfaces: []uint16{0}}
+	sub.CP.Utf8Refs = []string{"Iface"}
+
+	for _, cd := range []*classloader.ClData{sup, iface, sub} {
+		classloader.Classes.Store(cd.Name, classloader.Klass{Status: 'F', Loader: "app", Data: cd})
+	}
+	defer func() {
+		for _, name := range []string{"Super", "Iface", "Sub"} {
+			classloader.Classes.Delete(name)
+		}
+		for _, name := range []string{"Super.X", "Iface.Y"} {
+			delete(classloader.Statics, name)
+		}
+	}()
+
+	for _, expected := range []struct {
+		field string
+		value int64
+	}{{"X", 7}, {"Y", 9}} {
+		index, ok := resolveStatic("Sub", expected.field, make(map[string]bool))
+		if !ok {
+			t.Errorf("Expected Sub.%s to resolve to an inherited static, but it did not", expected.field)
+			continue
+		}
+		if classloader.StaticsArray[index].ValueInt != expected.value {
+			t.Errorf("Expected Sub.%s to be %d, got: %d", expected.field, expected.value,
+				classloader.StaticsArray[index].ValueInt)
+		}
+	}
+
+	if _, ok := resolveStatic("Sub", "Z", make(map[string]bool)); ok {
+		t.Error("Expected Sub.Z not to resolve, as no class declares it")
+	}
+}
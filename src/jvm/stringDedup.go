@@ -0,0 +1,50 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"runtime"
+	"sync/atomic"
+)
+
+// Strings are deduplicated after garbage collections (-XX:+UseStringDeduplication).
+// Go gives no notice of a collection other than running finalizers, which run in
+// their own goroutine. Deduplicating there could change a String while the interpreter
+// is reading it, so the finalizer only sets dedupPending, and the deduplication itself
+// is done by the interpreter when it next starts running a frame.
+var dedupPending atomic.Bool
+
+// gcSentinel is allocated only so that its finalizer tells us a collection has run.
+// (It holds a pointer so that Go doesn't combine it with other small allocations,
+// which would keep its finalizer from running.)
+type gcSentinel struct {
+	_ *byte
+}
+
+// startStringDeduplication arranges for Strings to be deduplicated after every
+// garbage collection
+func startStringDeduplication() {
+	afterEachCollection(func() { dedupPending.Store(true) })
+}
+
+// afterEachCollection runs fn after each garbage collection, by attaching fn to an
+// unreachable sentinel object, whose finalizer attaches fn to a new sentinel.
+func afterEachCollection(fn func()) {
+	runtime.SetFinalizer(&gcSentinel{}, func(*gcSentinel) {
+		fn()
+		afterEachCollection(fn)
+	})
+}
+
+// deduplicateIfPending deduplicates Strings if there's been a collection since
+// the last deduplication
+func deduplicateIfPending() {
+	if dedupPending.CompareAndSwap(true, false) {
+		classloader.DeduplicateStrings()
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// returns the address of the bytes of the String at addr
+func backingString(addr int64) uintptr {
+	s := classloader.GoStringFromAddr(addr)
+	return *(*uintptr)(unsafe.Pointer(&s))
+}
+
+// 100 Strings with the same value, each with its own copy of the value, share a
+// single copy once a collection has run and the interpreter has reached a safe point
+func TestStringDeduplicationAfterGC(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+
+	var addrs []int64
+	for i := 0; i < 100; i++ {
+		addrs = append(addrs, classloader.NewStringObject(strings.Clone("hello")))
+	}
+	if backingString(addrs[0]) == backingString(addrs[1]) {
+		t.Fatalf("Expected the Strings to start with separate copies of their value")
+	}
+
+	startStringDeduplication()
+	for i := 0; i < 100 && !dedupPending.Load(); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond) // the finalizer that notes the collection runs asynchronously
+	}
+	if !dedupPending.Load() {
+		t.Fatalf("Expected a collection to schedule String deduplication")
+	}
+
+	// running a frame reaches the safe point where the deduplication is done
+	f := newFrame(RETURN)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	_ = runFrame(fs)
+
+	canonical := backingString(addrs[0])
+	for i, addr := range addrs {
+		if backingString(addr) != canonical {
+			t.Errorf("Expected String %d to share the deduplicated value", i)
+		}
+		if classloader.GoStringFromAddr(addr) != "hello" {
+			t.Errorf("Expected String %d to still be 'hello', got: %s", i,
+				classloader.GoStringFromAddr(addr))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"strings"
+)
+
+// TokenizeOptions splits a string of JVM options, such as the value of JAVA_TOOL_OPTIONS,
+// into individual options. Options are separated by whitespace, except that:
+//   - text within double quotes is kept together, and within it, a backslash escapes
+//     the character that follows it (so \" is a quote, \\ is a backslash, and \  is
+//     a space)
+//   - text within single quotes is kept together, with no escapes
+//
+// The quotes themselves are removed, so "-Dkey=a b" becomes -Dkey=a b and -Dkey="" becomes
+// -Dkey= (an empty value). An error is returned for an unterminated quote and for text
+// that's left over from an option value containing an unquoted space, as in
+// -Dkey=a b, where b would otherwise be taken as the name of the main class.
+func TokenizeOptions(s string) ([]string, error) {
+	tokens, err := splitTokens(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(tokens); i++ {
+		if !strings.HasPrefix(tokens[i], "-") && strings.Contains(tokens[i-1], "=") {
+			return nil, errors.New("unquoted space in the value of option " + tokens[i-1] +
+				" (use quotes around the option): " + s)
+		}
+	}
+	return tokens, nil
+}
+
+// splits s into tokens separated by whitespace, honoring quotes and escapes as
+// described for TokenizeOptions()
+func splitTokens(s string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	inToken := false // needed to tell an empty token ("") from no token at all
+	var quote rune   // the quote character, if inside quotes; 0 otherwise
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			token.WriteRune(c)
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			token.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inToken = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote in options: " + s)
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
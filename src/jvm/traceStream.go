@@ -0,0 +1,151 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/json"
+	"jacobin/frames"
+	"jacobin/management"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The instruction trace endpoint, GET /trace/instructions, streams each instruction the
+// interpreter executes as a Server-Sent Event whose data is a JSON object:
+//
+//	data: {"class":"Hello2","method":"main","pc":5,"opcode":"iadd"}
+//
+// Instructions are streamed only when -XX:+TraceInstructions is set. The interpreter
+// never waits for clients: it sends each instruction on a buffered channel, from which
+// a goroutine fans it out to a buffered channel for each client. Whenever a channel is
+// full, its oldest instruction is dropped to make room. The optional query parameter
+// count ends the stream after that many instructions. Like the attach endpoint, this
+// endpoint is served at the address given by -agentlib:jacobin-attach=<address>.
+
+type traceEvent struct {
+	Class  string `json:"class"`
+	Method string `json:"method"`
+	PC     int    `json:"pc"`
+	Opcode string `json:"opcode"`
+}
+
+const traceBufferSize = 1024
+
+var instructionTracing atomic.Bool // checked by the interpreter before each instruction
+var traceEvents = make(chan traceEvent, traceBufferSize)
+
+// the channels of the connected clients
+var traceClients = make(map[chan traceEvent]struct{})
+var traceClientsMutex sync.Mutex
+
+var startTraceOnce sync.Once
+
+// startInstructionTracing begins streaming the executed instructions to the clients of
+// the instruction trace endpoint
+func startInstructionTracing() {
+	startTraceOnce.Do(func() {
+		management.Handle("/trace/instructions", traceHandler)
+		go fanOutTraceEvents()
+	})
+	instructionTracing.Store(true)
+}
+
+// traceInstruction sends the instruction at f.PC to the clients. It's called from the
+// interpreter's dispatch loop, so it never blocks.
+func traceInstruction(f *frames.Frame) {
+	event := traceEvent{Class: f.ClName, Method: f.MethName, PC: f.PC,
+		Opcode: strings.ToLower(BytecodeNames[f.Meth[f.PC]])}
+	sendDroppingOldest(traceEvents, event)
+}
+
+// sends event on ch; if ch is full, its oldest event is dropped to make room, and the
+// send is tried again
+func sendDroppingOldest(ch chan traceEvent, event traceEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// copies each traced instruction to every client's channel
+func fanOutTraceEvents() {
+	for event := range traceEvents {
+		traceClientsMutex.Lock()
+		for client := range traceClients {
+			sendDroppingOldest(client, event)
+		}
+		traceClientsMutex.Unlock()
+	}
+}
+
+func addTraceClient() chan traceEvent {
+	client := make(chan traceEvent, traceBufferSize)
+	traceClientsMutex.Lock()
+	traceClients[client] = struct{}{}
+	traceClientsMutex.Unlock()
+	return client
+}
+
+func removeTraceClient(client chan traceEvent) {
+	traceClientsMutex.Lock()
+	delete(traceClients, client)
+	traceClientsMutex.Unlock()
+}
+
+func traceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "the instruction trace requires GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := -1 // stream until the client disconnects
+	if param := r.URL.Query().Get("count"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid count: "+param, http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	client := addTraceClient()
+	defer removeTraceClient(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for ; count != 0; count-- {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-client:
+			data, _ := json.Marshal(event)
+			if _, err := w.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
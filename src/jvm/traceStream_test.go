@@ -0,0 +1,109 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"context"
+	"encoding/json"
+	"jacobin/classbuilder"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/management"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// returns the events in a Server-Sent Event stream of the instruction trace
+func parseTraceEvents(t *testing.T, body string) []traceEvent {
+	var events []traceEvent
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		var event traceEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Invalid trace event %q: %s", data, err.Error())
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestTraceInstructionsStreamsExecutedOpcodes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = classloader.Init()
+	classloader.MTable = make(map[string]classloader.MTentry)
+
+	bytes, err := classbuilder.NewClassBuilder("TraceTest").AddMethod("sum", "()I").
+		AddOpcode(ICONST_2).AddOpcode(ICONST_3).AddOpcode(IADD).AddOpcode(IRETURN).Build()
+	if err != nil {
+		t.Fatalf("Unexpected error building TraceTest: %s", err.Error())
+	}
+	if _, err = classloader.ParseAndPostClass(classloader.AppCL, "TraceTest.class", bytes); err != nil {
+		t.Fatalf("Unexpected error loading TraceTest: %s", err.Error())
+	}
+
+	startInstructionTracing()
+	defer instructionTracing.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/trace/instructions?count=4", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		management.Handler().ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	// wait for the client to connect before executing the method
+	for connected := false; !connected; time.Sleep(time.Millisecond) {
+		traceClientsMutex.Lock()
+		connected = len(traceClients) > 0
+		traceClientsMutex.Unlock()
+	}
+
+	if ret, _ := invokeMethod("TraceTest", "sum", "()I", nil); ret != int64(5) {
+		t.Fatalf("Expected TraceTest.sum() to return 5, got: %v", ret)
+	}
+	<-done
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected a 200 event stream, got: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	expected := []traceEvent{
+		{Class: "TraceTest", Method: "sum", PC: 0, Opcode: "iconst_2"},
+		{Class: "TraceTest", Method: "sum", PC: 1, Opcode: "iconst_3"},
+		{Class: "TraceTest", Method: "sum", PC: 2, Opcode: "iadd"},
+		{Class: "TraceTest", Method: "sum", PC: 3, Opcode: "ireturn"},
+	}
+	events := parseTraceEvents(t, rec.Body.String())
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d trace events, got: %s", len(expected), rec.Body.String())
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected trace event %+v, got: %+v", expected[i], events[i])
+		}
+	}
+}
+
+func TestTraceSendDropsOldestWhenFull(t *testing.T) {
+	ch := make(chan traceEvent, 2)
+	for pc := 0; pc < 5; pc++ {
+		sendDroppingOldest(ch, traceEvent{PC: pc})
+	}
+	if first, second := <-ch, <-ch; first.PC != 3 || second.PC != 4 {
+		t.Errorf("Expected the newest events (PCs 3 and 4) to be kept, got PCs %d and %d", first.PC, second.PC)
+	}
+}
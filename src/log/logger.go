@@ -65,6 +65,28 @@ func Log(msg string, level int) (err error) {
 		return
 	}
 
+	write(msg, level)
+	return
+}
+
+// LogRequested logs a message formatted as one at the given level, whatever the
+// current logging level. It's for the messages that an option other than -verbose
+// asks for, such as the lines -XX:+PrintGC logs for collections, so that turning
+// them on doesn't also turn on all the other messages at that level.
+func LogRequested(msg string, level int) (err error) {
+	if len(msg) == 0 {
+		return errors.New("empty logging message")
+	}
+	if level < SEVERE || level > TRACE_INST {
+		return errors.New("invalid logging level")
+	}
+
+	write(msg, level)
+	return
+}
+
+// writes the message to stderr
+func write(msg string, level int) {
 	// if the message is more low-level than a WARNING,
 	// prefix it with the elapsed time in millisecs.
 	duration := time.Since(StartTime)
@@ -78,7 +100,6 @@ func Log(msg string, level int) (err error) {
 	}
 	_, _ = fmt.Fprintf(os.Stderr, "%s\n", msg)
 	mutex.Unlock()
-	return
 }
 
 // SetLogLevel seta the level of granularity.
@@ -188,3 +188,31 @@ func TestThatTraceLoggingWithoutCLIsettingPrintsNothing(t *testing.T) {
 		t.Errorf("Test should not have logged anything, but it did: %s", msg)
 	}
 }
+
+// a requested message is logged, formatted for its level, even if that level is finer
+// than the logging level
+func TestLogRequestedIgnoresLoggingLevel(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = SetLogLevel(WARNING)
+
+	// to test the error message, capture the writing done to stderr
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_ = LogRequested("Test message (requested)", INFO)
+
+	// reset stderr to what it was before
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "Test message (requested)") || !strings.HasPrefix(msg, "[") {
+		t.Errorf("Expected the message with its elapsed time, got: %s", msg)
+	}
+	if Level != WARNING {
+		t.Errorf("Expected the logging level to be unchanged, got: %d", Level)
+	}
+}
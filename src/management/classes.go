@@ -0,0 +1,65 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// the number of classes loaded since Jacobin started, and the number of those that
+// have since been unloaded
+var totalLoadedClasses atomic.Int64
+var unloadedClasses atomic.Int64
+
+// RecordClassLoad records that a classloader has defined a class
+func RecordClassLoad() {
+	totalLoadedClasses.Add(1)
+}
+
+// RecordClassUnload records that a class has been unloaded
+func RecordClassUnload() {
+	unloadedClasses.Add(1)
+}
+
+// ClassesProvider is the "classes" InstrumentationProvider. Like Java's
+// ClassLoadingMXBean, it reports the number of classes loaded at present, the number
+// loaded since Jacobin started, and the number unloaded.
+type ClassesProvider struct{}
+
+func (ClassesProvider) Name() string { return "classes" }
+
+// returns the count for each of the provider's keys
+func classLoadingCounts() map[string]int64 {
+	total, unloaded := totalLoadedClasses.Load(), unloadedClasses.Load()
+	return map[string]int64{
+		"loaded":      total - unloaded,
+		"totalLoaded": total,
+		"unloaded":    unloaded,
+	}
+}
+
+// List returns the loaded, totalLoaded, and unloaded counts, in that order
+func (ClassesProvider) List() []Entry {
+	counts := classLoadingCounts()
+	return []Entry{
+		{Key: "loaded", Description: fmt.Sprintf("%d classes loaded", counts["loaded"])},
+		{Key: "totalLoaded", Description: fmt.Sprintf("%d classes loaded since start-up", counts["totalLoaded"])},
+		{Key: "unloaded", Description: fmt.Sprintf("%d classes unloaded", counts["unloaded"])},
+	}
+}
+
+// Detail returns the count for one of the keys returned by List()
+func (ClassesProvider) Detail(key string) (map[string]string, error) {
+	count, ok := classLoadingCounts()[key]
+	if !ok {
+		return nil, errors.New("no class count named " + key)
+	}
+	return map[string]string{"count": strconv.FormatInt(count, 10)}, nil
+}
@@ -0,0 +1,45 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestClassesProviderCountsLoadsAndUnloads(t *testing.T) {
+	classes, ok := Provider("classes")
+	if !ok {
+		t.Fatalf("Expected a classes InstrumentationProvider")
+	}
+	count := func(key string) int {
+		detail, err := classes.Detail(key)
+		if err != nil {
+			t.Fatalf("Unexpected error getting the %s count: %s", key, err.Error())
+		}
+		n, _ := strconv.Atoi(detail["count"])
+		return n
+	}
+	loaded, total, unloaded := count("loaded"), count("totalLoaded"), count("unloaded")
+
+	RecordClassLoad()
+	RecordClassLoad()
+	RecordClassUnload()
+
+	if count("loaded") != loaded+1 || count("totalLoaded") != total+2 || count("unloaded") != unloaded+1 {
+		t.Errorf("Unexpected counts: loaded %d, totalLoaded %d, unloaded %d",
+			count("loaded"), count("totalLoaded"), count("unloaded"))
+	}
+
+	entries := classes.List()
+	if len(entries) != 3 || entries[0].Key != "loaded" || entries[2].Key != "unloaded" {
+		t.Errorf("Unexpected list of class counts: %v", entries)
+	}
+	if _, err := classes.Detail("nothing"); err == nil {
+		t.Error("Expected an error for an unknown count")
+	}
+}
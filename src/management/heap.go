@@ -0,0 +1,87 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// the live objects in the heap, by class. The map is from the class name (in
+// java/lang/Object format) to its *classCounts. Counts are incremented when an
+// object is allocated and decremented when it's collected.
+var heapClasses sync.Map
+
+type classCounts struct {
+	objects atomic.Int64
+	bytes   atomic.Int64 // the estimated size of the objects
+}
+
+// RecordAlloc records the allocation of an object of the named class, whose size
+// is estimated to be size bytes
+func RecordAlloc(className string, size int64) {
+	c, _ := heapClasses.LoadOrStore(className, &classCounts{})
+	c.(*classCounts).objects.Add(1)
+	c.(*classCounts).bytes.Add(size)
+}
+
+// RecordFree records that an object of the named class, whose size was estimated to
+// be size bytes, has been collected
+func RecordFree(className string, size int64) {
+	if c, ok := heapClasses.Load(className); ok {
+		c.(*classCounts).objects.Add(-1)
+		c.(*classCounts).bytes.Add(-size)
+	}
+}
+
+// HeapProvider is the "heap" InstrumentationProvider. It reports the live objects in
+// the heap by class.
+type HeapProvider struct{}
+
+func (HeapProvider) Name() string { return "heap" }
+
+// List returns an entry for each class that has objects in the heap, sorted by class name
+func (HeapProvider) List() []Entry {
+	var entries []Entry
+	heapClasses.Range(func(key, value interface{}) bool {
+		c := value.(*classCounts)
+		if count := c.objects.Load(); count > 0 {
+			entries = append(entries, Entry{
+				Key:         key.(string),
+				Description: fmt.Sprintf("%d objects, %d bytes estimated", count, c.bytes.Load()),
+			})
+		}
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// Detail returns the number of live objects of the named class, their average
+// estimated size, and their GC generation. Go's garbage collector, which Jacobin
+// uses, is not generational, so all objects are in generation 0.
+func (HeapProvider) Detail(className string) (map[string]string, error) {
+	c, ok := heapClasses.Load(className)
+	if !ok {
+		return nil, errors.New("no objects of class " + className + " have been allocated")
+	}
+
+	count := c.(*classCounts).objects.Load()
+	average := int64(0)
+	if count > 0 {
+		average = c.(*classCounts).bytes.Load() / count
+	}
+	return map[string]string{
+		"count":       strconv.FormatInt(count, 10),
+		"averageSize": strconv.FormatInt(average, 10),
+		"generation":  "0",
+	}, nil
+}
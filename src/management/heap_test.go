@@ -0,0 +1,52 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"testing"
+)
+
+func TestHeapProviderListAndDetail(t *testing.T) {
+	RecordAlloc("test/Widget", 40)
+	RecordAlloc("test/Widget", 60)
+	RecordAlloc("test/Gadget", 24)
+	RecordFree("test/Gadget", 24)
+
+	heap, ok := Provider("heap")
+	if !ok {
+		t.Fatalf("Expected a heap InstrumentationProvider")
+	}
+
+	var widget *Entry
+	for _, entry := range heap.List() {
+		if entry.Key == "test/Gadget" {
+			t.Errorf("Expected classes without live objects to be omitted, got: %v", entry)
+		}
+		if entry.Key == "test/Widget" {
+			e := entry
+			widget = &e
+		}
+	}
+	if widget == nil {
+		t.Fatalf("Expected test/Widget in the heap provider's list")
+	}
+	if widget.Description != "2 objects, 100 bytes estimated" {
+		t.Errorf("Unexpected description of test/Widget: %s", widget.Description)
+	}
+
+	detail, err := heap.Detail("test/Widget")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if detail["count"] != "2" || detail["averageSize"] != "50" || detail["generation"] != "0" {
+		t.Errorf("Unexpected detail for test/Widget: %v", detail)
+	}
+
+	if _, err := heap.Detail("test/Nothing"); err == nil {
+		t.Errorf("Expected an error for a class with no objects")
+	}
+}
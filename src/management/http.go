@@ -0,0 +1,110 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The management endpoints are served over HTTP under /management/. Packages that
+// implement an endpoint register it with Handle(), so that this package doesn't
+// depend on them. (The attach endpoint, for example, is in package jvm.)
+var mux = http.NewServeMux()
+var muxMutex sync.Mutex
+
+// The providers endpoint serves the InstrumentationProviders as JSON:
+//
+//	GET /management/providers/             the names of the providers
+//	GET /management/providers/heap         the heap provider's List()
+//	GET /management/providers/classstats?sort=loadtime
+//	                                       a SortableProvider's list, in the given order
+//	GET /management/providers/heap/<key>   the heap provider's Detail() of key, e.g.,
+//	                                       /management/providers/heap/java/lang/String
+const providersPath = "/management/providers/"
+
+func init() {
+	mux.HandleFunc(providersPath, providersHandler)
+}
+
+func providersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "providers requests must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, providersPath)
+	if path == "" {
+		writeJSON(w, ProviderNames())
+		return
+	}
+
+	name, key, hasKey := strings.Cut(path, "/")
+	p, ok := Provider(name)
+	if !ok {
+		http.Error(w, "no provider named "+name, http.StatusNotFound)
+		return
+	}
+	if !hasKey || key == "" {
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy == "" {
+			writeJSON(w, p.List())
+			return
+		}
+		sortable, ok := p.(SortableProvider)
+		if !ok {
+			http.Error(w, "the list of provider "+name+" can't be sorted", http.StatusBadRequest)
+			return
+		}
+		entries, err := sortable.ListSorted(sortBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, entries)
+		return
+	}
+	detail, err := p.Detail(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, detail)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Handle registers the handler for an endpoint, such as /management/attach
+func Handle(pattern string, handler http.HandlerFunc) {
+	muxMutex.Lock()
+	defer muxMutex.Unlock()
+	mux.HandleFunc(pattern, handler)
+}
+
+// Handler returns the handler that routes requests to the registered endpoints
+func Handler() http.Handler {
+	return mux
+}
+
+// Serve starts serving the management endpoints at the given address (e.g.,
+// localhost:7000) in the background. It returns an error if it can't listen at the
+// address, and otherwise the address it's listening at, which tells the caller the
+// port chosen when the address's port is 0.
+func Serve(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	go func() { _ = http.Serve(listener, mux) }()
+	return listener.Addr().String(), nil
+}
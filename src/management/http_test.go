@@ -0,0 +1,103 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getProviders(t *testing.T, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+// the providers endpoint serves the names of the providers, their lists, and their details
+func TestProvidersEndpoint(t *testing.T) {
+	RecordAlloc("test/Served", 32)
+	defer RecordFree("test/Served", 32)
+
+	var names []string
+	rec := getProviders(t, "/management/providers/")
+	if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil || len(names) == 0 {
+		t.Fatalf("Expected the provider names, got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var entries []Entry
+	rec = getProviders(t, "/management/providers/heap")
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Expected the heap provider's list, got: %d %s", rec.Code, rec.Body.String())
+	}
+	found := false
+	for _, entry := range entries {
+		found = found || entry.Key == "test/Served"
+	}
+	if !found {
+		t.Errorf("Expected test/Served in the heap provider's list, got: %v", entries)
+	}
+
+	var detail map[string]string
+	rec = getProviders(t, "/management/providers/heap/test/Served")
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil || detail["count"] != "1" {
+		t.Errorf("Expected the detail of test/Served, got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = getProviders(t, "/management/providers/nothing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown provider, got: %d", rec.Code)
+	}
+	if rec = getProviders(t, "/management/providers/heap/test/Nothing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown key, got: %d", rec.Code)
+	}
+}
+
+// a provider with two entries, which it lists by key or, if sorted by "size", by size
+type sortableTestProvider struct{}
+
+func (sortableTestProvider) Name() string { return "sortableTest" }
+
+func (sortableTestProvider) List() []Entry {
+	return []Entry{{Key: "a", Description: "2 bytes"}, {Key: "b", Description: "10 bytes"}}
+}
+
+func (p sortableTestProvider) ListSorted(sortBy string) ([]Entry, error) {
+	if sortBy != "size" {
+		return nil, errors.New("can't sort by " + sortBy)
+	}
+	entries := p.List()
+	return []Entry{entries[1], entries[0]}, nil
+}
+
+func (sortableTestProvider) Detail(key string) (map[string]string, error) {
+	return nil, errors.New("no details")
+}
+
+// the sort query parameter gets a SortableProvider's list in the requested order
+func TestProvidersEndpointSorted(t *testing.T) {
+	Register(sortableTestProvider{})
+	defer func() {
+		providersMutex.Lock()
+		delete(providers, "sortableTest")
+		providersMutex.Unlock()
+	}()
+
+	var entries []Entry
+	rec := getProviders(t, "/management/providers/sortableTest?sort=size")
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil || len(entries) != 2 || entries[0].Key != "b" {
+		t.Errorf("Expected the list sorted by size, got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = getProviders(t, "/management/providers/sortableTest?sort=age"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unsupported order, got: %d", rec.Code)
+	}
+	if rec = getProviders(t, "/management/providers/heap?sort=size"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 sorting a provider that can't be sorted, got: %d", rec.Code)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package management exposes the run-time state of the JVM for monitoring. Each area
+// of that state (the heap, for example) is covered by an InstrumentationProvider.
+package management
+
+import (
+	"sort"
+	"sync"
+)
+
+// InstrumentationProvider exposes one area of the JVM's run-time state. List() returns
+// a summary entry for each item the provider tracks (each class in the heap, for
+// example), and Detail() returns the details of one of those items, by key.
+type InstrumentationProvider interface {
+	Name() string
+	List() []Entry
+	Detail(key string) (map[string]string, error)
+}
+
+// SortableProvider is an InstrumentationProvider whose List() can be sorted in orders
+// other than its default one. ListSorted() returns an error for an order it doesn't
+// support.
+type SortableProvider interface {
+	InstrumentationProvider
+	ListSorted(sortBy string) ([]Entry, error)
+}
+
+// Entry is one line of the summary returned by InstrumentationProvider.List()
+type Entry struct {
+	Key         string
+	Description string
+}
+
+var providers = map[string]InstrumentationProvider{
+	"classes": ClassesProvider{},
+	"heap":    HeapProvider{},
+}
+var providersMutex sync.RWMutex
+
+// Register adds an InstrumentationProvider, replacing any provider of the same name
+func Register(p InstrumentationProvider) {
+	providersMutex.Lock()
+	providers[p.Name()] = p
+	providersMutex.Unlock()
+}
+
+// Provider returns the named InstrumentationProvider
+func Provider(name string) (InstrumentationProvider, bool) {
+	providersMutex.RLock()
+	p, ok := providers[name]
+	providersMutex.RUnlock()
+	return p, ok
+}
+
+// ProviderNames returns the names of all the InstrumentationProviders, in sorted order
+func ProviderNames() []string {
+	providersMutex.RLock()
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	providersMutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
@@ -10,6 +10,7 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"sync"
 )
 
 // The various flags that can be passed to the exit() function, reflecting
@@ -25,10 +26,53 @@ const (
 	UNKNOWN_ERROR
 )
 
-// Shutdown is the exit function. Later on, this will check a list of JVM Shutdown hooks
-// before closing down in order to have an orderly exit
+// the shutdown hooks, which are run once, in the order they were added, before the JVM exits
+var hooks []func()
+var hooksMutex sync.Mutex
+
+// AddShutdownHook registers a function to run when the JVM shuts down
+func AddShutdownHook(hook func()) {
+	hooksMutex.Lock()
+	hooks = append(hooks, hook)
+	hooksMutex.Unlock()
+}
+
+// runs the shutdown hooks. Each hook is removed before it's run, so that it runs only
+// once, even if a hook itself causes the JVM to exit.
+func runShutdownHooks() {
+	for {
+		hooksMutex.Lock()
+		if len(hooks) == 0 {
+			hooksMutex.Unlock()
+			return
+		}
+		hook := hooks[0]
+		hooks = hooks[1:]
+		hooksMutex.Unlock()
+		hook()
+	}
+}
+
+// ExitWithStatus exits with the given status, as the program requested via
+// System.exit(status), after running the shutdown hooks. In test mode, it returns the
+// status rather than exiting.
+func ExitWithStatus(status int) int {
+	globals.LoaderWg.Wait()
+	runShutdownHooks()
+	_ = log.Log("shutdown", log.INFO)
+
+	if globals.GetGlobalRef().JacobinName == "test" {
+		return status
+	}
+	os.Exit(status)
+	return status // required by go
+}
+
+// Exit is the exit function. It runs the shutdown hooks before closing down in order to
+// have an orderly exit.
 func Exit(errorCondition ExitStatus) int {
 	globals.LoaderWg.Wait()
+	runShutdownHooks()
 	g := globals.GetGlobalRef()
 	if g.JacobinName == "test" {
 		if errorCondition == OK {
@@ -77,3 +77,23 @@ func TestShutdownReturn(t *testing.T) {
 		t.Errorf("Expecting exit() return value of 0, but got %d", ret)
 	}
 }
+
+func TestShutdownHooksRunOnce(t *testing.T) {
+	globals.InitGlobals("test")
+	gl := globals.GetGlobalRef()
+	gl.JacobinName = "test"
+	_ = log.SetLogLevel(log.WARNING)
+
+	var ran []int
+	AddShutdownHook(func() { ran = append(ran, 1) })
+	AddShutdownHook(func() { ran = append(ran, 2) })
+
+	if status := ExitWithStatus(42); status != 42 {
+		t.Errorf("Expected ExitWithStatus(42) to return 42 in test mode, got: %d", status)
+	}
+	Exit(OK)
+
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Errorf("Expected the hooks to run once, in the order they were added, got: %v", ran)
+	}
+}
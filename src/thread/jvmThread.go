@@ -7,7 +7,7 @@
 package thread
 
 import (
-	"container/list"
+	"jacobin/frames"
 	"jacobin/globals"
 )
 
@@ -17,10 +17,10 @@ import (
 // and performance data.
 
 type ExecThread struct {
-	ID    int        // the thread ID
-	Stack *list.List // the JVM Stack (frame stack, that is) for this thread
-	PC    int        // the program counter (the index to the instruction being executed)
-	Trace bool       // do we Trace instructions?
+	ID    int                // the thread ID
+	Stack *frames.FrameStack // the JVM Stack (frame stack, that is) for this thread
+	PC    int                // the program counter (the index to the instruction being executed)
+	Trace bool               // do we Trace instructions?
 }
 
 func CreateThread() ExecThread {
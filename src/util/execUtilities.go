@@ -31,9 +31,45 @@ func ParseIncomingParamsFromMethTypeString(s string) []byte {
 			params = append(params, 'J')
 		case 'D':
 			params = append(params, 'D')
-		case 'L', '[': // objects and arrays -> object references (TODO: Should this exit at first [ ?)
+		case 'L': // objects -> object references. Skip the class name, which ends with ;
 			params = append(params, 'L')
+			i = skipClassName(paramChars, i)
+		case '[': // arrays -> object references. Skip the rest of the array type.
+			params = append(params, 'L')
+			for i+1 < len(paramChars) && paramChars[i+1] == '[' {
+				i++
+			}
+			if i+1 < len(paramChars) {
+				i++
+				if paramChars[i] == 'L' {
+					i = skipClassName(paramChars, i)
+				}
+			}
 		}
 	}
 	return params
 }
+
+// returns the position of the ; that ends the class name starting at pos, or the
+// position of the last char if there's no ;
+func skipClassName(chars []byte, pos int) int {
+	for pos < len(chars)-1 && chars[pos] != ';' {
+		pos++
+	}
+	return pos
+}
+
+// ParamSlotsFromMethTypeString returns the number of operand-stack slots taken by the
+// parameters in a method's type string. Longs and doubles take two slots; all other
+// types take one.
+func ParamSlotsFromMethTypeString(s string) int {
+	slots := 0
+	for _, p := range ParseIncomingParamsFromMethTypeString(s) {
+		if p == 'J' || p == 'D' {
+			slots += 2
+		} else {
+			slots += 1
+		}
+	}
+	return slots
+}
@@ -22,7 +22,7 @@ func TestParseIncomingParamsFromMethType(t *testing.T) {
 		t.Errorf("Expected parse would return \"FJDL\", got: %s", string(res2))
 	}
 
-	res3 := ParseIncomingParamsFromMethTypeString("[[")
+	res3 := ParseIncomingParamsFromMethTypeString("([[I[Ljava/lang/String;)V")
 	if string(res3) != "LL" {
 		t.Errorf("Expected parse would return value of \"LL\", got: %s", string(res3))
 	}
@@ -32,3 +32,26 @@ func TestParseIncomingParamsFromMethType(t *testing.T) {
 		t.Errorf("Expected parse would return value an empty string, got: %s", string(res4))
 	}
 }
+
+// class names can contain letters that are also type codes, such as the I in Integer
+func TestParseIncomingParamsSkipsClassNames(t *testing.T) {
+	res := ParseIncomingParamsFromMethTypeString("(Ljava/lang/Integer;J[DLjava/lang/String;)V")
+	if string(res) != "LJLL" {
+		t.Errorf("Expected parse would return \"LJLL\", got: %s", string(res))
+	}
+}
+
+func TestParamSlotsFromMethType(t *testing.T) {
+	tests := map[string]int{
+		"()V":                      0,
+		"(II)I":                    2,
+		"(JD)V":                    4,
+		"(Ljava/lang/String;J)V":   3,
+		"([J[Ljava/lang/Double;)V": 2,
+	}
+	for methType, expected := range tests {
+		if slots := ParamSlotsFromMethTypeString(methType); slots != expected {
+			t.Errorf("%s: expected %d slots, got: %d", methType, expected, slots)
+		}
+	}
+}
@@ -155,7 +155,7 @@ func TestRunHelloVerboseClass(t *testing.T) {
 
 	// Here begin the actual tests on the output to stderr and stdout
 	slurp, _ := io.ReadAll(stderr)
-	if !strings.Contains(string(slurp), "Class: Hello, loader: bootstrap") {
+	if !strings.Contains(string(slurp), "Class: Hello, loader: app") {
 		t.Errorf("Got unexpected output to stderr: %s", string(slurp))
 	}
 